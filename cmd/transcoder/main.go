@@ -2,41 +2,246 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/heyjunin/HLSpresso/pkg/analysis"
+	"github.com/heyjunin/HLSpresso/pkg/bench"
+	"github.com/heyjunin/HLSpresso/pkg/clidoc"
+	"github.com/heyjunin/HLSpresso/pkg/compare"
+	"github.com/heyjunin/HLSpresso/pkg/doctor"
+	"github.com/heyjunin/HLSpresso/pkg/encrypt"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
 	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/jobspec"
 	"github.com/heyjunin/HLSpresso/pkg/logger"
 	"github.com/heyjunin/HLSpresso/pkg/progress"
+	"github.com/heyjunin/HLSpresso/pkg/secrets"
+	"github.com/heyjunin/HLSpresso/pkg/server"
 	"github.com/heyjunin/HLSpresso/pkg/transcoder"
+	"github.com/heyjunin/HLSpresso/pkg/version"
+	"github.com/heyjunin/HLSpresso/pkg/watch"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Input options
-	inputPath      string
-	isRemoteInput  bool
-	streamFromURL  bool
-	downloadDir    string
-	allowOverwrite bool
+	inputPath       string
+	isRemoteInput   bool
+	streamFromURL   bool
+	downloadDir     string
+	downloadTimeout time.Duration
+	dlCacheDir      string
+	dlCacheSizeMB   int64
+	inputMirrors    []string
+	useYtDlp        bool
+	ytDlpPath       string
+	gdriveSecret    string
+	dropboxSecret   string
+	probeTimeout    time.Duration
+	encodeTimeout   time.Duration
+	maxJobDuration  time.Duration
+	stallTimeout    time.Duration
+	stallRetries    int
+	allowOverwrite  bool
+	minFreeSpaceMB  uint64
+	skipDiskCheck   bool
+	inputExtensions []string
+	skipFormatCheck bool
+	preflightMode   string
+	preflightTypes  []string
+	preflightWait   time.Duration
+	skipPreflight   bool
+	blankCheck      string
+	verifyInput     bool
+	maxInputLength  time.Duration
+	maxInputSizeMB  int64
+
+	// Input decryption options
+	inputKeyFile    string
+	inputKeySecret  string
+	inputKeyURL     string
+	inputKeyHeaders []string
+	inputCipher     string
+	inputIV         string
 
 	// Output options
-	outputPath string
-	outputType string
+	outputPath       string
+	outputType       string
+	parallel         int
+	jobID            string
+	skipIfComplete   bool
+	force            bool
+	cacheDir         string
+	cacheLink        bool
+	writeManifest    bool
+	writeChecksums   bool
+	encryptKeyFile   string
+	encryptKeySecret string
 
 	// HLS options
 	hlsSegmentDuration int
 	hlsPlaylistType    string
+	sharedAudio        bool
+	hlsVersion         int
+	hlsByteRange       bool
+	tsPCRPeriod        time.Duration
+	tsPATPeriod        time.Duration
+	tsResendHeaders    bool
+	tsPATPMTAtFrames   bool
+	muxOverhead        float64
+	sessionDataSpecs   []string
+	masterTags         []string
+	passMetadata       bool
+	extractCaptions    bool
+	captionLanguage    string
+	trickPlay          bool
+	trickPlayInterval  time.Duration
+	trickPlayWidth     int
+	chunked            bool
+	chunkDuration      time.Duration
+	chunkConcurrency   int
+	audioCodec         string
+	videoPreset        string
+	videoTune          string
+	videoProfile       string
+	videoLevel         string
+	deinterlace        string
+	deinterlacer       string
+	pixelFormat        string
+	mezzanines         []string
+	mp4Height          int
+	outputSpecs        []string
+	introPath          string
+	introDuration      time.Duration
+	outroPath          string
+	outroDuration      time.Duration
+	maxOutputSizeMB    int64
+	ladderName         string
+	autoResolutions    bool
+	resolutionSpecs    []string
+	resolutionsFile    string
+	noUpscale          bool
+	scalePolicy        string
+	dimensionAlignment int
 
 	// Advanced options
 	ffmpegBinary       string
+	autoInstallFFmpeg  bool
 	ffmpegExtraParams  []string
+	ffmpegInputParams  []string
+	paramConflicts     string
+	ffmpegEnvVars      []string
+	ffmpegWorkDir      string
+	ffmpegLogPath      string
+	gracefulStop       bool
+	stopGracePeriod    time.Duration
+	realtimePacing     bool
+	deterministic      bool
 	progressFilePath   string
 	progressFileFormat string
+	progressSocket     string
+	progressPort       int
+	progressStep       float64
+
+	// Preview options
+	generatePreview bool
+	previewFileName string
+	previewDuration float64
+	previewScenes   int
+
+	// Analysis options
+	analyzeMedia       bool
+	analysisReportPath string
+
+	// Upload options
+	uploadURL       string
+	uploadHeaders   []string
+	uploadWebDAV    bool
+	uploadKey       string
+	uploadMD5       bool
+	uploadRetries   int
+	publishPartial  bool
+	publishInterval time.Duration
+
+	// Hook options
+	preHook  string
+	postHook string
+
+	// Preview server options
+	serverAddr     string
+	serverPlaylist string
+	serverNoPlayer bool
+
+	// Plan options
+	planReportPath string
+
+	// Probe options
+	probeReportPath string
+
+	// Bench options
+	benchReportPath string
+	benchSeconds    float64
+	benchHeight     int
+	benchBitrate    string
+	benchEncoders   []string
+	benchPresets    []string
+	benchHardware   bool
+
+	// Compare options
+	compareOutput      string
+	compareReportPath  string
+	compareSpecs       [2]string
+	compareLadders     [2]string
+	compareResolutions [2][]string
+	compareHeight      int
+	compareSeconds     float64
+	compareWorkDir     string
+
+	// Output control options
+	quiet       bool
+	verbosity   int
+	errorFormat string
+
+	// Documentation options
+	docsFormat string
+	docsDir    string
+
+	// Doctor options
+	doctorDirs    []string
+	doctorURL     string
+	doctorJSON    bool
+	doctorTimeout time.Duration
+
+	// Version options
+	versionJSON        bool
+	versionCheckUpdate bool
+
+	// Watch options
+	watchInputDir     string
+	watchOutputDir    string
+	watchOutputName   string
+	watchArchiveDir   string
+	watchFailedDir    string
+	watchDelete       bool
+	watchStableFor    time.Duration
+	watchSkipExisting bool
+
+	// Job options
+	jobSpecPath   string
+	jobResultPath string
 )
 
 func main() {
@@ -49,39 +254,371 @@ func main() {
 		Short: "☕ HLSpresso - Tool for generating HLS adaptive streams",
 		Long: `☕ HLSpresso - A powerful video transcoding tool that converts video files to HLS adaptive streaming format.
 It can download videos from remote URLs and generate multiple quality levels.`,
-		Run: runTranscoder,
+		Version:           version.Get().Version,
+		PersistentPreRunE: configureOutput,
+		Run:               runTranscoder,
 	}
 
+	// Output control flags, shared by every subcommand
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors and the final result")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Log debug messages (-v) and also every ffmpeg output line (-vv)")
+	rootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", "text", "Error output format: 'text', or 'json' to also print the error as the last stderr line")
+	rootCmd.RegisterFlagCompletionFunc("error-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+
+	// Transcoding flags, shared with the watch subcommand
+	addTranscodeFlags(rootCmd)
+
 	// Input flags
-	rootCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Input file path or URL (required)")
+	rootCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Input file path, wildcard pattern (e.g. 'videos/*.mov') or URL (required)")
 	rootCmd.Flags().BoolVar(&isRemoteInput, "remote", false, "Treat input as a remote URL (downloads first)")
 	rootCmd.Flags().BoolVar(&streamFromURL, "stream", false, "Attempt to stream directly from input URL (implies remote)")
 	rootCmd.Flags().StringVar(&downloadDir, "download-dir", "downloads", "Directory to save downloaded files (if not streaming)")
-	rootCmd.Flags().BoolVar(&allowOverwrite, "overwrite", false, "Allow overwriting existing files")
+	rootCmd.Flags().StringSliceVar(&inputMirrors, "input-mirror", nil, "URL of a copy of the remote input, tried in order if the input fails to download (repeatable)")
+	rootCmd.Flags().BoolVar(&useYtDlp, "yt-dlp", false, "Fetch the input URL with yt-dlp, e.g. the page of a video on a supported platform")
+	rootCmd.Flags().StringVar(&ytDlpPath, "yt-dlp-path", transcoder.DefaultYtDlpBinary, "Path to the yt-dlp executable")
+	rootCmd.Flags().StringVar(&gdriveSecret, "gdrive-token-secret", "", "Secret reference of the Google Drive access token used to download Drive share links (env:, file:, aws-sm:, aws-kms: or vault:)")
+	rootCmd.Flags().StringVar(&dropboxSecret, "dropbox-token-secret", "", "Secret reference of the Dropbox access token used to download Dropbox share links (env:, file:, aws-sm:, aws-kms: or vault:)")
+	rootCmd.Flags().StringVar(&dlCacheDir, "download-cache-dir", "", "Keep downloaded inputs in this directory, keyed by URL and ETag, and share them between jobs")
+	rootCmd.Flags().Int64Var(&dlCacheSizeMB, "download-cache-size", 0, "Maximum size in MB of --download-cache-dir; least recently used inputs are evicted (0 disables)")
+	rootCmd.Flags().DurationVar(&downloadTimeout, "download-timeout", transcoder.DefaultDownloadTimeout, "Time allowed to download a remote input")
+	rootCmd.Flags().Uint64Var(&minFreeSpaceMB, "min-free-space", 0, "Minimum free disk space in MB required before writing (0 uses the defaults)")
+	rootCmd.Flags().BoolVar(&skipDiskCheck, "skip-disk-check", false, "Disable free disk space checks")
+	rootCmd.Flags().BoolVar(&skipFormatCheck, "skip-format-check", false, "Do not validate the format of local inputs")
+	rootCmd.Flags().StringVar(&preflightMode, "preflight", "head", "How --stream checks http(s) inputs first: 'head', 'get' (range request) or 'none'")
+	rootCmd.Flags().StringSliceVar(&preflightTypes, "preflight-content-type", nil, "Content-Type accepted by the --stream check (repeatable; 'video/' matches every subtype, '*' anything)")
+	rootCmd.Flags().DurationVar(&preflightWait, "preflight-timeout", transcoder.DefaultPreflightTimeout, "Time allowed for each --stream preflight request")
+	rootCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "Do not check --stream inputs before ffmpeg opens them (same as --preflight none)")
 
 	// Output flags
-	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output directory or file path (required)")
-	rootCmd.Flags().StringVarP(&outputType, "type", "t", "hls", "Output type: 'hls' or 'mp4'")
+	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output directory or file path; may use {basename} (or {name}), {date}, {resolution} and {jobid} (required)")
+	rootCmd.Flags().StringVar(&jobID, "job-id", "", "Job identifier substituted for {jobid} in --output and added to every log line, progress event and error (random by default)")
+	rootCmd.Flags().IntVar(&parallel, "parallel", 1, "Number of files transcoded at once when --input is a wildcard pattern")
+	rootCmd.Flags().Int64Var(&maxOutputSizeMB, "max-output-size", 0, "Maximum total HLS output size in MB; lowers bitrates or drops top renditions to fit (0 disables)")
 
-	// HLS options
-	rootCmd.Flags().IntVar(&hlsSegmentDuration, "hls-segment-duration", 10, "HLS segment duration in seconds")
-	rootCmd.Flags().StringVar(&hlsPlaylistType, "hls-playlist-type", "vod", "HLS playlist type (vod or event)")
+	// Upload options
+	rootCmd.Flags().StringVar(&uploadURL, "upload-url", "", "Publish the output to this base URL once it is complete (http(s):// PUT, ftp:// or sftp://)")
+	rootCmd.Flags().StringArrayVar(&uploadHeaders, "upload-header", []string{}, "Header added to upload requests as 'Name: value' (repeatable)")
+	rootCmd.Flags().BoolVar(&uploadWebDAV, "upload-webdav", false, "Create remote directories with WebDAV MKCOL before uploading")
+	rootCmd.Flags().StringVar(&uploadKey, "upload-key", "", "SSH private key for sftp:// uploads")
+	rootCmd.Flags().BoolVar(&uploadMD5, "upload-content-md5", false, "Send a Content-MD5 header with http(s) uploads so the origin can reject corrupted files")
+	rootCmd.Flags().IntVar(&uploadRetries, "upload-retries", 3, "Number of times a failed file upload is retried")
+	rootCmd.Flags().BoolVar(&publishPartial, "publish-partial", false, "Upload the completed HLS segments and EVENT playlists while encoding, converted to VOD at the end, so viewers can start watching early")
+	rootCmd.Flags().DurationVar(&publishInterval, "publish-interval", transcoder.DefaultPublishInterval, "Interval between two uploads of the partial output with --publish-partial")
 
 	// Advanced options
-	rootCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
-	rootCmd.Flags().StringArrayVar(&ffmpegExtraParams, "ffmpeg-param", []string{}, "Extra parameters to pass to ffmpeg")
+	rootCmd.Flags().BoolVar(&autoInstallFFmpeg, "auto-install-ffmpeg", false, "Download a pinned static ffmpeg/ffprobe build into the user cache and use it")
 	rootCmd.Flags().StringVar(&progressFilePath, "progress-file", "", "Path to file for writing progress percentage (e.g., progress.txt)")
 	rootCmd.Flags().StringVar(&progressFileFormat, "progress-file-format", "text", "Format for progress file: 'text' (percentage only) or 'json' (full event)")
+	rootCmd.Flags().StringVar(&progressSocket, "progress-socket", "", "Serve progress over HTTP on this Unix socket path (GET /progress, GET /events)")
+	rootCmd.Flags().IntVar(&progressPort, "progress-port", 0, "Serve progress over HTTP on this localhost TCP port (GET /progress, GET /events)")
+	rootCmd.Flags().Float64Var(&progressStep, "progress-granularity", 0, "Only write the progress file and endpoint events every N percent and at step transitions (0 writes every update)")
+
+	// Preview options
+	rootCmd.Flags().BoolVar(&generatePreview, "preview", false, "Generate a short scene-based preview MP4 next to the output")
+	rootCmd.Flags().StringVar(&previewFileName, "preview-name", "preview.mp4", "File name of the preview clip")
+	rootCmd.Flags().Float64Var(&previewDuration, "preview-duration", 6, "Total length of the preview clip in seconds")
+	rootCmd.Flags().IntVar(&previewScenes, "preview-scenes", 3, "Number of scenes in the preview clip")
+
+	// Analysis options
+	rootCmd.Flags().BoolVar(&analyzeMedia, "analyze", false, "Write a loudness and silence analysis report (analysis.json) next to the output")
 
 	// Mark required flags
 	rootCmd.MarkFlagRequired("input")
 	rootCmd.MarkFlagRequired("output")
 
+	// Analyze subcommand
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Report loudness (LUFS, true peak) and silence/black segments as JSON",
+		Run:   runAnalyze,
+	}
+	analyzeCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Input file path or URL (required)")
+	analyzeCmd.Flags().StringVarP(&analysisReportPath, "output", "o", "", "Path to write the JSON report (defaults to stdout)")
+	analyzeCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	analyzeCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(analyzeCmd)
+
+	// Bench subcommand
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Encode a short sample across presets and hardware encoders and report speed and quality as JSON",
+		Run:   runBench,
+	}
+	benchCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Sample file path or URL (required)")
+	benchCmd.Flags().StringVarP(&benchReportPath, "output", "o", "", "Path to write the JSON report (defaults to stdout)")
+	benchCmd.Flags().Float64Var(&benchSeconds, "duration", 10, "Seconds of the input encoded for each preset")
+	benchCmd.Flags().IntVar(&benchHeight, "height", 720, "Height the sample is scaled to")
+	benchCmd.Flags().StringVar(&benchBitrate, "bitrate", "2800k", "Target video bitrate, identical for every preset so that their quality can be compared")
+	benchCmd.Flags().StringArrayVar(&benchEncoders, "encoder", []string{bench.SoftwareEncoder}, "ffmpeg video encoder to benchmark (repeatable, e.g. h264_nvenc)")
+	benchCmd.Flags().StringArrayVar(&benchPresets, "preset", []string{}, "Encoder preset to benchmark (repeatable, defaults to a range of presets of each encoder)")
+	benchCmd.Flags().BoolVar(&benchHardware, "hardware", true, "Also benchmark the hardware H.264 encoders (NVENC, Quick Sync, VideoToolbox) the ffmpeg build provides")
+	benchCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	benchCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(benchCmd)
+
+	// Compare subcommand
+	compareCmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Encode a sample with two option sets and produce a side-by-side MP4 and a quality report as JSON",
+		Run:   runCompare,
+	}
+	compareCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Input file path (required)")
+	compareCmd.Flags().StringVarP(&compareOutput, "output", "o", "", "Path of the side-by-side MP4, A on the left and B on the right (required)")
+	compareCmd.Flags().StringVar(&compareReportPath, "report", "", "Path to write the JSON report (defaults to stdout)")
+	for i, side := range []string{"a", "b"} {
+		compareCmd.Flags().StringVar(&compareSpecs[i], side, "", "Job spec (JSON transcoder options) of option set "+strings.ToUpper(side)+" (defaults to the default options)")
+		compareCmd.Flags().StringVar(&compareLadders[i], side+"-ladder", "", "Resolution ladder of option set "+strings.ToUpper(side)+": "+strings.Join(hls.LadderNames(), ", "))
+		compareCmd.Flags().StringArrayVar(&compareResolutions[i], side+"-resolution", []string{}, "Custom HLS rendition of option set "+strings.ToUpper(side)+" as WIDTHxHEIGHT[@FPS]:VIDEO_BITRATE[:AUDIO_BITRATE] (repeatable)")
+	}
+	compareCmd.Flags().IntVar(&compareHeight, "height", 720, "Height the renditions are compared at")
+	compareCmd.Flags().Float64Var(&compareSeconds, "duration", 30, "Seconds of the input encoded by each option set")
+	compareCmd.Flags().StringVar(&compareWorkDir, "work-dir", "", "Directory keeping the outputs of both option sets (defaults to a temporary directory, removed afterwards)")
+	compareCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	compareCmd.MarkFlagRequired("input")
+	compareCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(compareCmd)
+
+	// Plan subcommand
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the renditions, estimated sizes and encode time as JSON without encoding",
+		Run:   runPlan,
+	}
+	planCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Input file path or URL (required)")
+	planCmd.Flags().StringVarP(&outputType, "type", "t", "hls", "Output type: 'hls' or 'mp4'")
+	planCmd.Flags().StringVarP(&planReportPath, "output", "o", "", "Path to write the JSON plan (defaults to stdout)")
+	planCmd.Flags().BoolVar(&autoResolutions, "auto-resolutions", true, "Derive the HLS renditions from the input resolution (default unless a ladder or resolutions are given)")
+	planCmd.Flags().StringVar(&ladderName, "ladder", "", "Resolution ladder: "+strings.Join(hls.LadderNames(), ", ")+", or 'auto' to derive it from the input")
+	planCmd.Flags().StringArrayVar(&resolutionSpecs, "resolution", []string{}, "Custom HLS rendition as WIDTHxHEIGHT[@FPS]:VIDEO_BITRATE[:AUDIO_BITRATE] (repeatable)")
+	planCmd.Flags().StringVar(&resolutionsFile, "resolutions-file", "", "JSON file with a custom list of HLS renditions")
+	planCmd.Flags().StringVar(&scalePolicy, "scale-policy", "exact", "How automatic resolutions handle the input aspect ratio: exact, pad or crop (pad and crop produce 16:9 renditions)")
+	planCmd.Flags().IntVar(&dimensionAlignment, "dimension-alignment", 2, "Round automatic resolution dimensions to a multiple of 2 or 4")
+	planCmd.Flags().BoolVar(&noUpscale, "no-upscale", false, "Skip renditions larger than the input video when using --ladder or --resolution")
+	planCmd.Flags().Int64Var(&maxOutputSizeMB, "max-output-size", 0, "Maximum total HLS output size in MB (0 disables)")
+	planCmd.Flags().Float64Var(&muxOverhead, "mux-overhead", 0, "Share of the segment size taken by the container in size estimates (e.g. 0.06, 0 uses 6% for MPEG-TS)")
+	planCmd.Flags().BoolVar(&sharedAudio, "hls-shared-audio", false, "Plan a single audio rendition shared by every variant")
+	planCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	planCmd.Flags().BoolVar(&realtimePacing, "realtime", false, "Estimate the encode time for --realtime pacing")
+	planCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(planCmd)
+
+	// Probe subcommand
+	probeCmd := &cobra.Command{
+		Use:   "probe",
+		Short: "Print the container, streams and suggested auto ladder of an input as JSON",
+		Run:   runProbe,
+	}
+	probeCmd.Flags().StringVarP(&inputPath, "input", "i", "", "Input file path or URL (required)")
+	probeCmd.Flags().StringVarP(&probeReportPath, "output", "o", "", "Path to write the JSON media info (defaults to stdout)")
+	probeCmd.Flags().StringVar(&scalePolicy, "scale-policy", "exact", "How the suggested ladder handles the input aspect ratio: exact, pad or crop (pad and crop produce 16:9 renditions)")
+	probeCmd.Flags().IntVar(&dimensionAlignment, "dimension-alignment", 2, "Round suggested ladder dimensions to a multiple of 2 or 4")
+	probeCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(probeCmd)
+
+	// Preview server subcommand
+	serverCmd := &cobra.Command{
+		Use:   "preview <output-dir>",
+		Short: "Serve a generated HLS output directory over HTTP with a test player",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPreviewServer,
+	}
+	serverCmd.Flags().StringVar(&serverAddr, "addr", "localhost:8080", "Address to listen on")
+	serverCmd.Flags().StringVar(&serverPlaylist, "playlist", "master.m3u8", "Master playlist opened by the player, relative to the output directory")
+	serverCmd.Flags().BoolVar(&serverNoPlayer, "no-player", false, "Do not serve the hls.js test page")
+	rootCmd.AddCommand(serverCmd)
+
+	// Doctor subcommand
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check ffmpeg/ffprobe, codecs, disk space, permissions and network access",
+		Args:  cobra.NoArgs,
+		Run:   runDoctor,
+	}
+	doctorCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	doctorCmd.Flags().StringArrayVar(&doctorDirs, "dir", []string{".", "downloads"}, "Directory HLSpresso will write to (repeatable)")
+	doctorCmd.Flags().Uint64Var(&minFreeSpaceMB, "min-free-space", 0, "Minimum free disk space in MB expected in each directory (0 uses the default)")
+	doctorCmd.Flags().StringVar(&doctorURL, "url", "https://github.com", "URL fetched to check network access (empty skips the check)")
+	doctorCmd.Flags().DurationVar(&doctorTimeout, "timeout", doctor.DefaultTimeout, "Timeout of each check")
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Print the report as JSON")
+	doctorCmd.MarkFlagDirname("dir")
+	rootCmd.AddCommand(doctorCmd)
+
+	// Version subcommand
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version, build metadata and detected ffmpeg version",
+		Args:  cobra.NoArgs,
+		Run:   runVersion,
+	}
+	versionCmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the version information as JSON")
+	versionCmd.Flags().BoolVar(&versionCheckUpdate, "check-update", false, "Query GitHub for a newer release")
+	rootCmd.AddCommand(versionCmd)
+
+	// Watch subcommand
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch a directory and transcode every file added to it",
+		Long: `Watch a directory (hot folder) and transcode every file added to it, one at a time.
+Files are picked up once their size has stopped changing, then moved to the archive
+directory, or to the failed directory if transcoding fails.`,
+		Args: cobra.NoArgs,
+		Run:  runWatch,
+	}
+	watchCmd.Flags().StringVar(&watchInputDir, "input-dir", "", "Directory to watch for new files (required)")
+	watchCmd.Flags().StringVar(&watchOutputDir, "output-dir", "", "Directory receiving one output per input (required)")
+	watchCmd.Flags().StringVar(&watchOutputName, "output-name", transcoder.OutputVarBasename, "Output path relative to --output-dir; may use {basename}, {date}, {resolution} and {jobid} (.mp4 is appended for -t mp4 by default)")
+	watchCmd.Flags().StringVar(&watchArchiveDir, "archive-dir", "", "Directory processed inputs are moved to (defaults to <input-dir>/processed)")
+	watchCmd.Flags().StringVar(&watchFailedDir, "failed-dir", "", "Directory inputs that failed to transcode are moved to (defaults to <input-dir>/failed)")
+	watchCmd.Flags().BoolVar(&watchDelete, "delete-processed", false, "Delete processed inputs instead of archiving them")
+	watchCmd.Flags().DurationVar(&watchStableFor, "stable-for", watch.DefaultStableFor, "How long a file must stay unchanged before it is considered fully uploaded")
+	watchCmd.Flags().BoolVar(&watchSkipExisting, "skip-existing", false, "Ignore files already in the input directory when the watch starts")
+	addTranscodeFlags(watchCmd)
+	watchCmd.MarkFlagRequired("input-dir")
+	watchCmd.MarkFlagRequired("output-dir")
+	watchCmd.MarkFlagDirname("input-dir")
+	watchCmd.MarkFlagDirname("output-dir")
+	watchCmd.MarkFlagDirname("archive-dir")
+	watchCmd.MarkFlagDirname("failed-dir")
+	rootCmd.AddCommand(watchCmd)
+
+	// Decrypt subcommand
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt <path>...",
+		Short: "Decrypt outputs encrypted with --encrypt-key-file",
+		Long: `Decrypt .enc files produced with --encrypt-key-file next to them, without the .enc
+suffix. Directories are searched recursively for .enc files. Encrypted files are kept.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  runDecrypt,
+	}
+	decryptCmd.Flags().StringVar(&encryptKeyFile, "key-file", "", "File with the 32-byte key the outputs were encrypted with (this or --key-secret is required)")
+	decryptCmd.Flags().StringVar(&encryptKeySecret, "key-secret", "", "Secret reference of the key the outputs were encrypted with (env:, file:, aws-sm:, aws-kms: or vault:)")
+	rootCmd.AddCommand(decryptCmd)
+
+	// Job subcommand, for container orchestrators
+	jobCmd := &cobra.Command{
+		Use:   "job",
+		Short: "Run one transcoding job described by a JSON spec, for Kubernetes Jobs and Argo",
+		Long: `Run one transcoding job whose options are read from a JSON spec (the fields of
+transcoder.Options, e.g. {"InputPath": "/in/a.mp4", "OutputPath": "/out/a"}), typically
+mounted from a ConfigMap. Progress events are printed to stdout as JSON Lines and logs
+go to stderr. When the job ends, including on SIGTERM, the result (status, exit code,
+output, error and last progress) is written to --result before exiting with the code
+of the error (130 when interrupted).`,
+		Args: cobra.NoArgs,
+		Run:  runJobSpec,
+	}
+	jobCmd.Flags().StringVar(&jobSpecPath, "spec", "", "JSON file with the options of the job (required)")
+	jobCmd.Flags().StringVar(&jobResultPath, "result", "", "File the JSON result is written to when the job ends (e.g. /dev/termination-log)")
+	jobCmd.Flags().Float64Var(&progressStep, "progress-granularity", 0, "Only print progress events when the percentage crosses a multiple of this value (0 prints every update)")
+	jobCmd.Flags().StringVar(&encryptKeyFile, "encrypt-key-file", "", "Encrypt the output at rest (AES-256-GCM) with the 32-byte key in this file")
+	jobCmd.Flags().StringVar(&encryptKeySecret, "encrypt-key-secret", "", "Like --encrypt-key-file, with the key read from a secret reference (env:, file:, aws-sm:, aws-kms: or vault:)")
+	jobCmd.Flags().StringVar(&preHook, "pre-hook", "", "Shell command run before the input is handled, with the job manifest as JSON on stdin")
+	jobCmd.Flags().StringVar(&postHook, "post-hook", "", "Shell command run once the output is complete, with the job manifest as JSON on stdin")
+	jobCmd.MarkFlagRequired("spec")
+	rootCmd.AddCommand(jobCmd)
+
+	// Documentation subcommand, used to build man pages and the flag reference
+	docsCmd := &cobra.Command{
+		Use:    "gen-docs",
+		Short:  "Generate man pages or Markdown reference pages for every command",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		Run:    runGenDocs,
+	}
+	docsCmd.Flags().StringVar(&docsFormat, "format", "man", "Documentation format: 'man' or 'markdown'")
+	docsCmd.Flags().StringVar(&docsDir, "dir", "docs", "Directory to write the pages to")
+	rootCmd.AddCommand(docsCmd)
+
+	// Shell completion of flag values (the completion command is provided by cobra)
+	registerCompletions(rootCmd, planCmd, probeCmd, analyzeCmd, benchCmd, compareCmd, doctorCmd, versionCmd, watchCmd, decryptCmd, jobCmd)
+	serverCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+
 	// Execute command
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		reportError(errors.Wrap(err, errors.ValidationError, "Invalid command line usage", 0), errors.ExitUsage)
+		os.Exit(errors.ExitUsage)
+	}
+}
+
+// exitWithError logs message and err at the error level and exits with the
+// status errors.ExitCode maps err to.
+func exitWithError(message string, err error, data map[string]interface{}) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	code := errors.ExitCode(err)
+	data["error"] = err.Error()
+	data["exit_code"] = code
+	logger.Error(message, "main", data)
+
+	var sErr *errors.StructuredError
+	if !stderrors.As(err, &sErr) {
+		sErr = errors.Wrap(err, errors.UnknownError, message, 0)
+	}
+	reportError(sErr, code)
+	os.Exit(code)
+}
+
+// exitInvalid logs message at the error level and exits with
+// errors.ExitValidation; it reports invalid flag values.
+func exitInvalid(message string, data map[string]interface{}) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	details := make([]string, 0, len(keys))
+	for _, key := range keys {
+		details = append(details, fmt.Sprintf("%s: %v", key, data[key]))
+	}
+
+	data["exit_code"] = errors.ExitValidation
+	logger.Error(message, "main", data)
+	reportError(errors.New(errors.ValidationError, message, strings.Join(details, ", "), 0), errors.ExitValidation)
+	os.Exit(errors.ExitValidation)
+}
+
+// reportError prints err and the exit code as a single JSON line to stderr when
+// --error-format is json, so wrappers can parse the failure from the last line.
+func reportError(err *errors.StructuredError, code int) {
+	if !strings.EqualFold(errorFormat, "json") {
+		return
+	}
+	content, marshalErr := json.Marshal(struct {
+		*errors.StructuredError
+		ExitCode int `json:"exit_code"`
+	}{err, code})
+	if marshalErr != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(content))
+}
+
+// configureOutput sets the log level from --quiet and --verbose: errors only,
+// info (default), or debug.
+func configureOutput(cmd *cobra.Command, args []string) error {
+	if quiet && verbosity > 0 {
+		return fmt.Errorf("--quiet and --verbose cannot be used together")
+	}
+	if format := strings.ToLower(errorFormat); format != "text" && format != "json" {
+		return fmt.Errorf("invalid --error-format value %q: must be 'text' or 'json'", errorFormat)
 	}
+	switch {
+	case quiet:
+		logger.SetLevel(logger.ErrorLevel)
+	case verbosity > 0:
+		logger.SetLevel(logger.DebugLevel)
+	default:
+		logger.SetLevel(logger.InfoLevel)
+	}
+	return nil
 }
 
 func runTranscoder(cmd *cobra.Command, args []string) {
@@ -104,30 +641,104 @@ func runTranscoder(cmd *cobra.Command, args []string) {
 	// Validate progress file format
 	progressFileFormatLower := strings.ToLower(progressFileFormat)
 	if progressFileFormatLower != "text" && progressFileFormatLower != "json" {
-		logger.Fatal("Invalid --progress-file-format value. Must be 'text' or 'json'", "main", map[string]interface{}{
+		exitInvalid("Invalid --progress-file-format value. Must be 'text' or 'json'", map[string]interface{}{
 			"value": progressFileFormat,
 		})
 		return
 	}
 
-	// Create progress reporter with options
+	if progressStep < 0 || progressStep > 100 {
+		exitInvalid("--progress-granularity must be between 0 and 100", map[string]interface{}{
+			"value": progressStep,
+		})
+		return
+	}
+
+	// Expand wildcard inputs into one job per matching file
+	inputs, err := expandInput(inputPath)
+	if err != nil {
+		exitInvalid("Invalid --input pattern", map[string]interface{}{
+			"input": inputPath,
+			"error": err.Error(),
+		})
+		return
+	}
+	if parallel < 1 {
+		exitInvalid("--parallel must be at least 1", map[string]interface{}{
+			"value": parallel,
+		})
+		return
+	}
+	if len(inputs) > 1 {
+		if !perInputOutput(outputPath) {
+			exitInvalid("--output must contain {name}, {basename} or {jobid} when --input matches several files", map[string]interface{}{
+				"input":   inputPath,
+				"matches": len(inputs),
+			})
+			return
+		}
+		if progressFilePath != "" || progressSocket != "" || progressPort != 0 {
+			exitInvalid("--progress-file, --progress-socket and --progress-port cannot be used when --input matches several files", nil)
+			return
+		}
+	}
+
+	// Create progress reporter options
 	reporterOpts := []progress.ReporterOption{}
+	if quiet {
+		reporterOpts = append(reporterOpts, progress.WithBarWriter(io.Discard))
+	}
 	if progressFilePath != "" {
 		reporterOpts = append(reporterOpts, progress.WithProgressFile(progressFilePath))
 		reporterOpts = append(reporterOpts, progress.WithProgressFileFormat(progressFileFormatLower))
 	}
-	progressReporter := progress.NewReporter(reporterOpts...)
+	if progressSocket != "" && progressPort != 0 {
+		exitInvalid("Only one of --progress-socket and --progress-port can be used", nil)
+		return
+	}
+	if progressSocket != "" {
+		reporterOpts = append(reporterOpts, progress.WithProgressEndpoint("unix", progressSocket))
+	}
+	if progressPort != 0 {
+		reporterOpts = append(reporterOpts, progress.WithProgressEndpoint("tcp", fmt.Sprintf("127.0.0.1:%d", progressPort)))
+	}
+	if progressStep > 0 {
+		reporterOpts = append(reporterOpts, progress.WithGranularity(progressStep))
+	}
 
-	// Determine output type
-	var outType transcoder.OutputType
-	switch strings.ToLower(outputType) {
-	case "hls":
-		outType = transcoder.HLSOutput
-	case "mp4":
-		outType = transcoder.MP4Output
-	default:
-		logger.Fatal("Invalid output type", "main", map[string]interface{}{
-			"type": outputType,
+	// Build the transcoding options shared with the watch command
+	options, err := buildOptions(cmd)
+	if err != nil {
+		exitWithError("Invalid transcoding options", err, nil)
+		return
+	}
+
+	// Parse upload headers and resolve the credentials of the upload URL
+	headers, err := parseHeaders(uploadHeaders)
+	if err != nil {
+		exitInvalid("Invalid --upload-header value", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	resolvedUploadURL, err := expandSecrets(uploadURL)
+	if err != nil {
+		exitInvalid("Invalid --upload-url value", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	googleDriveToken, err := resolveToken(gdriveSecret)
+	if err != nil {
+		exitInvalid("Invalid --gdrive-token-secret value", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	dropboxToken, err := resolveToken(dropboxSecret)
+	if err != nil {
+		exitInvalid("Invalid --dropbox-token-secret value", map[string]interface{}{
+			"error": err.Error(),
 		})
 		return
 	}
@@ -135,7 +746,7 @@ func runTranscoder(cmd *cobra.Command, args []string) {
 	// Determine input type and streaming
 	isActuallyRemote := isRemoteInput || streamFromURL || (strings.HasPrefix(inputPath, "http://") || strings.HasPrefix(inputPath, "https://"))
 	if streamFromURL && !isActuallyRemote {
-		logger.Fatal("--stream flag can only be used with URL inputs", "main", nil)
+		exitInvalid("--stream flag can only be used with URL inputs", nil)
 		return
 	}
 	if !streamFromURL && isActuallyRemote && !isRemoteInput {
@@ -143,51 +754,86 @@ func runTranscoder(cmd *cobra.Command, args []string) {
 		logger.Info("Detected URL input, using download mode (use --stream to stream directly)", "main", nil)
 	}
 
-	// Create transcoder options
-	options := transcoder.Options{
-		// Input options
-		InputPath:      inputPath,
-		IsRemoteInput:  isActuallyRemote, // Set based on --remote, --stream, or URL detection
-		StreamFromURL:  streamFromURL,    // Set by the --stream flag
-		DownloadDir:    downloadDir,
-		AllowOverwrite: allowOverwrite,
+	// Download (or reuse) the managed ffmpeg build if requested
+	if autoInstallFFmpeg {
+		bins, err := ffmpeg.Ensure(ctx, ffmpeg.InstallOptions{AddToPath: true})
+		if err != nil {
+			exitWithError("Failed to install ffmpeg", err, nil)
+			return
+		}
+		options.FFmpegBinary = bins.FFmpeg
+	}
 
-		// Output options
-		OutputPath: outputPath,
-		OutputType: outType,
+	// Add the options of the root command only
+	options.InputPath = inputPath
+	options.IsRemoteInput = isActuallyRemote // Set based on --remote, --stream, or URL detection
+	options.StreamFromURL = streamFromURL    // Set by the --stream flag
+	options.DownloadDir = downloadDir
+	options.InputMirrors = inputMirrors
+	options.UseYtDlp = useYtDlp
+	options.YtDlpBinary = ytDlpPath
+	options.DownloadCacheDir = dlCacheDir
+	options.DownloadCacheMaxSize = dlCacheSizeMB * 1024 * 1024
+	options.GoogleDriveToken = googleDriveToken
+	options.DropboxToken = dropboxToken
+	options.DownloadTimeout = downloadTimeout
+	options.SkipInputFormatCheck = skipFormatCheck
+	options.PreflightMode = transcoder.PreflightMode(preflightMode)
+	options.PreflightContentTypes = preflightTypes
+	options.PreflightTimeout = preflightWait
+	options.SkipPreflight = skipPreflight
+	options.MinFreeDiskSpace = minFreeSpaceMB * 1024 * 1024
+	options.SkipDiskSpaceCheck = skipDiskCheck
+	options.OutputPath = outputPath
+	options.JobID = jobID
+	options.MaxOutputSizeBytes = maxOutputSizeMB * 1024 * 1024
 
-		// HLS options
-		HLSSegmentDuration: hlsSegmentDuration,
-		HLSPlaylistType:    hlsPlaylistType,
-		HLSResolutions:     hls.DefaultResolutions,
+	// Upload options
+	options.UploadURL = resolvedUploadURL
+	options.UploadHeaders = headers
+	options.UploadCreateCollections = uploadWebDAV
+	options.UploadIdentityFile = uploadKey
+	options.UploadContentMD5 = uploadMD5
+	options.UploadRetries = uploadRetries
+	options.PublishPartial = publishPartial
+	options.PublishInterval = publishInterval
 
-		// Advanced options
-		FFmpegBinary:      ffmpegBinary,
-		FFmpegExtraParams: ffmpegExtraParams,
+	// Preview and analysis options
+	options.GeneratePreview = generatePreview
+	options.PreviewFileName = previewFileName
+	options.PreviewDuration = previewDuration
+	options.PreviewScenes = previewScenes
+	options.AnalyzeMedia = analyzeMedia
+
+	if len(inputs) > 1 {
+		runBatch(ctx, options, inputs, reporterOpts)
+		return
 	}
+	options.InputPath = inputs[0]
+
+	// Create progress reporter
+	progressReporter := progress.NewReporter(reporterOpts...)
+	defer progressReporter.Close()
 
 	// Create transcoder
 	trans, err := transcoder.New(options, progressReporter)
 	if err != nil {
-		logger.Fatal("Failed to create transcoder", "main", map[string]interface{}{
-			"error": err.Error(),
-		})
+		exitWithError("Failed to create transcoder", err, nil)
 		return
 	}
 
 	// Start transcoding
 	logger.Info("Starting transcoder", "main", map[string]interface{}{
-		"input":  inputPath,
-		"output": outputPath,
+		"input":  options.InputPath,
+		"output": options.OutputPath,
 		"type":   outputType,
 	})
 
 	// Perform transcoding
 	outputFilePath, err := trans.Transcode(ctx)
 	if err != nil {
-		logger.Fatal("Transcoding failed", "main", map[string]interface{}{
-			"error": err.Error(),
-		})
+		// Interrupted by a signal, the error matches context.Canceled
+		exitWithError("Transcoding failed", err, nil)
 		return
 	}
 
@@ -196,4 +842,1167 @@ func runTranscoder(cmd *cobra.Command, args []string) {
 	logger.Info("Transcoding completed successfully", "main", map[string]interface{}{
 		"output_path": absPath,
 	})
+	if quiet {
+		// The log line above is suppressed; print the result for scripts
+		fmt.Println(absPath)
+	}
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	profile, err := buildOptions(cmd)
+	if err != nil {
+		exitWithError("Invalid transcoding options", err, nil)
+		return
+	}
+
+	// Each input is transcoded with the same profile, into an output named after it
+	transcode := func(ctx context.Context, path string) error {
+		output := filepath.Join(watchOutputDir, watchOutputName)
+		if profile.OutputType == transcoder.MP4Output && !cmd.Flags().Changed("output-name") {
+			output += ".mp4"
+		}
+
+		reporterOpts := []progress.ReporterOption{}
+		if quiet {
+			reporterOpts = append(reporterOpts, progress.WithBarWriter(io.Discard))
+		}
+		progressReporter := progress.NewReporter(reporterOpts...)
+		defer progressReporter.Close()
+
+		options := profile
+		options.InputPath = path
+		options.OutputPath = output
+		trans, err := transcoder.New(options, progressReporter)
+		if err != nil {
+			return err
+		}
+		outputFilePath, err := trans.Transcode(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("Transcoding completed successfully", "main", map[string]interface{}{
+			"input":       path,
+			"output_path": outputFilePath,
+		})
+		return nil
+	}
+
+	w := watch.New(watch.Options{
+		InputDir:        watchInputDir,
+		ArchiveDir:      watchArchiveDir,
+		FailedDir:       watchFailedDir,
+		DeleteProcessed: watchDelete,
+		StableFor:       watchStableFor,
+		SkipExisting:    watchSkipExisting,
+	}, transcode, logger.NewLogger())
+	if err := w.Run(ctx); err != nil {
+		exitWithError("Failed to watch the input directory", err, nil)
+	}
+}
+
+// addTranscodeFlags adds the flags of the transcoding options to cmd, read by
+// buildOptions.
+func addTranscodeFlags(cmd *cobra.Command) {
+	// Input flags
+	cmd.Flags().DurationVar(&probeTimeout, "probe-timeout", transcoder.DefaultProbeTimeout, "Time allowed for each ffprobe run on the input")
+	cmd.Flags().DurationVar(&encodeTimeout, "encode-timeout", 0, "Time allowed for each encode (0 means no limit)")
+	cmd.Flags().DurationVar(&maxJobDuration, "max-job-duration", 0, "Maximum total time of a job, after which it is stopped with a timeout error naming the stuck stage (0 means no limit)")
+	cmd.Flags().DurationVar(&stallTimeout, "stall-timeout", 0, "Stop an encode when ffmpeg prints nothing for this long, e.g. on a hung network input (0 disables)")
+	cmd.Flags().IntVar(&stallRetries, "stall-retries", 0, "Number of times a stalled encode is restarted before the job fails")
+	cmd.Flags().BoolVar(&allowOverwrite, "overwrite", false, "Allow overwriting existing files")
+	cmd.Flags().StringSliceVar(&inputExtensions, "input-extensions", nil, "Accept local inputs by extension (e.g. mp4,mov) instead of detecting their format with ffprobe")
+	cmd.Flags().StringVar(&blankCheck, "blank-check", "off", "Check the input for entirely black video or silent audio before encoding: 'off', 'warn' or 'reject' (fail fast)")
+	cmd.Flags().BoolVar(&verifyInput, "verify-input", false, "Decode the whole input before encoding and fail with its timestamped decode errors if it is corrupted")
+	cmd.Flags().DurationVar(&maxInputLength, "max-input-duration", 0, "Reject inputs longer than this (e.g. 2h) before encoding (0 disables)")
+	cmd.Flags().Int64Var(&maxInputSizeMB, "max-input-size", 0, "Reject inputs larger than this many MB before reading or downloading them (0 disables)")
+	cmd.Flags().StringVar(&inputKeyFile, "input-key-file", "", "Decrypt the input before transcoding with the key in this file (hex, base64 or raw)")
+	cmd.Flags().StringVar(&inputKeySecret, "input-key-secret", "", "Secret reference of the input decryption key (env:, file:, aws-sm:, aws-kms: or vault:)")
+	cmd.Flags().StringVar(&inputKeyURL, "input-key-url", "", "Fetch the input decryption key from this URL (e.g. a KMS proxy); {input} is replaced with the input file name")
+	cmd.Flags().StringArrayVar(&inputKeyHeaders, "input-key-header", []string{}, "Header added to --input-key-url requests as 'Name: value' (repeatable)")
+	cmd.Flags().StringVar(&inputCipher, "input-cipher", "hlspresso", "Format of the encrypted input: 'hlspresso' (--encrypt-key-file outputs) or 'aes-cbc' (openssl enc)")
+	cmd.Flags().StringVar(&inputIV, "input-iv", "", "Hex IV of aes-cbc inputs (default: the first 16 bytes of the input)")
+
+	// Output flags
+	cmd.Flags().StringVarP(&outputType, "type", "t", "hls", "Output type: 'hls' or 'mp4'")
+	cmd.Flags().BoolVar(&skipIfComplete, "skip-if-complete", false, "Do not re-encode when the output path already holds a complete output")
+	cmd.Flags().BoolVar(&force, "force", false, "Re-encode even if the output is complete, overwriting it (overrides --skip-if-complete)")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Reuse outputs of identical transcodes (same input content and settings) stored in this directory")
+	cmd.Flags().BoolVar(&cacheLink, "cache-link", false, "Hard-link outputs restored from --cache-dir instead of copying them (treat them as read-only)")
+	cmd.Flags().BoolVar(&writeManifest, "manifest", false, "Write a job manifest (hlspresso.json) with the source, options, ladder, timings and file checksums next to the output")
+	cmd.Flags().BoolVar(&writeChecksums, "checksums", false, "Write the SHA-256 of every produced file (SHA256SUMS, or <output>.sha256 for MP4) for verification with sha256sum -c")
+	cmd.Flags().StringVar(&encryptKeyFile, "encrypt-key-file", "", "Encrypt the output files at rest (AES-256-GCM) with the 32-byte key in this file (hex, base64 or raw) before uploading them")
+	cmd.Flags().StringVar(&encryptKeySecret, "encrypt-key-secret", "", "Like --encrypt-key-file, with the key read from a secret reference (env:, file:, aws-sm:, aws-kms: or vault:)")
+
+	// HLS flags
+	cmd.Flags().IntVar(&hlsSegmentDuration, "hls-segment-duration", 10, "HLS segment duration in seconds")
+	cmd.Flags().StringVar(&hlsPlaylistType, "hls-playlist-type", "vod", "HLS playlist type (vod or event)")
+	cmd.Flags().BoolVar(&sharedAudio, "hls-shared-audio", false, "Encode the audio once as an audio rendition shared by every variant instead of once per variant")
+	cmd.Flags().IntVar(&hlsVersion, "hls-version", 0, "HLS protocol version of the playlists: 3 for legacy players up to 7 for fMP4 segments (0 uses 6)")
+	cmd.Flags().BoolVar(&hlsByteRange, "hls-byte-range", false, "Store the segments of each variant in a single file addressed with byte ranges (HLS version 4 or later)")
+	cmd.Flags().DurationVar(&tsPCRPeriod, "ts-pcr-period", 0, "Interval between PCRs in the MPEG-TS segments (e.g. 40ms, 0 keeps the ffmpeg default)")
+	cmd.Flags().DurationVar(&tsPATPeriod, "ts-pat-period", 0, "Maximum interval between PAT/PMT tables in the MPEG-TS segments (e.g. 500ms, 0 keeps the ffmpeg default)")
+	cmd.Flags().BoolVar(&tsResendHeaders, "ts-resend-headers", false, "Write the PAT/PMT tables at the start of every MPEG-TS segment")
+	cmd.Flags().BoolVar(&tsPATPMTAtFrames, "ts-pat-pmt-at-frames", false, "Write the PAT/PMT tables before every video frame of the MPEG-TS segments")
+	cmd.Flags().Float64Var(&muxOverhead, "mux-overhead", 0, "Share of the segment size taken by the container in size estimates (e.g. 0.06, 0 uses 6% for MPEG-TS and 2% for fMP4)")
+	cmd.Flags().StringArrayVar(&sessionDataSpecs, "session-data", []string{}, "EXT-X-SESSION-DATA entry of the master playlist as DATA-ID=VALUE (repeatable, e.g. com.example.content-id=1234)")
+	cmd.Flags().StringArrayVar(&masterTags, "master-tag", []string{}, "Custom tag or comment line (starting with #) added to the master playlist (repeatable)")
+	cmd.Flags().BoolVar(&passMetadata, "passthrough-metadata", false, "Copy the SCTE-35 cues and timed ID3 tags of the input to the HLS playlists (EXT-X-DATERANGE)")
+	cmd.Flags().BoolVar(&extractCaptions, "extract-captions", false, "Also extract the closed captions of the input to a WebVTT subtitle rendition")
+	cmd.Flags().StringVar(&captionLanguage, "caption-language", "en", "Language tag of the closed captions in the master playlist (e.g. en, pt-BR)")
+	cmd.Flags().BoolVar(&trickPlay, "trick-play", false, "Add a keyframe-only trick-play rendition for scrubbing, declared as an I-frame stream")
+	cmd.Flags().DurationVar(&trickPlayInterval, "trick-play-interval", hls.DefaultTrickPlayInterval, "Time between two frames of the trick-play rendition")
+	cmd.Flags().IntVar(&trickPlayWidth, "trick-play-width", hls.DefaultTrickPlayWidth, "Width of the trick-play frames in pixels (the height keeps the aspect ratio)")
+	cmd.Flags().BoolVar(&chunked, "chunked", false, "Encode the HLS output in chunks split at keyframes, in parallel, to cut the encode time of long inputs")
+	cmd.Flags().DurationVar(&chunkDuration, "chunk-duration", transcoder.DefaultChunkDuration, "Minimum duration of the chunks of --chunked")
+	cmd.Flags().IntVar(&chunkConcurrency, "chunk-concurrency", transcoder.DefaultChunkConcurrency, "Number of chunks encoded at once with --chunked")
+	cmd.Flags().StringVar(&videoPreset, "video-preset", "", "x264 preset, from ultrafast to placebo (default medium)")
+	cmd.Flags().StringVar(&videoTune, "video-tune", "", "x264 tune: film, animation, grain, stillimage, fastdecode, zerolatency, psnr or ssim")
+	cmd.Flags().StringVar(&videoProfile, "video-profile", "", "H.264 profile: baseline, main, high, high10, high422 or high444")
+	cmd.Flags().StringVar(&videoLevel, "video-level", "", "H.264 level, e.g. 4.1")
+	cmd.Flags().StringVar(&deinterlace, "deinterlace", "auto", "Deinterlace the video: 'auto' (when ffprobe reports an interlaced field order), 'on' or 'off'")
+	cmd.Flags().StringVar(&deinterlacer, "deinterlacer", "bwdif", "Deinterlacing filter: 'bwdif' or 'yadif'")
+	cmd.Flags().StringVar(&pixelFormat, "pixel-format", "yuv420p", "Pixel format of the encoded video, e.g. yuv420p (8-bit 4:2:0) or yuv420p10le, or 'keep' for that of the input")
+	cmd.Flags().StringSliceVar(&mezzanines, "mezzanine", nil, "Also produce a mezzanine file for archival or editing: prores (ProRes 422) or dnxhr (DNxHR HQ) (repeatable)")
+	cmd.Flags().IntVar(&mp4Height, "mp4-height", 0, "Scale the video of the MP4 output to this height, keeping its aspect ratio (e.g. 720)")
+	cmd.Flags().StringArrayVar(&outputSpecs, "extra-output", []string{}, "Additional output of the same job as TYPE[:SIZE]=PATH, TYPE being hls, mp4, prores, dnxhr or thumbnail (repeatable, e.g. mp4:720={basename}_720p.mp4)")
+	cmd.Flags().StringVar(&audioCodec, "audio-codec", "aac", "Audio codec: aac, ac3 or eac3 (Dolby, keeps surround channels), or copy to pass the input audio through")
+	cmd.Flags().StringVar(&introPath, "intro", "", "Clip or still image (.png, .jpg...) inserted before the video, e.g. a branding bumper")
+	cmd.Flags().DurationVar(&introDuration, "intro-duration", 0, "How long the --intro image is shown (5s by default), or the maximum length of an --intro clip")
+	cmd.Flags().StringVar(&outroPath, "outro", "", "Clip or still image (.png, .jpg...) appended after the video")
+	cmd.Flags().DurationVar(&outroDuration, "outro-duration", 0, "How long the --outro image is shown (5s by default), or the maximum length of an --outro clip")
+	cmd.Flags().BoolVar(&autoResolutions, "auto-resolutions", true, "Derive the HLS renditions from the input resolution (default unless a ladder or resolutions are given)")
+	cmd.Flags().StringVar(&ladderName, "ladder", "", "Resolution ladder: "+strings.Join(hls.LadderNames(), ", ")+", or 'auto' to derive it from the input")
+	cmd.Flags().StringArrayVar(&resolutionSpecs, "resolution", []string{}, "Custom HLS rendition as WIDTHxHEIGHT[@FPS]:VIDEO_BITRATE[:AUDIO_BITRATE] (repeatable, e.g. 1280x720:2500k:128k)")
+	cmd.Flags().StringVar(&resolutionsFile, "resolutions-file", "", "JSON file with a custom list of HLS renditions")
+	cmd.Flags().StringVar(&preHook, "pre-hook", "", "Shell command run before the input is handled, with the job manifest as JSON on stdin; a non-zero exit fails the job (e.g. a virus scan)")
+	cmd.Flags().StringVar(&postHook, "post-hook", "", "Shell command run once the output is complete, with the job manifest as JSON on stdin (e.g. a CMS notification)")
+	cmd.Flags().StringVar(&scalePolicy, "scale-policy", "exact", "How automatic resolutions handle the input aspect ratio: exact, pad or crop (pad and crop produce 16:9 renditions)")
+	cmd.Flags().IntVar(&dimensionAlignment, "dimension-alignment", 2, "Round automatic resolution dimensions to a multiple of 2 or 4")
+	cmd.Flags().BoolVar(&noUpscale, "no-upscale", false, "Skip renditions larger than the input video when using --ladder or --resolution")
+
+	// Advanced flags
+	cmd.Flags().StringVar(&ffmpegBinary, "ffmpeg", "ffmpeg", "Path to ffmpeg binary")
+	cmd.Flags().StringArrayVar(&ffmpegExtraParams, "ffmpeg-param", []string{}, "Extra parameters to pass to ffmpeg")
+	cmd.Flags().StringArrayVar(&ffmpegInputParams, "ffmpeg-input-param", []string{}, "Extra parameters applying to the input, placed before its -i, e.g. '-ss 30' (repeatable)")
+	cmd.Flags().StringVar(&paramConflicts, "ffmpeg-param-conflicts", "warn", "Handling of extra parameters conflicting with the generated ones (e.g. a second -c:v or -f): 'warn', 'reject' or 'off'")
+	cmd.Flags().StringArrayVar(&ffmpegEnvVars, "ffmpeg-env", []string{}, "Environment variable of the ffmpeg/ffprobe processes as 'KEY=value', e.g. CUDA_VISIBLE_DEVICES=1 (repeatable)")
+	cmd.Flags().StringVar(&ffmpegWorkDir, "ffmpeg-workdir", "", "Working directory of the ffmpeg/ffprobe processes, e.g. for relative font files of drawtext filters")
+	cmd.Flags().StringVar(&ffmpegLogPath, "ffmpeg-log", "", "Append the full output of every ffmpeg/ffprobe run to this file, rotated at 50 MiB; {jobid} is replaced with the job ID")
+	cmd.Flags().BoolVar(&gracefulStop, "graceful-stop", false, "On SIGTERM/SIGINT, send \"q\" to ffmpeg so that the current segment and the playlists are finalized, and keep the output")
+	cmd.Flags().DurationVar(&stopGracePeriod, "stop-grace-period", ffmpeg.GracePeriod, "Time allowed for a stopped ffmpeg to exit before it is killed")
+	cmd.Flags().BoolVar(&realtimePacing, "realtime", false, "Encode at about 1x realtime (ffmpeg -re), e.g. to simulate a live stream or avoid bursty IO on shared storage")
+	cmd.Flags().BoolVar(&deterministic, "deterministic", false, "Produce byte-identical outputs for identical inputs and options (single-threaded, slower encode)")
+}
+
+// buildOptions returns the transcoding options of the flags added by
+// addTranscodeFlags to cmd. The input and output paths are left to the caller.
+func buildOptions(cmd *cobra.Command) (transcoder.Options, error) {
+	var options transcoder.Options
+
+	var outType transcoder.OutputType
+	switch strings.ToLower(outputType) {
+	case "hls":
+		outType = transcoder.HLSOutput
+	case "mp4":
+		outType = transcoder.MP4Output
+	default:
+		return options, errors.New(errors.ValidationError, "Invalid output type", outputType, 4)
+	}
+
+	// Resolve the resolution ladder
+	resolutions, useAutoResolutions, err := resolveResolutions(cmd)
+	if err != nil {
+		return options, errors.New(errors.ValidationError, "Invalid resolutions", err.Error(), 4)
+	}
+
+	sessionData, err := parseSessionData(sessionDataSpecs)
+	if err != nil {
+		return options, errors.New(errors.ValidationError, "Invalid --session-data value", err.Error(), 4)
+	}
+
+	outputs, err := parseOutputSpecs(outputSpecs)
+	if err != nil {
+		return options, errors.New(errors.ValidationError, "Invalid --extra-output value", err.Error(), 4)
+	}
+
+	ffmpegEnv, err := parseEnv(ffmpegEnvVars)
+	if err != nil {
+		return options, errors.New(errors.ValidationError, "Invalid --ffmpeg-env value", err.Error(), 4)
+	}
+
+	encryptionKey, err := readEncryptionKey()
+	if err != nil {
+		return options, errors.New(errors.ValidationError, "Invalid encryption key", err.Error(), 4)
+	}
+
+	decryption, err := inputDecryption()
+	if err != nil {
+		return options, errors.New(errors.ValidationError, "Invalid input decryption options", err.Error(), 4)
+	}
+
+	return transcoder.Options{
+		AllowOverwrite: allowOverwrite || force,
+
+		// Stage timeouts
+		ProbeTimeout:   probeTimeout,
+		EncodeTimeout:  encodeTimeout,
+		MaxJobDuration: maxJobDuration,
+		StallTimeout:   stallTimeout,
+		StallRetries:   stallRetries,
+
+		// Input checks
+		InputExtensions:   inputExtensions,
+		BlankCheck:        transcoder.BlankCheckMode(blankCheck),
+		VerifyInput:       verifyInput,
+		MaxInputDuration:  maxInputLength,
+		MaxInputSizeBytes: maxInputSizeMB * 1024 * 1024,
+
+		// Input decryption options
+		InputDecryptionKey: decryption.key,
+		InputKeyProvider:   decryption.provider,
+		InputCipher:        decryption.cipher,
+		InputIV:            decryption.iv,
+
+		// Output options
+		OutputType:     outType,
+		SkipIfComplete: skipIfComplete && !force,
+		CacheDir:       cacheDir,
+		CacheLink:      cacheLink,
+		WriteManifest:  writeManifest,
+		WriteChecksums: writeChecksums,
+		EncryptionKey:  encryptionKey,
+
+		// HLS options
+		HLSSegmentDuration: hlsSegmentDuration,
+		HLSPlaylistType:    hlsPlaylistType,
+		HLSSharedAudio:     sharedAudio,
+		HLSVersion:         hlsVersion,
+		HLSByteRange:       hlsByteRange,
+		HLSMPEGTS:          mpegtsOptions(),
+		HLSMuxOverhead:     muxOverhead,
+		HLSSessionData:     sessionData,
+		HLSMasterTags:      masterTags,
+		HLSResolutions:     resolutions,
+		UseAutoResolutions: useAutoResolutions,
+		DisallowUpscale:    noUpscale,
+
+		AutoScalePolicy:        hls.ScalePolicy(scalePolicy),
+		AutoDimensionAlignment: dimensionAlignment,
+		PassthroughMetadata:    passMetadata,
+		ExtractCaptions:        extractCaptions,
+		CaptionLanguage:        captionLanguage,
+		TrickPlay:              trickPlay,
+		TrickPlayInterval:      trickPlayInterval,
+		TrickPlayWidth:         trickPlayWidth,
+		ChunkedEncoding:        chunked,
+		ChunkDuration:          chunkDuration,
+		ChunkConcurrency:       chunkConcurrency,
+		VideoPreset:            hls.Preset(strings.ToLower(videoPreset)),
+		VideoTune:              hls.Tune(strings.ToLower(videoTune)),
+		VideoProfile:           hls.Profile(strings.ToLower(videoProfile)),
+		VideoLevel:             hls.Level(videoLevel),
+		Deinterlace:            transcoder.DeinterlaceMode(deinterlace),
+		Deinterlacer:           hls.Deinterlacer(deinterlacer),
+		PixelFormat:            hls.PixelFormat(pixelFormat),
+		Mezzanines:             mezzanineOutputs(mezzanines),
+		MP4Height:              mp4Height,
+		Outputs:                outputs,
+		AudioCodec:             hls.AudioCodec(audioCodec),
+		Intro:                  transcoder.Slate{Path: introPath, Duration: introDuration},
+		Outro:                  transcoder.Slate{Path: outroPath, Duration: outroDuration},
+
+		// Hook options
+		PreHook:  execHook(preHook),
+		PostHook: execHook(postHook),
+
+		// Advanced options
+		FFmpegBinary:         ffmpegBinary,
+		FFmpegExtraParams:    ffmpegExtraParams,
+		FFmpegInputParams:    ffmpegInputParams,
+		FFmpegParamConflicts: transcoder.ParamConflictMode(paramConflicts),
+		FFmpegEnv:            ffmpegEnv,
+		FFmpegWorkDir:        ffmpegWorkDir,
+		FFmpegLogPath:        ffmpegLogPath,
+		GracefulStop:         gracefulStop,
+		StopGracePeriod:      stopGracePeriod,
+		LogFFmpegOutput:      verbosity >= 2,
+		RealtimePacing:       realtimePacing,
+		Deterministic:        deterministic,
+	}, nil
+}
+
+// expandInput returns the local files matching input when it contains wildcards
+// (*, ? or [...]), in lexical order, or input itself otherwise.
+func expandInput(input string) ([]string, error) {
+	if !strings.ContainsAny(input, "*?[") || strings.Contains(input, "://") {
+		return []string{input}, nil
+	}
+	matches, err := filepath.Glob(input)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %q", input)
+	}
+	return files, nil
+}
+
+// perInputOutput reports whether the output path template resolves to a
+// different path for each input of a batch.
+func perInputOutput(output string) bool {
+	for _, variable := range []string{transcoder.OutputVarName, transcoder.OutputVarBasename, transcoder.OutputVarJobID} {
+		if strings.Contains(output, variable) {
+			return true
+		}
+	}
+	return false
+}
+
+// runBatch transcodes each input with options into its --output path, running up
+// to --parallel jobs at once. A failed job does not stop the others; the process
+// then exits with the status of the first failure.
+func runBatch(ctx context.Context, options transcoder.Options, inputs []string, reporterOpts []progress.ReporterOption) {
+	logger.Info("Starting batch", "main", map[string]interface{}{
+		"input":    inputPath,
+		"jobs":     len(inputs),
+		"parallel": parallel,
+	})
+
+	var (
+		mu       sync.Mutex
+		failures []error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for input := range jobs {
+				if err := runJob(ctx, options, input, reporterOpts); err != nil {
+					logger.Error("Transcoding failed", "main", map[string]interface{}{
+						"input": input,
+						"error": err.Error(),
+					})
+					mu.Lock()
+					failures = append(failures, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+queue:
+	for _, input := range inputs {
+		select {
+		case jobs <- input:
+		case <-ctx.Done():
+			break queue
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		exitWithError("Batch interrupted", ctx.Err(), nil)
+		return
+	}
+	if len(failures) > 0 {
+		exitWithError(fmt.Sprintf("%d of %d jobs failed", len(failures), len(inputs)), failures[0], map[string]interface{}{
+			"failed": len(failures),
+			"total":  len(inputs),
+		})
+		return
+	}
+	logger.Info("Batch completed successfully", "main", map[string]interface{}{
+		"jobs": len(inputs),
+	})
+}
+
+// runJob transcodes a single input of a batch.
+func runJob(ctx context.Context, options transcoder.Options, input string, reporterOpts []progress.ReporterOption) error {
+	options.InputPath = input
+
+	opts := append([]progress.ReporterOption{progress.WithDescription(filepath.Base(input))}, reporterOpts...)
+	if parallel > 1 {
+		// Concurrent progress bars would overwrite each other
+		opts = append(opts, progress.WithBarWriter(io.Discard))
+	}
+	progressReporter := progress.NewReporter(opts...)
+	defer progressReporter.Close()
+
+	trans, err := transcoder.New(options, progressReporter)
+	if err != nil {
+		return err
+	}
+	logger.Info("Starting transcoder", "main", map[string]interface{}{
+		"input":  options.InputPath,
+		"output": options.OutputPath,
+		"type":   outputType,
+	})
+	outputFilePath, err := trans.Transcode(ctx)
+	if err != nil {
+		return err
+	}
+
+	absPath, _ := filepath.Abs(outputFilePath)
+	logger.Info("Transcoding completed successfully", "main", map[string]interface{}{
+		"input":       input,
+		"output_path": absPath,
+	})
+	if quiet {
+		fmt.Println(absPath)
+	}
+	return nil
+}
+
+// runJobSpec runs the job described by --spec and writes its result to
+// --result, also when the job is interrupted by SIGTERM, so that the
+// orchestrator can tell a failed job from a preempted one.
+func runJobSpec(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	startedAt := time.Now()
+
+	if progressStep < 0 || progressStep > 100 {
+		exitInvalid("--progress-granularity must be between 0 and 100", map[string]interface{}{
+			"value": progressStep,
+		})
+		return
+	}
+	options, err := jobspec.Load(jobSpecPath)
+	if err != nil {
+		finishJobSpec("Invalid job spec", "", "", err, nil, startedAt)
+		return
+	}
+	options.EncryptionKey, err = readEncryptionKey()
+	if err != nil {
+		exitInvalid("Invalid encryption key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	options.PreHook = execHook(preHook)
+	options.PostHook = execHook(postHook)
+
+	// Progress goes to stdout as JSON Lines, logs stay on stderr
+	reporterOpts := []progress.ReporterOption{
+		progress.WithBarWriter(io.Discard),
+		progress.WithEventWriter(os.Stdout),
+	}
+	if progressStep > 0 {
+		reporterOpts = append(reporterOpts, progress.WithGranularity(progressStep))
+	}
+	progressReporter := progress.NewReporter(reporterOpts...)
+	defer progressReporter.Close()
+
+	trans, err := transcoder.New(options, progressReporter)
+	if err != nil {
+		finishJobSpec("Failed to create transcoder", options.JobID, "", err, nil, startedAt)
+		return
+	}
+	logger.Info("Starting job", "main", map[string]interface{}{
+		"spec":   jobSpecPath,
+		"input":  options.InputPath,
+		"output": options.OutputPath,
+	})
+	outputFilePath, err := trans.Transcode(ctx)
+	if outputFilePath != "" {
+		outputFilePath, _ = filepath.Abs(outputFilePath)
+	}
+	last := progressReporter.Snapshot()
+	finishJobSpec("Job failed", last.JobID, outputFilePath, err, &last, startedAt)
+	if err == nil {
+		logger.Info("Job completed successfully", "main", map[string]interface{}{
+			"output_path": outputFilePath,
+		})
+	}
+}
+
+// finishJobSpec writes the result of the job, which produced output or failed
+// with err, to --result and exits with the code of err, if any.
+func finishJobSpec(message, jobID, output string, err error, last *progress.ProgressEvent, startedAt time.Time) {
+	if jobResultPath != "" {
+		result := jobspec.NewResult(jobID, output, err, last, startedAt)
+		if writeErr := jobspec.WriteResult(jobResultPath, result); writeErr != nil {
+			logger.Warn("Failed to write the job result", "main", map[string]interface{}{
+				"path":  jobResultPath,
+				"error": writeErr.Error(),
+			})
+		}
+	}
+	if err != nil {
+		exitWithError(message, err, map[string]interface{}{
+			"spec": jobSpecPath,
+		})
+	}
+}
+
+// registerCompletions registers value completions for the flags cmds define.
+func registerCompletions(cmds ...*cobra.Command) {
+	fixed := map[string][]string{
+		"type":                 {"hls", "mp4"},
+		"hls-playlist-type":    {"vod", "event"},
+		"ladder":               append(hls.LadderNames(), "auto"),
+		"scale-policy":         {string(hls.ScaleExact), string(hls.ScalePad), string(hls.ScaleCrop)},
+		"dimension-alignment":  {"2", "4"},
+		"hls-version":          {"3", "4", "5", "6", "7"},
+		"audio-codec":          {string(hls.AudioAAC), string(hls.AudioAC3), string(hls.AudioEAC3), string(hls.AudioCopy)},
+		"progress-file-format": {"text", "json"},
+		"preflight":            {string(transcoder.PreflightHead), string(transcoder.PreflightGet), string(transcoder.PreflightNone)},
+		"encoder":              append([]string{bench.SoftwareEncoder}, bench.HardwareEncoders...),
+		"input-cipher":         {string(encrypt.CipherHLSpresso), string(encrypt.CipherAESCBC)},
+		"mezzanine":            {string(transcoder.ProResOutput), string(transcoder.DNxHROutput)},
+		"a-ladder":             hls.LadderNames(),
+		"b-ladder":             hls.LadderNames(),
+	}
+	files := map[string][]string{
+		"resolutions-file": {"json"},
+		"spec":             {"json"},
+		"a":                {"json"},
+		"b":                {"json"},
+		"report":           {"json"},
+		"result":           nil,
+		"progress-file":    nil,
+		"progress-socket":  nil,
+		"upload-key":       nil,
+		"encrypt-key-file": nil,
+		"input-key-file":   nil,
+		"key-file":         nil,
+		"ffmpeg":           nil,
+		"ffmpeg-log":       nil,
+	}
+	dirs := []string{"download-dir", "download-cache-dir", "cache-dir"}
+
+	for _, cmd := range cmds {
+		for name, values := range fixed {
+			if cmd.Flags().Lookup(name) != nil {
+				cmd.RegisterFlagCompletionFunc(name, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+			}
+		}
+		for name, extensions := range files {
+			if cmd.Flags().Lookup(name) != nil {
+				cmd.MarkFlagFilename(name, extensions...)
+			}
+		}
+		for _, name := range dirs {
+			if cmd.Flags().Lookup(name) != nil {
+				cmd.MarkFlagDirname(name)
+			}
+		}
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	report := doctor.Run(cmd.Context(), doctor.Options{
+		FFmpegBinary: ffmpegBinary,
+		Dirs:         doctorDirs,
+		MinFreeSpace: minFreeSpaceMB * 1024 * 1024,
+		TestURL:      doctorURL,
+		Timeout:      doctorTimeout,
+	})
+
+	if doctorJSON {
+		content, err := report.JSON()
+		if err != nil {
+			exitWithError("Failed to encode the doctor report", err, nil)
+			return
+		}
+		fmt.Println(content)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+
+	if !report.OK {
+		os.Exit(1)
+	}
+}
+
+func runVersion(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(cmd.Context(), doctor.DefaultTimeout)
+	defer cancel()
+
+	info := version.Get()
+	info.FFmpegVersion, _ = version.FFmpegVersion(ctx, nil, ffmpegBinary)
+
+	var release *version.Release
+	if versionCheckUpdate {
+		var err error
+		release, err = version.CheckUpdate(ctx, nil, "", info.Version)
+		if err != nil {
+			exitWithError("Update check failed", err, nil)
+			return
+		}
+	}
+
+	if versionJSON {
+		content, err := json.MarshalIndent(struct {
+			version.Info
+			LatestRelease *version.Release `json:"latest_release,omitempty"`
+		}{info, release}, "", "  ")
+		if err != nil {
+			exitWithError("Failed to encode the version information", err, nil)
+			return
+		}
+		fmt.Println(string(content))
+		return
+	}
+
+	fmt.Print(info)
+	switch {
+	case release == nil:
+	case release.UpdateAvailable:
+		fmt.Printf("\nA newer release is available: %s\n%s\n", release.Version, release.URL)
+	default:
+		fmt.Printf("\nHLSpresso is up to date (latest release: %s)\n", release.Version)
+	}
+}
+
+func runGenDocs(cmd *cobra.Command, args []string) {
+	var err error
+	switch strings.ToLower(docsFormat) {
+	case "man":
+		err = clidoc.GenManTree(cmd.Root(), docsDir)
+	case "markdown", "md":
+		err = clidoc.GenMarkdownTree(cmd.Root(), docsDir)
+	default:
+		exitInvalid("Invalid --format value. Must be 'man' or 'markdown'", map[string]interface{}{
+			"value": docsFormat,
+		})
+		return
+	}
+	if err != nil {
+		exitWithError("Failed to generate documentation", err, nil)
+		return
+	}
+}
+
+func runDecrypt(cmd *cobra.Command, args []string) {
+	key, err := readEncryptionKey()
+	if err == nil && key == nil {
+		err = fmt.Errorf("--key-file or --key-secret is required")
+	}
+	if err != nil {
+		exitInvalid("Invalid decryption key", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	for _, path := range args {
+		decrypted, err := encrypt.DecryptTree(key, path)
+		for _, file := range decrypted {
+			fmt.Println(file)
+		}
+		if err != nil {
+			exitWithError("Failed to decrypt output", err, map[string]interface{}{
+				"path": path,
+			})
+			return
+		}
+	}
+}
+
+func runPreviewServer(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	srv := server.New(server.Options{
+		Dir:            args[0],
+		Addr:           serverAddr,
+		MasterPlaylist: serverPlaylist,
+		DisablePlayer:  serverNoPlayer,
+	})
+	if err := srv.Listen(); err != nil {
+		exitWithError("Failed to start preview server", err, nil)
+		return
+	}
+
+	fmt.Printf("Serving %s at %s (press Ctrl+C to stop)\n", args[0], srv.URL())
+	if err := srv.Serve(ctx); err != nil {
+		exitWithError("Preview server failed", err, nil)
+	}
+}
+
+func runAnalyze(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	report, err := analysis.New(analysis.Options{
+		InputFile:    inputPath,
+		FFmpegBinary: ffmpegBinary,
+	}).Analyze(ctx)
+	if err != nil {
+		exitWithError("Analysis failed", err, nil)
+		return
+	}
+
+	content, err := report.JSON()
+	if err != nil {
+		exitWithError("Failed to marshal analysis report", err, nil)
+		return
+	}
+
+	if analysisReportPath == "" {
+		fmt.Println(content)
+		return
+	}
+	if err := os.WriteFile(analysisReportPath, []byte(content), 0644); err != nil {
+		exitWithError("Failed to write analysis report", err, map[string]interface{}{
+			"path": analysisReportPath,
+		})
+	}
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	report, err := bench.New(bench.Options{
+		InputFile:      inputPath,
+		SampleSeconds:  benchSeconds,
+		Height:         benchHeight,
+		Bitrate:        benchBitrate,
+		Encoders:       benchEncoders,
+		Presets:        benchPresets,
+		DetectHardware: benchHardware,
+		FFmpegBinary:   ffmpegBinary,
+	}).Run(ctx)
+	if err != nil {
+		exitWithError("Benchmark failed", err, nil)
+		return
+	}
+
+	content, err := report.JSON()
+	if err != nil {
+		exitWithError("Failed to marshal benchmark report", err, nil)
+		return
+	}
+
+	if benchReportPath == "" {
+		fmt.Println(content)
+		return
+	}
+	if err := os.WriteFile(benchReportPath, []byte(content), 0644); err != nil {
+		exitWithError("Failed to write benchmark report", err, map[string]interface{}{
+			"path": benchReportPath,
+		})
+	}
+}
+
+func runCompare(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var sides [2]transcoder.Options
+	for i := range sides {
+		options, err := compareSide(compareSpecs[i], compareLadders[i], compareResolutions[i])
+		if err != nil {
+			exitWithError("Invalid option set", err, map[string]interface{}{
+				"side": string(rune('a' + i)),
+			})
+			return
+		}
+		sides[i] = options
+	}
+
+	report, err := compare.New(compare.Options{
+		InputFile:     inputPath,
+		A:             sides[0],
+		B:             sides[1],
+		OutputFile:    compareOutput,
+		Height:        compareHeight,
+		SampleSeconds: compareSeconds,
+		WorkDir:       compareWorkDir,
+		FFmpegBinary:  ffmpegBinary,
+	}).Run(ctx)
+	if err != nil {
+		exitWithError("Comparison failed", err, nil)
+		return
+	}
+
+	content, err := report.JSON()
+	if err != nil {
+		exitWithError("Failed to marshal comparison report", err, nil)
+		return
+	}
+
+	if compareReportPath == "" {
+		fmt.Println(content)
+		return
+	}
+	if err := os.WriteFile(compareReportPath, []byte(content), 0644); err != nil {
+		exitWithError("Failed to write comparison report", err, map[string]interface{}{
+			"path": compareReportPath,
+		})
+	}
+}
+
+// compareSide returns an option set of the compare command: the job spec at
+// specPath, if any, with the HLS renditions of resolutionSpecs or ladder.
+func compareSide(specPath, ladder string, resolutionSpecs []string) (transcoder.Options, error) {
+	var options transcoder.Options
+	if specPath != "" {
+		var err error
+		if options, err = jobspec.Load(specPath); err != nil {
+			return options, err
+		}
+	}
+	switch {
+	case len(resolutionSpecs) > 0:
+		resolutions, err := hls.ParseResolutions(resolutionSpecs)
+		if err != nil {
+			return options, errors.New(errors.ValidationError, "Invalid resolution", err.Error(), 4)
+		}
+		options.HLSResolutions = resolutions
+	case ladder != "":
+		resolutions, err := hls.LadderByName(ladder)
+		if err != nil {
+			return options, errors.New(errors.ValidationError, "Invalid ladder", err.Error(), 4)
+		}
+		options.HLSResolutions = resolutions
+	}
+	return options, nil
+}
+
+func runPlan(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var outType transcoder.OutputType
+	switch strings.ToLower(outputType) {
+	case "hls":
+		outType = transcoder.HLSOutput
+	case "mp4":
+		outType = transcoder.MP4Output
+	default:
+		exitInvalid("Invalid output type", map[string]interface{}{
+			"type": outputType,
+		})
+		return
+	}
+
+	resolutions, useAutoResolutions, err := resolveResolutions(cmd)
+	if err != nil {
+		exitInvalid("Invalid resolutions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	plan, err := transcoder.Plan(ctx, transcoder.Options{
+		InputPath:          inputPath,
+		StreamFromURL:      true, // Inputs are probed in place, never downloaded
+		OutputType:         outType,
+		HLSResolutions:     resolutions,
+		UseAutoResolutions: useAutoResolutions,
+		DisallowUpscale:    noUpscale,
+		MaxOutputSizeBytes: maxOutputSizeMB * 1024 * 1024,
+		FFmpegBinary:       ffmpegBinary,
+		RealtimePacing:     realtimePacing,
+		HLSSharedAudio:     sharedAudio,
+		HLSMuxOverhead:     muxOverhead,
+
+		AutoScalePolicy:        hls.ScalePolicy(scalePolicy),
+		AutoDimensionAlignment: dimensionAlignment,
+	})
+	if err != nil {
+		exitWithError("Planning failed", err, nil)
+		return
+	}
+
+	content, err := plan.JSON()
+	if err != nil {
+		exitWithError("Failed to marshal encode plan", err, nil)
+		return
+	}
+
+	if planReportPath == "" {
+		fmt.Println(content)
+		return
+	}
+	if err := os.WriteFile(planReportPath, []byte(content), 0644); err != nil {
+		exitWithError("Failed to write encode plan", err, map[string]interface{}{
+			"path": planReportPath,
+		})
+	}
+}
+
+func runProbe(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	info, err := transcoder.Probe(ctx, transcoder.Options{
+		InputPath:     inputPath,
+		StreamFromURL: true, // Inputs are probed in place, never downloaded
+
+		AutoScalePolicy:        hls.ScalePolicy(scalePolicy),
+		AutoDimensionAlignment: dimensionAlignment,
+	})
+	if err != nil {
+		exitWithError("Probing failed", err, nil)
+		return
+	}
+
+	content, err := info.JSON()
+	if err != nil {
+		exitWithError("Failed to marshal media info", err, nil)
+		return
+	}
+
+	if probeReportPath == "" {
+		fmt.Println(content)
+		return
+	}
+	if err := os.WriteFile(probeReportPath, []byte(content), 0644); err != nil {
+		exitWithError("Failed to write media info", err, map[string]interface{}{
+			"path": probeReportPath,
+		})
+	}
+}
+
+// resolveResolutions returns the HLS resolutions selected with --resolution,
+// --resolutions-file or --ladder, or reports that they should be derived from the
+// input (--auto-resolutions, the default when none of those flags is used).
+// Only one of these flags may be used at a time. With --auto-resolutions=false and
+// no other flag, the 1080p ladder is used.
+func resolveResolutions(cmd *cobra.Command) ([]hls.VideoResolution, bool, error) {
+	custom := 0
+	for _, name := range []string{"resolution", "resolutions-file", "ladder"} {
+		if cmd.Flags().Changed(name) {
+			custom++
+		}
+	}
+	if cmd.Flags().Changed("auto-resolutions") && autoResolutions {
+		custom++
+	}
+	if custom > 1 {
+		return nil, false, fmt.Errorf("--auto-resolutions, --resolution, --resolutions-file and --ladder cannot be combined")
+	}
+
+	switch {
+	case len(resolutionSpecs) > 0:
+		resolutions, err := hls.ParseResolutions(resolutionSpecs)
+		return resolutions, false, err
+	case resolutionsFile != "":
+		resolutions, err := hls.LoadResolutions(resolutionsFile)
+		return resolutions, false, err
+	case strings.EqualFold(ladderName, "auto"):
+		return nil, true, nil
+	case ladderName != "":
+		resolutions, err := hls.LadderByName(ladderName)
+		return resolutions, false, err
+	case autoResolutions:
+		return nil, true, nil
+	}
+	return hls.DefaultResolutions, false, nil
+}
+
+// readEncryptionKey reads the key of --encrypt-key-file or --encrypt-key-secret
+// (--key-file or --key-secret), or returns nil if neither is set.
+func readEncryptionKey() ([]byte, error) {
+	switch {
+	case encryptKeyFile != "" && encryptKeySecret != "":
+		return nil, fmt.Errorf("a key file and a key secret are mutually exclusive")
+	case encryptKeySecret != "":
+		data, err := resolveSecret(encryptKeySecret)
+		if err != nil {
+			return nil, err
+		}
+		return encrypt.ParseKey(data)
+	case encryptKeyFile != "":
+		return encrypt.ReadKeyFile(encryptKeyFile)
+	}
+	return nil, nil
+}
+
+// secretTimeout is the time allowed to look up each secret.
+const secretTimeout = 30 * time.Second
+
+// resolveSecret returns the value of the secret reference ref.
+func resolveSecret(ref string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	return secrets.NewResolver().Resolve(ctx, ref)
+}
+
+// resolveToken returns the access token of the secret reference ref, or ""
+// if ref is empty.
+func resolveToken(ref string) (string, error) {
+	if ref == "" {
+		return "", nil
+	}
+	token, err := resolveSecret(ref)
+	return strings.TrimSpace(string(token)), err
+}
+
+// expandSecrets replaces the {secret:<reference>} placeholders of value, e.g.
+// in the credentials of an upload URL or in a header.
+func expandSecrets(value string) (string, error) {
+	if !strings.Contains(value, "{secret:") {
+		return value, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	return secrets.NewResolver().Expand(ctx, value)
+}
+
+// decryptionOptions holds the input decryption options built from the flags.
+type decryptionOptions struct {
+	key      []byte
+	provider encrypt.KeyProvider
+	cipher   encrypt.Cipher
+	iv       []byte
+}
+
+// inputDecryption reads the key of --input-key-file, or sets up the key
+// lookup of --input-key-url, with the cipher and IV of the input.
+func inputDecryption() (decryptionOptions, error) {
+	var opts decryptionOptions
+	var err error
+	if opts.cipher, err = encrypt.ParseCipher(inputCipher); err != nil {
+		return opts, err
+	}
+	if inputIV != "" {
+		if opts.iv, err = hex.DecodeString(inputIV); err != nil {
+			return opts, fmt.Errorf("--input-iv: %w", err)
+		}
+	}
+	sources := 0
+	for _, source := range []string{inputKeyFile, inputKeySecret, inputKeyURL} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return opts, fmt.Errorf("--input-key-file, --input-key-secret and --input-key-url are mutually exclusive")
+	}
+
+	if inputKeyFile != "" || inputKeySecret != "" {
+		var data []byte
+		if inputKeySecret != "" {
+			data, err = resolveSecret(inputKeySecret)
+		} else {
+			data, err = os.ReadFile(inputKeyFile)
+		}
+		if err != nil {
+			return opts, err
+		}
+		if opts.cipher == encrypt.CipherAESCBC {
+			opts.key, err = encrypt.ParseAESKey(data)
+		} else {
+			opts.key, err = encrypt.ParseKey(data)
+		}
+		return opts, err
+	}
+	if inputKeyURL != "" {
+		headers, err := parseHeaders(inputKeyHeaders)
+		if err != nil {
+			return opts, err
+		}
+		keyURL, err := expandSecrets(inputKeyURL)
+		if err != nil {
+			return opts, err
+		}
+		opts.provider = encrypt.HTTPKeyProvider{URL: keyURL, Headers: headers}
+	}
+	return opts, nil
+}
+
+// mezzanineOutputs converts the values of --mezzanine to output types.
+func mezzanineOutputs(values []string) []transcoder.OutputType {
+	var outputs []transcoder.OutputType
+	for _, value := range values {
+		outputs = append(outputs, transcoder.OutputType(value))
+	}
+	return outputs
+}
+
+// parseOutputSpecs parses "TYPE[:SIZE]=PATH" additional output flags.
+func parseOutputSpecs(values []string) ([]transcoder.OutputSpec, error) {
+	var specs []transcoder.OutputSpec
+	for _, value := range values {
+		spec, err := transcoder.ParseOutputSpec(value)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// parseSessionData parses "DATA-ID=VALUE" session data flags.
+func parseSessionData(values []string) ([]hls.SessionData, error) {
+	var entries []hls.SessionData
+	for _, value := range values {
+		entry, err := hls.ParseSessionData(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// execHook returns the transcoder hook running command, or nil if command is empty.
+func execHook(command string) transcoder.Hook {
+	if command == "" {
+		return nil
+	}
+	return transcoder.ExecHook(command)
+}
+
+// mpegtsOptions returns the MPEG-TS muxer options set by the --ts-* flags.
+func mpegtsOptions() hls.MPEGTSOptions {
+	return hls.MPEGTSOptions{
+		PCRPeriod:      tsPCRPeriod,
+		PATPeriod:      tsPATPeriod,
+		ResendHeaders:  tsResendHeaders,
+		PATPMTAtFrames: tsPATPMTAtFrames,
+	}
+}
+
+// parseHeaders parses "Name: value" header flags into a map, expanding the
+// {secret:<reference>} placeholders of the values.
+func parseHeaders(values []string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, value := range values {
+		name, v, found := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("header %q must be in the form 'Name: value'", value)
+		}
+		v, err := expandSecrets(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		headers[name] = v
+	}
+	return headers, nil
+}
+
+// parseEnv parses "KEY=value" environment variable flags into a map,
+// expanding the {secret:<reference>} placeholders of the values.
+func parseEnv(values []string) (map[string]string, error) {
+	env := map[string]string{}
+	for _, value := range values {
+		name, v, found := strings.Cut(value, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("variable %q must be in the form 'KEY=value'", value)
+		}
+		v, err := expandSecrets(v)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", name, err)
+		}
+		env[name] = v
+	}
+	return env, nil
 }
@@ -20,6 +20,7 @@ func main() {
 	outputDir := "output_hls_custom_res"
 
 	reporter := progress.NewReporter()
+	defer reporter.Close()
 
 	log.Println("Starting local HLS transcoding with custom resolutions...")
 
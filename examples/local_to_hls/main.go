@@ -33,6 +33,7 @@ func main() {
 		// progress.WithDescription("Local HLS Task"), // Example of other options
 	}
 	reporter := progress.NewReporter(reporterOpts...)
+	defer reporter.Close()
 
 	log.Println("Starting local HLS transcoding...")
 
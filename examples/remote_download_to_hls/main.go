@@ -27,6 +27,7 @@ func main() {
 	defer os.RemoveAll(downloadDir)
 
 	reporter := progress.NewReporter()
+	defer reporter.Close()
 
 	log.Printf("Starting remote HLS transcoding (download first) for: %s", remoteURL)
 
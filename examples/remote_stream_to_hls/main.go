@@ -20,7 +20,7 @@ func main() {
 	outputDir := "output_hls_remote_stream"
 
 	reporter := progress.NewReporter()
-	// defer reporter.Close() // No Close needed
+	defer reporter.Close()
 
 	// Default reporter prints automatically
 	// go func() {
@@ -23,7 +23,7 @@ func main() {
 	// defer os.Remove(inputFile) // Cleanup is handled by the script
 
 	reporter := progress.NewReporter()
-	// defer reporter.Close() // No longer needed
+	defer reporter.Close()
 
 	// Default reporter prints to console
 	// go func() {
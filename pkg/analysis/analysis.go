@@ -0,0 +1,224 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+)
+
+// Segment describes a time range (in seconds) detected in the input, such as a
+// period of silence or black frames.
+type Segment struct {
+	// Start of the segment in seconds.
+	Start float64 `json:"start"`
+	// End of the segment in seconds. Zero if the segment runs until the end of the input.
+	End float64 `json:"end"`
+	// Duration of the segment in seconds. Zero if the segment runs until the end of the input.
+	Duration float64 `json:"duration"`
+}
+
+// Report holds the result of a loudness and silence analysis.
+// It is designed to be serialized as JSON for QC tooling.
+type Report struct {
+	// Input is the analyzed file or URL.
+	Input string `json:"input"`
+	// IntegratedLoudness is the EBU R128 integrated loudness in LUFS.
+	IntegratedLoudness float64 `json:"integrated_loudness_lufs"`
+	// LoudnessRange is the EBU R128 loudness range in LU.
+	LoudnessRange float64 `json:"loudness_range_lu"`
+	// TruePeak is the maximum true peak in dBFS.
+	TruePeak float64 `json:"true_peak_dbfs"`
+	// Silence lists the detected silent audio segments.
+	Silence []Segment `json:"silence"`
+	// Black lists the detected black video segments.
+	Black []Segment `json:"black"`
+}
+
+// JSON returns the Report serialized as an indented JSON string.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Options contains settings for the media analysis.
+type Options struct {
+	// InputFile is the path (or URL) of the media to analyze.
+	InputFile string
+	// SilenceThreshold is the noise level (in dB) below which audio is considered silent. Defaults to -50.
+	SilenceThreshold float64
+	// MinSilenceDuration is the minimum duration in seconds of a silent segment. Defaults to 2.
+	MinSilenceDuration float64
+	// MinBlackDuration is the minimum duration in seconds of a black segment. Defaults to 2.
+	MinBlackDuration float64
+	// FFmpegBinary allows specifying a custom path to the ffmpeg executable. Defaults to "ffmpeg".
+	FFmpegBinary string
+	// Runner launches the ffmpeg process. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+	// Logger receives the log messages. Defaults to logger.NewLogger().
+	Logger logger.Logger
+}
+
+// Analyzer measures loudness and detects silence and black frames using FFmpeg.
+// Create instances using New().
+type Analyzer struct {
+	options Options
+}
+
+// New creates a new Analyzer with the provided options.
+// It sets default values for options that are not specified.
+func New(options Options) *Analyzer {
+	if options.SilenceThreshold == 0 {
+		options.SilenceThreshold = -50
+	}
+	if options.MinSilenceDuration <= 0 {
+		options.MinSilenceDuration = 2
+	}
+	if options.MinBlackDuration <= 0 {
+		options.MinBlackDuration = 2
+	}
+	if options.FFmpegBinary == "" {
+		options.FFmpegBinary = "ffmpeg"
+	}
+	if options.Runner == nil {
+		options.Runner = ffmpeg.ExecRunner{}
+	}
+	if options.Logger == nil {
+		options.Logger = logger.NewLogger()
+	}
+
+	return &Analyzer{
+		options: options,
+	}
+}
+
+// Analyze runs a single ffmpeg pass over the input with the ebur128, silencedetect
+// and blackdetect filters and returns the parsed Report.
+// The context can be used to cancel the ffmpeg execution.
+func (a *Analyzer) Analyze(ctx context.Context) (*Report, error) {
+	args := a.buildFFmpegArgs()
+
+	a.options.Logger.Debug("Executing FFmpeg command", "analysis", map[string]interface{}{
+		"command": a.options.FFmpegBinary + " " + strings.Join(args, " "),
+	})
+
+	proc, err := a.options.Runner.Start(ctx, a.options.FFmpegBinary, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.CodecNotFoundError, errors.GetErrorMessage(errors.ErrMissingDependency), errors.ErrMissingDependency)
+	}
+
+	report := parseOutput(proc.Stderr())
+	report.Input = a.options.InputFile
+
+	if err := proc.Wait(); err != nil {
+		return nil, errors.Wrap(err, errors.TranscodingError, "FFmpeg analysis command failed", 2)
+	}
+
+	a.options.Logger.Info("Media analysis completed", "analysis", map[string]interface{}{
+		"integrated_loudness": report.IntegratedLoudness,
+		"true_peak":           report.TruePeak,
+		"silence_segments":    len(report.Silence),
+		"black_segments":      len(report.Black),
+	})
+
+	return report, nil
+}
+
+// buildFFmpegArgs constructs the ffmpeg arguments for the analysis pass.
+// This is an internal helper function.
+func (a *Analyzer) buildFFmpegArgs() []string {
+	return []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", a.options.InputFile,
+		"-af", fmt.Sprintf("ebur128=peak=true,silencedetect=noise=%gdB:d=%g", a.options.SilenceThreshold, a.options.MinSilenceDuration),
+		"-vf", fmt.Sprintf("blackdetect=d=%g", a.options.MinBlackDuration),
+		"-f", "null",
+		"-",
+	}
+}
+
+var (
+	silenceStartRegex = regexp.MustCompile(`silence_start:\s*(-?\d+(?:\.\d+)?)`)
+	silenceEndRegex   = regexp.MustCompile(`silence_end:\s*(-?\d+(?:\.\d+)?)\s*\|\s*silence_duration:\s*(\d+(?:\.\d+)?)`)
+	blackRegex        = regexp.MustCompile(`black_start:\s*(\d+(?:\.\d+)?)\s+black_end:\s*(\d+(?:\.\d+)?)\s+black_duration:\s*(\d+(?:\.\d+)?)`)
+	integratedRegex   = regexp.MustCompile(`^\s*I:\s*(-?\d+(?:\.\d+)?)\s*LUFS`)
+	rangeRegex        = regexp.MustCompile(`^\s*LRA:\s*(-?\d+(?:\.\d+)?)\s*LU`)
+	peakRegex         = regexp.MustCompile(`^\s*Peak:\s*(-?\d+(?:\.\d+)?|-inf)\s*dBFS`)
+)
+
+// parseOutput reads ffmpeg stderr and extracts the loudness summary and the
+// silence/black segments.
+// This is an internal helper function.
+func parseOutput(r io.Reader) *Report {
+	report := &Report{
+		Silence: []Segment{},
+		Black:   []Segment{},
+	}
+
+	// The ebur128 filter prints per-frame lines containing "I:" as well; only the
+	// values printed after the "Summary:" header are used.
+	inSummary := false
+	openSilence := -1
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.Contains(line, "silence_start"):
+			if m := silenceStartRegex.FindStringSubmatch(line); m != nil {
+				report.Silence = append(report.Silence, Segment{Start: parseFloat(m[1])})
+				openSilence = len(report.Silence) - 1
+			}
+		case strings.Contains(line, "silence_end"):
+			if m := silenceEndRegex.FindStringSubmatch(line); m != nil && openSilence >= 0 {
+				report.Silence[openSilence].End = parseFloat(m[1])
+				report.Silence[openSilence].Duration = parseFloat(m[2])
+				openSilence = -1
+			}
+		case strings.Contains(line, "black_start"):
+			if m := blackRegex.FindStringSubmatch(line); m != nil {
+				report.Black = append(report.Black, Segment{
+					Start:    parseFloat(m[1]),
+					End:      parseFloat(m[2]),
+					Duration: parseFloat(m[3]),
+				})
+			}
+		case strings.Contains(line, "Summary:"):
+			inSummary = true
+		case inSummary:
+			if m := integratedRegex.FindStringSubmatch(line); m != nil {
+				report.IntegratedLoudness = parseFloat(m[1])
+			} else if m := rangeRegex.FindStringSubmatch(line); m != nil {
+				report.LoudnessRange = parseFloat(m[1])
+			} else if m := peakRegex.FindStringSubmatch(line); m != nil {
+				report.TruePeak = parseFloat(m[1])
+			}
+		}
+	}
+
+	return report
+}
+
+// parseFloat converts an ffmpeg numeric value to float64, returning 0 for values
+// that cannot be represented (such as "-inf" for digital silence).
+func parseFloat(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleOutput = `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'input.mp4':
+[Parsed_ebur128_0 @ 0x1] t: 0.1  TARGET:-23 LUFS    M: -120.7 S:-120.7     I: -70.0 LUFS       LRA:   0.0 LU  FTPK: -inf dBFS  TPK: -inf dBFS
+[silencedetect @ 0x2] silence_start: 0
+[blackdetect @ 0x3] black_start:0 black_end:2.04 black_duration:2.04
+[silencedetect @ 0x2] silence_end: 3.2 | silence_duration: 3.2
+[silencedetect @ 0x2] silence_start: 28.5
+[Parsed_ebur128_0 @ 0x1] Summary:
+
+  Integrated loudness:
+    I:         -18.4 LUFS
+    Threshold: -28.7 LUFS
+
+  Loudness range:
+    LRA:         6.3 LU
+    Threshold: -38.9 LUFS
+    LRA low:   -23.1 LUFS
+    LRA high:  -16.8 LUFS
+
+  True peak:
+    Peak:       -0.9 dBFS
+`
+
+func TestNewAnalyzerDefaults(t *testing.T) {
+	a := New(Options{})
+
+	if a.options.SilenceThreshold != -50 {
+		t.Errorf("Default SilenceThreshold: got %v, want -50", a.options.SilenceThreshold)
+	}
+	if a.options.MinSilenceDuration != 2 {
+		t.Errorf("Default MinSilenceDuration: got %v, want 2", a.options.MinSilenceDuration)
+	}
+	if a.options.MinBlackDuration != 2 {
+		t.Errorf("Default MinBlackDuration: got %v, want 2", a.options.MinBlackDuration)
+	}
+	if a.options.FFmpegBinary != "ffmpeg" {
+		t.Errorf("Default FFmpegBinary: got %q, want \"ffmpeg\"", a.options.FFmpegBinary)
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	report := parseOutput(strings.NewReader(sampleOutput))
+
+	if report.IntegratedLoudness != -18.4 {
+		t.Errorf("IntegratedLoudness: got %v, want -18.4", report.IntegratedLoudness)
+	}
+	if report.LoudnessRange != 6.3 {
+		t.Errorf("LoudnessRange: got %v, want 6.3", report.LoudnessRange)
+	}
+	if report.TruePeak != -0.9 {
+		t.Errorf("TruePeak: got %v, want -0.9", report.TruePeak)
+	}
+
+	wantSilence := []Segment{{Start: 0, End: 3.2, Duration: 3.2}, {Start: 28.5}}
+	if !reflect.DeepEqual(report.Silence, wantSilence) {
+		t.Errorf("Silence: got %+v, want %+v", report.Silence, wantSilence)
+	}
+	wantBlack := []Segment{{Start: 0, End: 2.04, Duration: 2.04}}
+	if !reflect.DeepEqual(report.Black, wantBlack) {
+		t.Errorf("Black: got %+v, want %+v", report.Black, wantBlack)
+	}
+
+	if _, err := report.JSON(); err != nil {
+		t.Errorf("JSON() failed: %v", err)
+	}
+}
+
+func TestBuildFFmpegArgs(t *testing.T) {
+	a := New(Options{InputFile: "input.mp4"})
+	argsStr := strings.Join(a.buildFFmpegArgs(), " ")
+
+	for _, want := range []string{
+		"-i input.mp4",
+		"-af ebur128=peak=true,silencedetect=noise=-50dB:d=2",
+		"-vf blackdetect=d=2",
+		"-f null -",
+	} {
+		if !strings.Contains(argsStr, want) {
+			t.Errorf("Args missing %q: %s", want, argsStr)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// blankTolerance is the part of the input, in seconds, that may be left
+// uncovered at each end by a black or silent segment for the whole input to
+// count as blank. The detection filters report segments a frame late and stop
+// at the last full frame.
+const blankTolerance = 1.0
+
+// Blank reports whether the input is entirely black or silent, as bad uploads
+// such as screen recordings of a closed window or muted exports are.
+type Blank struct {
+	// Black is set when every frame of the video is black.
+	Black bool `json:"black"`
+	// Silent is set when the whole audio is below the silence threshold.
+	Silent bool `json:"silent"`
+}
+
+// DetectBlank runs a single ffmpeg pass over the input with only the
+// blackdetect and silencedetect filters, on downscaled frames, and reports
+// whether its video or audio is blank from start to end. duration is the
+// duration of the input in seconds; when unknown (zero), only silence lasting
+// until the end of the input can be detected. The streams the input does not
+// have (video or audio false) are not checked.
+func (a *Analyzer) DetectBlank(ctx context.Context, duration float64, video, audio bool) (Blank, error) {
+	if !video && !audio {
+		return Blank{}, nil
+	}
+	args := a.blankArgs(video, audio)
+
+	a.options.Logger.Debug("Executing FFmpeg command", "analysis", map[string]interface{}{
+		"command": a.options.FFmpegBinary + " " + strings.Join(args, " "),
+	})
+
+	proc, err := a.options.Runner.Start(ctx, a.options.FFmpegBinary, args...)
+	if err != nil {
+		return Blank{}, errors.Wrap(err, errors.CodecNotFoundError, errors.GetErrorMessage(errors.ErrMissingDependency), errors.ErrMissingDependency)
+	}
+	report := parseOutput(proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		return Blank{}, errors.Wrap(err, errors.TranscodingError, "FFmpeg blank detection command failed", 2)
+	}
+
+	blank := Blank{
+		Black:  video && coversInput(report.Black, duration),
+		Silent: audio && coversInput(report.Silence, duration),
+	}
+	a.options.Logger.Debug("Blank detection completed", "analysis", map[string]interface{}{
+		"black":            blank.Black,
+		"silent":           blank.Silent,
+		"silence_segments": len(report.Silence),
+		"black_segments":   len(report.Black),
+	})
+	return blank, nil
+}
+
+// blankArgs constructs the ffmpeg arguments for the blank detection pass.
+func (a *Analyzer) blankArgs(video, audio bool) []string {
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", a.options.InputFile,
+	}
+	if video {
+		args = append(args, "-map", "0:v:0", "-vf", fmt.Sprintf("scale=160:-2,blackdetect=d=%g", a.options.MinBlackDuration))
+	}
+	if audio {
+		args = append(args, "-map", "0:a:0", "-af", fmt.Sprintf("silencedetect=noise=%gdB:d=%g", a.options.SilenceThreshold, a.options.MinSilenceDuration))
+	}
+	return append(args, "-f", "null", "-")
+}
+
+// coversInput reports whether one of segments spans the whole input of
+// duration seconds, give or take blankTolerance.
+func coversInput(segments []Segment, duration float64) bool {
+	tolerance := math.Max(blankTolerance, duration*0.01)
+	for _, s := range segments {
+		if s.Start > tolerance {
+			continue
+		}
+		// Silence lasting until the end of the input has no end
+		if s.End == 0 && s.Duration == 0 {
+			return true
+		}
+		if duration > 0 && s.End >= duration-tolerance {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestCoversInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+		duration float64
+		want     bool
+	}{
+		{"whole input", []Segment{{Start: 0, End: 59.96, Duration: 59.96}}, 60, true},
+		{"until the end", []Segment{{Start: 0}}, 0, true},
+		{"start only", []Segment{{Start: 0, End: 2.04, Duration: 2.04}}, 60, false},
+		{"late start", []Segment{{Start: 5, End: 60, Duration: 55}}, 60, false},
+		{"unknown duration", []Segment{{Start: 0, End: 60, Duration: 60}}, 0, false},
+		{"nothing detected", nil, 60, false},
+	}
+	for _, tt := range tests {
+		if got := coversInput(tt.segments, tt.duration); got != tt.want {
+			t.Errorf("%s: coversInput() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectBlank(t *testing.T) {
+	var args []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, a []string) ffmpegtest.Result {
+		args = a
+		return ffmpegtest.Result{Stderr: "[blackdetect @ 0x3] black_start:0 black_end:29.97 black_duration:29.97\n" +
+			"[silencedetect @ 0x2] silence_start: 0\n[silencedetect @ 0x2] silence_end: 4 | silence_duration: 4\n"}
+	}}
+	a := New(Options{InputFile: "input.mp4", Runner: runner})
+
+	blank, err := a.DetectBlank(context.Background(), 30, true, true)
+	if err != nil {
+		t.Fatalf("DetectBlank() unexpected error: %v", err)
+	}
+	if !blank.Black || blank.Silent {
+		t.Errorf("DetectBlank() = %+v, want black and not silent", blank)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"-vf scale=160:-2,blackdetect=d=2", "-af silencedetect=noise=-50dB:d=2", "-f null -"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Args missing %q: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "ebur128") {
+		t.Errorf("The blank detection should not measure the loudness: %s", joined)
+	}
+
+	// Streams the input does not have are not checked
+	if blank, err = a.DetectBlank(context.Background(), 30, false, true); err != nil || blank.Black {
+		t.Errorf("DetectBlank() without video = %+v, %v", blank, err)
+	}
+	if strings.Contains(strings.Join(args, " "), "blackdetect") {
+		t.Errorf("The video should not be checked: %v", args)
+	}
+}
@@ -0,0 +1,346 @@
+// Package auth authenticates the HTTP requests of services exposing HLSpresso,
+// with API keys or HS256 JSON Web Tokens, authorizes them by role and limits
+// their rate per key.
+//
+// Clients send their credentials as "Authorization: Bearer <key or JWT>" or
+// in the X-API-Key header. Middleware rejects unauthenticated requests with
+// 401, requests whose role is insufficient with 403 and requests over the
+// rate limit of their key with 429.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role is the set of operations a principal may perform.
+type Role string
+
+const (
+	// RoleSubmitter may submit jobs and read their own jobs.
+	RoleSubmitter Role = "submitter"
+	// RoleAdmin may perform every operation, including those of submitters.
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole converts a role name ("submitter" or "admin", case-insensitive) to a Role.
+func ParseRole(name string) (Role, error) {
+	switch role := Role(strings.ToLower(strings.TrimSpace(name))); role {
+	case RoleSubmitter, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q (available: submitter, admin)", name)
+	}
+}
+
+// Allows reports whether r may perform the operations of required.
+func (r Role) Allows(required Role) bool {
+	return r == required || r == RoleAdmin
+}
+
+// Errors returned by Authenticate.
+var (
+	// ErrNoCredentials is returned for requests without an API key or token.
+	ErrNoCredentials = stderrors.New("missing API key or token")
+	// ErrInvalidCredentials is returned for unknown API keys and for tokens
+	// that are malformed, badly signed, expired or not yet valid.
+	ErrInvalidCredentials = stderrors.New("invalid API key or token")
+)
+
+// Limit is a rate limit: PerSecond requests per second on average, with
+// bursts of up to Burst requests. The zero Limit does not limit requests.
+type Limit struct {
+	PerSecond float64 `json:"per_second"`
+	Burst     int     `json:"burst"`
+}
+
+// Key is an API key and the principal it authenticates.
+type Key struct {
+	// Key is the secret sent by the client.
+	Key string `json:"key"`
+	// Subject names the client, e.g. "upload-service".
+	Subject string `json:"subject"`
+	// Role is the role of the client.
+	Role Role `json:"role"`
+	// Limit is the rate limit of the key.
+	Limit Limit `json:"limit"`
+	// Tenant is the tenant the client acts for. Defaults to the Subject.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Subject names the client: the Key.Subject, or the "sub" claim of a token.
+	Subject string
+	// Role is the role of the client.
+	Role Role
+	// Tenant is the tenant the client acts for: the Key.Tenant, or the
+	// "tenant" claim of a token, defaulting to the Subject.
+	Tenant string
+
+	// limit is the rate limit of the client, and bucket identifies its
+	// credential in the rate limiter: the hash of its API key, or the subject
+	// of its tokens.
+	limit  Limit
+	bucket string
+}
+
+// Options contains settings for an Authenticator.
+type Options struct {
+	// Keys lists the accepted API keys.
+	Keys []Key
+	// JWTSecret is the HMAC-SHA256 secret of accepted tokens. Tokens are
+	// rejected when it is empty. Their "sub" claim names the subject, their
+	// "role" claim its role and their optional "tenant" claim its tenant.
+	JWTSecret []byte
+	// JWTIssuer, if set, is the required "iss" claim of tokens.
+	JWTIssuer string
+	// JWTLimit is the rate limit of each token subject.
+	JWTLimit Limit
+}
+
+// Authenticator authenticates, authorizes and rate limits requests. It is
+// safe for concurrent use.
+type Authenticator struct {
+	options Options
+	// keys maps the SHA-256 of each API key to the key, so that keys are
+	// compared in constant time.
+	keys map[[sha256.Size]byte]Key
+	now  func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	// sweptAt is when the full buckets were last removed from buckets.
+	sweptAt time.Time
+}
+
+// New returns an Authenticator accepting the keys and tokens of options.
+func New(options Options) (*Authenticator, error) {
+	a := &Authenticator{
+		options: options,
+		keys:    make(map[[sha256.Size]byte]Key),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	for _, key := range options.Keys {
+		if key.Key == "" || key.Subject == "" {
+			return nil, fmt.Errorf("API keys need a key and a subject")
+		}
+		role, err := ParseRole(string(key.Role))
+		if err != nil {
+			return nil, fmt.Errorf("API key of %s: %w", key.Subject, err)
+		}
+		key.Role = role
+		if key.Tenant == "" {
+			key.Tenant = key.Subject
+		}
+		if key.Limit.PerSecond < 0 || key.Limit.Burst < 0 {
+			return nil, fmt.Errorf("API key of %s: negative rate limit", key.Subject)
+		}
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, ok := a.keys[hash]; ok {
+			return nil, fmt.Errorf("API key of %s is used twice", key.Subject)
+		}
+		a.keys[hash] = key
+	}
+	return a, nil
+}
+
+// Authenticate returns the principal of the credentials of r.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		credential = strings.TrimSpace(bearer)
+	}
+	if credential == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(credential))
+	if key, ok := a.keys[hash]; ok {
+		return Principal{Subject: key.Subject, Role: key.Role, Tenant: key.Tenant, limit: key.Limit,
+			bucket: "key:" + hex.EncodeToString(hash[:])}, nil
+	}
+	if strings.Count(credential, ".") == 2 && len(a.options.JWTSecret) > 0 {
+		return a.verifyToken(credential)
+	}
+	return Principal{}, ErrInvalidCredentials
+}
+
+// Middleware returns a handler calling next for the requests authenticated
+// with at least the role required and within the rate limit of their key. The
+// Principal of the request is available to next with FromContext.
+func (a *Authenticator) Middleware(required Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="HLSpresso"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !principal.Role.Allows(required) {
+			http.Error(w, fmt.Sprintf("role %s required", required), http.StatusForbidden)
+			return
+		}
+		if wait := a.reserve(principal); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// principalKey is the context key of the Principal of a request.
+type principalKey struct{}
+
+// FromContext returns the Principal of the request authenticated by Middleware.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// claims are the claims of the tokens accepted by verifyToken.
+type claims struct {
+	Subject   string   `json:"sub"`
+	Role      string   `json:"role"`
+	Issuer    string   `json:"iss"`
+	Tenant    string   `json:"tenant"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// verifyToken checks the HS256 signature and the claims of token.
+func (a *Authenticator) verifyToken(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Algorithm != "HS256" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	mac := hmac.New(sha256.New, a.options.JWTSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if subtle.ConstantTimeCompare(signature, mac.Sum(nil)) != 1 {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil || c.Subject == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	now := float64(a.now().Unix())
+	if (c.ExpiresAt != nil && now >= *c.ExpiresAt) || (c.NotBefore != nil && now < *c.NotBefore) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if a.options.JWTIssuer != "" && c.Issuer != a.options.JWTIssuer {
+		return Principal{}, ErrInvalidCredentials
+	}
+	role, err := ParseRole(c.Role)
+	if err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if c.Tenant == "" {
+		c.Tenant = c.Subject
+	}
+	return Principal{Subject: c.Subject, Role: role, Tenant: c.Tenant, limit: a.options.JWTLimit, bucket: "jwt:" + c.Subject}, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// SignToken returns an HS256 token for subject with role, valid for ttl (or
+// without expiry if ttl is zero), e.g. to issue tokens to trusted clients.
+func SignToken(secret []byte, subject string, role Role, issuer string, ttl time.Duration) (string, error) {
+	c := map[string]interface{}{"sub": subject, "role": role}
+	if issuer != "" {
+		c["iss"] = issuer
+	}
+	if ttl != 0 {
+		c["exp"] = time.Now().Add(ttl).Unix()
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// bucketSweepInterval is how often reserve removes the full buckets.
+const bucketSweepInterval = time.Minute
+
+// bucket is the token bucket of a rate limited credential.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// full reports whether the bucket has refilled by now, so that it behaves
+// like a new one.
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.limit.PerSecond >= float64(max(b.limit.Burst, 1))
+}
+
+// reserve takes a token from the bucket of principal, returning zero, or how
+// long to wait for the next token when the bucket is empty. Every
+// bucketSweepInterval, it removes the buckets that refilled, so that the
+// buckets of credentials no longer used do not accumulate.
+func (a *Authenticator) reserve(principal Principal) time.Duration {
+	limit := principal.limit
+	if limit.PerSecond == 0 {
+		return 0
+	}
+	burst := float64(max(limit.Burst, 1))
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if now.Sub(a.sweptAt) >= bucketSweepInterval {
+		for key, b := range a.buckets {
+			if b.full(now) {
+				delete(a.buckets, key)
+			}
+		}
+		a.sweptAt = now
+	}
+	b, ok := a.buckets[principal.bucket]
+	if !ok {
+		b = &bucket{tokens: burst, last: now, limit: limit}
+		a.buckets[principal.bucket] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.PerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.PerSecond * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
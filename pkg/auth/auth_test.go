@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	a, err := New(Options{
+		Keys: []Key{
+			{Key: "submit-key", Subject: "upload-service", Role: RoleSubmitter, Limit: Limit{PerSecond: 1, Burst: 2}},
+			{Key: "admin-key", Subject: "ops", Role: RoleAdmin, Tenant: "acme"},
+		},
+		JWTSecret: []byte("jwt-secret"),
+		JWTIssuer: "hlspresso-test",
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return a
+}
+
+func request(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newTestAuthenticator(t)
+	valid, _ := SignToken([]byte("jwt-secret"), "ci", RoleAdmin, "hlspresso-test", time.Hour)
+	expired, _ := SignToken([]byte("jwt-secret"), "ci", RoleAdmin, "hlspresso-test", -time.Hour)
+	forged, _ := SignToken([]byte("other-secret"), "ci", RoleAdmin, "hlspresso-test", time.Hour)
+	otherIssuer, _ := SignToken([]byte("jwt-secret"), "ci", RoleAdmin, "elsewhere", time.Hour)
+	unsigned := strings.Join(strings.Split(valid, ".")[:2], ".") + "."
+
+	for _, tc := range []struct {
+		name          string
+		header, value string
+		want          Principal
+		wantErr       error
+	}{
+		{name: "bearer key", header: "Authorization", value: "Bearer submit-key", want: Principal{Subject: "upload-service", Role: RoleSubmitter, Tenant: "upload-service"}},
+		{name: "X-API-Key", header: "X-API-Key", value: "admin-key", want: Principal{Subject: "ops", Role: RoleAdmin, Tenant: "acme"}},
+		{name: "token", header: "Authorization", value: "Bearer " + valid, want: Principal{Subject: "ci", Role: RoleAdmin, Tenant: "ci"}},
+		{name: "no credentials", wantErr: ErrNoCredentials},
+		{name: "unknown key", header: "X-API-Key", value: "guess", wantErr: ErrInvalidCredentials},
+		{name: "expired token", header: "Authorization", value: "Bearer " + expired, wantErr: ErrInvalidCredentials},
+		{name: "forged token", header: "Authorization", value: "Bearer " + forged, wantErr: ErrInvalidCredentials},
+		{name: "other issuer", header: "Authorization", value: "Bearer " + otherIssuer, wantErr: ErrInvalidCredentials},
+		{name: "unsigned token", header: "Authorization", value: "Bearer " + unsigned, wantErr: ErrInvalidCredentials},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := a.Authenticate(request(tc.header, tc.value))
+			if err != tc.wantErr || got.Subject != tc.want.Subject || got.Role != tc.want.Role || got.Tenant != tc.want.Tenant {
+				t.Errorf("Authenticate() = %+v, %v, want %+v, %v", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t)
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+
+	var subject string
+	handler := a.Middleware(RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := FromContext(r.Context())
+		subject = principal.Subject
+	}))
+	serve := func(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := serve(handler, request("X-API-Key", "admin-key")); rec.Code != http.StatusOK || subject != "ops" {
+		t.Errorf("Admin request: status %d, subject %q", rec.Code, subject)
+	}
+	if rec := serve(handler, request("", "")); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Anonymous request: status %d, headers %v", rec.Code, rec.Header())
+	}
+	if rec := serve(handler, request("X-API-Key", "submit-key")); rec.Code != http.StatusForbidden {
+		t.Errorf("Submitter request to an admin handler: status %d, want 403", rec.Code)
+	}
+
+	// The submitter key allows bursts of 2 requests, then 1 per second
+	submit := a.Middleware(RoleSubmitter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := serve(submit, request("X-API-Key", "submit-key"))
+		if rec.Code != want {
+			t.Errorf("Request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+		}
+	}
+	now = now.Add(time.Second)
+	if rec := serve(submit, request("X-API-Key", "submit-key")); rec.Code != http.StatusOK {
+		t.Errorf("Request after a second: status %d, want 200", rec.Code)
+	}
+	// Admin keys have no limit
+	for i := 0; i < 10; i++ {
+		if rec := serve(submit, request("X-API-Key", "admin-key")); rec.Code != http.StatusOK {
+			t.Fatalf("Admin request %d: status %d", i+1, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitBuckets(t *testing.T) {
+	limit := Limit{PerSecond: 1, Burst: 1}
+	a, err := New(Options{
+		Keys: []Key{
+			{Key: "first-key", Subject: "upload-service", Role: RoleSubmitter, Limit: limit},
+			{Key: "second-key", Subject: "upload-service", Role: RoleSubmitter, Limit: limit},
+		},
+		JWTSecret: []byte("jwt-secret"),
+		JWTLimit:  limit,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+	token, _ := SignToken([]byte("jwt-secret"), "upload-service", RoleSubmitter, "", time.Hour)
+
+	// Each key and the tokens have their own bucket, even with the same subject
+	for _, credential := range []string{"first-key", "second-key", token} {
+		principal, err := a.Authenticate(request("Authorization", "Bearer "+credential))
+		if err != nil {
+			t.Fatalf("Authenticate() error: %v", err)
+		}
+		if wait := a.reserve(principal); wait != 0 {
+			t.Errorf("First request of %s: wait %v, want 0", principal.bucket, wait)
+		}
+		if wait := a.reserve(principal); wait == 0 {
+			t.Errorf("Second request of %s: no wait", principal.bucket)
+		}
+	}
+	if len(a.buckets) != 3 {
+		t.Fatalf("%d buckets, want 3", len(a.buckets))
+	}
+
+	// The next sweep removes the buckets that refilled
+	now = now.Add(bucketSweepInterval)
+	principal, _ := a.Authenticate(request("X-API-Key", "first-key"))
+	a.reserve(principal)
+	if len(a.buckets) != 1 {
+		t.Errorf("%d buckets after the sweep, want 1", len(a.buckets))
+	}
+}
+
+func TestNewInvalidKeys(t *testing.T) {
+	for name, keys := range map[string][]Key{
+		"no subject": {{Key: "k", Role: RoleAdmin}},
+		"role":       {{Key: "k", Subject: "s", Role: "owner"}},
+		"duplicate":  {{Key: "k", Subject: "a", Role: RoleAdmin}, {Key: "k", Subject: "b", Role: RoleAdmin}},
+		"limit":      {{Key: "k", Subject: "s", Role: RoleAdmin, Limit: Limit{PerSecond: -1}}},
+	} {
+		if _, err := New(Options{Keys: keys}); err == nil {
+			t.Errorf("%s: New() expected an error", name)
+		}
+	}
+}
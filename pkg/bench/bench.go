@@ -0,0 +1,455 @@
+// Package bench encodes a short sample of an input with several H.264 encoders
+// and presets, and reports the speed and quality (SSIM, PSNR) of each, to help
+// choose the preset and hardware encoder that suit the machine.
+package bench
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+)
+
+// SoftwareEncoder is the encoder HLSpresso uses, always benchmarked.
+const SoftwareEncoder = "libx264"
+
+// DefaultPresets lists the presets benchmarked for each encoder when
+// Options.Presets is empty. Encoders without presets have a single "" entry.
+var DefaultPresets = map[string][]string{
+	SoftwareEncoder:     {"ultrafast", "veryfast", "faster", "medium", "slow"},
+	"h264_nvenc":        {"p1", "p4", "p7"},
+	"h264_qsv":          {"veryfast", "medium", "veryslow"},
+	"h264_videotoolbox": {""},
+}
+
+// HardwareEncoders lists the hardware H.264 encoders Options.DetectHardware
+// looks for, in the order they are benchmarked.
+var HardwareEncoders = []string{"h264_nvenc", "h264_qsv", "h264_videotoolbox"}
+
+// Result is the benchmark of one encoder and preset.
+type Result struct {
+	// Encoder is the ffmpeg encoder (e.g. "libx264" or "h264_nvenc").
+	Encoder string `json:"encoder"`
+	// Preset is the encoder preset, empty for encoders without presets.
+	Preset string `json:"preset,omitempty"`
+	// Speed is the encode speed relative to realtime (e.g. 2.5 for 2.5x).
+	Speed float64 `json:"speed"`
+	// FPS is the number of frames encoded per second.
+	FPS float64 `json:"fps"`
+	// EncodeSeconds is the wall clock time of the encode.
+	EncodeSeconds float64 `json:"encode_seconds"`
+	// SizeBytes is the size of the encoded sample.
+	SizeBytes int64 `json:"size_bytes"`
+	// SSIM is the structural similarity of the encoded sample to the input (0 to 1).
+	SSIM float64 `json:"ssim"`
+	// PSNR is the average peak signal-to-noise ratio in dB.
+	PSNR float64 `json:"psnr_db"`
+	// Error is set when the encode or the quality measurement failed, e.g. for
+	// a hardware encoder built into ffmpeg without the matching hardware.
+	Error string `json:"error,omitempty"`
+}
+
+// Name returns the encoder and preset of the result (e.g. "libx264/medium").
+func (r Result) Name() string {
+	if r.Preset == "" {
+		return r.Encoder
+	}
+	return r.Encoder + "/" + r.Preset
+}
+
+// Report holds the results of a benchmark.
+type Report struct {
+	// Input is the benchmarked file or URL.
+	Input string `json:"input"`
+	// SampleSeconds is the length of the encoded sample.
+	SampleSeconds float64 `json:"sample_seconds"`
+	// Height is the height of the encoded sample.
+	Height int `json:"height"`
+	// Bitrate is the target video bitrate of the encoded sample.
+	Bitrate string `json:"bitrate"`
+	// Results lists the benchmark of every encoder and preset, in order.
+	Results []Result `json:"results"`
+	// Recommended is the result with the best SSIM among those at least
+	// Options.MinSpeed fast, or the fastest one if none is. Nil if every
+	// encode failed.
+	Recommended *Result `json:"recommended,omitempty"`
+}
+
+// JSON returns the Report serialized as an indented JSON string.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Options contains settings for the benchmark.
+type Options struct {
+	// InputFile is the path (or URL) of the sample to encode.
+	InputFile string
+	// SampleSeconds is the length of the sample encoded from the start of the input. Defaults to 10.
+	SampleSeconds float64
+	// Height is the height the sample is scaled to. Defaults to 720.
+	Height int
+	// Bitrate is the target video bitrate, identical for every encoder so that
+	// their quality can be compared. Defaults to "2800k".
+	Bitrate string
+	// Encoders lists the encoders to benchmark. Defaults to SoftwareEncoder.
+	Encoders []string
+	// DetectHardware adds the HardwareEncoders the ffmpeg build provides to Encoders.
+	DetectHardware bool
+	// Presets lists the presets benchmarked for every encoder. Defaults to the
+	// DefaultPresets of each encoder.
+	Presets []string
+	// MinSpeed is the speed a result needs to be recommended. Defaults to 1 (realtime).
+	MinSpeed float64
+	// WorkDir is where the encoded samples are written; they are deleted once
+	// measured. Defaults to a new temporary directory.
+	WorkDir string
+	// FFmpegBinary allows specifying a custom path to the ffmpeg executable. Defaults to "ffmpeg".
+	FFmpegBinary string
+	// Runner launches the ffmpeg processes. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+	// Logger receives the log messages. Defaults to logger.NewLogger().
+	Logger logger.Logger
+}
+
+// Benchmark encodes samples of an input with several encoders and presets.
+// Create instances using New().
+type Benchmark struct {
+	options Options
+}
+
+// New creates a new Benchmark with the provided options.
+// It sets default values for options that are not specified.
+func New(options Options) *Benchmark {
+	if options.SampleSeconds <= 0 {
+		options.SampleSeconds = 10
+	}
+	if options.Height <= 0 {
+		options.Height = 720
+	}
+	if options.Bitrate == "" {
+		options.Bitrate = "2800k"
+	}
+	if len(options.Encoders) == 0 {
+		options.Encoders = []string{SoftwareEncoder}
+	}
+	if options.MinSpeed <= 0 {
+		options.MinSpeed = 1
+	}
+	if options.FFmpegBinary == "" {
+		options.FFmpegBinary = "ffmpeg"
+	}
+	if options.Runner == nil {
+		options.Runner = ffmpeg.ExecRunner{}
+	}
+	if options.Logger == nil {
+		options.Logger = logger.NewLogger()
+	}
+
+	return &Benchmark{
+		options: options,
+	}
+}
+
+// Run encodes the sample with every encoder and preset, one at a time so that
+// they do not compete for the machine, measures the quality of each against
+// the input, and returns the Report. Failed encodes are reported in their
+// Result. The context can be used to cancel the benchmark.
+func (b *Benchmark) Run(ctx context.Context) (*Report, error) {
+	if b.options.InputFile == "" {
+		return nil, errors.New(errors.ValidationError, "No input to benchmark", "", 1)
+	}
+
+	workDir := b.options.WorkDir
+	if workDir == "" {
+		dir, err := os.MkdirTemp("", "hlspresso-bench-")
+		if err != nil {
+			return nil, errors.Wrap(err, errors.SystemError, "Failed to create the benchmark directory", 2)
+		}
+		defer os.RemoveAll(dir)
+		workDir = dir
+	}
+
+	report := &Report{
+		Input:         b.options.InputFile,
+		SampleSeconds: b.options.SampleSeconds,
+		Height:        b.options.Height,
+		Bitrate:       b.options.Bitrate,
+		Results:       []Result{},
+	}
+	for _, encoder := range b.encoders(ctx) {
+		presets := b.options.Presets
+		if len(presets) == 0 {
+			presets = DefaultPresets[encoder]
+		}
+		if len(presets) == 0 {
+			presets = []string{""}
+		}
+		for _, preset := range presets {
+			result := b.runCase(ctx, workDir, encoder, preset)
+			if err := ctx.Err(); err != nil {
+				return nil, errors.Wrap(err, errors.TranscodingError, "Benchmark cancelled", 3)
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	report.Recommended = recommend(report.Results, b.options.MinSpeed)
+
+	fields := map[string]interface{}{"results": len(report.Results)}
+	if report.Recommended != nil {
+		fields["recommended"] = report.Recommended.Name()
+	}
+	b.options.Logger.Info("Benchmark completed", "bench", fields)
+
+	return report, nil
+}
+
+// encoders returns the encoders to benchmark: Encoders, followed by the
+// HardwareEncoders listed by "ffmpeg -encoders" when DetectHardware is set.
+func (b *Benchmark) encoders(ctx context.Context) []string {
+	encoders := append([]string(nil), b.options.Encoders...)
+	if !b.options.DetectHardware {
+		return encoders
+	}
+
+	output, err := b.options.Runner.Output(ctx, b.options.FFmpegBinary, "-hide_banner", "-encoders")
+	if err != nil {
+		b.options.Logger.Warn("Failed to list the ffmpeg encoders, skipping hardware encoders", "bench", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return encoders
+	}
+	available := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			available[fields[1]] = true
+		}
+	}
+	for _, encoder := range HardwareEncoders {
+		if available[encoder] && !contains(encoders, encoder) {
+			encoders = append(encoders, encoder)
+		}
+	}
+	return encoders
+}
+
+// runCase encodes the sample with encoder and preset and measures its quality.
+// This is an internal helper function.
+func (b *Benchmark) runCase(ctx context.Context, workDir, encoder, preset string) Result {
+	result := Result{Encoder: encoder, Preset: preset}
+	output := filepath.Join(workDir, strings.ReplaceAll(result.Name(), "/", "_")+".mp4")
+	defer os.Remove(output)
+
+	start := time.Now()
+	stats, err := b.ffmpeg(ctx, b.encodeArgs(encoder, preset, output))
+	result.EncodeSeconds = time.Since(start).Seconds()
+	if err != nil {
+		result.Error = err.Error()
+		b.logFailure(result)
+		return result
+	}
+	result.Speed, result.FPS = stats.speed, stats.fps
+	if info, err := os.Stat(output); err == nil {
+		result.SizeBytes = info.Size()
+	}
+
+	result.SSIM, result.PSNR, err = b.Quality(ctx, output)
+	if err != nil {
+		result.Error = "quality measurement failed: " + err.Error()
+		b.logFailure(result)
+		return result
+	}
+
+	b.options.Logger.Info("Benchmark case completed", "bench", map[string]interface{}{
+		"case":  result.Name(),
+		"speed": result.Speed,
+		"ssim":  result.SSIM,
+	})
+	return result
+}
+
+// logFailure logs a benchmark case that failed.
+func (b *Benchmark) logFailure(result Result) {
+	b.options.Logger.Warn("Benchmark case failed", "bench", map[string]interface{}{
+		"case":  result.Name(),
+		"error": result.Error,
+	})
+}
+
+// encodeArgs builds the ffmpeg arguments encoding the video of the sample.
+// This is an internal helper function.
+func (b *Benchmark) encodeArgs(encoder, preset, output string) []string {
+	args := []string{
+		"-hide_banner",
+		"-t", strconv.FormatFloat(b.options.SampleSeconds, 'f', -1, 64),
+		"-i", b.options.InputFile,
+		"-map", "0:v:0",
+		"-vf", b.scaleFilter(),
+		"-c:v", encoder,
+	}
+	if preset != "" {
+		args = append(args, "-preset", preset)
+	}
+	return append(args, "-b:v", b.options.Bitrate, "-y", output)
+}
+
+// Quality measures the structural similarity (SSIM) and the peak
+// signal-to-noise ratio (PSNR) of the video of encoded, such as the sample of
+// a benchmark case or a rendition encoded elsewhere, against the first
+// SampleSeconds of the input, both scaled to Height.
+func (b *Benchmark) Quality(ctx context.Context, encoded string) (ssim, psnr float64, err error) {
+	stats, err := b.ffmpeg(ctx, b.qualityArgs(encoded))
+	if err != nil {
+		return 0, 0, err
+	}
+	return stats.ssim, stats.psnr, nil
+}
+
+// qualityArgs builds the ffmpeg arguments comparing the encoded video with
+// the same part of the input, both scaled to Height, with the ssim and psnr
+// filters.
+// This is an internal helper function.
+func (b *Benchmark) qualityArgs(encoded string) []string {
+	return []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", encoded,
+		"-t", strconv.FormatFloat(b.options.SampleSeconds, 'f', -1, 64),
+		"-i", b.options.InputFile,
+		"-lavfi", fmt.Sprintf("[0:v]%s,split[e1][e2];[1:v]%s,split[r1][r2];[e1][r1]ssim;[e2][r2]psnr", b.scaleFilter(), b.scaleFilter()),
+		"-f", "null",
+		"-",
+	}
+}
+
+// scaleFilter returns the filter scaling the sample to Height, keeping the aspect ratio.
+func (b *Benchmark) scaleFilter() string {
+	return fmt.Sprintf("scale=-2:%d", b.options.Height)
+}
+
+// ffmpeg runs ffmpeg with args and parses the statistics it prints.
+// This is an internal helper function.
+func (b *Benchmark) ffmpeg(ctx context.Context, args []string) (stats, error) {
+	b.options.Logger.Debug("Executing FFmpeg command", "bench", map[string]interface{}{
+		"command": b.options.FFmpegBinary + " " + strings.Join(args, " "),
+	})
+
+	proc, err := b.options.Runner.Start(ctx, b.options.FFmpegBinary, args...)
+	if err != nil {
+		return stats{}, err
+	}
+	s, last := parseOutput(proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		if last != "" {
+			return s, fmt.Errorf("%w: %s", err, last)
+		}
+		return s, err
+	}
+	return s, nil
+}
+
+// stats are the figures parsed from ffmpeg output.
+type stats struct {
+	speed, fps float64
+	ssim, psnr float64
+}
+
+var (
+	speedRegex = regexp.MustCompile(`speed=\s*(\d+(?:\.\d+)?)x`)
+	fpsRegex   = regexp.MustCompile(`fps=\s*(\d+(?:\.\d+)?)`)
+	ssimRegex  = regexp.MustCompile(`SSIM .*All:(\d+(?:\.\d+)?)`)
+	psnrRegex  = regexp.MustCompile(`PSNR .*average:(\d+(?:\.\d+)?|inf)`)
+)
+
+// parseOutput reads ffmpeg stderr and returns the last progress figures and
+// the quality summaries, with the last non-empty line (the error message when
+// ffmpeg fails). Progress lines end with a carriage return.
+// This is an internal helper function.
+func parseOutput(r io.Reader) (s stats, last string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		last = line
+		if m := speedRegex.FindStringSubmatch(line); m != nil {
+			s.speed, _ = strconv.ParseFloat(m[1], 64)
+		}
+		if m := fpsRegex.FindStringSubmatch(line); m != nil {
+			s.fps, _ = strconv.ParseFloat(m[1], 64)
+		}
+		if m := ssimRegex.FindStringSubmatch(line); m != nil {
+			s.ssim, _ = strconv.ParseFloat(m[1], 64)
+		}
+		if m := psnrRegex.FindStringSubmatch(line); m != nil {
+			// Identical frames have an infinite PSNR
+			s.psnr, _ = strconv.ParseFloat(strings.Replace(m[1], "inf", "100", 1), 64)
+		}
+	}
+	return s, last
+}
+
+// scanLines is a bufio.SplitFunc splitting on both \n and \r.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, c := range data {
+		if c == '\n' || c == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// recommend returns the successful result with the best SSIM among those at
+// least minSpeed fast, or the fastest successful result if none is.
+func recommend(results []Result, minSpeed float64) *Result {
+	var best, fastest *Result
+	for i := range results {
+		r := &results[i]
+		if r.Error != "" {
+			continue
+		}
+		if fastest == nil || r.Speed > fastest.Speed {
+			fastest = r
+		}
+		if r.Speed >= minSpeed && (best == nil || r.SSIM > best.SSIM) {
+			best = r
+		}
+	}
+	if best == nil {
+		best = fastest
+	}
+	if best == nil {
+		return nil
+	}
+	recommended := *best
+	return &recommended
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
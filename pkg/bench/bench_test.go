@@ -0,0 +1,118 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+const encodersOutput = `Encoders:
+ V..... = Video
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ V....D h264_vaapi           H.264/AVC (VAAPI) (codec h264)
+`
+
+// benchFFmpeg simulates ffmpeg: presets run at the speeds and reach the SSIM
+// given, and the nvenc encoder fails as on a machine without an NVIDIA GPU.
+func benchFFmpeg(speeds, ssims map[string]float64) func(name string, args []string) ffmpegtest.Result {
+	var current string
+	return func(name string, args []string) ffmpegtest.Result {
+		joined := strings.Join(args, " ")
+		switch {
+		case strings.Contains(joined, "-encoders"):
+			return ffmpegtest.Result{Stdout: encodersOutput}
+		case strings.Contains(joined, "h264_nvenc"):
+			return ffmpegtest.Result{Stderr: "Cannot load libcuda.so.1\n", Err: fmt.Errorf("exit status 1")}
+		case strings.Contains(joined, "-lavfi"):
+			return ffmpegtest.Result{Stderr: fmt.Sprintf(
+				"[Parsed_ssim_4 @ 0x1] SSIM Y:0.99 U:0.99 V:0.99 All:%g (20.0)\n"+
+					"[Parsed_psnr_5 @ 0x2] PSNR y:42.1 u:45.0 v:45.2 average:42.9 min:38.0 max:50.1\n", ssims[current])}
+		default:
+			for i, arg := range args {
+				if arg == "-preset" {
+					current = args[i+1]
+				}
+			}
+			return ffmpegtest.Result{Stderr: fmt.Sprintf(
+				"frame=  100 fps= 50 q=28.0 size=1024kB time=00:00:04.00 speed=0.5x\r"+
+					"frame=  250 fps= 60 q=-1.0 Lsize=2048kB time=00:00:10.00 speed=%gx\n", speeds[current])}
+		}
+	}
+}
+
+func TestNewBenchmarkDefaults(t *testing.T) {
+	b := New(Options{})
+	if b.options.SampleSeconds != 10 || b.options.Height != 720 || b.options.Bitrate != "2800k" || b.options.MinSpeed != 1 {
+		t.Errorf("Unexpected defaults: %+v", b.options)
+	}
+	if len(b.options.Encoders) != 1 || b.options.Encoders[0] != SoftwareEncoder {
+		t.Errorf("Default Encoders = %v, want [%s]", b.options.Encoders, SoftwareEncoder)
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	s, last := parseOutput(strings.NewReader(
+		"frame=  100 fps= 50 speed=1.2x\rframe=  250 fps= 62.5 speed=2.5x\n" +
+			"[Parsed_ssim_2 @ 0x1] SSIM Y:0.98 U:0.99 V:0.99 All:0.983412 (17.7)\n" +
+			"[Parsed_psnr_3 @ 0x2] PSNR y:40.1 u:44.0 v:44.2 average:inf min:38.0 max:inf\n"))
+	if s.speed != 2.5 || s.fps != 62.5 || s.ssim != 0.983412 || s.psnr != 100 {
+		t.Errorf("parseOutput() = %+v", s)
+	}
+	if !strings.HasPrefix(last, "[Parsed_psnr_3") {
+		t.Errorf("parseOutput() last line = %q", last)
+	}
+}
+
+func TestRun(t *testing.T) {
+	runner := &ffmpegtest.Runner{Handler: benchFFmpeg(
+		map[string]float64{"ultrafast": 8, "medium": 1.5, "slow": 0.7},
+		map[string]float64{"ultrafast": 0.95, "medium": 0.97, "slow": 0.98},
+	)}
+	report, err := New(Options{
+		InputFile:      "sample.mp4",
+		Presets:        []string{"ultrafast", "medium", "slow"},
+		DetectHardware: true,
+		WorkDir:        t.TempDir(),
+		Runner:         runner,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	// libx264 and the detected nvenc encoder, without the unsupported vaapi one
+	if len(report.Results) != 6 {
+		t.Fatalf("Run() returned %d results, want 6: %+v", len(report.Results), report.Results)
+	}
+	medium := report.Results[1]
+	if medium.Name() != "libx264/medium" || medium.Speed != 1.5 || medium.FPS != 60 || medium.SSIM != 0.97 || medium.PSNR != 42.9 {
+		t.Errorf("Unexpected medium result: %+v", medium)
+	}
+	for _, r := range report.Results[3:] {
+		if r.Encoder != "h264_nvenc" || !strings.Contains(r.Error, "libcuda") {
+			t.Errorf("Unexpected nvenc result: %+v", r)
+		}
+	}
+	// slow has the best SSIM but is slower than realtime
+	if report.Recommended == nil || report.Recommended.Name() != "libx264/medium" {
+		t.Errorf("Recommended = %+v, want libx264/medium", report.Recommended)
+	}
+}
+
+func TestRecommendFallsBackToFastest(t *testing.T) {
+	results := []Result{
+		{Encoder: SoftwareEncoder, Preset: "medium", Speed: 0.4, SSIM: 0.97},
+		{Encoder: SoftwareEncoder, Preset: "ultrafast", Speed: 0.9, SSIM: 0.94},
+		{Encoder: "h264_nvenc", Preset: "p1", Error: "failed"},
+	}
+	if got := recommend(results, 1); got == nil || got.Preset != "ultrafast" {
+		t.Errorf("recommend() = %+v, want ultrafast", got)
+	}
+	if got := recommend(results[2:], 1); got != nil {
+		t.Errorf("recommend() = %+v, want nil when every case failed", got)
+	}
+}
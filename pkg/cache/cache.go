@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// keyVersion is mixed into every key; bump it when the layout or the meaning
+// of cached outputs changes so that old entries are no longer used.
+const keyVersion = "hlspresso-cache-v1"
+
+// entryName is the name of the cached output inside an entry directory.
+const entryName = "output"
+
+// Options contains settings for the output cache.
+type Options struct {
+	// Dir is the directory the cache entries are stored in.
+	Dir string
+	// Link hard-links restored files to the cache instead of copying them, which
+	// is faster and uses no extra disk space. Outputs restored this way must be
+	// treated as read-only, since modifying them in place modifies the cache.
+	// Files that cannot be linked (e.g. across file systems) are copied.
+	Link bool
+}
+
+// Cache stores transcoded outputs keyed by the checksum of their input and the
+// settings they were produced with, so identical transcodes can be restored
+// instead of encoded again.
+type Cache struct {
+	options Options
+}
+
+// New creates a Cache storing entries in options.Dir.
+func New(options Options) *Cache {
+	return &Cache{options: options}
+}
+
+// Key returns the cache key of the output produced from the file at inputPath
+// with settings, which must be JSON-encodable and include everything that
+// affects the output.
+func Key(inputPath string, settings interface{}) (string, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	io.WriteString(hash, keyVersion+"\n")
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	hash.Write(encoded)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// path returns the directory of the entry for key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.options.Dir, key[:2], key)
+}
+
+// Has reports whether an entry exists for key.
+func (c *Cache) Has(key string) bool {
+	_, err := os.Stat(filepath.Join(c.path(key), entryName))
+	return err == nil
+}
+
+// Restore writes the output cached for key to dst, a file or a directory like
+// the output that was stored. Existing files at dst are replaced.
+func (c *Cache) Restore(key, dst string) error {
+	src := filepath.Join(c.path(key), entryName)
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	return copyTree(src, dst, c.options.Link)
+}
+
+// Store copies the output at src (a file or a directory) into the cache under
+// key. The entry only becomes visible once it is complete, so a failed or
+// concurrent Store never leaves a partial entry behind.
+func (c *Cache) Store(key, src string) error {
+	entry := c.path(key)
+	if c.Has(key) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(entry), ".tmp-"+key[:8]+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// Always copy: linking would let later writes to the output corrupt the entry
+	if err := copyTree(src, filepath.Join(tmp, entryName), false); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, entry); err != nil {
+		if c.Has(key) {
+			// Stored concurrently by another process
+			return nil
+		}
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+	return nil
+}
+
+// copyTree copies the file or directory src to dst, hard-linking files when
+// link is set and falling back to copying them.
+func copyTree(src, dst string, link bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if link && os.Link(path, target) == nil {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies the regular file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.mp4")
+	copied := filepath.Join(dir, "copy.mp4")
+	other := filepath.Join(dir, "other.mp4")
+	writeFile(t, input, "video")
+	writeFile(t, copied, "video")
+	writeFile(t, other, "other video")
+
+	key := func(path string, settings interface{}) string {
+		k, err := Key(path, settings)
+		if err != nil {
+			t.Fatalf("Key(%s) unexpected error: %v", path, err)
+		}
+		return k
+	}
+	settings := map[string]int{"segment_duration": 6}
+
+	if key(input, settings) != key(copied, settings) {
+		t.Error("Key() should only depend on the input content, not its path")
+	}
+	if key(input, settings) == key(other, settings) {
+		t.Error("Key() should differ for different inputs")
+	}
+	if key(input, settings) == key(input, map[string]int{"segment_duration": 10}) {
+		t.Error("Key() should differ for different settings")
+	}
+	if _, err := Key(filepath.Join(dir, "missing.mp4"), settings); err == nil {
+		t.Error("Key() expected an error for a missing input")
+	}
+}
+
+func TestStoreRestore(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "hls")
+	writeFile(t, filepath.Join(output, "master.m3u8"), "#EXTM3U")
+	writeFile(t, filepath.Join(output, "stream_0", "segment_000.ts"), "segment")
+
+	for _, link := range []bool{false, true} {
+		c := New(Options{Dir: filepath.Join(dir, "cache"), Link: link})
+		key := "0a1b2c3d4e5f"
+		if !link {
+			if c.Has(key) {
+				t.Fatal("Has() = true before Store")
+			}
+			if err := c.Store(key, output); err != nil {
+				t.Fatalf("Store() unexpected error: %v", err)
+			}
+		}
+		if !c.Has(key) {
+			t.Fatal("Has() = false after Store")
+		}
+
+		restored := filepath.Join(dir, "restored")
+		writeFile(t, filepath.Join(restored, "master.m3u8"), "stale")
+		if err := c.Restore(key, restored); err != nil {
+			t.Fatalf("Restore(link=%v) unexpected error: %v", link, err)
+		}
+		for name, want := range map[string]string{"master.m3u8": "#EXTM3U", "stream_0/segment_000.ts": "segment"} {
+			got, err := os.ReadFile(filepath.Join(restored, filepath.FromSlash(name)))
+			if err != nil || string(got) != want {
+				t.Errorf("Restored %s = %q, %v; want %q", name, got, err, want)
+			}
+		}
+	}
+
+	// Changing the output after storing it does not change the entry
+	writeFile(t, filepath.Join(output, "master.m3u8"), "changed")
+	c := New(Options{Dir: filepath.Join(dir, "cache")})
+	single := filepath.Join(dir, "single.m3u8")
+	if err := c.Restore("0a1b2c3d4e5f", filepath.Join(dir, "again")); err != nil {
+		t.Fatalf("Restore() unexpected error: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "again", "master.m3u8")); string(got) != "#EXTM3U" {
+		t.Errorf("Cached master.m3u8 = %q after the output changed", got)
+	}
+	if err := c.Restore("ffffffffffff", single); err == nil {
+		t.Error("Restore() expected an error for a missing entry")
+	}
+}
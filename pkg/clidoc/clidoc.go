@@ -0,0 +1,228 @@
+// Package clidoc generates reference documentation (Markdown pages and man pages)
+// for a cobra command tree, one page per available command.
+package clidoc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// DefaultManSection is the manual section of generated man pages (user commands).
+const DefaultManSection = "1"
+
+// GenMarkdownTree writes a Markdown page for cmd and each of its available
+// subcommands into dir, named after the command path (e.g. "HLSpresso_plan.md").
+func GenMarkdownTree(cmd *cobra.Command, dir string) error {
+	return genTree(cmd, dir, func(c *cobra.Command) string {
+		return strings.ReplaceAll(c.CommandPath(), " ", "_") + ".md"
+	}, GenMarkdown)
+}
+
+// GenManTree writes a man page for cmd and each of its available subcommands
+// into dir, named after the command path (e.g. "HLSpresso-plan.1").
+func GenManTree(cmd *cobra.Command, dir string) error {
+	return genTree(cmd, dir, func(c *cobra.Command) string {
+		return manName(c) + "." + DefaultManSection
+	}, GenMan)
+}
+
+// genTree writes the page generated by gen for cmd and its subcommands.
+func genTree(cmd *cobra.Command, dir string, name func(*cobra.Command) string, gen func(*cobra.Command, io.Writer) error) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create documentation directory", 1)
+	}
+	for _, c := range commands(cmd) {
+		var buf bytes.Buffer
+		if err := gen(c, &buf); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name(c))
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return errors.Wrap(err, errors.SystemError, "Failed to write documentation page", 2)
+		}
+	}
+	return nil
+}
+
+// commands returns cmd followed by its available subcommands, depth first.
+// Hidden, deprecated and help commands are skipped.
+func commands(cmd *cobra.Command) []*cobra.Command {
+	all := []*cobra.Command{cmd}
+	for _, c := range subcommands(cmd) {
+		all = append(all, commands(c)...)
+	}
+	return all
+}
+
+// subcommands returns the available subcommands of cmd sorted by name.
+func subcommands(cmd *cobra.Command) []*cobra.Command {
+	var subs []*cobra.Command
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() && !c.IsAdditionalHelpTopicCommand() {
+			subs = append(subs, c)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Name() < subs[j].Name() })
+	return subs
+}
+
+// GenMarkdown writes the Markdown page of cmd to w.
+func GenMarkdown(cmd *cobra.Command, w io.Writer) error {
+	cmd.InitDefaultHelpFlag()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "## %s\n\n%s\n\n", cmd.CommandPath(), cmd.Short)
+	if cmd.Long != "" {
+		fmt.Fprintf(&buf, "### Synopsis\n\n%s\n\n", cmd.Long)
+	}
+	if cmd.Runnable() {
+		fmt.Fprintf(&buf, "```\n%s\n```\n\n", cmd.UseLine())
+	}
+	if cmd.Example != "" {
+		fmt.Fprintf(&buf, "### Examples\n\n```\n%s\n```\n\n", cmd.Example)
+	}
+	if flags := cmd.NonInheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, "### Options\n\n```\n%s```\n\n", flags.FlagUsages())
+	}
+	if flags := cmd.InheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, "### Options inherited from parent commands\n\n```\n%s```\n\n", flags.FlagUsages())
+	}
+
+	var related []*cobra.Command
+	if cmd.HasParent() {
+		related = append(related, cmd.Parent())
+	}
+	related = append(related, subcommands(cmd)...)
+	if len(related) > 0 {
+		buf.WriteString("### SEE ALSO\n\n")
+		for _, c := range related {
+			link := strings.ReplaceAll(c.CommandPath(), " ", "_") + ".md"
+			fmt.Fprintf(&buf, "* [%s](%s)\t - %s\n", c.CommandPath(), link, c.Short)
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// GenMan writes the man page (roff) of cmd to w.
+func GenMan(cmd *cobra.Command, w io.Writer) error {
+	cmd.InitDefaultHelpFlag()
+	root := cmd.Root().Name()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, ".TH \"%s\" \"%s\" \"\" \"%s\" \"%s Manual\"\n",
+		roff(strings.ToUpper(manName(cmd))), DefaultManSection, roff(root), roff(root))
+	fmt.Fprintf(&buf, ".SH NAME\n%s \\- %s\n", roff(manName(cmd)), roff(cmd.Short))
+	fmt.Fprintf(&buf, ".SH SYNOPSIS\n.B %s\n%s\n", roff(cmd.CommandPath()), roff(strings.TrimPrefix(cmd.UseLine(), cmd.CommandPath()+" ")))
+
+	description := cmd.Long
+	if description == "" {
+		description = cmd.Short
+	}
+	fmt.Fprintf(&buf, ".SH DESCRIPTION\n%s\n", roffParagraphs(description))
+
+	if flags := cmd.NonInheritedFlags(); flags.HasAvailableFlags() {
+		buf.WriteString(".SH OPTIONS\n")
+		manFlags(&buf, flags)
+	}
+	if flags := cmd.InheritedFlags(); flags.HasAvailableFlags() {
+		buf.WriteString(".SH OPTIONS INHERITED FROM PARENT COMMANDS\n")
+		manFlags(&buf, flags)
+	}
+	if cmd.Example != "" {
+		fmt.Fprintf(&buf, ".SH EXAMPLES\n.nf\n%s\n.fi\n", roff(cmd.Example))
+	}
+
+	var related []string
+	if cmd.HasParent() {
+		related = append(related, manName(cmd.Parent()))
+	}
+	for _, c := range subcommands(cmd) {
+		related = append(related, manName(c))
+	}
+	if len(related) > 0 {
+		buf.WriteString(".SH SEE ALSO\n")
+		for i, name := range related {
+			sep := ","
+			if i == len(related)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, ".BR %s (%s)%s\n", roff(name), DefaultManSection, sep)
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// manFlags writes a tagged paragraph per visible flag.
+func manFlags(buf *bytes.Buffer, flags *pflag.FlagSet) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		varname, usage := pflag.UnquoteUsage(f)
+		name := "--" + f.Name
+		if f.Shorthand != "" {
+			name = "-" + f.Shorthand + ", " + name
+		}
+		if varname != "" {
+			name += " " + varname
+		}
+		if def := defaultValue(f); def != "" {
+			usage += " (default " + def + ")"
+		}
+		fmt.Fprintf(buf, ".TP\n.B %s\n%s\n", roff(name), roff(usage))
+	})
+}
+
+// defaultValue returns the default of f as shown in help output, or an empty
+// string for zero defaults.
+func defaultValue(f *pflag.Flag) string {
+	switch f.DefValue {
+	case "", "false", "0", "[]":
+		return ""
+	}
+	if f.Value.Type() == "string" {
+		return fmt.Sprintf("%q", f.DefValue)
+	}
+	return f.DefValue
+}
+
+// manName returns the man page name of cmd, e.g. "HLSpresso-plan".
+func manName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+// roff escapes text for use in a roff line: backslashes and hyphens are escaped
+// and lines starting with a control character are protected.
+func roff(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffParagraphs escapes text and separates its paragraphs with .PP requests.
+func roffParagraphs(text string) string {
+	paragraphs := strings.Split(strings.TrimSpace(text), "\n\n")
+	for i, p := range paragraphs {
+		paragraphs[i] = roff(p)
+	}
+	return strings.Join(paragraphs, "\n.PP\n")
+}
@@ -0,0 +1,99 @@
+package clidoc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newTestCommand() *cobra.Command {
+	root := &cobra.Command{Use: "tool", Short: "Transcode videos", Run: func(*cobra.Command, []string) {}}
+	root.PersistentFlags().BoolP("quiet", "q", false, "Only print errors")
+	root.Flags().StringP("input", "i", "", "Input file path")
+	root.Flags().String("type", "hls", "Output type")
+
+	plan := &cobra.Command{Use: "plan", Short: "Show the plan", Long: "Show the plan.\n\nNothing is encoded.", Run: func(*cobra.Command, []string) {}}
+	plan.Flags().Int("max-output-size", 0, "Maximum size in MB")
+	root.AddCommand(plan)
+	root.AddCommand(&cobra.Command{Use: "secret", Short: "Hidden", Hidden: true, Run: func(*cobra.Command, []string) {}})
+	return root
+}
+
+func TestGenMarkdown(t *testing.T) {
+	root := newTestCommand()
+	plan, _, _ := root.Find([]string{"plan"})
+
+	var buf bytes.Buffer
+	if err := GenMarkdown(plan, &buf); err != nil {
+		t.Fatalf("GenMarkdown() unexpected error: %v", err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		"## tool plan\n",
+		"### Synopsis\n\nShow the plan.",
+		"tool plan [flags]",
+		"--max-output-size int",
+		"### Options inherited from parent commands",
+		"-q, --quiet",
+		"* [tool](tool.md)",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("GenMarkdown() page does not contain %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestGenMan(t *testing.T) {
+	root := newTestCommand()
+
+	var buf bytes.Buffer
+	if err := GenMan(root, &buf); err != nil {
+		t.Fatalf("GenMan() unexpected error: %v", err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		`.TH "TOOL" "1" "" "tool" "tool Manual"`,
+		".SH NAME\ntool \\- Transcode videos\n",
+		".B \\-i, \\-\\-input string\nInput file path\n",
+		".B \\-\\-type string\nOutput type (default \"hls\")\n",
+		".BR tool\\-plan (1)\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("GenMan() page does not contain %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "secret") {
+		t.Error("GenMan() should not reference hidden commands")
+	}
+}
+
+func TestGenTrees(t *testing.T) {
+	root := newTestCommand()
+	tests := map[string]func(*cobra.Command, string) error{
+		"tool.md,tool_plan.md": GenMarkdownTree,
+		"tool-plan.1,tool.1":   GenManTree,
+	}
+	for want, gen := range tests {
+		dir := filepath.Join(t.TempDir(), "docs")
+		if err := gen(root, dir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("generated files = %s, want %s", got, want)
+		}
+	}
+}
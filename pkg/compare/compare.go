@@ -0,0 +1,384 @@
+// Package compare encodes the same input with two sets of transcoder options
+// and produces a side-by-side comparison video, with a report of the quality
+// (SSIM, PSNR), size and encode time of each, to evaluate changes of the
+// ladder or of the encoder settings before rolling them out.
+package compare
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/bench"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+	"github.com/heyjunin/HLSpresso/pkg/transcoder"
+)
+
+// Side is the outcome of one of the option sets compared.
+type Side struct {
+	// Label is "a" or "b".
+	Label string `json:"label"`
+	// OutputType is the type of the output of the option set.
+	OutputType transcoder.OutputType `json:"output_type"`
+	// Output is the path of the output: the master playlist or the MP4 file.
+	// It is removed with the work directory unless Options.WorkDir is set.
+	Output string `json:"output"`
+	// Rendition is the compared video file: the MP4 file, or the variant
+	// playlist of the HLS rendition picked for Options.Height.
+	Rendition string `json:"rendition"`
+	// Width and Height are the size of Rendition, unknown (zero) for MP4.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// EncodeSeconds is the wall clock time of the transcode.
+	EncodeSeconds float64 `json:"encode_seconds"`
+	// SizeBytes is the size of the whole output, every rendition included.
+	SizeBytes int64 `json:"size_bytes"`
+	// SSIM is the structural similarity of Rendition to the input (0 to 1).
+	SSIM float64 `json:"ssim"`
+	// PSNR is the average peak signal-to-noise ratio of Rendition in dB.
+	PSNR float64 `json:"psnr_db"`
+}
+
+// Report holds the results of a comparison.
+type Report struct {
+	// Input is the compared input.
+	Input string `json:"input"`
+	// SampleSeconds is the length of the input encoded by both option sets.
+	SampleSeconds float64 `json:"sample_seconds"`
+	// Height is the height both renditions are compared at.
+	Height int `json:"height"`
+	// Comparison is the side-by-side video: A on the left, B on the right.
+	Comparison string `json:"comparison"`
+	// A and B are the outcomes of the two option sets.
+	A Side `json:"a"`
+	B Side `json:"b"`
+	// SSIMDelta is the SSIM of B minus that of A: positive when B looks
+	// closer to the input.
+	SSIMDelta float64 `json:"ssim_delta"`
+	// SizeRatio is the size of the output of B relative to that of A (e.g.
+	// 0.8 when B is 20% smaller).
+	SizeRatio float64 `json:"size_ratio"`
+}
+
+// JSON returns the Report serialized as an indented JSON string.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Options contains settings for the comparison.
+type Options struct {
+	// InputFile is the path of the input encoded by both option sets.
+	InputFile string
+	// A and B are the option sets compared, e.g. the current and the new
+	// ladder. Their input and output paths are set by Run, OutputType
+	// defaults to transcoder.HLSOutput, and the cache, uploads and
+	// SkipIfComplete are disabled so that both are encoded in full.
+	A, B transcoder.Options
+	// OutputFile is the side-by-side comparison MP4 to write.
+	OutputFile string
+	// Height is the height the videos are compared at: the rendition with
+	// the largest height not above it is picked from HLS outputs (the
+	// smallest one if all are larger), and both sides are scaled to it.
+	// Defaults to 720.
+	Height int
+	// SampleSeconds is the length of the input encoded, from its start.
+	// Defaults to 30.
+	SampleSeconds float64
+	// WorkDir is where the outputs of A and B are written, in the "a" and "b"
+	// subdirectories. Defaults to a new temporary directory, removed once the
+	// comparison is done.
+	WorkDir string
+	// FFmpegBinary allows specifying a custom path to the ffmpeg executable. Defaults to "ffmpeg".
+	FFmpegBinary string
+	// Runner launches the ffmpeg processes, those of the transcodes included.
+	// Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+	// Logger receives the log messages. Defaults to logger.NewLogger().
+	Logger logger.Logger
+}
+
+// Comparison encodes an input with two option sets and compares the outputs.
+// Create instances using New().
+type Comparison struct {
+	options Options
+	runner  ffmpeg.Runner
+}
+
+// New creates a new Comparison with the provided options.
+// It sets default values for options that are not specified.
+func New(options Options) *Comparison {
+	if options.Height <= 0 {
+		options.Height = 720
+	}
+	if options.SampleSeconds <= 0 {
+		options.SampleSeconds = 30
+	}
+	if options.FFmpegBinary == "" {
+		options.FFmpegBinary = "ffmpeg"
+	}
+	if options.Logger == nil {
+		options.Logger = logger.NewLogger()
+	}
+	runner := options.Runner
+	if runner == nil {
+		runner = ffmpeg.ExecRunner{}
+	}
+
+	return &Comparison{
+		options: options,
+		runner:  runner,
+	}
+}
+
+// Run transcodes the first SampleSeconds of the input with both option sets,
+// one after the other so that their encode times can be compared, measures
+// the quality of the rendition of each at Height against the input, writes
+// the side-by-side video to OutputFile and returns the Report. The context
+// can be used to cancel the comparison.
+func (c *Comparison) Run(ctx context.Context) (*Report, error) {
+	if c.options.InputFile == "" {
+		return nil, errors.New(errors.ValidationError, "No input to compare", "", 1)
+	}
+	if c.options.OutputFile == "" {
+		return nil, errors.New(errors.ValidationError, "No comparison output", "Set the path of the side-by-side MP4", 1)
+	}
+
+	workDir := c.options.WorkDir
+	if workDir == "" {
+		dir, err := os.MkdirTemp("", "hlspresso-compare-")
+		if err != nil {
+			return nil, errors.Wrap(err, errors.SystemError, "Failed to create the comparison directory", 2)
+		}
+		defer os.RemoveAll(dir)
+		workDir = dir
+	}
+
+	report := &Report{
+		Input:         c.options.InputFile,
+		SampleSeconds: c.options.SampleSeconds,
+		Height:        c.options.Height,
+		Comparison:    c.options.OutputFile,
+	}
+	for _, side := range []struct {
+		label   string
+		options transcoder.Options
+		result  *Side
+	}{
+		{"a", c.options.A, &report.A},
+		{"b", c.options.B, &report.B},
+	} {
+		result, err := c.runSide(ctx, workDir, side.label, side.options)
+		if err != nil {
+			return nil, err
+		}
+		*side.result = result
+	}
+	report.SSIMDelta = report.B.SSIM - report.A.SSIM
+	if report.A.SizeBytes > 0 {
+		report.SizeRatio = float64(report.B.SizeBytes) / float64(report.A.SizeBytes)
+	}
+
+	if err := c.ffmpeg(ctx, c.stackArgs(report.A.Rendition, report.B.Rendition)); err != nil {
+		return nil, errors.Wrap(err, errors.TranscodingError, "Failed to create the side-by-side video", 3)
+	}
+
+	c.options.Logger.Info("Comparison completed", "compare", map[string]interface{}{
+		"comparison": c.options.OutputFile,
+		"ssim_delta": report.SSIMDelta,
+		"size_ratio": report.SizeRatio,
+	})
+	return report, nil
+}
+
+// runSide transcodes the sample with the option set labeled label into
+// workDir and measures the rendition to compare.
+// This is an internal helper function.
+func (c *Comparison) runSide(ctx context.Context, workDir, label string, options transcoder.Options) (Side, error) {
+	options.InputPath = c.options.InputFile
+	if options.OutputType == "" {
+		options.OutputType = transcoder.HLSOutput
+	}
+	options.OutputPath = filepath.Join(workDir, label)
+	if options.OutputType == transcoder.MP4Output {
+		options.OutputPath = filepath.Join(workDir, label, label+".mp4")
+	}
+	if options.FFmpegBinary == "" {
+		options.FFmpegBinary = c.options.FFmpegBinary
+	}
+	options.FFmpegInputParams = append([]string{"-t", strconv.FormatFloat(c.options.SampleSeconds, 'f', -1, 64)}, options.FFmpegInputParams...)
+	options.AllowOverwrite = true
+	options.SkipIfComplete = false
+	options.CacheDir, options.UploadURL = "", ""
+
+	var deps []transcoder.DependencyOption
+	if c.options.Runner != nil {
+		deps = append(deps, transcoder.WithRunner(c.options.Runner))
+	}
+	trans, err := transcoder.NewWithDeps(options, nil, c.options.Logger, nil, deps...)
+	if err != nil {
+		return Side{}, err
+	}
+
+	c.options.Logger.Info("Encoding comparison side", "compare", map[string]interface{}{
+		"side":   label,
+		"type":   string(options.OutputType),
+		"output": options.OutputPath,
+	})
+	start := time.Now()
+	output, err := trans.Transcode(ctx)
+	if err != nil {
+		return Side{}, err
+	}
+	side := Side{
+		Label:         label,
+		OutputType:    options.OutputType,
+		Output:        output,
+		Rendition:     output,
+		EncodeSeconds: time.Since(start).Seconds(),
+		SizeBytes:     dirSize(filepath.Join(workDir, label)),
+	}
+	if options.OutputType == transcoder.HLSOutput {
+		variant, ok := pickVariant(output, c.options.Height)
+		if !ok {
+			return Side{}, errors.New(errors.HLSError, "No rendition to compare", output, 4)
+		}
+		side.Rendition, side.Width, side.Height = variant.path, variant.width, variant.height
+	}
+
+	side.SSIM, side.PSNR, err = bench.New(bench.Options{
+		InputFile:     c.options.InputFile,
+		SampleSeconds: c.options.SampleSeconds,
+		Height:        c.options.Height,
+		FFmpegBinary:  c.options.FFmpegBinary,
+		Runner:        c.runner,
+		Logger:        c.options.Logger,
+	}).Quality(ctx, side.Rendition)
+	if err != nil {
+		return Side{}, errors.Wrap(err, errors.TranscodingError, "Failed to measure the quality of side "+label, 3)
+	}
+	return side, nil
+}
+
+// stackArgs builds the ffmpeg arguments placing the video of a on the left of
+// that of b, both scaled to Height, into OutputFile.
+// This is an internal helper function.
+func (c *Comparison) stackArgs(a, b string) []string {
+	scale := fmt.Sprintf("scale=-2:%d,setsar=1,format=yuv420p", c.options.Height)
+	return []string{
+		"-hide_banner",
+		"-i", a,
+		"-i", b,
+		"-filter_complex", fmt.Sprintf("[0:v]%s[a];[1:v]%s[b];[a][b]hstack=inputs=2[v]", scale, scale),
+		"-map", "[v]",
+		"-c:v", "libx264",
+		"-crf", "18",
+		"-preset", "veryfast",
+		"-y", c.options.OutputFile,
+	}
+}
+
+// ffmpeg runs ffmpeg with args, returning the last line of its output when it
+// fails.
+// This is an internal helper function.
+func (c *Comparison) ffmpeg(ctx context.Context, args []string) error {
+	c.options.Logger.Debug("Executing FFmpeg command", "compare", map[string]interface{}{
+		"command": c.options.FFmpegBinary + " " + strings.Join(args, " "),
+	})
+
+	proc, err := c.runner.Start(ctx, c.options.FFmpegBinary, args...)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	_, _ = io.Copy(&stderr, proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		output := strings.TrimSpace(stderr.String())
+		if output != "" {
+			return fmt.Errorf("%w: %s", err, output[strings.LastIndex(output, "\n")+1:])
+		}
+		return err
+	}
+	return nil
+}
+
+// variant is a rendition listed in a master playlist.
+type variant struct {
+	path          string
+	width, height int
+}
+
+var resolutionPattern = regexp.MustCompile(`RESOLUTION=(\d+)x(\d+)`)
+
+// pickVariant returns the variant of the master playlist at masterPath with
+// the largest height not above height, or the smallest one if all are
+// larger. ok is false if the playlist cannot be read or lists no variant
+// with a resolution.
+func pickVariant(masterPath string, height int) (picked variant, ok bool) {
+	file, err := os.Open(masterPath)
+	if err != nil {
+		return picked, false
+	}
+	defer file.Close()
+
+	var variants []variant
+	var pending *variant
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			pending = nil
+			if m := resolutionPattern.FindStringSubmatch(line); m != nil {
+				width, _ := strconv.Atoi(m[1])
+				h, _ := strconv.Atoi(m[2])
+				pending = &variant{width: width, height: h}
+			}
+		case line != "" && !strings.HasPrefix(line, "#") && pending != nil:
+			pending.path = filepath.Join(filepath.Dir(masterPath), filepath.FromSlash(line))
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+
+	for _, v := range variants {
+		switch {
+		case !ok:
+			picked, ok = v, true
+		case picked.height > height && v.height < picked.height:
+			picked = v
+		case v.height <= height && (picked.height > height || v.height > picked.height):
+			picked = v
+		}
+	}
+	return picked, ok
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
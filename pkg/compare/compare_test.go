@@ -0,0 +1,115 @@
+package compare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/transcoder"
+)
+
+const master = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080
+stream_0.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=842x480
+stream_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720
+stream_2.m3u8
+`
+
+func TestPickVariant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.m3u8")
+	if err := os.WriteFile(path, []byte(master), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for height, want := range map[int]string{720: "stream_2.m3u8", 600: "stream_1.m3u8", 2160: "stream_0.m3u8", 360: "stream_1.m3u8"} {
+		got, ok := pickVariant(path, height)
+		if !ok || filepath.Base(got.path) != want {
+			t.Errorf("pickVariant(%d) = %+v, %v, want %s", height, got, ok, want)
+		}
+	}
+	if _, ok := pickVariant(filepath.Join(t.TempDir(), "missing.m3u8"), 720); ok {
+		t.Error("pickVariant() of a missing playlist should fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(input, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workDir := filepath.Join(dir, "work")
+	var encodes, stack []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		joined := strings.Join(args, " ")
+		switch {
+		case name == "ffprobe":
+			return ffmpegtest.Result{Stdout: `{"streams": [{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "pix_fmt": "yuv420p"}],
+				"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "120.0"}}`}
+		case len(args) == 1:
+			return ffmpegtest.Result{Stdout: "ffmpeg version 6.1 libx264 aac"}
+		case strings.Contains(joined, "-lavfi"):
+			ssim := 0.95
+			if strings.Contains(args[3], "/b/") {
+				ssim = 0.97
+			}
+			return ffmpegtest.Result{Stderr: fmt.Sprintf("[Parsed_ssim_4 @ 0x1] SSIM Y:0.99 U:0.99 V:0.99 All:%g (20.0)\n"+
+				"[Parsed_psnr_5 @ 0x2] PSNR y:42.1 u:45.0 v:45.2 average:42.9 min:38.0 max:50.1\n", ssim)}
+		case strings.Contains(joined, "hstack"):
+			stack = args
+			return ffmpegtest.Result{}
+		}
+		encodes = append(encodes, joined)
+		output := args[len(args)-1]
+		if strings.HasSuffix(output, ".mp4") {
+			os.WriteFile(output, make([]byte, 1000), 0644)
+		} else {
+			os.WriteFile(filepath.Join(workDir, "b", "master.m3u8"), []byte(master), 0644)
+			os.WriteFile(filepath.Join(workDir, "b", "stream_2.ts"), make([]byte, 800), 0644)
+		}
+		return ffmpegtest.Result{}
+	}}
+
+	report, err := New(Options{
+		InputFile:     input,
+		A:             transcoder.Options{OutputType: transcoder.MP4Output, SkipDiskSpaceCheck: true},
+		B:             transcoder.Options{SkipDiskSpaceCheck: true, CacheDir: filepath.Join(dir, "cache")},
+		OutputFile:    filepath.Join(dir, "ab.mp4"),
+		SampleSeconds: 20,
+		WorkDir:       workDir,
+		Runner:        runner,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Os dois lados codificam só a amostra da entrada
+	if len(encodes) != 2 || !strings.HasPrefix(encodes[0], "-t 20 -i "+input) || !strings.HasPrefix(encodes[1], "-t 20 -i "+input) {
+		t.Errorf("encodes = %q, want both sides encoding a 20 s sample", encodes)
+	}
+	if report.A.Rendition != filepath.Join(workDir, "a", "a.mp4") || report.B.Rendition != filepath.Join(workDir, "b", "stream_2.m3u8") || report.B.Height != 720 {
+		t.Errorf("renditions = %q, %q (%dp)", report.A.Rendition, report.B.Rendition, report.B.Height)
+	}
+	if report.A.SSIM != 0.95 || report.B.SSIM != 0.97 || report.SSIMDelta < 0.0199 || report.SSIMDelta > 0.0201 {
+		t.Errorf("SSIM = %g, %g (delta %g)", report.A.SSIM, report.B.SSIM, report.SSIMDelta)
+	}
+	if report.A.SizeBytes != 1000 || report.B.SizeBytes <= 800 || report.SizeRatio != float64(report.B.SizeBytes)/1000 {
+		t.Errorf("sizes = %d, %d (ratio %g)", report.A.SizeBytes, report.B.SizeBytes, report.SizeRatio)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache")); !os.IsNotExist(err) {
+		t.Errorf("the cache of side b was used: %v", err)
+	}
+	if joined := strings.Join(stack, " "); !strings.Contains(joined, "-i "+report.A.Rendition+" -i "+report.B.Rendition) ||
+		!strings.Contains(joined, "[a][b]hstack=inputs=2[v]") || !strings.HasSuffix(joined, filepath.Join(dir, "ab.mp4")) {
+		t.Errorf("stack args = %q", joined)
+	}
+
+	if _, err := New(Options{InputFile: input}).Run(context.Background()); err == nil {
+		t.Error("Run() without OutputFile expected an error")
+	}
+}
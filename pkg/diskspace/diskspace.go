@@ -0,0 +1,54 @@
+package diskspace
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// Default minimum free space thresholds used by the Transcoder when no explicit
+// threshold is configured.
+const (
+	// DefaultMinFreeSpace is required before downloading inputs and writing MP4 output (500 MB).
+	DefaultMinFreeSpace uint64 = 500 * 1024 * 1024
+	// DefaultMinFreeSpaceHLS is required before writing HLS output, which stores several
+	// renditions (1 GB).
+	DefaultMinFreeSpaceHLS uint64 = 1024 * 1024 * 1024
+)
+
+// ErrUnsupported is returned by a Checker when free space cannot be determined
+// on the current platform.
+var ErrUnsupported = stderrors.New("disk space check not supported on this platform")
+
+// Checker reports the free space available to the current user at a given path.
+// Platform specific implementations are selected with build tags; custom
+// implementations can be provided for testing or special filesystems.
+type Checker interface {
+	// FreeSpace returns the number of bytes available at path.
+	FreeSpace(path string) (uint64, error)
+}
+
+// NewChecker returns the Checker implementation for the current platform.
+func NewChecker() Checker {
+	return platformChecker{}
+}
+
+// Check verifies that at least minBytes are available at path.
+// It returns a *errors.StructuredError of type DiskSpaceError if the free space is
+// below the threshold. If the free space cannot be determined (including
+// ErrUnsupported), the error from the Checker is returned unchanged so callers can
+// decide whether to ignore it.
+func Check(checker Checker, path string, minBytes uint64) error {
+	free, err := checker.FreeSpace(path)
+	if err != nil {
+		return err
+	}
+	if free < minBytes {
+		return errors.New(errors.DiskSpaceError,
+			errors.GetErrorMessage(errors.ErrDiskSpaceInsufficient),
+			fmt.Sprintf("Espaço disponível: %d bytes (mínimo: %d bytes)", free, minBytes),
+			errors.ErrDiskSpaceInsufficient)
+	}
+	return nil
+}
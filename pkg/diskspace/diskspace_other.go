@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package diskspace
+
+// platformChecker is used on platforms without a free space implementation.
+type platformChecker struct{}
+
+// FreeSpace always returns ErrUnsupported.
+func (platformChecker) FreeSpace(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
@@ -0,0 +1,50 @@
+package diskspace
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// fakeChecker returns a fixed amount of free space or error.
+type fakeChecker struct {
+	free uint64
+	err  error
+}
+
+func (f fakeChecker) FreeSpace(path string) (uint64, error) {
+	return f.free, f.err
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check(fakeChecker{free: 2048}, "/tmp", 1024); err != nil {
+		t.Errorf("Check() with enough space returned error: %v", err)
+	}
+
+	err := Check(fakeChecker{free: 512}, "/tmp", 1024)
+	var sErr *errors.StructuredError
+	if !stderrors.As(err, &sErr) {
+		t.Fatalf("Check() with low space should return a StructuredError, got %v", err)
+	}
+	if sErr.Type != errors.DiskSpaceError || sErr.Code != errors.ErrDiskSpaceInsufficient {
+		t.Errorf("Unexpected error: type=%s code=%d", sErr.Type, sErr.Code)
+	}
+
+	if err := Check(fakeChecker{err: ErrUnsupported}, "/tmp", 1024); !stderrors.Is(err, ErrUnsupported) {
+		t.Errorf("Check() should pass through checker errors, got %v", err)
+	}
+}
+
+func TestPlatformChecker(t *testing.T) {
+	free, err := NewChecker().FreeSpace(t.TempDir())
+	if stderrors.Is(err, ErrUnsupported) {
+		t.Skip("Disk space check not supported on this platform")
+	}
+	if err != nil {
+		t.Fatalf("FreeSpace() failed: %v", err)
+	}
+	if free == 0 {
+		t.Error("FreeSpace() returned 0 for a temporary directory")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package diskspace
+
+import "golang.org/x/sys/unix"
+
+// platformChecker uses statfs(2) to determine the free space.
+type platformChecker struct{}
+
+// FreeSpace returns the number of bytes available to unprivileged users at path.
+func (platformChecker) FreeSpace(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package diskspace
+
+import "golang.org/x/sys/windows"
+
+// platformChecker uses GetDiskFreeSpaceExW to determine the free space.
+type platformChecker struct{}
+
+// FreeSpace returns the number of bytes available to the calling user at path.
+func (platformChecker) FreeSpace(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &freeBytesAvailable, &totalBytes, &totalFreeBytes); err != nil {
+		return 0, err
+	}
+	return freeBytesAvailable, nil
+}
@@ -0,0 +1,336 @@
+// Package doctor diagnoses the environment HLSpresso runs in: the FFmpeg
+// binaries and codecs, free disk space and write permissions of the working
+// directories, and network reachability. Each check reports an actionable fix
+// when it does not pass.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/diskspace"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	// StatusOK means the check passed.
+	StatusOK Status = "ok"
+	// StatusWarn means HLSpresso works, but some features or inputs may fail.
+	StatusWarn Status = "warn"
+	// StatusFail means transcoding will fail until the problem is fixed.
+	StatusFail Status = "fail"
+	// StatusSkipped means the check could not run (e.g. ffmpeg is missing).
+	StatusSkipped Status = "skipped"
+)
+
+// DefaultTimeout bounds each command and network check.
+const DefaultTimeout = 10 * time.Second
+
+// Check is the result of one diagnostic check.
+type Check struct {
+	// Name identifies the check (e.g. "ffmpeg", "codec libx264", "disk space /data").
+	Name string `json:"name"`
+	// Status is the outcome of the check.
+	Status Status `json:"status"`
+	// Message describes what was found.
+	Message string `json:"message"`
+	// Fix suggests how to solve a warning or failure.
+	Fix string `json:"fix,omitempty"`
+}
+
+// Report holds the results of every check.
+type Report struct {
+	Checks []Check `json:"checks"`
+	// OK is false if any check failed.
+	OK bool `json:"ok"`
+}
+
+// Options configures the checks run by Run.
+type Options struct {
+	// FFmpegBinary is the ffmpeg executable to check. Defaults to "ffmpeg".
+	FFmpegBinary string
+	// FFprobeBinary is the ffprobe executable to check. Defaults to "ffprobe".
+	FFprobeBinary string
+	// Dirs are the directories HLSpresso will write to (output, downloads).
+	// Directories that do not exist yet are checked through their nearest existing parent.
+	Dirs []string
+	// MinFreeSpace is the free space in bytes required in each directory.
+	// Defaults to diskspace.DefaultMinFreeSpaceHLS.
+	MinFreeSpace uint64
+	// TestURL is fetched to check network reachability. Empty skips the check.
+	TestURL string
+	// Timeout bounds each command and network check. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Runner runs ffmpeg and ffprobe. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+	// DiskChecker reports free disk space. Defaults to diskspace.NewChecker().
+	DiskChecker diskspace.Checker
+	// HTTPClient fetches TestURL. Defaults to a new http.Client.
+	HTTPClient *http.Client
+}
+
+// codec is an ffmpeg codec looked up in the output of "ffmpeg -codecs".
+type codec struct {
+	name     string
+	required bool
+	purpose  string
+}
+
+// codecs lists the codecs HLSpresso uses; optional ones enable extra features.
+var codecs = []codec{
+	{"libx264", true, "H.264 video encoding"},
+	{"aac", true, "AAC audio encoding"},
+	{"libx265", false, "HEVC video encoding through --ffmpeg-param"},
+	{"libvpx-vp9", false, "VP9 video encoding through --ffmpeg-param"},
+}
+
+// installFix is the fix suggested when an FFmpeg binary cannot be run.
+const installFix = "Install FFmpeg (e.g. 'apt install ffmpeg' or 'brew install ffmpeg'), " +
+	"pass its path with --ffmpeg, or use --auto-install-ffmpeg"
+
+// Run executes every check and returns the report.
+func Run(ctx context.Context, opts Options) *Report {
+	opts = withDefaults(opts)
+	r := &Report{}
+
+	ffmpegOK := r.checkBinary(ctx, opts, "ffmpeg", opts.FFmpegBinary, StatusFail)
+	r.checkBinary(ctx, opts, "ffprobe", opts.FFprobeBinary, StatusWarn)
+	r.checkCodecs(ctx, opts, ffmpegOK)
+	for _, dir := range opts.Dirs {
+		r.checkDir(opts, dir)
+	}
+	r.checkNetwork(ctx, opts)
+
+	r.OK = true
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			r.OK = false
+		}
+	}
+	return r
+}
+
+func withDefaults(opts Options) Options {
+	if opts.FFmpegBinary == "" {
+		opts.FFmpegBinary = "ffmpeg"
+	}
+	if opts.FFprobeBinary == "" {
+		opts.FFprobeBinary = "ffprobe"
+	}
+	if opts.MinFreeSpace == 0 {
+		opts.MinFreeSpace = diskspace.DefaultMinFreeSpaceHLS
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Runner == nil {
+		opts.Runner = ffmpeg.ExecRunner{}
+	}
+	if opts.DiskChecker == nil {
+		opts.DiskChecker = diskspace.NewChecker()
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{}
+	}
+	return opts
+}
+
+func (r *Report) add(c Check) {
+	r.Checks = append(r.Checks, c)
+}
+
+// checkBinary runs "binary -version" and reports its version. A missing binary
+// is reported with missing status. It returns whether the binary runs.
+func (r *Report) checkBinary(ctx context.Context, opts Options, name, binary string, missing Status) bool {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	output, err := opts.Runner.Output(ctx, binary, "-version")
+	if err != nil {
+		fix := installFix
+		if name == "ffprobe" {
+			fix = "Install ffprobe (shipped with FFmpeg); automatic resolutions and progress estimates need it"
+		}
+		r.add(Check{Name: name, Status: missing, Message: fmt.Sprintf("%s cannot be run: %v", binary, err), Fix: fix})
+		return false
+	}
+
+	version := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	if version == "" {
+		version = binary + " (unknown version)"
+	}
+	r.add(Check{Name: name, Status: StatusOK, Message: version})
+	return true
+}
+
+// checkCodecs looks up the codecs HLSpresso uses in "ffmpeg -codecs".
+func (r *Report) checkCodecs(ctx context.Context, opts Options, ffmpegOK bool) {
+	if !ffmpegOK {
+		r.add(Check{Name: "codecs", Status: StatusSkipped, Message: "ffmpeg is not available"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	output, err := opts.Runner.Output(ctx, opts.FFmpegBinary, "-hide_banner", "-codecs")
+	if err != nil {
+		r.add(Check{Name: "codecs", Status: StatusFail, Message: fmt.Sprintf("ffmpeg -codecs failed: %v", err), Fix: installFix})
+		return
+	}
+
+	for _, c := range codecs {
+		name := "codec " + c.name
+		if strings.Contains(string(output), c.name) {
+			r.add(Check{Name: name, Status: StatusOK, Message: c.purpose + " available"})
+			continue
+		}
+		if c.required {
+			r.add(Check{Name: name, Status: StatusFail, Message: c.purpose + " is not available",
+				Fix: "Use an FFmpeg build with " + c.name + " (e.g. a static build via --auto-install-ffmpeg)"})
+		} else {
+			r.add(Check{Name: name, Status: StatusWarn, Message: c.purpose + " is not available",
+				Fix: "Only needed for " + c.purpose + "; use an FFmpeg build with " + c.name + " if you rely on it"})
+		}
+	}
+}
+
+// checkDir checks that dir (or its nearest existing parent) is writable and has
+// enough free space.
+func (r *Report) checkDir(opts Options, dir string) {
+	existing, err := nearestExisting(dir)
+	if err != nil {
+		r.add(Check{Name: "directory " + dir, Status: StatusFail, Message: err.Error(),
+			Fix: "Create the directory or choose another location"})
+		return
+	}
+
+	probe, err := os.CreateTemp(existing, ".hlspresso-doctor-*")
+	if err != nil {
+		r.add(Check{Name: "write " + dir, Status: StatusFail, Message: fmt.Sprintf("%s is not writable: %v", existing, err),
+			Fix: "Fix the permissions of " + existing + " or choose another directory"})
+	} else {
+		probe.Close()
+		os.Remove(probe.Name())
+		r.add(Check{Name: "write " + dir, Status: StatusOK, Message: existing + " is writable"})
+	}
+
+	free, err := opts.DiskChecker.FreeSpace(existing)
+	switch {
+	case stderrors.Is(err, diskspace.ErrUnsupported):
+		r.add(Check{Name: "disk space " + dir, Status: StatusSkipped, Message: err.Error()})
+	case err != nil:
+		r.add(Check{Name: "disk space " + dir, Status: StatusWarn, Message: fmt.Sprintf("free space unknown: %v", err)})
+	case free < opts.MinFreeSpace:
+		r.add(Check{Name: "disk space " + dir, Status: StatusWarn,
+			Message: fmt.Sprintf("%s free, below the %s HLSpresso requires by default", formatBytes(free), formatBytes(opts.MinFreeSpace)),
+			Fix:     "Free some space, write elsewhere, or lower the threshold with --min-free-space"})
+	default:
+		r.add(Check{Name: "disk space " + dir, Status: StatusOK, Message: formatBytes(free) + " free"})
+	}
+}
+
+// nearestExisting returns dir, or its closest existing parent directory.
+func nearestExisting(dir string) (string, error) {
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("%s is not a directory", path)
+			}
+			return path, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
+
+// checkNetwork fetches the test URL.
+func (r *Report) checkNetwork(ctx context.Context, opts Options) {
+	if opts.TestURL == "" {
+		r.add(Check{Name: "network", Status: StatusSkipped, Message: "no test URL"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.TestURL, nil)
+	if err != nil {
+		r.add(Check{Name: "network", Status: StatusFail, Message: fmt.Sprintf("invalid test URL: %v", err)})
+		return
+	}
+
+	start := time.Now()
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		r.add(Check{Name: "network", Status: StatusWarn, Message: fmt.Sprintf("%s is not reachable: %v", opts.TestURL, err),
+			Fix: "Check the network, DNS and proxy settings (HTTPS_PROXY); only remote inputs and uploads need the network"})
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if resp.StatusCode >= 400 {
+		r.add(Check{Name: "network", Status: StatusWarn, Message: fmt.Sprintf("%s answered %s in %s", opts.TestURL, resp.Status, elapsed),
+			Fix: "The server is reachable but rejected the request; check the URL or credentials"})
+		return
+	}
+	r.add(Check{Name: "network", Status: StatusOK, Message: fmt.Sprintf("%s answered %s in %s", opts.TestURL, resp.Status, elapsed)})
+}
+
+// JSON returns the report as indented JSON.
+func (r *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// WriteText writes the report as one line per check, followed by its fix.
+func (r *Report) WriteText(w io.Writer) {
+	labels := map[Status]string{StatusOK: " OK ", StatusWarn: "WARN", StatusFail: "FAIL", StatusSkipped: "SKIP"}
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", labels[c.Status], c.Name, c.Message)
+		if c.Fix != "" {
+			fmt.Fprintf(w, "       -> %s\n", c.Fix)
+		}
+	}
+	if r.OK {
+		fmt.Fprintln(w, "\nNo blocking problems found.")
+	} else {
+		fmt.Fprintln(w, "\nSome checks failed; transcoding will not work until they are fixed.")
+	}
+}
+
+// formatBytes formats n bytes with a binary unit (e.g. "1.5 GiB").
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
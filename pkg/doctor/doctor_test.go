@@ -0,0 +1,134 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+// fakeDisk reports a fixed amount of free space.
+type fakeDisk struct {
+	free uint64
+	err  error
+}
+
+func (d fakeDisk) FreeSpace(string) (uint64, error) { return d.free, d.err }
+
+// fakeFFmpeg answers -version for ffmpeg and ffprobe and -codecs with codecs.
+func fakeFFmpeg(codecs string, missing ...string) *ffmpegtest.Runner {
+	return &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			for _, m := range missing {
+				if name == m {
+					return ffmpegtest.Result{Err: stderrors.New("executable file not found in $PATH")}
+				}
+			}
+			if args[len(args)-1] == "-codecs" {
+				return ffmpegtest.Result{Stdout: codecs}
+			}
+			return ffmpegtest.Result{Stdout: name + " version 7.1 Copyright (c) the FFmpeg developers\nbuilt with gcc"}
+		},
+	}
+}
+
+func statuses(r *Report) map[string]Status {
+	m := map[string]Status{}
+	for _, c := range r.Checks {
+		m[c.Name] = c.Status
+	}
+	return m
+}
+
+func TestRunHealthy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	report := Run(context.Background(), Options{
+		Runner:      fakeFFmpeg("libx264 aac libx265 libvpx-vp9"),
+		Dirs:        []string{dir, filepath.Join(dir, "not", "created", "yet")},
+		DiskChecker: fakeDisk{free: 50 << 30},
+		TestURL:     srv.URL,
+	})
+
+	if !report.OK {
+		t.Errorf("Report should be OK: %+v", report.Checks)
+	}
+	for name, status := range statuses(report) {
+		if status != StatusOK {
+			t.Errorf("check %q = %s, want ok", name, status)
+		}
+	}
+	if report.Checks[0].Message != "ffmpeg version 7.1 Copyright (c) the FFmpeg developers" {
+		t.Errorf("ffmpeg message = %q, want the version line", report.Checks[0].Message)
+	}
+}
+
+func TestRunProblems(t *testing.T) {
+	dir := t.TempDir()
+
+	report := Run(context.Background(), Options{
+		Runner:      fakeFFmpeg("aac", "ffprobe"),
+		Dirs:        []string{dir},
+		DiskChecker: fakeDisk{free: 100 << 20},
+		TestURL:     "http://127.0.0.1:1/unreachable",
+	})
+
+	want := map[string]Status{
+		"ffmpeg":            StatusOK,
+		"ffprobe":           StatusWarn,
+		"codec libx264":     StatusFail,
+		"codec aac":         StatusOK,
+		"codec libx265":     StatusWarn,
+		"write " + dir:      StatusOK,
+		"disk space " + dir: StatusWarn,
+		"network":           StatusWarn,
+	}
+	got := statuses(report)
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("check %q = %q, want %q", name, got[name], status)
+		}
+	}
+	if report.OK {
+		t.Error("Report should not be OK when a required codec is missing")
+	}
+	for _, c := range report.Checks {
+		if (c.Status == StatusFail || c.Status == StatusWarn) && c.Fix == "" {
+			t.Errorf("check %q (%s) has no fix", c.Name, c.Status)
+		}
+	}
+}
+
+func TestRunWithoutFFmpeg(t *testing.T) {
+	report := Run(context.Background(), Options{Runner: fakeFFmpeg("", "ffmpeg", "ffprobe")})
+	got := statuses(report)
+	if got["ffmpeg"] != StatusFail || got["codecs"] != StatusSkipped || got["network"] != StatusSkipped {
+		t.Errorf("Unexpected statuses: %v", got)
+	}
+	if report.OK {
+		t.Error("Report should not be OK without ffmpeg")
+	}
+
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	if !strings.Contains(buf.String(), "[FAIL] ffmpeg: ") || !strings.Contains(buf.String(), "-> Install FFmpeg") {
+		t.Errorf("WriteText() output missing the failure and its fix:\n%s", buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{512: "512 B", 1536: "1.5 KiB", 1 << 30: "1.0 GiB"}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
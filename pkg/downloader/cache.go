@@ -0,0 +1,309 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+)
+
+// CacheOptions contains settings for a download Cache.
+type CacheOptions struct {
+	// Dir is the directory the downloaded files are kept in.
+	Dir string
+	// MaxSize is the total size in bytes of the files kept in Dir. Once it is
+	// exceeded, the least recently used files are evicted, except those in use.
+	// Zero disables the limit.
+	MaxSize int64
+	// Logger receives the log messages of the cache.
+	// Defaults to logger.NewLogger().
+	Logger logger.Logger
+}
+
+// Cache shares the downloads of remote files between jobs. Files are kept in
+// a local directory keyed by their URL and ETag (or Last-Modified date), so
+// that a file is downloaded once while it does not change on the server, and
+// jobs requesting a file being downloaded wait for that download instead of
+// starting their own. Files are reference counted: a file is never evicted
+// while a job of this process uses it. A Cache is safe for concurrent use;
+// create it with OpenCache.
+type Cache struct {
+	options CacheOptions
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a file being downloaded or in use, and its users.
+type cacheEntry struct {
+	done   chan struct{}
+	path   string
+	err    error
+	refs   int
+	cancel context.CancelFunc
+}
+
+var (
+	cachesMu sync.Mutex
+	caches   = map[string]*Cache{}
+)
+
+// OpenCache returns the Cache of options.Dir, creating it on first use. Every
+// call with the same directory returns the same Cache, so that the jobs of a
+// process share their downloads; the options of the first call are kept.
+func OpenCache(options CacheOptions) *Cache {
+	if options.Logger == nil {
+		options.Logger = logger.NewLogger()
+	}
+	dir, err := filepath.Abs(options.Dir)
+	if err != nil {
+		dir = filepath.Clean(options.Dir)
+	}
+	options.Dir = dir
+
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	if c, ok := caches[dir]; ok {
+		return c
+	}
+	c := &Cache{options: options, entries: make(map[string]*cacheEntry)}
+	caches[dir] = c
+	return c
+}
+
+// Acquire returns the path of a local copy of the file at options.URL,
+// downloading it with options unless the cache holds the current version
+// (options.OutputPath and AllowOverride are not used). release must be called
+// once the file is no longer used. A caller whose ctx is done returns
+// ctx.Err(); the download only stops once every caller waiting for it gave
+// up. Files whose version cannot be identified, because the server rejects
+// HEAD requests or sends neither an ETag nor a Last-Modified date, are
+// downloaded to options.OutputPath without caching.
+func (c *Cache) Acquire(ctx context.Context, options Options) (string, func(), error) {
+	key, ok := c.key(ctx, options)
+	if !ok {
+		c.options.Logger.Debug("Remote file without ETag, downloading it without caching", "downloader", map[string]interface{}{
+			"url": options.URL,
+		})
+		downloaded, err := New(options).Download(ctx)
+		return downloaded, func() {}, err
+	}
+	target := filepath.Join(c.options.Dir, key+cacheExt(options))
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	switch {
+	case ok:
+		entry.refs++
+		c.options.Logger.Info("Waiting for the download of the same file", "downloader", map[string]interface{}{
+			"url": options.URL,
+		})
+	case fileExists(target):
+		entry = &cacheEntry{done: make(chan struct{}), path: target, refs: 1}
+		close(entry.done)
+		c.entries[key] = entry
+		c.options.Logger.Info("Using the cached download", "downloader", map[string]interface{}{
+			"url":  options.URL,
+			"path": target,
+		})
+	default:
+		downloadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		entry = &cacheEntry{done: make(chan struct{}), path: target, refs: 1, cancel: cancel}
+		c.entries[key] = entry
+		go c.download(downloadCtx, key, entry, options)
+	}
+	c.mu.Unlock()
+
+	release := func() { c.release(key, entry) }
+	select {
+	case <-entry.done:
+		if entry.err != nil {
+			release()
+			return "", nil, entry.err
+		}
+		// Marcar o arquivo como usado recentemente para a evicção
+		now := time.Now()
+		os.Chtimes(entry.path, now, now)
+		return entry.path, release, nil
+	case <-ctx.Done():
+		release()
+		return "", nil, ctx.Err()
+	}
+}
+
+// download downloads the file of entry to a temporary file, moved to the
+// entry path once complete, and evicts old files if the cache is too large.
+func (c *Cache) download(ctx context.Context, key string, entry *cacheEntry, options Options) {
+	defer entry.cancel()
+	tmp := filepath.Join(c.options.Dir, fmt.Sprintf(".tmp-%s-%d%s", key[:8], time.Now().UnixNano(), cacheExt(options)))
+	options.OutputPath = tmp
+	options.AllowOverride = true
+	options.Logger = c.options.Logger
+
+	_, err := New(options).Download(ctx)
+	if err == nil {
+		err = os.Rename(tmp, entry.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+
+	c.mu.Lock()
+	entry.err = err
+	if (err != nil || entry.refs == 0) && c.entries[key] == entry {
+		// Uma falha não fica no cache: a próxima chamada baixa o arquivo de novo.
+		// Sem referências, o arquivo baixado deixa de estar em uso. A entrada
+		// já pode ter sido substituída por um novo download
+		delete(c.entries, key)
+	}
+	close(entry.done)
+	c.mu.Unlock()
+
+	if err == nil {
+		c.evict()
+	}
+}
+
+// release drops a reference to entry. The download of an entry nobody waits
+// for anymore is stopped and forgotten, so that the next caller starts a new
+// one, and the files no longer used may be evicted.
+func (c *Cache) release(key string, entry *cacheEntry) {
+	c.mu.Lock()
+	entry.refs--
+	if entry.refs > 0 {
+		c.mu.Unlock()
+		return
+	}
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	select {
+	case <-entry.done:
+	default:
+		entry.cancel()
+	}
+	c.mu.Unlock()
+	c.evict()
+}
+
+// evict removes the least recently used files not in use until the files of
+// the cache fit in MaxSize.
+func (c *Cache) evict() {
+	if c.options.MaxSize <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(c.options.Dir)
+	if err != nil {
+		return
+	}
+	inUse := make(map[string]bool, len(c.entries))
+	for _, entry := range c.entries {
+		inUse[entry.path] = true
+	}
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cachedFile
+	var total int64
+	for _, d := range dirEntries {
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() || strings.HasPrefix(d.Name(), ".tmp-") {
+			continue
+		}
+		files = append(files, cachedFile{filepath.Join(c.options.Dir, d.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	for _, f := range files {
+		if total <= c.options.MaxSize {
+			return
+		}
+		if inUse[f.path] {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			continue
+		}
+		total -= f.size
+		c.options.Logger.Info("Evicted a cached download", "downloader", map[string]interface{}{
+			"path":  f.path,
+			"bytes": f.size,
+		})
+	}
+}
+
+// key returns the key of the current version of the file at options.URL: the
+// SHA-256 of its URL and version. It returns false if the version cannot be
+// identified.
+func (c *Cache) key(ctx context.Context, options Options) (string, bool) {
+	version := c.version(ctx, options)
+	if version == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(options.URL + "\n" + version))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// version returns the version of the file at options.URL: its ETag (or
+// Last-Modified date), read with a HEAD request, or for share links the
+// version returned by the API of the storage service. It returns "" if the
+// version cannot be identified.
+func (c *Cache) version(ctx context.Context, options Options) string {
+	d := New(options)
+	if info, ok, err := d.Stat(ctx); ok {
+		if err != nil {
+			return ""
+		}
+		return info.Version
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, options.URL, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// cacheExt returns the extension of the file name of options.URL, or else of
+// options.OutputPath (e.g. for share links without a file name), kept on
+// cached files for the tools that rely on it.
+func cacheExt(options Options) string {
+	u, err := url.Parse(options.URL)
+	if err == nil && path.Ext(u.Path) != "" {
+		return strings.ToLower(path.Ext(u.Path))
+	}
+	return strings.ToLower(filepath.Ext(options.OutputPath))
+}
+
+// fileExists reports whether a regular file exists at path.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
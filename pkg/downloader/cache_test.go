@@ -0,0 +1,197 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheSharesDownloads(t *testing.T) {
+	var gets atomic.Int32
+	etag := `"v1"`
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/no-etag.mp4" {
+			w.Header().Set("ETag", etag)
+		}
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			<-release
+		}
+		fmt.Fprint(w, "mezzanine")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := OpenCache(CacheOptions{Dir: dir})
+	if OpenCache(CacheOptions{Dir: dir + "/."}) != c {
+		t.Error("OpenCache() should return the same cache for the same directory")
+	}
+	options := Options{URL: server.URL + "/video.mp4"}
+
+	// Jobs concorrentes compartilham um único download, mesmo se o primeiro desistir
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, _, err := c.Acquire(ctx, options)
+		first <- err
+	}()
+	for gets.Load() == 0 {
+		time.Sleep(time.Millisecond) // Aguardar o início do download
+	}
+	var wg sync.WaitGroup
+	paths := make([]string, 3)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path, done, err := c.Acquire(context.Background(), options)
+			if err != nil {
+				t.Errorf("Acquire() failed: %v", err)
+				return
+			}
+			defer done()
+			paths[i] = path
+		}(i)
+	}
+	for c.refs(options) != 4 {
+		time.Sleep(time.Millisecond) // Aguardar os outros jobs
+	}
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("Acquire() with a cancelled context = %v, want context.Canceled", err)
+	}
+	close(release)
+	wg.Wait()
+
+	if gets.Load() != 1 {
+		t.Errorf("The file was downloaded %d times, want 1", gets.Load())
+	}
+	for _, path := range paths {
+		if content, err := os.ReadFile(path); err != nil || string(content) != "mezzanine" || filepath.Dir(path) != dir {
+			t.Errorf("Acquire() = %q (%q, %v), want the cached file", path, content, err)
+		}
+	}
+
+	// O arquivo continua no cache enquanto o ETag não muda
+	if _, done, err := c.Acquire(context.Background(), options); err != nil || gets.Load() != 1 {
+		t.Errorf("Acquire() of a cached file = %v, %d downloads", err, gets.Load())
+	} else {
+		done()
+	}
+	etag = `"v2"`
+	if path, done, err := c.Acquire(context.Background(), options); err != nil || gets.Load() != 2 || path == paths[0] {
+		t.Errorf("Acquire() of a changed file = %q, %v, %d downloads", path, err, gets.Load())
+	} else {
+		done()
+	}
+
+	// Arquivos sem ETag são baixados sem cache
+	outputPath := filepath.Join(t.TempDir(), "video.mp4")
+	path, done, err := c.Acquire(context.Background(), Options{URL: server.URL + "/no-etag.mp4", OutputPath: outputPath})
+	if err != nil || path != outputPath {
+		t.Errorf("Acquire() without ETag = %q, %v, want %q", path, err, outputPath)
+	}
+	done()
+}
+
+func TestCacheAcquireAfterCancel(t *testing.T) {
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodGet && gets.Add(1) == 1 {
+			<-r.Context().Done() // O primeiro download só termina ao ser cancelado
+			return
+		}
+		fmt.Fprint(w, "mezzanine")
+	}))
+	defer server.Close()
+
+	c := OpenCache(CacheOptions{Dir: t.TempDir()})
+	options := Options{URL: server.URL + "/video.mp4"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, _, err := c.Acquire(ctx, options)
+		first <- err
+	}()
+	for gets.Load() == 0 {
+		time.Sleep(time.Millisecond) // Aguardar o início do download
+	}
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("Acquire() with a cancelled context = %v, want context.Canceled", err)
+	}
+	key, _ := c.key(context.Background(), options)
+	c.mu.Lock()
+	_, cached := c.entries[key]
+	c.mu.Unlock()
+	if cached {
+		t.Error("The cancelled download is still in the cache after the last release")
+	}
+
+	// O download cancelado não é reaproveitado: um novo começa
+	path, done, err := c.Acquire(context.Background(), options)
+	if err != nil {
+		t.Fatalf("Acquire() right after the last release failed: %v", err)
+	}
+	defer done()
+	if content, err := os.ReadFile(path); err != nil || string(content) != "mezzanine" || gets.Load() != 2 {
+		t.Errorf("Acquire() = %q (%q, %v), %d downloads, want a new download", path, content, err, gets.Load())
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		fmt.Fprint(w, "ten bytes!")
+	}))
+	defer server.Close()
+
+	c := OpenCache(CacheOptions{Dir: t.TempDir(), MaxSize: 25})
+	acquire := func(name string) (string, func()) {
+		path, done, err := c.Acquire(context.Background(), Options{URL: server.URL + "/" + name})
+		if err != nil {
+			t.Fatalf("Acquire(%s) failed: %v", name, err)
+		}
+		return path, done
+	}
+
+	a, doneA := acquire("a.mp4")
+	b, doneB := acquire("b.mp4")
+	doneB()
+	// a.mp4 está em uso: b.mp4, menos recente entre os livres, é removido
+	c3, doneC := acquire("c.mp4")
+	for _, f := range []struct {
+		path string
+		kept bool
+	}{{a, true}, {b, false}, {c3, true}} {
+		if _, err := os.Stat(f.path); (err == nil) != f.kept {
+			t.Errorf("%s kept = %v, want %v", filepath.Base(f.path), err == nil, f.kept)
+		}
+	}
+	doneA()
+	doneC()
+}
+
+// refs returns the number of callers of the entry of options.URL.
+func (c *Cache) refs(options Options) int {
+	key, ok := c.key(context.Background(), options)
+	if !ok {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry := c.entries[key]; entry != nil {
+		return entry.refs
+	}
+	return 0
+}
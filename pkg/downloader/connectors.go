@@ -0,0 +1,245 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// Base URLs of the storage service APIs, replaced in tests.
+var (
+	googleDriveAPI    = "https://www.googleapis.com"
+	dropboxAPI        = "https://api.dropboxapi.com"
+	dropboxContentAPI = "https://content.dropboxapi.com"
+)
+
+// FileInfo describes the file of a share link, as returned by the API of its
+// storage service.
+type FileInfo struct {
+	// Name is the name of the file, e.g. "mezzanine.mov".
+	Name string
+	// Size is the size of the file in bytes, or -1 if unknown.
+	Size int64
+	// Version identifies the content of the file, such as its checksum or
+	// revision, and changes when the file does.
+	Version string
+}
+
+// connector downloads the files of the share links of a storage service
+// through its API.
+type connector interface {
+	// stat returns the metadata of the file of link.
+	stat(ctx context.Context, client *http.Client, link *url.URL) (FileInfo, error)
+	// request returns the request downloading the file of link.
+	request(ctx context.Context, link *url.URL) (*http.Request, error)
+}
+
+// UsesConnector reports whether options.URL is a share link downloaded
+// through the API of a storage service: a Google Drive link with
+// options.GoogleDriveToken set, or a Dropbox link with options.DropboxToken
+// set.
+func UsesConnector(options Options) bool {
+	c, _ := connectorFor(options)
+	return c != nil
+}
+
+// connectorFor returns the connector of options.URL and the parsed URL, or a
+// nil connector if it is not a share link of a service whose token is set.
+func connectorFor(options Options) (connector, *url.URL) {
+	link, err := url.Parse(options.URL)
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+		return nil, nil
+	}
+	switch {
+	case options.GoogleDriveToken != "" && googleDriveFileID(link) != "":
+		return googleDrive{token: options.GoogleDriveToken}, link
+	case options.DropboxToken != "" && isDropboxLink(link):
+		return dropbox{token: options.DropboxToken}, link
+	}
+	return nil, nil
+}
+
+// Stat returns the metadata of the file of a share link downloaded through a
+// storage connector (see UsesConnector). ok is false for other URLs, whose
+// metadata is only known once downloaded.
+func (d *Downloader) Stat(ctx context.Context) (info FileInfo, ok bool, err error) {
+	c, link := connectorFor(d.options)
+	if c == nil {
+		return FileInfo{}, false, nil
+	}
+	info, err = c.stat(ctx, d.client, link)
+	return info, true, err
+}
+
+// newRequest returns the request downloading options.URL, through the API of
+// its storage service for share links.
+func (d *Downloader) newRequest(ctx context.Context) (*http.Request, error) {
+	if c, link := connectorFor(d.options); c != nil {
+		return c.request(ctx, link)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.options.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+	}
+	return req, nil
+}
+
+// googleDrive is the connector of Google Drive share links, using the Drive
+// API v3 with an OAuth access token.
+type googleDrive struct {
+	token string
+}
+
+// googleDriveFileID returns the file ID of a Google Drive share link
+// (https://drive.google.com/file/d/<id>/view, .../open?id=<id> or
+// .../uc?id=<id>), or "" for other URLs.
+func googleDriveFileID(link *url.URL) string {
+	if host := strings.ToLower(link.Hostname()); host != "drive.google.com" && host != "docs.google.com" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(link.Path, "/file/d/"); ok {
+		id, _, _ := strings.Cut(rest, "/")
+		return id
+	}
+	if link.Path == "/open" || link.Path == "/uc" {
+		return link.Query().Get("id")
+	}
+	return ""
+}
+
+// newRequest returns an authenticated request for the file of link, with the
+// resource key of files shared by link.
+func (g googleDrive) newRequest(ctx context.Context, link *url.URL, query url.Values) (*http.Request, error) {
+	query.Set("supportsAllDrives", "true")
+	id := googleDriveFileID(link)
+	endpoint := googleDriveAPI + "/drive/v3/files/" + url.PathEscape(id) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	if resourceKey := link.Query().Get("resourcekey"); resourceKey != "" {
+		req.Header.Set("X-Goog-Drive-Resource-Keys", id+"/"+resourceKey)
+	}
+	return req, nil
+}
+
+func (g googleDrive) stat(ctx context.Context, client *http.Client, link *url.URL) (FileInfo, error) {
+	req, err := g.newRequest(ctx, link, url.Values{"fields": {"name,size,md5Checksum,modifiedTime,mimeType"}})
+	if err != nil {
+		return FileInfo{}, err
+	}
+	var file struct {
+		Name         string `json:"name"`
+		Size         string `json:"size"`
+		MD5Checksum  string `json:"md5Checksum"`
+		ModifiedTime string `json:"modifiedTime"`
+		MimeType     string `json:"mimeType"`
+	}
+	if err := callAPI(client, req, &file); err != nil {
+		return FileInfo{}, err
+	}
+	if file.MimeType == "application/vnd.google-apps.folder" {
+		return FileInfo{}, notAFile(link)
+	}
+	info := FileInfo{Name: file.Name, Size: -1, Version: file.MD5Checksum}
+	if size, err := strconv.ParseInt(file.Size, 10, 64); err == nil {
+		info.Size = size
+	}
+	if info.Version == "" {
+		info.Version = file.ModifiedTime
+	}
+	return info, nil
+}
+
+func (g googleDrive) request(ctx context.Context, link *url.URL) (*http.Request, error) {
+	return g.newRequest(ctx, link, url.Values{"alt": {"media"}})
+}
+
+// dropbox is the connector of Dropbox share links, using the Dropbox API v2
+// with an access token.
+type dropbox struct {
+	token string
+}
+
+// isDropboxLink reports whether link is a Dropbox file share link
+// (https://www.dropbox.com/s/... or https://www.dropbox.com/scl/fi/...).
+func isDropboxLink(link *url.URL) bool {
+	if host := strings.ToLower(link.Hostname()); host != "www.dropbox.com" && host != "dropbox.com" {
+		return false
+	}
+	return strings.HasPrefix(link.Path, "/s/") || strings.HasPrefix(link.Path, "/scl/fi/")
+}
+
+func (d dropbox) stat(ctx context.Context, client *http.Client, link *url.URL) (FileInfo, error) {
+	body, err := json.Marshal(map[string]string{"url": link.String()})
+	if err != nil {
+		return FileInfo{}, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxAPI+"/2/sharing/get_shared_link_metadata", bytes.NewReader(body))
+	if err != nil {
+		return FileInfo{}, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	req.Header.Set("Content-Type", "application/json")
+	var file struct {
+		Tag  string `json:".tag"`
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+		Rev  string `json:"rev"`
+	}
+	if err := callAPI(client, req, &file); err != nil {
+		return FileInfo{}, err
+	}
+	if file.Tag != "file" {
+		return FileInfo{}, notAFile(link)
+	}
+	return FileInfo{Name: file.Name, Size: file.Size, Version: file.Rev}, nil
+}
+
+func (d dropbox) request(ctx context.Context, link *url.URL) (*http.Request, error) {
+	arg, err := json.Marshal(map[string]string{"url": link.String()})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxContentAPI+"/2/sharing/get_shared_link_file", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	req.Header.Set("Dropbox-API-Arg", string(arg))
+	return req, nil
+}
+
+// callAPI sends req and decodes its JSON response into v. Error responses
+// are reported with the start of their body, where the APIs explain them.
+func callAPI(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, errors.DownloadError, "Failed to download file", 3)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(errors.DownloadError, "HTTP request failed",
+			fmt.Sprintf("Status: %s: %s", resp.Status, strings.TrimSpace(string(body))), 4)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, errors.DownloadError, "Invalid storage API response", 9)
+	}
+	return nil
+}
+
+// notAFile returns the error of a share link of a folder.
+func notAFile(link *url.URL) *errors.StructuredError {
+	return errors.New(errors.ValidationError, "Invalid share link",
+		fmt.Sprintf("%s%s is not a file", link.Host, link.Path), 4)
+}
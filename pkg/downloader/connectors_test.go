@@ -0,0 +1,163 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// fakeStorageAPIs serves the Google Drive and Dropbox API endpoints used by
+// the connectors, pointing the connectors to it until the test ends.
+func fakeStorageAPIs(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error_summary": "invalid_access_token/"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/drive/v3/files/1AbC":
+			if r.Header.Get("X-Goog-Drive-Resource-Keys") != "1AbC/0-key" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.URL.Query().Get("alt") == "media" {
+				fmt.Fprint(w, "drive video")
+				return
+			}
+			fmt.Fprint(w, `{"name": "mezzanine.mov", "size": "11", "md5Checksum": "d41d8cd9", "mimeType": "video/quicktime"}`)
+		case "/drive/v3/files/folder":
+			fmt.Fprint(w, `{"name": "Sources", "mimeType": "application/vnd.google-apps.folder"}`)
+		case "/2/sharing/get_shared_link_metadata":
+			var arg struct{ URL string }
+			json.NewDecoder(r.Body).Decode(&arg)
+			if arg.URL != "https://www.dropbox.com/scl/fi/xyz/promo.mp4?rlkey=abc&dl=0" {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"error_summary": "shared_link_not_found/"}`)
+				return
+			}
+			fmt.Fprint(w, `{".tag": "file", "name": "promo.mp4", "size": 13, "rev": "015f"}`)
+		case "/2/sharing/get_shared_link_file":
+			var arg struct{ URL string }
+			json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+			if r.Method != http.MethodPost || arg.URL != "https://www.dropbox.com/scl/fi/xyz/promo.mp4?rlkey=abc&dl=0" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "dropbox video")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	apis := []*string{&googleDriveAPI, &dropboxAPI, &dropboxContentAPI}
+	saved := []string{googleDriveAPI, dropboxAPI, dropboxContentAPI}
+	for _, api := range apis {
+		*api = server.URL
+	}
+	t.Cleanup(func() {
+		for i, api := range apis {
+			*api = saved[i]
+		}
+	})
+}
+
+func TestConnectors(t *testing.T) {
+	fakeStorageAPIs(t)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantInfo FileInfo
+		content  string
+		wantCode int
+	}{
+		{
+			name:     "Google Drive",
+			url:      "https://drive.google.com/file/d/1AbC/view?usp=sharing&resourcekey=0-key",
+			wantInfo: FileInfo{Name: "mezzanine.mov", Size: 11, Version: "d41d8cd9"},
+			content:  "drive video",
+		},
+		{
+			name:     "Dropbox",
+			url:      "https://www.dropbox.com/scl/fi/xyz/promo.mp4?rlkey=abc&dl=0",
+			wantInfo: FileInfo{Name: "promo.mp4", Size: 13, Version: "015f"},
+			content:  "dropbox video",
+		},
+		{name: "Google Drive folder", url: "https://drive.google.com/open?id=folder", wantCode: 4},
+		{name: "Dropbox link not found", url: "https://www.dropbox.com/s/missing/clip.mp4", wantCode: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "input")
+			d := New(Options{
+				URL:              tt.url,
+				OutputPath:       outputPath,
+				GoogleDriveToken: "secret-token",
+				DropboxToken:     "secret-token",
+			})
+
+			info, ok, err := d.Stat(context.Background())
+			if !ok {
+				t.Fatal("Stat() ok = false, want a share link")
+			}
+			if tt.wantCode != 0 {
+				sErr, isStructured := err.(*errors.StructuredError)
+				if !isStructured || sErr.Code != tt.wantCode {
+					t.Fatalf("Stat() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil || info != tt.wantInfo {
+				t.Fatalf("Stat() = %+v, %v, want %+v", info, err, tt.wantInfo)
+			}
+
+			if _, err := d.Download(context.Background()); err != nil {
+				t.Fatalf("Download() failed: %v", err)
+			}
+			if content, err := os.ReadFile(outputPath); err != nil || string(content) != tt.content {
+				t.Errorf("Downloaded file = %q, %v, want %q", content, err, tt.content)
+			}
+		})
+	}
+
+	// Um token inválido é informado com a resposta da API
+	_, _, err := New(Options{URL: tests[1].url, DropboxToken: "expired"}).Stat(context.Background())
+	if sErr, ok := err.(*errors.StructuredError); !ok || sErr.Details != `Status: 401 Unauthorized: {"error_summary": "invalid_access_token/"}` {
+		t.Errorf("Stat() with an invalid token = %v", err)
+	}
+}
+
+func TestUsesConnector(t *testing.T) {
+	tokens := Options{GoogleDriveToken: "drive", DropboxToken: "dropbox"}
+	tests := []struct {
+		url     string
+		options Options
+		want    bool
+	}{
+		{"https://drive.google.com/file/d/1AbC/view", tokens, true},
+		{"https://drive.google.com/uc?id=1AbC&export=download", tokens, true},
+		{"https://drive.google.com/drive/folders/1AbC", tokens, false},
+		{"https://www.dropbox.com/s/abc/video.mp4?dl=0", tokens, true},
+		{"https://dl.dropboxusercontent.com/s/abc/video.mp4", tokens, false},
+		// Sem token, o link é baixado como qualquer URL
+		{"https://drive.google.com/file/d/1AbC/view", Options{DropboxToken: "dropbox"}, false},
+		{"https://www.dropbox.com/s/abc/video.mp4?dl=1", Options{GoogleDriveToken: "drive"}, false},
+		{"https://example.com/video.mp4", tokens, false},
+	}
+	for _, tt := range tests {
+		tt.options.URL = tt.url
+		if got := UsesConnector(tt.options); got != tt.want {
+			t.Errorf("UsesConnector(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
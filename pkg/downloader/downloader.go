@@ -28,6 +28,21 @@ type Options struct {
 	// AllowOverride, if true, allows the downloader to overwrite an existing file
 	// at the OutputPath. If false and the file exists, the download is skipped.
 	AllowOverride bool
+	// GoogleDriveToken is the OAuth access token used to download Google
+	// Drive share links (e.g. https://drive.google.com/file/d/<id>/view)
+	// through the Drive API. Without it, such links are downloaded as any URL.
+	GoogleDriveToken string
+	// DropboxToken is the access token used to download Dropbox share links
+	// (e.g. https://www.dropbox.com/scl/fi/...) through the Dropbox API.
+	// Without it, such links are downloaded as any URL.
+	DropboxToken string
+	// MaxSize, if positive, is the size in bytes above which the download fails
+	// with ErrInputTooLarge. Announced sizes are rejected before anything is
+	// written, and the others once the limit is reached.
+	MaxSize int64
+	// Logger receives the log messages of the download.
+	// Defaults to logger.NewLogger().
+	Logger logger.Logger
 }
 
 // Downloader handles the process of downloading files from a given URL.
@@ -45,6 +60,9 @@ func New(options Options) *Downloader {
 	if options.Timeout == 0 {
 		options.Timeout = 30 * time.Minute
 	}
+	if options.Logger == nil {
+		options.Logger = logger.NewLogger()
+	}
 
 	client := &http.Client{
 		Timeout: options.Timeout,
@@ -71,20 +89,20 @@ func (d *Downloader) Download(ctx context.Context) (string, error) {
 
 	// Check if file already exists
 	if _, err := os.Stat(d.options.OutputPath); err == nil && !d.options.AllowOverride {
-		logger.Info("File already exists, skipping download", "downloader", map[string]interface{}{
+		d.options.Logger.Info("File already exists, skipping download", "downloader", map[string]interface{}{
 			"path": d.options.OutputPath,
 		})
 		return d.options.OutputPath, nil
 	}
 
 	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.options.URL, nil)
+	req, err := d.newRequest(ctx)
 	if err != nil {
-		return "", errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 2)
+		return "", err
 	}
 
 	// Log download start
-	logger.Info("Starting download", "downloader", map[string]interface{}{
+	d.options.Logger.Info("Starting download", "downloader", map[string]interface{}{
 		"url":  d.options.URL,
 		"path": d.options.OutputPath,
 	})
@@ -101,6 +119,11 @@ func (d *Downloader) Download(ctx context.Context) (string, error) {
 		return "", errors.New(errors.DownloadError, "HTTP request failed", fmt.Sprintf("Status: %s", resp.Status), 4)
 	}
 
+	// Reject files announced larger than the limit before writing them
+	if d.options.MaxSize > 0 && resp.ContentLength > d.options.MaxSize {
+		return "", d.tooLarge(resp.ContentLength)
+	}
+
 	// Create output file
 	file, err := os.Create(d.options.OutputPath)
 	if err != nil {
@@ -121,35 +144,57 @@ func (d *Downloader) Download(ctx context.Context) (string, error) {
 			reader:   resp.Body,
 			reporter: d.options.Progress,
 			size:     contentLength,
+			started:  time.Now(),
 		}
 	} else {
 		reader = resp.Body
 	}
 
-	// Copy data from response to file
-	if _, err := io.Copy(file, reader); err != nil {
+	// Copy data from response to file, reading one byte past the limit to detect larger files
+	if d.options.MaxSize > 0 {
+		reader = io.LimitReader(reader, d.options.MaxSize+1)
+	}
+	written, err := io.Copy(file, reader)
+	if err != nil {
+		// Um arquivo parcial seria reaproveitado pelo próximo download sem AllowOverride
+		file.Close()
+		os.Remove(d.options.OutputPath)
 		return "", errors.Wrap(err, errors.DownloadError, "Failed to write file", 6)
 	}
+	if d.options.MaxSize > 0 && written > d.options.MaxSize {
+		file.Close()
+		os.Remove(d.options.OutputPath)
+		return "", d.tooLarge(written)
+	}
 
 	// Complete progress
 	if d.options.Progress != nil {
 		d.options.Progress.Complete()
 	}
 
-	logger.Info("Download completed", "downloader", map[string]interface{}{
+	d.options.Logger.Info("Download completed", "downloader", map[string]interface{}{
 		"path": d.options.OutputPath,
 	})
 
 	return d.options.OutputPath, nil
 }
 
+// tooLarge returns the error of a download of size bytes, larger than MaxSize.
+// A size read from the body is a lower bound.
+func (d *Downloader) tooLarge(size int64) *errors.StructuredError {
+	return errors.New(errors.ValidationError, errors.GetErrorMessage(errors.ErrInputTooLarge),
+		fmt.Sprintf("%s: %d bytes, more than the maximum of %d", d.options.URL, size, d.options.MaxSize), errors.ErrInputTooLarge)
+}
+
 // progressReader is an internal io.Reader wrapper used to track download progress
-// by reporting the number of bytes read via a progress.Reporter.
+// by reporting the number of bytes read, out of size, and the average download
+// speed via a progress.Reporter (see progress.UpdateBytes).
 type progressReader struct {
 	reader   io.Reader
 	reporter progress.Reporter
 	size     int64
 	read     int64
+	started  time.Time
 }
 
 // Read implements the io.Reader interface for progressReader.
@@ -158,7 +203,16 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 	n, err := pr.reader.Read(p)
 	if n > 0 {
 		pr.read += int64(n)
-		pr.reporter.Update(pr.read, "downloading", "Downloading file")
+		progress.UpdateBytes(pr.reporter, pr.read, pr.size, pr.bytesPerSecond(), "downloading", "Downloading file")
 	}
 	return n, err
 }
+
+// bytesPerSecond returns the average speed since the download started.
+func (pr *progressReader) bytesPerSecond() float64 {
+	elapsed := time.Since(pr.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(pr.read) / elapsed
+}
@@ -10,29 +10,39 @@ import (
 	"testing"
 	"time"
 
+	"github.com/heyjunin/HLSpresso/pkg/errors"
 	"github.com/heyjunin/HLSpresso/pkg/progress"
 )
 
 // mockProgressReporter é um mock simples para testes
 type mockProgressReporter struct {
-	started   bool
-	completed bool
-	updates   int
-	total     int64
-	current   int64
+	started    bool
+	completed  bool
+	updates    int
+	total      int64
+	current    int64
+	totalBytes int64
+	speed      float64
 }
 
 func (m *mockProgressReporter) Start(total int64)                 { m.started = true; m.total = total }
 func (m *mockProgressReporter) Update(current int64, _, _ string) { m.updates++; m.current = current }
 func (m *mockProgressReporter) Increment(_, _ string)             { m.updates++; m.current++ }
 func (m *mockProgressReporter) Complete()                         { m.completed = true }
+func (m *mockProgressReporter) Fail(err error)                    {}
 func (m *mockProgressReporter) Updates() <-chan progress.ProgressEvent {
 	ch := make(chan progress.ProgressEvent)
 	close(ch)
 	return ch
 }
-func (m *mockProgressReporter) Close()                {}
+func (m *mockProgressReporter) Close() error          { return nil }
 func (m *mockProgressReporter) JSON() (string, error) { return "{}", nil } // Mock JSON
+func (m *mockProgressReporter) UpdateBytes(current, total int64, bytesPerSecond float64, _, _ string) {
+	m.updates++
+	m.current = current
+	m.totalBytes = total
+	m.speed = bytesPerSecond
+}
 
 func TestNewDownloader(t *testing.T) {
 	opts := Options{}
@@ -104,6 +114,12 @@ func TestDownloader_Download_Success(t *testing.T) {
 	if mockReporter.updates == 0 {
 		t.Error("Progress reporter Update() was never called")
 	}
+	if mockReporter.current != 12 || mockReporter.totalBytes != 12 {
+		t.Errorf("Byte progress = %d/%d, want 12/12", mockReporter.current, mockReporter.totalBytes)
+	}
+	if mockReporter.speed <= 0 {
+		t.Errorf("Download speed = %f, want a positive value", mockReporter.speed)
+	}
 }
 
 func TestDownloader_Download_SkipExisting(t *testing.T) {
@@ -250,3 +266,39 @@ func TestDownloader_Download_ContextCancel(t *testing.T) {
 		}
 	}
 }
+
+func TestDownloader_Download_MaxSize(t *testing.T) {
+	for _, announced := range []bool{true, false} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if announced {
+				w.Header().Set("Content-Length", "20")
+			} else {
+				w.(http.Flusher).Flush() // Resposta chunked, sem Content-Length
+			}
+			fmt.Fprint(w, "twenty bytes content")
+		}))
+
+		outputPath := filepath.Join(t.TempDir(), "large.mp4")
+		d := New(Options{URL: server.URL, OutputPath: outputPath, MaxSize: 10})
+		_, err := d.Download(context.Background())
+		server.Close()
+
+		sErr, ok := err.(*errors.StructuredError)
+		if !ok || sErr.Code != errors.ErrInputTooLarge {
+			t.Errorf("Announced size %v: Download() error = %v, want ErrInputTooLarge", announced, err)
+		}
+		if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+			t.Errorf("Announced size %v: the rejected download should be removed", announced)
+		}
+	}
+
+	// Arquivos dentro do limite são baixados normalmente
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ten bytes!")
+	}))
+	defer server.Close()
+	d := New(Options{URL: server.URL, OutputPath: filepath.Join(t.TempDir(), "small.mp4"), MaxSize: 10})
+	if _, err := d.Download(context.Background()); err != nil {
+		t.Errorf("Download() within the limit failed: %v", err)
+	}
+}
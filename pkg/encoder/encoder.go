@@ -0,0 +1,112 @@
+package encoder
+
+import (
+	"context"
+
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// Format identifies the kind of output produced by an encoding Job.
+type Format string
+
+const (
+	// FormatHLS produces HLS adaptive streaming files (master playlist, variant
+	// playlists and segments) inside an output directory.
+	FormatHLS Format = "hls"
+	// FormatMP4 produces a single MP4 file.
+	FormatMP4 Format = "mp4"
+	// FormatProRes produces a single QuickTime file with ProRes 422 video
+	// (10-bit 4:2:2) and uncompressed audio, for archival and editing.
+	FormatProRes Format = "prores"
+	// FormatDNxHR produces a single QuickTime file with DNxHR HQ video (8-bit
+	// 4:2:2) and uncompressed audio, for archival and editing.
+	FormatDNxHR Format = "dnxhr"
+)
+
+// Job describes a single encoding operation independently of the backend that
+// performs it. The Transcoder validates inputs and prepares output locations
+// before handing a Job to an Encoder.
+type Job struct {
+	// Format selects the kind of output to produce.
+	Format Format
+	// InputPath is the local file path or URL of the source media.
+	InputPath string
+	// OutputPath is the output directory for FormatHLS or the output file for
+	// the other formats.
+	OutputPath string
+	// Resolutions defines the HLS quality levels. Only used for FormatHLS.
+	Resolutions []hls.VideoResolution
+	// SegmentDuration is the target HLS segment duration in seconds. Only used for FormatHLS.
+	SegmentDuration int
+	// PlaylistType is the HLS playlist type ("vod" or "event"). Only used for FormatHLS.
+	PlaylistType string
+	// ExtraParams are backend specific arguments (e.g. additional ffmpeg
+	// flags). Not used for FormatProRes and FormatDNxHR, whose codec
+	// arguments are fixed.
+	ExtraParams []string
+	// InputParams are backend specific arguments applying to the input (e.g.
+	// ffmpeg flags placed before -i).
+	InputParams []string
+	// EncoderSettings are the x264 preset, tune, profile and level of the
+	// video, overridden for FormatHLS by those of each resolution. The preset
+	// defaults to medium.
+	EncoderSettings hls.EncoderSettings
+	// LogOutput logs the raw output of the backend (e.g. ffmpeg stderr lines) at the debug level.
+	LogOutput bool
+	// AudioCodec selects the audio codec of the output. Empty means AAC.
+	AudioCodec hls.AudioCodec
+	// SharedAudio encodes the audio once, as an audio rendition shared by every
+	// variant. Only used for FormatHLS.
+	SharedAudio bool
+	// Version is the HLS protocol version targeted by the playlists, which
+	// selects the features used (see hls.CheckVersion). Only used for FormatHLS.
+	Version int
+	// ByteRange stores the segments of each variant in a single file. Only used for FormatHLS.
+	ByteRange bool
+	// MPEGTS tunes the muxer of MPEG-TS segments. Only used for FormatHLS.
+	MPEGTS hls.MPEGTSOptions
+	// ClosedCaptions keeps the CEA-608/708 captions embedded in the input video
+	// and, for FormatHLS, declares them in the master playlist.
+	ClosedCaptions bool
+	// CaptionLanguage is the language of the closed captions. Only used for FormatHLS.
+	CaptionLanguage string
+	// Deinterlace converts the interlaced input video to progressive frames
+	// with this filter. Empty leaves the video as is.
+	Deinterlace hls.Deinterlacer
+	// Color converts the input video to a pixel format and tags the output
+	// with color tags (see hls.ColorSettings).
+	Color hls.ColorSettings
+	// Height scales the video to this height, keeping its aspect ratio. Zero
+	// keeps the size of the input. Only used for FormatMP4.
+	Height int
+	// Deterministic produces byte-identical output for identical jobs (see
+	// hls.DeterministicArgs).
+	Deterministic bool
+	// RealtimePacing encodes at about 1x realtime (ffmpeg -re) instead of as fast
+	// as possible, for simulated live workflows or to smooth IO on shared storage.
+	RealtimePacing bool
+	// VideoConcatLists are ffconcat lists of the video of each resolution,
+	// already encoded in chunks, to segment without re-encoding (see
+	// hls.Options.VideoConcatLists). Only used for FormatHLS.
+	VideoConcatLists []string
+	// Duration is the duration of the input in seconds, against which the
+	// progress of FormatMP4 is measured. If zero, it is probed with ffprobe.
+	Duration float64
+	// Progress is an optional progress.Reporter to receive encoding updates.
+	Progress progress.Reporter
+}
+
+// Encoder is implemented by encoding backends. The default backend executes the
+// ffmpeg command line tool (see FFmpegEncoder); alternative implementations can
+// wrap libav bindings, GStreamer or a remote encoding service, or fake the
+// encoding entirely in tests.
+type Encoder interface {
+	// Name returns a short identifier of the backend (e.g. "ffmpeg").
+	Name() string
+	// Check verifies that the backend is available and supports the codecs it needs.
+	Check(ctx context.Context) error
+	// Encode performs the job and returns the path to the primary output
+	// (the HLS master playlist or the MP4 file).
+	Encode(ctx context.Context, job Job) (string, error)
+}
@@ -0,0 +1,373 @@
+package encoder
+
+import (
+	"context"
+	stderrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+func TestNewFFmpegEncoderDefaults(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	if e.binary != "ffmpeg" {
+		t.Errorf("Default binary: got %q, want \"ffmpeg\"", e.binary)
+	}
+	if e.logger == nil {
+		t.Error("Default logger should not be nil")
+	}
+	if e.Name() != "ffmpeg" {
+		t.Errorf("Name() = %q, want \"ffmpeg\"", e.Name())
+	}
+}
+
+func TestArgsMP4(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	args, err := e.Args(Job{
+		Format:      FormatMP4,
+		InputPath:   "input.mp4",
+		OutputPath:  "out/video.mp4",
+		ExtraParams: []string{"-threads", "2"},
+	})
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+
+	argsStr := strings.Join(args, " ")
+	for _, want := range []string{"-i input.mp4", "-c:v libx264", "-c:a aac", "-threads 2"} {
+		if !strings.Contains(argsStr, want) {
+			t.Errorf("Args() = %q, missing %q", argsStr, want)
+		}
+	}
+	if args[len(args)-1] != "out/video.mp4" {
+		t.Errorf("Last argument should be the output file, got %q", args[len(args)-1])
+	}
+	if !strings.Contains(argsStr, "-preset:v medium") {
+		t.Errorf("Args() = %q, want the medium preset by default", argsStr)
+	}
+}
+
+func TestArgsEncoderSettings(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	args, err := e.Args(Job{
+		Format:          FormatMP4,
+		InputPath:       "input.mp4",
+		OutputPath:      "out/video.mp4",
+		EncoderSettings: hls.EncoderSettings{Preset: hls.PresetVeryslow, Tune: hls.TuneGrain, Profile: hls.ProfileMain, Level: "4"},
+	})
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	want := "-c:v libx264 -preset:v veryslow -tune:v grain -profile:v main -level:v 4 -crf 22"
+	if argsStr := strings.Join(args, " "); !strings.Contains(argsStr, want) {
+		t.Errorf("Args() = %q, missing %q", argsStr, want)
+	}
+}
+
+func TestArgsInputParams(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	for _, format := range []Format{FormatMP4, FormatHLS} {
+		args, err := e.Args(Job{
+			Format:         format,
+			InputPath:      "input.mp4",
+			OutputPath:     "out",
+			Resolutions:    []hls.VideoResolution{{Width: 640, Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"}},
+			InputParams:    []string{"-ss", "30"},
+			RealtimePacing: true,
+		})
+		if err != nil {
+			t.Fatalf("Args(%s) error = %v", format, err)
+		}
+		// Os parâmetros de entrada precedem o -i da entrada
+		if argsStr := strings.Join(args, " "); !strings.HasPrefix(argsStr, "-re -ss 30 -i input.mp4 ") {
+			t.Errorf("Args(%s) = %q, want the input params before -i", format, argsStr)
+		}
+	}
+}
+
+func TestArgsDeinterlace(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	args, err := e.Args(Job{
+		Format:      FormatMP4,
+		InputPath:   "input.ts",
+		OutputPath:  "out/video.mp4",
+		Deinterlace: hls.DeinterlaceYadif,
+		Color:       hls.ColorSettings{PixelFormat: hls.PixelFormatYUV420P, ColorPrimaries: "bt709"},
+	})
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	if argsStr := strings.Join(args, " "); !strings.Contains(argsStr, "-i input.ts -vf yadif=mode=send_frame,format=yuv420p -c:v libx264") ||
+		!strings.Contains(argsStr, "-color_primaries:v bt709") {
+		t.Errorf("Args() = %q, want the deinterlace and pixel format filters and the color tags", argsStr)
+	}
+}
+
+func TestArgsHeight(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	for _, tt := range []struct {
+		job  Job
+		want string
+	}{
+		{Job{Height: 720}, "-i input.mp4 -vf scale=-2:720 -c:v libx264"},
+		{Job{Height: 720, Deinterlace: hls.DeinterlaceBwdif}, "-i input.mp4 -vf bwdif=mode=send_frame,scale=-2:720 -c:v libx264"},
+	} {
+		tt.job.Format, tt.job.InputPath, tt.job.OutputPath = FormatMP4, "input.mp4", "out/video_720p.mp4"
+		args, err := e.Args(tt.job)
+		if err != nil {
+			t.Fatalf("Args() error = %v", err)
+		}
+		if argsStr := strings.Join(args, " "); !strings.HasPrefix(argsStr, tt.want) {
+			t.Errorf("Args() = %q, want prefix %q", argsStr, tt.want)
+		}
+	}
+}
+
+func TestArgsMezzanine(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	for format, want := range map[Format]string{
+		FormatProRes: "-i input.mxf -vf format=yuv422p10le -c:v prores_ks -profile:v 2 -vendor apl0 -colorspace:v bt709 -c:a pcm_s24le -f mov -y out/movie_prores.mov",
+		FormatDNxHR:  "-i input.mxf -vf format=yuv422p -c:v dnxhd -profile:v dnxhr_hq -colorspace:v bt709 -c:a pcm_s24le -f mov -y out/movie_dnxhr.mov",
+	} {
+		args, err := e.Args(Job{
+			Format:      format,
+			InputPath:   "input.mxf",
+			OutputPath:  "out/movie_" + string(format) + ".mov",
+			ExtraParams: []string{"-crf", "18"},
+			Color:       hls.ColorSettings{PixelFormat: hls.PixelFormatYUV420P, ColorSpace: "bt709"},
+		})
+		if err != nil {
+			t.Fatalf("Args(%s) error = %v", format, err)
+		}
+		if argsStr := strings.Join(args, " "); argsStr != want {
+			t.Errorf("Args(%s) = %q, want %q", format, argsStr, want)
+		}
+	}
+}
+
+func TestArgsHLS(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	args, err := e.Args(Job{
+		Format:          FormatHLS,
+		InputPath:       "input.mp4",
+		OutputPath:      "out",
+		SegmentDuration: 4,
+		Resolutions: []hls.VideoResolution{
+			{Width: 640, Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+
+	argsStr := strings.Join(args, " ")
+	for _, want := range []string{"-i input.mp4", "-hls_time 4", "master.m3u8"} {
+		if !strings.Contains(argsStr, want) {
+			t.Errorf("Args() = %q, missing %q", argsStr, want)
+		}
+	}
+}
+
+func TestArgsRealtimePacing(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	for _, format := range []Format{FormatMP4, FormatHLS} {
+		job := Job{Format: format, InputPath: "input.mp4", OutputPath: "out"}
+		args, _ := e.Args(job)
+		if strings.Contains(strings.Join(args, " ")+" ", "-re ") {
+			t.Errorf("Args(%s) = %q, should not pace without RealtimePacing", format, args)
+		}
+
+		job.RealtimePacing = true
+		args, _ = e.Args(job)
+		// -re is an input option and must precede -i
+		if len(args) < 2 || args[0] != "-re" || args[1] != "-i" {
+			t.Errorf("Args(%s) = %q, want -re before -i", format, args)
+		}
+	}
+}
+
+func TestArgsDeterministic(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	for _, format := range []Format{FormatMP4, FormatHLS} {
+		job := Job{Format: format, InputPath: "input.mp4", OutputPath: "out"}
+		args, _ := e.Args(job)
+		if strings.Contains(strings.Join(args, " "), "bitexact") {
+			t.Errorf("Args(%s) = %q, should not be bitexact without Deterministic", format, args)
+		}
+
+		job.Deterministic = true
+		args, _ = e.Args(job)
+		argsStr := strings.Join(args, " ")
+		for _, want := range []string{"-threads 1", "-fflags +bitexact", "-map_metadata -1"} {
+			if !strings.Contains(argsStr, want) {
+				t.Errorf("Args(%s) = %q, missing %q", format, argsStr, want)
+			}
+		}
+	}
+}
+
+func TestArgsUnknownFormat(t *testing.T) {
+	e := NewFFmpegEncoder("", nil, nil)
+	if _, err := e.Args(Job{Format: "webm"}); err == nil {
+		t.Error("Args() should fail for an unknown format")
+	}
+}
+
+func TestCheckCodecs(t *testing.T) {
+	if err := checkCodecs(" DEV.LS h264 (encoders: libx264)\n DEA.L. aac"); err != nil {
+		t.Errorf("checkCodecs() unexpected error: %v", err)
+	}
+
+	err := checkCodecs(" DEA.L. aac")
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok {
+		t.Fatalf("checkCodecs() should return a StructuredError, got %T", err)
+	}
+	if sErr.Code != errors.ErrCodecNotFound {
+		t.Errorf("checkCodecs() code = %d, want %d", sErr.Code, errors.ErrCodecNotFound)
+	}
+}
+
+func TestClassifyExitError(t *testing.T) {
+	err := classifyExitError(stderrors.New("signal: killed"), "Unknown encoder 'libx264'")
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok {
+		t.Fatalf("classifyExitError() should return a StructuredError, got %T", err)
+	}
+	// Only exit code 1 is inspected; other failures are generic transcoding errors.
+	if sErr.Type != errors.TranscodingError {
+		t.Errorf("classifyExitError() type = %q, want %q", sErr.Type, errors.TranscodingError)
+	}
+	if sErr.FFmpegOutput != "Unknown encoder 'libx264'" {
+		t.Errorf("classifyExitError() FFmpegOutput = %q, want the ffmpeg output", sErr.FFmpegOutput)
+	}
+}
+
+// recordingLogger records the messages logged by the "ffmpeg" component.
+type recordingLogger struct {
+	ffmpegLines []string
+}
+
+func (l *recordingLogger) Debug(message, component string, _ map[string]interface{}) {
+	if component == "ffmpeg" && message != "Executing FFmpeg command" {
+		l.ffmpegLines = append(l.ffmpegLines, message)
+	}
+}
+func (l *recordingLogger) Info(string, string, map[string]interface{})  {}
+func (l *recordingLogger) Warn(string, string, map[string]interface{})  {}
+func (l *recordingLogger) Error(string, string, map[string]interface{}) {}
+func (l *recordingLogger) Fatal(string, string, map[string]interface{}) {}
+
+func TestEncodeMP4LogOutput(t *testing.T) {
+	runner := &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			return ffmpegtest.Result{Stderr: "Input #0, mov\nframe=  10 time=00:00:01.00\n"}
+		},
+	}
+
+	for _, logOutput := range []bool{false, true} {
+		log := &recordingLogger{}
+		e := NewFFmpegEncoder("", runner, log)
+		job := Job{Format: FormatMP4, InputPath: "input.mp4", OutputPath: "out.mp4", LogOutput: logOutput}
+		if _, err := e.Encode(context.Background(), job); err != nil {
+			t.Fatalf("Encode() unexpected error: %v", err)
+		}
+
+		wantLines := 0
+		if logOutput {
+			wantLines = 2
+		}
+		if len(log.ffmpegLines) != wantLines {
+			t.Errorf("LogOutput=%v logged %d ffmpeg lines, want %d: %v", logOutput, len(log.ffmpegLines), wantLines, log.ffmpegLines)
+		}
+	}
+}
+
+// speedReporter records the MP4 progress reported to it.
+type speedReporter struct {
+	total     int64
+	updates   []int64
+	speeds    []float64
+	etas      []float64
+	estimate  map[string]interface{}
+	completed bool
+}
+
+func (r *speedReporter) Start(total int64) { r.total = total }
+func (r *speedReporter) Update(current int64, _, _ string) {
+	r.updates = append(r.updates, current)
+}
+func (r *speedReporter) UpdateSpeed(current int64, speed, eta float64, _, _ string) {
+	r.Update(current, "", "")
+	r.speeds = append(r.speeds, speed)
+	r.etas = append(r.etas, eta)
+}
+func (r *speedReporter) ReportEvent(name string, data map[string]interface{}) {
+	if name == progress.EventProgressEstimated {
+		r.estimate = data
+	}
+}
+func (r *speedReporter) Increment(string, string)               {}
+func (r *speedReporter) Complete()                              { r.completed = true }
+func (r *speedReporter) Fail(error)                             {}
+func (r *speedReporter) Close() error                           { return nil }
+func (r *speedReporter) Updates() <-chan progress.ProgressEvent { return nil }
+func (r *speedReporter) JSON() (string, error)                  { return "", nil }
+
+func TestEncodeMP4Progress(t *testing.T) {
+	stats := "frame=  250 time=-00:00:00.04 speed=N/A\rframe=  250 time=00:00:10.00 bitrate=1000k speed=2.00x\rframe=  500 time=00:00:20.00 speed=4x\n"
+	newRunner := func(duration string) *ffmpegtest.Runner {
+		return &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+			if name == "ffprobe" {
+				return ffmpegtest.Result{Stdout: duration}
+			}
+			return ffmpegtest.Result{Stderr: stats}
+		}}
+	}
+
+	// The duration of the job is used as is, probed otherwise
+	for _, tc := range []struct {
+		name     string
+		duration float64
+		probed   string
+	}{
+		{"job duration", 40, ""},
+		{"probed duration", 0, "40.000000\n"},
+	} {
+		reporter := &speedReporter{}
+		runner := newRunner(tc.probed)
+		e := NewFFmpegEncoder("", runner, nil)
+		job := Job{Format: FormatMP4, InputPath: "input.mp4", OutputPath: "out.mp4", Duration: tc.duration, Progress: reporter}
+		if _, err := e.Encode(context.Background(), job); err != nil {
+			t.Fatalf("%s: Encode() unexpected error: %v", tc.name, err)
+		}
+		if reporter.total != 40000 || !reflect.DeepEqual(reporter.updates, []int64{10000, 20000}) {
+			t.Errorf("%s: progress = %v of %d, want milliseconds of 40000", tc.name, reporter.updates, reporter.total)
+		}
+		if !reflect.DeepEqual(reporter.speeds, []float64{2, 4}) || !reflect.DeepEqual(reporter.etas, []float64{15, 5}) {
+			t.Errorf("%s: speeds = %v, ETAs = %v, want [2 4] and [15 5]", tc.name, reporter.speeds, reporter.etas)
+		}
+		if reporter.estimate["basis"] != "duration" || !reporter.completed {
+			t.Errorf("%s: estimate = %v, completed = %v", tc.name, reporter.estimate, reporter.completed)
+		}
+		if probes := len(runner.Calls()) - 1; (tc.duration == 0) != (probes == 1) {
+			t.Errorf("%s: %d ffprobe runs", tc.name, probes)
+		}
+	}
+
+	// Without a duration, no meaningless percentage is reported
+	reporter := &speedReporter{}
+	e := NewFFmpegEncoder("", newRunner("N/A\n"), nil)
+	job := Job{Format: FormatMP4, InputPath: "input.mp4", OutputPath: "out.mp4", Progress: reporter}
+	if _, err := e.Encode(context.Background(), job); err != nil {
+		t.Fatalf("Encode() unexpected error: %v", err)
+	}
+	if reporter.total != 0 || len(reporter.updates) != 0 || reporter.estimate["basis"] != "none" {
+		t.Errorf("Unknown duration reported %v of %d with estimate %v, want none", reporter.updates, reporter.total, reporter.estimate)
+	}
+}
@@ -0,0 +1,358 @@
+package encoder
+
+import (
+	"bufio"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// FFmpegEncoder is the default Encoder backend. It builds ffmpeg command line
+// arguments for a Job and executes the ffmpeg binary.
+// Create instances using NewFFmpegEncoder().
+type FFmpegEncoder struct {
+	binary string
+	runner ffmpeg.Runner
+	logger logger.Logger
+}
+
+// NewFFmpegEncoder creates an FFmpegEncoder that runs the given ffmpeg binary
+// through runner.
+// The binary defaults to "ffmpeg", the runner to ffmpeg.ExecRunner and the
+// logger to logger.NewLogger().
+func NewFFmpegEncoder(binary string, runner ffmpeg.Runner, log logger.Logger) *FFmpegEncoder {
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+	if runner == nil {
+		runner = ffmpeg.ExecRunner{}
+	}
+	if log == nil {
+		log = logger.NewLogger()
+	}
+	return &FFmpegEncoder{
+		binary: binary,
+		runner: runner,
+		logger: log,
+	}
+}
+
+// Name returns "ffmpeg".
+func (e *FFmpegEncoder) Name() string {
+	return "ffmpeg"
+}
+
+// Check verifies that FFmpeg is installed and working correctly,
+// including checking for essential codecs and dependencies.
+func (e *FFmpegEncoder) Check(ctx context.Context) error {
+	output, err := e.runner.Output(ctx, e.binary, "-version")
+	if err != nil {
+		return errors.Wrap(err, errors.CodecNotFoundError,
+			errors.GetErrorMessage(errors.ErrMissingDependency),
+			errors.ErrMissingDependency)
+	}
+
+	e.logger.Debug("FFmpeg version info", "ffmpeg", map[string]interface{}{
+		"version_output": string(output),
+	})
+
+	// Verificar codecs importantes
+	codecsOutput, err := e.runner.Output(ctx, e.binary, "-codecs")
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Falha ao verificar codecs do FFmpeg", 20)
+	}
+
+	return checkCodecs(string(codecsOutput))
+}
+
+// checkCodecs verifies that the output of "ffmpeg -codecs" lists the codecs
+// required by HLSpresso.
+func checkCodecs(codecsStr string) error {
+	requiredCodecs := []struct {
+		name       string
+		searchTerm string
+		errorCode  int
+	}{
+		{"libx264", "libx264", errors.ErrCodecNotFound},
+		{"AAC audio", "aac", errors.ErrCodecNotSupported},
+	}
+
+	for _, codec := range requiredCodecs {
+		if !strings.Contains(codecsStr, codec.searchTerm) {
+			return errors.New(errors.CodecNotFoundError,
+				errors.GetErrorMessage(codec.errorCode),
+				fmt.Sprintf("Codec %s não encontrado", codec.name),
+				codec.errorCode)
+		}
+	}
+
+	return nil
+}
+
+// Args builds the ffmpeg command line arguments for job without executing anything.
+func (e *FFmpegEncoder) Args(job Job) ([]string, error) {
+	switch job.Format {
+	case FormatHLS:
+		return hls.New(e.hlsOptions(job)).Args(), nil
+	case FormatMP4:
+		return mp4Args(job), nil
+	case FormatProRes, FormatDNxHR:
+		return mezzanineArgs(job), nil
+	default:
+		return nil, errors.New(errors.ValidationError, "Unknown output format", string(job.Format), 21)
+	}
+}
+
+// Encode executes ffmpeg for job and returns the path to the primary output.
+func (e *FFmpegEncoder) Encode(ctx context.Context, job Job) (string, error) {
+	switch job.Format {
+	case FormatHLS:
+		return hls.New(e.hlsOptions(job)).CreateHLS(ctx)
+	case FormatMP4, FormatProRes, FormatDNxHR:
+		return e.encodeFile(ctx, job)
+	default:
+		return "", errors.New(errors.ValidationError, "Unknown output format", string(job.Format), 21)
+	}
+}
+
+// hlsOptions converts a Job into hls.Options.
+func (e *FFmpegEncoder) hlsOptions(job Job) hls.Options {
+	return hls.Options{
+		InputFile:         job.InputPath,
+		OutputDir:         job.OutputPath,
+		SegmentDuration:   job.SegmentDuration,
+		PlaylistType:      job.PlaylistType,
+		Resolutions:       job.Resolutions,
+		FFmpegBinary:      e.binary,
+		FFmpegExtraParams: job.ExtraParams,
+		FFmpegInputParams: job.InputParams,
+		EncoderSettings:   job.EncoderSettings,
+		LogFFmpegOutput:   job.LogOutput,
+		AudioCodec:        job.AudioCodec,
+		SharedAudio:       job.SharedAudio,
+		Version:           job.Version,
+		ByteRange:         job.ByteRange,
+		MPEGTS:            job.MPEGTS,
+		RealtimePacing:    job.RealtimePacing,
+		Deterministic:     job.Deterministic,
+		ClosedCaptions:    job.ClosedCaptions,
+		CaptionLanguage:   job.CaptionLanguage,
+		Deinterlace:       job.Deinterlace,
+		Color:             job.Color,
+		VideoConcatLists:  job.VideoConcatLists,
+		Progress:          job.Progress,
+		Runner:            e.runner,
+	}
+}
+
+// mp4Args builds the ffmpeg arguments for a single MP4 output.
+func mp4Args(job Job) []string {
+	var args []string
+	if job.RealtimePacing {
+		args = append(args, "-re")
+	}
+	args = append(args, job.InputParams...)
+	args = append(args, "-i", job.InputPath)
+	filter := hls.SourceFilter(job.Deinterlace, job.Color)
+	if job.Height > 0 {
+		filter = strings.TrimPrefix(filter+fmt.Sprintf(",scale=-2:%d", job.Height), ",")
+	}
+	if filter != "" {
+		args = append(args, "-vf", filter)
+	}
+	args = append(args, "-c:v", "libx264")
+	args = append(args, job.EncoderSettings.Or(hls.EncoderSettings{Preset: hls.PresetMedium}).Args("v")...)
+	args = append(args, job.Color.Args("v")...)
+	args = append(args, "-crf", "22")
+	switch job.AudioCodec {
+	case hls.AudioCopy, hls.AudioAC3, hls.AudioEAC3:
+		args = append(args, "-c:a", string(job.AudioCodec))
+	default:
+		args = append(args, "-c:a", "aac", "-b:a", "128k")
+	}
+	if job.ClosedCaptions {
+		args = append(args, "-a53cc", "1")
+	}
+	if job.Deterministic {
+		args = append(args, hls.DeterministicArgs()...)
+	}
+
+	// Add any extra parameters
+	args = append(args, job.ExtraParams...)
+
+	// Add output path
+	return append(args, "-y", job.OutputPath)
+}
+
+// mezzanineCodecs maps the mezzanine formats to the arguments of their video
+// codec and to the pixel format it encodes.
+var mezzanineCodecs = map[Format]struct {
+	args        []string
+	pixelFormat hls.PixelFormat
+}{
+	FormatProRes: {[]string{"-c:v", "prores_ks", "-profile:v", "2", "-vendor", "apl0"}, hls.PixelFormatYUV422P10},
+	FormatDNxHR:  {[]string{"-c:v", "dnxhd", "-profile:v", "dnxhr_hq"}, hls.PixelFormatYUV422P},
+}
+
+// mezzanineArgs builds the ffmpeg arguments for a single FormatProRes or
+// FormatDNxHR file. The video is converted to the pixel format of the codec
+// and keeps the color tags of job, and the audio is stored as 24-bit PCM.
+func mezzanineArgs(job Job) []string {
+	codec := mezzanineCodecs[job.Format]
+	color := job.Color
+	color.PixelFormat = codec.pixelFormat
+
+	args := append([]string{}, job.InputParams...)
+	args = append(args, "-i", job.InputPath, "-vf", hls.SourceFilter(job.Deinterlace, color))
+	args = append(args, codec.args...)
+	args = append(args, color.Args("v")...)
+	args = append(args, "-c:a", "pcm_s24le")
+	if job.Deterministic {
+		args = append(args, hls.DeterministicArgs()...)
+	}
+	return append(args, "-f", "mov", "-y", job.OutputPath)
+}
+
+// encodeFile runs ffmpeg to produce a single MP4 or mezzanine file.
+func (e *FFmpegEncoder) encodeFile(ctx context.Context, job Job) (string, error) {
+	args, err := e.Args(job)
+	if err != nil {
+		return "", err
+	}
+
+	e.logger.Debug("Executing FFmpeg command", "ffmpeg", map[string]interface{}{
+		"command": e.binary + " " + strings.Join(args, " "),
+	})
+
+	// Measure the progress in milliseconds of the input duration
+	estimate := hls.ProgressEstimate{Basis: hls.ProgressNone}
+	if job.Progress != nil {
+		duration := job.Duration
+		if duration <= 0 {
+			duration = ffmpeg.ProbeDuration(ctx, e.runner, job.InputPath)
+		}
+		if duration > 0 {
+			estimate = hls.ProgressEstimate{Basis: hls.ProgressDuration, Duration: duration}
+			job.Progress.Start(int64(duration * 1000))
+		}
+		progress.ReportEvent(job.Progress, progress.EventProgressEstimated, estimate.Data())
+		e.logger.Debug("MP4 progress estimated", "ffmpeg", estimate.Data())
+	}
+
+	// Start ffmpeg, capturing stderr for progress tracking
+	proc, err := e.runner.Start(ctx, e.binary, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such file") || stderrors.Is(err, exec.ErrNotFound) {
+			return "", errors.Wrap(err, errors.CodecNotFoundError,
+				errors.GetErrorMessage(errors.ErrMissingDependency),
+				errors.ErrMissingDependency)
+		}
+		return "", errors.Wrap(err, errors.TranscodingError, "Failed to start FFmpeg", 12)
+	}
+
+	// Read stderr until ffmpeg closes it, keeping the tail for error analysis.
+	tail := e.trackProgress(proc.Stderr(), job, estimate.Duration)
+
+	if err := proc.Wait(); err != nil {
+		return "", classifyExitError(err, tail)
+	}
+
+	if job.Progress != nil {
+		job.Progress.Complete()
+	}
+	return job.OutputPath, nil
+}
+
+// stderrTailLines is the number of trailing ffmpeg output lines kept for error analysis.
+const stderrTailLines = 50
+
+// trackProgress lê a saída do FFmpeg em stderr e atualiza o progresso da transcodificação
+// em milissegundos da duração da entrada, com a velocidade e o tempo restante.
+// Sem duração conhecida, nenhuma porcentagem é reportada.
+// Retorna as últimas linhas da saída para análise de erros.
+func (e *FFmpegEncoder) trackProgress(stderr io.Reader, job Job, duration float64) string {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(ffmpeg.ScanLines)
+	var tail []string
+	message := "Encoding MP4"
+	if job.Format != FormatMP4 {
+		message = "Encoding " + string(job.Format) + " mezzanine"
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// Log FFmpeg output
+		if job.LogOutput {
+			e.logger.Debug(line, "ffmpeg", nil)
+		}
+
+		tail = append(tail, line)
+		if len(tail) > stderrTailLines {
+			tail = tail[1:]
+		}
+
+		// Sem reporter ou sem duração, apenas continue registrando a saída
+		if job.Progress == nil || duration <= 0 {
+			continue
+		}
+
+		// Procurar o tempo codificado e a velocidade nas linhas de estatísticas
+		stats, ok := ffmpeg.ParseStats(line)
+		if !ok || stats.Time < 0 {
+			continue
+		}
+		eta := 0.0
+		if stats.Speed > 0 {
+			eta = math.Max(duration-stats.Time.Seconds(), 0) / stats.Speed
+		}
+		progress.UpdateSpeed(job.Progress, stats.Time.Milliseconds(), stats.Speed, eta, "transcoding", message)
+	}
+
+	return strings.Join(tail, "\n")
+}
+
+// classifyExitError maps an ffmpeg failure to a StructuredError based on the
+// exit code and the tail of its output.
+func classifyExitError(err error, errOutput string) error {
+	var exitErr *exec.ExitError
+	if stderrors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// Verificar se há problemas comuns de codec
+		if strings.Contains(errOutput, "Unknown encoder") {
+			return errors.Wrap(err, errors.CodecNotFoundError,
+				errors.GetErrorMessage(errors.ErrCodecNotFound),
+				errors.ErrCodecNotFound).WithFFmpegOutput(errOutput)
+		}
+
+		// Verificar se há problemas de memória
+		if strings.Contains(errOutput, "Cannot allocate memory") ||
+			strings.Contains(errOutput, "out of memory") {
+			return errors.Wrap(err, errors.MemoryError,
+				errors.GetErrorMessage(errors.ErrOutOfMemory),
+				errors.ErrOutOfMemory).WithFFmpegOutput(errOutput)
+		}
+
+		// Verificar problemas com o formato do arquivo
+		if strings.Contains(errOutput, "Invalid data") ||
+			strings.Contains(errOutput, "could not find codec parameters") {
+			return errors.Wrap(err, errors.InvalidFileFormatError,
+				errors.GetErrorMessage(errors.ErrCorruptedFile),
+				errors.ErrCorruptedFile).WithFFmpegOutput(errOutput)
+		}
+	}
+
+	return errors.Wrap(err, errors.TranscodingError, "FFmpeg process failed", 13).WithFFmpegOutput(errOutput)
+}
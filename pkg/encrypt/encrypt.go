@@ -0,0 +1,274 @@
+// Package encrypt encrypts output files at rest with AES-256-GCM, so that
+// outputs can be stored in shared buckets. This is unrelated to HLS AES-128
+// streaming encryption: encrypted files must be decrypted before playback.
+//
+// Files are encrypted in chunks, so large outputs are processed with constant
+// memory. The format is a header (magic and a random nonce prefix) followed by
+// sealed chunks of up to ChunkSize bytes; each chunk nonce holds its index and
+// a flag marking the final chunk, so reordered, truncated or extended files
+// fail to decrypt.
+//
+// Encrypted inputs, in this format or AES-CBC, are decrypted with
+// DecryptInput, with keys given directly or looked up with a KeyProvider.
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeySize is the size of encryption keys in bytes (AES-256).
+const KeySize = 32
+
+// Suffix is appended to the name of encrypted files.
+const Suffix = ".enc"
+
+// ChunkSize is the size of the plaintext chunks sealed independently.
+const ChunkSize = 64 * 1024
+
+// magic identifies encrypted files and the version of the format.
+const magic = "HLSPENC1"
+
+// noncePrefixSize is the size of the random part of chunk nonces; the rest is
+// the chunk index (4 bytes) and the final chunk flag (1 byte).
+const noncePrefixSize = 7
+
+// ErrInvalidKey is returned for keys that are not KeySize bytes long.
+var ErrInvalidKey = fmt.Errorf("encryption key must be %d bytes (%d hex characters)", KeySize, KeySize*2)
+
+// ErrDecrypt is returned when a file is not encrypted, was encrypted with
+// another key, or was modified or truncated.
+var ErrDecrypt = stderrors.New("failed to decrypt: wrong key or corrupted file")
+
+// ParseKey decodes a key given as 64 hex characters, as base64, or as 32 raw
+// bytes. Surrounding whitespace is ignored, so key files may end with a newline.
+func ParseKey(data []byte) ([]byte, error) {
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if len(data) == KeySize {
+		return data, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// ReadKeyFile reads and parses the key stored in the file at path.
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKey(data)
+}
+
+// newAEAD returns the AES-256-GCM cipher for key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of chunk index.
+func chunkNonce(prefix []byte, index uint32, final bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], index)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// Encrypt reads src until EOF and writes it encrypted with key to dst.
+func Encrypt(key []byte, dst io.Writer, src io.Reader) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(dst, magic); err != nil {
+		return err
+	}
+	if _, err := dst.Write(prefix); err != nil {
+		return err
+	}
+
+	buf := make([]byte, ChunkSize)
+	sealed := make([]byte, 0, ChunkSize+aead.Overhead())
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(src, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+		sealed = aead.Seal(sealed[:0], chunkNonce(prefix, index, final), buf[:n], nil)
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+		if index == ^uint32(0) {
+			return stderrors.New("file too large to encrypt")
+		}
+	}
+}
+
+// Decrypt reads a file encrypted with Encrypt from src and writes the
+// plaintext to dst. It returns ErrDecrypt if src cannot be authenticated, in
+// which case part of the plaintext may already have been written.
+func Decrypt(key []byte, dst io.Writer, src io.Reader) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(magic)+noncePrefixSize)
+	if _, err := io.ReadFull(src, header); err != nil || !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return ErrDecrypt
+	}
+	prefix := header[len(magic):]
+
+	buf := make([]byte, ChunkSize+aead.Overhead())
+	plain := make([]byte, 0, ChunkSize)
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(src, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+		plain, err = aead.Open(plain[:0], chunkNonce(prefix, index, final), buf[:n], nil)
+		if err != nil {
+			return ErrDecrypt
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// EncryptFile encrypts the file at path into path+Suffix and removes the
+// plaintext file. Returns the path of the encrypted file.
+func EncryptFile(key []byte, path string) (string, error) {
+	target := path + Suffix
+	if err := transformFile(path, target, func(dst io.Writer, src io.Reader) error {
+		return Encrypt(key, dst, src)
+	}); err != nil {
+		return "", err
+	}
+	return target, os.Remove(path)
+}
+
+// DecryptFile decrypts the file at path, whose name must end with Suffix, into
+// the same path without the suffix. The encrypted file is kept. Returns the
+// path of the decrypted file.
+func DecryptFile(key []byte, path string) (string, error) {
+	if !strings.HasSuffix(path, Suffix) {
+		return "", fmt.Errorf("%s: encrypted file names end with %s", path, Suffix)
+	}
+	target := strings.TrimSuffix(path, Suffix)
+	if err := transformFile(path, target, func(dst io.Writer, src io.Reader) error {
+		return Decrypt(key, dst, src)
+	}); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// EncryptTree encrypts the file at root, or every file under the directory
+// root, with EncryptFile. Files already ending with Suffix are skipped.
+// Returns the paths of the encrypted files.
+func EncryptTree(key []byte, root string) ([]string, error) {
+	var encrypted []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, Suffix) {
+			return nil
+		}
+		target, err := EncryptFile(key, path)
+		if err != nil {
+			return err
+		}
+		encrypted = append(encrypted, target)
+		return nil
+	})
+	return encrypted, err
+}
+
+// DecryptTree decrypts the file at root, or every file ending with Suffix
+// under the directory root, with DecryptFile. Returns the paths of the
+// decrypted files.
+func DecryptTree(key []byte, root string) ([]string, error) {
+	var decrypted []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || (path != root && !strings.HasSuffix(path, Suffix)) {
+			return nil
+		}
+		target, err := DecryptFile(key, path)
+		if err != nil {
+			return err
+		}
+		decrypted = append(decrypted, target)
+		return nil
+	})
+	return decrypted, err
+}
+
+// transformFile writes the result of transform applied to the file at src to
+// dst, through a temporary file so that dst is never left incomplete.
+func transformFile(src, dst string, transform func(io.Writer, io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if err := transform(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	if err := out.Chmod(0644); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
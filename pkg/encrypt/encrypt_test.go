@@ -0,0 +1,127 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := newKey(t)
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, 3*ChunkSize + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		var sealed bytes.Buffer
+		if err := Encrypt(key, &sealed, bytes.NewReader(plain)); err != nil {
+			t.Fatalf("Encrypt(%d bytes) unexpected error: %v", size, err)
+		}
+		if size > 0 && bytes.Contains(sealed.Bytes(), plain) {
+			t.Errorf("Encrypt(%d bytes) output contains the plaintext", size)
+		}
+
+		var opened bytes.Buffer
+		if err := Decrypt(key, &opened, bytes.NewReader(sealed.Bytes())); err != nil {
+			t.Fatalf("Decrypt(%d bytes) unexpected error: %v", size, err)
+		}
+		if !bytes.Equal(opened.Bytes(), plain) {
+			t.Errorf("Decrypt(%d bytes) did not return the plaintext", size)
+		}
+
+		// Truncating the file, even at a chunk boundary, must be detected
+		truncated := sealed.Bytes()[:sealed.Len()-aeadOverhead(t)-1]
+		if err := Decrypt(key, &bytes.Buffer{}, bytes.NewReader(truncated)); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("Decrypt(truncated %d bytes) error = %v, want ErrDecrypt", size, err)
+		}
+	}
+
+	var sealed bytes.Buffer
+	Encrypt(key, &sealed, bytes.NewReader([]byte("secret")))
+	if err := Decrypt(newKey(t), &bytes.Buffer{}, &sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Decrypt() with another key error = %v, want ErrDecrypt", err)
+	}
+	if err := Encrypt(key[:16], &bytes.Buffer{}, bytes.NewReader(nil)); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Encrypt() with a short key error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func aeadOverhead(t *testing.T) int {
+	aead, err := newAEAD(newKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead.Overhead()
+}
+
+func TestParseKey(t *testing.T) {
+	key := newKey(t)
+	for _, data := range [][]byte{
+		[]byte(hex.EncodeToString(key) + "\n"),
+		[]byte("  " + hex.EncodeToString(key)),
+		key,
+	} {
+		got, err := ParseKey(data)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Errorf("ParseKey(%q) = %x, %v; want %x", data, got, err, key)
+		}
+	}
+	if _, err := ParseKey([]byte("abcd")); err == nil {
+		t.Error("ParseKey() expected an error for a short key")
+	}
+}
+
+func TestEncryptTree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"master.m3u8":             "#EXTM3U",
+		"stream_0/segment_000.ts": "segment",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := newKey(t)
+	encrypted, err := EncryptTree(key, dir)
+	if err != nil {
+		t.Fatalf("EncryptTree() unexpected error: %v", err)
+	}
+	if len(encrypted) != len(files) {
+		t.Errorf("EncryptTree() = %v, want %d files", encrypted, len(files))
+	}
+	for name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Plaintext %s still exists after EncryptTree()", name)
+		}
+		if _, err := os.Stat(path + Suffix); err != nil {
+			t.Errorf("Encrypted %s missing: %v", name, err)
+		}
+	}
+
+	if _, err := DecryptTree(key, dir); err != nil {
+		t.Fatalf("DecryptTree() unexpected error: %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Errorf("Decrypted %s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Cipher identifies the format of an encrypted input file.
+type Cipher string
+
+const (
+	// CipherHLSpresso is the chunked AES-256-GCM format written by Encrypt,
+	// e.g. an output of a previous job encrypted at rest.
+	CipherHLSpresso Cipher = "hlspresso"
+	// CipherAESCBC is AES-CBC with PKCS#7 padding and a 128, 192 or 256-bit
+	// key, as written by "openssl enc -aes-256-cbc -K <key> -iv <iv>". Without
+	// an explicit IV, the first 16 bytes of the file are the IV.
+	CipherAESCBC Cipher = "aes-cbc"
+)
+
+// ParseCipher validates a cipher name, case-insensitively. The empty string
+// selects CipherHLSpresso.
+func ParseCipher(value string) (Cipher, error) {
+	switch c := Cipher(strings.ToLower(strings.TrimSpace(value))); c {
+	case "":
+		return CipherHLSpresso, nil
+	case CipherHLSpresso, CipherAESCBC:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown cipher %q (expected hlspresso or aes-cbc)", value)
+	}
+}
+
+// ParseAESKey decodes an AES-128, AES-192 or AES-256 key given as hex, as
+// base64, or as raw bytes, like ParseKey.
+func ParseAESKey(data []byte) ([]byte, error) {
+	valid := func(key []byte) bool {
+		return len(key) == 16 || len(key) == 24 || len(key) == 32
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && valid(key) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && valid(key) {
+		return key, nil
+	}
+	if valid(data) {
+		return data, nil
+	}
+	return nil, fmt.Errorf("AES key must be 16, 24 or 32 bytes (32, 48 or 64 hex characters)")
+}
+
+// DecryptCBC reads a file encrypted with AES-CBC and PKCS#7 padding from src
+// and writes the plaintext to dst. If iv is nil, it is read from the first
+// aes.BlockSize bytes of src. It returns ErrDecrypt if the padding is invalid,
+// which is how a wrong key usually shows, in which case part of the plaintext
+// may already have been written.
+func DecryptCBC(key, iv []byte, dst io.Writer, src io.Reader) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+		if _, err := io.ReadFull(src, iv); err != nil {
+			return ErrDecrypt
+		}
+	}
+	if len(iv) != aes.BlockSize {
+		return fmt.Errorf("initialization vector must be %d bytes", aes.BlockSize)
+	}
+	mode := cipher.NewCBCDecrypter(block, iv)
+
+	// The last block is held back until EOF to remove its padding
+	buf := make([]byte, ChunkSize)
+	last := make([]byte, aes.BlockSize)
+	hasLast := false
+	for {
+		n, err := io.ReadFull(src, buf)
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return err
+		}
+		if n%aes.BlockSize != 0 {
+			return ErrDecrypt
+		}
+		if n > 0 {
+			if hasLast {
+				if _, err := dst.Write(last); err != nil {
+					return err
+				}
+			}
+			mode.CryptBlocks(buf[:n], buf[:n])
+			if _, err := dst.Write(buf[:n-aes.BlockSize]); err != nil {
+				return err
+			}
+			copy(last, buf[n-aes.BlockSize:n])
+			hasLast = true
+		}
+		if eof {
+			break
+		}
+	}
+
+	if !hasLast {
+		return ErrDecrypt
+	}
+	padding := int(last[aes.BlockSize-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return ErrDecrypt
+	}
+	for _, b := range last[aes.BlockSize-padding:] {
+		if int(b) != padding {
+			return ErrDecrypt
+		}
+	}
+	_, err = dst.Write(last[:aes.BlockSize-padding])
+	return err
+}
+
+// DecryptInput decrypts the file at src, encrypted with c, into dst. iv is
+// only used by CipherAESCBC. dst is written through a temporary file so that
+// it is never left incomplete.
+func DecryptInput(c Cipher, key, iv []byte, src, dst string) error {
+	return transformFile(src, dst, func(w io.Writer, r io.Reader) error {
+		if c == CipherAESCBC {
+			return DecryptCBC(key, iv, w, r)
+		}
+		return Decrypt(key, w, r)
+	})
+}
+
+// KeyProvider looks up the key of an encrypted input, e.g. in a key
+// management service, so keys do not have to be stored with the jobs.
+type KeyProvider interface {
+	// Key returns the key of input, the path or URL of the encrypted file.
+	Key(ctx context.Context, input string) ([]byte, error)
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface.
+type KeyProviderFunc func(ctx context.Context, input string) ([]byte, error)
+
+// Key implements KeyProvider.
+func (f KeyProviderFunc) Key(ctx context.Context, input string) ([]byte, error) {
+	return f(ctx, input)
+}
+
+// InputPlaceholder is replaced in HTTPKeyProvider URLs with the base name of
+// the input.
+const InputPlaceholder = "{input}"
+
+// maxKeyResponseSize is the maximum size of a key fetched by HTTPKeyProvider.
+const maxKeyResponseSize = 4096
+
+// HTTPKeyProvider is a KeyProvider fetching keys with GET requests, e.g. from
+// a key server in front of a KMS. The response body holds the key as hex,
+// base64 or raw bytes (see ParseAESKey).
+type HTTPKeyProvider struct {
+	// URL of the key. InputPlaceholder is replaced with the escaped base name
+	// of the input, e.g. "https://keys.example.com/mezzanine/{input}".
+	URL string
+	// Headers are added to every request, e.g. an Authorization header.
+	Headers map[string]string
+	// Client is the HTTP client used for the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Key implements KeyProvider.
+func (p HTTPKeyProvider) Key(ctx context.Context, input string) ([]byte, error) {
+	name := input
+	if u, err := url.Parse(input); err == nil && u.Scheme != "" && u.Host != "" {
+		name = u.Path
+	}
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	keyURL := strings.ReplaceAll(p.URL, InputPlaceholder, url.PathEscape(name))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range p.Headers {
+		req.Header.Set(name, value)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key server returned %s for %s", resp.Status, redactQuery(keyURL))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeyResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseAESKey(body)
+}
+
+// redactQuery removes the query string of rawURL, which may hold credentials.
+func redactQuery(rawURL string) string {
+	before, _, _ := strings.Cut(rawURL, "?")
+	return before
+}
@@ -0,0 +1,141 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encryptCBC encrypts plain like "openssl enc -aes-*-cbc": PKCS#7 padding,
+// with the IV prepended when prependIV is set.
+func encryptCBC(t *testing.T, key, iv, plain []byte, prependIV bool) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+	if prependIV {
+		return append(append([]byte{}, iv...), padded...)
+	}
+	return padded
+}
+
+func TestDecryptCBC(t *testing.T) {
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(iv)
+	for _, keySize := range []int{16, 32} {
+		key := make([]byte, keySize)
+		rand.Read(key)
+		for _, size := range []int{0, 15, 16, ChunkSize, 2*ChunkSize + 5} {
+			plain := make([]byte, size)
+			rand.Read(plain)
+
+			var opened bytes.Buffer
+			if err := DecryptCBC(key, iv, &opened, bytes.NewReader(encryptCBC(t, key, iv, plain, false))); err != nil {
+				t.Fatalf("DecryptCBC(%d-byte key, %d bytes) unexpected error: %v", keySize, size, err)
+			}
+			if !bytes.Equal(opened.Bytes(), plain) {
+				t.Errorf("DecryptCBC(%d-byte key, %d bytes) did not return the plaintext", keySize, size)
+			}
+
+			opened.Reset()
+			if err := DecryptCBC(key, nil, &opened, bytes.NewReader(encryptCBC(t, key, iv, plain, true))); err != nil || !bytes.Equal(opened.Bytes(), plain) {
+				t.Errorf("DecryptCBC(%d bytes) with the IV in the file failed: %v", size, err)
+			}
+		}
+	}
+
+	key := make([]byte, 16)
+	sealed := encryptCBC(t, key, iv, []byte("mezzanine"), false)
+	wrongKey := bytes.Repeat([]byte{1}, 16)
+	if err := DecryptCBC(wrongKey, iv, &bytes.Buffer{}, bytes.NewReader(sealed)); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptCBC() with the wrong key error = %v, want ErrDecrypt", err)
+	}
+	if err := DecryptCBC(key, iv, &bytes.Buffer{}, bytes.NewReader(sealed[:len(sealed)-3])); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptCBC() of a truncated file error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestDecryptInput(t *testing.T) {
+	dir := t.TempDir()
+	key := newKey(t)
+	src := filepath.Join(dir, "master.mov.enc")
+	var sealed bytes.Buffer
+	if err := Encrypt(key, &sealed, bytes.NewReader([]byte("mezzanine"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, sealed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "master.mov")
+	if err := DecryptInput(CipherHLSpresso, key, nil, src, dst); err != nil {
+		t.Fatalf("DecryptInput() unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(dst); string(content) != "mezzanine" {
+		t.Errorf("Decrypted input = %q, want the plaintext", content)
+	}
+	if err := DecryptInput(CipherAESCBC, key, nil, src, dst); err == nil {
+		t.Error("DecryptInput() expected an error for the wrong cipher")
+	}
+}
+
+func TestParseCipher(t *testing.T) {
+	for value, want := range map[string]Cipher{"": CipherHLSpresso, "AES-CBC": CipherAESCBC, "hlspresso": CipherHLSpresso} {
+		if got, err := ParseCipher(value); err != nil || got != want {
+			t.Errorf("ParseCipher(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseCipher("aes-ctr"); err == nil {
+		t.Error("ParseCipher(aes-ctr) expected an error")
+	}
+}
+
+func TestParseAESKey(t *testing.T) {
+	key128 := bytes.Repeat([]byte{0xab}, 16)
+	if got, err := ParseAESKey([]byte(hex.EncodeToString(key128) + "\n")); err != nil || !bytes.Equal(got, key128) {
+		t.Errorf("ParseAESKey(hex) = %x, %v", got, err)
+	}
+	if _, err := ParseAESKey([]byte("short")); err == nil {
+		t.Error("ParseAESKey() expected an error for a 5-byte key")
+	}
+}
+
+func TestHTTPKeyProvider(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/keys/movie one.mov.enc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(hex.EncodeToString(key)))
+	}))
+	defer server.Close()
+
+	provider := HTTPKeyProvider{URL: server.URL + "/keys/" + InputPlaceholder, Headers: map[string]string{"Authorization": "Bearer token"}}
+	got, err := provider.Key(context.Background(), "https://storage.example.com/masters/movie%20one.mov.enc?sig=secret")
+	if err != nil || !bytes.Equal(got, key) {
+		t.Errorf("Key() = %x, %v, want %x", got, err, key)
+	}
+
+	provider.Headers = nil
+	if _, err := provider.Key(context.Background(), "/masters/movie one.mov.enc"); err == nil {
+		t.Error("Key() expected an error when the key server rejects the request")
+	}
+}
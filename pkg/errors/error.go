@@ -2,7 +2,9 @@ package errors
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -20,8 +22,13 @@ const (
 	ValidationError ErrorType = "validation_error"
 	// SystemError represents underlying system issues, such as file I/O errors or command execution problems (excluding FFmpeg transcoding itself).
 	SystemError ErrorType = "system_error"
+	// UnknownError describes a failure that was not classified by the component that reported it.
+	UnknownError ErrorType = "unknown_error"
 )
 
+// FFmpegOutputLines is the number of trailing ffmpeg output lines kept by WithFFmpegOutput.
+const FFmpegOutputLines = 10
+
 // StructuredError represents a detailed error originating from HLSpresso operations.
 // It includes a type, message, optional details, timestamp, and a specific error code.
 // It implements the standard Go `error` interface.
@@ -36,6 +43,60 @@ type StructuredError struct {
 	Timestamp string `json:"timestamp"`
 	// Code provides a specific integer code unique to the error source within its type.
 	Code int `json:"code"`
+	// FFmpegOutput holds the last lines ffmpeg printed before failing, when the
+	// error comes from an ffmpeg run.
+	FFmpegOutput string `json:"ffmpeg_output,omitempty"`
+	// JobID identifies the job that failed, so that errors of concurrent jobs
+	// can be told apart (see WithJobID).
+	JobID string `json:"job_id,omitempty"`
+	// Fields lists the invalid fields of a validation error, so that every
+	// problem of a configuration can be fixed at once.
+	Fields []FieldError `json:"fields,omitempty"`
+	// Stage is the step a TimeoutError stopped the job in, e.g. "download"
+	// or "encode", to tell where it was stuck.
+	Stage string `json:"stage,omitempty"`
+	// DecodeErrors lists the errors found by a decode pass over a corrupted
+	// input, with the position they were found at.
+	DecodeErrors []DecodeError `json:"decode_errors,omitempty"`
+}
+
+// DecodeError is an error ffmpeg reported while decoding an input.
+type DecodeError struct {
+	// Time is the position of the input decoded when the error was reported,
+	// in seconds. Zero if the error was reported before the first frame.
+	Time float64 `json:"time"`
+	// Message is the ffmpeg error line, e.g.
+	// "[h264 @ 0x5581] error while decoding MB 12 34, bytestream -7".
+	Message string `json:"message"`
+}
+
+// String returns the error prefixed with its position, e.g.
+// "[00:01:02.50] [h264 @ 0x5581] error while decoding MB 12 34".
+func (d DecodeError) String() string {
+	seconds := d.Time
+	hours := int(seconds / 3600)
+	seconds -= float64(hours * 3600)
+	minutes := int(seconds / 60)
+	seconds -= float64(minutes * 60)
+	return fmt.Sprintf("[%02d:%02d:%05.2f] %s", hours, minutes, seconds, d.Message)
+}
+
+// FieldError describes the problem of one invalid field of a configuration.
+type FieldError struct {
+	// Field is the name of the field, e.g. "HLSVersion" or "HLSMasterTags[1]".
+	Field string `json:"field"`
+	// Message is the concise description of the problem.
+	Message string `json:"message"`
+	// Details tells what was expected and what was received, if available.
+	Details string `json:"details,omitempty"`
+}
+
+// String returns the field and its problem on one line.
+func (f FieldError) String() string {
+	if f.Details == "" {
+		return fmt.Sprintf("%s: %s", f.Field, f.Message)
+	}
+	return fmt.Sprintf("%s: %s (%s)", f.Field, f.Message, f.Details)
 }
 
 // Error implements the standard `error` interface for StructuredError.
@@ -54,6 +115,32 @@ func (e *StructuredError) JSON() (string, error) {
 	return string(data), nil
 }
 
+// WithFFmpegOutput sets FFmpegOutput to the last FFmpegOutputLines non-empty
+// lines of output and returns e.
+func (e *StructuredError) WithFFmpegOutput(output string) *StructuredError {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > FFmpegOutputLines {
+		lines = lines[len(lines)-FFmpegOutputLines:]
+	}
+	e.FFmpegOutput = strings.Join(lines, "\n")
+	return e
+}
+
+// WithJobID sets the JobID of the StructuredError in err's chain, if any, and
+// returns err. Other errors are returned unchanged.
+func WithJobID(err error, jobID string) error {
+	var sErr *StructuredError
+	if stderrors.As(err, &sErr) {
+		sErr.JobID = jobID
+	}
+	return err
+}
+
 // New creates a new StructuredError instance.
 // It automatically sets the Timestamp to the current time.
 func New(errorType ErrorType, message, details string, code int) *StructuredError {
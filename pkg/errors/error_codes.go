@@ -22,6 +22,8 @@ const (
 	ErrInvalidFileFormat     = 1300
 	ErrUnsupportedFileFormat = 1301
 	ErrCorruptedFile         = 1302
+	ErrBlackInput            = 1303
+	ErrSilentInput           = 1304
 
 	// Códigos de erro para PermissionError (1400-1499)
 	ErrPermissionDenied      = 1400
@@ -47,4 +49,25 @@ const (
 	ErrInvalidResolution     = 1801
 	ErrResolutionTooHigh     = 1802
 	ErrResolutionTooLow      = 1803
+
+	// Códigos de erro para timeouts de etapa (1900-1999)
+	ErrDownloadTimeout       = 1900
+	ErrProbeTimeout          = 1901
+	ErrEncodeTimeout         = 1902
+	ErrJobTimeout            = 1903
+	ErrFFmpegStalled         = 1904
+
+	// Códigos de erro para limites do input (2000-2099)
+	ErrInputTooLong          = 2000
+	ErrInputTooLarge         = 2001
+
+	// Códigos de erro para a criptografia das saídas (2100-2199)
+	ErrEncryptionFailed      = 2100
+
+	// Códigos de erro para o pós-processamento das playlists HLS (2200-2299)
+	ErrPlaylistConversionFailed = 2200
+	ErrPlaylistVersionFailed = 2201
+	ErrBandwidthUpdateFailed = 2202
+	ErrAudioCodecSignalFailed = 2203
+	ErrMasterTagsFailed      = 2204
 )
@@ -1,58 +1,191 @@
 package errors
 
-// Mensagens de erro padronizadas para cada código
+import (
+	stderrors "errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the language of the error messages when no locale is configured.
+const DefaultLocale = "en"
+
+// LocaleEnv is the environment variable that selects the language of the error
+// messages, e.g. HLSPRESSO_LANG=pt or HLSPRESSO_LANG=pt_BR.UTF-8.
+const LocaleEnv = "HLSPRESSO_LANG"
+
+// ErrorMessages holds the standard (English) message of each error code.
 var ErrorMessages = map[int]string{
 	// NetworkError
-	ErrNetworkConnectionFailed: "Erro de rede ao tentar acessar o arquivo. Verifique sua conexão e tente novamente.",
-	ErrNetworkTimeout:          "Tempo limite de rede excedido. Verifique sua conexão e tente novamente.",
-	ErrNetworkDNSFailure:       "Falha na resolução DNS. Verifique o endereço do servidor e tente novamente.",
-	ErrNetworkServerUnavailable: "Servidor indisponível. Tente novamente mais tarde.",
-	
+	ErrNetworkConnectionFailed:  "Network error while accessing the file. Check your connection and try again.",
+	ErrNetworkTimeout:           "Network timeout exceeded. Check your connection and try again.",
+	ErrNetworkDNSFailure:        "DNS resolution failed. Check the server address and try again.",
+	ErrNetworkServerUnavailable: "Server unavailable. Try again later.",
+
 	// DiskSpaceError
-	ErrDiskSpaceInsufficient:   "Espaço insuficiente no disco para processar o arquivo. Libere espaço e tente novamente.",
-	ErrDiskQuotaExceeded:       "Cota de disco excedida. Libere espaço ou ajuste sua cota.",
-	ErrDiskWriteFailed:         "Falha ao escrever no disco. Verifique as permissões e o espaço disponível.",
-	
+	ErrDiskSpaceInsufficient: "Not enough disk space to process the file. Free up space and try again.",
+	ErrDiskQuotaExceeded:     "Disk quota exceeded. Free up space or adjust your quota.",
+	ErrDiskWriteFailed:       "Failed to write to disk. Check the permissions and the available space.",
+
 	// FileNotFoundError
-	ErrFileNotFound:            "Arquivo não encontrado. Verifique o caminho e se o arquivo está acessível.",
-	ErrFileNotAccessible:       "Arquivo inacessível. Verifique as permissões e se o arquivo existe.",
-	ErrDirectoryNotFound:       "Diretório não encontrado. Verifique o caminho e se o diretório existe.",
-	
+	ErrFileNotFound:      "File not found. Check the path and that the file is accessible.",
+	ErrFileNotAccessible: "File not accessible. Check the permissions and that the file exists.",
+	ErrDirectoryNotFound: "Directory not found. Check the path and that the directory exists.",
+
 	// InvalidFileFormatError
-	ErrInvalidFileFormat:       "Formato de arquivo inválido. Somente MP4, MOV, AVI, MKV, WEBM são suportados.",
-	ErrUnsupportedFileFormat:   "Formato de arquivo não suportado. Utilize um dos formatos compatíveis.",
-	ErrCorruptedFile:           "O arquivo parece estar corrompido. Verifique a integridade do arquivo.",
-	
+	ErrInvalidFileFormat:     "Invalid file format. Only MP4, MOV, AVI, MKV and WEBM are supported.",
+	ErrUnsupportedFileFormat: "Unsupported file format. Use one of the supported formats.",
+	ErrCorruptedFile:         "The file seems to be corrupted. Check the integrity of the file.",
+	ErrBlackInput:            "The video of the input is entirely black. Check that the right file was uploaded.",
+	ErrSilentInput:           "The audio of the input is entirely silent. Check that the right file was uploaded.",
+
 	// PermissionError
-	ErrPermissionDenied:        "Permissão negada. Verifique as permissões de leitura/gravação no arquivo ou diretório.",
-	ErrReadPermissionDenied:    "Permissão de leitura negada. Verifique as permissões do arquivo.",
-	ErrWritePermissionDenied:   "Permissão de escrita negada. Verifique as permissões do diretório de destino.",
-	
+	ErrPermissionDenied:      "Permission denied. Check the read/write permissions of the file or directory.",
+	ErrReadPermissionDenied:  "Read permission denied. Check the permissions of the file.",
+	ErrWritePermissionDenied: "Write permission denied. Check the permissions of the destination directory.",
+
 	// MemoryError
-	ErrOutOfMemory:             "Memória insuficiente para processar o arquivo. Tente reduzir a resolução ou o tamanho do arquivo.",
-	ErrMemoryAllocationFailed:  "Falha na alocação de memória. Tente fechar outros aplicativos ou processos.",
-	
+	ErrOutOfMemory:            "Not enough memory to process the file. Try reducing the resolution or the file size.",
+	ErrMemoryAllocationFailed: "Memory allocation failed. Try closing other applications or processes.",
+
 	// CodecNotFoundError
-	ErrCodecNotFound:           "Codec necessário não encontrado. Certifique-se de que o codec necessário está instalado.",
-	ErrCodecNotSupported:       "Codec não suportado nesta plataforma.",
-	ErrMissingDependency:       "Dependência necessária não encontrada. Verifique a instalação do FFmpeg.",
-	
+	ErrCodecNotFound:     "Required codec not found. Make sure the required codec is installed.",
+	ErrCodecNotSupported: "Codec not supported on this platform.",
+	ErrMissingDependency: "Required dependency not found. Check the FFmpeg installation.",
+
 	// InvalidOutputPathError
-	ErrInvalidOutputPath:       "Caminho de saída inválido ou inacessível. Verifique as permissões e tente novamente.",
-	ErrOutputPathNotAccessible: "Caminho de saída inacessível. Verifique as permissões e se o diretório existe.",
-	ErrOutputDirectoryCreationFailed: "Falha ao criar diretório de saída. Verifique as permissões.",
-	
+	ErrInvalidOutputPath:             "Invalid or inaccessible output path. Check the permissions and try again.",
+	ErrOutputPathNotAccessible:       "Output path not accessible. Check the permissions and that the directory exists.",
+	ErrOutputDirectoryCreationFailed: "Failed to create the output directory. Check the permissions.",
+
 	// UnsupportedResolutionError
-	ErrUnsupportedResolution:   "Resolução de vídeo não suportada. Tente uma resolução compatível.",
-	ErrInvalidResolution:       "Resolução de vídeo inválida. Use uma resolução válida.",
-	ErrResolutionTooHigh:       "Resolução de vídeo muito alta. Use uma resolução menor.",
-	ErrResolutionTooLow:        "Resolução de vídeo muito baixa. Use uma resolução maior.",
+	ErrUnsupportedResolution: "Unsupported video resolution. Try a compatible resolution.",
+	ErrInvalidResolution:     "Invalid video resolution. Use a valid resolution.",
+	ErrResolutionTooHigh:     "Video resolution too high. Use a lower resolution.",
+	ErrResolutionTooLow:      "Video resolution too low. Use a higher resolution.",
+
+	// Stage timeouts
+	ErrDownloadTimeout: "The download took longer than the download timeout. Check the connection or raise the timeout.",
+	ErrProbeTimeout:    "Reading the input information took longer than the probe timeout. Check the input or raise the timeout.",
+	ErrEncodeTimeout:   "The encode took longer than the encode timeout. Use a faster preset or raise the timeout.",
+	ErrJobTimeout:      "The job took longer than its maximum duration and was stopped. Check the stage it was stuck in or raise the limit.",
+	ErrFFmpegStalled:   "FFmpeg stopped making progress and was stopped. Check that the input is reachable or raise the stall timeout.",
+
+	// Input limits
+	ErrInputTooLong:  "The input is longer than the maximum input duration. Trim the input or raise the limit.",
+	ErrInputTooLarge: "The input is larger than the maximum input size. Compress the input or raise the limit.",
+
+	// Output encryption
+	ErrEncryptionFailed: "Failed to encrypt the output. Check the encryption key.",
+
+	// HLS playlist post-processing
+	ErrPlaylistConversionFailed: "Failed to convert the EVENT playlists to VOD.",
+	ErrPlaylistVersionFailed:    "Failed to set the HLS version of the playlists.",
+	ErrBandwidthUpdateFailed:    "Failed to update the bandwidth of the master playlist.",
+	ErrAudioCodecSignalFailed:   "Failed to signal the audio codec in the master playlist.",
+	ErrMasterTagsFailed:         "Failed to add the session data and custom tags to the master playlist.",
+}
+
+// catalogs holds the messages of each supported language, by ISO 639-1 code.
+// Codes missing from a catalog fall back to ErrorMessages.
+var catalogs = map[string]map[int]string{
+	"en": ErrorMessages,
+	"pt": portugueseMessages,
+}
+
+// unknownMessages is the message of codes missing from every catalog, by language.
+var unknownMessages = map[string]string{
+	"en": "Unknown error.",
+	"pt": "Erro desconhecido.",
+}
+
+var (
+	localeMu sync.RWMutex
+	locale   = DefaultLocale
+)
+
+func init() {
+	SetLocale(os.Getenv(LocaleEnv))
 }
 
-// GetErrorMessage retorna a mensagem de erro padronizada para um código de erro
+// Locales returns the supported languages, sorted.
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for l := range catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// NormalizeLocale returns the supported language of a locale such as "pt",
+// "pt-BR" or "pt_BR.UTF-8", or DefaultLocale if it is empty or not supported.
+func NormalizeLocale(l string) string {
+	l = strings.ToLower(strings.TrimSpace(l))
+	if i := strings.IndexAny(l, "_-."); i >= 0 {
+		l = l[:i]
+	}
+	if _, ok := catalogs[l]; ok {
+		return l
+	}
+	return DefaultLocale
+}
+
+// SetLocale sets the language of the messages returned by GetErrorMessage.
+// Unsupported locales select DefaultLocale. The initial locale is read from
+// the LocaleEnv environment variable.
+func SetLocale(l string) {
+	localeMu.Lock()
+	defer localeMu.Unlock()
+	locale = NormalizeLocale(l)
+}
+
+// Locale returns the language of the messages returned by GetErrorMessage.
+func Locale() string {
+	localeMu.RLock()
+	defer localeMu.RUnlock()
+	return locale
+}
+
+// GetErrorMessage returns the standard message for an error code in the
+// current Locale.
 func GetErrorMessage(code int) string {
+	return GetLocalizedErrorMessage(Locale(), code)
+}
+
+// GetLocalizedErrorMessage returns the standard message for an error code in
+// the given locale, falling back to English when it has no translation.
+func GetLocalizedErrorMessage(l string, code int) string {
+	l = NormalizeLocale(l)
+	if msg, ok := catalogs[l][code]; ok {
+		return msg
+	}
 	if msg, ok := ErrorMessages[code]; ok {
 		return msg
 	}
-	return "Erro desconhecido."
-}
\ No newline at end of file
+	return unknownMessages[l]
+}
+
+// Localize translates the message of e into locale l when it is the standard
+// message of e.Code in any language. Custom messages are left unchanged.
+// It returns e.
+func (e *StructuredError) Localize(l string) *StructuredError {
+	for _, catalog := range catalogs {
+		if msg, ok := catalog[e.Code]; ok && msg == e.Message {
+			e.Message = GetLocalizedErrorMessage(l, e.Code)
+			break
+		}
+	}
+	return e
+}
+
+// Localize translates the message of the StructuredError err is or wraps into
+// locale l (see StructuredError.Localize) and returns err.
+func Localize(err error, l string) error {
+	var sErr *StructuredError
+	if stderrors.As(err, &sErr) {
+		sErr.Localize(l)
+	}
+	return err
+}
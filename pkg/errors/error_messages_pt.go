@@ -0,0 +1,73 @@
+package errors
+
+// portugueseMessages holds the Portuguese message of each error code.
+var portugueseMessages = map[int]string{
+	// NetworkError
+	ErrNetworkConnectionFailed:  "Erro de rede ao tentar acessar o arquivo. Verifique sua conexão e tente novamente.",
+	ErrNetworkTimeout:           "Tempo limite de rede excedido. Verifique sua conexão e tente novamente.",
+	ErrNetworkDNSFailure:        "Falha na resolução DNS. Verifique o endereço do servidor e tente novamente.",
+	ErrNetworkServerUnavailable: "Servidor indisponível. Tente novamente mais tarde.",
+
+	// DiskSpaceError
+	ErrDiskSpaceInsufficient: "Espaço insuficiente no disco para processar o arquivo. Libere espaço e tente novamente.",
+	ErrDiskQuotaExceeded:     "Cota de disco excedida. Libere espaço ou ajuste sua cota.",
+	ErrDiskWriteFailed:       "Falha ao escrever no disco. Verifique as permissões e o espaço disponível.",
+
+	// FileNotFoundError
+	ErrFileNotFound:      "Arquivo não encontrado. Verifique o caminho e se o arquivo está acessível.",
+	ErrFileNotAccessible: "Arquivo inacessível. Verifique as permissões e se o arquivo existe.",
+	ErrDirectoryNotFound: "Diretório não encontrado. Verifique o caminho e se o diretório existe.",
+
+	// InvalidFileFormatError
+	ErrInvalidFileFormat:     "Formato de arquivo inválido. Somente MP4, MOV, AVI, MKV, WEBM são suportados.",
+	ErrUnsupportedFileFormat: "Formato de arquivo não suportado. Utilize um dos formatos compatíveis.",
+	ErrCorruptedFile:         "O arquivo parece estar corrompido. Verifique a integridade do arquivo.",
+	ErrBlackInput:            "O vídeo da entrada é inteiramente preto. Verifique se o arquivo certo foi enviado.",
+	ErrSilentInput:           "O áudio da entrada é inteiramente silencioso. Verifique se o arquivo certo foi enviado.",
+
+	// PermissionError
+	ErrPermissionDenied:      "Permissão negada. Verifique as permissões de leitura/gravação no arquivo ou diretório.",
+	ErrReadPermissionDenied:  "Permissão de leitura negada. Verifique as permissões do arquivo.",
+	ErrWritePermissionDenied: "Permissão de escrita negada. Verifique as permissões do diretório de destino.",
+
+	// MemoryError
+	ErrOutOfMemory:            "Memória insuficiente para processar o arquivo. Tente reduzir a resolução ou o tamanho do arquivo.",
+	ErrMemoryAllocationFailed: "Falha na alocação de memória. Tente fechar outros aplicativos ou processos.",
+
+	// CodecNotFoundError
+	ErrCodecNotFound:     "Codec necessário não encontrado. Certifique-se de que o codec necessário está instalado.",
+	ErrCodecNotSupported: "Codec não suportado nesta plataforma.",
+	ErrMissingDependency: "Dependência necessária não encontrada. Verifique a instalação do FFmpeg.",
+
+	// InvalidOutputPathError
+	ErrInvalidOutputPath:             "Caminho de saída inválido ou inacessível. Verifique as permissões e tente novamente.",
+	ErrOutputPathNotAccessible:       "Caminho de saída inacessível. Verifique as permissões e se o diretório existe.",
+	ErrOutputDirectoryCreationFailed: "Falha ao criar diretório de saída. Verifique as permissões.",
+
+	// UnsupportedResolutionError
+	ErrUnsupportedResolution: "Resolução de vídeo não suportada. Tente uma resolução compatível.",
+	ErrInvalidResolution:     "Resolução de vídeo inválida. Use uma resolução válida.",
+	ErrResolutionTooHigh:     "Resolução de vídeo muito alta. Use uma resolução menor.",
+	ErrResolutionTooLow:      "Resolução de vídeo muito baixa. Use uma resolução maior.",
+
+	// Timeouts de etapa
+	ErrDownloadTimeout: "O download excedeu o tempo limite de download. Verifique a conexão ou aumente o tempo limite.",
+	ErrProbeTimeout:    "A leitura das informações da entrada excedeu o tempo limite de análise. Verifique a entrada ou aumente o tempo limite.",
+	ErrEncodeTimeout:   "A codificação excedeu o tempo limite de codificação. Use um preset mais rápido ou aumente o tempo limite.",
+	ErrJobTimeout:      "O job excedeu sua duração máxima e foi interrompido. Verifique a etapa em que ele travou ou aumente o limite.",
+	ErrFFmpegStalled:   "O FFmpeg parou de progredir e foi interrompido. Verifique se a entrada está acessível ou aumente o tempo limite de travamento.",
+
+	// Limites do input
+	ErrInputTooLong:  "A entrada é mais longa que a duração máxima permitida. Corte a entrada ou aumente o limite.",
+	ErrInputTooLarge: "A entrada é maior que o tamanho máximo permitido. Comprima a entrada ou aumente o limite.",
+
+	// Criptografia das saídas
+	ErrEncryptionFailed: "Falha ao criptografar a saída. Verifique a chave de criptografia.",
+
+	// Pós-processamento das playlists HLS
+	ErrPlaylistConversionFailed: "Falha ao converter as playlists EVENT em VOD.",
+	ErrPlaylistVersionFailed:    "Falha ao definir a versão HLS das playlists.",
+	ErrBandwidthUpdateFailed:    "Falha ao atualizar a largura de banda do master playlist.",
+	ErrAudioCodecSignalFailed:   "Falha ao sinalizar o codec de áudio no master playlist.",
+	ErrMasterTagsFailed:         "Falha ao adicionar os dados de sessão e as tags personalizadas ao master playlist.",
+}
@@ -0,0 +1,71 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"":            DefaultLocale,
+		"en":          "en",
+		"pt":          "pt",
+		"pt_BR.UTF-8": "pt",
+		"PT-br":       "pt",
+		"fr_FR":       DefaultLocale,
+	}
+	for input, want := range tests {
+		if got := NormalizeLocale(input); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for _, l := range Locales() {
+		for code := range ErrorMessages {
+			if _, ok := catalogs[l][code]; !ok {
+				t.Errorf("Locale %q has no message for code %d", l, code)
+			}
+		}
+		if unknownMessages[l] == "" {
+			t.Errorf("Locale %q has no unknown error message", l)
+		}
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	defer SetLocale(Locale())
+
+	SetLocale("pt")
+	if got := GetErrorMessage(ErrFileNotFound); got != portugueseMessages[ErrFileNotFound] {
+		t.Errorf("GetErrorMessage() = %q, want the Portuguese message", got)
+	}
+	if got := GetErrorMessage(-1); got != "Erro desconhecido." {
+		t.Errorf("GetErrorMessage(-1) = %q, want the Portuguese unknown message", got)
+	}
+
+	SetLocale("de")
+	if got := GetErrorMessage(ErrFileNotFound); got != ErrorMessages[ErrFileNotFound] {
+		t.Errorf("GetErrorMessage() = %q, want the English message", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	err := New(NetworkError, ErrorMessages[ErrNetworkTimeout], "i/o timeout", ErrNetworkTimeout)
+	wrapped := fmt.Errorf("download: %w", err)
+
+	Localize(wrapped, "pt")
+	if err.Message != portugueseMessages[ErrNetworkTimeout] || err.Code != ErrNetworkTimeout {
+		t.Errorf("Localize() = %+v, want the Portuguese message and the same code", err)
+	}
+	err.Localize("en")
+	if err.Message != ErrorMessages[ErrNetworkTimeout] {
+		t.Errorf("Localize() message = %q, want the English message", err.Message)
+	}
+
+	custom := New(NetworkError, "Custom message", "", ErrNetworkTimeout).Localize("pt")
+	if custom.Message != "Custom message" {
+		t.Errorf("Localize() changed a custom message to %q", custom.Message)
+	}
+}
@@ -3,6 +3,8 @@ package errors
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -89,3 +91,37 @@ func TestWrap(t *testing.T) {
 		t.Errorf("Details = %q, want empty string", nilWrapped.Details)
 	}
 }
+
+func TestWithFFmpegOutput(t *testing.T) {
+	var output []string
+	for i := 1; i <= 15; i++ {
+		output = append(output, fmt.Sprintf("line %d", i))
+	}
+	err := New(TranscodingError, "FFmpeg failed", "exit status 1", 13).WithFFmpegOutput(strings.Join(output, "\r\n") + "\n\n")
+
+	lines := strings.Split(err.FFmpegOutput, "\n")
+	if len(lines) != FFmpegOutputLines || lines[0] != "line 6" || lines[len(lines)-1] != "line 15" {
+		t.Errorf("FFmpegOutput = %q, want the last %d lines", err.FFmpegOutput, FFmpegOutputLines)
+	}
+
+	jsonStr, _ := err.JSON()
+	if !strings.Contains(jsonStr, `"ffmpeg_output":"line 6\nline 7`) {
+		t.Errorf("JSON() = %s, want ffmpeg_output", jsonStr)
+	}
+}
+
+func TestWithJobID(t *testing.T) {
+	sErr := New(TranscodingError, "FFmpeg failed", "exit status 1", 13)
+	wrapped := fmt.Errorf("transcode: %w", sErr)
+	if got := WithJobID(wrapped, "job-42"); got != wrapped || sErr.JobID != "job-42" {
+		t.Errorf("WithJobID() = %v with JobID %q, want the same error with JobID job-42", got, sErr.JobID)
+	}
+	if jsonStr, _ := sErr.JSON(); !strings.Contains(jsonStr, `"job_id":"job-42"`) {
+		t.Errorf("JSON() = %s, want job_id", jsonStr)
+	}
+
+	plain := errors.New("plain")
+	if got := WithJobID(plain, "job-42"); got != plain {
+		t.Errorf("WithJobID() = %v, want the plain error unchanged", got)
+	}
+}
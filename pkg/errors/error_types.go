@@ -26,3 +26,9 @@ const InvalidOutputPathError ErrorType = "invalid_output_path_error"
 
 // UnsupportedResolutionError indica resolução de vídeo não suportada
 const UnsupportedResolutionError ErrorType = "unsupported_resolution_error"
+
+// TimeoutError indica um job interrompido por exceder sua duração máxima
+const TimeoutError ErrorType = "timeout_error"
+
+// StallError indica um processo FFmpeg que parou de produzir saída
+const StallError ErrorType = "stall_error"
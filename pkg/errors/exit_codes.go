@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"io/fs"
+	"syscall"
+)
+
+// Process exit codes returned by the HLSpresso CLI. Each failure class owns a
+// range of ten codes so that shell-based orchestrators can branch on
+// `code / 10` and still tell the individual error types apart.
+const (
+	// ExitOK reports success.
+	ExitOK = 0
+	// ExitFailure reports an error that carries no StructuredError type.
+	ExitFailure = 1
+	// ExitUsage reports invalid command line usage (unknown flags, missing arguments).
+	ExitUsage = 2
+
+	// ExitValidation reports invalid options or configuration (ValidationError).
+	ExitValidation = 10
+	// ExitFileNotFound reports a missing input file (FileNotFoundError).
+	ExitFileNotFound = 11
+	// ExitInvalidFileFormat reports an unreadable or unsupported input (InvalidFileFormatError).
+	ExitInvalidFileFormat = 12
+	// ExitUnsupportedResolution reports an unusable resolution (UnsupportedResolutionError).
+	ExitUnsupportedResolution = 13
+	// ExitInvalidOutputPath reports an unusable output path (InvalidOutputPathError).
+	ExitInvalidOutputPath = 14
+
+	// ExitNetwork reports a network failure (NetworkError).
+	ExitNetwork = 20
+	// ExitDownload reports a failed download of a remote input (DownloadError).
+	ExitDownload = 21
+
+	// ExitTranscoding reports a failed FFmpeg run (TranscodingError).
+	ExitTranscoding = 30
+	// ExitHLS reports a failed playlist or segment generation (HLSError).
+	ExitHLS = 31
+	// ExitCodecNotFound reports a missing FFmpeg binary, codec or dependency (CodecNotFoundError).
+	ExitCodecNotFound = 32
+
+	// ExitDiskSpace reports insufficient disk space (DiskSpaceError).
+	ExitDiskSpace = 40
+	// ExitPermission reports a denied read or write (PermissionError).
+	ExitPermission = 41
+
+	// ExitSystem reports an underlying system failure (SystemError).
+	ExitSystem = 50
+	// ExitMemory reports an out of memory condition (MemoryError).
+	ExitMemory = 51
+
+	// ExitTimeout reports a job stopped after its maximum duration (TimeoutError).
+	ExitTimeout = 60
+	// ExitStalled reports an encode stopped because ffmpeg stalled (StallError).
+	ExitStalled = 61
+
+	// ExitInterrupted reports an operation cancelled by SIGINT or SIGTERM,
+	// following the shell convention of 128 + SIGINT.
+	ExitInterrupted = 130
+)
+
+// exitCodes maps each error type to its process exit code.
+var exitCodes = map[ErrorType]int{
+	ValidationError:            ExitValidation,
+	FileNotFoundError:          ExitFileNotFound,
+	InvalidFileFormatError:     ExitInvalidFileFormat,
+	UnsupportedResolutionError: ExitUnsupportedResolution,
+	InvalidOutputPathError:     ExitInvalidOutputPath,
+	NetworkError:               ExitNetwork,
+	DownloadError:              ExitDownload,
+	TranscodingError:           ExitTranscoding,
+	HLSError:                   ExitHLS,
+	CodecNotFoundError:         ExitCodecNotFound,
+	DiskSpaceError:             ExitDiskSpace,
+	PermissionError:            ExitPermission,
+	SystemError:                ExitSystem,
+	MemoryError:                ExitMemory,
+	TimeoutError:               ExitTimeout,
+	StallError:                 ExitStalled,
+}
+
+// ExitCode returns the process exit code for the error type.
+// Unknown types map to ExitFailure.
+func (t ErrorType) ExitCode() int {
+	if code, ok := exitCodes[t]; ok {
+		return code
+	}
+	return ExitFailure
+}
+
+// ExitCode returns the process exit code for err: ExitOK for nil,
+// ExitInterrupted for a cancelled context, the code of the first
+// StructuredError in the chain, the code of the matching class for missing
+// files, denied permissions and full disks, and ExitFailure otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if stderrors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+	var structured *StructuredError
+	if stderrors.As(err, &structured) {
+		return structured.Type.ExitCode()
+	}
+	switch {
+	case stderrors.Is(err, fs.ErrNotExist):
+		return ExitFileNotFound
+	case stderrors.Is(err, fs.ErrPermission):
+		return ExitPermission
+	case stderrors.Is(err, syscall.ENOSPC):
+		return ExitDiskSpace
+	}
+	return ExitFailure
+}
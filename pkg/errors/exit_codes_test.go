@@ -0,0 +1,46 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitFailure},
+		{"validation", New(ValidationError, "Invalid", "", 1), ExitValidation},
+		{"network", New(NetworkError, "Offline", "", ErrNetworkConnectionFailed), ExitNetwork},
+		{"transcoding", New(TranscodingError, "FFmpeg failed", "", 1), ExitTranscoding},
+		{"disk space", New(DiskSpaceError, "Full", "", ErrDiskSpaceInsufficient), ExitDiskSpace},
+		{"job timeout", New(TimeoutError, "Too long", "", ErrJobTimeout), ExitTimeout},
+		{"stall", New(StallError, "Stalled", "", ErrFFmpegStalled), ExitStalled},
+		{"wrapped", fmt.Errorf("context: %w", New(DownloadError, "Failed", "", 1)), ExitDownload},
+		{"unknown type", New(ErrorType("other_error"), "Other", "", 1), ExitFailure},
+		{"missing file", &fs.PathError{Op: "open", Path: "in.mp4", Err: fs.ErrNotExist}, ExitFileNotFound},
+		{"permission", &fs.PathError{Op: "open", Path: "out", Err: fs.ErrPermission}, ExitPermission},
+		{"cancelled", fmt.Errorf("stopped: %w", context.Canceled), ExitInterrupted},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExitCodesAreDistinct(t *testing.T) {
+	seen := map[int]ErrorType{}
+	for errorType, code := range exitCodes {
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s share exit code %d", errorType, other, code)
+		}
+		seen[code] = errorType
+	}
+}
@@ -0,0 +1,58 @@
+package ffmpeg
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// GracePeriod is how long a cancelled ffmpeg/ffprobe process is given to exit
+// after being asked to stop before it is forcefully killed.
+var GracePeriod = 5 * time.Second
+
+// CommandContext works like exec.CommandContext, but when the context is done the
+// process is first asked to stop gracefully (SIGINT on Unix-like systems, which
+// lets ffmpeg finalize the files it is writing) and only killed if it has not
+// exited after GracePeriod. On Unix-like systems the process runs in its own
+// process group, which is stopped as a whole, so that no child outlives it; the
+// caller is then responsible for cancelling ctx on SIGINT, which no longer
+// reaches the process from the terminal. On Windows, where console interrupts
+// cannot be sent to a single child process, the process is terminated immediately.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return commandContext(ctx, GracePeriod, name, args...)
+}
+
+// commandContext is CommandContext with the given grace period.
+func commandContext(ctx context.Context, grace time.Duration, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return interrupt(cmd, grace)
+	}
+	cmd.WaitDelay = grace
+	return cmd
+}
+
+// quitOnCancel makes cmd stop like an interactive ffmpeg whose user pressed
+// "q" when its context is done: ffmpeg then closes the segment it is writing
+// and finalizes its output, as on SIGINT, but also when its own signal
+// handling is unavailable. The process is killed if it has not exited after
+// grace. It must be called before cmd is started.
+func quitOnCancel(cmd *exec.Cmd, grace time.Duration) error {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Cancel = func() error {
+		_, err := io.WriteString(stdin, "q")
+		stdin.Close()
+		if err != nil {
+			// ffmpeg is not reading its input anymore, interrupt it instead
+			return interrupt(cmd, grace)
+		}
+		killAfter(cmd, grace)
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package ffmpeg
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup does nothing: process groups are not available.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// interrupt terminates the process; graceful interrupts are not available.
+func interrupt(cmd *exec.Cmd, grace time.Duration) error {
+	return cmd.Process.Kill()
+}
+
+// killAfter does nothing: exec.Cmd kills the process after its WaitDelay.
+func killAfter(cmd *exec.Cmd, grace time.Duration) {}
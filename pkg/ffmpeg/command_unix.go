@@ -0,0 +1,38 @@
+//go:build unix
+
+package ffmpeg
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts the process in its own process group, so that its
+// children (e.g. the commands of a hook shell) are stopped with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interrupt asks the process group to stop gracefully, and kills what is left
+// of it after grace.
+func interrupt(cmd *exec.Cmd, grace time.Duration) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGINT); err != nil {
+		if err == syscall.ESRCH {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	killAfter(cmd, grace)
+	return nil
+}
+
+// killAfter kills what is left of the process group after grace: exec.Cmd
+// only kills the process itself.
+func killAfter(cmd *exec.Cmd, grace time.Duration) {
+	pgid := cmd.Process.Pid
+	time.AfterFunc(grace, func() {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+}
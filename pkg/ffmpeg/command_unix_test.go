@@ -0,0 +1,132 @@
+//go:build linux
+
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandContextStopsProcessGroup(t *testing.T) {
+	defer func(period time.Duration) { GracePeriod = period }(GracePeriod)
+	GracePeriod = 100 * time.Millisecond
+
+	// The background sleep ignores SIGINT, like the children of a non-interactive shell
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := CommandContext(ctx, "sh", "-c", "sleep 30 & echo $!; wait")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sh is not available: %v", err)
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read the child PID: %v", err)
+	}
+	child, _ := strconv.Atoi(strings.TrimSpace(line))
+
+	cancel()
+	if err := cmd.Wait(); err == nil {
+		t.Error("Wait() expected an error after cancellation")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for running(child) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Child process %d outlived the cancelled command", child)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// running reports whether the process pid exists and is not a zombie.
+func running(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestExecRunnerLog(t *testing.T) {
+	var log strings.Builder
+	runner := ExecRunner{Log: &log}
+	output, err := runner.Output(context.Background(), "sh", "-c", "echo stdout; echo stderr >&2")
+	if err != nil || string(output) != "stdout\n" {
+		t.Fatalf("Output() = %q, %v", output, err)
+	}
+	if !strings.Contains(log.String(), "] stderr\n") || !strings.Contains(log.String(), "exited successfully") || strings.Contains(log.String(), "] stdout") {
+		t.Errorf("Log of Output =\n%s", log.String())
+	}
+
+	log.Reset()
+	proc, err := runner.Start(context.Background(), "sh", "-c", "echo progress >&2; exit 3")
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	stderr := new(strings.Builder)
+	bufio.NewReader(proc.Stderr()).WriteTo(stderr)
+	if err := proc.Wait(); err == nil || stderr.String() != "progress\n" {
+		t.Fatalf("Wait() = %v with stderr %q", err, stderr)
+	}
+	if !strings.Contains(log.String(), "] progress\n") || !strings.Contains(log.String(), "failed: exit status 3") {
+		t.Errorf("Log of Start =\n%s", log.String())
+	}
+}
+
+func TestExecRunnerGracefulStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := ExecRunner{GracefulStop: true, GracePeriod: 5 * time.Second}
+	proc, err := runner.Start(ctx, "sh", "-c", `trap "echo interrupted >&2; exit 1" INT; echo ready >&2; read -r c; echo "quit $c" >&2`)
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	stderr := bufio.NewReader(proc.Stderr())
+	if line, _ := stderr.ReadString('\n'); line != "ready\n" {
+		t.Fatalf("First line = %q", line)
+	}
+
+	cancel()
+	rest := new(strings.Builder)
+	stderr.WriteTo(rest)
+	if rest.String() != "quit q\n" {
+		t.Errorf("Output after cancellation = %q, want the process to quit on \"q\"", rest)
+	}
+	// The process exited by itself, but the encode did not complete
+	if err := proc.Wait(); err != context.Canceled {
+		t.Errorf("Wait() = %v, want context.Canceled", err)
+	}
+}
+
+func TestExecRunnerEnvAndDir(t *testing.T) {
+	t.Setenv("HLSPRESSO_INHERITED", "kept")
+	t.Setenv("HLSPRESSO_OVERRIDDEN", "inherited")
+	dir := t.TempDir()
+	runner := ExecRunner{Env: []string{"HLSPRESSO_OVERRIDDEN=job", "FONTCONFIG_PATH=/fonts"}, Dir: dir}
+
+	out, err := runner.Output(context.Background(), "sh", "-c", `echo "$HLSPRESSO_INHERITED $HLSPRESSO_OVERRIDDEN $FONTCONFIG_PATH"; pwd`)
+	if err != nil {
+		t.Fatalf("Output() error: %v", err)
+	}
+	want := "kept job /fonts\n" + dir + "\n"
+	if string(out) != want {
+		t.Errorf("Output() = %q, want %q", out, want)
+	}
+
+	proc, err := runner.Start(context.Background(), "sh", "-c", `echo "$FONTCONFIG_PATH $(pwd)" >&2`)
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	stderr := new(strings.Builder)
+	bufio.NewReader(proc.Stderr()).WriteTo(stderr)
+	if err := proc.Wait(); err != nil || stderr.String() != "/fonts "+dir+"\n" {
+		t.Errorf("Start() stderr = %q, %v", stderr, err)
+	}
+}
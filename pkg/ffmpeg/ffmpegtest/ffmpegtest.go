@@ -0,0 +1,98 @@
+// Package ffmpegtest provides a scripted ffmpeg.Runner for unit tests.
+package ffmpegtest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// Call records a command launched through a Runner.
+type Call struct {
+	// Name is the executable (e.g. "ffmpeg" or "ffprobe").
+	Name string
+	// Args are the command line arguments.
+	Args []string
+}
+
+// Result is the simulated outcome of a command.
+type Result struct {
+	// Stdout is returned by Runner.Output.
+	Stdout string
+	// Stderr is streamed by the Process returned from Runner.Start
+	// (e.g. ffmpeg progress lines or error messages).
+	Stderr string
+	// Err is returned by Output or by Process.Wait.
+	Err error
+	// StartErr, if set, is returned by Start instead of a Process
+	// (e.g. to simulate a missing binary).
+	StartErr error
+}
+
+// Runner is an ffmpeg.Runner that records the commands it receives and
+// returns scripted results instead of executing processes.
+// The zero value is ready to use; every command succeeds with no output.
+type Runner struct {
+	// Handler returns the result for a command. If nil, commands succeed with no output.
+	Handler func(name string, args []string) Result
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Output implements ffmpeg.Runner.
+func (r *Runner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	res := r.run(name, args)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []byte(res.Stdout), res.Err
+}
+
+// Start implements ffmpeg.Runner.
+func (r *Runner) Start(ctx context.Context, name string, args ...string) (ffmpeg.Process, error) {
+	res := r.run(name, args)
+	if res.StartErr != nil {
+		return nil, res.StartErr
+	}
+	return &process{ctx: ctx, stderr: strings.NewReader(res.Stderr), err: res.Err}, nil
+}
+
+// Calls returns the commands launched so far, in order.
+func (r *Runner) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+func (r *Runner) run(name string, args []string) Result {
+	r.mu.Lock()
+	r.calls = append(r.calls, Call{Name: name, Args: append([]string(nil), args...)})
+	r.mu.Unlock()
+
+	if r.Handler == nil {
+		return Result{}
+	}
+	return r.Handler(name, args)
+}
+
+// process is the ffmpeg.Process returned by Runner.Start.
+type process struct {
+	ctx    context.Context
+	stderr io.Reader
+	err    error
+}
+
+func (p *process) Stderr() io.Reader {
+	return p.stderr
+}
+
+func (p *process) Wait() error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	return p.err
+}
@@ -0,0 +1,482 @@
+package ffmpeg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/downloader"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// Version is the FFmpeg release branch of the managed builds.
+const Version = "7.1"
+
+// Release is the dated BtbN/FFmpeg-Builds release tag of the managed builds.
+// Unlike "latest", a dated release is never rebuilt, so its checksums.sha256
+// pins both the names and the content of its archives.
+const Release = "autobuild-2025-03-31-12-55"
+
+// Build describes a downloadable static FFmpeg build containing both the ffmpeg
+// and ffprobe executables.
+type Build struct {
+	// URL of the archive to download. If empty, the archive is the file of
+	// the ChecksumsURL manifest matching Asset, downloaded from the directory
+	// of ChecksumsURL.
+	URL string
+	// Asset is a path.Match pattern of the archive name, used when URL is
+	// empty. It must match exactly one file of the manifest.
+	Asset string
+	// Format of the archive: "zip", "tar.gz" or "tar.xz".
+	// Extracting "tar.xz" archives requires a tar executable with xz support.
+	Format string
+	// SHA256 is the expected hex encoded checksum of the archive. If empty, the
+	// checksum is looked up in the manifest at ChecksumsURL.
+	SHA256 string
+	// ChecksumsURL points to a sha256sum-style manifest ("<checksum>  <file name>")
+	// published alongside the archive.
+	ChecksumsURL string
+	// Release identifies the build in the cache directory, e.g. the release
+	// tag it was published under. Builds without Release are identified by
+	// their URL and SHA256.
+	Release string
+}
+
+const btbnRelease = "https://github.com/BtbN/FFmpeg-Builds/releases/download/" + Release + "/"
+
+// DefaultBuilds lists the managed builds per "GOOS/GOARCH" platform, pinned to
+// Release: the archive of the Version branch is found in, and verified
+// against, the checksums.sha256 of the release. Dated archive names carry the
+// git revision of the build (e.g. "ffmpeg-n7.1.1-6-g48c0f071d4-linux64-gpl-7.1.tar.xz"),
+// hence the patterns. Platforms that are not listed (e.g. macOS) are not
+// supported by Ensure and require a system installation of FFmpeg.
+var DefaultBuilds = map[string]Build{
+	"linux/amd64": {
+		Asset:        "ffmpeg-n" + Version + "*-linux64-gpl-" + Version + ".tar.xz",
+		Format:       "tar.xz",
+		ChecksumsURL: btbnRelease + "checksums.sha256",
+		Release:      Release,
+	},
+	"linux/arm64": {
+		Asset:        "ffmpeg-n" + Version + "*-linuxarm64-gpl-" + Version + ".tar.xz",
+		Format:       "tar.xz",
+		ChecksumsURL: btbnRelease + "checksums.sha256",
+		Release:      Release,
+	},
+	"windows/amd64": {
+		Asset:        "ffmpeg-n" + Version + "*-win64-gpl-" + Version + ".zip",
+		Format:       "zip",
+		ChecksumsURL: btbnRelease + "checksums.sha256",
+		Release:      Release,
+	},
+}
+
+// InstallOptions contains settings for Ensure.
+type InstallOptions struct {
+	// CacheDir is the directory where managed builds are stored.
+	// Defaults to "HLSpresso/ffmpeg" inside os.UserCacheDir().
+	CacheDir string
+	// Build overrides the entry of DefaultBuilds for the current platform.
+	Build *Build
+	// Progress is an optional progress.Reporter to receive download updates.
+	Progress progress.Reporter
+	// AddToPath, if true, prepends the directory containing the managed binaries to
+	// the PATH environment variable of the current process, so components that
+	// invoke "ffprobe" by name use the managed build as well.
+	AddToPath bool
+}
+
+// Binaries holds the paths of an installed ffmpeg/ffprobe pair.
+type Binaries struct {
+	// Dir is the directory containing both executables.
+	Dir string
+	// FFmpeg is the full path to the ffmpeg executable.
+	FFmpeg string
+	// FFprobe is the full path to the ffprobe executable.
+	FFprobe string
+}
+
+// Ensure makes sure a managed FFmpeg build for the current OS/architecture is
+// available in the cache directory, downloading and verifying it if needed.
+// The archive checksum is always verified before the executables are extracted.
+// Returns the paths to the cached ffmpeg and ffprobe executables.
+func Ensure(ctx context.Context, opts InstallOptions) (*Binaries, error) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+
+	build, ok := DefaultBuilds[platform]
+	if opts.Build != nil {
+		build, ok = *opts.Build, true
+	}
+	if !ok {
+		return nil, errors.New(errors.CodecNotFoundError, "No managed FFmpeg build available for this platform, install FFmpeg manually", platform, errors.ErrMissingDependency)
+	}
+	if build.SHA256 == "" && build.ChecksumsURL == "" {
+		// Never install a build whose content is not pinned.
+		return nil, errors.New(errors.ValidationError, "Managed FFmpeg build has no checksum to verify against", build.URL, 3)
+	}
+	if build.URL == "" && (build.Asset == "" || build.ChecksumsURL == "") {
+		return nil, errors.New(errors.ValidationError, "Managed FFmpeg build has no archive URL", build.Asset, 3)
+	}
+
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, errors.Wrap(err, errors.SystemError, "Failed to determine user cache directory", 1)
+		}
+		cacheDir = filepath.Join(userCache, "HLSpresso", "ffmpeg")
+	}
+
+	bins := binariesIn(filepath.Join(cacheDir, build.cacheKey()+"-"+runtime.GOOS+"-"+runtime.GOARCH))
+	if bins.exist() {
+		logger.Debug("Using cached FFmpeg build", "ffmpeg", map[string]interface{}{
+			"dir": bins.Dir,
+		})
+		return bins.withPath(opts.AddToPath), nil
+	}
+
+	if err := install(ctx, build, cacheDir, bins, opts.Progress); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Managed FFmpeg build installed", "ffmpeg", map[string]interface{}{
+		"dir":     bins.Dir,
+		"version": Version,
+		"release": build.Release,
+	})
+
+	return bins.withPath(opts.AddToPath), nil
+}
+
+// install downloads, verifies and extracts build into bins.Dir.
+func install(ctx context.Context, build Build, cacheDir string, bins *Binaries, rep progress.Reporter) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return errors.Wrap(err, errors.PermissionError, errors.GetErrorMessage(errors.ErrWritePermissionDenied), errors.ErrWritePermissionDenied)
+	}
+	workDir, err := os.MkdirTemp(cacheDir, "download-")
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create temporary directory", 2)
+	}
+	defer os.RemoveAll(workDir)
+
+	var checksums map[string]string
+	if build.URL == "" {
+		// Find the archive in the manifest of its release
+		if checksums, err = fetchChecksums(ctx, build.ChecksumsURL); err != nil {
+			return err
+		}
+		name, err := matchAsset(checksums, build.Asset)
+		if err != nil {
+			return err
+		}
+		build.URL = build.ChecksumsURL[:strings.LastIndex(build.ChecksumsURL, "/")+1] + name
+	}
+	archiveName := path.Base(build.URL)
+	archivePath := filepath.Join(workDir, archiveName)
+
+	logger.Info("Downloading managed FFmpeg build", "ffmpeg", map[string]interface{}{
+		"url": build.URL,
+	})
+	if _, err := downloader.New(downloader.Options{
+		URL:           build.URL,
+		OutputPath:    archivePath,
+		Progress:      rep,
+		AllowOverride: true,
+	}).Download(ctx); err != nil {
+		return err
+	}
+
+	expected := strings.ToLower(build.SHA256)
+	if expected == "" {
+		if checksums == nil {
+			if checksums, err = fetchChecksums(ctx, build.ChecksumsURL); err != nil {
+				return err
+			}
+		}
+		var ok bool
+		if expected, ok = checksums[archiveName]; !ok {
+			return errors.New(errors.ValidationError, "Checksum manifest does not list the FFmpeg archive", archiveName, 3)
+		}
+	}
+	if err := verifyChecksum(archivePath, expected); err != nil {
+		return err
+	}
+
+	extractDir := filepath.Join(workDir, "extract")
+	if err := extract(archivePath, build.Format, extractDir); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to extract FFmpeg archive", 4)
+	}
+
+	tmpDir, err := os.MkdirTemp(cacheDir, "install-")
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create temporary directory", 2)
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{filepath.Base(bins.FFmpeg), filepath.Base(bins.FFprobe)} {
+		src, err := findFile(extractDir, name)
+		if err != nil {
+			return errors.Wrap(err, errors.CodecNotFoundError, "FFmpeg archive does not contain "+name, errors.ErrMissingDependency)
+		}
+		if err := os.Rename(src, filepath.Join(tmpDir, name)); err != nil {
+			return errors.Wrap(err, errors.SystemError, "Failed to install "+name, 5)
+		}
+		if err := os.Chmod(filepath.Join(tmpDir, name), 0755); err != nil {
+			return errors.Wrap(err, errors.SystemError, "Failed to install "+name, 5)
+		}
+	}
+
+	// Move the complete installation into place so an interrupted install never
+	// leaves a partially populated cache directory behind.
+	os.RemoveAll(bins.Dir)
+	if err := os.Rename(tmpDir, bins.Dir); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to install FFmpeg build", 5)
+	}
+	return nil
+}
+
+// cacheKey names the cache directory of the build: its Release, or a digest
+// of its archive and checksum sources, so that a new build never reuses the
+// binaries of another one.
+func (b Build) cacheKey() string {
+	if b.Release != "" {
+		return b.Release
+	}
+	sum := sha256.Sum256([]byte(b.URL + "\n" + b.Asset + "\n" + b.ChecksumsURL + "\n" + strings.ToLower(b.SHA256)))
+	return "build-" + hex.EncodeToString(sum[:8])
+}
+
+// binariesIn returns the Binaries located in dir, adding the platform executable suffix.
+func binariesIn(dir string) *Binaries {
+	suffix := ""
+	if runtime.GOOS == "windows" {
+		suffix = ".exe"
+	}
+	return &Binaries{
+		Dir:     dir,
+		FFmpeg:  filepath.Join(dir, "ffmpeg"+suffix),
+		FFprobe: filepath.Join(dir, "ffprobe"+suffix),
+	}
+}
+
+// exist reports whether both executables are present.
+func (b *Binaries) exist() bool {
+	for _, p := range []string{b.FFmpeg, b.FFprobe} {
+		if info, err := os.Stat(p); err != nil || info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// withPath optionally prepends b.Dir to PATH and returns b.
+func (b *Binaries) withPath(addToPath bool) *Binaries {
+	if addToPath {
+		os.Setenv("PATH", b.Dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	return b
+}
+
+// fetchChecksums downloads a sha256sum-style manifest and returns its
+// checksums by file name.
+func fetchChecksums(ctx context.Context, manifestURL string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.DownloadError, "Failed to create HTTP request", 6)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(errors.DownloadError, "Failed to download checksum manifest", fmt.Sprintf("Status: %s", resp.Status), 7)
+	}
+	return parseChecksums(resp.Body), nil
+}
+
+// matchAsset returns the single file name of checksums matching pattern.
+func matchAsset(checksums map[string]string, pattern string) (string, error) {
+	var matches []string
+	for name := range checksums {
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) != 1 {
+		sort.Strings(matches)
+		return "", errors.New(errors.ValidationError, "Checksum manifest does not list exactly one FFmpeg archive",
+			fmt.Sprintf("%s matches %v", pattern, matches), 3)
+	}
+	return matches[0], nil
+}
+
+// parseChecksums parses sha256sum output ("<checksum>  [*]<file name>") into a
+// map of file name to lowercase checksum.
+func parseChecksums(r io.Reader) map[string]string {
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return checksums
+}
+
+// verifyChecksum compares the SHA-256 of the file at filePath with expected.
+func verifyChecksum(filePath, expected string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to open downloaded archive", 8)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to read downloaded archive", 8)
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != expected {
+		return errors.New(errors.ValidationError, "Checksum mismatch for downloaded FFmpeg archive",
+			fmt.Sprintf("expected %s, got %s", expected, actual), 9)
+	}
+	return nil
+}
+
+// extract unpacks the archive at archivePath into destDir.
+func extract(archivePath, format, destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	switch format {
+	case "zip":
+		return extractZip(archivePath, destDir)
+	case "tar.gz":
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTar(gz, destDir)
+	case "tar.xz":
+		// The standard library has no xz decoder; rely on the system tar.
+		out, err := exec.Command("tar", "-xJf", archivePath, "-C", destDir).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+// extractZip unpacks the regular files of a zip archive into destDir.
+func extractZip(archivePath, destDir string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(destDir, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTar unpacks the regular files of a tar stream into destDir.
+func extractTar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeFile(destDir, hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile writes the archive entry name below destDir, rejecting entries that
+// would escape it.
+func writeFile(destDir, name string, r io.Reader) error {
+	target := filepath.Join(destDir, filepath.FromSlash(name))
+	if !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+		return fmt.Errorf("archive entry %q escapes destination", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, r)
+	return err
+}
+
+// findFile searches root recursively for a regular file with the given name.
+func findFile(root, name string) (string, error) {
+	var found string
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == name {
+			found = p
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("%s not found", name)
+	}
+	return found, nil
+}
@@ -0,0 +1,175 @@
+package ffmpeg
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newBuildServer serves a zip archive containing fake ffmpeg/ffprobe executables
+// and a checksum manifest for it.
+func newBuildServer(t *testing.T) (*httptest.Server, []byte) {
+	t.Helper()
+
+	suffix := ""
+	if runtime.GOOS == "windows" {
+		suffix = ".exe"
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"ffmpeg" + suffix, "ffprobe" + suffix} {
+		w, err := zw.Create("ffmpeg-build/bin/" + name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		w.Write([]byte("fake " + name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	archive := buf.Bytes()
+	sum := sha256.Sum256(archive)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ffmpeg.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/checksums.sha256", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0000  other.zip\n" + hex.EncodeToString(sum[:]) + "  ffmpeg.zip\n"))
+	})
+	return httptest.NewServer(mux), archive
+}
+
+func TestEnsureDownloadsAndCaches(t *testing.T) {
+	server, _ := newBuildServer(t)
+	cacheDir := t.TempDir()
+
+	opts := InstallOptions{
+		CacheDir: cacheDir,
+		Build: &Build{
+			URL:          server.URL + "/ffmpeg.zip",
+			Format:       "zip",
+			ChecksumsURL: server.URL + "/checksums.sha256",
+		},
+	}
+
+	bins, err := Ensure(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Ensure() failed: %v", err)
+	}
+	content, err := os.ReadFile(bins.FFprobe)
+	if err != nil || !strings.HasPrefix(string(content), "fake ffprobe") {
+		t.Errorf("ffprobe not installed correctly: %q, %v", content, err)
+	}
+
+	// Second call must be served from the cache.
+	server.Close()
+	cached, err := Ensure(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Ensure() from cache failed: %v", err)
+	}
+	if cached.FFmpeg != bins.FFmpeg {
+		t.Errorf("Cached FFmpeg path = %q, want %q", cached.FFmpeg, bins.FFmpeg)
+	}
+}
+
+func TestEnsureFindsAsset(t *testing.T) {
+	server, _ := newBuildServer(t)
+	defer server.Close()
+
+	bins, err := Ensure(context.Background(), InstallOptions{
+		CacheDir: t.TempDir(),
+		Build: &Build{
+			Asset:        "ffmpeg*.zip",
+			Format:       "zip",
+			ChecksumsURL: server.URL + "/checksums.sha256",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Ensure() failed: %v", err)
+	}
+	if _, err := os.Stat(bins.FFmpeg); err != nil {
+		t.Errorf("ffmpeg not installed: %v", err)
+	}
+
+	_, err = Ensure(context.Background(), InstallOptions{
+		CacheDir: t.TempDir(),
+		Build: &Build{
+			Asset:        "*.zip",
+			Format:       "zip",
+			ChecksumsURL: server.URL + "/checksums.sha256",
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Errorf("Ensure() of an ambiguous asset error = %v", err)
+	}
+}
+
+func TestDefaultBuildsArePinned(t *testing.T) {
+	for platform, build := range DefaultBuilds {
+		if build.SHA256 == "" && build.ChecksumsURL == "" {
+			t.Errorf("%s: no checksum source", platform)
+		}
+		if build.URL == "" && build.Asset == "" {
+			t.Errorf("%s: no archive", platform)
+		}
+		if strings.Contains(build.URL+build.Asset, "latest") || !strings.Contains(build.URL+build.ChecksumsURL, "/"+Release+"/") {
+			t.Errorf("%s: not pinned to release %s", platform, Release)
+		}
+	}
+}
+
+func TestEnsureChecksumMismatch(t *testing.T) {
+	server, _ := newBuildServer(t)
+	defer server.Close()
+
+	_, err := Ensure(context.Background(), InstallOptions{
+		CacheDir: t.TempDir(),
+		Build: &Build{
+			URL:    server.URL + "/ffmpeg.zip",
+			Format: "zip",
+			SHA256: strings.Repeat("0", 64),
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "Checksum mismatch") {
+		t.Errorf("Ensure() should fail on checksum mismatch, got %v", err)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	got := parseChecksums(strings.NewReader("ABCD  a.zip\nef01 *b.tar.xz\ninvalid line here\n"))
+	if got["a.zip"] != "abcd" || got["b.tar.xz"] != "ef01" || len(got) != 2 {
+		t.Errorf("parseChecksums() = %v", got)
+	}
+}
+
+func TestEnsureRequiresChecksum(t *testing.T) {
+	_, err := Ensure(context.Background(), InstallOptions{
+		CacheDir: t.TempDir(),
+		Build:    &Build{URL: "http://127.0.0.1:1/ffmpeg.zip", Format: "zip"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no checksum") {
+		t.Errorf("Ensure() of an unpinned build error = %v", err)
+	}
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	pinned := Build{URL: "https://example.com/ffmpeg.zip", SHA256: "ABCD", Release: "autobuild-2025-01-01"}
+	if key := pinned.cacheKey(); key != "autobuild-2025-01-01" {
+		t.Errorf("cacheKey() = %q, want the release", key)
+	}
+	a := Build{URL: "https://example.com/ffmpeg.zip", SHA256: "abcd"}
+	b := Build{URL: "https://example.com/ffmpeg.zip", SHA256: "ef01"}
+	if a.cacheKey() == b.cacheKey() {
+		t.Errorf("cacheKey() of different archives = %q for both", a.cacheKey())
+	}
+}
@@ -0,0 +1,166 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// processCount numbers the processes whose output is logged by ExecRunner.
+var processCount atomic.Int64
+
+// processLog writes the output of a process to the Log of an ExecRunner, one
+// line per Write, so that the lines of concurrent processes do not mix.
+type processLog struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+// startLog logs the command line of a process and returns the log of its output.
+func startLog(w io.Writer, name string, args []string) *processLog {
+	l := &processLog{w: w, prefix: fmt.Sprintf("[%d] ", processCount.Add(1))}
+	l.line(fmt.Sprintf("%s $ %s %s", time.Now().Format(time.RFC3339), name, strings.Join(args, " ")))
+	return l
+}
+
+// Write logs the complete lines of p, ended by \n or by the \r of the
+// ffmpeg statistics, and buffers the rest.
+func (l *processLog) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexAny(l.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			l.line(string(l.buf[:i]))
+		}
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// end logs the buffered output and the exit status of the process. It does
+// nothing on a nil processLog.
+func (l *processLog) end(err error) {
+	if l == nil {
+		return
+	}
+	if len(l.buf) > 0 {
+		l.line(string(l.buf))
+		l.buf = nil
+	}
+	status := "exited successfully"
+	if err != nil {
+		status = "failed: " + err.Error()
+	}
+	l.line(fmt.Sprintf("%s %s", time.Now().Format(time.RFC3339), status))
+}
+
+// line writes a line of the log. Write errors are ignored: the log must never
+// fail the process it describes.
+func (l *processLog) line(s string) {
+	l.w.Write([]byte(l.prefix + s + "\n"))
+}
+
+// LogFile is a log file safe for concurrent use, such as the Log of an
+// ExecRunner, rotated when it grows beyond a maximum size: path is renamed to
+// path.1, path.1 to path.2 and so on, keeping a given number of backups.
+type LogFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenLogFile opens the log file at path for appending, creating it and its
+// directory if needed. It is rotated once larger than maxSize bytes, keeping
+// backups old files; a maxSize of 0 disables the rotation.
+func OpenLogFile(path string, maxSize int64, backups int) (*LogFile, error) {
+	l := &LogFile{path: path, maxSize: maxSize, backups: backups}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write appends p to the file, rotating it first if p would make it larger
+// than its maximum size.
+func (l *LogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// open opens the file at l.path for appending.
+func (l *LogFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the current file to the first backup, shifting the older
+// backups and removing the oldest, and opens a new file.
+// Requires lock to be held by caller.
+func (l *LogFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", l.path, i) }
+	if l.backups == 0 {
+		os.Remove(l.path)
+	} else {
+		os.Remove(backup(l.backups))
+		for i := l.backups - 1; i >= 1; i-- {
+			os.Rename(backup(i), backup(i+1))
+		}
+		if err := os.Rename(l.path, backup(1)); err != nil {
+			return err
+		}
+	}
+	return l.open()
+}
@@ -0,0 +1,50 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "ffmpeg.log")
+	log, err := OpenLogFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenLogFile() error: %v", err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := log.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	// Each line exceeds the limit with the previous one; the oldest is dropped
+	for file, want := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		if content, _ := os.ReadFile(file); string(content) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), content, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("More backups than configured were kept: %v", err)
+	}
+}
+
+func TestProcessLog(t *testing.T) {
+	var out strings.Builder
+	log := startLog(&out, "ffmpeg", []string{"-i", "in.mp4"})
+	log.Write([]byte("frame=  1\rframe=  2\r"))
+	log.Write([]byte("\nconversion failed"))
+	log.end(os.ErrDeadlineExceeded)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	prefix := lines[0][:strings.Index(lines[0], " ")+1]
+	if len(lines) != 5 || !strings.HasSuffix(lines[0], "$ ffmpeg -i in.mp4") ||
+		lines[1] != prefix+"frame=  1" || lines[2] != prefix+"frame=  2" || lines[3] != prefix+"conversion failed" ||
+		!strings.Contains(lines[4], "failed: ") {
+		t.Errorf("Log =\n%s", out.String())
+	}
+}
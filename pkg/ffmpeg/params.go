@@ -0,0 +1,210 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Param is an option of an ffmpeg command line, such as "-c:v libx264".
+type Param struct {
+	// Name is the option name without the dash and the stream specifier, e.g. "c".
+	Name string
+	// Stream is the stream specifier of the option, e.g. "v:0", or "" if none.
+	Stream string
+	// Value is the argument of the option, or "" for flags such as -y.
+	Value string
+}
+
+// String returns the param as written on the command line.
+func (p Param) String() string {
+	s := "-" + p.Name
+	if p.Stream != "" {
+		s += ":" + p.Stream
+	}
+	if p.Value != "" {
+		s += " " + p.Value
+	}
+	return s
+}
+
+// Args returns the param as command line arguments.
+func (p Param) Args() []string {
+	name := "-" + p.Name
+	if p.Stream != "" {
+		name += ":" + p.Stream
+	}
+	if flags[p.Name] {
+		return []string{name}
+	}
+	return []string{name, p.Value}
+}
+
+// flags lists the ffmpeg options that take no argument. The other options
+// consume the next argument, whatever it looks like.
+var flags = map[string]bool{
+	"y": true, "n": true, "re": true, "an": true, "vn": true, "sn": true, "dn": true,
+	"nostdin": true, "stdin": true, "hide_banner": true, "stats": true, "nostats": true,
+	"shortest": true, "copyts": true, "start_at_zero": true, "copyinkf": true,
+	"accurate_seek": true, "noaccurate_seek": true, "autorotate": true, "noautorotate": true,
+	"autoscale": true, "noautoscale": true, "xerror": true, "ignore_unknown": true,
+	"benchmark": true, "benchmark_all": true, "dump": true, "hex": true, "debug_ts": true,
+	"bitexact": true, "psnr": true, "vstats": true, "intra": true, "ignore_chapters": true,
+}
+
+// aliases maps the ffmpeg options that are shorthands of another option for
+// a type of stream to that option and stream type, e.g. -vcodec for -c:v.
+var aliases = map[string]Param{
+	"codec":   {Name: "c"},
+	"vcodec":  {Name: "c", Stream: "v"},
+	"acodec":  {Name: "c", Stream: "a"},
+	"scodec":  {Name: "c", Stream: "s"},
+	"vf":      {Name: "filter", Stream: "v"},
+	"af":      {Name: "filter", Stream: "a"},
+	"vb":      {Name: "b", Stream: "v"},
+	"ab":      {Name: "b", Stream: "a"},
+	"vframes": {Name: "frames", Stream: "v"},
+	"aframes": {Name: "frames", Stream: "a"},
+	"vtag":    {Name: "tag", Stream: "v"},
+	"atag":    {Name: "tag", Stream: "a"},
+	"aq":      {Name: "q", Stream: "a"},
+}
+
+// repeatable lists the options that may be given several times without
+// overriding each other.
+var repeatable = map[string]bool{
+	"metadata": true,
+}
+
+// SplitParams returns params with the entries holding an option and its value
+// separated by whitespace (e.g. "-crf 18", as commonly written in
+// configuration files) split into two arguments. Other entries, such as
+// filter graphs with spaces given as a separate argument, are kept as is.
+func SplitParams(params []string) []string {
+	var args []string
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if isOption(param) {
+			if name, value, ok := strings.Cut(param, " "); ok {
+				args = append(args, name, strings.TrimSpace(value))
+				continue
+			}
+		}
+		args = append(args, param)
+	}
+	return args
+}
+
+// ParseParams parses ffmpeg command line arguments into its options, in
+// order, and its positional arguments (the output files).
+func ParseParams(args []string) (params []Param, positional []string) {
+	for i := 0; i < len(args); i++ {
+		if !isOption(args[i]) {
+			positional = append(positional, args[i])
+			continue
+		}
+		name, stream, _ := strings.Cut(args[i][1:], ":")
+		param := Param{Name: name, Stream: stream}
+		if !flags[name] && i+1 < len(args) {
+			i++
+			param.Value = args[i]
+		}
+		params = append(params, param)
+	}
+	return params, positional
+}
+
+// ParamConflicts returns the conflicts of the extra arguments added to an
+// ffmpeg command line with the generated arguments: options the generated
+// arguments already set for the same streams, which ffmpeg overrides with the
+// last value or rejects, video filters that cannot be combined with a
+// generated -filter_complex, additional inputs, and positional arguments,
+// which ffmpeg would read as another output file, leaving the arguments after
+// them without effect.
+func ParamConflicts(generated, extra []string) []string {
+	generatedParams, _ := ParseParams(generated)
+	extraParams, positional := ParseParams(extra)
+
+	var conflicts []string
+	for _, param := range extraParams {
+		if conflict := paramConflict(param, generatedParams); conflict != "" {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	for _, arg := range positional {
+		conflicts = append(conflicts, fmt.Sprintf("%q is not the value of an option and would be read as another output file", arg))
+	}
+	return conflicts
+}
+
+// paramConflict describes the first conflict of param with the generated
+// params, or returns "" if there is none.
+func paramConflict(param Param, generated []Param) string {
+	name, stream := canonical(param)
+	if name == "i" {
+		return fmt.Sprintf("%s adds an input to the generated command", param)
+	}
+	if flags[name] || repeatable[name] {
+		return ""
+	}
+	for _, g := range generated {
+		gName, gStream := canonical(g)
+		switch {
+		case name == "filter" && gName == "filter_complex" && streamsOverlap(stream, "v"):
+			return fmt.Sprintf("%s cannot be combined with the generated -filter_complex", param)
+		case name == gName && streamsOverlap(stream, gStream):
+			return fmt.Sprintf("%s overrides the generated %s", param, g)
+		}
+	}
+	return ""
+}
+
+// isOption reports whether arg is an option name rather than a value, such as
+// a negative number or "-" for the standard output.
+func isOption(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err != nil
+}
+
+// canonical returns the option name and stream specifier of p with the
+// aliases resolved, e.g. "c" and "v" for -vcodec.
+func canonical(p Param) (name, stream string) {
+	name, stream = p.Name, p.Stream
+	if alias, ok := aliases[name]; ok {
+		name = alias.Name
+		if alias.Stream != "" {
+			stream = strings.TrimSuffix(alias.Stream+":"+stream, ":")
+		}
+	}
+	return name, stream
+}
+
+// streamsOverlap reports whether the stream specifiers a and b may select the
+// same stream. An empty specifier selects every stream, and specifiers other
+// than stream types and indexes (e.g. "0" or "p:1") are assumed to overlap.
+func streamsOverlap(a, b string) bool {
+	as, bs := strings.Split(a, ":"), strings.Split(b, ":")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, y := strings.ToLower(as[i]), strings.ToLower(bs[i])
+		if x == "" || y == "" || x == y {
+			continue
+		}
+		if i == 0 && (!isStreamType(x) || !isStreamType(y)) {
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// isStreamType reports whether s is a stream type of a stream specifier.
+func isStreamType(s string) bool {
+	switch s {
+	case "v", "a", "s", "d", "t":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitParams(t *testing.T) {
+	got := SplitParams([]string{"-crf 18", " -metadata title=My Movie ", "-vf", "drawtext=text='Hello world'", "-map_metadata", "-1"})
+	want := []string{"-crf", "18", "-metadata", "title=My Movie", "-vf", "drawtext=text='Hello world'", "-map_metadata", "-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitParams() = %q, want %q", got, want)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	params, positional := ParseParams([]string{"-y", "-c:v:0", "libx264", "-map_metadata", "-1", "-shortest", "out.mp4"})
+	want := []Param{{Name: "y"}, {Name: "c", Stream: "v:0", Value: "libx264"}, {Name: "map_metadata", Value: "-1"}, {Name: "shortest"}}
+	if !reflect.DeepEqual(params, want) || !reflect.DeepEqual(positional, []string{"out.mp4"}) {
+		t.Errorf("ParseParams() = %+v, %q", params, positional)
+	}
+}
+
+func TestParamConflicts(t *testing.T) {
+	generated := []string{
+		"-i", "input.mp4", "-filter_complex", "[0:v]split=2[v0][v1]",
+		"-map", "[v0]", "-c:v:0", "libx264", "-b:v:0", "5000k",
+		"-map", "[v1]", "-c:v:1", "libx264", "-b:v:1", "2500k",
+		"-map", "a:0", "-c:a:0", "aac", "-y", "-f", "hls", "out/stream_%v.m3u8",
+	}
+	tests := []struct {
+		name  string
+		extra []string
+		want  []string
+	}{
+		{"no conflict", []string{"-crf", "20", "-c:a:1", "aac", "-metadata", "title=Demo", "-y"}, nil},
+		{"codec alias", []string{"-vcodec", "libx265"}, []string{"-vcodec libx265 overrides the generated -c:v:0 libx264"}},
+		{"same stream", []string{"-b:v:1", "3M"}, []string{"-b:v:1 3M overrides the generated -b:v:1 2500k"}},
+		{"every stream", []string{"-b", "3M"}, []string{"-b 3M overrides the generated -b:v:0 5000k"}},
+		{"input", []string{"-i", "logo.png"}, []string{"-i logo.png adds an input to the generated command"}},
+		{"format", []string{"-f", "mpegts"}, []string{"-f mpegts overrides the generated -f hls"}},
+		{"video filter", []string{"-vf", "hflip", "-af", "volume=2"}, []string{"-vf hflip cannot be combined with the generated -filter_complex"}},
+		{"output file", []string{"-crf", "20", "extra.mp4", "-g", "48"}, []string{`"extra.mp4" is not the value of an option and would be read as another output file`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParamConflicts(generated, tt.extra); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParamConflicts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Runner launches the external ffmpeg and ffprobe processes.
+// The default implementation is ExecRunner; tests can provide a fake Runner
+// (see the ffmpegtest package) to simulate ffmpeg output and failures without
+// the binaries being installed.
+type Runner interface {
+	// Output runs the command to completion and returns its standard output.
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+	// Start launches the command and returns the running Process, whose standard
+	// error can be read while it runs (e.g. for progress tracking).
+	Start(ctx context.Context, name string, args ...string) (Process, error)
+}
+
+// Process is a command started by a Runner.
+type Process interface {
+	// Stderr returns the standard error output of the process.
+	// It must be read until EOF before calling Wait.
+	Stderr() io.Reader
+	// Wait waits for the process to exit and returns its exit error, if any.
+	Wait() error
+}
+
+// ExecRunner is the default Runner. It executes commands with CommandContext,
+// so cancelled processes are stopped gracefully.
+type ExecRunner struct {
+	// Log, if set, receives the command line, the standard error and the exit
+	// status of every process, each line prefixed with the number of the
+	// process (see LogFile). It must be safe for concurrent use.
+	Log io.Writer
+	// GracefulStop stops the processes launched with Start (the encodes) by
+	// sending "q" to their standard input when their context is done, instead
+	// of SIGINT, so that ffmpeg finishes the segment it is writing and
+	// finalizes the playlists rather than leaving an unplayable tail.
+	GracefulStop bool
+	// GracePeriod is how long a stopped process is given to exit before it is
+	// killed. Defaults to the package GracePeriod.
+	GracePeriod time.Duration
+	// Env lists environment variables ("KEY=value") added to the environment
+	// inherited by the processes, overriding the inherited values.
+	Env []string
+	// Dir is the working directory of the processes. Defaults to the working
+	// directory of the calling process.
+	Dir string
+}
+
+// grace returns the grace period of the processes of r.
+func (r ExecRunner) grace() time.Duration {
+	if r.GracePeriod > 0 {
+		return r.GracePeriod
+	}
+	return GracePeriod
+}
+
+// command returns the command running name with the environment and working
+// directory of r.
+func (r ExecRunner) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := commandContext(ctx, r.grace(), name, args...)
+	if len(r.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	cmd.Dir = r.Dir
+	return cmd
+}
+
+// Output implements Runner.
+func (r ExecRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := r.command(ctx, name, args...)
+	if r.Log == nil {
+		return cmd.Output()
+	}
+	var stdout bytes.Buffer
+	log := startLog(r.Log, name, args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = log
+	err := cmd.Run()
+	log.end(err)
+	return stdout.Bytes(), err
+}
+
+// Start implements Runner.
+func (r ExecRunner) Start(ctx context.Context, name string, args ...string) (Process, error) {
+	cmd := r.command(ctx, name, args...)
+	if r.GracefulStop {
+		if err := quitOnCancel(cmd, r.grace()); err != nil {
+			return nil, err
+		}
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	var log *processLog
+	if r.Log != nil {
+		log = startLog(r.Log, name, args)
+	}
+	if err := cmd.Start(); err != nil {
+		log.end(err)
+		return nil, err
+	}
+	p := &execProcess{cmd: cmd, stderr: stderr, log: log}
+	if log != nil {
+		p.stderr = io.TeeReader(stderr, log)
+	}
+	return p, nil
+}
+
+// execProcess is the Process returned by ExecRunner.
+type execProcess struct {
+	cmd    *exec.Cmd
+	stderr io.Reader
+	log    *processLog
+}
+
+func (p *execProcess) Stderr() io.Reader {
+	return p.stderr
+}
+
+func (p *execProcess) Wait() error {
+	err := p.cmd.Wait()
+	p.log.end(err)
+	return err
+}
@@ -0,0 +1,79 @@
+package ffmpeg
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats holds the values of an ffmpeg statistics line, e.g.
+// "frame=  250 fps= 50 ... time=00:00:10.00 bitrate=... speed=2.01x".
+type Stats struct {
+	// Frame is the number of frames encoded so far, or -1 if not reported.
+	Frame int64
+	// Time is the position encoded so far, or -1 if not reported.
+	Time time.Duration
+	// Speed is the encoding speed relative to realtime (2 means twice as
+	// fast as playback), or 0 if not reported.
+	Speed float64
+}
+
+var (
+	statsFrameRegex = regexp.MustCompile(`frame=\s*(\d+)`)
+	statsTimeRegex  = regexp.MustCompile(`time=\s*(-?)(\d+):(\d+):(\d+(?:\.\d+)?)`)
+	statsSpeedRegex = regexp.MustCompile(`speed=\s*(\d+(?:\.\d+)?(?:e[+-]?\d+)?)x`)
+)
+
+// ParseStats parses an ffmpeg statistics line. It returns false if line
+// reports neither a frame nor a time.
+func ParseStats(line string) (Stats, bool) {
+	stats := Stats{Frame: -1, Time: -1}
+	if m := statsFrameRegex.FindStringSubmatch(line); m != nil {
+		stats.Frame, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	// Negative times are reported before the first frame is encoded
+	if m := statsTimeRegex.FindStringSubmatch(line); m != nil && m[1] == "" {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		seconds, _ := strconv.ParseFloat(m[4], 64)
+		stats.Time = time.Duration((float64(hours*3600+minutes*60) + seconds) * float64(time.Second))
+	}
+	if m := statsSpeedRegex.FindStringSubmatch(line); m != nil {
+		stats.Speed, _ = strconv.ParseFloat(m[1], 64)
+	}
+	return stats, stats.Frame >= 0 || stats.Time >= 0
+}
+
+// ScanLines is a bufio.SplitFunc splitting the ffmpeg output on both \n and
+// the \r ending its statistics lines.
+func ScanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, c := range data {
+		if c == '\n' || c == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// ProbeDuration returns the duration in seconds of input reported by
+// ffprobe, or 0 if it cannot be determined.
+func ProbeDuration(ctx context.Context, runner Runner, input string) float64 {
+	output, err := runner.Output(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "csv=p=0",
+		input)
+	if err != nil {
+		return 0
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
@@ -0,0 +1,28 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStats(t *testing.T) {
+	tests := []struct {
+		line string
+		want Stats
+		ok   bool
+	}{
+		{"frame=  250 fps= 50 q=28.0 size=    1024kB time=00:01:10.50 bitrate= 800.0kbits/s speed=2.01x",
+			Stats{Frame: 250, Time: 70500 * time.Millisecond, Speed: 2.01}, true},
+		{"size=     256kB time=00:00:05.00 bitrate= 419.4kbits/s speed=N/A",
+			Stats{Frame: -1, Time: 5 * time.Second}, true},
+		{"frame=    0 fps=0.0 q=0.0 size=       0kB time=-00:00:00.04 bitrate=N/A speed=N/A",
+			Stats{Frame: 0, Time: -1}, true},
+		{"Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'input.mp4':", Stats{Frame: -1, Time: -1}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseStats(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseStats(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package health serves the liveness and readiness endpoints of long-running
+// HLSpresso services, /healthz and /readyz, for Docker HEALTHCHECK and
+// Kubernetes probes. Both answer 200 when healthy and 503 otherwise, with a
+// JSON report of the checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/doctor"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/jobstore"
+)
+
+// Paths of the endpoints served by Checker.Handler.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Defaults of Options.
+const (
+	DefaultTimeout   = 5 * time.Second
+	DefaultCacheTime = 30 * time.Second
+)
+
+// Queue reports the number of queued jobs; jobstore.Store implements it.
+type Queue interface {
+	QueueDepth(ctx context.Context) (int, error)
+}
+
+// Options contains the settings of a Checker.
+type Options struct {
+	// FFmpegBinary is the ffmpeg executable checked by the readiness probe.
+	// Defaults to "ffmpeg".
+	FFmpegBinary string
+	// Runner runs ffmpeg. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+	// Queue is the job queue reported by the readiness probe, if any. Its
+	// depth is only reported: the queue is shared by every instance, so a
+	// deep queue says nothing about the capacity of this one.
+	Queue Queue
+	// Workers are the workers reported by both probes. The service is not
+	// live once one of them stopped.
+	Workers []*jobstore.Worker
+	// Timeout bounds the ffmpeg and queue checks. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// CacheTime is how long the result of the ffmpeg check is reused, to
+	// avoid starting a process on every probe. Defaults to DefaultCacheTime.
+	CacheTime time.Duration
+}
+
+// Report is the response of a probe.
+type Report struct {
+	// Status is doctor.StatusOK when every check passed, doctor.StatusFail otherwise.
+	Status doctor.Status  `json:"status"`
+	Checks []doctor.Check `json:"checks"`
+	// QueueDepth is the number of queued jobs, when Options.Queue is set and
+	// could be queried.
+	QueueDepth *int                    `json:"queue_depth,omitempty"`
+	Workers    []jobstore.WorkerStatus `json:"workers,omitempty"`
+}
+
+// Checker runs the checks of the liveness and readiness probes.
+type Checker struct {
+	options Options
+
+	mu        sync.Mutex
+	ffmpeg    doctor.Check
+	checkedAt time.Time
+}
+
+// New creates a Checker, setting defaults for unspecified options.
+func New(options Options) *Checker {
+	if options.FFmpegBinary == "" {
+		options.FFmpegBinary = "ffmpeg"
+	}
+	if options.Runner == nil {
+		options.Runner = ffmpeg.ExecRunner{}
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.CacheTime <= 0 {
+		options.CacheTime = DefaultCacheTime
+	}
+	return &Checker{options: options}
+}
+
+// Liveness reports whether the process works: every worker is still serving.
+// It runs no external command, so it stays cheap when probed often.
+func (c *Checker) Liveness(ctx context.Context) Report {
+	r := Report{}
+	c.checkWorkers(&r)
+	return r.finish()
+}
+
+// Readiness reports whether the service can take jobs: ffmpeg runs, the queue
+// can be queried, and every worker is still serving.
+func (c *Checker) Readiness(ctx context.Context) Report {
+	r := Report{}
+	r.Checks = append(r.Checks, c.checkFFmpeg(ctx))
+	if c.options.Queue != nil {
+		c.checkQueue(ctx, &r)
+	}
+	c.checkWorkers(&r)
+	return r.finish()
+}
+
+// Handler returns the http.Handler serving LivenessPath and ReadinessPath. It
+// can be mounted on the mux of a service.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, r *http.Request) {
+		c.serve(w, r, c.Liveness)
+	})
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, r *http.Request) {
+		c.serve(w, r, c.Readiness)
+	})
+	return mux
+}
+
+// serve writes the report of probe as JSON, with status 503 when it failed.
+func (c *Checker) serve(w http.ResponseWriter, r *http.Request, probe func(context.Context) Report) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := probe(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != doctor.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// checkFFmpeg runs "ffmpeg -version", reusing its result for CacheTime.
+func (c *Checker) checkFFmpeg(ctx context.Context) doctor.Check {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.options.CacheTime {
+		return c.ffmpeg
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
+	output, err := c.options.Runner.Output(ctx, c.options.FFmpegBinary, "-version")
+	if err != nil {
+		// A canceled probe says nothing about ffmpeg; do not cache it
+		check := doctor.Check{Name: "ffmpeg", Status: doctor.StatusFail, Message: fmt.Sprintf("%s cannot be run: %v", c.options.FFmpegBinary, err)}
+		if ctx.Err() == nil {
+			c.ffmpeg, c.checkedAt = check, time.Now()
+		}
+		return check
+	}
+	c.ffmpeg = doctor.Check{Name: "ffmpeg", Status: doctor.StatusOK, Message: strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])}
+	c.checkedAt = time.Now()
+	return c.ffmpeg
+}
+
+// checkQueue adds the queue depth to r.
+func (c *Checker) checkQueue(ctx context.Context, r *Report) {
+	ctx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
+	depth, err := c.options.Queue.QueueDepth(ctx)
+	if err != nil {
+		r.Checks = append(r.Checks, doctor.Check{Name: "queue", Status: doctor.StatusFail, Message: fmt.Sprintf("the job queue cannot be queried: %v", err)})
+		return
+	}
+	r.QueueDepth = &depth
+	r.Checks = append(r.Checks, doctor.Check{Name: "queue", Status: doctor.StatusOK, Message: fmt.Sprintf("%d jobs queued", depth)})
+}
+
+// checkWorkers adds the status of the workers to r.
+func (c *Checker) checkWorkers(r *Report) {
+	if len(c.options.Workers) == 0 {
+		return
+	}
+	stopped := 0
+	for _, worker := range c.options.Workers {
+		status := worker.Status()
+		if status.State == jobstore.WorkerStopped {
+			stopped++
+		}
+		r.Workers = append(r.Workers, status)
+	}
+	if stopped > 0 {
+		r.Checks = append(r.Checks, doctor.Check{Name: "workers", Status: doctor.StatusFail,
+			Message: fmt.Sprintf("%d of %d workers stopped", stopped, len(c.options.Workers))})
+		return
+	}
+	r.Checks = append(r.Checks, doctor.Check{Name: "workers", Status: doctor.StatusOK,
+		Message: fmt.Sprintf("%d workers serving", len(c.options.Workers))})
+}
+
+// finish sets the status of r from its checks.
+func (r Report) finish() Report {
+	r.Status = doctor.StatusOK
+	for _, check := range r.Checks {
+		if check.Status == doctor.StatusFail {
+			r.Status = doctor.StatusFail
+		}
+	}
+	if r.Checks == nil {
+		r.Checks = []doctor.Check{}
+	}
+	return r
+}
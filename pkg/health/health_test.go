@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/doctor"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/jobstore"
+)
+
+func init() {
+	sql.Register("healthtest", downDriver{})
+}
+
+// downDriver is a database/sql driver of an unreachable database.
+type downDriver struct{}
+
+func (downDriver) Open(string) (driver.Conn, error) { return nil, stderrors.New("database down") }
+
+type fakeQueue struct {
+	depth int
+	err   error
+}
+
+func (q fakeQueue) QueueDepth(context.Context) (int, error) { return q.depth, q.err }
+
+func ffmpegRunner(err error) *ffmpegtest.Runner {
+	return &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		return ffmpegtest.Result{Stdout: "ffmpeg version 6.1\nbuilt with gcc", Err: err}
+	}}
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("GET %s returned invalid JSON %q: %v", path, rec.Body, err)
+	}
+	return rec.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	runner := ffmpegRunner(nil)
+	checker := New(Options{Runner: runner, Queue: fakeQueue{depth: 500}})
+
+	// A deep shared queue does not make the instance unready
+	code, report := get(t, checker.Handler(), ReadinessPath)
+	if code != http.StatusOK || report.Status != doctor.StatusOK || report.QueueDepth == nil || *report.QueueDepth != 500 {
+		t.Errorf("GET /readyz = %d %+v", code, report)
+	}
+	if report.Checks[0].Message != "ffmpeg version 6.1" {
+		t.Errorf("ffmpeg check = %+v", report.Checks[0])
+	}
+
+	// The ffmpeg check is cached
+	get(t, checker.Handler(), ReadinessPath)
+	if calls := len(runner.Calls()); calls != 1 {
+		t.Errorf("ffmpeg ran %d times, want 1", calls)
+	}
+}
+
+func TestReadinessFailures(t *testing.T) {
+	for name, options := range map[string]Options{
+		"ffmpeg missing": {Runner: ffmpegRunner(stderrors.New("executable file not found"))},
+		"queue error":    {Runner: ffmpegRunner(nil), Queue: fakeQueue{err: stderrors.New("connection refused")}},
+	} {
+		code, report := get(t, New(options).Handler(), ReadinessPath)
+		if code != http.StatusServiceUnavailable || report.Status != doctor.StatusFail {
+			t.Errorf("%s: GET /readyz = %d %+v", name, code, report)
+		}
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	runner := ffmpegRunner(stderrors.New("executable file not found"))
+	worker := &jobstore.Worker{ID: "node-a"}
+	checker := New(Options{Runner: runner, Workers: []*jobstore.Worker{worker}})
+
+	code, report := get(t, checker.Handler(), LivenessPath)
+	if code != http.StatusOK || len(report.Workers) != 1 || report.Workers[0].State != jobstore.WorkerStarting {
+		t.Errorf("GET /healthz = %d %+v", code, report)
+	}
+	if len(runner.Calls()) != 0 {
+		t.Error("The liveness probe ran ffmpeg")
+	}
+
+	// A worker whose Serve returned makes the service not live
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db, _ := sql.Open("healthtest", "")
+	worker.Store, _ = jobstore.New(db, jobstore.SQLite)
+	worker.Serve(ctx)
+	if code, report := get(t, checker.Handler(), LivenessPath); code != http.StatusServiceUnavailable || report.Workers[0].State != jobstore.WorkerStopped {
+		t.Errorf("GET /healthz with a stopped worker = %d %+v", code, report)
+	}
+}
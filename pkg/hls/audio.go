@@ -0,0 +1,107 @@
+package hls
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// AudioCodec selects how the audio of the renditions is produced.
+type AudioCodec string
+
+const (
+	// AudioAAC encodes stereo AAC audio at the bitrate of each rendition.
+	AudioAAC AudioCodec = "aac"
+	// AudioAC3 encodes Dolby Digital (AC-3) audio, keeping the channel layout of
+	// the input (e.g. 5.1) at the default bitrate of the encoder for it.
+	AudioAC3 AudioCodec = "ac3"
+	// AudioEAC3 encodes Dolby Digital Plus (E-AC-3) audio, keeping the channel
+	// layout of the input at the default bitrate of the encoder for it.
+	AudioEAC3 AudioCodec = "eac3"
+	// AudioCopy copies the audio of the input unchanged, e.g. to pass an AC-3 or
+	// E-AC-3 track through without generational loss.
+	AudioCopy AudioCodec = "copy"
+)
+
+// SharedAudioName names the audio rendition of Options.SharedAudio: its group
+// (GROUP-ID "group_audio" in the master playlist) and its directory ("stream_audio").
+const SharedAudioName = "audio"
+
+// SharedAudioBitrate returns the bitrate of the shared audio rendition for
+// resolutions: the highest of their audio bitrates.
+func SharedAudioBitrate(resolutions []VideoResolution) string {
+	var bitrate string
+	for _, res := range resolutions {
+		if ParseBitrate(res.AudioBitrate) > ParseBitrate(bitrate) {
+			bitrate = res.AudioBitrate
+		}
+	}
+	return bitrate
+}
+
+// ParseAudioCodec converts a codec name ("aac", "ac3", "eac3" or "copy",
+// case-insensitive) to an AudioCodec. An empty name returns AudioAAC.
+func ParseAudioCodec(name string) (AudioCodec, error) {
+	switch codec := AudioCodec(strings.ToLower(strings.TrimSpace(name))); codec {
+	case "":
+		return AudioAAC, nil
+	case AudioAAC, AudioAC3, AudioEAC3, AudioCopy:
+		return codec, nil
+	default:
+		return "", fmt.Errorf("unknown audio codec %q (available: aac, ac3, eac3, copy)", name)
+	}
+}
+
+// AudioCodecTag returns the RFC 6381 codec tag signaled in the CODECS attribute
+// of master playlists for the ffmpeg audio codec name (e.g. "ec-3" for "eac3"),
+// or "" if the codec is not supported in HLS.
+func AudioCodecTag(codecName string) string {
+	switch codecName {
+	case "aac":
+		return "mp4a.40.2"
+	case "mp3":
+		return "mp4a.40.34"
+	case "ac3":
+		return "ac-3"
+	case "eac3":
+		return "ec-3"
+	}
+	return ""
+}
+
+// codecsPattern matches the CODECS attribute of an EXT-X-STREAM-INF tag.
+var codecsPattern = regexp.MustCompile(`CODECS="([^"]*)"`)
+
+// SetAudioCodec replaces the audio codec listed in the CODECS attribute of every
+// variant of the master playlist at masterPath with tag (see AudioCodecTag).
+// ffmpeg signals AAC only, or omits CODECS for other audio codecs; variants
+// without CODECS are left unchanged, since the attribute must list every codec.
+func SetAudioCodec(masterPath, tag string) error {
+	content, err := os.ReadFile(masterPath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			continue
+		}
+		lines[i] = codecsPattern.ReplaceAllStringFunc(line, func(attr string) string {
+			var codecs []string
+			for _, codec := range strings.Split(codecsPattern.FindStringSubmatch(attr)[1], ",") {
+				if codec != "" && !isAudioCodecTag(codec) {
+					codecs = append(codecs, codec)
+				}
+			}
+			return fmt.Sprintf(`CODECS="%s"`, strings.Join(append(codecs, tag), ","))
+		})
+	}
+	return os.WriteFile(masterPath, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// isAudioCodecTag reports whether the RFC 6381 tag is an audio codec.
+func isAudioCodecTag(tag string) bool {
+	return strings.HasPrefix(tag, "mp4a.") || tag == "ac-3" || tag == "ec-3" || tag == "opus" || tag == "fLaC"
+}
@@ -0,0 +1,84 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseAudioCodec(t *testing.T) {
+	for name, want := range map[string]AudioCodec{"": AudioAAC, "AAC": AudioAAC, "ac3": AudioAC3, " eac3 ": AudioEAC3, "copy": AudioCopy} {
+		if got, err := ParseAudioCodec(name); err != nil || got != want {
+			t.Errorf("ParseAudioCodec(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseAudioCodec("dts"); err == nil {
+		t.Error("ParseAudioCodec(\"dts\") expected an error")
+	}
+}
+
+func TestBuildFFmpegArgsAudioCodec(t *testing.T) {
+	resolutions := []VideoResolution{DefaultResolutions[0], DefaultResolutions[1]}
+	tests := []struct {
+		codec AudioCodec
+		want  []string
+	}{
+		{AudioAAC, []string{"-c:a:1", "aac", "-b:a:1", "128k", "-ac", "2"}},
+		{AudioEAC3, []string{"-map", "a:0", "-c:a:1", "eac3", "-f"}},
+		{AudioCopy, []string{"-map", "a:0", "-c:a:1", "copy", "-f"}},
+	}
+	for _, tt := range tests {
+		args := New(Options{InputFile: "input.ts", OutputDir: "out", Resolutions: resolutions, AudioCodec: tt.codec}).Args()
+		if !containsSequence(args, tt.want) {
+			t.Errorf("Args() with %s audio = %v, want %v", tt.codec, args, tt.want)
+		}
+	}
+}
+
+func TestBuildFFmpegArgsSharedAudio(t *testing.T) {
+	resolutions := []VideoResolution{DefaultResolutions[1], DefaultResolutions[0], DefaultResolutions[2]}
+	args := New(Options{InputFile: "input.ts", OutputDir: "out", Resolutions: resolutions, SharedAudio: true, ClosedCaptions: true}).Args()
+
+	if n := len(slices.DeleteFunc(slices.Clone(args), func(arg string) bool { return arg != "a:0" })); n != 1 {
+		t.Errorf("Args() map the audio %d times, want once: %v", n, args)
+	}
+	if !containsSequence(args, []string{"-c:a:0", "aac", "-b:a:0", "192k"}) {
+		t.Errorf("Args() should encode the shared audio at the highest bitrate: %v", args)
+	}
+	want := "v:0,agroup:audio,ccgroup:cc v:1,agroup:audio,ccgroup:cc v:2,agroup:audio,ccgroup:cc a:0,agroup:audio,name:audio,default:yes"
+	if !contains(args, "-var_stream_map", want) {
+		t.Errorf("Args() = %v, want -var_stream_map %q", args, want)
+	}
+}
+
+// containsSequence reports whether seq appears in args as consecutive elements.
+func containsSequence(args, seq []string) bool {
+	for i := 0; i+len(seq) <= len(args); i++ {
+		if slices.Equal(args[i:i+len(seq)], seq) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSetAudioCodec(t *testing.T) {
+	master := filepath.Join(t.TempDir(), "master.m3u8")
+	content := "#EXTM3U\n#EXT-X-VERSION:3\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2"` + "\nstream_0/playlist.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nstream_1/playlist.m3u8\n"
+	if err := os.WriteFile(master, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetAudioCodec(master, AudioCodecTag("eac3")); err != nil {
+		t.Fatalf("SetAudioCodec() unexpected error: %v", err)
+	}
+	got, _ := os.ReadFile(master)
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,CODECS="avc1.640028,ec-3"` + "\nstream_0/playlist.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nstream_1/playlist.m3u8\n"
+	if string(got) != want {
+		t.Errorf("Master playlist =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -3,6 +3,16 @@ package hls
 import (
 	"fmt"
 	"math"
+	"strings"
+)
+
+const (
+	// HighFrameRateThreshold is the frame rate above which LimitLowFrameRates
+	// considers a source to be high frame rate.
+	HighFrameRateThreshold = 30.0
+	// LowFrameRateMaxHeight is the largest shorter edge, in pixels, of the
+	// renditions LimitLowFrameRates reduces the frame rate of.
+	LowFrameRateMaxHeight = 480
 )
 
 // DefaultBitrates provides recommended bitrate settings (video, maxrate, bufsize, audio)
@@ -25,6 +35,53 @@ var (
 	}
 )
 
+// ScalePolicy controls how a rendition is scaled when its aspect ratio differs
+// from the input video's.
+type ScalePolicy string
+
+const (
+	// ScaleExact scales the input to the exact rendition size. Auto resolutions
+	// using this policy keep the input aspect ratio. This is the default.
+	ScaleExact ScalePolicy = "exact"
+	// ScalePad scales the input to fit inside the rendition and pads the rest
+	// with black bars (letterbox/pillarbox).
+	ScalePad ScalePolicy = "pad"
+	// ScaleCrop scales the input to fill the rendition and crops the overflow.
+	ScaleCrop ScalePolicy = "crop"
+)
+
+// DefaultDimensionAlignment is the multiple rendition dimensions are snapped to
+// when no alignment is given (mod-2, required by 4:2:0 chroma subsampling).
+const DefaultDimensionAlignment = 2
+
+// ParseScalePolicy converts a policy name ("exact", "pad" or "crop",
+// case-insensitive) to a ScalePolicy. An empty name returns ScaleExact.
+func ParseScalePolicy(name string) (ScalePolicy, error) {
+	switch policy := ScalePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return ScaleExact, nil
+	case ScaleExact, ScalePad, ScaleCrop:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown scale policy %q (available: exact, pad, crop)", name)
+	}
+}
+
+// standardHeights lists the rendition sizes considered by GenerateAutoResolutions,
+// from highest to lowest, as the shorter edge in pixels.
+var standardHeights = []struct {
+	name string
+	size int
+}{
+	{"2160p", 2160},
+	{"1440p", 1440},
+	{"1080p", 1080},
+	{"720p", 720},
+	{"480p", 480},
+	{"360p", 360},
+	{"240p", 240},
+}
+
 // GenerateAutoResolutions analyzes the original video dimensions (width, height)
 // and generates a suitable set of VideoResolution structs for HLS adaptive streaming.
 // It preserves the aspect ratio of the input video and includes the original resolution
@@ -32,123 +89,175 @@ var (
 // smaller than the original.
 // It avoids upscaling (generating resolutions higher than the original).
 // The bitrates for each generated resolution are based on the DefaultBitrates map.
+// It is the same as GenerateAutoResolutionsWithPolicy with ScaleExact and
+// DefaultDimensionAlignment.
 func GenerateAutoResolutions(originalWidth, originalHeight int) []VideoResolution {
-	// Determinar se é vídeo vertical ou horizontal
-	isVertical := originalHeight > originalWidth
+	return GenerateAutoResolutionsWithPolicy(originalWidth, originalHeight, ScaleExact, DefaultDimensionAlignment)
+}
 
-	// Calcular aspect ratio (sempre como width:height)
-	var aspectRatio float64
-	if isVertical {
-		aspectRatio = float64(originalWidth) / float64(originalHeight)
-	} else {
-		aspectRatio = float64(originalHeight) / float64(originalWidth)
+// GenerateAutoResolutionsWithPolicy generates an HLS ladder for an input of the
+// given dimensions, like GenerateAutoResolutions, using the given scale policy:
+//
+//   - ScaleExact keeps the input aspect ratio: the original resolution plus the
+//     lower standard sizes (by shorter edge), e.g. 1280x720 and 854x480 for 1920x1080.
+//   - ScalePad and ScaleCrop produce standard 16:9 sizes (9:16 for vertical inputs),
+//     e.g. 1920x1080 for a 1920x800 input, padding or cropping the picture to fit.
+//     Only the sizes that do not require upscaling are included; if none does,
+//     the smallest standard size is used.
+//
+// Width and height are rounded to the nearest multiple of alignment (e.g. 2 or 4);
+// values below 2 use DefaultDimensionAlignment. Renditions of the pad and crop
+// policies have their Scale field set accordingly.
+func GenerateAutoResolutionsWithPolicy(originalWidth, originalHeight int, policy ScalePolicy, alignment int) []VideoResolution {
+	if alignment < 2 {
+		alignment = DefaultDimensionAlignment
+	}
+	if policy == ScalePad || policy == ScaleCrop {
+		return generateStandardResolutions(originalWidth, originalHeight, policy, alignment)
 	}
 
-	// Arredondar para evitar problemas de precisão
-	aspectRatio = math.Round(aspectRatio*100) / 100
-
-	// Selecionar as resoluções possíveis baseadas na resolução original
-	var resolutions []VideoResolution
+	// Determinar se é vídeo vertical ou horizontal
+	isVertical := originalHeight > originalWidth
+	long, short := longShort(originalWidth, originalHeight)
 
-	// Adicionamos sempre a resolução original primeiro com a melhor qualidade
-	var originalQuality string
-	originalMax := math.Max(float64(originalWidth), float64(originalHeight))
-
-	// Determinar a qualidade da resolução original
-	switch {
-	case originalMax >= 2160:
-		originalQuality = "2160p"
-	case originalMax >= 1440:
-		originalQuality = "1440p"
-	case originalMax >= 1080:
-		originalQuality = "1080p"
-	case originalMax >= 720:
-		originalQuality = "720p"
-	case originalMax >= 480:
-		originalQuality = "480p"
-	case originalMax >= 360:
-		originalQuality = "360p"
-	default:
-		originalQuality = "240p"
-	}
-
-	// Adicionar a resolução original primeiro
-	bitrates := DefaultBitrates[originalQuality]
-	resolutions = append(resolutions, VideoResolution{
-		Width:        originalWidth,
-		Height:       originalHeight,
-		VideoBitrate: bitrates.Video,
-		MaxRate:      bitrates.MaxRate,
-		BufSize:      bitrates.BufSize,
-		AudioBitrate: bitrates.Audio,
-	})
-
-	// Lista de resoluções padrão a considerar (do maior para o menor)
-	standardResolutions := []struct {
-		name   string
-		size   int
-		minDim int
-	}{
-		{"1080p", 1080, 1080},
-		{"720p", 720, 720},
-		{"480p", 480, 480},
-		{"360p", 360, 360},
-		{"240p", 240, 240},
+	// Adicionamos sempre a resolução original primeiro, com os bitrates
+	// da menor dimensão (1920x1080 -> 1080p, 1278x718 -> entre 480p e 720p).
+	// As dimensões são arredondadas para baixo para nunca exceder o original
+	original := VideoResolution{
+		Width:  originalWidth - originalWidth%alignment,
+		Height: originalHeight - originalHeight%alignment,
 	}
+	_, originalShort := longShort(original.Width, original.Height)
+	resolutions := []VideoResolution{withSizeBitrates(original, originalShort)}
 
-	// Adicionar resoluções inferiores à original
-	for _, res := range standardResolutions {
+	// Adicionar resoluções padrão inferiores à original, mantendo o aspect ratio
+	for _, std := range standardHeights {
 		// Pular se esta qualidade for igual ou maior que a original
-		if res.size >= int(originalMax) {
+		if std.size >= originalShort {
 			continue
 		}
 
-		// Calcular dimensões mantendo aspect ratio
-		var width, height int
-		if isVertical {
-			// Para vídeos verticais, a altura é a dimensão principal
-			height = res.size
-			width = int(math.Round(float64(height) * aspectRatio))
+		// A menor dimensão recebe o tamanho padrão e a maior segue o aspect ratio
+		scaledLong := snapDimension(float64(std.size)*float64(long)/float64(short), alignment)
+		scaledShort := snapDimension(float64(std.size), alignment)
 
-			// Garantir que largura é pelo menos a mínima esperada para esta resolução
-			if width < res.minDim/3 {
-				width = res.minDim / 3
-			}
-		} else {
-			// Para vídeos horizontais, a largura é a dimensão principal
-			width = res.size
-			height = int(math.Round(float64(width) * aspectRatio))
-
-			// Garantir que altura é pelo menos a mínima esperada para esta resolução
-			if height < res.minDim/3 {
-				height = res.minDim / 3
-			}
+		width, height := scaledLong, scaledShort
+		if isVertical {
+			width, height = scaledShort, scaledLong
 		}
 
-		// Garantir que as dimensões são números pares (requerido por alguns codecs)
-		width = width - (width % 2)
-		height = height - (height % 2)
-
 		// Pular resoluções muito pequenas
 		if width < 160 || height < 90 {
 			continue
 		}
 
-		// Adicionar esta resolução
-		bitrates = DefaultBitrates[res.name]
-		resolutions = append(resolutions, VideoResolution{
-			Width:        width,
-			Height:       height,
-			VideoBitrate: bitrates.Video,
-			MaxRate:      bitrates.MaxRate,
-			BufSize:      bitrates.BufSize,
-			AudioBitrate: bitrates.Audio,
-		})
+		resolutions = append(resolutions, withBitrates(VideoResolution{Width: width, Height: height}, std.name))
 	}
 
 	return resolutions
 }
 
+// generateStandardResolutions builds the 16:9 (or 9:16) ladder of the pad and crop
+// policies, skipping the sizes that would upscale the input.
+func generateStandardResolutions(originalWidth, originalHeight int, policy ScalePolicy, alignment int) []VideoResolution {
+	isVertical := originalHeight > originalWidth
+
+	var resolutions []VideoResolution
+	var smallest VideoResolution
+	for _, std := range standardHeights {
+		width := snapDimension(float64(std.size)*16/9, alignment)
+		height := snapDimension(float64(std.size), alignment)
+		if isVertical {
+			width, height = height, width
+		}
+		res := withBitrates(VideoResolution{Width: width, Height: height, Scale: policy}, std.name)
+		smallest = res
+
+		// Fator de escala aplicado ao vídeo original: pad encaixa o vídeo dentro
+		// da rendition, crop preenche a rendition inteira
+		scaleW := float64(width) / float64(originalWidth)
+		scaleH := float64(height) / float64(originalHeight)
+		factor := math.Min(scaleW, scaleH)
+		if policy == ScaleCrop {
+			factor = math.Max(scaleW, scaleH)
+		}
+		if factor > 1 {
+			continue
+		}
+
+		resolutions = append(resolutions, res)
+	}
+
+	if len(resolutions) == 0 {
+		resolutions = append(resolutions, smallest)
+	}
+	return resolutions
+}
+
+// qualityName returns the DefaultBitrates key for a rendition whose shorter edge
+// is size pixels.
+func qualityName(size int) string {
+	for _, std := range standardHeights {
+		if size >= std.size {
+			return std.name
+		}
+	}
+	return "240p"
+}
+
+// withSizeBitrates returns res with bitrates for a shorter edge of size pixels:
+// the DefaultBitrates of the standard size, or between two standard sizes, the
+// bitrates of the smaller one raised in proportion to the extra pixels, so
+// that a non-standard original never gets the bitrate of the standard rung
+// below it. Audio uses the bitrate of the nearest standard size.
+func withSizeBitrates(res VideoResolution, size int) VideoResolution {
+	lower := qualityName(size)
+	for i := 1; i < len(standardHeights); i++ {
+		upper, std := standardHeights[i-1], standardHeights[i]
+		if std.name != lower || size <= std.size {
+			continue
+		}
+		// Fração dos pixels adicionais entre as duas qualidades
+		frac := float64(size*size-std.size*std.size) / float64(upper.size*upper.size-std.size*std.size)
+		low, high := DefaultBitrates[std.name], DefaultBitrates[upper.name]
+		res.VideoBitrate = interpolateBitrate(low.Video, high.Video, frac)
+		res.MaxRate = interpolateBitrate(low.MaxRate, high.MaxRate, frac)
+		res.BufSize = interpolateBitrate(low.BufSize, high.BufSize, frac)
+		res.AudioBitrate = low.Audio
+		if frac >= 0.5 {
+			res.AudioBitrate = high.Audio
+		}
+		return res
+	}
+	return withBitrates(res, lower)
+}
+
+// interpolateBitrate returns the bitrate at frac (0 to 1) from low to high, in
+// kbit/s.
+func interpolateBitrate(low, high string, frac float64) string {
+	l, h := ParseBitrate(low), ParseBitrate(high)
+	return fmt.Sprintf("%dk", (l+int64(float64(h-l)*frac))/1000)
+}
+
+// withBitrates returns res with the DefaultBitrates of the given quality.
+func withBitrates(res VideoResolution, quality string) VideoResolution {
+	bitrates := DefaultBitrates[quality]
+	res.VideoBitrate = bitrates.Video
+	res.MaxRate = bitrates.MaxRate
+	res.BufSize = bitrates.BufSize
+	res.AudioBitrate = bitrates.Audio
+	return res
+}
+
+// snapDimension rounds v to the nearest multiple of alignment (never below alignment).
+func snapDimension(v float64, alignment int) int {
+	snapped := int(math.Round(v/float64(alignment))) * alignment
+	if snapped < alignment {
+		return alignment
+	}
+	return snapped
+}
+
 // GetAutoResolutionNames returns a slice of strings containing the names (like "1080p", "720p")
 // of the resolutions defined in the DefaultBitrates map.
 func GetAutoResolutionNames() []string {
@@ -169,6 +278,73 @@ func FormatAutoResolutions(resolutions []VideoResolution) string {
 			result += ", "
 		}
 		result += fmt.Sprintf("%dx%d@%s", res.Width, res.Height, res.VideoBitrate)
+		if res.FrameRate > 0 {
+			result += fmt.Sprintf("/%gfps", res.FrameRate)
+		}
 	}
 	return result
 }
+
+// RemoveUpscaled splits resolutions into the renditions that fit within the source
+// dimensions and the ones that would upscale it. Dimensions are compared
+// orientation-independently (long edge against long edge, short edge against short
+// edge). If every rendition would upscale the source, the smallest one is kept,
+// capped to the source dimensions, so the ladder is never empty.
+func RemoveUpscaled(resolutions []VideoResolution, sourceWidth, sourceHeight int) (kept, skipped []VideoResolution) {
+	srcLong, srcShort := longShort(sourceWidth, sourceHeight)
+
+	for _, res := range resolutions {
+		long, short := longShort(res.Width, res.Height)
+		if long > srcLong || short > srcShort {
+			skipped = append(skipped, res)
+			continue
+		}
+		kept = append(kept, res)
+	}
+
+	if len(kept) == 0 && len(skipped) > 0 {
+		smallest := 0
+		for i, res := range skipped {
+			if res.Width*res.Height < skipped[smallest].Width*skipped[smallest].Height {
+				smallest = i
+			}
+		}
+		capped := skipped[smallest]
+		capped.Width = sourceWidth - sourceWidth%2
+		capped.Height = sourceHeight - sourceHeight%2
+		kept = append(kept, capped)
+		skipped = append(skipped[:smallest:smallest], skipped[smallest+1:]...)
+	}
+
+	return kept, skipped
+}
+
+// LimitLowFrameRates halves high source frame rates (above HighFrameRateThreshold,
+// e.g. 50 or 60fps) for the renditions whose shorter edge is at most
+// LowFrameRateMaxHeight, following Apple's HLS authoring guidelines. Low tiers
+// gain little from the extra frames and save a large share of their bitrate.
+// Renditions with an explicit FrameRate are left unchanged, as is the whole ladder
+// when the source frame rate is unknown (zero) or not high.
+// The input slice is not modified.
+func LimitLowFrameRates(resolutions []VideoResolution, sourceFrameRate float64) []VideoResolution {
+	limited := append([]VideoResolution(nil), resolutions...)
+	if sourceFrameRate <= HighFrameRateThreshold {
+		return limited
+	}
+
+	for i := range limited {
+		_, short := longShort(limited[i].Width, limited[i].Height)
+		if limited[i].FrameRate == 0 && short <= LowFrameRateMaxHeight {
+			limited[i].FrameRate = sourceFrameRate / 2
+		}
+	}
+	return limited
+}
+
+// longShort returns the longer and the shorter of two dimensions.
+func longShort(a, b int) (int, int) {
+	if a >= b {
+		return a, b
+	}
+	return b, a
+}
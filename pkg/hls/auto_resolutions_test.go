@@ -7,92 +7,99 @@ import (
 )
 
 func TestGenerateAutoResolutionsHorizontal(t *testing.T) {
-	// Test case 1: Input is exactly 1080p
+	// Test case 1: 1080p Input
 	res1 := GenerateAutoResolutions(1920, 1080)
-	// NOTE: Current logic assigns 1440p bitrates to 1080p original resolution. Adjusting test expectation.
-	// NOTE: Also, the aspect ratio calculation might lead to slightly different lower resolutions.
+	// The original uses the bitrates of its shorter edge and the lower renditions are standard 16:9 sizes.
 	expected1 := []VideoResolution{
-		{Width: 1920, Height: 1080, VideoBitrate: "9000k", MaxRate: "9630k", BufSize: "13500k", AudioBitrate: "192k"}, // Original (Uses 1440p bitrate)
-		{Width: 1080, Height: 604, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
-		{Width: 720, Height: 402, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
-		{Width: 480, Height: 268, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
-		{Width: 360, Height: 202, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
-		{Width: 240, Height: 134, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
+		{Width: 1920, Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
+		{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 426, Height: 240, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
 	}
 	if !reflect.DeepEqual(res1, expected1) {
 		msg := fmt.Sprintf("Test Case 1 (1080p Input) Failed:\nGot:      %+v\nExpected: %+v", res1, expected1)
 		t.Error(msg)
 	}
 
-	// Test case 2: Input is 720p
+	// Test case 2: 720p Input
 	res2 := GenerateAutoResolutions(1280, 720)
-	// NOTE: Current logic assigns 1080p bitrates to 720p original resolution. Adjusting.
 	expected2 := []VideoResolution{
-		{Width: 1280, Height: 720, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"}, // Original (Uses 1080p bitrate)
-		{Width: 1080, Height: 604, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
-		{Width: 720, Height: 402, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
-		{Width: 480, Height: 268, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
-		{Width: 360, Height: 202, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
-		{Width: 240, Height: 134, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
+		{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 426, Height: 240, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
 	}
 	if !reflect.DeepEqual(res2, expected2) {
 		msg := fmt.Sprintf("Test Case 2 (720p Input) Failed:\nGot:      %+v\nExpected: %+v", res2, expected2)
 		t.Error(msg)
 	}
 
-	// Test case 3: Input is higher than 1080p (e.g., 4K)
+	// Test case 3: 4K Input
 	res3 := GenerateAutoResolutions(3840, 2160)
 	expected3 := []VideoResolution{
-		{Width: 3840, Height: 2160, VideoBitrate: "15000k", MaxRate: "16050k", BufSize: "22500k", AudioBitrate: "192k"}, // Original (Correctly uses 2160p)
-		{Width: 1080, Height: 604, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
-		{Width: 720, Height: 402, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
-		{Width: 480, Height: 268, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
-		{Width: 360, Height: 202, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
-		{Width: 240, Height: 134, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
+		{Width: 3840, Height: 2160, VideoBitrate: "15000k", MaxRate: "16050k", BufSize: "22500k", AudioBitrate: "192k"},
+		{Width: 2560, Height: 1440, VideoBitrate: "9000k", MaxRate: "9630k", BufSize: "13500k", AudioBitrate: "192k"},
+		{Width: 1920, Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
+		{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 426, Height: 240, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
 	}
 	if !reflect.DeepEqual(res3, expected3) {
 		msg := fmt.Sprintf("Test Case 3 (4K Input) Failed:\nGot:      %+v\nExpected: %+v", res3, expected3)
 		t.Error(msg)
 	}
 
-	// Test case 4: Input is very small (320x180)
+	// Test case 4: Small Input 320x180
 	res4 := GenerateAutoResolutions(320, 180)
-	// NOTE: Uses lowest bitrate (240p) for original. Adjusting.
+	// Uses lowest bitrate (240p) for the original and has no lower standard size.
 	expected4 := []VideoResolution{
-		{Width: 320, Height: 180, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"}, // Original (Uses 240p bitrate)
-		{Width: 240, Height: 134, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
+		{Width: 320, Height: 180, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
 	}
 	if !reflect.DeepEqual(res4, expected4) {
 		msg := fmt.Sprintf("Test Case 4 (Small Input 320x180) Failed:\nGot:      %+v\nExpected: %+v", res4, expected4)
 		t.Error(msg)
 	}
+
+	// Test case 5: Odd Input 1279x719
+	res5 := GenerateAutoResolutions(1279, 719)
+	// The original is snapped down to even dimensions, and its 718px shorter edge gets
+	// bitrates between 480p and 720p, above the 480p rung.
+	expected5 := []VideoResolution{
+		{Width: 1278, Height: 718, VideoBitrate: "2786k", MaxRate: "2981k", BufSize: "4179k", AudioBitrate: "128k"},
+		{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 426, Height: 240, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
+	}
+	if !reflect.DeepEqual(res5, expected5) {
+		msg := fmt.Sprintf("Test Case 5 (Odd Input 1279x719) Failed:\nGot:      %+v\nExpected: %+v", res5, expected5)
+		t.Error(msg)
+	}
 }
 
 func TestGenerateAutoResolutionsVertical(t *testing.T) {
-	// Test case 1: Vertical 1080x1920 input
+	// Test case 1: Vertical 1080x1920
 	res1 := GenerateAutoResolutions(1080, 1920)
-	// NOTE: Current logic assigns 1440p bitrates to 1080 original width. Adjusting.
 	expected1 := []VideoResolution{
-		{Width: 1080, Height: 1920, VideoBitrate: "9000k", MaxRate: "9630k", BufSize: "13500k", AudioBitrate: "192k"}, // Original (Uses 1440p bitrate)
-		{Width: 604, Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
-		{Width: 402, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
-		{Width: 268, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
-		{Width: 202, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 1080, Height: 1920, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
+		{Width: 720, Height: 1280, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		{Width: 480, Height: 854, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
+		{Width: 360, Height: 640, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 240, Height: 426, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
 	}
 	if !reflect.DeepEqual(res1, expected1) {
 		msg := fmt.Sprintf("Test Case 1 (Vertical 1080x1920) Failed:\nGot:      %+v\nExpected: %+v", res1, expected1)
 		t.Error(msg)
 	}
 
-	// Test case 2: Vertical 720x1280 input
+	// Test case 2: Vertical 720x1280
 	res2 := GenerateAutoResolutions(720, 1280)
-	// NOTE: Current logic assigns 1080p bitrates to 720 original width. Adjusting.
 	expected2 := []VideoResolution{
-		{Width: 720, Height: 1280, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"}, // Original (Uses 1080p bitrate)
-		{Width: 604, Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
-		{Width: 402, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
-		{Width: 268, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
-		{Width: 202, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 720, Height: 1280, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		{Width: 480, Height: 854, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
+		{Width: 360, Height: 640, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+		{Width: 240, Height: 426, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},
 	}
 	if !reflect.DeepEqual(res2, expected2) {
 		msg := fmt.Sprintf("Test Case 2 (Vertical 720x1280) Failed:\nGot:      %+v\nExpected: %+v", res2, expected2)
@@ -100,6 +107,54 @@ func TestGenerateAutoResolutionsVertical(t *testing.T) {
 	}
 }
 
+func TestGenerateAutoResolutionsWithPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		width     int
+		height    int
+		policy    ScalePolicy
+		alignment int
+		want      []string
+	}{
+		{"exact mod-4", 1920, 1080, ScaleExact, 4, []string{"1920x1080", "1280x720", "852x480", "640x360", "428x240"}},
+		{"pad 2.4:1 input", 1920, 800, ScalePad, 2, []string{"1920x1080", "1280x720", "854x480", "640x360", "426x240"}},
+		{"crop 2.4:1 input never upscales", 1920, 800, ScaleCrop, 2, []string{"1280x720", "854x480", "640x360", "426x240"}},
+		{"pad vertical input", 1080, 1920, ScalePad, 2, []string{"1080x1920", "720x1280", "480x854", "360x640", "240x426"}},
+		{"crop tiny input keeps smallest", 320, 240, ScaleCrop, 4, []string{"428x240"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateAutoResolutionsWithPolicy(tt.width, tt.height, tt.policy, tt.alignment)
+			var sizes []string
+			for _, res := range got {
+				sizes = append(sizes, fmt.Sprintf("%dx%d", res.Width, res.Height))
+				if res.Width%tt.alignment != 0 || res.Height%tt.alignment != 0 {
+					t.Errorf("%dx%d is not aligned to %d", res.Width, res.Height, tt.alignment)
+				}
+				if tt.policy != ScaleExact && res.Scale != tt.policy {
+					t.Errorf("%dx%d Scale = %q, want %q", res.Width, res.Height, res.Scale, tt.policy)
+				}
+			}
+			if !reflect.DeepEqual(sizes, tt.want) {
+				t.Errorf("sizes = %v, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScalePolicy(t *testing.T) {
+	for name, want := range map[string]ScalePolicy{"": ScaleExact, "exact": ScaleExact, "PAD": ScalePad, " crop ": ScaleCrop} {
+		got, err := ParseScalePolicy(name)
+		if err != nil || got != want {
+			t.Errorf("ParseScalePolicy(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseScalePolicy("stretch"); err == nil {
+		t.Error("ParseScalePolicy(\"stretch\") expected an error")
+	}
+}
+
 func TestGetAutoResolutionNames(t *testing.T) {
 	names := GetAutoResolutionNames()
 	// Check if common names exist, order doesn't matter
@@ -134,3 +189,102 @@ func TestFormatAutoResolutions(t *testing.T) {
 		t.Errorf("FormatAutoResolutions() with empty slice = %q, want %q", resultEmpty, expectedEmpty)
 	}
 }
+
+func TestRemoveUpscaled(t *testing.T) {
+	tests := []struct {
+		name        string
+		width       int
+		height      int
+		wantKept    []string
+		wantSkipped []string
+	}{
+		{
+			name:        "720p source",
+			width:       1280,
+			height:      720,
+			wantKept:    []string{"1280x720", "854x480"},
+			wantSkipped: []string{"1920x1080"},
+		},
+		{
+			name:     "1080p source keeps everything",
+			width:    1920,
+			height:   1080,
+			wantKept: []string{"1920x1080", "1280x720", "854x480"},
+		},
+		{
+			name:        "Vertical 720p source",
+			width:       720,
+			height:      1280,
+			wantKept:    []string{"1280x720", "854x480"},
+			wantSkipped: []string{"1920x1080"},
+		},
+		{
+			name:        "Tiny source caps the smallest rendition",
+			width:       320,
+			height:      181,
+			wantKept:    []string{"320x180"},
+			wantSkipped: []string{"1920x1080", "1280x720"},
+		},
+	}
+
+	sizes := func(resolutions []VideoResolution) []string {
+		var out []string
+		for _, res := range resolutions {
+			out = append(out, fmt.Sprintf("%dx%d", res.Width, res.Height))
+		}
+		return out
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, skipped := RemoveUpscaled(DefaultResolutions, tt.width, tt.height)
+			if !reflect.DeepEqual(sizes(kept), tt.wantKept) {
+				t.Errorf("kept = %v, want %v", sizes(kept), tt.wantKept)
+			}
+			if !reflect.DeepEqual(sizes(skipped), tt.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", sizes(skipped), tt.wantSkipped)
+			}
+		})
+	}
+
+	if DefaultResolutions[2].Width != 854 {
+		t.Error("RemoveUpscaled() must not modify the input slice")
+	}
+}
+
+func TestLimitLowFrameRates(t *testing.T) {
+	frameRates := func(resolutions []VideoResolution) []float64 {
+		var out []float64
+		for _, res := range resolutions {
+			out = append(out, res.FrameRate)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name       string
+		ladder     []VideoResolution
+		sourceFPS  float64
+		wantFrames []float64
+	}{
+		{"60fps halves 480p", DefaultResolutions, 60, []float64{0, 0, 30}},
+		{"59.94fps halves 480p", DefaultResolutions, 60000.0 / 1001, []float64{0, 0, 30000.0 / 1001}},
+		{"30fps unchanged", DefaultResolutions, 30, []float64{0, 0, 0}},
+		{"unknown fps unchanged", DefaultResolutions, 0, []float64{0, 0, 0}},
+		{"vertical 480p", []VideoResolution{{Width: 1080, Height: 1920}, {Width: 480, Height: 854}}, 50, []float64{0, 25}},
+		{"explicit frame rate kept", []VideoResolution{{Width: 640, Height: 360, FrameRate: 24}}, 60, []float64{24}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LimitLowFrameRates(tt.ladder, tt.sourceFPS)
+			if !reflect.DeepEqual(frameRates(got), tt.wantFrames) {
+				t.Errorf("frame rates = %v, want %v", frameRates(got), tt.wantFrames)
+			}
+		})
+	}
+
+	if DefaultResolutions[2].FrameRate != 0 {
+		t.Error("LimitLowFrameRates() must not modify the input slice")
+	}
+}
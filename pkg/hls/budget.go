@@ -0,0 +1,121 @@
+package hls
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// MinBudgetBitrateScale is the lowest factor FitToBudget scales video bitrates by
+// before dropping the highest rendition instead. Scaling further usually produces
+// visibly worse quality than serving a smaller ladder.
+const MinBudgetBitrateScale = 0.5
+
+// ParseBitrate converts an ffmpeg bitrate such as "2800k", "5M" or "128000" to
+// bits per second. Returns 0 if the value cannot be parsed.
+func ParseBitrate(bitrate string) int64 {
+	s := strings.TrimSpace(bitrate)
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		multiplier = 1e3
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "m"), strings.HasSuffix(s, "M"):
+		multiplier = 1e6
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return int64(v * multiplier)
+}
+
+// EstimateSize returns the approximate size in bytes of a rendition encoded for
+// duration seconds at its target video and audio bitrates.
+func EstimateSize(res VideoResolution, duration float64) int64 {
+	return EstimateSizeWithOverhead(res, duration, 0)
+}
+
+// EstimateSizeWithOverhead is like EstimateSize, adding the muxing overhead of
+// the container as a share of the encoded size (see DefaultMuxOverhead).
+func EstimateSizeWithOverhead(res VideoResolution, duration, overhead float64) int64 {
+	bitrate := ParseBitrate(res.VideoBitrate) + ParseBitrate(res.AudioBitrate)
+	return int64(math.Round(float64(bitrate) * (1 + overhead) * duration / 8))
+}
+
+// FitToBudget adapts a ladder so that its estimated total size for an input of
+// duration seconds does not exceed maxBytes.
+// Video bitrates (and the related maxrate/bufsize) are scaled down proportionally,
+// by no less than MinBudgetBitrateScale; if that is not enough, the highest
+// rendition is dropped and the remaining ladder is tried again. Audio bitrates are
+// kept as is. The input slice is not modified.
+// Returns an error if even the lowest rendition does not fit the budget.
+func FitToBudget(resolutions []VideoResolution, duration float64, maxBytes int64) ([]VideoResolution, error) {
+	return FitToBudgetWithOverhead(resolutions, duration, maxBytes, 0)
+}
+
+// FitToBudgetWithOverhead is like FitToBudget, accounting for the muxing
+// overhead of the container as a share of the encoded size (see
+// DefaultMuxOverhead), so that the segments written fit maxBytes.
+func FitToBudgetWithOverhead(resolutions []VideoResolution, duration float64, maxBytes int64, overhead float64) ([]VideoResolution, error) {
+	if maxBytes <= 0 || duration <= 0 {
+		return resolutions, nil
+	}
+
+	ladder := append([]VideoResolution(nil), resolutions...)
+	budgetBits := float64(maxBytes) * 8 / duration / (1 + overhead)
+	for len(ladder) > 0 {
+		var video, audio float64
+		for _, res := range ladder {
+			video += float64(ParseBitrate(res.VideoBitrate))
+			audio += float64(ParseBitrate(res.AudioBitrate))
+		}
+
+		if video+audio <= budgetBits {
+			return ladder, nil
+		}
+		if video > 0 {
+			if scale := (budgetBits - audio) / video; scale >= MinBudgetBitrateScale {
+				for i := range ladder {
+					ladder[i].VideoBitrate = scaleBitrate(ladder[i].VideoBitrate, scale)
+					ladder[i].MaxRate = scaleBitrate(ladder[i].MaxRate, scale)
+					ladder[i].BufSize = scaleBitrate(ladder[i].BufSize, scale)
+				}
+				return ladder, nil
+			}
+		}
+
+		ladder = dropHighest(ladder)
+	}
+
+	return nil, errors.New(errors.ValidationError,
+		"Output size budget is too small for the requested resolutions",
+		fmt.Sprintf("Budget of %d bytes for %.1fs of video cannot fit the lowest rendition", maxBytes, duration),
+		10)
+}
+
+// dropHighest removes the rendition with the most pixels, keeping the order of the others.
+func dropHighest(ladder []VideoResolution) []VideoResolution {
+	highest := 0
+	for i, res := range ladder {
+		if res.Width*res.Height > ladder[highest].Width*ladder[highest].Height {
+			highest = i
+		}
+	}
+	return append(ladder[:highest], ladder[highest+1:]...)
+}
+
+// scaleBitrate multiplies a bitrate string by scale, returning it in kbit/s.
+// Empty or unparsable values are returned unchanged.
+func scaleBitrate(bitrate string, scale float64) string {
+	v := ParseBitrate(bitrate)
+	if v == 0 {
+		return bitrate
+	}
+	return fmt.Sprintf("%dk", int64(float64(v)*scale)/1000)
+}
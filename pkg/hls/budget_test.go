@@ -0,0 +1,116 @@
+package hls
+
+import (
+	"testing"
+)
+
+func TestParseBitrate(t *testing.T) {
+	tests := map[string]int64{
+		"2800k":  2800000,
+		"5M":     5000000,
+		"128000": 128000,
+		"1.5m":   1500000,
+		"":       0,
+		"abc":    0,
+	}
+	for in, want := range tests {
+		if got := ParseBitrate(in); got != want {
+			t.Errorf("ParseBitrate(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	res := VideoResolution{VideoBitrate: "2800k", AudioBitrate: "128k"}
+	if got := EstimateSize(res, 60); got != 21960000 {
+		t.Errorf("EstimateSize() = %d, want 21960000", got)
+	}
+	if got := EstimateSizeWithOverhead(res, 60, MPEGTSOverhead); got != 23277600 {
+		t.Errorf("EstimateSizeWithOverhead() = %d, want 23277600", got)
+	}
+}
+
+func TestFitToBudgetWithOverhead(t *testing.T) {
+	ladder := []VideoResolution{
+		{Width: 1280, Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", AudioBitrate: "64k"},
+	}
+	// 47.4 MB of encoded streams fit 50 MB, but not once muxed in MPEG-TS (50.2 MB).
+	got, err := FitToBudgetWithOverhead(ladder, 100, 50_000_000, MPEGTSOverhead)
+	if err != nil {
+		t.Fatalf("FitToBudgetWithOverhead() unexpected error: %v", err)
+	}
+	var total int64
+	for _, res := range got {
+		total += EstimateSizeWithOverhead(res, 100, MPEGTSOverhead)
+	}
+	if got[0].VideoBitrate == "2800k" || total > 50_000_000 {
+		t.Errorf("FitToBudgetWithOverhead() = %+v (%d bytes), want lower bitrates within 50 MB", got, total)
+	}
+}
+
+func TestFitToBudget(t *testing.T) {
+	ladder := []VideoResolution{
+		{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
+	}
+	// Full ladder: (2800k + 128k + 800k + 64k) * 100s / 8 = 47.4 MB.
+	const duration = 100
+
+	t.Run("Fits unchanged", func(t *testing.T) {
+		got, err := FitToBudget(ladder, duration, 50_000_000)
+		if err != nil {
+			t.Fatalf("FitToBudget() unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0].VideoBitrate != "2800k" || got[1].VideoBitrate != "800k" {
+			t.Errorf("Ladder should be unchanged, got %+v", got)
+		}
+	})
+
+	t.Run("Bitrates scaled", func(t *testing.T) {
+		// 30 MB leaves 2400k - 192k of audio = 2208k for 3600k of video (scale ~0.61).
+		got, err := FitToBudget(ladder, duration, 30_000_000)
+		if err != nil {
+			t.Fatalf("FitToBudget() unexpected error: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("FitToBudget() kept %d renditions, want 2", len(got))
+		}
+		if got[0].VideoBitrate != "1717k" || got[0].MaxRate != "1837k" || got[1].VideoBitrate != "490k" || got[1].AudioBitrate != "64k" {
+			t.Errorf("Unexpected scaled ladder: %+v", got)
+		}
+		var total int64
+		for _, res := range got {
+			total += EstimateSize(res, duration)
+		}
+		if total > 30_000_000 {
+			t.Errorf("Scaled ladder estimate %d exceeds the budget", total)
+		}
+		if ladder[0].VideoBitrate != "2800k" {
+			t.Error("FitToBudget() must not modify the input slice")
+		}
+	})
+
+	t.Run("Top rendition dropped", func(t *testing.T) {
+		got, err := FitToBudget(ladder, duration, 10_000_000)
+		if err != nil {
+			t.Fatalf("FitToBudget() unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].Height != 360 {
+			t.Errorf("Expected only the 360p rendition, got %+v", got)
+		}
+	})
+
+	t.Run("Impossible budget", func(t *testing.T) {
+		if _, err := FitToBudget(ladder, duration, 1_000_000); err == nil {
+			t.Error("FitToBudget() should fail when no rendition fits")
+		}
+	})
+
+	t.Run("No budget", func(t *testing.T) {
+		got, err := FitToBudget(ladder, duration, 0)
+		if err != nil || len(got) != 2 {
+			t.Errorf("FitToBudget() with no budget = %v, %v", got, err)
+		}
+	})
+}
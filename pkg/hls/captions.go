@@ -0,0 +1,72 @@
+package hls
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// CaptionGroupID is the GROUP-ID of the closed captions declared in master
+// playlists when Options.ClosedCaptions is set.
+const CaptionGroupID = "cc"
+
+// SubtitleGroupID is the GROUP-ID of the subtitle renditions added by AddSubtitles.
+const SubtitleGroupID = "subs"
+
+// DefaultCaptionLanguage is the language of closed captions when
+// Options.CaptionLanguage is empty.
+const DefaultCaptionLanguage = "en"
+
+// captionStreamMap returns the ffmpeg -cc_stream_map value declaring the first
+// CEA-608 channel (CC1) of the video in language.
+func captionStreamMap(language string) string {
+	return fmt.Sprintf("ccgroup:%s,instreamid:CC1,language:%s", CaptionGroupID, language)
+}
+
+// SubtitleRendition describes a subtitle rendition of a master playlist.
+type SubtitleRendition struct {
+	// Name is the name shown to viewers, e.g. "English (CC)".
+	Name string
+	// Language is the language tag of the subtitles, e.g. "en".
+	Language string
+	// URI is the subtitle media playlist, relative to the master playlist.
+	URI string
+}
+
+// AddSubtitles declares the subtitle rendition r in the master playlist at
+// masterPath (EXT-X-MEDIA TYPE=SUBTITLES) and links every variant to it.
+func AddSubtitles(masterPath string, r SubtitleRendition) error {
+	content, err := os.ReadFile(masterPath)
+	if err != nil {
+		return err
+	}
+
+	media := fmt.Sprintf(`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="%s",NAME=%q,LANGUAGE=%q,DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI=%q`,
+		SubtitleGroupID, r.Name, r.Language, r.URI)
+	var out strings.Builder
+	added := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !added {
+				out.WriteString(media + "\n")
+				added = true
+			}
+			line = strings.TrimRight(line, "\r\n") + fmt.Sprintf(`,SUBTITLES="%s"`, SubtitleGroupID) + "\n"
+		}
+		out.WriteString(line)
+	}
+	if !added {
+		return fmt.Errorf("%s: no variant streams to add the subtitles to", masterPath)
+	}
+	return os.WriteFile(masterPath, []byte(out.String()), 0644)
+}
+
+// WriteSubtitlePlaylist writes a VOD media playlist at path made of the single
+// WebVTT file segment, lasting duration seconds.
+func WriteSubtitlePlaylist(path, segment string, duration float64) error {
+	playlist := fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:%.6f,\n%s\n#EXT-X-ENDLIST\n",
+		int(math.Ceil(duration)), duration, segment)
+	return os.WriteFile(path, []byte(playlist), 0644)
+}
@@ -0,0 +1,62 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildFFmpegArgsClosedCaptions(t *testing.T) {
+	g := New(Options{
+		InputFile:       "input.ts",
+		OutputDir:       "out",
+		ClosedCaptions:  true,
+		CaptionLanguage: "pt-BR",
+		Resolutions:     []VideoResolution{DefaultResolutions[0], DefaultResolutions[1]},
+	})
+	args := g.Args()
+
+	if !contains(args, "-a53cc", "1") {
+		t.Errorf("Args should keep the captions with -a53cc 1: %v", args)
+	}
+	if !contains(args, "-cc_stream_map", "ccgroup:cc,instreamid:CC1,language:pt-BR") {
+		t.Errorf("Args should declare the captions with -cc_stream_map: %v", args)
+	}
+	if !contains(args, "-var_stream_map", "v:0,a:0,ccgroup:cc v:1,a:1,ccgroup:cc") {
+		t.Errorf("Every variant should reference the caption group: %v", args)
+	}
+
+	if args := New(Options{InputFile: "input.ts", OutputDir: "out"}).Args(); contains(args, "-a53cc", "1") {
+		t.Errorf("Args should not keep captions unless enabled: %v", args)
+	}
+}
+
+func TestAddSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "master.m3u8")
+	content := "#EXTM3U\n#EXT-X-VERSION:3\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720\nstream_0/playlist.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nstream_1/playlist.m3u8\n"
+	if err := os.WriteFile(master, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := AddSubtitles(master, SubtitleRendition{Name: "Closed captions (en)", Language: "en", URI: "subs/playlist.m3u8"})
+	if err != nil {
+		t.Fatalf("AddSubtitles() unexpected error: %v", err)
+	}
+	got, _ := os.ReadFile(master)
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Closed captions (en)",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI="subs/playlist.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720,SUBTITLES="subs"` + "\nstream_0/playlist.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,SUBTITLES="subs"` + "\nstream_1/playlist.m3u8\n"
+	if string(got) != want {
+		t.Errorf("Master playlist =\n%s\nwant\n%s", got, want)
+	}
+
+	empty := filepath.Join(dir, "empty.m3u8")
+	os.WriteFile(empty, []byte("#EXTM3U\n"), 0644)
+	if err := AddSubtitles(empty, SubtitleRendition{URI: "subs/playlist.m3u8"}); err == nil {
+		t.Error("AddSubtitles() expected an error for a master playlist without variants")
+	}
+}
@@ -0,0 +1,55 @@
+package hls
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChunkArgs returns the ffmpeg arguments encoding the video of chunk, a part
+// of the input split at a keyframe, in every resolution: the video of
+// resolution i is written to outputs[i] as MPEG-TS, with a keyframe every
+// SegmentDuration seconds. Stitched with WriteConcatList, the chunks of each
+// resolution are segmented without re-encoding (see Options.VideoConcatLists).
+func (g *Generator) ChunkArgs(chunk string, outputs []string) []string {
+	args := []string{
+		"-v", "error",
+		"-i", chunk,
+		"-filter_complex", buildFilterGraph(len(g.options.Resolutions), g.options.Resolutions, SourceFilter(g.options.Deinterlace, g.options.Color)),
+	}
+	for i, res := range g.options.Resolutions {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%dout]", i),
+			"-c:v", "libx264",
+		)
+		args = append(args, res.EncoderSettings.Or(g.options.EncoderSettings).Args("v")...)
+		args = append(args, g.options.Color.Args("v")...)
+		args = append(args,
+			"-b:v", res.VideoBitrate,
+			"-maxrate:v", res.MaxRate,
+			"-bufsize:v", res.BufSize,
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", g.options.SegmentDuration),
+		)
+		args = append(args, res.ExtraArgs("v")...)
+		if g.options.ClosedCaptions {
+			args = append(args, "-a53cc", "1")
+		}
+		if g.options.Deterministic {
+			args = append(args, DeterministicArgs()...)
+		}
+		args = append(args, "-f", "mpegts", "-y", outputs[i])
+	}
+	return args
+}
+
+// WriteConcatList writes an ffconcat list of files, to be read by the ffmpeg
+// concat demuxer, at path. Relative file paths are relative to the list.
+func WriteConcatList(path string, files []string) error {
+	var b strings.Builder
+	b.WriteString("ffconcat version 1.0\n")
+	for _, file := range files {
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(filepath.ToSlash(file), "'", `'\''`))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
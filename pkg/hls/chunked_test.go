@@ -0,0 +1,67 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkArgs(t *testing.T) {
+	g := New(Options{
+		SegmentDuration: 6,
+		ClosedCaptions:  true,
+		Resolutions: []VideoResolution{
+			{Width: 1280, Height: 720, VideoBitrate: "2M", MaxRate: "2.2M", BufSize: "4M"},
+			{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "880k", BufSize: "1.6M"},
+		},
+	})
+	args := g.ChunkArgs("source00003.mkv", []string{"r0_00003.ts", "r1_00003.ts"})
+
+	if !contains(args, "-i", "source00003.mkv") ||
+		!contains(args, "-filter_complex", "[0:v]split=2[v0][v1]; [v0]scale=w=1280:h=720[v0out]; [v1]scale=w=640:h=360[v1out]") {
+		t.Errorf("Missing input or filter graph in args: %v", args)
+	}
+	if !contains(args, "-map", "[v1out]") || !contains(args, "-b:v", "800k") ||
+		!contains(args, "-force_key_frames", "expr:gte(t,n_forced*6)") || !contains(args, "-a53cc", "1") {
+		t.Errorf("Missing video options in args: %v", args)
+	}
+	if !contains(args, "-y", "r0_00003.ts") || !endsWith(args, "r1_00003.ts") {
+		t.Errorf("Missing outputs in args: %v", args)
+	}
+}
+
+func TestBuildPackagingArgs(t *testing.T) {
+	opts := Options{
+		InputFile:        "input.mp4",
+		OutputDir:        "out",
+		VideoConcatLists: []string{"rendition_0.ffconcat", "rendition_1.ffconcat"},
+		Resolutions:      DefaultResolutions[1:],
+		SharedAudio:      true,
+	}
+	args := New(opts).Args()
+
+	if !contains(args, "-i", "rendition_1.ffconcat") || !contains(args, "-safe", "0") || !contains(args, "-i", "input.mp4") {
+		t.Errorf("Missing inputs in args: %v", args)
+	}
+	if !contains(args, "-map", "1:v:0") || !contains(args, "-c:v:1", "copy") || contains(args, "-c:v:0", "libx264") {
+		t.Errorf("Video is not copied in args: %v", args)
+	}
+	if !contains(args, "-map", "2:a:0") || !contains(args, "-b:a:0", "128k") {
+		t.Errorf("Audio is not read from the input in args: %v", args)
+	}
+	if argsToMap(args)["-var_stream_map"] != "v:0,agroup:audio v:1,agroup:audio a:0,agroup:audio,name:audio,default:yes" {
+		t.Errorf("Incorrect -var_stream_map in args: %v", args)
+	}
+}
+
+func TestWriteConcatList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.ffconcat")
+	if err := WriteConcatList(path, []string{"r0_00000.ts", "it's.ts"}); err != nil {
+		t.Fatalf("WriteConcatList() error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "ffconcat version 1.0\nfile 'r0_00000.ts'\nfile 'it'\\''s.ts'\n"
+	if string(data) != want {
+		t.Errorf("List =\n%s\nwant\n%s", data, want)
+	}
+}
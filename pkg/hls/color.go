@@ -0,0 +1,92 @@
+package hls
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PixelFormat is the pixel format of the encoded video, setting its bit
+// depth and chroma subsampling.
+type PixelFormat string
+
+const (
+	// PixelFormatYUV420P is 8-bit 4:2:0, the only format every H.264 decoder
+	// supports.
+	PixelFormatYUV420P PixelFormat = "yuv420p"
+	// PixelFormatYUV420P10 is 10-bit 4:2:0, requiring the high10 profile
+	// (main10 for HEVC).
+	PixelFormatYUV420P10 PixelFormat = "yuv420p10le"
+	PixelFormatYUV422P   PixelFormat = "yuv422p"
+	PixelFormatYUV422P10 PixelFormat = "yuv422p10le"
+	PixelFormatYUV444P   PixelFormat = "yuv444p"
+	PixelFormatYUV444P10 PixelFormat = "yuv444p10le"
+)
+
+var pixelFormats = []PixelFormat{PixelFormatYUV420P, PixelFormatYUV420P10, PixelFormatYUV422P, PixelFormatYUV422P10, PixelFormatYUV444P, PixelFormatYUV444P10}
+
+// Validate checks that p is a pixel format x264 can encode, or empty.
+func (p PixelFormat) Validate() error {
+	if p != "" && !slices.Contains(pixelFormats, p) {
+		return fmt.Errorf("unknown pixel format %q (available: %s)", p, joinNames(pixelFormats))
+	}
+	return nil
+}
+
+// ColorSettings are the pixel format and the color tags of the encoded
+// video. Empty fields leave the input as is.
+type ColorSettings struct {
+	// PixelFormat converts the video to this pixel format before it is
+	// scaled, e.g. 10-bit 4:2:2 masters to PixelFormatYUV420P.
+	PixelFormat PixelFormat
+	// ColorSpace is the matrix coefficients tag, e.g. "bt709".
+	ColorSpace string
+	// ColorTransfer is the transfer characteristics tag, e.g. "bt709".
+	ColorTransfer string
+	// ColorPrimaries is the color primaries tag, e.g. "bt709".
+	ColorPrimaries string
+	// ColorRange is the color range tag: "tv" (limited) or "pc" (full).
+	ColorRange string
+}
+
+// Filter returns the filter converting the video to PixelFormat, or "" if
+// PixelFormat is empty.
+func (c ColorSettings) Filter() string {
+	if c.PixelFormat == "" {
+		return ""
+	}
+	return "format=" + string(c.PixelFormat)
+}
+
+// Args returns the ffmpeg arguments tagging the video streams selected by
+// stream, a stream specifier such as "v:0", with the color tags.
+func (c ColorSettings) Args(stream string) []string {
+	if stream != "" {
+		stream = ":" + stream
+	}
+	var args []string
+	for _, tag := range []struct{ name, value string }{
+		{"-colorspace", c.ColorSpace},
+		{"-color_trc", c.ColorTransfer},
+		{"-color_primaries", c.ColorPrimaries},
+		{"-color_range", c.ColorRange},
+	} {
+		if tag.value != "" {
+			args = append(args, tag.name+stream, tag.value)
+		}
+	}
+	return args
+}
+
+// SourceFilter returns the filter chain applied to the input video before it
+// is scaled: the deinterlacer, then the pixel format conversion of color.
+// Returns "" if there is none.
+func SourceFilter(deinterlace Deinterlacer, color ColorSettings) string {
+	var filters []string
+	for _, filter := range []string{deinterlace.Filter(), color.Filter()} {
+		if filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+	return strings.Join(filters, ",")
+}
@@ -0,0 +1,54 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorSettings(t *testing.T) {
+	color := ColorSettings{PixelFormat: PixelFormatYUV420P, ColorSpace: "bt709", ColorTransfer: "bt709", ColorPrimaries: "bt709", ColorRange: "tv"}
+	if got, want := strings.Join(color.Args("v:1"), " "), "-colorspace:v:1 bt709 -color_trc:v:1 bt709 -color_primaries:v:1 bt709 -color_range:v:1 tv"; got != want {
+		t.Errorf("Args() = %q, want %q", got, want)
+	}
+	if got := (ColorSettings{}).Args("v"); len(got) != 0 {
+		t.Errorf("Args() without tags = %q, want none", got)
+	}
+
+	for _, tt := range []struct {
+		deinterlace Deinterlacer
+		color       ColorSettings
+		want        string
+	}{
+		{"", ColorSettings{}, ""},
+		{"", color, "format=yuv420p"},
+		{DeinterlaceYadif, color, "yadif=mode=send_frame,format=yuv420p"},
+	} {
+		if got := SourceFilter(tt.deinterlace, tt.color); got != tt.want {
+			t.Errorf("SourceFilter(%q, %+v) = %q, want %q", tt.deinterlace, tt.color, got, tt.want)
+		}
+	}
+
+	if err := PixelFormatYUV422P10.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := PixelFormat("rgb24").Validate(); err == nil {
+		t.Error("Validate() expected an error for rgb24")
+	}
+}
+
+func TestColorArgs(t *testing.T) {
+	g := New(Options{
+		InputFile: "master.mov",
+		OutputDir: "out",
+		Resolutions: []VideoResolution{
+			{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+		},
+		Color: ColorSettings{PixelFormat: PixelFormatYUV420P, ColorSpace: "bt709"},
+	})
+	args := strings.Join(g.Args(), " ")
+	for _, want := range []string{"[0:v]format=yuv420p,split=1[v0]", "-c:v:0 libx264 -colorspace:v:0 bt709 "} {
+		if !strings.Contains(args, want) {
+			t.Errorf("Args() = %q, missing %q", args, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of TimedMetadata.
+const (
+	// MetadataSCTE35 is an SCTE-35 splice_info_section, such as an ad break cue.
+	MetadataSCTE35 = "scte35"
+	// MetadataID3 is a timed ID3 tag, such as Nielsen watermark data.
+	MetadataID3 = "id3"
+)
+
+// programDateTimeLayout is the EXT-X-PROGRAM-DATE-TIME format written by ffmpeg.
+const programDateTimeLayout = "2006-01-02T15:04:05.999-0700"
+
+// TimedMetadata is a metadata payload carried by the input at a point in time.
+type TimedMetadata struct {
+	// Kind is MetadataSCTE35 or MetadataID3.
+	Kind string
+	// Offset is the position of the payload in seconds from the start of the output.
+	Offset float64
+	// Data is the raw payload.
+	Data []byte
+}
+
+// DateRangeTag returns the EXT-X-DATERANGE tag carrying m, with the given ID,
+// for a stream that started at start. SCTE-35 payloads use the SCTE35-CMD
+// attribute (RFC 8216 section 4.3.2.7.1), ID3 tags the X-ID3 client attribute.
+func (m TimedMetadata) DateRangeTag(id string, start time.Time) string {
+	date := start.Add(time.Duration(m.Offset * float64(time.Second)))
+	attribute := "X-ID3"
+	if m.Kind == MetadataSCTE35 {
+		attribute = "SCTE35-CMD"
+	}
+	return fmt.Sprintf("#EXT-X-DATERANGE:ID=%q,START-DATE=%q,%s=0x%X",
+		id, date.UTC().Format(time.RFC3339Nano), attribute, m.Data)
+}
+
+// InsertDateRanges adds an EXT-X-DATERANGE tag for each entry of metadata to
+// the media playlist at path, before the segment playing at its offset.
+// Date ranges are anchored to the EXT-X-PROGRAM-DATE-TIME of the playlist; an
+// EXT-X-PROGRAM-DATE-TIME of start is added to playlists without one, so every
+// variant of a stream must be given the same start.
+func InsertDateRanges(path string, start time.Time, metadata []TimedMetadata) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Find the segments (their #EXTINF line and start offset) and the anchor date
+	type segment struct {
+		line   int
+		offset float64
+	}
+	var segments []segment
+	var elapsed float64
+	hasDateTime := false
+	for i, line := range lines {
+		if value, ok := strings.CutPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"); ok && !hasDateTime {
+			hasDateTime = true
+			if parsed, err := parseProgramDateTime(value); err == nil {
+				start = parsed.Add(-time.Duration(elapsed * float64(time.Second)))
+			}
+		}
+		if value, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			duration, _ := strconv.ParseFloat(strings.SplitN(value, ",", 2)[0], 64)
+			segments = append(segments, segment{line: i, offset: elapsed})
+			elapsed += duration
+		}
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("%s: no segments to attach the date ranges to", path)
+	}
+
+	// Tags inserted before each line, by line index
+	inserted := make(map[int][]string)
+	if !hasDateTime {
+		inserted[segments[0].line] = append(inserted[segments[0].line],
+			"#EXT-X-PROGRAM-DATE-TIME:"+start.UTC().Format(programDateTimeLayout))
+	}
+	counts := make(map[string]int)
+	for _, m := range metadata {
+		target := segments[0]
+		for _, s := range segments {
+			if s.offset > m.Offset {
+				break
+			}
+			target = s
+		}
+		counts[m.Kind]++
+		inserted[target.line] = append(inserted[target.line],
+			m.DateRangeTag(fmt.Sprintf("%s-%d", m.Kind, counts[m.Kind]), start))
+	}
+
+	var out strings.Builder
+	for i, line := range lines {
+		for _, tag := range inserted[i] {
+			out.WriteString(tag + "\n")
+		}
+		out.WriteString(line + "\n")
+	}
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+// parseProgramDateTime parses an EXT-X-PROGRAM-DATE-TIME value, as written by
+// ffmpeg or in RFC 3339 format.
+func parseProgramDateTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(programDateTimeLayout, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
@@ -0,0 +1,65 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInsertDateRanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlist.m3u8")
+	playlist := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n" +
+		"#EXTINF:4.000000,\ndata000.ts\n" +
+		"#EXTINF:4.000000,\ndata001.ts\n" +
+		"#EXTINF:2.500000,\ndata002.ts\n" +
+		"#EXT-X-ENDLIST\n"
+	if err := os.WriteFile(path, []byte(playlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := InsertDateRanges(path, start, []TimedMetadata{
+		{Kind: MetadataSCTE35, Offset: 5.5, Data: []byte{0xfc, 0x30, 0x11}},
+		{Kind: MetadataID3, Offset: 0.2, Data: []byte("ID3")},
+		{Kind: MetadataSCTE35, Offset: 30, Data: []byte{0xfc}},
+	})
+	if err != nil {
+		t.Fatalf("InsertDateRanges() unexpected error: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2024-05-01T12:00:00+0000\n" +
+		`#EXT-X-DATERANGE:ID="id3-1",START-DATE="2024-05-01T12:00:00.2Z",X-ID3=0x494433` + "\n" +
+		"#EXTINF:4.000000,\ndata000.ts\n" +
+		`#EXT-X-DATERANGE:ID="scte35-1",START-DATE="2024-05-01T12:00:05.5Z",SCTE35-CMD=0xFC3011` + "\n" +
+		"#EXTINF:4.000000,\ndata001.ts\n" +
+		`#EXT-X-DATERANGE:ID="scte35-2",START-DATE="2024-05-01T12:00:30Z",SCTE35-CMD=0xFC` + "\n" +
+		"#EXTINF:2.500000,\ndata002.ts\n" +
+		"#EXT-X-ENDLIST\n"
+	if string(content) != want {
+		t.Errorf("Playlist =\n%s\nwant\n%s", content, want)
+	}
+
+	// An existing EXT-X-PROGRAM-DATE-TIME anchors the date ranges
+	if err := InsertDateRanges(path, time.Now(), []TimedMetadata{{Kind: MetadataID3, Offset: 9, Data: []byte{1}}}); err != nil {
+		t.Fatalf("InsertDateRanges() unexpected error: %v", err)
+	}
+	content, _ = os.ReadFile(path)
+	if strings.Count(string(content), "#EXT-X-PROGRAM-DATE-TIME") != 1 ||
+		!strings.Contains(string(content), `START-DATE="2024-05-01T12:00:09Z",X-ID3=0x01`+"\n#EXTINF:2.500000,") {
+		t.Errorf("Playlist =\n%s\nwant an ID3 date range anchored to the existing date", content)
+	}
+}
+
+func TestInsertDateRangesWithoutSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlist.m3u8")
+	if err := os.WriteFile(path, []byte("#EXTM3U\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertDateRanges(path, time.Now(), []TimedMetadata{{Kind: MetadataID3}}); err == nil {
+		t.Error("InsertDateRanges() expected an error for a playlist without segments")
+	}
+}
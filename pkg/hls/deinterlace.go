@@ -0,0 +1,32 @@
+package hls
+
+import "fmt"
+
+// Deinterlacer is the ffmpeg filter converting interlaced video to
+// progressive frames before it is scaled for the renditions.
+type Deinterlacer string
+
+const (
+	// DeinterlaceBwdif uses the bwdif filter, sharper than yadif on motion.
+	DeinterlaceBwdif Deinterlacer = "bwdif"
+	// DeinterlaceYadif uses the yadif filter, faster than bwdif.
+	DeinterlaceYadif Deinterlacer = "yadif"
+)
+
+// Validate checks that d is a known deinterlacer, or empty.
+func (d Deinterlacer) Validate() error {
+	switch d {
+	case "", DeinterlaceBwdif, DeinterlaceYadif:
+		return nil
+	}
+	return fmt.Errorf("unknown deinterlacer %q (expected bwdif or yadif)", d)
+}
+
+// Filter returns the filter deinterlacing every frame into one progressive
+// frame, keeping the frame rate of the input, or "" if d is empty.
+func (d Deinterlacer) Filter() string {
+	if d == "" {
+		return ""
+	}
+	return string(d) + "=mode=send_frame"
+}
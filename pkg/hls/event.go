@@ -0,0 +1,59 @@
+package hls
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MediaPlaylists returns the paths of the media playlists generated in
+// outputDir, one per variant stream.
+func MediaPlaylists(outputDir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(outputDir, "stream_*", "playlist.m3u8"))
+}
+
+// ConvertEventToVOD turns the EVENT media playlists generated in outputDir
+// into VOD playlists once the stream is complete: EXT-X-PLAYLIST-TYPE is set
+// to VOD and EXT-X-ENDLIST is added to the playlists missing it.
+func ConvertEventToVOD(outputDir string) error {
+	playlists, err := MediaPlaylists(outputDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range playlists {
+		if err := convertEventToVOD(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertEventToVOD(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if strings.TrimSpace(lines[0]) != "#EXTM3U" {
+		return fmt.Errorf("%s: not an HLS playlist", path)
+	}
+	found, ended := false, false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:"):
+			lines[i] = "#EXT-X-PLAYLIST-TYPE:VOD"
+			found = true
+		case strings.TrimSpace(line) == "#EXT-X-ENDLIST":
+			ended = true
+		}
+	}
+	if !found {
+		lines = append([]string{lines[0], "#EXT-X-PLAYLIST-TYPE:VOD"}, lines[1:]...)
+	}
+	if !ended {
+		lines = append(lines, "#EXT-X-ENDLIST")
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
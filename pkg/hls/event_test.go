@@ -0,0 +1,39 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertEventToVOD(t *testing.T) {
+	dir := t.TempDir()
+	playlists := map[string]string{
+		"stream_0": "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:6.000000,\ndata000.ts\n",
+		"stream_1": "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:6.000000,\ndata000.ts\n#EXT-X-ENDLIST\n",
+		"stream_2": "#EXTM3U\n#EXTINF:6.000000,\ndata000.ts\n",
+	}
+	for stream, content := range playlists {
+		os.MkdirAll(filepath.Join(dir, stream), 0755)
+		os.WriteFile(filepath.Join(dir, stream, "playlist.m3u8"), []byte(content), 0644)
+	}
+
+	if err := ConvertEventToVOD(dir); err != nil {
+		t.Fatalf("ConvertEventToVOD() unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"stream_0": "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:6.000000,\ndata000.ts\n#EXT-X-ENDLIST\n",
+		"stream_1": "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:6.000000,\ndata000.ts\n#EXT-X-ENDLIST\n",
+		"stream_2": "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:6.000000,\ndata000.ts\n#EXT-X-ENDLIST\n",
+	}
+	for stream, content := range want {
+		if got, _ := os.ReadFile(filepath.Join(dir, stream, "playlist.m3u8")); string(got) != content {
+			t.Errorf("%s playlist =\n%s\nwant\n%s", stream, got, content)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "stream_0", "playlist.m3u8"), []byte("not a playlist"), 0644)
+	if err := ConvertEventToVOD(dir); err == nil {
+		t.Error("ConvertEventToVOD() expected an error for an invalid playlist")
+	}
+}
@@ -5,13 +5,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
 	"github.com/heyjunin/HLSpresso/pkg/logger"
 	"github.com/heyjunin/HLSpresso/pkg/progress"
 )
@@ -33,6 +32,19 @@ type VideoResolution struct {
 	BufSize string `json:"buf_size"`
 	// AudioBitrate specifies the target audio bitrate (e.g., "128k").
 	AudioBitrate string `json:"audio_bitrate"`
+	// FrameRate sets the output frame rate of the rendition (e.g., 30).
+	// Zero keeps the frame rate of the input video.
+	FrameRate float64 `json:"frame_rate,omitempty"`
+	// Scale sets how the input is fitted into the rendition when their aspect
+	// ratios differ (see ScalePolicy). Empty means ScaleExact.
+	Scale ScalePolicy `json:"scale,omitempty"`
+	// EncoderSettings override the x264 settings of Options.EncoderSettings
+	// for this rendition, e.g. a baseline profile for the lowest one.
+	EncoderSettings
+	// ExtraParams are additional ffmpeg output options of the video of this
+	// rendition only (e.g. "-x264opts keyint=60:ref=5" for the top one),
+	// scoped to its stream (see ExtraArgs).
+	ExtraParams []string `json:"extra_params,omitempty"`
 }
 
 // DefaultResolutions provides a common set of video resolutions and bitrates
@@ -60,8 +72,21 @@ type Options struct {
 	Resolutions []VideoResolution
 	// MasterPlaylist specifies the filename for the master HLS playlist. Defaults to "master.m3u8".
 	MasterPlaylist string
-	// SegmentFormat defines the format for HLS segments ("mpegts" or "fmp4"). Defaults to "mpegts".
+	// SegmentFormat defines the format for HLS segments ("mpegts" or "fmp4").
+	// Defaults to "fmp4" when Version is 7 and "mpegts" otherwise.
 	SegmentFormat string
+	// Version is the HLS protocol version the playlists target, from MinVersion
+	// to MaxVersion; see CheckVersion for the features each one allows. Version 6
+	// and later declare independent segments. Defaults to DefaultVersion. ffmpeg
+	// writes the lowest EXT-X-VERSION the features used require; call
+	// SetPlaylistVersions to declare Version instead.
+	Version int
+	// ByteRange stores the segments of each variant in a single file addressed
+	// with EXT-X-BYTERANGE, which requires Version 4 or later.
+	ByteRange bool
+	// MPEGTS tunes the muxer of "mpegts" segments (PCR and PAT/PMT intervals).
+	// Ignored for fMP4 segments.
+	MPEGTS MPEGTSOptions
 	// VariantStreamMap defines the ffmpeg -var_stream_map argument. If empty, a default
 	// map is generated based on the Resolutions.
 	VariantStreamMap string
@@ -72,13 +97,57 @@ type Options struct {
 	// FFmpegExtraParams provides a way to pass additional command-line arguments
 	// directly to the underlying ffmpeg process.
 	FFmpegExtraParams []string
+	// FFmpegInputParams are additional ffmpeg arguments placed before the -i of
+	// InputFile, applying to the input (e.g. -ss, -probesize or -hwaccel).
+	FFmpegInputParams []string
+	// EncoderSettings are the x264 preset, tune, profile and level of the
+	// renditions, unless set by the rendition (see VideoResolution).
+	EncoderSettings EncoderSettings
+	// LogFFmpegOutput logs every line ffmpeg writes to stderr at the debug level.
+	LogFFmpegOutput bool
+	// SharedAudio encodes the audio once, as an audio rendition (EXT-X-MEDIA
+	// TYPE=AUDIO, see SharedAudioName) referenced by every variant, instead of
+	// muxing a copy of it into each variant. It is encoded at the highest audio
+	// bitrate of the Resolutions (see SharedAudioBitrate).
+	SharedAudio bool
+	// AudioCodec selects the audio codec of the renditions. Defaults to AudioAAC.
+	// ffmpeg does not signal AC-3 and E-AC-3 in the master playlist; see SetAudioCodec.
+	AudioCodec AudioCodec
+	// ClosedCaptions keeps the CEA-608/708 captions embedded in the input video
+	// in every rendition and declares them in the master playlist (EXT-X-MEDIA
+	// TYPE=CLOSED-CAPTIONS, see CaptionGroupID).
+	ClosedCaptions bool
+	// CaptionLanguage is the language of the closed captions declared in the
+	// master playlist. Defaults to "en".
+	CaptionLanguage string
+	// Deinterlace converts the interlaced input video to progressive frames
+	// with this filter before scaling it. Empty leaves the video as is.
+	Deinterlace Deinterlacer
+	// Color converts the input video to a pixel format before scaling it and
+	// tags the renditions with color tags (see ColorSettings).
+	Color ColorSettings
+	// Deterministic produces byte-identical segments and playlists for identical
+	// inputs and options (see DeterministicArgs), at the cost of a slower,
+	// single-threaded encode.
+	Deterministic bool
+	// RealtimePacing reads the input at its native frame rate (ffmpeg -re), so
+	// that segments are produced at about 1x realtime instead of as fast as possible.
+	RealtimePacing bool
+	// VideoConcatLists are ffconcat lists of the video of each Resolution,
+	// already encoded (see ChunkArgs and WriteConcatList). When set, the video
+	// is segmented without re-encoding and only the audio of InputFile is
+	// encoded.
+	VideoConcatLists []string
+	// Runner launches the ffmpeg and ffprobe processes. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
 }
 
 // Generator handles the low-level HLS playlist and segment generation using FFmpeg.
 // It is typically used internally by the Transcoder but can be instantiated directly
 // using New() for more granular control over HLS generation.
 type Generator struct {
-	options Options
+	options  Options
+	estimate ProgressEstimate
 }
 
 // New creates a new HLS Generator instance with the provided options.
@@ -94,15 +163,27 @@ func New(options Options) *Generator {
 	if options.MasterPlaylist == "" {
 		options.MasterPlaylist = "master.m3u8"
 	}
+	if options.Version == 0 {
+		options.Version = DefaultVersion
+	}
 	if options.SegmentFormat == "" {
-		options.SegmentFormat = "mpegts"
+		options.SegmentFormat = segmentFormatForVersion(options.Version)
 	}
 	if options.FFmpegBinary == "" {
 		options.FFmpegBinary = "ffmpeg"
 	}
+	if options.AudioCodec == "" {
+		options.AudioCodec = AudioAAC
+	}
+	if options.CaptionLanguage == "" {
+		options.CaptionLanguage = DefaultCaptionLanguage
+	}
 	if len(options.Resolutions) == 0 {
 		options.Resolutions = DefaultResolutions
 	}
+	if options.Runner == nil {
+		options.Runner = ffmpeg.ExecRunner{}
+	}
 
 	return &Generator{
 		options: options,
@@ -121,9 +202,15 @@ func (g *Generator) CreateHLS(ctx context.Context) (string, error) {
 	}
 
 	// Create directories for each stream variant
+	streamDirs := make([]string, 0, len(g.options.Resolutions)+1)
 	for i := range g.options.Resolutions {
-		streamDir := filepath.Join(g.options.OutputDir, fmt.Sprintf("stream_%d", i))
-		if err := os.MkdirAll(streamDir, 0755); err != nil {
+		streamDirs = append(streamDirs, fmt.Sprintf("stream_%d", i))
+	}
+	if g.options.SharedAudio {
+		streamDirs = append(streamDirs, "stream_"+SharedAudioName)
+	}
+	for _, dir := range streamDirs {
+		if err := os.MkdirAll(filepath.Join(g.options.OutputDir, dir), 0755); err != nil {
 			return "", errors.Wrap(err, errors.HLSError, "Failed to create stream directory", 2)
 		}
 	}
@@ -137,53 +224,50 @@ func (g *Generator) CreateHLS(ctx context.Context) (string, error) {
 		"command": cmd,
 	})
 
-	// Prepare command
-	ffmpegCmd := exec.CommandContext(ctx, g.options.FFmpegBinary, args...)
-
-	// Capture stderr for progress tracking
-	stderr, err := ffmpegCmd.StderrPipe()
+	// Start the command, capturing stderr for progress tracking
+	proc, err := g.options.Runner.Start(ctx, g.options.FFmpegBinary, args...)
 	if err != nil {
-		return "", errors.Wrap(err, errors.HLSError, "Failed to create stderr pipe", 3)
-	}
-
-	// Start the command
-	if err := ffmpegCmd.Start(); err != nil {
 		return "", errors.Wrap(err, errors.HLSError, "Failed to start ffmpeg", 4)
 	}
 
-	// Initialize progress tracking
-	totalFrames := int64(0)
+	// Initialize progress tracking, from the frame count or else the duration
+	g.estimate = ProgressEstimate{Basis: ProgressNone}
 	if g.options.Progress != nil {
-		totalFrames = estimateTotalFrames(g.options.InputFile)
-		if totalFrames > 0 {
-			g.options.Progress.Start(totalFrames)
+		g.estimate = EstimateProgress(ctx, g.options.Runner, g.options.InputFile)
+		switch g.estimate.Basis {
+		case ProgressFrames:
+			g.options.Progress.Start(g.estimate.TotalFrames)
+		case ProgressDuration:
+			g.options.Progress.Start(int64(g.estimate.Duration * 1000))
 		}
+		progress.ReportEvent(g.options.Progress, progress.EventProgressEstimated, g.estimate.Data())
+		logger.Debug("HLS progress estimated", "hls", g.estimate.Data())
 	}
 
-	// Track progress by parsing ffmpeg output
-	go func() {
-		progressRegex := regexp.MustCompile(`frame=\s*(\d+)`)
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			// Parse frame count for progress
-			if g.options.Progress != nil && totalFrames > 0 {
-				if matches := progressRegex.FindStringSubmatch(line); len(matches) > 1 {
-					if frame, err := strconv.ParseInt(matches[1], 10, 64); err == nil {
-						g.options.Progress.Update(frame, "transcoding", "Creating HLS stream")
-					}
-				}
+	// Track progress by parsing ffmpeg output until it closes stderr
+	scanner := bufio.NewScanner(proc.Stderr())
+	scanner.Split(ffmpeg.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Parse frame count or encoded time for progress
+		if stats, ok := ffmpeg.ParseStats(line); ok {
+			switch {
+			case g.estimate.Basis == ProgressFrames && stats.Frame >= 0:
+				g.options.Progress.Update(stats.Frame, "transcoding", "Creating HLS stream")
+			case g.estimate.Basis == ProgressDuration && stats.Time >= 0:
+				g.options.Progress.Update(stats.Time.Milliseconds(), "transcoding", "Creating HLS stream (estimated)")
 			}
+		}
 
-			// Log FFmpeg output
+		// Log FFmpeg output
+		if g.options.LogFFmpegOutput && line != "" {
 			logger.Debug(line, "ffmpeg", nil)
 		}
-	}()
+	}
 
 	// Wait for command to complete
-	err = ffmpegCmd.Wait()
-	if err != nil {
+	if err := proc.Wait(); err != nil {
 		return "", errors.Wrap(err, errors.HLSError, "FFmpeg command failed", 5)
 	}
 
@@ -200,17 +284,32 @@ func (g *Generator) CreateHLS(ctx context.Context) (string, error) {
 	return masterPath, nil
 }
 
+// Args returns the ffmpeg command line arguments CreateHLS would execute,
+// without running anything. Useful for logging, dry runs and custom executors.
+func (g *Generator) Args() []string {
+	return g.buildFFmpegArgs()
+}
+
 // buildFFmpegArgs constructs the slice of command-line arguments for the ffmpeg process
 // based on the Generator's options.
 // This is an internal helper function.
 func (g *Generator) buildFFmpegArgs() []string {
-	args := []string{
+	if len(g.options.VideoConcatLists) > 0 {
+		return g.buildPackagingArgs()
+	}
+
+	var args []string
+	if g.options.RealtimePacing {
+		args = append(args, "-re")
+	}
+	args = append(args, g.options.FFmpegInputParams...)
+	args = append(args,
 		"-i", g.options.InputFile,
 		"-filter_complex",
-	}
+	)
 
 	// Build filter graph for video splits and scaling
-	filter := buildFilterGraph(len(g.options.Resolutions), g.options.Resolutions)
+	filter := buildFilterGraph(len(g.options.Resolutions), g.options.Resolutions, SourceFilter(g.options.Deinterlace, g.options.Color))
 	args = append(args, filter)
 
 	// Add output options for each resolution
@@ -219,30 +318,104 @@ func (g *Generator) buildFFmpegArgs() []string {
 		args = append(args,
 			"-map", fmt.Sprintf("[v%dout]", i),
 			"-c:v:"+fmt.Sprintf("%d", i), "libx264",
+		)
+		args = append(args, res.EncoderSettings.Or(g.options.EncoderSettings).Args(fmt.Sprintf("v:%d", i))...)
+		args = append(args, g.options.Color.Args(fmt.Sprintf("v:%d", i))...)
+		args = append(args,
 			"-b:v:"+fmt.Sprintf("%d", i), res.VideoBitrate,
 			"-maxrate:v:"+fmt.Sprintf("%d", i), res.MaxRate,
 			"-bufsize:v:"+fmt.Sprintf("%d", i), res.BufSize,
 		)
+		args = append(args, res.ExtraArgs(fmt.Sprintf("v:%d", i))...)
 
 		// Audio stream options
+		if !g.options.SharedAudio {
+			args = append(args, g.audioArgs(i, res.AudioBitrate)...)
+		}
+	}
+	if g.options.SharedAudio {
+		args = append(args, g.audioArgs(0, SharedAudioBitrate(g.options.Resolutions))...)
+	}
+
+	// Keep the captions carried in the video frames (x264 SEI)
+	if g.options.ClosedCaptions {
+		args = append(args, "-a53cc", "1")
+	}
+	if g.options.Deterministic {
+		args = append(args, DeterministicArgs()...)
+	}
+	return append(args, g.muxerArgs()...)
+}
+
+// buildPackagingArgs constructs the arguments segmenting the pre-encoded video
+// of VideoConcatLists, with the audio of InputFile.
+// This is an internal helper function.
+func (g *Generator) buildPackagingArgs() []string {
+	var args []string
+	for _, list := range g.options.VideoConcatLists {
+		args = append(args, "-f", "concat", "-safe", "0", "-i", list)
+	}
+	args = append(args, g.options.FFmpegInputParams...)
+	args = append(args, "-i", g.options.InputFile)
+
+	audioInput := len(g.options.VideoConcatLists)
+	for i, res := range g.options.Resolutions {
 		args = append(args,
-			"-map", "a:0",
-			"-c:a:"+fmt.Sprintf("%d", i), "aac",
-			"-b:a:"+fmt.Sprintf("%d", i), res.AudioBitrate,
-			"-ac", "2",
+			"-map", fmt.Sprintf("%d:v:0", i),
+			"-c:v:"+fmt.Sprintf("%d", i), "copy",
 		)
+		if !g.options.SharedAudio {
+			args = append(args, g.audioArgsFrom(audioInput, i, res.AudioBitrate)...)
+		}
+	}
+	if g.options.SharedAudio {
+		args = append(args, g.audioArgsFrom(audioInput, 0, SharedAudioBitrate(g.options.Resolutions))...)
+	}
+	if g.options.Deterministic {
+		args = append(args, DeterministicArgs()...)
 	}
+	return append(args, g.muxerArgs()...)
+}
+
+// muxerArgs returns the arguments of the HLS muxer and of the output.
+// This is an internal helper function.
+func (g *Generator) muxerArgs() []string {
+	var args []string
 
-	// Add HLS options
+	// Add HLS options, with the features of the target version
+	var flags []string
+	if g.options.Version >= independentSegmentsVersion {
+		flags = append(flags, "independent_segments")
+	}
+	segmentPattern := "stream_%v/data%03d"
+	if g.options.ByteRange {
+		flags = append(flags, "single_file")
+		segmentPattern = "stream_%v/data"
+	}
+	if g.options.SegmentFormat == "fmp4" {
+		segmentPattern += ".m4s"
+	} else {
+		segmentPattern += ".ts"
+	}
 	args = append(args,
 		"-f", "hls",
 		"-hls_time", fmt.Sprintf("%d", g.options.SegmentDuration),
 		"-hls_playlist_type", g.options.PlaylistType,
-		"-hls_flags", "independent_segments",
+	)
+	if len(flags) > 0 {
+		args = append(args, "-hls_flags", strings.Join(flags, "+"))
+	}
+	args = append(args,
 		"-hls_segment_type", g.options.SegmentFormat,
-		"-hls_segment_filename", filepath.Join(g.options.OutputDir, "stream_%v/data%03d.ts"),
+		"-hls_segment_filename", outputPattern(g.options.OutputDir, segmentPattern),
 		"-master_pl_name", g.options.MasterPlaylist,
 	)
+	if segmentOptions := g.options.MPEGTS.segmentOptions(); segmentOptions != "" && g.options.SegmentFormat == "mpegts" {
+		args = append(args, "-hls_segment_options", segmentOptions)
+	}
+	if g.options.ClosedCaptions {
+		args = append(args, "-cc_stream_map", captionStreamMap(g.options.CaptionLanguage))
+	}
 
 	// Add variant stream map
 	streamMap := g.options.VariantStreamMap
@@ -250,7 +423,17 @@ func (g *Generator) buildFFmpegArgs() []string {
 		// Build default stream map if not provided
 		var mapParts []string
 		for i := range g.options.Resolutions {
-			mapParts = append(mapParts, fmt.Sprintf("v:%d,a:%d", i, i))
+			part := fmt.Sprintf("v:%d,a:%d", i, i)
+			if g.options.SharedAudio {
+				part = fmt.Sprintf("v:%d,agroup:%s", i, SharedAudioName)
+			}
+			if g.options.ClosedCaptions {
+				part += ",ccgroup:" + CaptionGroupID
+			}
+			mapParts = append(mapParts, part)
+		}
+		if g.options.SharedAudio {
+			mapParts = append(mapParts, fmt.Sprintf("a:0,agroup:%s,name:%s,default:yes", SharedAudioName, SharedAudioName))
 		}
 		streamMap = strings.Join(mapParts, " ")
 	}
@@ -263,17 +446,75 @@ func (g *Generator) buildFFmpegArgs() []string {
 	}
 
 	// Add output pattern LAST
-	args = append(args, filepath.Join(g.options.OutputDir, "stream_%v/playlist.m3u8"))
+	args = append(args, outputPattern(g.options.OutputDir, "stream_%v/playlist.m3u8"))
 
 	return args
 }
 
+// DeterministicArgs returns the ffmpeg output arguments that make an encode
+// reproducible: a single encoder thread, so that x264 and the audio encoders
+// always see the same frames in the same order, and bitexact muxing without the
+// metadata, chapters and encoder version strings of the input and of ffmpeg.
+func DeterministicArgs() []string {
+	return []string{
+		"-threads", "1",
+		"-fflags", "+bitexact",
+		"-flags:v", "+bitexact",
+		"-flags:a", "+bitexact",
+		"-map_metadata", "-1",
+		"-map_chapters", "-1",
+	}
+}
+
+// audioArgs returns the ffmpeg arguments mapping the input audio to the output
+// audio stream index, encoded with the configured AudioCodec.
+// This is an internal helper function.
+func (g *Generator) audioArgs(index int, bitrate string) []string {
+	return g.audioArgsFrom(-1, index, bitrate)
+}
+
+// audioArgsFrom returns the audioArgs mapping the audio of the input input,
+// or of any input if input is negative.
+// This is an internal helper function.
+func (g *Generator) audioArgsFrom(input, index int, bitrate string) []string {
+	args := []string{"-map", "a:0"}
+	if input >= 0 {
+		args[1] = fmt.Sprintf("%d:a:0", input)
+	}
+	switch g.options.AudioCodec {
+	case AudioCopy:
+		return append(args, "-c:a:"+fmt.Sprintf("%d", index), "copy")
+	case AudioAC3, AudioEAC3:
+		// Keep the surround channels, at the bitrate the encoder picks for them
+		return append(args, "-c:a:"+fmt.Sprintf("%d", index), string(g.options.AudioCodec))
+	default:
+		return append(args,
+			"-c:a:"+fmt.Sprintf("%d", index), "aac",
+			"-b:a:"+fmt.Sprintf("%d", index), bitrate,
+			"-ac", "2",
+		)
+	}
+}
+
+// outputPattern joins the output directory with an ffmpeg output pattern using
+// forward slashes on every platform, so ffmpeg writes URL-style relative paths
+// (e.g. "stream_0/playlist.m3u8") into the master playlist on Windows too.
+// This is an internal helper function.
+func outputPattern(outputDir, pattern string) string {
+	return filepath.ToSlash(filepath.Join(outputDir, pattern))
+}
+
 // buildFilterGraph constructs the complex FFmpeg filter graph string required for
-// splitting the input video and scaling it to each specified resolution.
+// filtering the input video with sourceFilter (see SourceFilter), splitting it
+// and scaling it to each specified resolution.
 // This is an internal helper function.
-func buildFilterGraph(numStreams int, resolutions []VideoResolution) string {
+func buildFilterGraph(numStreams int, resolutions []VideoResolution, sourceFilter string) string {
 	// Create video split
-	filter := fmt.Sprintf("[0:v]split=%d", numStreams)
+	filter := "[0:v]"
+	if sourceFilter != "" {
+		filter += sourceFilter + ","
+	}
+	filter += fmt.Sprintf("split=%d", numStreams)
 
 	// Add labels for each split output
 	for i := 0; i < numStreams; i++ {
@@ -281,9 +522,13 @@ func buildFilterGraph(numStreams int, resolutions []VideoResolution) string {
 	}
 	filter += "; "
 
-	// Add scaling for each resolution
+	// Add frame rate conversion (if set) and scaling for each resolution
 	for i, res := range resolutions {
-		filter += fmt.Sprintf("[v%d]scale=w=%d:h=%d[v%dout]; ", i, res.Width, res.Height, i)
+		fps := ""
+		if res.FrameRate > 0 {
+			fps = fmt.Sprintf("fps=%g,", res.FrameRate)
+		}
+		filter += fmt.Sprintf("[v%d]%s%s[v%dout]; ", i, fps, scaleFilter(res), i)
 	}
 
 	// Remove trailing semicolon and space
@@ -292,20 +537,101 @@ func buildFilterGraph(numStreams int, resolutions []VideoResolution) string {
 	return filter
 }
 
+// scaleFilter returns the filter chain scaling the input to res according to its
+// Scale policy.
+// This is an internal helper function.
+func scaleFilter(res VideoResolution) string {
+	switch res.Scale {
+	case ScalePad:
+		return fmt.Sprintf("scale=w=%d:h=%d:force_original_aspect_ratio=decrease:force_divisible_by=2,pad=w=%d:h=%d:x=(ow-iw)/2:y=(oh-ih)/2,setsar=1",
+			res.Width, res.Height, res.Width, res.Height)
+	case ScaleCrop:
+		return fmt.Sprintf("scale=w=%d:h=%d:force_original_aspect_ratio=increase,crop=w=%d:h=%d,setsar=1",
+			res.Width, res.Height, res.Width, res.Height)
+	default:
+		return fmt.Sprintf("scale=w=%d:h=%d", res.Width, res.Height)
+	}
+}
+
+// ProgressBasis tells how CreateHLS measures the progress it reports.
+type ProgressBasis string
+
+const (
+	// ProgressFrames measures the frames encoded out of the frames counted in
+	// the input.
+	ProgressFrames ProgressBasis = "frames"
+	// ProgressDuration estimates the progress from the media time encoded out
+	// of the input duration, when the frames of the input cannot be counted.
+	ProgressDuration ProgressBasis = "duration"
+	// ProgressNone means that neither the frames nor the duration of the input
+	// are known, e.g. for live sources; no percentage is reported.
+	ProgressNone ProgressBasis = "none"
+)
+
+// ProgressEstimate describes what the progress of CreateHLS is measured
+// against. The totals passed to progress.Reporter.Start are TotalFrames for
+// ProgressFrames and the duration in milliseconds for ProgressDuration.
+type ProgressEstimate struct {
+	Basis ProgressBasis `json:"basis"`
+	// TotalFrames is the number of video frames of the input, for ProgressFrames.
+	TotalFrames int64 `json:"total_frames,omitempty"`
+	// Duration is the duration of the input in seconds, for ProgressDuration.
+	Duration float64 `json:"duration_seconds,omitempty"`
+}
+
+// Estimated reports whether the progress is estimated from the duration
+// rather than counted in frames.
+func (e ProgressEstimate) Estimated() bool {
+	return e.Basis == ProgressDuration
+}
+
+// Data returns e as the data of the progress.EventProgressEstimated event.
+func (e ProgressEstimate) Data() map[string]interface{} {
+	data := map[string]interface{}{
+		"basis":     string(e.Basis),
+		"estimated": e.Estimated(),
+	}
+	if e.TotalFrames > 0 {
+		data["total_frames"] = e.TotalFrames
+	}
+	if e.Duration > 0 {
+		data["duration_seconds"] = e.Duration
+	}
+	return data
+}
+
+// ProgressEstimate returns what the progress of the last CreateHLS call was
+// measured against. Its Basis is empty before CreateHLS is called and
+// ProgressNone when no progress.Reporter is set.
+func (g *Generator) ProgressEstimate() ProgressEstimate {
+	return g.estimate
+}
+
+// EstimateProgress returns what the progress of encoding inputFile can be
+// measured against: its frame count, counted with ffprobe, or else its
+// duration, or ProgressNone when neither can be determined.
+func EstimateProgress(ctx context.Context, runner ffmpeg.Runner, inputFile string) ProgressEstimate {
+	if frames := estimateTotalFrames(ctx, runner, inputFile); frames > 0 {
+		return ProgressEstimate{Basis: ProgressFrames, TotalFrames: frames}
+	}
+	if duration := ffmpeg.ProbeDuration(ctx, runner, inputFile); duration > 0 {
+		return ProgressEstimate{Basis: ProgressDuration, Duration: duration}
+	}
+	return ProgressEstimate{Basis: ProgressNone}
+}
+
 // estimateTotalFrames attempts to get the total frame count of the input video using ffprobe.
 // Returns 0 if ffprobe fails or the frame count cannot be determined.
 // This is used for initializing the progress reporter.
 // This is an internal helper function.
-func estimateTotalFrames(inputFile string) int64 {
-	cmd := exec.Command("ffprobe",
+func estimateTotalFrames(ctx context.Context, runner ffmpeg.Runner, inputFile string) int64 {
+	output, err := runner.Output(ctx, "ffprobe",
 		"-v", "error",
 		"-select_streams", "v:0",
 		"-count_packets",
 		"-show_entries", "stream=nb_read_packets",
 		"-of", "csv=p=0",
 		inputFile)
-
-	output, err := cmd.Output()
 	if err != nil {
 		return 0
 	}
@@ -1,10 +1,16 @@
 package hls
 
 import (
+	"context"
+	stderrors "errors"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
 )
 
 func TestNewGeneratorDefaults(t *testing.T) {
@@ -39,7 +45,7 @@ func TestBuildFilterGraph(t *testing.T) {
 	numStreams := len(resolutions)
 
 	expected := "[0:v]split=2[v0][v1]; [v0]scale=w=1280:h=720[v0out]; [v1]scale=w=640:h=360[v1out]"
-	result := buildFilterGraph(numStreams, resolutions)
+	result := buildFilterGraph(numStreams, resolutions, "")
 
 	if result != expected {
 		t.Errorf("buildFilterGraph() failed:\nGot: %s\nWant: %s", result, expected)
@@ -48,10 +54,35 @@ func TestBuildFilterGraph(t *testing.T) {
 	// Teste com uma stream
 	resolutionsSingle := []VideoResolution{{Width: 1920, Height: 1080}}
 	expectedSingle := "[0:v]split=1[v0]; [v0]scale=w=1920:h=1080[v0out]"
-	resultSingle := buildFilterGraph(1, resolutionsSingle)
+	resultSingle := buildFilterGraph(1, resolutionsSingle, "")
 	if resultSingle != expectedSingle {
 		t.Errorf("buildFilterGraph() single stream failed:\nGot: %s\nWant: %s", resultSingle, expectedSingle)
 	}
+
+	// Teste com frame rate por rendition
+	resolutionsFPS := []VideoResolution{{Width: 1280, Height: 720}, {Width: 640, Height: 360, FrameRate: 30}}
+	expectedFPS := "[0:v]split=2[v0][v1]; [v0]scale=w=1280:h=720[v0out]; [v1]fps=30,scale=w=640:h=360[v1out]"
+	resultFPS := buildFilterGraph(2, resolutionsFPS, "")
+	if resultFPS != expectedFPS {
+		t.Errorf("buildFilterGraph() with frame rate failed:\nGot: %s\nWant: %s", resultFPS, expectedFPS)
+	}
+
+	// Teste com políticas de escala pad e crop
+	resolutionsScale := []VideoResolution{{Width: 1280, Height: 720, Scale: ScalePad}, {Width: 640, Height: 360, Scale: ScaleCrop}}
+	expectedScale := "[0:v]split=2[v0][v1]; " +
+		"[v0]scale=w=1280:h=720:force_original_aspect_ratio=decrease:force_divisible_by=2,pad=w=1280:h=720:x=(ow-iw)/2:y=(oh-ih)/2,setsar=1[v0out]; " +
+		"[v1]scale=w=640:h=360:force_original_aspect_ratio=increase,crop=w=640:h=360,setsar=1[v1out]"
+	resultScale := buildFilterGraph(2, resolutionsScale, "")
+	if resultScale != expectedScale {
+		t.Errorf("buildFilterGraph() with scale policies failed:\nGot: %s\nWant: %s", resultScale, expectedScale)
+	}
+
+	// Teste com desentrelaçamento antes da divisão
+	expectedDeinterlace := "[0:v]bwdif=mode=send_frame,split=1[v0]; [v0]scale=w=1920:h=1080[v0out]"
+	resultDeinterlace := buildFilterGraph(1, resolutionsSingle, DeinterlaceBwdif.Filter())
+	if resultDeinterlace != expectedDeinterlace {
+		t.Errorf("buildFilterGraph() with deinterlacing failed:\nGot: %s\nWant: %s", resultDeinterlace, expectedDeinterlace)
+	}
 }
 
 func TestBuildFFmpegArgs(t *testing.T) {
@@ -157,3 +188,103 @@ func endsWith(args []string, value string) bool {
 	}
 	return args[len(args)-1] == value
 }
+
+// recordingReporter records the progress values it receives.
+type recordingReporter struct {
+	total     int64
+	updates   []int64
+	completed bool
+}
+
+func (r *recordingReporter) Start(total int64) { r.total = total }
+func (r *recordingReporter) Update(current int64, _, _ string) {
+	r.updates = append(r.updates, current)
+}
+func (r *recordingReporter) Increment(_, _ string)                  {}
+func (r *recordingReporter) Complete()                              { r.completed = true }
+func (r *recordingReporter) Fail(err error)                         {}
+func (r *recordingReporter) Close() error                           { return nil }
+func (r *recordingReporter) Updates() <-chan progress.ProgressEvent { return nil }
+func (r *recordingReporter) JSON() (string, error)                  { return "{}", nil }
+
+func TestCreateHLSWithRunner(t *testing.T) {
+	runner := &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			if name == "ffprobe" {
+				return ffmpegtest.Result{Stdout: "100\n"}
+			}
+			return ffmpegtest.Result{Stderr: "frame=   40 fps=25\nframe=  100 fps=25\n"}
+		},
+	}
+	reporter := &recordingReporter{}
+	outputDir := t.TempDir()
+
+	g := New(Options{
+		InputFile: "input.mp4",
+		OutputDir: outputDir,
+		Progress:  reporter,
+		Runner:    runner,
+	})
+	master, err := g.CreateHLS(context.Background())
+	if err != nil {
+		t.Fatalf("CreateHLS() unexpected error: %v", err)
+	}
+	if master != filepath.Join(outputDir, "master.m3u8") {
+		t.Errorf("CreateHLS() = %q, want master playlist in %q", master, outputDir)
+	}
+
+	if reporter.total != 100 || !reflect.DeepEqual(reporter.updates, []int64{40, 100}) || !reporter.completed {
+		t.Errorf("Unexpected progress: total=%d updates=%v completed=%v", reporter.total, reporter.updates, reporter.completed)
+	}
+
+	calls := runner.Calls()
+	if len(calls) != 2 || calls[0].Name != "ffmpeg" || calls[1].Name != "ffprobe" {
+		t.Fatalf("Unexpected calls: %+v", calls)
+	}
+	if !reflect.DeepEqual(calls[0].Args, g.Args()) {
+		t.Errorf("ffmpeg args mismatch:\nGot:  %v\nWant: %v", calls[0].Args, g.Args())
+	}
+}
+
+func TestCreateHLSDurationProgress(t *testing.T) {
+	runner := &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			if name == "ffprobe" && strings.Contains(strings.Join(args, " "), "format=duration") {
+				return ffmpegtest.Result{Stdout: "10.000000\n"}
+			}
+			if name == "ffprobe" {
+				return ffmpegtest.Result{Stdout: "N/A\n"}
+			}
+			return ffmpegtest.Result{Stderr: "frame=   40 fps=25 time=00:00:04.00 speed=2x\rframe=   90 fps=25 time=00:00:09.50 speed=2x\r\n"}
+		},
+	}
+	reporter := &recordingReporter{}
+
+	g := New(Options{InputFile: "input.mp4", OutputDir: t.TempDir(), Progress: reporter, Runner: runner})
+	if _, err := g.CreateHLS(context.Background()); err != nil {
+		t.Fatalf("CreateHLS() unexpected error: %v", err)
+	}
+	if reporter.total != 10000 || !reflect.DeepEqual(reporter.updates, []int64{4000, 9500}) {
+		t.Errorf("Unexpected progress: total=%d updates=%v", reporter.total, reporter.updates)
+	}
+	if estimate := g.ProgressEstimate(); estimate.Basis != ProgressDuration || !estimate.Estimated() || estimate.Duration != 10 {
+		t.Errorf("ProgressEstimate() = %+v, want an estimate from the duration", estimate)
+	}
+}
+
+func TestCreateHLSWithRunnerFailure(t *testing.T) {
+	runner := &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			return ffmpegtest.Result{Err: stderrors.New("exit status 1")}
+		},
+	}
+
+	_, err := New(Options{InputFile: "input.mp4", OutputDir: t.TempDir(), Runner: runner}).CreateHLS(context.Background())
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok {
+		t.Fatalf("CreateHLS() should return a StructuredError, got %T (%v)", err, err)
+	}
+	if sErr.Type != errors.HLSError {
+		t.Errorf("CreateHLS() error type = %q, want %q", sErr.Type, errors.HLSError)
+	}
+}
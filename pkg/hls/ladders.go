@@ -0,0 +1,58 @@
+package hls
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ladder1080 is the standard 16:9 ladder topping out at Full HD (1080p, 720p, 480p).
+// It is the same ladder as DefaultResolutions.
+var Ladder1080 = DefaultResolutions
+
+// Ladder4K is a 16:9 ladder for UHD sources, from 2160p down to 360p.
+var Ladder4K = []VideoResolution{
+	{Width: 3840, Height: 2160, VideoBitrate: "15000k", MaxRate: "16050k", BufSize: "22500k", AudioBitrate: "192k"}, // 2160p
+	{Width: 2560, Height: 1440, VideoBitrate: "9000k", MaxRate: "9630k", BufSize: "13500k", AudioBitrate: "192k"},   // 1440p
+	{Width: 1920, Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},    // 1080p
+	{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},     // 720p
+	{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},       // 480p
+	{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},         // 360p
+}
+
+// LadderMobile is a 16:9 ladder for constrained mobile networks, from 720p down to 240p.
+var LadderMobile = []VideoResolution{
+	{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"}, // 720p
+	{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},   // 480p
+	{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},     // 360p
+	{Width: 426, Height: 240, VideoBitrate: "400k", MaxRate: "428k", BufSize: "600k", AudioBitrate: "48k"},      // 240p
+}
+
+// Ladders maps the names accepted by LadderByName (and the --ladder CLI flag)
+// to the predefined ladders.
+var Ladders = map[string][]VideoResolution{
+	"1080p":  Ladder1080,
+	"4k":     Ladder4K,
+	"mobile": LadderMobile,
+}
+
+// LadderByName returns a copy of the predefined ladder with the given name
+// (case-insensitive, see Ladders).
+// Returns an error listing the available names if the ladder is unknown.
+func LadderByName(name string) ([]VideoResolution, error) {
+	ladder, ok := Ladders[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown ladder %q (available: %s)", name, strings.Join(LadderNames(), ", "))
+	}
+	return append([]VideoResolution(nil), ladder...), nil
+}
+
+// LadderNames returns the sorted names of the predefined ladders.
+func LadderNames() []string {
+	names := make([]string, 0, len(Ladders))
+	for name := range Ladders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,58 @@
+package hls
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLadderByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    []VideoResolution
+		wantErr bool
+	}{
+		{name: "1080p", want: DefaultResolutions},
+		{name: "4K", want: Ladder4K},
+		{name: " mobile ", want: LadderMobile},
+		{name: "8k", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LadderByName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LadderByName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LadderByName() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLadderByNameReturnsCopy(t *testing.T) {
+	got, err := LadderByName("4k")
+	if err != nil {
+		t.Fatalf("LadderByName() unexpected error: %v", err)
+	}
+	got[0].VideoBitrate = "1k"
+	if Ladder4K[0].VideoBitrate != "15000k" {
+		t.Error("Modifying the returned ladder must not change Ladder4K")
+	}
+}
+
+func TestLaddersMatchDefaultBitrates(t *testing.T) {
+	for name, ladder := range Ladders {
+		for _, res := range ladder {
+			quality := fmt.Sprintf("%dp", res.Height)
+			bitrates, ok := DefaultBitrates[quality]
+			if !ok {
+				t.Fatalf("Ladder %q has unexpected height %d", name, res.Height)
+			}
+			if res.VideoBitrate != bitrates.Video || res.MaxRate != bitrates.MaxRate || res.BufSize != bitrates.BufSize {
+				t.Errorf("Ladder %q %s bitrates %+v do not match DefaultBitrates %+v", name, quality, res, bitrates)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Share of the segment size taken by the container, on top of the encoded
+// audio and video, used when estimating output sizes.
+const (
+	// MPEGTSOverhead is the muxing overhead of MPEG-TS segments: 188-byte
+	// packet headers, PES headers, adaptation field stuffing and PAT/PMT/PCR.
+	MPEGTSOverhead = 0.06
+	// FMP4Overhead is the muxing overhead of fMP4 segments (moof/mdat boxes).
+	FMP4Overhead = 0.02
+)
+
+// DefaultMuxOverhead returns the muxing overhead of the segments written for
+// the HLS protocol version (zero meaning DefaultVersion): FMP4Overhead from
+// version 7, MPEGTSOverhead before.
+func DefaultMuxOverhead(version int) float64 {
+	if version == 0 {
+		version = DefaultVersion
+	}
+	if segmentFormatForVersion(version) == "fmp4" {
+		return FMP4Overhead
+	}
+	return MPEGTSOverhead
+}
+
+// MPEGTSOptions tunes the MPEG-TS muxer writing the segments. Zero values keep
+// the defaults of ffmpeg. They only apply to "mpegts" segments.
+type MPEGTSOptions struct {
+	// PCRPeriod is the interval between program clock references (default 20ms).
+	PCRPeriod time.Duration `json:"pcr_period,omitempty"`
+	// PATPeriod is the maximum interval between PAT/PMT tables (default 100ms).
+	PATPeriod time.Duration `json:"pat_period,omitempty"`
+	// PATPMTAtFrames writes the PAT/PMT tables before every video frame.
+	PATPMTAtFrames bool `json:"pat_pmt_at_frames,omitempty"`
+	// ResendHeaders writes the PAT/PMT tables at the start of every segment.
+	ResendHeaders bool `json:"resend_headers,omitempty"`
+}
+
+// IsZero reports whether the options keep every ffmpeg default.
+func (o MPEGTSOptions) IsZero() bool {
+	return o == MPEGTSOptions{}
+}
+
+// Validate checks that the periods are not negative and, for PCRPeriod, at
+// least a millisecond, the resolution of the muxer option.
+func (o MPEGTSOptions) Validate() error {
+	if o.PCRPeriod < 0 || (o.PCRPeriod > 0 && o.PCRPeriod < time.Millisecond) {
+		return fmt.Errorf("invalid PCR period %s (expected at least 1ms)", o.PCRPeriod)
+	}
+	if o.PATPeriod < 0 {
+		return fmt.Errorf("invalid PAT period %s", o.PATPeriod)
+	}
+	return nil
+}
+
+// segmentOptions returns the options of o in the key=value:key=value format
+// of the ffmpeg -hls_segment_options argument, or "" if o is zero.
+func (o MPEGTSOptions) segmentOptions() string {
+	var opts, flags []string
+	if o.PCRPeriod > 0 {
+		opts = append(opts, fmt.Sprintf("pcr_period=%d", o.PCRPeriod.Milliseconds()))
+	}
+	if o.PATPeriod > 0 {
+		opts = append(opts, "pat_period="+strconv.FormatFloat(o.PATPeriod.Seconds(), 'f', -1, 64))
+	}
+	if o.ResendHeaders {
+		flags = append(flags, "resend_headers")
+	}
+	if o.PATPMTAtFrames {
+		flags = append(flags, "pat_pmt_at_frames")
+	}
+	if len(flags) > 0 {
+		opts = append(opts, "mpegts_flags="+strings.Join(flags, "+"))
+	}
+	return strings.Join(opts, ":")
+}
+
+// Patterns of the bandwidth attributes of an EXT-X-STREAM-INF tag.
+var (
+	bandwidthPattern        = regexp.MustCompile(`([:,])BANDWIDTH=\d+`)
+	averageBandwidthPattern = regexp.MustCompile(`,AVERAGE-BANDWIDTH=\d+`)
+	mediaGroupPattern       = regexp.MustCompile(`GROUP-ID="([^"]*)"`)
+	mediaURIPattern         = regexp.MustCompile(`URI="([^"]*)"`)
+	audioGroupPattern       = regexp.MustCompile(`AUDIO="([^"]*)"`)
+)
+
+// segmentBitrate is the measured bitrate of a media playlist, in bits per second.
+type segmentBitrate struct {
+	peak, average int64
+}
+
+// UpdateBandwidth sets the BANDWIDTH (peak segment bitrate) and
+// AVERAGE-BANDWIDTH attributes of every variant of the master playlist at
+// masterPath to the bitrates measured on the segments written, including the
+// muxing overhead and, for variants referencing an audio group, the audio
+// rendition. ffmpeg derives them from the target bitrates instead. Variants
+// whose media playlist or segments cannot be read are left unchanged.
+func UpdateBandwidth(masterPath string) error {
+	content, err := os.ReadFile(masterPath)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(masterPath)
+
+	lines := strings.Split(string(content), "\n")
+	audioGroups := make(map[string]segmentBitrate)
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#EXT-X-MEDIA:") || !strings.Contains(line, "TYPE=AUDIO") {
+			continue
+		}
+		group, uri := mediaGroupPattern.FindStringSubmatch(line), mediaURIPattern.FindStringSubmatch(line)
+		if group == nil || uri == nil {
+			continue
+		}
+		if rate, ok := measureBitrate(filepath.Join(dir, filepath.FromSlash(uri[1]))); ok {
+			audioGroups[group[1]] = rate
+		}
+	}
+
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") || i+1 >= len(lines) {
+			continue
+		}
+		rate, ok := measureBitrate(filepath.Join(dir, filepath.FromSlash(strings.TrimSpace(lines[i+1]))))
+		if !ok {
+			continue
+		}
+		if group := audioGroupPattern.FindStringSubmatch(line); group != nil {
+			audio := audioGroups[group[1]]
+			rate.peak += audio.peak
+			rate.average += audio.average
+		}
+
+		line = averageBandwidthPattern.ReplaceAllString(line, "")
+		line = bandwidthPattern.ReplaceAllString(line,
+			fmt.Sprintf("${1}BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d", rate.peak, rate.average))
+		lines[i] = line
+	}
+	return os.WriteFile(masterPath, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// measureBitrate returns the peak and average segment bitrates of the media
+// playlist at playlistPath, using the EXT-X-BYTERANGE lengths when present and
+// the segment file sizes otherwise. ok is false if the playlist or one of its
+// segments cannot be read, or if it lists no segments.
+func measureBitrate(playlistPath string) (rate segmentBitrate, ok bool) {
+	f, err := os.Open(playlistPath)
+	if err != nil {
+		return rate, false
+	}
+	defer f.Close()
+
+	var duration, totalDuration float64
+	var length, totalBits int64 = -1, 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			duration, _ = strconv.ParseFloat(value, 64)
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"), "@")
+			length, _ = strconv.ParseInt(value, 10, 64)
+		case line == "" || strings.HasPrefix(line, "#"):
+			// Other tags and blank lines
+		default:
+			if length < 0 {
+				info, err := os.Stat(filepath.Join(filepath.Dir(playlistPath), filepath.FromSlash(line)))
+				if err != nil {
+					return rate, false
+				}
+				length = info.Size()
+			}
+			if duration > 0 {
+				bits := length * 8
+				rate.peak = max(rate.peak, int64(math.Ceil(float64(bits)/duration)))
+				totalBits += bits
+				totalDuration += duration
+			}
+			duration, length = 0, -1
+		}
+	}
+	if scanner.Err() != nil || totalDuration == 0 {
+		return rate, false
+	}
+	rate.average = int64(math.Ceil(float64(totalBits) / totalDuration))
+	return rate, true
+}
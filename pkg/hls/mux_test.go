@@ -0,0 +1,83 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultMuxOverhead(t *testing.T) {
+	for version, want := range map[int]float64{0: MPEGTSOverhead, 3: MPEGTSOverhead, 7: FMP4Overhead} {
+		if got := DefaultMuxOverhead(version); got != want {
+			t.Errorf("DefaultMuxOverhead(%d) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestBuildFFmpegArgsMPEGTS(t *testing.T) {
+	mpegts := MPEGTSOptions{PCRPeriod: 40 * time.Millisecond, PATPeriod: 500 * time.Millisecond, ResendHeaders: true}
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"default", Options{}, ""},
+		{"tuned", Options{MPEGTS: mpegts}, "pcr_period=40:pat_period=0.5:mpegts_flags=resend_headers"},
+		{"fmp4", Options{Version: 7, MPEGTS: mpegts}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.InputFile, tt.options.OutputDir = "input.mp4", "out"
+			if got := argsToMap(New(tt.options).Args())["-hls_segment_options"]; got != tt.want {
+				t.Errorf("-hls_segment_options = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMPEGTSOptionsValidate(t *testing.T) {
+	for _, o := range []MPEGTSOptions{{PCRPeriod: -time.Millisecond}, {PCRPeriod: time.Microsecond}, {PATPeriod: -time.Second}} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected an error", o)
+		}
+	}
+	if err := (MPEGTSOptions{PCRPeriod: 20 * time.Millisecond, PATPMTAtFrames: true}).Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+}
+
+func TestUpdateBandwidth(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 100 kB over 4s, then 50 kB over 2s: 200 kbit/s peak, 200 kbit/s average
+	write("stream_0/playlist.m3u8", "#EXTM3U\n#EXTINF:4.000000,\ndata000.ts\n#EXTINF:2.000000,\ndata001.ts\n#EXT-X-ENDLIST\n")
+	write("stream_0/data000.ts", string(make([]byte, 100_000)))
+	write("stream_0/data001.ts", string(make([]byte, 50_000)))
+	// Byte ranges of 30 kB and 10 kB over 2s each
+	write("stream_audio/playlist.m3u8", "#EXTM3U\n#EXTINF:2.0,\n#EXT-X-BYTERANGE:30000@0\ndata.ts\n#EXTINF:2.0,\n#EXT-X-BYTERANGE:10000@30000\ndata.ts\n")
+	write("master.m3u8", "#EXTM3U\n"+
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio",DEFAULT=YES,URI="stream_audio/playlist.m3u8"`+"\n"+
+		`#EXT-X-STREAM-INF:BANDWIDTH=140800,AVERAGE-BANDWIDTH=128000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="group_audio"`+"\n"+
+		"stream_0/playlist.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_1/playlist.m3u8\n")
+
+	if err := UpdateBandwidth(filepath.Join(dir, "master.m3u8")); err != nil {
+		t.Fatalf("UpdateBandwidth() unexpected error: %v", err)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "master.m3u8"))
+	want := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio",DEFAULT=YES,URI="stream_audio/playlist.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=320000,AVERAGE-BANDWIDTH=280000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="group_audio"` + "\n" +
+		"stream_0/playlist.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_1/playlist.m3u8\n"
+	if string(got) != want {
+		t.Errorf("Master playlist =\n%s\nwant\n%s", got, want)
+	}
+}
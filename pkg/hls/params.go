@@ -0,0 +1,50 @@
+package hls
+
+import (
+	"fmt"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// renditionOptions lists the ffmpeg options that act on the whole command
+// rather than on the video of a rendition, which ExtraParams cannot set.
+var renditionOptions = map[string]bool{
+	"i": true, "map": true, "f": true, "filter_complex": true, "var_stream_map": true,
+	"y": true, "n": true, "re": true,
+}
+
+// ExtraArgs returns the ExtraParams of the rendition scoped to its video
+// stream, selected by stream (e.g. "v:1"): the options without a stream
+// specifier or with the "v" specifier are given stream, e.g.
+// "-x264opts:v:1 keyint=60" for "-x264opts keyint=60". Options with another
+// specifier are kept as is. Entries holding an option and its value are
+// split (see ffmpeg.SplitParams).
+func (r VideoResolution) ExtraArgs(stream string) []string {
+	params, _ := ffmpeg.ParseParams(ffmpeg.SplitParams(r.ExtraParams))
+	var args []string
+	for _, param := range params {
+		if param.Stream == "" || param.Stream == "v" {
+			param.Stream = stream
+		}
+		args = append(args, param.Args()...)
+	}
+	return args
+}
+
+// ValidateExtraParams checks that ExtraParams only holds options with their
+// values, and no option acting on the whole command such as -map or -f.
+func (r VideoResolution) ValidateExtraParams() error {
+	params, positional := ffmpeg.ParseParams(ffmpeg.SplitParams(r.ExtraParams))
+	if len(positional) > 0 {
+		return fmt.Errorf("%q is not the value of an option", positional[0])
+	}
+	for _, param := range params {
+		if renditionOptions[param.Name] {
+			return fmt.Errorf("-%s cannot be set for a single rendition", param.Name)
+		}
+		if param.Value == "" && len(param.Args()) == 2 {
+			return fmt.Errorf("-%s is missing its value", param.Name)
+		}
+	}
+	return nil
+}
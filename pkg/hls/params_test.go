@@ -0,0 +1,53 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtraArgs(t *testing.T) {
+	res := VideoResolution{ExtraParams: []string{"-x264opts keyint=60:ref=5", "-g:v", "48", "-aq-mode", "2", "-b:a", "96k"}}
+	want := "-x264opts:v:1 keyint=60:ref=5 -g:v:1 48 -aq-mode:v:1 2 -b:a 96k"
+	if got := strings.Join(res.ExtraArgs("v:1"), " "); got != want {
+		t.Errorf("ExtraArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateExtraParams(t *testing.T) {
+	tests := []struct {
+		params  []string
+		wantErr string
+	}{
+		{[]string{"-x264-params", "bframes=5", "-tune film"}, ""},
+		{[]string{"-x264opts keyint=60", "extra.mp4"}, `"extra.mp4" is not the value of an option`},
+		{[]string{"-map", "0:v"}, "-map cannot be set for a single rendition"},
+		{[]string{"-crf"}, "-crf is missing its value"},
+	}
+	for _, tt := range tests {
+		err := VideoResolution{ExtraParams: tt.params}.ValidateExtraParams()
+		if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+			t.Errorf("ValidateExtraParams(%q) = %v, want %q", tt.params, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRenditionExtraParamsArgs(t *testing.T) {
+	g := New(Options{
+		InputFile:       "input.mp4",
+		OutputDir:       "out",
+		SegmentDuration: 4,
+		Resolutions: []VideoResolution{
+			{Width: 1920, Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k", ExtraParams: []string{"-x264opts keyint=48"}},
+			{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "96k"},
+		},
+	})
+	// Os parâmetros da representação seguem as opções de vídeo geradas para ela
+	args := strings.Join(g.Args(), " ")
+	if !strings.Contains(args, "-bufsize:v:0 7500k -x264opts:v:0 keyint=48 ") || strings.Count(args, "-x264opts") != 1 {
+		t.Errorf("Args() = %q, want the params of the first rendition only", args)
+	}
+	chunk := strings.Join(g.ChunkArgs("chunk.ts", []string{"r0.ts", "r1.ts"}), " ")
+	if !strings.Contains(chunk, "-x264opts:v keyint=48 -f mpegts -y r0.ts") || strings.Count(chunk, "-x264opts") != 1 {
+		t.Errorf("ChunkArgs() = %q, want the params of the first rendition only", chunk)
+	}
+}
@@ -0,0 +1,137 @@
+package hls
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultParsedAudioBitrate is used when a resolution spec omits the audio bitrate.
+	defaultParsedAudioBitrate = "128k"
+	// maxRateFactor and bufSizeFactor derive MaxRate and BufSize from the video
+	// bitrate when they are not given, matching the ratios of DefaultBitrates.
+	maxRateFactor = 1.07
+	bufSizeFactor = 1.5
+)
+
+// ParseResolution parses a resolution spec of the form
+// "WIDTHxHEIGHT[@FPS]:VIDEO_BITRATE[:AUDIO_BITRATE]", e.g. "1280x720:2500k:128k"
+// or "854x480@30:1400k".
+// The audio bitrate defaults to 128k and the frame rate to the input's; MaxRate
+// and BufSize are derived from the video bitrate.
+func ParseResolution(spec string) (VideoResolution, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return VideoResolution{}, fmt.Errorf("invalid resolution %q: expected WIDTHxHEIGHT[@FPS]:VIDEO_BITRATE[:AUDIO_BITRATE]", spec)
+	}
+
+	size, fps, hasFPS := strings.Cut(parts[0], "@")
+	var frameRate float64
+	if hasFPS {
+		var err error
+		frameRate, err = strconv.ParseFloat(fps, 64)
+		if err != nil || frameRate <= 0 {
+			return VideoResolution{}, fmt.Errorf("invalid resolution %q: frame rate must be a positive number", spec)
+		}
+	}
+
+	w, h, found := strings.Cut(strings.ToLower(size), "x")
+	if !found {
+		return VideoResolution{}, fmt.Errorf("invalid resolution %q: size must be WIDTHxHEIGHT", spec)
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return VideoResolution{}, fmt.Errorf("invalid resolution %q: width and height must be positive integers", spec)
+	}
+
+	res := VideoResolution{
+		Width:        width,
+		Height:       height,
+		VideoBitrate: parts[1],
+		AudioBitrate: defaultParsedAudioBitrate,
+		FrameRate:    frameRate,
+	}
+	if len(parts) == 3 {
+		res.AudioBitrate = parts[2]
+	}
+
+	if err := completeResolution(&res); err != nil {
+		return VideoResolution{}, fmt.Errorf("invalid resolution %q: %w", spec, err)
+	}
+	return res, nil
+}
+
+// ParseResolutions parses several specs with ParseResolution.
+func ParseResolutions(specs []string) ([]VideoResolution, error) {
+	resolutions := make([]VideoResolution, 0, len(specs))
+	for _, spec := range specs {
+		res, err := ParseResolution(spec)
+		if err != nil {
+			return nil, err
+		}
+		resolutions = append(resolutions, res)
+	}
+	return resolutions, nil
+}
+
+// LoadResolutions reads a ladder from a JSON file containing an array of
+// VideoResolution objects, e.g.
+//
+//	[{"width": 1280, "height": 720, "video_bitrate": "2500k", "audio_bitrate": "128k"}]
+//
+// Missing max_rate and buf_size values are derived from the video bitrate and a
+// missing audio_bitrate defaults to 128k.
+func LoadResolutions(path string) ([]VideoResolution, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolutions []VideoResolution
+	if err := json.Unmarshal(data, &resolutions); err != nil {
+		return nil, fmt.Errorf("invalid resolutions file %s: %w", path, err)
+	}
+	if len(resolutions) == 0 {
+		return nil, fmt.Errorf("resolutions file %s does not contain any resolution", path)
+	}
+
+	for i := range resolutions {
+		res := &resolutions[i]
+		if res.Width <= 0 || res.Height <= 0 {
+			return nil, fmt.Errorf("invalid resolution #%d in %s: width and height must be positive", i+1, path)
+		}
+		if res.FrameRate < 0 {
+			return nil, fmt.Errorf("invalid resolution #%d in %s: frame rate must not be negative", i+1, path)
+		}
+		if res.AudioBitrate == "" {
+			res.AudioBitrate = defaultParsedAudioBitrate
+		}
+		if err := completeResolution(res); err != nil {
+			return nil, fmt.Errorf("invalid resolution #%d in %s: %w", i+1, path, err)
+		}
+	}
+	return resolutions, nil
+}
+
+// completeResolution validates the bitrates of res and fills in MaxRate and
+// BufSize when they are not set.
+func completeResolution(res *VideoResolution) error {
+	video := ParseBitrate(res.VideoBitrate)
+	if video <= 0 {
+		return fmt.Errorf("invalid video bitrate %q", res.VideoBitrate)
+	}
+	if ParseBitrate(res.AudioBitrate) <= 0 {
+		return fmt.Errorf("invalid audio bitrate %q", res.AudioBitrate)
+	}
+	if res.MaxRate == "" {
+		res.MaxRate = fmt.Sprintf("%dk", int64(float64(video)*maxRateFactor)/1000)
+	}
+	if res.BufSize == "" {
+		res.BufSize = fmt.Sprintf("%dk", int64(float64(video)*bufSizeFactor)/1000)
+	}
+	return nil
+}
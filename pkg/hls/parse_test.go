@@ -0,0 +1,88 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseResolution(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    VideoResolution
+		wantErr bool
+	}{
+		{
+			spec: "1280x720:2500k:96k",
+			want: VideoResolution{Width: 1280, Height: 720, VideoBitrate: "2500k", MaxRate: "2675k", BufSize: "3750k", AudioBitrate: "96k"},
+		},
+		{
+			spec: "640X360:800k",
+			want: VideoResolution{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "128k"},
+		},
+		{
+			spec: "854x480@29.97:1400k",
+			want: VideoResolution{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "128k", FrameRate: 29.97},
+		},
+		{spec: "1280x720", wantErr: true},
+		{spec: "1280x720@0:2500k", wantErr: true},
+		{spec: "1280x720@fast:2500k", wantErr: true},
+		{spec: "1280:2500k", wantErr: true},
+		{spec: "0x720:2500k", wantErr: true},
+		{spec: "1280x720:fast", wantErr: true},
+		{spec: "1280x720:2500k:loud", wantErr: true},
+		{spec: "1280x720:2500k:128k:extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseResolution(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResolution() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseResolution() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadResolutions(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "ladder.json")
+	content := `[
+		{"width": 1920, "height": 1080, "video_bitrate": "5000k", "max_rate": "5500k", "buf_size": "8000k", "audio_bitrate": "192k"},
+		{"width": 640, "height": 360, "video_bitrate": "800k"}
+	]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write ladder file: %v", err)
+	}
+
+	got, err := LoadResolutions(path)
+	if err != nil {
+		t.Fatalf("LoadResolutions() unexpected error: %v", err)
+	}
+	want := []VideoResolution{
+		{Width: 1920, Height: 1080, VideoBitrate: "5000k", MaxRate: "5500k", BufSize: "8000k", AudioBitrate: "192k"},
+		{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "128k"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadResolutions() = %+v, want %+v", got, want)
+	}
+
+	for name, content := range map[string]string{
+		"empty.json":   `[]`,
+		"invalid.json": `{"width": 1}`,
+		"nosize.json":  `[{"video_bitrate": "800k"}]`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if _, err := LoadResolutions(path); err == nil {
+			t.Errorf("LoadResolutions(%s) should fail", name)
+		}
+	}
+}
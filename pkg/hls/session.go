@@ -0,0 +1,107 @@
+package hls
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SessionData is an EXT-X-SESSION-DATA entry of a master playlist, carrying
+// arbitrary session metadata such as a content ID or analytics hints.
+type SessionData struct {
+	// DataID identifies the entry, in reverse DNS notation (e.g. "com.example.content-id").
+	DataID string `json:"data_id"`
+	// Value is the value of the entry. Exactly one of Value and URI is set.
+	Value string `json:"value,omitempty"`
+	// URI locates a JSON document holding the value of the entry.
+	URI string `json:"uri,omitempty"`
+	// Language is the optional language tag of Value (e.g. "en").
+	Language string `json:"language,omitempty"`
+}
+
+// Validate checks that the entry can be written as a valid EXT-X-SESSION-DATA tag.
+func (d SessionData) Validate() error {
+	if d.DataID == "" {
+		return fmt.Errorf("session data without DATA-ID")
+	}
+	if (d.Value == "") == (d.URI == "") {
+		return fmt.Errorf("session data %q must have either a value or a URI", d.DataID)
+	}
+	for _, s := range []string{d.DataID, d.Value, d.URI, d.Language} {
+		if strings.ContainsAny(s, "\"\r\n") {
+			return fmt.Errorf("session data %q contains a double quote or a line break", d.DataID)
+		}
+	}
+	return nil
+}
+
+// Tag returns the EXT-X-SESSION-DATA tag of the entry.
+func (d SessionData) Tag() string {
+	tag := fmt.Sprintf(`#EXT-X-SESSION-DATA:DATA-ID="%s"`, d.DataID)
+	if d.URI != "" {
+		tag += fmt.Sprintf(`,URI="%s"`, d.URI)
+	} else {
+		tag += fmt.Sprintf(`,VALUE="%s"`, d.Value)
+	}
+	if d.Language != "" {
+		tag += fmt.Sprintf(`,LANGUAGE="%s"`, d.Language)
+	}
+	return tag
+}
+
+// ParseSessionData parses a session data entry given as "DATA-ID=VALUE".
+func ParseSessionData(spec string) (SessionData, error) {
+	id, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return SessionData{}, fmt.Errorf("invalid session data %q (expected DATA-ID=VALUE)", spec)
+	}
+	d := SessionData{DataID: strings.TrimSpace(id), Value: value}
+	return d, d.Validate()
+}
+
+// ValidateMasterTag checks that tag can be added to a master playlist: a single
+// line starting with "#", either a tag (e.g. "#EXT-X-CONTENT-STEERING:...") or
+// a comment.
+func ValidateMasterTag(tag string) error {
+	if !strings.HasPrefix(tag, "#") || strings.ContainsAny(tag, "\r\n") {
+		return fmt.Errorf("invalid master playlist tag %q (expected a single line starting with #)", tag)
+	}
+	if tag == "#EXTM3U" || strings.HasPrefix(tag, "#EXT-X-VERSION:") {
+		return fmt.Errorf("master playlist tag %q is written by the encoder", tag)
+	}
+	return nil
+}
+
+// AddMasterTags adds the session data entries and the custom tags to the
+// master playlist at masterPath, after its header and before the renditions
+// and variants.
+func AddMasterTags(masterPath string, sessionData []SessionData, tags []string) error {
+	if len(sessionData) == 0 && len(tags) == 0 {
+		return nil
+	}
+	content, err := os.ReadFile(masterPath)
+	if err != nil {
+		return err
+	}
+
+	var added []string
+	for _, d := range sessionData {
+		added = append(added, d.Tag())
+	}
+	added = append(added, tags...)
+
+	lines := strings.Split(string(content), "\n")
+	at := len(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-MEDIA:") || strings.HasPrefix(line, "#EXT-X-STREAM-INF:") ||
+			strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF:") {
+			at = i
+			break
+		}
+	}
+	if at == len(lines) {
+		return fmt.Errorf("%s: no variant streams in the master playlist", masterPath)
+	}
+	lines = append(lines[:at], append(added, lines[at:]...)...)
+	return os.WriteFile(masterPath, []byte(strings.Join(lines, "\n")), 0644)
+}
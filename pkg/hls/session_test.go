@@ -0,0 +1,61 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSessionData(t *testing.T) {
+	got, err := ParseSessionData("com.example.title=A=B test")
+	if err != nil || got != (SessionData{DataID: "com.example.title", Value: "A=B test"}) {
+		t.Errorf("ParseSessionData() = %+v, %v", got, err)
+	}
+	for _, spec := range []string{"com.example.title", "=value", `com.example.title=say "hi"`, "com.example.title="} {
+		if _, err := ParseSessionData(spec); err == nil {
+			t.Errorf("ParseSessionData(%q) expected an error", spec)
+		}
+	}
+}
+
+func TestValidateMasterTag(t *testing.T) {
+	for tag, wantErr := range map[string]bool{
+		"# generated for the spring campaign":     false,
+		`#EXT-X-CONTENT-STEERING:SERVER-URI="/s"`: false,
+		"EXT-X-FOO":              true,
+		"#EXT-X-FOO\n#EXT-X-BAR": true,
+		"#EXT-X-VERSION:7":       true,
+	} {
+		if err := ValidateMasterTag(tag); (err != nil) != wantErr {
+			t.Errorf("ValidateMasterTag(%q) error = %v, wantErr %v", tag, err, wantErr)
+		}
+	}
+}
+
+func TestAddMasterTags(t *testing.T) {
+	master := filepath.Join(t.TempDir(), "master.m3u8")
+	content := "#EXTM3U\n#EXT-X-VERSION:6\n" +
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="CC1",INSTREAM-ID="CC1"` + "\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n"
+	if err := os.WriteFile(master, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := AddMasterTags(master, []SessionData{
+		{DataID: "com.example.content-id", Value: "1234"},
+		{DataID: "com.example.chapters", URI: "chapters.json", Language: "en"},
+	}, []string{"# analytics: campaign=spring"})
+	if err != nil {
+		t.Fatalf("AddMasterTags() unexpected error: %v", err)
+	}
+	got, _ := os.ReadFile(master)
+	want := "#EXTM3U\n#EXT-X-VERSION:6\n" +
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.content-id",VALUE="1234"` + "\n" +
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.chapters",URI="chapters.json",LANGUAGE="en"` + "\n" +
+		"# analytics: campaign=spring\n" +
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="CC1",INSTREAM-ID="CC1"` + "\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n"
+	if string(got) != want {
+		t.Errorf("Master playlist =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package hls
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTrickPlayInterval is the time between two frames of the trick-play
+	// rendition when Options.TrickPlayInterval is zero.
+	DefaultTrickPlayInterval = time.Second
+	// DefaultTrickPlayWidth is the width of the trick-play frames when
+	// Options.TrickPlayWidth is zero.
+	DefaultTrickPlayWidth = 320
+	// MinTrickPlayInterval is the shortest time allowed between two trick-play frames.
+	MinTrickPlayInterval = 100 * time.Millisecond
+	// MaxTrickPlayWidth is the largest width allowed for the trick-play frames.
+	MaxTrickPlayWidth = 1920
+)
+
+// iFramesVersion is the first HLS version with I-frame playlists
+// (EXT-X-I-FRAMES-ONLY and EXT-X-I-FRAME-STREAM-INF).
+const iFramesVersion = 4
+
+// IFrameStream describes an I-frame only rendition of a master playlist, used
+// by players for trick play (fast forward, rewind and scrubbing previews).
+type IFrameStream struct {
+	// URI is the I-frame media playlist, relative to the master playlist.
+	URI string
+	// Width and Height are the dimensions of the frames in pixels.
+	Width, Height int
+	// Codecs is the RFC 6381 codec tag of the frames, e.g. "avc1.4d401e".
+	Codecs string
+}
+
+// TrickPlayLevel returns the H.264 Main profile level fitting frames of
+// width x height (e.g. "3.0") and its RFC 6381 codec tag (e.g. "avc1.4d401e").
+func TrickPlayLevel(width, height int) (level, codecs string) {
+	macroblocks := ((width + 15) / 16) * ((height + 15) / 16)
+	switch {
+	case macroblocks <= 1620:
+		level = "3.0"
+	case macroblocks <= 3600:
+		level = "3.1"
+	case macroblocks <= 8192:
+		level = "4.0"
+	default:
+		level = "5.1"
+	}
+	idc, _ := strconv.ParseFloat(level, 64)
+	return level, fmt.Sprintf("avc1.4d40%02x", int(idc*10))
+}
+
+// MarkIFramesOnly declares the media playlist at path as an I-frame playlist
+// (EXT-X-I-FRAMES-ONLY), raising its EXT-X-VERSION to 4 if needed. Every
+// segment of the playlist must hold a single keyframe.
+func MarkIFramesOnly(path string) error {
+	if err := ensureVersion(path, iFramesVersion); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(content), "#EXT-X-I-FRAMES-ONLY") {
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	at := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-VERSION:") {
+			at = i + 1
+			break
+		}
+	}
+	if at < 0 {
+		return fmt.Errorf("%s: not an HLS playlist", path)
+	}
+	lines = append(lines[:at], append([]string{"#EXT-X-I-FRAMES-ONLY"}, lines[at:]...)...)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// AddIFrameStream declares the I-frame rendition s in the master playlist at
+// masterPath (EXT-X-I-FRAME-STREAM-INF), after the variants, with the peak and
+// average bitrates measured on its segments. The EXT-X-VERSION of the master
+// playlist is raised to 4 if needed.
+func AddIFrameStream(masterPath string, s IFrameStream) error {
+	rate, ok := measureBitrate(filepath.Join(filepath.Dir(masterPath), filepath.FromSlash(s.URI)))
+	if !ok {
+		return fmt.Errorf("%s: cannot measure the bitrate of the I-frame playlist", s.URI)
+	}
+	if err := ensureVersion(masterPath, iFramesVersion); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(masterPath)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(content), "#EXT-X-STREAM-INF:") {
+		return fmt.Errorf("%s: no variant streams in the master playlist", masterPath)
+	}
+
+	tag := fmt.Sprintf(`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,RESOLUTION=%dx%d`,
+		rate.peak, rate.average, s.Width, s.Height)
+	if s.Codecs != "" {
+		tag += fmt.Sprintf(`,CODECS="%s"`, s.Codecs)
+	}
+	tag += fmt.Sprintf(`,URI="%s"`, s.URI)
+
+	playlist := strings.TrimRight(string(content), "\n") + "\n" + tag + "\n"
+	return os.WriteFile(masterPath, []byte(playlist), 0644)
+}
+
+// ensureVersion raises the EXT-X-VERSION of the playlist at path to version
+// if it declares an older one, or none.
+func ensureVersion(path string, version int) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "#EXT-X-VERSION:"); ok {
+			if current, err := strconv.Atoi(value); err == nil && current >= version {
+				return nil
+			}
+		}
+	}
+	return SetPlaylistVersion(path, version)
+}
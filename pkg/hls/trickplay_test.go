@@ -0,0 +1,71 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrickPlayLevel(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		level, codecs string
+	}{
+		{320, 180, "3.0", "avc1.4d401e"},
+		{1280, 720, "3.1", "avc1.4d401f"},
+		{1920, 1080, "4.0", "avc1.4d4028"},
+		{1920, 3414, "5.1", "avc1.4d4033"},
+	} {
+		level, codecs := TrickPlayLevel(tc.width, tc.height)
+		if level != tc.level || codecs != tc.codecs {
+			t.Errorf("TrickPlayLevel(%d, %d) = %s, %s, want %s, %s", tc.width, tc.height, level, codecs, tc.level, tc.codecs)
+		}
+	}
+}
+
+func TestMarkIFramesOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "playlist.m3u8")
+	content := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:1\n#EXTINF:1.000000,\nframe00000.ts\n#EXT-X-ENDLIST\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := MarkIFramesOnly(path); err != nil {
+			t.Fatalf("MarkIFramesOnly() error: %v", err)
+		}
+	}
+	got, _ := os.ReadFile(path)
+	want := "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-I-FRAMES-ONLY\n#EXT-X-TARGETDURATION:1\n#EXTINF:1.000000,\nframe00000.ts\n#EXT-X-ENDLIST\n"
+	if string(got) != want {
+		t.Errorf("Playlist =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAddIFrameStream(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "master.m3u8")
+	content := "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nstream_0/playlist.m3u8\n"
+	if err := os.WriteFile(master, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "trickplay"), 0755)
+	os.WriteFile(filepath.Join(dir, "trickplay", "playlist.m3u8"),
+		[]byte("#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-I-FRAMES-ONLY\n#EXTINF:1.000000,\nframe00000.ts\n#EXTINF:1.000000,\nframe00001.ts\n#EXT-X-ENDLIST\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "trickplay", "frame00000.ts"), make([]byte, 2000), 0644)
+	os.WriteFile(filepath.Join(dir, "trickplay", "frame00001.ts"), make([]byte, 1000), 0644)
+
+	err := AddIFrameStream(master, IFrameStream{URI: "trickplay/playlist.m3u8", Width: 320, Height: 180, Codecs: "avc1.4d401e"})
+	if err != nil {
+		t.Fatalf("AddIFrameStream() error: %v", err)
+	}
+	got, _ := os.ReadFile(master)
+	want := content + `#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=16000,AVERAGE-BANDWIDTH=12000,RESOLUTION=320x180,CODECS="avc1.4d401e",URI="trickplay/playlist.m3u8"` + "\n"
+	if string(got) != want {
+		t.Errorf("Master playlist =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := AddIFrameStream(master, IFrameStream{URI: "missing/playlist.m3u8"}); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("AddIFrameStream() with a missing playlist error = %v", err)
+	}
+}
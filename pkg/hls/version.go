@@ -0,0 +1,95 @@
+package hls
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// MinVersion is the oldest HLS protocol version that can be targeted, as
+	// supported by legacy players (e.g. old smart TVs and set-top boxes).
+	MinVersion = 3
+	// MaxVersion is the newest HLS protocol version that can be targeted.
+	MaxVersion = 7
+	// DefaultVersion is the HLS protocol version targeted when Options.Version
+	// is zero: MPEG-TS segments declared as independent segments.
+	DefaultVersion = 6
+)
+
+// Minimum protocol versions of the playlist features.
+const (
+	// byteRangeVersion is the first version with EXT-X-BYTERANGE.
+	byteRangeVersion = 4
+	// independentSegmentsVersion is the first version declaring
+	// EXT-X-INDEPENDENT-SEGMENTS.
+	independentSegmentsVersion = 6
+	// fmp4Version is the first version with fragmented MP4 (CMAF) segments.
+	fmp4Version = 7
+)
+
+// CheckVersion verifies that the HLS protocol version supports the segment
+// format ("mpegts" or "fmp4", empty for the default of the version) and, if
+// byteRange is set, byte range segments.
+func CheckVersion(version int, segmentFormat string, byteRange bool) error {
+	if version < MinVersion || version > MaxVersion {
+		return fmt.Errorf("unsupported HLS version %d (available: %d to %d)", version, MinVersion, MaxVersion)
+	}
+	if segmentFormat == "fmp4" && version < fmp4Version {
+		return fmt.Errorf("fMP4 segments require HLS version %d, got %d", fmp4Version, version)
+	}
+	if byteRange && version < byteRangeVersion {
+		return fmt.Errorf("byte range segments require HLS version %d, got %d", byteRangeVersion, version)
+	}
+	return nil
+}
+
+// segmentFormatForVersion returns the default segment format of version:
+// fMP4 from version 7, MPEG-TS before.
+func segmentFormatForVersion(version int) string {
+	if version >= fmp4Version {
+		return "fmp4"
+	}
+	return "mpegts"
+}
+
+// SetPlaylistVersion sets the EXT-X-VERSION of the playlist at path to version,
+// adding the tag after #EXTM3U when the playlist has none.
+func SetPlaylistVersion(path string, version int) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tag := fmt.Sprintf("#EXT-X-VERSION:%d", version)
+	lines := strings.Split(string(content), "\n")
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-VERSION:") {
+			lines[i] = tag
+			found = true
+		}
+	}
+	if !found {
+		if len(lines) == 0 || strings.TrimSpace(lines[0]) != "#EXTM3U" {
+			return fmt.Errorf("%s: not an HLS playlist", path)
+		}
+		lines = append([]string{lines[0], tag}, lines[1:]...)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// SetPlaylistVersions sets the EXT-X-VERSION of the master playlist at
+// masterPath and of the media playlists generated in outputDir to version.
+func SetPlaylistVersions(outputDir, masterPath string, version int) error {
+	playlists, err := MediaPlaylists(outputDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range append(playlists, masterPath) {
+		if err := SetPlaylistVersion(path, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
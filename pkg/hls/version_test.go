@@ -0,0 +1,73 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		version       int
+		segmentFormat string
+		byteRange     bool
+		wantErr       bool
+	}{
+		{3, "", false, false},
+		{3, "mpegts", true, true},
+		{4, "", true, false},
+		{6, "fmp4", false, true},
+		{7, "fmp4", true, false},
+		{2, "", false, true},
+		{8, "", false, true},
+	}
+	for _, tt := range tests {
+		if err := CheckVersion(tt.version, tt.segmentFormat, tt.byteRange); (err != nil) != tt.wantErr {
+			t.Errorf("CheckVersion(%d, %q, %v) error = %v, wantErr %v", tt.version, tt.segmentFormat, tt.byteRange, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildFFmpegArgsVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     Options
+		wantFlags   string
+		wantType    string
+		wantSegment string
+	}{
+		{"default", Options{}, "independent_segments", "mpegts", "out/stream_%v/data%03d.ts"},
+		{"legacy", Options{Version: 3}, "", "mpegts", "out/stream_%v/data%03d.ts"},
+		{"byte ranges", Options{Version: 4, ByteRange: true}, "single_file", "mpegts", "out/stream_%v/data.ts"},
+		{"cmaf", Options{Version: 7}, "independent_segments", "fmp4", "out/stream_%v/data%03d.m4s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.InputFile, tt.options.OutputDir = "input.mp4", "out"
+			args := argsToMap(New(tt.options).Args())
+			if args["-hls_flags"] != tt.wantFlags || args["-hls_segment_type"] != tt.wantType || args["-hls_segment_filename"] != tt.wantSegment {
+				t.Errorf("Args() flags %q, type %q, segments %q; want %q, %q, %q",
+					args["-hls_flags"], args["-hls_segment_type"], args["-hls_segment_filename"], tt.wantFlags, tt.wantType, tt.wantSegment)
+			}
+		})
+	}
+}
+
+func TestSetPlaylistVersions(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "master.m3u8")
+	media := filepath.Join(dir, "stream_0", "playlist.m3u8")
+	os.MkdirAll(filepath.Dir(media), 0755)
+	os.WriteFile(master, []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n"), 0644)
+	os.WriteFile(media, []byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXTINF:6.000000,\ndata000.ts\n"), 0644)
+
+	if err := SetPlaylistVersions(dir, master, 4); err != nil {
+		t.Fatalf("SetPlaylistVersions() unexpected error: %v", err)
+	}
+	if got, _ := os.ReadFile(master); string(got) != "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n" {
+		t.Errorf("Master playlist =\n%s", got)
+	}
+	if got, _ := os.ReadFile(media); string(got) != "#EXTM3U\n#EXT-X-VERSION:4\n#EXTINF:6.000000,\ndata000.ts\n" {
+		t.Errorf("Media playlist =\n%s", got)
+	}
+}
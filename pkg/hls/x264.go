@@ -0,0 +1,146 @@
+package hls
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Preset is an x264/x265 preset, trading encoding speed for compression
+// efficiency.
+type Preset string
+
+const (
+	PresetUltrafast Preset = "ultrafast"
+	PresetSuperfast Preset = "superfast"
+	PresetVeryfast  Preset = "veryfast"
+	PresetFaster    Preset = "faster"
+	PresetFast      Preset = "fast"
+	PresetMedium    Preset = "medium"
+	PresetSlow      Preset = "slow"
+	PresetSlower    Preset = "slower"
+	PresetVeryslow  Preset = "veryslow"
+	PresetPlacebo   Preset = "placebo"
+)
+
+// Tune is an x264/x265 tuning, adapting the encoder to the content or to a
+// constraint such as latency.
+type Tune string
+
+const (
+	TuneFilm        Tune = "film"
+	TuneAnimation   Tune = "animation"
+	TuneGrain       Tune = "grain"
+	TuneStillImage  Tune = "stillimage"
+	TuneFastDecode  Tune = "fastdecode"
+	TuneZeroLatency Tune = "zerolatency"
+	TunePSNR        Tune = "psnr"
+	TuneSSIM        Tune = "ssim"
+)
+
+// Profile is an H.264 (x264) or HEVC (x265) profile, restricting the coding
+// tools used so that the output plays on the decoders of the profile.
+type Profile string
+
+const (
+	ProfileBaseline Profile = "baseline"
+	ProfileMain     Profile = "main"
+	ProfileHigh     Profile = "high"
+	ProfileHigh10   Profile = "high10"
+	ProfileHigh422  Profile = "high422"
+	ProfileHigh444  Profile = "high444"
+	// ProfileMain10 is the 10-bit HEVC profile, for x265 only.
+	ProfileMain10 Profile = "main10"
+)
+
+// Level is an H.264 or HEVC level (e.g. "4.1"), bounding the resolution,
+// frame rate and bitrate of the output for the decoders of the level.
+type Level string
+
+var (
+	presets  = []Preset{PresetUltrafast, PresetSuperfast, PresetVeryfast, PresetFaster, PresetFast, PresetMedium, PresetSlow, PresetSlower, PresetVeryslow, PresetPlacebo}
+	tunes    = []Tune{TuneFilm, TuneAnimation, TuneGrain, TuneStillImage, TuneFastDecode, TuneZeroLatency, TunePSNR, TuneSSIM}
+	profiles = []Profile{ProfileBaseline, ProfileMain, ProfileHigh, ProfileHigh10, ProfileHigh422, ProfileHigh444, ProfileMain10}
+	levels   = []Level{"1", "1b", "1.1", "1.2", "1.3", "2", "2.1", "2.2", "3", "3.1", "3.2", "4", "4.1", "4.2", "5", "5.1", "5.2", "6", "6.1", "6.2"}
+)
+
+// EncoderSettings are the x264/x265 settings of the video encoder. Empty
+// fields keep the encoder defaults (the medium preset, no tuning, and the
+// profile and level the encoder picks for the stream).
+type EncoderSettings struct {
+	// Preset trades encoding speed for compression efficiency.
+	Preset Preset `json:"preset,omitempty"`
+	// Tune adapts the encoder to the content (e.g. film, animation) or to a
+	// constraint (e.g. zerolatency).
+	Tune Tune `json:"tune,omitempty"`
+	// Profile restricts the coding tools to those of the profile, e.g. main
+	// or baseline for older devices.
+	Profile Profile `json:"profile,omitempty"`
+	// Level bounds the output to the limits of the level, e.g. "4.1".
+	Level Level `json:"level,omitempty"`
+}
+
+// Validate checks that the settings are known x264/x265 values.
+func (s EncoderSettings) Validate() error {
+	if s.Preset != "" && !slices.Contains(presets, s.Preset) {
+		return fmt.Errorf("unknown preset %q (available: %s)", s.Preset, joinNames(presets))
+	}
+	if s.Tune != "" && !slices.Contains(tunes, s.Tune) {
+		return fmt.Errorf("unknown tune %q (available: %s)", s.Tune, joinNames(tunes))
+	}
+	if s.Profile != "" && !slices.Contains(profiles, s.Profile) {
+		return fmt.Errorf("unknown profile %q (available: %s)", s.Profile, joinNames(profiles))
+	}
+	if s.Level != "" && !slices.Contains(levels, s.Level) {
+		return fmt.Errorf("unknown level %q (available: %s)", s.Level, joinNames(levels))
+	}
+	return nil
+}
+
+// Or returns s with its empty fields taken from defaults, e.g. the settings
+// of a resolution completed with those of the whole encode.
+func (s EncoderSettings) Or(defaults EncoderSettings) EncoderSettings {
+	if s.Preset == "" {
+		s.Preset = defaults.Preset
+	}
+	if s.Tune == "" {
+		s.Tune = defaults.Tune
+	}
+	if s.Profile == "" {
+		s.Profile = defaults.Profile
+	}
+	if s.Level == "" {
+		s.Level = defaults.Level
+	}
+	return s
+}
+
+// Args returns the ffmpeg arguments applying the settings to the video
+// streams selected by stream, a stream specifier such as "v:0" ("" for every
+// stream).
+func (s EncoderSettings) Args(stream string) []string {
+	if stream != "" {
+		stream = ":" + stream
+	}
+	var args []string
+	for _, setting := range []struct{ name, value string }{
+		{"-preset", string(s.Preset)},
+		{"-tune", string(s.Tune)},
+		{"-profile", string(s.Profile)},
+		{"-level", string(s.Level)},
+	} {
+		if setting.value != "" {
+			args = append(args, setting.name+stream, setting.value)
+		}
+	}
+	return args
+}
+
+// joinNames lists the names of values for error messages.
+func joinNames[T ~string](values []T) string {
+	names := make([]string, len(values))
+	for i, value := range values {
+		names[i] = string(value)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,63 @@
+package hls
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEncoderSettingsValidate(t *testing.T) {
+	valid := EncoderSettings{Preset: PresetVeryslow, Tune: TuneAnimation, Profile: ProfileMain10, Level: "5.1"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() of %+v error = %v", valid, err)
+	}
+	if err := (EncoderSettings{}).Validate(); err != nil {
+		t.Errorf("Validate() of empty settings error = %v", err)
+	}
+	for _, invalid := range []EncoderSettings{
+		{Preset: "Medium"},
+		{Tune: "cartoon"},
+		{Profile: "extended"},
+		{Level: "4.5"},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate() of %+v expected an error", invalid)
+		}
+	}
+}
+
+func TestEncoderSettingsArgs(t *testing.T) {
+	defaults := EncoderSettings{Preset: PresetSlow, Profile: ProfileHigh, Level: "4.1"}
+	settings := EncoderSettings{Profile: ProfileBaseline, Tune: TuneFilm}.Or(defaults)
+	want := []string{"-preset:v:2", "slow", "-tune:v:2", "film", "-profile:v:2", "baseline", "-level:v:2", "4.1"}
+	if got := settings.Args("v:2"); !slices.Equal(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+	if got := (EncoderSettings{}).Args(""); len(got) != 0 {
+		t.Errorf("Args() of empty settings = %v", got)
+	}
+}
+
+func TestBuildFFmpegArgsEncoderSettings(t *testing.T) {
+	g := New(Options{
+		InputFile: "input.mp4",
+		OutputDir: "out",
+		Resolutions: []VideoResolution{
+			{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+			{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "96k",
+				EncoderSettings: EncoderSettings{Profile: ProfileBaseline, Level: "3"}},
+		},
+		EncoderSettings: EncoderSettings{Preset: PresetSlow, Profile: ProfileHigh},
+	})
+	args := g.Args()
+	for _, pair := range [][2]string{
+		{"-preset:v:0", "slow"}, {"-profile:v:0", "high"},
+		{"-preset:v:1", "slow"}, {"-profile:v:1", "baseline"}, {"-level:v:1", "3"},
+	} {
+		if !contains(args, pair[0], pair[1]) {
+			t.Errorf("Args() missing %s %s: %v", pair[0], pair[1], args)
+		}
+	}
+	if slices.Contains(args, "-level:v:0") {
+		t.Errorf("Args() sets a level for the first resolution: %v", args)
+	}
+}
@@ -0,0 +1,100 @@
+// Package jobspec describes a single transcoding job with files, for running
+// HLSpresso as a container job (e.g. a Kubernetes Job or an Argo Workflows
+// step): the job options are read from a mounted JSON spec, and the outcome is
+// written to a JSON result that the orchestrator collects once the container
+// exits.
+package jobspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+	"github.com/heyjunin/HLSpresso/pkg/transcoder"
+)
+
+// Status values of a Result.
+const (
+	StatusSucceeded   = "succeeded"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+)
+
+// Load reads the transcoder options of a job from the JSON spec at path. The
+// spec holds the fields of transcoder.Options, as stored by the job store
+// (e.g. {"InputPath": "/in/a.mp4", "OutputPath": "/out/a", "OutputType": "hls"},
+// durations in nanoseconds). Unknown fields are rejected so that typos do not
+// silently fall back to defaults. Keys and hooks are never read from the spec.
+func Load(path string) (transcoder.Options, error) {
+	var options transcoder.Options
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return options, errors.Wrap(err, errors.FileNotFoundError, "Job spec not found", errors.ErrFileNotFound)
+		}
+		return options, errors.Wrap(err, errors.SystemError, "Failed to read the job spec", 15)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&options); err != nil {
+		return options, errors.Wrap(err, errors.ValidationError, "Invalid job spec", 4)
+	}
+	return options, nil
+}
+
+// Result is the outcome of a job, written when the job ends, including when
+// it is interrupted by SIGTERM, so that a retry can tell how far it went.
+type Result struct {
+	JobID    string `json:"job_id,omitempty"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	// Output is the path of the produced playlist or file, on success.
+	Output string               `json:"output,omitempty"`
+	Error  *progress.EventError `json:"error,omitempty"`
+	// Progress is the last progress event of the job.
+	Progress        *progress.ProgressEvent `json:"progress,omitempty"`
+	StartedAt       time.Time               `json:"started_at"`
+	FinishedAt      time.Time               `json:"finished_at"`
+	DurationSeconds float64                 `json:"duration_seconds"`
+}
+
+// NewResult returns the result of a job started at startedAt, which produced
+// output or failed with err. The status and exit code follow errors.ExitCode:
+// a job cancelled by a signal is reported as interrupted.
+func NewResult(jobID, output string, err error, last *progress.ProgressEvent, startedAt time.Time) Result {
+	finishedAt := time.Now()
+	result := Result{
+		JobID:           jobID,
+		Status:          StatusSucceeded,
+		Progress:        last,
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+		DurationSeconds: finishedAt.Sub(startedAt).Seconds(),
+	}
+	if err == nil {
+		result.Output = output
+		return result
+	}
+
+	result.ExitCode = errors.ExitCode(err)
+	result.Error = progress.NewEventError(err)
+	result.Status = StatusFailed
+	if result.ExitCode == errors.ExitInterrupted {
+		result.Status = StatusInterrupted
+	}
+	return result
+}
+
+// WriteResult writes result as JSON to path, such as the termination message
+// file of a Kubernetes container (/dev/termination-log).
+func WriteResult(path string, result Result) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
@@ -0,0 +1,85 @@
+package jobspec
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+	"github.com/heyjunin/HLSpresso/pkg/transcoder"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.json")
+	spec := `{"InputPath": "/in/a.mp4", "OutputPath": "/out/a", "OutputType": "hls", "EncodeTimeout": 60000000000}`
+	if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+	options, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if options.InputPath != "/in/a.mp4" || options.OutputType != transcoder.HLSOutput || options.EncodeTimeout != time.Minute {
+		t.Errorf("Load() = %+v", options)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, spec := range map[string]string{
+		"unknown field": `{"InputPath": "/in/a.mp4", "OutputDir": "/out/a"}`,
+		"syntax":        `{"InputPath": `,
+	} {
+		path := filepath.Join(dir, "job.json")
+		os.WriteFile(path, []byte(spec), 0644)
+		var sErr *errors.StructuredError
+		if _, err := Load(path); !stderrors.As(err, &sErr) || sErr.Type != errors.ValidationError {
+			t.Errorf("%s: Load() error = %v, want a validation error", name, err)
+		}
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); errors.ExitCode(err) != errors.ExitFileNotFound {
+		t.Errorf("Load() of a missing spec error = %v, want a file not found error", err)
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	startedAt := time.Now().Add(-time.Minute)
+	last := &progress.ProgressEvent{Status: "processing", Percentage: 42}
+
+	result := NewResult("job-1", "/out/a/master.m3u8", nil, last, startedAt)
+	if result.Status != StatusSucceeded || result.ExitCode != 0 || result.Output != "/out/a/master.m3u8" || result.DurationSeconds < 60 {
+		t.Errorf("NewResult() of a success = %+v", result)
+	}
+
+	result = NewResult("job-1", "", fmt.Errorf("%w: ffmpeg killed", context.Canceled), last, startedAt)
+	if result.Status != StatusInterrupted || result.ExitCode != errors.ExitInterrupted || result.Progress.Percentage != 42 {
+		t.Errorf("NewResult() of an interruption = %+v", result)
+	}
+
+	err := errors.New(errors.TranscodingError, "FFmpeg failed", "exit status 1", 13)
+	result = NewResult("job-1", "", err, last, startedAt)
+	if result.Status != StatusFailed || result.ExitCode != errors.ExitTranscoding || result.Error.Code != 13 || result.Output != "" {
+		t.Errorf("NewResult() of a failure = %+v", result)
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := WriteResult(path, Result{JobID: "job-1", Status: StatusSucceeded}); err != nil {
+		t.Fatalf("WriteResult() error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	var result map[string]interface{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		t.Fatalf("Invalid result JSON: %v", err)
+	}
+	if result["job_id"] != "job-1" || result["status"] != "succeeded" || result["exit_code"] != float64(0) {
+		t.Errorf("Result = %s", content)
+	}
+}
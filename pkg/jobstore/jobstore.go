@@ -0,0 +1,431 @@
+// Package jobstore persists transcoding jobs, with their options, progress
+// snapshots and results, in a SQLite or PostgreSQL database, so that a
+// service running HLSpresso can recover its unfinished jobs after a restart
+// and list the job history.
+//
+// The store uses database/sql: the program imports the driver of its choice,
+// e.g. for SQLite:
+//
+//	import _ "modernc.org/sqlite"
+//
+//	store, err := jobstore.Open(ctx, jobstore.SQLite, "sqlite", "file:jobs.db")
+//
+// or for PostgreSQL:
+//
+//	import _ "github.com/jackc/pgx/v5/stdlib"
+//
+//	store, err := jobstore.Open(ctx, jobstore.Postgres, "pgx", "postgres://hlspresso@db/hlspresso")
+//
+// Several instances sharing a database can also use the store as a job queue:
+// each runs a Worker, which claims queued jobs under a lease, renews it while
+// the job runs and queues again the jobs of workers whose lease expired.
+package jobstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// Dialect selects the SQL dialect of the database.
+type Dialect string
+
+const (
+	// SQLite is the dialect of SQLite databases, the default.
+	SQLite Dialect = "sqlite"
+	// Postgres is the dialect of PostgreSQL databases.
+	Postgres Dialect = "postgres"
+)
+
+// ParseDialect converts a dialect name ("sqlite" or "postgres",
+// case-insensitive) to a Dialect. An empty name returns SQLite.
+func ParseDialect(name string) (Dialect, error) {
+	switch dialect := Dialect(strings.ToLower(strings.TrimSpace(name))); dialect {
+	case "":
+		return SQLite, nil
+	case SQLite, Postgres:
+		return dialect, nil
+	case "postgresql":
+		return Postgres, nil
+	default:
+		return "", fmt.Errorf("unknown database dialect %q (available: sqlite, postgres)", name)
+	}
+}
+
+// Status is the state of a job.
+type Status string
+
+const (
+	// StatusQueued is the state of a job waiting to run.
+	StatusQueued Status = "queued"
+	// StatusRunning is the state of a job being transcoded.
+	StatusRunning Status = "running"
+	// StatusSucceeded is the state of a job whose output is complete.
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed is the state of a job that ended with an error.
+	StatusFailed Status = "failed"
+	// StatusCancelled is the state of a job stopped on request, e.g. with
+	// transcoder.Transcoder.Cancel.
+	StatusCancelled Status = "cancelled"
+)
+
+// Finished reports whether s is a terminal state.
+func (s Status) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// Priority orders the queued jobs: Claim takes the jobs of higher priority
+// first, and the jobs of a same priority in the order they were created.
+type Priority int
+
+// Named priorities. Any other value is valid and ranks between them.
+const (
+	// PriorityLow is the priority of bulk jobs, e.g. backfills.
+	PriorityLow Priority = -10
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = 0
+	// PriorityHigh is the priority of urgent jobs, which run before the
+	// queued jobs of lower priority.
+	PriorityHigh Priority = 10
+)
+
+// ParsePriority converts a priority name ("low", "normal" or "high",
+// case-insensitive) or number to a Priority. An empty name returns
+// PriorityNormal.
+func ParsePriority(name string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(name))
+	if err != nil {
+		return 0, fmt.Errorf("unknown job priority %q (available: low, normal, high or a number)", name)
+	}
+	return Priority(n), nil
+}
+
+// ErrNotFound is returned for jobs that are not in the store.
+var ErrNotFound = stderrors.New("job not found")
+
+// Job is the record of a transcoding job.
+type Job struct {
+	// ID identifies the job, e.g. the transcoder.Options.JobID.
+	ID string `json:"id"`
+	// Tenant is the tenant the job belongs to, e.g. the auth.Principal
+	// Tenant of its submitter. Empty for services without tenants.
+	Tenant string `json:"tenant,omitempty"`
+	// Input is the input path or URL.
+	Input string `json:"input"`
+	// Output is the output path.
+	Output string `json:"output"`
+	// Options holds the options of the job as JSON, e.g. a marshalled
+	// transcoder.Options, to submit it again after a restart.
+	Options json.RawMessage `json:"options,omitempty"`
+	// Status is the state of the job.
+	Status Status `json:"status"`
+	// Priority orders the job in the queue. Defaults to PriorityNormal.
+	Priority Priority `json:"priority,omitempty"`
+	// Progress is the last progress snapshot saved, if any.
+	Progress *progress.ProgressEvent `json:"progress,omitempty"`
+	// Result is the path returned by Transcode, once the job succeeded.
+	Result string `json:"result,omitempty"`
+	// Error describes the failure of a failed job.
+	Error *progress.EventError `json:"error,omitempty"`
+	// Worker is the worker holding the lease of a job claimed with Claim.
+	Worker string `json:"worker,omitempty"`
+	// LeaseExpiresAt is the time the lease of Worker ends unless renewed
+	// with Heartbeat. Zero for jobs not claimed with Claim.
+	LeaseExpiresAt time.Time `json:"lease_expires_at,omitempty"`
+	// CreatedAt and UpdatedAt are the times the job was created and last changed.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// createTable creates the jobs table. It is valid in both dialects.
+const createTable = `CREATE TABLE IF NOT EXISTS hlspresso_jobs (
+		id TEXT PRIMARY KEY,
+		tenant TEXT NOT NULL,
+		input TEXT NOT NULL,
+		output TEXT NOT NULL,
+		options TEXT NOT NULL,
+		status TEXT NOT NULL,
+		priority INTEGER NOT NULL DEFAULT 0,
+		progress TEXT NOT NULL,
+		result TEXT NOT NULL,
+		error TEXT NOT NULL,
+		worker TEXT NOT NULL,
+		lease_expires_at BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL
+	)`
+
+// addedColumns are the columns added to the jobs table after its first
+// version, with their definition, which Migrate adds to older tables.
+var addedColumns = []struct{ name, definition string }{
+	{"priority", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// indexes creates the indexes of the jobs table.
+var indexes = []string{
+	`CREATE INDEX IF NOT EXISTS hlspresso_jobs_status ON hlspresso_jobs (status, created_at)`,
+	`CREATE INDEX IF NOT EXISTS hlspresso_jobs_queue ON hlspresso_jobs (status, priority, created_at)`,
+	`CREATE INDEX IF NOT EXISTS hlspresso_jobs_tenant ON hlspresso_jobs (tenant, created_at)`,
+}
+
+// jobColumns lists the columns of the jobs, in the order query scans them.
+const jobColumns = "id, tenant, input, output, options, status, priority, progress, result, error, worker, lease_expires_at, created_at, updated_at"
+
+// Store saves job records in a SQL database. It is safe for concurrent use.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	// now returns the current time. Replaced in tests.
+	now func() time.Time
+}
+
+// New returns a Store saving jobs in db, whose SQL dialect is dialect. Call
+// Migrate to create the jobs table.
+func New(db *sql.DB, dialect Dialect) (*Store, error) {
+	dialect, err := ParseDialect(string(dialect))
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, dialect: dialect, now: time.Now}, nil
+}
+
+// Open opens the database dsn with the database/sql driver driverName and
+// returns a Store on it, creating the jobs table if needed.
+func Open(ctx context.Context, dialect Dialect, driverName, dsn string) (*Store, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	store, err := New(db, dialect)
+	if err == nil {
+		err = store.Migrate(ctx)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Migrate creates the jobs table and its indexes if they do not exist, and
+// adds to an existing table the columns it lacks.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create the jobs table: %w", err)
+	}
+	for _, column := range addedColumns {
+		// SQLite has no ADD COLUMN IF NOT EXISTS: probe the column first.
+		rows, err := s.db.QueryContext(ctx, `SELECT `+column.name+` FROM hlspresso_jobs WHERE 1 = 0`)
+		if err == nil {
+			rows.Close()
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE hlspresso_jobs ADD COLUMN `+column.name+` `+column.definition); err != nil {
+			return fmt.Errorf("failed to add the %s column to the jobs table: %w", column.name, err)
+		}
+	}
+	for _, statement := range indexes {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create the jobs table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create saves a new job, queued unless job.Status is set.
+func (s *Store) Create(ctx context.Context, job Job) error {
+	if job.ID == "" {
+		return fmt.Errorf("job without ID")
+	}
+	if job.Status == "" {
+		job.Status = StatusQueued
+	}
+	if len(job.Options) == 0 {
+		job.Options = json.RawMessage("{}")
+	}
+	now := s.now().UnixMilli()
+	return s.exec(ctx, `INSERT INTO hlspresso_jobs (`+jobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Tenant, job.Input, job.Output, string(job.Options), string(job.Status), int64(job.Priority), "", "", "", "", int64(0), now, now)
+}
+
+// Start marks the job id as running.
+func (s *Store) Start(ctx context.Context, id string) error {
+	return s.update(ctx, id, `status = ?`, string(StatusRunning))
+}
+
+// SaveProgress saves event as the progress snapshot of the job id.
+func (s *Store) SaveProgress(ctx context.Context, id string, event progress.ProgressEvent) error {
+	snapshot, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, id, `progress = ?`, string(snapshot))
+}
+
+// Finish records the outcome of the job id: succeeded with result, or failed
+// with jobErr, or cancelled when jobErr matches context.Canceled.
+func (s *Store) Finish(ctx context.Context, id, result string, jobErr error) error {
+	if jobErr == nil {
+		return s.update(ctx, id, `status = ?, result = ?, error = ?`, string(StatusSucceeded), result, "")
+	}
+	description, err := json.Marshal(progress.NewEventError(jobErr))
+	if err != nil {
+		return err
+	}
+	status := StatusFailed
+	if stderrors.Is(jobErr, context.Canceled) {
+		status = StatusCancelled
+	}
+	return s.update(ctx, id, `status = ?, result = ?, error = ?`, string(status), "", string(description))
+}
+
+// Get returns the job id, or ErrNotFound.
+func (s *Store) Get(ctx context.Context, id string) (Job, error) {
+	jobs, err := s.query(ctx, `SELECT `+jobColumns+` FROM hlspresso_jobs WHERE id = ?`, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if len(jobs) == 0 {
+		return Job{}, ErrNotFound
+	}
+	return jobs[0], nil
+}
+
+// List returns the job history, most recent first, up to limit jobs (all of
+// them if limit is zero).
+func (s *Store) List(ctx context.Context, limit int) ([]Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM hlspresso_jobs ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(limit)
+	}
+	return s.query(ctx, query)
+}
+
+// ListTenant returns the job history of tenant, most recent first, up to
+// limit jobs (all of them if limit is zero).
+func (s *Store) ListTenant(ctx context.Context, tenant string, limit int) ([]Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM hlspresso_jobs WHERE tenant = ? ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(limit)
+	}
+	return s.query(ctx, query, tenant)
+}
+
+// Unfinished returns the queued and running jobs, oldest first, to run again
+// after a restart. Running jobs were interrupted: their output is incomplete.
+func (s *Store) Unfinished(ctx context.Context) ([]Job, error) {
+	return s.query(ctx, `SELECT `+jobColumns+` FROM hlspresso_jobs WHERE status IN (?, ?) ORDER BY created_at, id`,
+		string(StatusQueued), string(StatusRunning))
+}
+
+// Delete removes the jobs finished before cutoff, returning how many were removed.
+func (s *Store) Delete(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM hlspresso_jobs WHERE status IN (?, ?, ?) AND updated_at < ?`),
+		string(StatusSucceeded), string(StatusFailed), string(StatusCancelled), cutoff.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// update sets the columns of assignments on the job id, and its update time.
+func (s *Store) update(ctx context.Context, id, assignments string, args ...interface{}) error {
+	args = append(args, s.now().UnixMilli(), id)
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE hlspresso_jobs SET `+assignments+`, updated_at = ? WHERE id = ?`), args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(query), args...)
+	return err
+}
+
+// query runs a SELECT of jobColumns and scans the jobs it returns.
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) ([]Job, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var (
+			job                                  Job
+			options, status, snapshot            string
+			description                          string
+			priority                             int64
+			leaseExpiresAt, createdAt, updatedAt int64
+		)
+		if err := rows.Scan(&job.ID, &job.Tenant, &job.Input, &job.Output, &options, &status, &priority, &snapshot,
+			&job.Result, &description, &job.Worker, &leaseExpiresAt, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		job.Options = json.RawMessage(options)
+		job.Status = Status(status)
+		job.Priority = Priority(priority)
+		if snapshot != "" {
+			job.Progress = &progress.ProgressEvent{}
+			if err := json.Unmarshal([]byte(snapshot), job.Progress); err != nil {
+				return nil, fmt.Errorf("job %s: invalid progress snapshot: %w", job.ID, err)
+			}
+		}
+		if description != "" {
+			job.Error = &progress.EventError{}
+			if err := json.Unmarshal([]byte(description), job.Error); err != nil {
+				return nil, fmt.Errorf("job %s: invalid error: %w", job.ID, err)
+			}
+		}
+		if leaseExpiresAt > 0 {
+			job.LeaseExpiresAt = time.UnixMilli(leaseExpiresAt)
+		}
+		job.CreatedAt, job.UpdatedAt = time.UnixMilli(createdAt), time.UnixMilli(updatedAt)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// rebind replaces the "?" placeholders of query with the "$1", "$2", ...
+// placeholders of PostgreSQL. Queries never contain "?" in literals.
+func (s *Store) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
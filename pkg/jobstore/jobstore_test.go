@@ -0,0 +1,244 @@
+package jobstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// fakeDB records the statements run through the "jobstoretest" driver and
+// answers queries with rows.
+type fakeDB struct {
+	mu       sync.Mutex
+	execs    []statement
+	queries  []statement
+	rows     [][]driver.Value
+	affected int64
+}
+
+type statement struct {
+	query string
+	args  []driver.Value
+}
+
+var fakes sync.Map // DSN -> *fakeDB
+
+func init() {
+	sql.Register("jobstoretest", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	db, _ := fakes.Load(dsn)
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, stderrors.New("not supported") }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.execs = append(c.db.execs, statement{query, values(args)})
+	return driver.RowsAffected(c.db.affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries = append(c.db.queries, statement{query, values(args)})
+	return &fakeRows{rows: c.db.rows}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vs[i] = arg.Value
+	}
+	return vs
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 1 && len(r.rows[0]) == 1 {
+		return []string{"count"} // COUNT(*) queries
+	}
+	return strings.Split(jobColumns, ", ")
+}
+
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newFakeStore returns a Store of dialect on a fake database named after the test.
+func newFakeStore(t *testing.T, dialect Dialect) (*Store, *fakeDB) {
+	fake := &fakeDB{affected: 1}
+	fakes.Store(t.Name(), fake)
+	db, err := sql.Open("jobstoretest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := New(db, dialect)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	store.now = func() time.Time { return time.UnixMilli(1700000000000) }
+	t.Cleanup(func() { store.Close() })
+	return store, fake
+}
+
+func TestParseDialect(t *testing.T) {
+	for name, want := range map[string]Dialect{"": SQLite, "SQLite": SQLite, "postgres": Postgres, "postgresql": Postgres} {
+		if got, err := ParseDialect(name); err != nil || got != want {
+			t.Errorf("ParseDialect(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseDialect("mysql"); err == nil {
+		t.Error("ParseDialect(mysql) expected an error")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for name, want := range map[string]Priority{"": PriorityNormal, "High": PriorityHigh, "low": PriorityLow, "5": 5, "-3": -3} {
+		if got, err := ParsePriority(name); err != nil || got != want {
+			t.Errorf("ParsePriority(%q) = %d, %v, want %d", name, got, err, want)
+		}
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("ParsePriority(urgent) expected an error")
+	}
+}
+
+func TestStoreStatements(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		dialect Dialect
+		insert  string
+		update  string
+	}{
+		{SQLite, "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "SET status = ?, result = ?, error = ?, updated_at = ? WHERE id = ?"},
+		{Postgres, "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)", "SET status = $1, result = $2, error = $3, updated_at = $4 WHERE id = $5"},
+	} {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			store, fake := newFakeStore(t, tc.dialect)
+			if err := store.Migrate(ctx); err != nil {
+				t.Fatalf("Migrate() error: %v", err)
+			}
+			if err := store.Create(ctx, Job{ID: "job-1", Tenant: "acme", Input: "in.mp4", Output: "out", Priority: PriorityHigh}); err != nil {
+				t.Fatalf("Create() error: %v", err)
+			}
+			jobErr := errors.New(errors.TranscodingError, "FFmpeg failed", "exit status 1", 16)
+			if err := store.Finish(ctx, "job-1", "", jobErr); err != nil {
+				t.Fatalf("Finish() error: %v", err)
+			}
+
+			if len(fake.execs) != 6 || !strings.HasPrefix(fake.execs[0].query, "CREATE TABLE IF NOT EXISTS hlspresso_jobs") {
+				t.Fatalf("Statements = %+v", fake.execs)
+			}
+			insert := fake.execs[4]
+			wantArgs := []driver.Value{"job-1", "acme", "in.mp4", "out", "{}", "queued", int64(10), "", "", "", "", int64(0), int64(1700000000000), int64(1700000000000)}
+			if !strings.HasSuffix(insert.query, tc.insert) || !reflect.DeepEqual(insert.args, wantArgs) {
+				t.Errorf("Create() ran %q with %v", insert.query, insert.args)
+			}
+			finish := fake.execs[5]
+			if !strings.HasSuffix(finish.query, tc.update) || finish.args[0] != "failed" ||
+				!strings.Contains(finish.args[2].(string), `"code":16`) {
+				t.Errorf("Finish() ran %q with %v", finish.query, finish.args)
+			}
+		})
+	}
+}
+
+func TestStoreFinishCancelled(t *testing.T) {
+	store, fake := newFakeStore(t, SQLite)
+	if err := store.Finish(context.Background(), "job-1", "", fmt.Errorf("transcoding cancelled: %w", context.Canceled)); err != nil {
+		t.Fatalf("Finish() error: %v", err)
+	}
+	if args := fake.execs[0].args; args[0] != "cancelled" || !strings.Contains(args[2].(string), "transcoding cancelled") {
+		t.Errorf("Finish() of a cancelled job ran with %v", args)
+	}
+	if !StatusCancelled.Finished() {
+		t.Error("StatusCancelled is not a terminal state")
+	}
+}
+
+func TestStoreGet(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newFakeStore(t, SQLite)
+	fake.rows = [][]driver.Value{{
+		"job-1", "acme", "in.mp4", "out", `{"OutputType":"hls"}`, "failed", int64(-10),
+		`{"status":"processing","percentage":42.5,"step":"transcoding","stage":"Creating HLS stream","timestamp":""}`,
+		"", `{"type":"TRANSCODING_ERROR","code":16,"message":"FFmpeg failed"}`, "", int64(0),
+		int64(1700000000000), int64(1700000060000),
+	}}
+
+	job, err := store.Get(ctx, "job-1")
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if job.Tenant != "acme" || job.Status != StatusFailed || job.Priority != PriorityLow || string(job.Options) != `{"OutputType":"hls"}` ||
+		job.Progress == nil || job.Progress.Percentage != 42.5 ||
+		!reflect.DeepEqual(job.Error, &progress.EventError{Type: "TRANSCODING_ERROR", Code: 16, Message: "FFmpeg failed"}) ||
+		!job.LeaseExpiresAt.IsZero() || job.UpdatedAt.Sub(job.CreatedAt) != time.Minute {
+		t.Errorf("Get() = %+v", job)
+	}
+	if q := fake.queries[0]; !strings.HasSuffix(q.query, "WHERE id = ?") || q.args[0] != "job-1" {
+		t.Errorf("Get() ran %q with %v", q.query, q.args)
+	}
+
+	fake.rows = nil
+	if _, err := store.Get(ctx, "job-2"); !stderrors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing job error = %v, want ErrNotFound", err)
+	}
+	fake.affected = 0
+	if err := store.Start(ctx, "job-2"); !stderrors.Is(err, ErrNotFound) {
+		t.Errorf("Start() of a missing job error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreUnfinishedAndList(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newFakeStore(t, Postgres)
+	if _, err := store.Unfinished(ctx); err != nil {
+		t.Fatalf("Unfinished() error: %v", err)
+	}
+	if _, err := store.List(ctx, 20); err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if _, err := store.ListTenant(ctx, "acme", 0); err != nil {
+		t.Fatalf("ListTenant() error: %v", err)
+	}
+	if q := fake.queries[0]; !strings.Contains(q.query, "WHERE status IN ($1, $2) ORDER BY created_at, id") ||
+		!reflect.DeepEqual(q.args, []driver.Value{"queued", "running"}) {
+		t.Errorf("Unfinished() ran %q with %v", q.query, q.args)
+	}
+	if q := fake.queries[1]; !strings.HasSuffix(q.query, "ORDER BY created_at DESC, id DESC LIMIT 20") {
+		t.Errorf("List() ran %q", q.query)
+	}
+	if q := fake.queries[2]; !strings.HasSuffix(q.query, "WHERE tenant = $1 ORDER BY created_at DESC, id DESC") ||
+		!reflect.DeepEqual(q.args, []driver.Value{"acme"}) {
+		t.Errorf("ListTenant() ran %q with %v", q.query, q.args)
+	}
+}
@@ -0,0 +1,304 @@
+package jobstore
+
+import (
+	"context"
+	stderrors "errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+)
+
+// Errors returned by the queue operations.
+var (
+	// ErrNoJob is returned by Claim when no job is queued.
+	ErrNoJob = stderrors.New("no queued job")
+	// ErrLeaseLost is returned by Heartbeat when the job is no longer running
+	// under the lease of the worker, e.g. because the lease expired and the job
+	// was queued again.
+	ErrLeaseLost = stderrors.New("job lease lost")
+)
+
+// claimBatch is how many queued jobs Claim tries before giving up, when other
+// workers claim them first.
+const claimBatch = 10
+
+// Claim takes the queued job of highest priority, the oldest of them, for
+// worker and marks it as running under a lease of duration lease. Claims are
+// atomic: when several workers race for a job, one of them gets it. It returns
+// ErrNoJob when no job is queued.
+func (s *Store) Claim(ctx context.Context, worker string, lease time.Duration) (Job, error) {
+	candidates, err := s.query(ctx, `SELECT `+jobColumns+` FROM hlspresso_jobs WHERE status = ? ORDER BY priority DESC, created_at, id LIMIT `+strconv.Itoa(claimBatch),
+		string(StatusQueued))
+	if err != nil {
+		return Job{}, err
+	}
+	for _, job := range candidates {
+		now := s.now()
+		expires := now.Add(lease)
+		res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE hlspresso_jobs SET status = ?, worker = ?, lease_expires_at = ?, updated_at = ? WHERE id = ? AND status = ?`),
+			string(StatusRunning), worker, expires.UnixMilli(), now.UnixMilli(), job.ID, string(StatusQueued))
+		if err != nil {
+			return Job{}, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return Job{}, err
+		} else if n == 0 {
+			// Claimed by another worker in the meantime.
+			continue
+		}
+		job.Status, job.Worker, job.LeaseExpiresAt, job.UpdatedAt = StatusRunning, worker, expires, now
+		return job, nil
+	}
+	return Job{}, ErrNoJob
+}
+
+// Heartbeat renews for lease the lease of worker on the job id, or returns
+// ErrLeaseLost if the worker no longer holds it.
+func (s *Store) Heartbeat(ctx context.Context, id, worker string, lease time.Duration) error {
+	now := s.now()
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE hlspresso_jobs SET lease_expires_at = ?, updated_at = ? WHERE id = ? AND worker = ? AND status = ?`),
+		now.Add(lease).UnixMilli(), now.UnixMilli(), id, worker, string(StatusRunning))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Recover queues again the orphaned jobs: the running jobs whose lease expired
+// because their worker stopped, returning how many were queued. Jobs started
+// with Start have no lease and are left alone.
+func (s *Store) Recover(ctx context.Context) (int64, error) {
+	now := s.now().UnixMilli()
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE hlspresso_jobs SET status = ?, worker = ?, lease_expires_at = ?, updated_at = ? WHERE status = ? AND lease_expires_at > ? AND lease_expires_at < ?`),
+		string(StatusQueued), "", int64(0), now, string(StatusRunning), int64(0), now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Requeue queues again the job id that worker runs, e.g. when a job of higher
+// priority preempts it, or returns ErrLeaseLost if the worker no longer
+// holds it.
+func (s *Store) Requeue(ctx context.Context, id, worker string) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE hlspresso_jobs SET status = ?, worker = ?, lease_expires_at = ?, updated_at = ? WHERE id = ? AND worker = ? AND status = ?`),
+		string(StatusQueued), "", int64(0), s.now().UnixMilli(), id, worker, string(StatusRunning))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// QueuedAtLeast reports whether a job of priority at least priority is queued.
+func (s *Store) QueuedAtLeast(ctx context.Context, priority Priority) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM hlspresso_jobs WHERE status = ? AND priority >= ?`),
+		string(StatusQueued), int64(priority)).Scan(&count)
+	return count > 0, err
+}
+
+// QueueDepth returns the number of queued jobs.
+func (s *Store) QueueDepth(ctx context.Context) (int, error) {
+	var depth int
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM hlspresso_jobs WHERE status = ?`), string(StatusQueued)).Scan(&depth)
+	return depth, err
+}
+
+// Default settings of a Worker.
+const (
+	DefaultLease        = 30 * time.Second
+	DefaultPollInterval = 2 * time.Second
+)
+
+// Worker runs the jobs of a Store shared by several instances, one at a time.
+// Run one Worker per encode slot; capacity grows with the instances sharing
+// the database.
+type Worker struct {
+	// Store is the shared store.
+	Store *Store
+	// ID identifies the worker, e.g. "<hostname>-<pid>-<slot>". It must be
+	// unique among the workers sharing the store.
+	ID string
+	// Run transcodes job, returning its result. Its context is canceled when
+	// the worker loses the lease of the job.
+	Run func(ctx context.Context, job Job) (string, error)
+	// Lease is how long a job stays claimed without a heartbeat, after which
+	// it is queued again for another worker. Defaults to DefaultLease. The
+	// worker renews it every third of Lease.
+	Lease time.Duration
+	// PollInterval is how long the worker waits when no job is queued.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// PreemptMargin enables preemption: when a job whose priority exceeds
+	// the priority of the running job by at least PreemptMargin is queued, the
+	// worker cancels the running job and queues it again, so that the urgent
+	// job is claimed first. The queue is checked with each lease renewal.
+	// Zero disables preemption: running jobs always complete.
+	PreemptMargin Priority
+	// Logger logs the jobs and store errors. Defaults to logger.NewLogger().
+	Logger logger.Logger
+
+	mu     sync.Mutex
+	status WorkerStatus
+}
+
+// States of a Worker, reported by Worker.Status.
+const (
+	// WorkerStarting is the state of a worker whose Serve has not run yet.
+	WorkerStarting = "starting"
+	// WorkerIdle is the state of a worker waiting for a queued job.
+	WorkerIdle = "idle"
+	// WorkerRunning is the state of a worker running a job.
+	WorkerRunning = "running"
+	// WorkerStopped is the state of a worker whose Serve returned.
+	WorkerStopped = "stopped"
+)
+
+// WorkerStatus describes what a Worker is doing.
+type WorkerStatus struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// JobID is the job the worker runs, in the WorkerRunning state.
+	JobID string `json:"job_id,omitempty"`
+	// Since is when the worker entered State.
+	Since time.Time `json:"since,omitempty"`
+}
+
+// Status returns the current status of the worker.
+func (w *Worker) Status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.ID = w.ID
+	if status.State == "" {
+		status.State = WorkerStarting
+	}
+	return status
+}
+
+// setStatus records the state of the worker and the job it runs.
+func (w *Worker) setStatus(state, jobID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status.State != state || w.status.JobID != jobID {
+		w.status = WorkerStatus{State: state, JobID: jobID, Since: time.Now()}
+	}
+}
+
+// Serve claims and runs jobs until ctx is done, then returns ctx.Err(). It
+// queues again the orphaned jobs of other workers before each claim. Store
+// errors are logged and retried after PollInterval.
+func (w *Worker) Serve(ctx context.Context) error {
+	lease, poll, log := w.Lease, w.PollInterval, w.Logger
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+	if log == nil {
+		log = logger.NewLogger()
+	}
+	defer w.setStatus(WorkerStopped, "")
+
+	for {
+		w.setStatus(WorkerIdle, "")
+		if recovered, err := w.Store.Recover(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("Failed to recover orphaned jobs", "jobstore", map[string]interface{}{"worker": w.ID, "error": err.Error()})
+		} else if recovered > 0 {
+			log.Info("Queued orphaned jobs again", "jobstore", map[string]interface{}{"worker": w.ID, "jobs": recovered})
+		}
+
+		job, err := w.Store.Claim(ctx, w.ID, lease)
+		if err == nil {
+			w.setStatus(WorkerRunning, job.ID)
+			w.runJob(ctx, job, lease, log)
+			continue
+		}
+		if !stderrors.Is(err, ErrNoJob) && ctx.Err() == nil {
+			log.Warn("Failed to claim a job", "jobstore", map[string]interface{}{"worker": w.ID, "error": err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// runJob runs job while renewing its lease, then records its outcome, unless
+// the lease was lost: the job then belongs to another worker. A preempted job
+// is queued again instead.
+func (w *Worker) runJob(ctx context.Context, job Job, lease time.Duration, log logger.Logger) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lost, preempted, stopped := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				err := w.Store.Heartbeat(jobCtx, job.ID, w.ID, lease)
+				if stderrors.Is(err, ErrLeaseLost) {
+					close(lost)
+					cancel()
+					return
+				}
+				if err != nil && jobCtx.Err() == nil {
+					log.Warn("Failed to renew the job lease", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID, "error": err.Error()})
+				}
+				if w.PreemptMargin <= 0 {
+					continue
+				}
+				urgent, err := w.Store.QueuedAtLeast(jobCtx, job.Priority+w.PreemptMargin)
+				if urgent {
+					close(preempted)
+					cancel()
+					return
+				}
+				if err != nil && jobCtx.Err() == nil {
+					log.Warn("Failed to check the queue for urgent jobs", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID, "error": err.Error()})
+				}
+			}
+		}
+	}()
+
+	log.Info("Running job", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID})
+	result, runErr := w.Run(jobCtx, job)
+	cancel()
+	<-stopped
+	select {
+	case <-lost:
+		log.Warn("Job lease lost, discarding the outcome", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID})
+		return
+	default:
+	}
+	if ctx.Err() != nil {
+		// Shutting down: the job is queued again once its lease expires.
+		return
+	}
+	select {
+	case <-preempted:
+		log.Info("Job preempted by a job of higher priority, queued again", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID})
+		if err := w.Store.Requeue(ctx, job.ID, w.ID); err != nil {
+			log.Error("Failed to queue the preempted job again", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID, "error": err.Error()})
+		}
+		return
+	default:
+	}
+	if err := w.Store.Finish(ctx, job.ID, result, runErr); err != nil {
+		log.Error("Failed to record the job outcome", "jobstore", map[string]interface{}{"worker": w.ID, "job_id": job.ID, "error": err.Error()})
+	}
+}
@@ -0,0 +1,220 @@
+package jobstore
+
+import (
+	"context"
+	"database/sql/driver"
+	stderrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queuedRow is a queued job row of the fake database.
+func queuedRow(id string) []driver.Value {
+	return []driver.Value{id, "", "in.mp4", "out", "{}", "queued", int64(0), "", "", "", "", int64(0), int64(1700000000000), int64(1700000000000)}
+}
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newFakeStore(t, Postgres)
+	fake.rows = [][]driver.Value{queuedRow("job-1")}
+
+	job, err := store.Claim(ctx, "node-a", time.Minute)
+	if err != nil {
+		t.Fatalf("Claim() error: %v", err)
+	}
+	if job.ID != "job-1" || job.Status != StatusRunning || job.Worker != "node-a" ||
+		!job.LeaseExpiresAt.Equal(time.UnixMilli(1700000060000)) {
+		t.Errorf("Claim() = %+v", job)
+	}
+	if q := fake.queries[0]; !strings.HasSuffix(q.query, "WHERE status = $1 ORDER BY priority DESC, created_at, id LIMIT 10") {
+		t.Errorf("Claim() ran %q", q.query)
+	}
+	claim := fake.execs[0]
+	wantArgs := []driver.Value{"running", "node-a", int64(1700000060000), int64(1700000000000), "job-1", "queued"}
+	if !strings.HasSuffix(claim.query, "WHERE id = $5 AND status = $6") || !reflect.DeepEqual(claim.args, wantArgs) {
+		t.Errorf("Claim() ran %q with %v", claim.query, claim.args)
+	}
+
+	// Another worker claims the job first.
+	fake.affected = 0
+	if _, err := store.Claim(ctx, "node-b", time.Minute); !stderrors.Is(err, ErrNoJob) {
+		t.Errorf("Claim() of a job claimed elsewhere error = %v, want ErrNoJob", err)
+	}
+}
+
+func TestHeartbeatAndRecover(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newFakeStore(t, SQLite)
+	if err := store.Heartbeat(ctx, "job-1", "node-a", time.Minute); err != nil {
+		t.Fatalf("Heartbeat() error: %v", err)
+	}
+	if e := fake.execs[0]; !strings.HasSuffix(e.query, "WHERE id = ? AND worker = ? AND status = ?") ||
+		!reflect.DeepEqual(e.args, []driver.Value{int64(1700000060000), int64(1700000000000), "job-1", "node-a", "running"}) {
+		t.Errorf("Heartbeat() ran %q with %v", e.query, e.args)
+	}
+	if _, err := store.Recover(ctx); err != nil {
+		t.Fatalf("Recover() error: %v", err)
+	}
+	if e := fake.execs[1]; !strings.HasSuffix(e.query, "WHERE status = ? AND lease_expires_at > ? AND lease_expires_at < ?") ||
+		e.args[0] != "queued" || e.args[6] != int64(1700000000000) {
+		t.Errorf("Recover() ran %q with %v", e.query, e.args)
+	}
+
+	fake.affected = 0
+	if err := store.Heartbeat(ctx, "job-1", "node-a", time.Minute); !stderrors.Is(err, ErrLeaseLost) {
+		t.Errorf("Heartbeat() of a lost lease error = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestQueueDepth(t *testing.T) {
+	store, fake := newFakeStore(t, Postgres)
+	fake.rows = [][]driver.Value{{int64(3)}}
+	depth, err := store.QueueDepth(context.Background())
+	if err != nil || depth != 3 {
+		t.Errorf("QueueDepth() = %d, %v, want 3", depth, err)
+	}
+	if q := fake.queries[0]; q.query != "SELECT COUNT(*) FROM hlspresso_jobs WHERE status = $1" || q.args[0] != "queued" {
+		t.Errorf("QueueDepth() ran %q with %v", q.query, q.args)
+	}
+}
+
+func TestWorkerServe(t *testing.T) {
+	store, fake := newFakeStore(t, SQLite)
+	fake.rows = [][]driver.Value{queuedRow("job-1")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan Job, 1)
+	release := make(chan struct{})
+	w := &Worker{
+		Store:        store,
+		ID:           "node-a",
+		PollInterval: time.Millisecond,
+		Logger:       &discardLogger{},
+		Run: func(ctx context.Context, job Job) (string, error) {
+			fake.mu.Lock()
+			fake.rows = nil
+			fake.mu.Unlock()
+			ran <- job
+			<-release
+			return "out/master.m3u8", nil
+		},
+	}
+	if status := w.Status(); status.ID != "node-a" || status.State != WorkerStarting {
+		t.Errorf("Status() before Serve = %+v", status)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Serve(ctx) }()
+
+	if job := <-ran; job.ID != "job-1" || job.Worker != "node-a" {
+		t.Errorf("Run() got %+v", job)
+	}
+	if status := w.Status(); status.State != WorkerRunning || status.JobID != "job-1" {
+		t.Errorf("Status() while running = %+v", status)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !finished(fake) {
+		if time.Now().After(deadline) {
+			t.Fatal("the worker did not record the outcome of the job")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Serve() = %v, want context.Canceled", err)
+	}
+	if status := w.Status(); status.State != WorkerStopped {
+		t.Errorf("Status() after Serve = %+v", status)
+	}
+}
+
+func TestWorkerPreempt(t *testing.T) {
+	store, fake := newFakeStore(t, SQLite)
+	fake.rows = [][]driver.Value{queuedRow("job-1")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan struct{})
+	w := &Worker{
+		Store:         store,
+		ID:            "node-a",
+		Lease:         30 * time.Millisecond,
+		PollInterval:  time.Millisecond,
+		PreemptMargin: PriorityHigh,
+		Logger:        &discardLogger{},
+		Run: func(ctx context.Context, job Job) (string, error) {
+			// Um job urgente entra na fila enquanto este roda
+			fake.mu.Lock()
+			fake.rows = [][]driver.Value{{int64(1)}}
+			fake.mu.Unlock()
+			<-ctx.Done()
+			fake.mu.Lock()
+			fake.rows = nil
+			fake.mu.Unlock()
+			close(stopped)
+			return "", ctx.Err()
+		},
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Serve(ctx) }()
+
+	<-stopped
+	deadline := time.Now().Add(5 * time.Second)
+	for !requeued(fake) {
+		if time.Now().After(deadline) {
+			t.Fatal("the worker did not queue the preempted job again")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, q := range fake.queries {
+		if strings.Contains(q.query, "priority >= ?") && q.args[1] != int64(PriorityHigh) {
+			t.Errorf("Preemption check ran with %v, want priority %d", q.args, PriorityHigh)
+		}
+	}
+	for _, e := range fake.execs {
+		if strings.Contains(e.query, "result = ?") {
+			t.Errorf("The preempted job was finished: %v", e.args)
+		}
+	}
+}
+
+// requeued reports whether the fake database recorded job-1 queued again by node-a.
+func requeued(fake *fakeDB) bool {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, e := range fake.execs {
+		if strings.HasSuffix(e.query, "WHERE id = ? AND worker = ? AND status = ?") && e.args[0] == "queued" &&
+			e.args[4] == "job-1" && e.args[5] == "node-a" {
+			return true
+		}
+	}
+	return false
+}
+
+// finished reports whether the fake database recorded a succeeded job.
+func finished(fake *fakeDB) bool {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, e := range fake.execs {
+		if strings.Contains(e.query, "result = ?") && e.args[0] == "succeeded" && e.args[1] == "out/master.m3u8" {
+			return true
+		}
+	}
+	return false
+}
+
+type discardLogger struct{}
+
+func (*discardLogger) Debug(string, string, map[string]interface{}) {}
+func (*discardLogger) Info(string, string, map[string]interface{})  {}
+func (*discardLogger) Warn(string, string, map[string]interface{})  {}
+func (*discardLogger) Error(string, string, map[string]interface{}) {}
+func (*discardLogger) Fatal(string, string, map[string]interface{}) {}
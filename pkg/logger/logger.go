@@ -2,6 +2,7 @@ package logger
 
 import (
 	"os"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -33,8 +34,34 @@ func Init() {
 	log.Logger = log.Output(os.Stderr)
 }
 
-// LogEvent represents the structure of a log entry, primarily used for understanding the JSON output.
-// This struct itself is not directly used for logging via the exported functions.
+// SetLevel sets the minimum level of the events logged through this package.
+// Events below level are discarded; all levels are logged until SetLevel is called.
+func SetLevel(level LogLevel) {
+	switch level {
+	case DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel:
+		zerolog.SetGlobalLevel(zerologLevel(level))
+	}
+}
+
+// zerologLevel returns the zerolog level of level.
+func zerologLevel(level LogLevel) zerolog.Level {
+	switch level {
+	case DebugLevel:
+		return zerolog.DebugLevel
+	case InfoLevel:
+		return zerolog.InfoLevel
+	case WarnLevel:
+		return zerolog.WarnLevel
+	case ErrorLevel:
+		return zerolog.ErrorLevel
+	case FatalLevel:
+		return zerolog.FatalLevel
+	}
+	return zerolog.NoLevel
+}
+
+// LogEvent represents the structure of a log entry. It documents the JSON output and is
+// the value delivered to a Sink (see SetSink and NewSinkLogger).
 type LogEvent struct {
 	// Level is the severity level of the log event (e.g., "info", "error").
 	Level LogLevel `json:"level"`
@@ -44,12 +71,18 @@ type LogEvent struct {
 	Component string `json:"component"`
 	// Data contains optional additional structured key-value data associated with the log event.
 	Data map[string]interface{} `json:"data,omitempty"`
+	// Time is when the event was logged. It is only set for events delivered to a Sink.
+	Time time.Time `json:"time"`
 }
 
 // Log is the core logging function.
 // It takes the level, message, component, and optional data, and logs it using the globally configured zerolog logger.
+// Events go to the sink set with SetSink instead, if any.
 // Use the specific level functions (Debug, Info, Warn, Error, Fatal) instead of calling Log directly.
 func Log(level LogLevel, message, component string, data map[string]interface{}) {
+	if deliver(level, message, component, data) {
+		return
+	}
 	logger := log.With().
 		Str("component", component).
 		Fields(data).
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Sink receives log events as Go values, so that applications embedding
+// HLSpresso can route them through their own logging stack.
+// It may be called from several goroutines at once.
+type Sink func(event LogEvent)
+
+var (
+	sinkMu sync.RWMutex
+	sink   Sink
+)
+
+// SetSink sends every event logged through this package, including those of
+// DefaultLogger and of the packages logging directly (progress, hls...),
+// to s instead of writing them to stderr. Events below the level set with
+// SetLevel are discarded. Fatal events still terminate the process once
+// delivered. A nil s restores the stderr output.
+func SetSink(s Sink) {
+	sinkMu.Lock()
+	sink = s
+	sinkMu.Unlock()
+}
+
+// currentSink returns the sink set with SetSink, or nil.
+func currentSink() Sink {
+	sinkMu.RLock()
+	defer sinkMu.RUnlock()
+	return sink
+}
+
+// ChannelSink returns a Sink sending the events to ch. Events are dropped
+// while ch is full, so that a slow consumer never blocks the transcoding.
+func ChannelSink(ch chan<- LogEvent) Sink {
+	return func(event LogEvent) {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// SinkLogger is a Logger delivering its events to a Sink, e.g. to capture the
+// logs of a single Transcoder created with transcoder.NewWithDeps. Unlike
+// SetSink, it leaves the logs of the other components unchanged.
+type SinkLogger struct {
+	sink Sink
+}
+
+// NewSinkLogger creates a SinkLogger delivering its events to s.
+func NewSinkLogger(s Sink) *SinkLogger {
+	return &SinkLogger{sink: s}
+}
+
+// Debug delivers a debug event to the sink.
+func (l *SinkLogger) Debug(message string, component string, data map[string]interface{}) {
+	l.log(DebugLevel, message, component, data)
+}
+
+// Info delivers an info event to the sink.
+func (l *SinkLogger) Info(message string, component string, data map[string]interface{}) {
+	l.log(InfoLevel, message, component, data)
+}
+
+// Warn delivers a warning event to the sink.
+func (l *SinkLogger) Warn(message string, component string, data map[string]interface{}) {
+	l.log(WarnLevel, message, component, data)
+}
+
+// Error delivers an error event to the sink.
+func (l *SinkLogger) Error(message string, component string, data map[string]interface{}) {
+	l.log(ErrorLevel, message, component, data)
+}
+
+// Fatal delivers a fatal event to the sink. It does not terminate the
+// process: the embedding application decides what to do.
+func (l *SinkLogger) Fatal(message string, component string, data map[string]interface{}) {
+	l.log(FatalLevel, message, component, data)
+}
+
+// log delivers the event to the sink if its level is enabled.
+func (l *SinkLogger) log(level LogLevel, message, component string, data map[string]interface{}) {
+	if enabled(level) {
+		l.sink(newEvent(level, message, component, data))
+	}
+}
+
+// deliver sends the event to the sink set with SetSink, exiting after fatal
+// events. It returns false when no sink is set.
+func deliver(level LogLevel, message, component string, data map[string]interface{}) bool {
+	s := currentSink()
+	if s == nil {
+		return false
+	}
+	if enabled(level) {
+		s(newEvent(level, message, component, data))
+	}
+	if level == FatalLevel {
+		os.Exit(1)
+	}
+	return true
+}
+
+// enabled reports whether events of level pass the level set with SetLevel.
+func enabled(level LogLevel) bool {
+	return zerologLevel(level) >= zerolog.GlobalLevel()
+}
+
+// newEvent returns the LogEvent of a log call, with a copy of data so that
+// the caller can reuse its map.
+func newEvent(level LogLevel, message, component string, data map[string]interface{}) LogEvent {
+	event := LogEvent{Level: level, Message: message, Component: component, Time: time.Now()}
+	if len(data) > 0 {
+		event.Data = make(map[string]interface{}, len(data))
+		for k, v := range data {
+			event.Data[k] = v
+		}
+	}
+	return event
+}
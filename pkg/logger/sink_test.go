@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSetSink(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	var events []LogEvent
+	SetSink(func(event LogEvent) { events = append(events, event) })
+	defer SetSink(nil)
+	SetLevel(InfoLevel)
+
+	data := map[string]interface{}{"job_id": "job-1"}
+	NewLogger().Info("Transcoding started", "transcoder", data)
+	Debug("Not delivered below the level", "transcoder", nil)
+	data["job_id"] = "reused"
+
+	if len(events) != 1 {
+		t.Fatalf("Sink received %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Level != InfoLevel || event.Message != "Transcoding started" || event.Component != "transcoder" ||
+		event.Data["job_id"] != "job-1" || event.Time.IsZero() {
+		t.Errorf("Event = %+v", event)
+	}
+}
+
+func TestSinkLogger(t *testing.T) {
+	ch := make(chan LogEvent, 1)
+	l := NewSinkLogger(ChannelSink(ch))
+	l.Warn("Disk almost full", "diskspace", nil)
+	l.Error("Dropped while the channel is full", "diskspace", nil)
+
+	if len(ch) != 1 {
+		t.Fatalf("Channel has %d events, want 1", len(ch))
+	}
+	if event := <-ch; event.Level != WarnLevel || event.Message != "Disk almost full" {
+		t.Errorf("Event = %+v", event)
+	}
+}
@@ -0,0 +1,256 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+)
+
+// Options contains settings for generating a short preview (highlight) clip.
+// The preview is built from a few scenes selected through ffmpeg scene detection
+// and concatenated into a single, silent MP4 suitable for hover previews.
+type Options struct {
+	// InputFile is the path (or URL) of the source video.
+	InputFile string
+	// OutputFile is the full path of the preview MP4 to be written.
+	OutputFile string
+	// Duration is the total length of the preview in seconds. Defaults to 6.
+	Duration float64
+	// Scenes is the number of scenes that make up the preview. Defaults to 3.
+	// Each scene lasts Duration/Scenes seconds.
+	Scenes int
+	// SceneThreshold is the ffmpeg scene change score (0.0-1.0) above which a frame
+	// is considered the start of a new scene. Defaults to 0.4.
+	SceneThreshold float64
+	// Width of the preview in pixels. The height is derived from the aspect ratio. Defaults to 480.
+	Width int
+	// FFmpegBinary allows specifying a custom path to the ffmpeg executable. Defaults to "ffmpeg".
+	FFmpegBinary string
+	// Runner launches the ffmpeg and ffprobe processes. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+	// Logger receives the log messages. Defaults to logger.NewLogger().
+	Logger logger.Logger
+}
+
+// Generator handles scene detection and preview clip creation using FFmpeg.
+// Create instances using New().
+type Generator struct {
+	options Options
+}
+
+// New creates a new preview Generator with the provided options.
+// It sets default values for options that are not specified.
+func New(options Options) *Generator {
+	if options.Duration <= 0 {
+		options.Duration = 6
+	}
+	if options.Scenes <= 0 {
+		options.Scenes = 3
+	}
+	if options.SceneThreshold <= 0 {
+		options.SceneThreshold = 0.4
+	}
+	if options.Width <= 0 {
+		options.Width = 480
+	}
+	if options.FFmpegBinary == "" {
+		options.FFmpegBinary = "ffmpeg"
+	}
+	if options.Runner == nil {
+		options.Runner = ffmpeg.ExecRunner{}
+	}
+	if options.Logger == nil {
+		options.Logger = logger.NewLogger()
+	}
+
+	return &Generator{
+		options: options,
+	}
+}
+
+// CreatePreview detects scene changes in the input, selects the scenes to use and
+// renders them into the preview MP4.
+// The context can be used to cancel the ffmpeg executions.
+// Returns the path to the generated preview file or an error if the process fails.
+func (g *Generator) CreatePreview(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(g.options.OutputFile), 0755); err != nil {
+		return "", errors.Wrap(err, errors.SystemError, "Failed to create preview output directory", 1)
+	}
+
+	duration := ffmpeg.ProbeDuration(ctx, g.options.Runner, g.options.InputFile)
+	if duration <= 0 {
+		return "", errors.New(errors.TranscodingError, "Failed to determine input duration for preview", g.options.InputFile, 2)
+	}
+
+	sceneTimes, err := g.detectScenes(ctx)
+	if err != nil {
+		// Scene detection is best effort; evenly spaced scenes are used instead.
+		g.options.Logger.Warn("Scene detection failed, using evenly spaced scenes", "preview", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	sceneLength := g.options.Duration / float64(g.options.Scenes)
+	starts := selectSceneStarts(sceneTimes, duration, g.options.Scenes, sceneLength)
+	if duration <= g.options.Duration {
+		sceneLength = duration
+	}
+
+	args := g.buildFFmpegArgs(starts, sceneLength)
+
+	g.options.Logger.Debug("Executing FFmpeg command", "preview", map[string]interface{}{
+		"command": g.options.FFmpegBinary + " " + strings.Join(args, " "),
+	})
+
+	proc, err := g.options.Runner.Start(ctx, g.options.FFmpegBinary, args...)
+	if err != nil {
+		return "", errors.Wrap(err, errors.TranscodingError, "Failed to start FFmpeg", 3)
+	}
+	var stderr bytes.Buffer
+	_, _ = io.Copy(&stderr, proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		return "", errors.New(errors.TranscodingError, "FFmpeg preview command failed", strings.TrimSpace(stderr.String()), 3).WithFFmpegOutput(stderr.String())
+	}
+
+	g.options.Logger.Info("Preview generation completed", "preview", map[string]interface{}{
+		"preview": g.options.OutputFile,
+		"scenes":  starts,
+	})
+
+	return g.options.OutputFile, nil
+}
+
+// detectScenes runs ffmpeg with the scene filter and returns the timestamps (in seconds)
+// at which a new scene starts.
+func (g *Generator) detectScenes(ctx context.Context) ([]float64, error) {
+	args := []string{
+		"-hide_banner",
+		"-i", g.options.InputFile,
+		"-an",
+		"-vf", fmt.Sprintf("select='gt(scene,%.2f)',showinfo", g.options.SceneThreshold),
+		"-f", "null",
+		"-",
+	}
+
+	proc, err := g.options.Runner.Start(ctx, g.options.FFmpegBinary, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sceneTimes []float64
+	scanner := bufio.NewScanner(proc.Stderr())
+	for scanner.Scan() {
+		if t, ok := parseSceneTime(scanner.Text()); ok {
+			sceneTimes = append(sceneTimes, t)
+		}
+	}
+
+	if err := proc.Wait(); err != nil {
+		return nil, err
+	}
+
+	return sceneTimes, nil
+}
+
+// buildFFmpegArgs constructs the ffmpeg arguments that trim each selected scene,
+// scale it and concatenate the result into the preview file.
+// This is an internal helper function.
+func (g *Generator) buildFFmpegArgs(starts []float64, sceneLength float64) []string {
+	var filter strings.Builder
+	for i, start := range starts {
+		fmt.Fprintf(&filter, "[0:v]trim=start=%.3f:duration=%.3f,setpts=PTS-STARTPTS,scale=w=%d:h=-2[s%d]; ",
+			start, sceneLength, g.options.Width, i)
+	}
+	for i := range starts {
+		fmt.Fprintf(&filter, "[s%d]", i)
+	}
+	fmt.Fprintf(&filter, "concat=n=%d:v=1:a=0[preview]", len(starts))
+
+	return []string{
+		"-i", g.options.InputFile,
+		"-filter_complex", filter.String(),
+		"-map", "[preview]",
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "28",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-y", g.options.OutputFile,
+	}
+}
+
+// sceneTimeRegex matches the presentation timestamp printed by the showinfo filter.
+var sceneTimeRegex = regexp.MustCompile(`pts_time:\s*(\d+(?:\.\d+)?)`)
+
+// parseSceneTime extracts the scene timestamp from a showinfo line.
+func parseSceneTime(line string) (float64, bool) {
+	if !strings.Contains(line, "Parsed_showinfo") {
+		return 0, false
+	}
+	matches := sceneTimeRegex.FindStringSubmatch(line)
+	if len(matches) < 2 {
+		return 0, false
+	}
+	t, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return t, true
+}
+
+// selectSceneStarts chooses the start time of each preview scene.
+// Detected scene changes are preferred: scenes that don't fit in the video or would
+// overlap the previously kept scene are dropped, and the remaining ones are sampled
+// evenly across the video. If not enough scene changes are available, the scenes are
+// spread evenly over the duration instead. Videos shorter than the preview produce a
+// single scene starting at zero.
+func selectSceneStarts(sceneTimes []float64, duration float64, count int, sceneLength float64) []float64 {
+	if duration <= sceneLength*float64(count) {
+		return []float64{0}
+	}
+
+	sorted := append([]float64(nil), sceneTimes...)
+	sort.Float64s(sorted)
+
+	var candidates []float64
+	for _, t := range sorted {
+		if t < 0 || t+sceneLength > duration {
+			continue
+		}
+		if len(candidates) > 0 && t < candidates[len(candidates)-1]+sceneLength {
+			continue
+		}
+		candidates = append(candidates, t)
+	}
+
+	starts := make([]float64, 0, count)
+	if len(candidates) >= count {
+		for i := 0; i < count; i++ {
+			starts = append(starts, candidates[i*len(candidates)/count])
+		}
+		return starts
+	}
+
+	step := duration / float64(count+1)
+	for i := 1; i <= count; i++ {
+		start := step*float64(i) - sceneLength/2
+		if start < 0 {
+			start = 0
+		}
+		starts = append(starts, start)
+	}
+	return starts
+}
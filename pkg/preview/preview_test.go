@@ -0,0 +1,97 @@
+package preview
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewGeneratorDefaults(t *testing.T) {
+	g := New(Options{})
+
+	if g.options.Duration != 6 {
+		t.Errorf("Default Duration: got %v, want 6", g.options.Duration)
+	}
+	if g.options.Scenes != 3 {
+		t.Errorf("Default Scenes: got %d, want 3", g.options.Scenes)
+	}
+	if g.options.SceneThreshold != 0.4 {
+		t.Errorf("Default SceneThreshold: got %v, want 0.4", g.options.SceneThreshold)
+	}
+	if g.options.Width != 480 {
+		t.Errorf("Default Width: got %d, want 480", g.options.Width)
+	}
+	if g.options.FFmpegBinary != "ffmpeg" {
+		t.Errorf("Default FFmpegBinary: got %q, want \"ffmpeg\"", g.options.FFmpegBinary)
+	}
+}
+
+func TestParseSceneTime(t *testing.T) {
+	line := "[Parsed_showinfo_1 @ 0x55d] n:   0 pts:  51200 pts_time:4.16667 duration:512"
+	got, ok := parseSceneTime(line)
+	if !ok || got != 4.16667 {
+		t.Errorf("parseSceneTime() = %v, %v; want 4.16667, true", got, ok)
+	}
+
+	if _, ok := parseSceneTime("frame=  100 fps=50 time=00:00:04.00"); ok {
+		t.Error("parseSceneTime() should ignore lines not produced by showinfo")
+	}
+}
+
+func TestSelectSceneStarts(t *testing.T) {
+	tests := []struct {
+		name       string
+		sceneTimes []float64
+		duration   float64
+		want       []float64
+	}{
+		{
+			name:       "Enough scenes",
+			sceneTimes: []float64{5, 12, 30, 45, 50},
+			duration:   60,
+			want:       []float64{5, 12, 45},
+		},
+		{
+			name:       "Overlapping and out of range scenes are dropped",
+			sceneTimes: []float64{10, 10.5, 11, 59, 30},
+			duration:   60,
+			want:       []float64{14, 29, 44},
+		},
+		{
+			name:     "No scenes falls back to evenly spaced",
+			duration: 40,
+			want:     []float64{9, 19, 29},
+		},
+		{
+			name:       "Short video",
+			sceneTimes: []float64{1, 2},
+			duration:   5,
+			want:       []float64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectSceneStarts(tt.sceneTimes, tt.duration, 3, 2)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectSceneStarts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildFFmpegArgs(t *testing.T) {
+	g := New(Options{InputFile: "input.mp4", OutputFile: "out/preview.mp4"})
+	args := g.buildFFmpegArgs([]float64{1, 10}, 2)
+	argsStr := strings.Join(args, " ")
+
+	expectedFilter := "[0:v]trim=start=1.000:duration=2.000,setpts=PTS-STARTPTS,scale=w=480:h=-2[s0]; " +
+		"[0:v]trim=start=10.000:duration=2.000,setpts=PTS-STARTPTS,scale=w=480:h=-2[s1]; " +
+		"[s0][s1]concat=n=2:v=1:a=0[preview]"
+	if !strings.Contains(argsStr, expectedFilter) {
+		t.Errorf("Filter graph mismatch:\nGot:  %s\nWant: %s", argsStr, expectedFilter)
+	}
+	if args[len(args)-1] != "out/preview.mp4" {
+		t.Errorf("Last argument should be the output file, got %q", args[len(args)-1])
+	}
+}
@@ -0,0 +1,142 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// endpointShutdownTimeout bounds how long closing the endpoint waits for open requests.
+const endpointShutdownTimeout = time.Second
+
+// endpoint serves the latest ProgressEvent and a stream of events over HTTP on
+// a Unix socket or TCP listener:
+//
+//	GET /progress  the latest event as JSON
+//	GET /events    a Server-Sent Events stream, starting with the latest event
+//	               and ending when the endpoint is closed
+type endpoint struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu          sync.Mutex
+	latest      ProgressEvent
+	subscribers map[chan ProgressEvent]struct{}
+	closed      chan struct{}
+}
+
+// newEndpoint listens on network ("unix" or "tcp") and address and starts serving.
+func newEndpoint(network, address string, initial ProgressEvent) (*endpoint, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &endpoint{
+		listener:    listener,
+		latest:      initial,
+		subscribers: map[chan ProgressEvent]struct{}{},
+		closed:      make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/progress", e.handleProgress)
+	mux.HandleFunc("/events", e.handleEvents)
+	e.server = &http.Server{Handler: mux}
+
+	go e.server.Serve(listener)
+	return e, nil
+}
+
+// addr returns the listening address, e.g. "127.0.0.1:9099" or the socket path.
+func (e *endpoint) addr() string {
+	return e.listener.Addr().String()
+}
+
+// publish records event as the latest one and sends it to the event streams.
+// Slow subscribers miss intermediate events rather than blocking the reporter.
+func (e *endpoint) publish(event ProgressEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.latest = event
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// close ends the event streams and stops the server, removing Unix sockets.
+func (e *endpoint) close() {
+	e.mu.Lock()
+	select {
+	case <-e.closed:
+		e.mu.Unlock()
+		return
+	default:
+		close(e.closed)
+	}
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), endpointShutdownTimeout)
+	defer cancel()
+	e.server.Shutdown(ctx)
+}
+
+func (e *endpoint) handleProgress(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	latest := e.latest
+	e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(latest)
+}
+
+func (e *endpoint) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan ProgressEvent, 16)
+	e.mu.Lock()
+	latest := e.latest
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.subscribers, ch)
+		e.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	event := latest
+	for {
+		data, _ := json.Marshal(event)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case event = <-ch:
+		case <-e.closed:
+			// Deliver the events sent just before close
+			select {
+			case event = <-ch:
+				continue
+			default:
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unixClient returns an HTTP client dialing the Unix socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func getEvent(t *testing.T, client *http.Client, url string) ProgressEvent {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s unexpected error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var event ProgressEvent
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
+		t.Fatalf("Failed to decode progress event: %v", err)
+	}
+	return event
+}
+
+func TestReporterWithProgressEndpointTCP(t *testing.T) {
+	reporter := NewReporter(WithProgressEndpoint("tcp", "127.0.0.1:0"))
+	addr := reporter.EndpointAddr()
+	if addr == "" {
+		t.Fatal("EndpointAddr() is empty, endpoint did not start")
+	}
+	url := "http://" + addr + "/progress"
+
+	if event := getEvent(t, http.DefaultClient, url); event.Status != "initialized" {
+		t.Errorf("Status = %q, want %q", event.Status, "initialized")
+	}
+
+	reporter.Start(100)
+	reporter.Update(40, "transcoding", "Creating HLS stream")
+	event := getEvent(t, http.DefaultClient, url)
+	if event.Status != "processing" || event.Percentage != 40 || event.Step != "transcoding" {
+		t.Errorf("Latest event = %+v, want processing at 40%% in transcoding", event)
+	}
+
+	reporter.Complete()
+	if event := getEvent(t, http.DefaultClient, url); event.Status != "completed" {
+		t.Errorf("Status = %q, want %q", event.Status, "completed")
+	}
+
+	reporter.Close()
+	if reporter.EndpointAddr() != "" {
+		t.Error("EndpointAddr() should be empty after Close()")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("Endpoint should stop listening after Close()")
+	}
+}
+
+func TestReporterWithProgressEndpointEvents(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "progress.sock")
+	reporter := NewReporter(WithProgressEndpoint("unix", socket))
+	if reporter.EndpointAddr() != socket {
+		t.Fatalf("EndpointAddr() = %q, want %q", reporter.EndpointAddr(), socket)
+	}
+	reporter.Start(10)
+
+	resp, err := unixClient(socket).Get("http://progress/events")
+	if err != nil {
+		t.Fatalf("GET /events unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// The stream starts with the latest event; read it before producing more.
+	events := make(chan ProgressEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var event ProgressEvent
+			if json.Unmarshal([]byte(data), &event) == nil {
+				events <- event
+			}
+		}
+	}()
+	if first := <-events; first.Status != "started" {
+		t.Errorf("First streamed status = %q, want %q", first.Status, "started")
+	}
+
+	reporter.Update(5, "transcoding", "halfway")
+	reporter.Complete()
+	reporter.Close()
+
+	var statuses []string
+	for event := range events {
+		statuses = append(statuses, event.Status)
+	}
+	if strings.Join(statuses, ",") != "processing,completed" {
+		t.Errorf("Streamed statuses = %v, want [processing completed]", statuses)
+	}
+}
+
+func TestReporterWithProgressEndpointListenFailure(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "missing", "progress.sock")
+	reporter := NewReporter(WithProgressEndpoint("unix", socket))
+	if reporter.EndpointAddr() != "" {
+		t.Error("EndpointAddr() should be empty when listening fails")
+	}
+	// Reporting still works without the endpoint
+	reporter.Start(1)
+	reporter.Complete()
+}
@@ -0,0 +1,282 @@
+package progress
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MultiReporter is a Reporter that fans every call out to several reporters, so
+// sinks such as the console bar, a progress file and custom Reporter
+// implementations can be combined for a single operation.
+//
+// It tracks the overall progress itself: Updates emits one event per call
+// (not one per wrapped reporter) and JSON returns the latest of those.
+type MultiReporter struct {
+	reporters []Reporter
+	updatesCh chan ProgressEvent
+	total     int64
+	current   int64
+	event     ProgressEvent
+	completed bool
+	failed    bool
+	closed    bool
+	mu        sync.Mutex
+}
+
+// NewMultiReporter creates a MultiReporter forwarding to reporters, in order.
+// Nil reporters are ignored.
+func NewMultiReporter(reporters ...Reporter) *MultiReporter {
+	m := &MultiReporter{
+		event: ProgressEvent{
+			Status:    "initialized",
+			Timestamp: time.Now().Format(time.RFC3339),
+		},
+		updatesCh: make(chan ProgressEvent, DefaultBufferSize),
+	}
+	for _, r := range reporters {
+		if r != nil {
+			m.reporters = append(m.reporters, r)
+		}
+	}
+	return m
+}
+
+// Start starts every wrapped reporter with total.
+func (m *MultiReporter) Start(total int64) {
+	m.mu.Lock()
+	if m.failed {
+		m.mu.Unlock()
+		return
+	}
+	m.total = total
+	m.current = 0
+	m.event.Status = "started"
+	m.event.Percentage = 0
+	rates{}.apply(&m.event)
+	m.event.Timestamp = time.Now().Format(time.RFC3339)
+	m.sendInternal()
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		r.Start(total)
+	}
+}
+
+// ReportEvent sends the lifecycle event to the Updates channel and forwards it
+// to every wrapped reporter that implements EventReporter.
+func (m *MultiReporter) ReportEvent(name string, data map[string]interface{}) {
+	m.mu.Lock()
+	if m.failed {
+		m.mu.Unlock()
+		return
+	}
+	event := m.event
+	event.Event = name
+	event.Data = data
+	event.Timestamp = time.Now().Format(time.RFC3339)
+	if !m.closed {
+		sendDropOldest(m.updatesCh, event)
+	}
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		ReportEvent(r, name, data)
+	}
+}
+
+// SetJobID sets the JobID of the events of the MultiReporter and of every
+// wrapped reporter that implements JobReporter.
+func (m *MultiReporter) SetJobID(jobID string) {
+	m.mu.Lock()
+	m.event.JobID = jobID
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		SetJobID(r, jobID)
+	}
+}
+
+// Update forwards the update to every wrapped reporter.
+func (m *MultiReporter) Update(current int64, step, stage string) {
+	m.mu.Lock()
+	m.setInternal(current, step, stage, rates{})
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		r.Update(current, step, stage)
+	}
+}
+
+// UpdateBytes forwards the byte progress to every wrapped reporter, as an
+// Update for those that do not implement ByteReporter.
+func (m *MultiReporter) UpdateBytes(current, total int64, bytesPerSecond float64, step, stage string) {
+	m.mu.Lock()
+	m.setInternal(current, step, stage, rates{currentBytes: current, totalBytes: total, bytesPerSecond: bytesPerSecond})
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		UpdateBytes(r, current, total, bytesPerSecond, step, stage)
+	}
+}
+
+// UpdateSpeed forwards the encoding speed to every wrapped reporter, as an
+// Update for those that do not implement SpeedReporter.
+func (m *MultiReporter) UpdateSpeed(current int64, speed, etaSeconds float64, step, stage string) {
+	m.mu.Lock()
+	m.setInternal(current, step, stage, rates{speed: speed, etaSeconds: etaSeconds})
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		UpdateSpeed(r, current, speed, etaSeconds, step, stage)
+	}
+}
+
+// Increment forwards the increment to every wrapped reporter.
+func (m *MultiReporter) Increment(step, stage string) {
+	m.mu.Lock()
+	m.setInternal(m.current+1, step, stage, rates{})
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		r.Increment(step, stage)
+	}
+}
+
+// Complete completes every wrapped reporter. The first call sends the final
+// event and closes the Updates channel.
+func (m *MultiReporter) Complete() {
+	m.mu.Lock()
+	if !m.completed && !m.failed {
+		m.current = m.total
+		if m.event.TotalBytes > 0 {
+			m.event.CurrentBytes = m.event.TotalBytes
+		}
+		m.event.ETASeconds = 0
+		m.event.Percentage = 100
+		m.event.Status = "completed"
+		m.event.Timestamp = time.Now().Format(time.RFC3339)
+		m.sendInternal()
+		m.closeInternal()
+		m.completed = true
+	}
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		r.Complete()
+	}
+}
+
+// Fail fails every wrapped reporter with err and sends a final "failed" event.
+// Calls after the first are ignored.
+func (m *MultiReporter) Fail(err error) {
+	m.finish("failed", err, Reporter.Fail)
+}
+
+// Cancel marks the combined operation as cancelled and cancels every wrapped
+// reporter, falling back to Fail for those that are not CancelReporters.
+func (m *MultiReporter) Cancel(err error) {
+	m.finish("cancelled", err, Cancel)
+}
+
+// finish sends a final event with status and the details of err, then ends
+// every wrapped reporter with end.
+func (m *MultiReporter) finish(status string, err error, end func(Reporter, error)) {
+	m.mu.Lock()
+	if m.failed {
+		m.mu.Unlock()
+		return
+	}
+	if err == nil {
+		err = stderrors.New("unknown error")
+	}
+	m.event.Status = status
+	m.event.Error = NewEventError(err)
+	m.event.Timestamp = time.Now().Format(time.RFC3339)
+	m.sendInternal()
+	m.closeInternal()
+	m.failed = true
+	m.mu.Unlock()
+
+	for _, r := range m.reporters {
+		end(r, err)
+	}
+}
+
+// Close closes every wrapped reporter and the Updates channel, returning the
+// errors of the wrapped reporters joined together.
+func (m *MultiReporter) Close() error {
+	m.mu.Lock()
+	m.closeInternal()
+	m.mu.Unlock()
+
+	var errs []error
+	for _, r := range m.reporters {
+		if err := r.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// Updates returns the channel of combined ProgressEvent updates.
+// Events of the wrapped reporters remain available on their own channels.
+func (m *MultiReporter) Updates() <-chan ProgressEvent {
+	return m.updatesCh
+}
+
+// JSON returns the latest combined progress event as a JSON string.
+// Deprecated: Use the Updates() channel for receiving events instead.
+func (m *MultiReporter) JSON() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := json.Marshal(m.event)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal progress event: %w", err)
+	}
+	return string(data), nil
+}
+
+// setInternal records the progress and rates and sends the resulting event.
+// Requires lock to be held by caller.
+func (m *MultiReporter) setInternal(current int64, step, stage string, rt rates) {
+	if m.completed || m.failed {
+		return
+	}
+	if m.total > 0 && current > m.total {
+		current = m.total
+	}
+	m.current = current
+
+	percentage := 0.0
+	if m.total > 0 {
+		percentage = float64(current) / float64(m.total) * 100
+	}
+	m.event.Percentage = percentage
+	m.event.Step = step
+	m.event.Stage = stage
+	m.event.Status = "processing"
+	rt.apply(&m.event)
+	m.event.Timestamp = time.Now().Format(time.RFC3339)
+	m.sendInternal()
+}
+
+// sendInternal sends the current event without blocking when nobody listens.
+// Requires lock to be held by caller.
+func (m *MultiReporter) sendInternal() {
+	if m.closed {
+		return
+	}
+	sendDropOldest(m.updatesCh, m.event)
+}
+
+// closeInternal closes the updates channel once.
+// Requires lock to be held by caller.
+func (m *MultiReporter) closeInternal() {
+	if !m.closed {
+		close(m.updatesCh)
+		m.closed = true
+	}
+}
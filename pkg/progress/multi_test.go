@@ -0,0 +1,153 @@
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordingReporter records the calls it receives.
+type recordingReporter struct {
+	calls     []string
+	updatesCh chan ProgressEvent
+}
+
+func newRecordingReporter() *recordingReporter {
+	return &recordingReporter{updatesCh: make(chan ProgressEvent)}
+}
+
+func (r *recordingReporter) Start(total int64) { r.calls = append(r.calls, "start") }
+func (r *recordingReporter) Update(current int64, step, stage string) {
+	r.calls = append(r.calls, "update "+step)
+}
+func (r *recordingReporter) Increment(step, stage string) {
+	r.calls = append(r.calls, "increment "+step)
+}
+func (r *recordingReporter) Complete()                     { r.calls = append(r.calls, "complete") }
+func (r *recordingReporter) Fail(err error)                { r.calls = append(r.calls, "fail "+err.Error()) }
+func (r *recordingReporter) Close() error                  { r.calls = append(r.calls, "close"); return nil }
+func (r *recordingReporter) Updates() <-chan ProgressEvent { return r.updatesCh }
+func (r *recordingReporter) JSON() (string, error)         { return "{}", nil }
+
+func TestMultiReporterFansOut(t *testing.T) {
+	first, second := newRecordingReporter(), newRecordingReporter()
+	multi := NewMultiReporter(first, nil, second)
+
+	multi.Start(10)
+	multi.Update(4, "downloading", "Downloading file")
+	multi.Increment("transcoding", "Creating HLS stream")
+	multi.Complete()
+
+	want := []string{"start", "update downloading", "increment transcoding", "complete"}
+	for i, r := range []*recordingReporter{first, second} {
+		if !reflect.DeepEqual(r.calls, want) {
+			t.Errorf("reporter %d calls = %v, want %v", i, r.calls, want)
+		}
+	}
+}
+
+func TestMultiReporterUpdates(t *testing.T) {
+	multi := NewMultiReporter(newRecordingReporter())
+
+	multi.Start(10)
+	multi.Update(4, "downloading", "Downloading file")
+	multi.Increment("downloading", "Downloading file")
+	multi.Complete()
+
+	var got []ProgressEvent
+	for event := range multi.Updates() {
+		got = append(got, event)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Received %d events, want 4: %+v", len(got), got)
+	}
+	if got[1].Percentage != 40 || got[2].Percentage != 50 || got[2].Step != "downloading" {
+		t.Errorf("Unexpected processing events: %+v", got[1:3])
+	}
+	if got[3].Status != "completed" || got[3].Percentage != 100 {
+		t.Errorf("Final event = %+v, want completed at 100%%", got[3])
+	}
+
+	jsonStr, err := multi.JSON()
+	if err != nil {
+		t.Fatalf("JSON() failed: %v", err)
+	}
+	var parsed ProgressEvent
+	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil || parsed.Status != "completed" {
+		t.Errorf("JSON() = %s, want the completed event", jsonStr)
+	}
+}
+
+func TestMultiReporterWithDefaultReporter(t *testing.T) {
+	progressFile := filepath.Join(t.TempDir(), "progress.txt")
+	fileReporter := NewReporter(WithProgressFile(progressFile))
+	multi := NewMultiReporter(fileReporter, newRecordingReporter())
+
+	multi.Start(200)
+	multi.Update(50, "transcoding", "Creating HLS stream")
+
+	content, err := os.ReadFile(progressFile)
+	if err != nil {
+		t.Fatalf("Failed to read progress file: %v", err)
+	}
+	if string(content) != "25.00" {
+		t.Errorf("Progress file = %q, want %q", content, "25.00")
+	}
+
+	multi.Complete()
+	if fileReporter.Event.Status != "completed" {
+		t.Errorf("Wrapped reporter status = %q, want %q", fileReporter.Event.Status, "completed")
+	}
+}
+
+func TestMultiReporterFailAndClose(t *testing.T) {
+	wrapped := newRecordingReporter()
+	multi := NewMultiReporter(wrapped)
+
+	multi.Start(10)
+	multi.Fail(errors.New("disk full"))
+	multi.Fail(errors.New("ignored"))
+	if err := multi.Close(); err != nil {
+		t.Errorf("Close() unexpected error: %v", err)
+	}
+
+	want := []string{"start", "fail disk full", "close"}
+	if !reflect.DeepEqual(wrapped.calls, want) {
+		t.Errorf("calls = %v, want %v", wrapped.calls, want)
+	}
+
+	var last ProgressEvent
+	for event := range multi.Updates() {
+		last = event
+	}
+	if last.Status != "failed" || last.Error == nil || last.Error.Message != "disk full" {
+		t.Errorf("Final event = %+v, want failed with the error message", last)
+	}
+}
+
+func TestMultiReporterCancel(t *testing.T) {
+	recording, defaultReporter := newRecordingReporter(), NewReporter(WithBarWriter(io.Discard))
+	multi := NewMultiReporter(recording, defaultReporter)
+
+	multi.Start(10)
+	multi.Cancel(errors.New("cancelled by user"))
+
+	// Reporters that cannot report cancellations fail instead
+	if want := []string{"start", "fail cancelled by user"}; !reflect.DeepEqual(recording.calls, want) {
+		t.Errorf("calls = %v, want %v", recording.calls, want)
+	}
+	if status := defaultReporter.Snapshot().Status; status != "cancelled" {
+		t.Errorf("Wrapped reporter status = %q, want cancelled", status)
+	}
+	var last ProgressEvent
+	for event := range multi.Updates() {
+		last = event
+	}
+	if last.Status != "cancelled" || last.Error.Message != "cancelled by user" {
+		t.Errorf("Final event = %+v, want cancelled with the error message", last)
+	}
+}
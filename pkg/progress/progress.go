@@ -2,18 +2,22 @@ package progress
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/heyjunin/HLSpresso/pkg/errors"
 	"github.com/heyjunin/HLSpresso/pkg/logger"
 	"github.com/schollz/progressbar/v3"
 )
 
 // ProgressEvent represents a single progress update event, often serialized to JSON.
 type ProgressEvent struct {
-	// Status indicates the current overall status (e.g., "initialized", "started", "processing", "completed").
+	// Status indicates the current overall status (e.g., "initialized", "started", "processing", "completed", "failed", "cancelled").
 	Status string `json:"status"`
 	// Percentage represents the progress completion from 0.0 to 100.0.
 	Percentage float64 `json:"percentage"`
@@ -23,6 +27,75 @@ type ProgressEvent struct {
 	Stage string `json:"stage"`
 	// Timestamp marks when the event occurred in RFC3339 format.
 	Timestamp string `json:"timestamp"`
+	// CurrentBytes is the number of bytes transferred so far, for byte-based steps such as downloads.
+	CurrentBytes int64 `json:"current_bytes,omitempty"`
+	// TotalBytes is the total number of bytes to transfer, for byte-based steps.
+	TotalBytes int64 `json:"total_bytes,omitempty"`
+	// BytesPerSecond is the average transfer speed of the current byte-based step.
+	BytesPerSecond float64 `json:"bytes_per_second,omitempty"`
+	// Speed is the encoding speed relative to realtime (e.g. 2.5 for 2.5x), for
+	// time-based steps such as MP4 encodes.
+	Speed float64 `json:"speed,omitempty"`
+	// ETASeconds is the estimated number of seconds left in the current step,
+	// derived from its speed.
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+	// Error describes the failure of a "failed" event.
+	Error *EventError `json:"error,omitempty"`
+	// JobID identifies the job the event belongs to, when set with SetJobID.
+	JobID string `json:"job_id,omitempty"`
+	// Event names the lifecycle event (e.g. EventDownloadCompleted) of events
+	// reported with ReportEvent; empty for progress updates.
+	Event string `json:"event,omitempty"`
+	// Data holds the metadata of a lifecycle event, e.g. the byte count and
+	// duration of a download or the ffmpeg command of an encode.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Lifecycle events reported by the transcoder with ReportEvent.
+const (
+	// EventInputValidated is reported once the input exists and has a
+	// supported format, or once the URL of a streamed input is reachable.
+	EventInputValidated = "input_validated"
+	// EventInputProbed is reported once the input is probed, with its
+	// duration, resolution and codecs.
+	EventInputProbed = "input_probed"
+	// EventDownloadStarted is reported when the download of a remote input starts.
+	EventDownloadStarted = "download_started"
+	// EventDownloadCompleted is reported once a remote input is downloaded.
+	EventDownloadCompleted = "download_completed"
+	// EventEncodeStarted is reported when the encoder starts.
+	EventEncodeStarted = "encode_started"
+	// EventProgressEstimated is reported when the encoding starts, with the
+	// basis of its progress: "frames" when the frames of the input could be
+	// counted, "duration" when the progress is estimated from the encoded
+	// time, or "none" when no percentage can be reported.
+	EventProgressEstimated = "progress_estimated"
+	// EventEncodeCompleted is reported once the output is encoded or restored
+	// from the cache.
+	EventEncodeCompleted = "encode_completed"
+	// EventUploadStarted is reported when the upload of the output starts.
+	EventUploadStarted = "upload_started"
+	// EventUploadCompleted is reported once the output is uploaded.
+	EventUploadCompleted = "upload_completed"
+)
+
+// EventError describes the error that ended an operation in a "failed" ProgressEvent.
+// Type, Code, Details and FFmpegOutput are set when the error is (or wraps) an errors.StructuredError.
+type EventError struct {
+	Type         string `json:"type,omitempty"`
+	Code         int    `json:"code,omitempty"`
+	Message      string `json:"message"`
+	Details      string `json:"details,omitempty"`
+	FFmpegOutput string `json:"ffmpeg_output,omitempty"`
+}
+
+// NewEventError describes err for a "failed" ProgressEvent.
+func NewEventError(err error) *EventError {
+	var se *errors.StructuredError
+	if stderrors.As(err, &se) {
+		return &EventError{Type: string(se.Type), Code: se.Code, Message: se.Message, Details: se.Details, FFmpegOutput: se.FFmpegOutput}
+	}
+	return &EventError{Message: err.Error()}
 }
 
 // Reporter defines the interface for reporting progress during long-running operations
@@ -39,36 +112,159 @@ type Reporter interface {
 	Increment(step, stage string)
 	// Complete marks the operation as finished.
 	Complete()
+	// Fail marks the operation as failed, emitting a terminal "failed" ProgressEvent
+	// describing err. Progress reported afterwards is ignored.
+	Fail(err error)
+	// Close releases the resources held by the reporter and closes the Updates channel.
+	// It is safe to call Close after Complete or Fail, and more than once.
+	Close() error
 	// Updates returns a channel that emits ProgressEvent updates.
 	// Consumers can listen on this channel to receive progress information.
-	// The channel will be closed when the reporter is closed or the operation completes.
+	// The channel will be closed when the reporter is closed, fails or the operation completes.
 	Updates() <-chan ProgressEvent
 	// JSON returns the latest ProgressEvent as a JSON string.
 	// Deprecated: Use the Updates() channel for receiving events.
 	JSON() (string, error)
 }
 
+// ByteReporter is implemented by reporters that can report byte counts and transfer
+// speed in addition to the progress, e.g. "512MB / 2.1GB at 40MB/s" for downloads.
+type ByteReporter interface {
+	// UpdateBytes sets the current progress to current bytes out of total, transferred
+	// at bytesPerSecond, with descriptions of the current step and stage.
+	UpdateBytes(current, total int64, bytesPerSecond float64, step, stage string)
+}
+
+// UpdateBytes reports byte progress to r, using UpdateBytes if r is a ByteReporter
+// and falling back to Update otherwise.
+func UpdateBytes(r Reporter, current, total int64, bytesPerSecond float64, step, stage string) {
+	if br, ok := r.(ByteReporter); ok {
+		br.UpdateBytes(current, total, bytesPerSecond, step, stage)
+		return
+	}
+	r.Update(current, step, stage)
+}
+
+// SpeedReporter is implemented by reporters that can report the speed and
+// estimated time left of an encode in addition to the progress, e.g.
+// "2.5x, 40s left".
+type SpeedReporter interface {
+	// UpdateSpeed sets the current progress to current, encoded at speed
+	// times realtime with etaSeconds left, with descriptions of the current
+	// step and stage.
+	UpdateSpeed(current int64, speed, etaSeconds float64, step, stage string)
+}
+
+// UpdateSpeed reports the encoding progress to r, using UpdateSpeed if r is a
+// SpeedReporter and falling back to Update otherwise.
+func UpdateSpeed(r Reporter, current int64, speed, etaSeconds float64, step, stage string) {
+	if sr, ok := r.(SpeedReporter); ok {
+		sr.UpdateSpeed(current, speed, etaSeconds, step, stage)
+		return
+	}
+	r.Update(current, step, stage)
+}
+
+// EventReporter is implemented by reporters that can report lifecycle events
+// in addition to the progress, so that integrators can follow the stages of
+// an operation without inferring them from percentages.
+type EventReporter interface {
+	// ReportEvent reports the lifecycle event name (e.g. EventEncodeStarted)
+	// with its metadata. It does not change the progress.
+	ReportEvent(name string, data map[string]interface{})
+}
+
+// ReportEvent reports a lifecycle event to r if r is an EventReporter.
+func ReportEvent(r Reporter, name string, data map[string]interface{}) {
+	if er, ok := r.(EventReporter); ok {
+		er.ReportEvent(name, data)
+	}
+}
+
+// CancelReporter is implemented by reporters that tell cancelled operations
+// from failed ones.
+type CancelReporter interface {
+	// Cancel marks the operation as cancelled by err, like Fail but with the
+	// "cancelled" status.
+	Cancel(err error)
+}
+
+// Cancel reports to r that the operation was cancelled by err, using Cancel if
+// r is a CancelReporter and falling back to Fail otherwise.
+func Cancel(r Reporter, err error) {
+	if cr, ok := r.(CancelReporter); ok {
+		cr.Cancel(err)
+		return
+	}
+	r.Fail(err)
+}
+
+// JobReporter is implemented by reporters that can tag their events with the
+// identifier of the job they report, so that events of concurrent jobs can be
+// correlated.
+type JobReporter interface {
+	// SetJobID sets the JobID of the events reported from now on.
+	SetJobID(jobID string)
+}
+
+// SetJobID sets the job identifier of r's events if r is a JobReporter.
+func SetJobID(r Reporter, jobID string) {
+	if jr, ok := r.(JobReporter); ok {
+		jr.SetJobID(jobID)
+	}
+}
+
+// Defaults of the DefaultReporter options.
+const (
+	// DefaultThrottle is the default minimum interval between updates sent to
+	// the Updates channel.
+	DefaultThrottle = 100 * time.Millisecond
+	// DefaultBufferSize is the default number of events buffered by the
+	// Updates channel.
+	DefaultBufferSize = 10
+)
+
 // reporterOptions holds configuration for the DefaultReporter.
 type reporterOptions struct {
 	throttle           time.Duration
-	progressFilePath   string // New option: path to the progress file
-	progressFileFormat string // New option: "text" or "json" (default: "text")
-	description        string // Option for progress bar description
-	showBytes          bool   // Option to show bytes in progress bar
+	bufferSize         int       // Capacity of the updates channel
+	progressFilePath   string    // New option: path to the progress file
+	progressFileFormat string    // New option: "text" or "json" (default: "text")
+	description        string    // Option for progress bar description
+	barWriter          io.Writer // Destination of the console progress bar
+	showBytes          bool      // Option to show bytes in progress bar
+	endpointNetwork    string    // "unix" or "tcp" for the progress endpoint
+	endpointAddress    string    // Socket path or host:port for the progress endpoint
+	granularity        float64   // Percentage step between progress file and endpoint writes
+	eventWriter        io.Writer // Destination of the events as JSON Lines
 }
 
 // ReporterOption is a function type used to configure a DefaultReporter.
 type ReporterOption func(*reporterOptions)
 
 // WithThrottle sets the minimum time interval between progress updates sent to the Updates channel.
-// This helps prevent flooding listeners with too many events.
-// Defaults to 0 (no throttling) if not set.
+// This helps prevent flooding listeners with too many events. Start, Complete, Fail,
+// Cancel and lifecycle events are never throttled.
+// Defaults to DefaultThrottle; 0 disables throttling.
 func WithThrottle(duration time.Duration) ReporterOption {
 	return func(opts *reporterOptions) {
 		opts.throttle = duration
 	}
 }
 
+// WithBufferSize sets the number of events buffered by the Updates channel.
+// When the buffer is full, the oldest buffered event is dropped to make room
+// for the new one, so a slow consumer never blocks the reporter and always
+// receives the latest state and the final event.
+// Defaults to DefaultBufferSize; values below 1 keep the default.
+func WithBufferSize(size int) ReporterOption {
+	return func(opts *reporterOptions) {
+		if size >= 1 {
+			opts.bufferSize = size
+		}
+	}
+}
+
 // WithProgressFile sets the file path where the current progress should be written.
 // The format is controlled by WithProgressFileFormat (defaults to "text").
 // The file is created or truncated on Start and overwritten on each Update and Complete.
@@ -105,6 +301,14 @@ func WithDescription(desc string) ReporterOption {
 	}
 }
 
+// WithBarWriter sets where the console progress bar is drawn. Defaults to os.Stderr;
+// use io.Discard to hide the bar while keeping the other progress sinks.
+func WithBarWriter(w io.Writer) ReporterOption {
+	return func(opts *reporterOptions) {
+		opts.barWriter = w
+	}
+}
+
 // WithShowBytes configures the console progress bar to display progress in bytes.
 func WithShowBytes(show bool) ReporterOption {
 	return func(opts *reporterOptions) {
@@ -112,6 +316,42 @@ func WithShowBytes(show bool) ReporterOption {
 	}
 }
 
+// WithProgressEndpoint serves progress over HTTP on a Unix socket ("unix" and a
+// socket path) or a TCP address ("tcp" and e.g. "127.0.0.1:9099"), so supervising
+// processes can query it without polling a file. GET /progress returns the latest
+// ProgressEvent as JSON and GET /events streams every event as Server-Sent Events
+// until the reporter is closed. The endpoint listens from NewReporter until Close or Fail.
+func WithProgressEndpoint(network, address string) ReporterOption {
+	return func(opts *reporterOptions) {
+		opts.endpointNetwork = network
+		opts.endpointAddress = address
+	}
+}
+
+// WithGranularity limits progress file writes and endpoint events to
+// milestones: when the percentage crosses a multiple of percent (e.g. 5 for
+// 0%, 5%, 10%...), when the step changes (e.g. from "downloading" to
+// "transcoding") and when the operation starts, completes or fails. This
+// reduces the noise of long jobs for consumers of these sinks, while the
+// Updates channel and the console bar still receive every update.
+// Defaults to 0, which writes every update.
+func WithGranularity(percent float64) ReporterOption {
+	return func(opts *reporterOptions) {
+		opts.granularity = percent
+	}
+}
+
+// WithEventWriter writes every event sent to the progress endpoint, lifecycle
+// events included, to w as a line of JSON (JSON Lines), so that a supervising
+// process can follow a job from its stdout. The events are subject to
+// WithGranularity. Combine it with WithBarWriter(io.Discard) when w is the
+// terminal the bar is drawn on.
+func WithEventWriter(w io.Writer) ReporterOption {
+	return func(opts *reporterOptions) {
+		opts.eventWriter = w
+	}
+}
+
 // DefaultReporter is the default implementation of the Reporter interface.
 // It uses the github.com/schollz/progressbar/v3 library to display a progress
 // bar on the console (stderr) and sends ProgressEvent updates to a channel.
@@ -124,8 +364,12 @@ type DefaultReporter struct {
 	updatesCh  chan ProgressEvent
 	lastUpdate time.Time
 	Event      ProgressEvent
-	completed  bool              // Flag to track whether Complete() has been called
-	mu         sync.Mutex // Protects access to shared fields
+	completed  bool          // Flag to track whether Complete() has been called
+	failed     bool          // Flag to track whether Fail() has been called
+	closed     bool          // Flag to track whether the updates channel is closed
+	endpoint   *endpoint     // Progress endpoint, nil unless WithProgressEndpoint is set
+	milestone  ProgressEvent // Last event written to the progress file and endpoint
+	mu         sync.Mutex    // Protects access to shared fields
 }
 
 // NewReporter creates a new DefaultReporter.
@@ -134,8 +378,11 @@ func NewReporter(opts ...ReporterOption) *DefaultReporter {
 	// Default options
 	options := reporterOptions{
 		description:        "Processing...",
-		showBytes:          true,   // Default to showing bytes
+		showBytes:          true, // Default to showing bytes
+		barWriter:          os.Stderr,
 		progressFileFormat: "text", // Default format
+		throttle:           DefaultThrottle,
+		bufferSize:         DefaultBufferSize,
 	}
 	// Apply provided functional options
 	for _, opt := range opts {
@@ -149,27 +396,95 @@ func NewReporter(opts ...ReporterOption) *DefaultReporter {
 			Timestamp: time.Now().Format(time.RFC3339),
 		},
 		lastUpdate: time.Now(),
-		updatesCh:  make(chan ProgressEvent, 10), // Buffered channel
+		updatesCh:  make(chan ProgressEvent, options.bufferSize),
+	}
+
+	if options.endpointAddress != "" {
+		ep, err := newEndpoint(options.endpointNetwork, options.endpointAddress, r.Event)
+		if err != nil {
+			// Progress is still reported through the other sinks
+			logger.Warn("Failed to start progress endpoint", "progress", map[string]interface{}{
+				"network": options.endpointNetwork,
+				"address": options.endpointAddress,
+				"error":   err.Error(),
+			})
+		} else {
+			r.endpoint = ep
+		}
 	}
 	return r
 }
 
+// EndpointAddr returns the address the progress endpoint listens on, or an empty
+// string when no endpoint is running.
+func (r *DefaultReporter) EndpointAddr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.endpoint == nil {
+		return ""
+	}
+	return r.endpoint.addr()
+}
+
+// Snapshot returns a copy of the current progress event.
+func (r *DefaultReporter) Snapshot() ProgressEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Event
+}
+
+// SetJobID sets the JobID of the events reported from now on.
+func (r *DefaultReporter) SetJobID(jobID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Event.JobID = jobID
+}
+
+// ReportEvent sends a lifecycle event, a copy of the current event with Event
+// and Data set, to the Updates channel, the progress endpoint and the event
+// writer, regardless
+// of the throttle and granularity. The progress file is not written, since it
+// holds the progress state.
+func (r *DefaultReporter) ReportEvent(name string, data map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed {
+		return
+	}
+	event := r.Event
+	event.Event = name
+	event.Data = data
+	event.Timestamp = time.Now().Format(time.RFC3339)
+
+	r.sendEventInternal(event)
+	if r.endpoint != nil {
+		r.endpoint.publish(event)
+	}
+	r.writeEventInternal(event)
+}
+
 // Start initializes the progress tracking for the DefaultReporter.
 // It sets the total number of steps and starts the progress bar.
 func (r *DefaultReporter) Start(total int64) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if r.failed {
+		return // Progress after a failure is ignored
+	}
+
 	r.Total = total
 	r.Current = 0
 	r.Started = time.Now()
 	r.Event.Status = "started"
 	r.Event.Percentage = 0
+	rates{}.apply(&r.Event)
 	r.Event.Timestamp = time.Now().Format(time.RFC3339)
 
 	barOpts := []progressbar.Option{
 		progressbar.OptionSetDescription(r.opts.description),
-		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetWriter(r.opts.barWriter),
 		progressbar.OptionShowCount(),
 		progressbar.OptionSetTheme(progressbar.Theme{
 			Saucer:        "=",
@@ -188,6 +503,7 @@ func (r *DefaultReporter) Start(total int64) {
 	// Send initial event and write initial file state
 	r.sendUpdateInternal(true)
 	r.writeProgressFileInternal()
+	r.publishInternal()
 }
 
 // Update sets the current progress and reports it via the progress bar and Updates channel.
@@ -196,6 +512,47 @@ func (r *DefaultReporter) Update(current int64, step, stage string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.updateInternal(current, step, stage, rates{})
+}
+
+// UpdateBytes sets the current progress like Update and records the byte counts
+// and transfer speed in the ProgressEvent.
+func (r *DefaultReporter) UpdateBytes(current, total int64, bytesPerSecond float64, step, stage string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.updateInternal(current, step, stage, rates{currentBytes: current, totalBytes: total, bytesPerSecond: bytesPerSecond})
+}
+
+// UpdateSpeed sets the current progress like Update and records the encoding
+// speed and estimated time left in the ProgressEvent.
+func (r *DefaultReporter) UpdateSpeed(current int64, speed, etaSeconds float64, step, stage string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.updateInternal(current, step, stage, rates{speed: speed, etaSeconds: etaSeconds})
+}
+
+// rates holds the byte counts and speeds of a progress update, zero for the
+// steps that do not measure them.
+type rates struct {
+	currentBytes, totalBytes int64
+	bytesPerSecond           float64
+	speed, etaSeconds        float64
+}
+
+// apply records the rates in event.
+func (rt rates) apply(event *ProgressEvent) {
+	event.CurrentBytes = rt.currentBytes
+	event.TotalBytes = rt.totalBytes
+	event.BytesPerSecond = rt.bytesPerSecond
+	event.Speed = rt.speed
+	event.ETASeconds = rt.etaSeconds
+}
+
+// updateInternal sets the current progress and rates and reports them to every sink.
+// Requires lock to be held by caller.
+func (r *DefaultReporter) updateInternal(current int64, step, stage string, rt rates) {
 	if r.Bar == nil {
 		return
 	} // Not started
@@ -212,12 +569,27 @@ func (r *DefaultReporter) Update(current int64, step, stage string) {
 	r.Event.Step = step
 	r.Event.Stage = stage
 	r.Event.Status = "processing"
+	rt.apply(&r.Event)
 	r.Event.Timestamp = time.Now().Format(time.RFC3339)
 
 	_ = r.Bar.Set64(current)
 
-	r.sendUpdateInternal(false)   // Throttle updates channel
-	r.writeProgressFileInternal() // Write file on every update
+	r.sendUpdateInternal(false) // Throttle updates channel
+	if r.milestoneInternal() {
+		r.writeProgressFileInternal() // Write file on every update, or at milestones
+		r.publishInternal()           // Publish to the endpoint likewise
+	}
+}
+
+// milestoneInternal reports whether the current event must be written to the
+// progress file and endpoint under the WithGranularity option.
+// Requires lock to be held by caller.
+func (r *DefaultReporter) milestoneInternal() bool {
+	step := r.opts.granularity
+	if step <= 0 || r.Event.Status != r.milestone.Status || r.Event.Step != r.milestone.Step {
+		return true
+	}
+	return math.Floor(r.Event.Percentage/step) != math.Floor(r.milestone.Percentage/step)
 }
 
 // Increment increases the progress by 1 and reports it.
@@ -237,19 +609,78 @@ func (r *DefaultReporter) Complete() {
 
 	_ = r.Bar.Finish()
 	r.Current = r.Total
+	if r.Event.TotalBytes > 0 {
+		r.Event.CurrentBytes = r.Event.TotalBytes
+	}
+	r.Event.ETASeconds = 0
 	r.Event.Percentage = 100
 	r.Event.Status = "completed"
 	r.Event.Timestamp = time.Now().Format(time.RFC3339)
 
 	r.sendUpdateInternal(true)    // Send final update regardless of throttle
 	r.writeProgressFileInternal() // Write final state
-	r.Bar = nil                   // Mark as finished to prevent further updates
-	
+	r.publishInternal()
+	r.Bar = nil // Mark as finished to prevent further updates
+
 	// Close the updates channel and mark as completed
-	close(r.updatesCh)
+	if !r.closed {
+		close(r.updatesCh)
+		r.closed = true
+	}
 	r.completed = true
 }
 
+// Fail marks the operation as failed, sends a final "failed" event with the error
+// details to every sink and closes the reporter. It also reports failures after a
+// Complete, since a reporter may be reused for several stages (download, transcoding).
+func (r *DefaultReporter) Fail(err error) {
+	r.finish("failed", err)
+}
+
+// Cancel marks the operation as cancelled, sending a final "cancelled" event
+// with the error details to every sink and closing the reporter.
+func (r *DefaultReporter) Cancel(err error) {
+	r.finish("cancelled", err)
+}
+
+// finish sends a final event with status and the details of err, then closes
+// the reporter.
+func (r *DefaultReporter) finish(status string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed {
+		return // Already failed
+	}
+	if err == nil {
+		err = stderrors.New("unknown error")
+	}
+
+	if r.Bar != nil {
+		_ = r.Bar.Exit()
+		r.Bar = nil
+	}
+	r.Event.Status = status
+	r.Event.Error = NewEventError(err)
+	r.Event.Timestamp = time.Now().Format(time.RFC3339)
+
+	r.sendUpdateInternal(true)
+	r.writeProgressFileInternal()
+	r.publishInternal()
+
+	r.closeInternal()
+	r.failed = true
+}
+
+// Close closes the Updates channel and stops the progress endpoint, if any.
+// It is safe to call after Complete or Fail, and more than once.
+func (r *DefaultReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeInternal()
+	return nil
+}
+
 // Updates returns the channel for receiving ProgressEvent updates.
 func (r *DefaultReporter) Updates() <-chan ProgressEvent {
 	return r.updatesCh
@@ -275,18 +706,35 @@ func (r *DefaultReporter) sendUpdateInternal(force bool) {
 		return // Throttled
 	}
 	r.lastUpdate = now
+	r.sendEventInternal(r.Event)
+}
 
-	// Don't attempt to send if we're already marked as completed
-	if r.completed {
+// sendEventInternal sends event to the updates channel without blocking,
+// dropping the oldest buffered event when nobody keeps up.
+// Requires lock to be held by caller.
+func (r *DefaultReporter) sendEventInternal(event ProgressEvent) {
+	// Don't attempt to send once the channel is closed
+	if r.closed {
 		return
 	}
+	sendDropOldest(r.updatesCh, event)
+}
 
-	// Non-blocking send to channel with timeout to prevent blocking
-	select {
-	case r.updatesCh <- r.Event:
-		// Successfully sent message
-	case <-time.After(time.Millisecond):
-		// Channel might be full or closed, don't panic
+// sendDropOldest sends event to the buffered channel ch without blocking. When
+// ch is full, its oldest event is dropped to make room, so the latest event,
+// such as the final "completed" one, is never lost. The callers hold a lock,
+// which makes them the only sender and ensures the loop ends.
+func sendDropOldest(ch chan ProgressEvent, event ProgressEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
 	}
 }
 
@@ -328,6 +776,47 @@ func (r *DefaultReporter) writeProgressFileInternal() {
 	}
 }
 
+// closeInternal closes the updates channel and the endpoint once.
+// Requires lock to be held by caller.
+func (r *DefaultReporter) closeInternal() {
+	if !r.closed {
+		close(r.updatesCh)
+		r.closed = true
+	}
+	if r.endpoint != nil {
+		r.endpoint.close()
+		r.endpoint = nil
+	}
+}
+
+// publishInternal publishes the current event to the progress endpoint and the
+// event writer, if any, and records it as the last milestone.
+// Requires lock to be held by caller.
+func (r *DefaultReporter) publishInternal() {
+	r.milestone = r.Event
+	if r.endpoint != nil {
+		r.endpoint.publish(r.Event)
+	}
+	r.writeEventInternal(r.Event)
+}
+
+// writeEventInternal writes event to the event writer as a JSON line.
+// Requires lock to be held by caller.
+func (r *DefaultReporter) writeEventInternal(event ProgressEvent) {
+	if r.opts.eventWriter == nil {
+		return
+	}
+	content, err := json.Marshal(event)
+	if err == nil {
+		_, err = r.opts.eventWriter.Write(append(content, '\n'))
+	}
+	if err != nil {
+		logger.Warn("Failed to write progress event", "progress", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
 // ReportProgress is deprecated. Consume events from the Reporter.Updates() channel instead.
 func ReportProgress(reporter Reporter) (string, error) {
 	// Deprecated: use reporter.Updates() channel.
@@ -1,10 +1,18 @@
 package progress
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
 )
 
 func TestNewReporter(t *testing.T) {
@@ -309,6 +317,260 @@ func TestReporterWithProgressFile(t *testing.T) {
 	})
 }
 
+func TestReporterWithGranularity(t *testing.T) {
+	progressFilePath := filepath.Join(t.TempDir(), "progress.txt")
+	reporter := NewReporter(WithProgressFile(progressFilePath), WithGranularity(5), WithThrottle(0), WithBarWriter(io.Discard))
+	reporter.Start(100)
+
+	steps := []struct {
+		current int64
+		step    string
+		want    string
+	}{
+		{1, "transcoding", "1.00"}, // first update: status changes to processing
+		{3, "transcoding", "1.00"},
+		{4, "transcoding", "1.00"},
+		{5, "transcoding", "5.00"},
+		{9, "transcoding", "5.00"},
+		{12, "transcoding", "12.00"},
+		{13, "uploading", "13.00"}, // step transition
+		{14, "uploading", "13.00"},
+	}
+	for _, s := range steps {
+		reporter.Update(s.current, s.step, "stage")
+		content, err := os.ReadFile(progressFilePath)
+		if err != nil {
+			t.Fatalf("Failed to read progress file: %v", err)
+		}
+		if string(content) != s.want {
+			t.Errorf("Progress file after Update(%d, %q) = %q, want %q", s.current, s.step, content, s.want)
+		}
+	}
+
+	// The Updates channel keeps every update
+	if got := len(reporter.Updates()); got != len(steps)+1 {
+		t.Errorf("Updates channel has %d events, want %d", got, len(steps)+1)
+	}
+
+	reporter.Complete()
+	if content, _ := os.ReadFile(progressFilePath); string(content) != "100.00" {
+		t.Errorf("Progress file after Complete = %q, want 100.00", content)
+	}
+}
+
+func TestReporterReportEvent(t *testing.T) {
+	progressFilePath := filepath.Join(t.TempDir(), "progress.txt")
+	reporter := NewReporter(WithProgressFile(progressFilePath), WithThrottle(time.Hour), WithBarWriter(io.Discard))
+	reporter.Start(100)
+	reporter.Update(40, "downloading", "Downloading file")
+	<-reporter.Updates() // start
+
+	// Lifecycle events ignore the throttle and do not change the progress
+	reporter.ReportEvent(EventDownloadCompleted, map[string]interface{}{"bytes": 1024})
+	event := <-reporter.Updates()
+	if event.Event != EventDownloadCompleted || event.Data["bytes"] != 1024 {
+		t.Errorf("Lifecycle event = %+v, want download_completed with bytes", event)
+	}
+	if event.Percentage != 40 || event.Step != "downloading" {
+		t.Errorf("Lifecycle event progress = %.0f%% %q, want the current progress", event.Percentage, event.Step)
+	}
+	if reporter.Event.Event != "" || reporter.Event.Data != nil {
+		t.Errorf("Current event = %+v, should not keep the lifecycle event", reporter.Event)
+	}
+	if content, _ := os.ReadFile(progressFilePath); string(content) != "40.00" {
+		t.Errorf("Progress file = %q, want 40.00", content)
+	}
+}
+
+func TestReporterWithEventWriter(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := NewReporter(WithEventWriter(&buf), WithGranularity(50), WithBarWriter(io.Discard))
+	reporter.Start(100)
+	reporter.Update(10, "transcoding", "stage")
+	reporter.Update(20, "transcoding", "stage") // below the granularity
+	reporter.ReportEvent(EventDownloadCompleted, nil)
+	reporter.Complete()
+
+	var got []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event ProgressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %.0f", event.Status, event.Event, event.Percentage))
+	}
+	want := []string{"started  0", "processing  10", "processing download_completed 20", "completed  100"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Event lines = %q, want %q", got, want)
+	}
+	if snapshot := reporter.Snapshot(); snapshot.Status != "completed" {
+		t.Errorf("Snapshot() status = %q, want completed", snapshot.Status)
+	}
+}
+
+func TestReporterFail(t *testing.T) {
+	progressFilePath := filepath.Join(t.TempDir(), "progress.json")
+	reporter := NewReporter(WithProgressFile(progressFilePath), WithProgressFileFormat("json"))
+	reporter.Start(100)
+	reporter.Update(30, "transcoding", "Creating HLS stream")
+
+	reporter.Fail(errors.New(errors.TranscodingError, "FFmpeg failed", "exit status 1", 1500))
+	reporter.Fail(errors.New(errors.SystemError, "ignored", "", 1))
+	reporter.Update(60, "transcoding", "ignored")
+	reporter.Complete()
+
+	if reporter.Event.Status != "failed" || reporter.Event.Percentage != 30 {
+		t.Errorf("Event = %+v, want failed at 30%%", reporter.Event)
+	}
+	want := &EventError{Type: "transcoding_error", Code: 1500, Message: "FFmpeg failed", Details: "exit status 1"}
+	if !reflect.DeepEqual(reporter.Event.Error, want) {
+		t.Errorf("Event.Error = %+v, want %+v", reporter.Event.Error, want)
+	}
+
+	var last ProgressEvent
+	for event := range reporter.Updates() {
+		last = event
+	}
+	if last.Status != "failed" {
+		t.Errorf("Last channel event status = %q, want %q", last.Status, "failed")
+	}
+
+	content, err := os.ReadFile(progressFilePath)
+	if err != nil {
+		t.Fatalf("Failed to read progress file: %v", err)
+	}
+	var fileEvent ProgressEvent
+	if err := json.Unmarshal(content, &fileEvent); err != nil || fileEvent.Error == nil || fileEvent.Error.Code != 1500 {
+		t.Errorf("Progress file = %s, want the failed event with its error", content)
+	}
+}
+
+func TestReporterThrottleDefault(t *testing.T) {
+	reporter := NewReporter(WithBarWriter(io.Discard))
+	reporter.Start(100)
+	for i := int64(1); i <= 50; i++ {
+		reporter.Update(i, "transcoding", "stage")
+	}
+	// Start is sent, the updates within DefaultThrottle of it are not
+	if got := len(reporter.Updates()); got != 1 {
+		t.Errorf("Updates channel has %d events, want 1", got)
+	}
+	reporter.Complete()
+	if got := len(reporter.Updates()); got != 2 {
+		t.Errorf("Updates channel has %d events after Complete, want 2", got)
+	}
+}
+
+func TestReporterBufferDropsOldest(t *testing.T) {
+	reporter := NewReporter(WithBufferSize(3), WithThrottle(0), WithBarWriter(io.Discard))
+	reporter.Start(100)
+	for i := int64(1); i <= 20; i++ {
+		reporter.Update(i, "transcoding", "stage")
+	}
+	reporter.Complete()
+	reporter.Close()
+
+	// Nobody listened: the latest events are kept, the final one included
+	var events []ProgressEvent
+	for event := range reporter.Updates() {
+		events = append(events, event)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Updates channel has %d events, want 3", len(events))
+	}
+	if events[0].Percentage != 19 || events[1].Percentage != 20 || events[2].Status != "completed" {
+		t.Errorf("Buffered events = %+v, want the updates to 19%% and 20%% then completed", events)
+	}
+}
+
+func TestReporterFailPlainError(t *testing.T) {
+	reporter := NewReporter()
+	reporter.Fail(fmt.Errorf("context canceled"))
+	if reporter.Event.Error == nil || reporter.Event.Error.Message != "context canceled" || reporter.Event.Error.Code != 0 {
+		t.Errorf("Event.Error = %+v, want the plain error message", reporter.Event.Error)
+	}
+}
+
+func TestReporterCloseIsIdempotent(t *testing.T) {
+	reporter := NewReporter()
+	reporter.Start(10)
+	reporter.Complete()
+	reporter.Complete()
+	if err := reporter.Close(); err != nil {
+		t.Errorf("Close() unexpected error: %v", err)
+	}
+	if err := reporter.Close(); err != nil {
+		t.Errorf("Second Close() unexpected error: %v", err)
+	}
+	// A failure after completion (e.g. in a later stage) is still reported
+	reporter.Fail(fmt.Errorf("upload failed"))
+	if reporter.Event.Status != "failed" {
+		t.Errorf("Status = %q, want %q", reporter.Event.Status, "failed")
+	}
+}
+
+func TestReporterUpdateBytes(t *testing.T) {
+	reporter := NewReporter()
+	reporter.Start(2000)
+
+	UpdateBytes(reporter, 500, 2000, 40e6, "downloading", "Downloading file")
+	if reporter.Event.CurrentBytes != 500 || reporter.Event.TotalBytes != 2000 || reporter.Event.BytesPerSecond != 40e6 {
+		t.Errorf("Byte fields = %d/%d at %f, want 500/2000 at 40e6", reporter.Event.CurrentBytes, reporter.Event.TotalBytes, reporter.Event.BytesPerSecond)
+	}
+	if reporter.Event.Percentage != 25 {
+		t.Errorf("Percentage = %f, want 25", reporter.Event.Percentage)
+	}
+
+	reporter.Complete()
+	if reporter.Event.CurrentBytes != 2000 {
+		t.Errorf("CurrentBytes after Complete() = %d, want 2000", reporter.Event.CurrentBytes)
+	}
+
+	// A new, non-byte step clears the byte fields
+	reporter.Start(100)
+	reporter.Update(10, "transcoding", "Creating HLS stream")
+	if reporter.Event.CurrentBytes != 0 || reporter.Event.TotalBytes != 0 || reporter.Event.BytesPerSecond != 0 {
+		t.Errorf("Byte fields should be cleared for frame updates, got %+v", reporter.Event)
+	}
+}
+
+func TestUpdateBytesFallsBackToUpdate(t *testing.T) {
+	wrapped := newRecordingReporter()
+	UpdateBytes(wrapped, 10, 20, 1, "downloading", "Downloading file")
+	if len(wrapped.calls) != 1 || wrapped.calls[0] != "update downloading" {
+		t.Errorf("calls = %v, want a single Update", wrapped.calls)
+	}
+}
+
+func TestReporterUpdateSpeed(t *testing.T) {
+	reporter := NewReporter(WithBarWriter(io.Discard))
+	reporter.Start(60000)
+
+	UpdateSpeed(reporter, 15000, 2.5, 18, "transcoding", "Encoding MP4")
+	if reporter.Event.Speed != 2.5 || reporter.Event.ETASeconds != 18 || reporter.Event.Percentage != 25 {
+		t.Errorf("Event = %+v, want 25%% at 2.5x with 18s left", reporter.Event)
+	}
+
+	reporter.Complete()
+	if reporter.Event.ETASeconds != 0 {
+		t.Errorf("ETASeconds after Complete() = %f, want 0", reporter.Event.ETASeconds)
+	}
+
+	// Steps that do not measure the speed clear it
+	reporter.Start(100)
+	reporter.Update(10, "transcoding", "Creating HLS stream")
+	if reporter.Event.Speed != 0 || reporter.Event.ETASeconds != 0 {
+		t.Errorf("Speed fields should be cleared for frame updates, got %+v", reporter.Event)
+	}
+
+	wrapped := newRecordingReporter()
+	UpdateSpeed(wrapped, 10, 1, 1, "transcoding", "Encoding MP4")
+	if len(wrapped.calls) != 1 || wrapped.calls[0] != "update transcoding" {
+		t.Errorf("calls = %v, want a single Update", wrapped.calls)
+	}
+}
+
 /* // Teste removido pois a função ReportProgress foi depreciada.
 func TestReportProgress(t *testing.T) {
 	reporter := NewReporter()
@@ -0,0 +1,245 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSConfig holds the region and credentials of AWS requests. Requests are
+// signed with Signature Version 4, without the AWS SDK.
+type AWSConfig struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials (e.g. an assumed role).
+	SessionToken string
+	// Endpoint replaces the "https://<service>.<region>.amazonaws.com" endpoint,
+	// e.g. for a VPC endpoint or LocalStack.
+	Endpoint string
+	// Client is the HTTP client used for the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// AWSConfigFromEnv reads the configuration from the standard AWS environment
+// variables: AWS_REGION (or AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_ENDPOINT_URL.
+func AWSConfigFromEnv() AWSConfig {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return AWSConfig{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+	}
+}
+
+// SecretsManager is the Provider of AWS Secrets Manager secrets. The name is
+// the secret ID or ARN, optionally followed by "#<key>" to select a field of
+// a JSON secret.
+type SecretsManager struct {
+	Config AWSConfig
+}
+
+// Secret implements Provider.
+func (p SecretsManager) Secret(ctx context.Context, name string) ([]byte, error) {
+	id, field, _ := strings.Cut(name, "#")
+	var resp struct {
+		SecretString *string
+		SecretBinary []byte
+	}
+	if err := p.Config.call(ctx, "secretsmanager", "secretsmanager.GetSecretValue",
+		map[string]string{"SecretId": id}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.SecretString == nil {
+		if field != "" {
+			return nil, fmt.Errorf("binary secret has no field %q", field)
+		}
+		return resp.SecretBinary, nil
+	}
+	if field == "" {
+		return []byte(*resp.SecretString), nil
+	}
+	return jsonField([]byte(*resp.SecretString), field)
+}
+
+// KMS is the Provider decrypting AWS KMS ciphertexts: the name is the base64
+// CiphertextBlob returned by "aws kms encrypt", and the secret its plaintext.
+type KMS struct {
+	Config AWSConfig
+}
+
+// Secret implements Provider.
+func (p KMS) Secret(ctx context.Context, name string) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte
+	}
+	if err := p.Config.call(ctx, "kms", "TrentService.Decrypt",
+		map[string]string{"CiphertextBlob": name}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// call sends a request of the AWS JSON 1.1 protocol, used by Secrets Manager
+// and KMS, and decodes the response into out.
+func (c AWSConfig) call(ctx context.Context, service, target string, in, out interface{}) error {
+	if c.Region == "" || c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return fmt.Errorf("AWS region and credentials are required (AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY)")
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", service, c.Region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	c.sign(req, body, service, time.Now())
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Services use "message" or "Message", both decoded into Message
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string
+		}
+		if json.Unmarshal(data, &awsErr) != nil || awsErr.Type == "" {
+			return fmt.Errorf("%s returned %s", target, resp.Status)
+		}
+		return fmt.Errorf("%s returned %s: %s", target, awsErr.Type, awsErr.Message)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// sign adds the Signature Version 4 headers of req, whose body is body, at now.
+func (c AWSConfig) sign(req *http.Request, body []byte, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	// Every header set so far is signed, with the host
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query parameters sorted and escaped as required by
+// Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsEscape percent-encodes s, leaving only unreserved characters.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// jsonField returns the field of the JSON object data: strings as is, other
+// values as JSON.
+func jsonField(data []byte, field string) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	value, ok := object[field]
+	if !ok {
+		return nil, fmt.Errorf("secret has no field %q", field)
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return []byte(s), nil
+	}
+	return value, nil
+}
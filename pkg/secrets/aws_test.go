@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureV4(t *testing.T) {
+	// Example of the AWS Signature Version 4 documentation
+	config := AWSConfig{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	config.sign(req, nil, "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+// awsServer simulates Secrets Manager and KMS, answering with the response of
+// the X-Amz-Target of each request.
+func awsServer(t *testing.T, responses map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var in map[string]string
+		json.Unmarshal(body, &in)
+		response, ok := responses[r.Header.Get("X-Amz-Target")+" "+in["SecretId"]+in["CiphertextBlob"]]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","Message":"Secrets Manager can't find the specified secret."}`))
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSecretsManager(t *testing.T) {
+	server := awsServer(t, map[string]string{
+		"secretsmanager.GetSecretValue prod/key": `{"SecretString":"0123456789abcdef"}`,
+		"secretsmanager.GetSecretValue prod/cdn": `{"SecretString":"{\"token\":\"abc\",\"port\":21}"}`,
+		"secretsmanager.GetSecretValue prod/bin": `{"SecretBinary":"AAEC"}`,
+	})
+	provider := SecretsManager{Config: AWSConfig{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session", Endpoint: server.URL}}
+
+	for name, want := range map[string]string{"prod/key": "0123456789abcdef", "prod/cdn#token": "abc", "prod/cdn#port": "21", "prod/bin": "\x00\x01\x02"} {
+		if got, err := provider.Secret(context.Background(), name); err != nil || string(got) != want {
+			t.Errorf("Secret(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := provider.Secret(context.Background(), "prod/missing"); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("Secret() error = %v, want the AWS error", err)
+	}
+	if _, err := provider.Secret(context.Background(), "prod/cdn#user"); err == nil {
+		t.Error("Secret() expected an error for a missing field")
+	}
+	if _, err := (SecretsManager{}).Secret(context.Background(), "prod/key"); err == nil {
+		t.Error("Secret() expected an error without credentials")
+	}
+}
+
+func TestKMS(t *testing.T) {
+	server := awsServer(t, map[string]string{
+		"TrentService.Decrypt AQICAHh": `{"KeyId":"arn:aws:kms:eu-west-1:1:key/1","Plaintext":"c2VjcmV0IGtleQ=="}`,
+	})
+	provider := KMS{Config: AWSConfig{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session", Endpoint: server.URL}}
+	if got, err := provider.Secret(context.Background(), "AQICAHh"); err != nil || string(got) != "secret key" {
+		t.Errorf("Secret() = %q, %v, want the plaintext", got, err)
+	}
+}
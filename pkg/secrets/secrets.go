@@ -0,0 +1,136 @@
+// Package secrets resolves references to secrets, such as encryption keys and
+// storage credentials, so they do not have to be passed as plaintext flags.
+//
+// A reference is "<scheme>:<name>", where the scheme selects the Provider:
+//
+//	env:HLSPRESSO_KEY                    environment variable
+//	file:/run/secrets/hlspresso.key      file contents
+//	aws-sm:prod/cdn#token                AWS Secrets Manager secret (or a field of its JSON value)
+//	aws-kms:AQICAHh...                   AWS KMS ciphertext (base64), decrypted
+//	vault:secret/data/hlspresso#key      HashiCorp Vault KV secret field
+//
+// Strings may also embed references as "{secret:<reference>}", replaced by
+// Expand, e.g. "Authorization: Bearer {secret:env:CDN_TOKEN}".
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Provider looks up secrets by name.
+type Provider interface {
+	// Secret returns the value of the secret name.
+	Secret(ctx context.Context, name string) ([]byte, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, name string) ([]byte, error)
+
+// Secret implements Provider.
+func (f ProviderFunc) Secret(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+// Env is the Provider of environment variables.
+var Env = ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return []byte(value), nil
+})
+
+// File is the Provider of file contents, e.g. Docker or Kubernetes secrets
+// mounted as files. A single trailing newline is removed.
+var File = ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	return bytes.TrimSuffix(data, []byte("\r")), nil
+})
+
+// Resolver resolves references with the Provider registered for their scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a Resolver with the env and file providers, and the
+// aws-sm, aws-kms and vault providers configured from the environment (see
+// AWSConfigFromEnv and VaultFromEnv).
+func NewResolver() *Resolver {
+	r := &Resolver{providers: map[string]Provider{}}
+	r.Register("env", Env)
+	r.Register("file", File)
+	aws := AWSConfigFromEnv()
+	r.Register("aws-sm", SecretsManager{Config: aws})
+	r.Register("aws-kms", KMS{Config: aws})
+	r.Register("vault", VaultFromEnv())
+	return r
+}
+
+// Register sets the Provider of scheme, replacing any previous one.
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+// Schemes returns the registered schemes, sorted.
+func (r *Resolver) Schemes() []string {
+	schemes := make([]string, 0, len(r.providers))
+	for scheme := range r.providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Resolve returns the value of the secret ref ("<scheme>:<name>").
+func (r *Resolver) Resolve(ctx context.Context, ref string) ([]byte, error) {
+	scheme, name, found := strings.Cut(ref, ":")
+	provider := r.providers[scheme]
+	if !found || provider == nil || name == "" {
+		return nil, fmt.Errorf("invalid secret reference %q (expected <scheme>:<name> with a scheme among %s)",
+			ref, strings.Join(r.Schemes(), ", "))
+	}
+	value, err := provider.Secret(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", scheme+":"+redactName(name), err)
+	}
+	return value, nil
+}
+
+// placeholderPattern matches the "{secret:<reference>}" placeholders of Expand.
+var placeholderPattern = regexp.MustCompile(`\{secret:([^{}]+)\}`)
+
+// Expand replaces every "{secret:<reference>}" placeholder of s with the value
+// of the secret. Strings without placeholders are returned unchanged.
+func (r *Resolver) Expand(ctx context.Context, s string) (string, error) {
+	var err error
+	expanded := placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return match
+		}
+		var value []byte
+		value, err = r.Resolve(ctx, placeholderPattern.FindStringSubmatch(match)[1])
+		return string(value)
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// redactName shortens long secret names, such as KMS ciphertexts, in errors.
+func redactName(name string) string {
+	if len(name) > 40 {
+		return name[:16] + "..."
+	}
+	return name
+}
@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Vault is the Provider of HashiCorp Vault KV secrets. The name is the API path
+// of the secret, without "/v1/", followed by "#<field>": for KV version 2,
+// "secret/data/hlspresso#key" reads the field "key" of the secret
+// "hlspresso" of the "secret" mount. Without a field, a secret with a single
+// field returns it.
+type Vault struct {
+	// Address is the URL of the Vault server, e.g. "https://vault.example.com:8200".
+	Address string
+	// Token authenticates the requests.
+	Token string
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+	// Client is the HTTP client used for the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// VaultFromEnv reads the configuration from the standard Vault environment
+// variables: VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE.
+func VaultFromEnv() Vault {
+	return Vault{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+}
+
+// Secret implements Provider.
+func (v Vault) Secret(ctx context.Context, name string) ([]byte, error) {
+	if v.Address == "" || v.Token == "" {
+		return nil, fmt.Errorf("Vault address and token are required (VAULT_ADDR, VAULT_TOKEN)")
+	}
+	path, field, _ := strings.Cut(name, "#")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(v.Address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return nil, fmt.Errorf("Vault returned %s: %s", resp.Status, strings.Join(vaultErr.Errors, "; "))
+		}
+		return nil, fmt.Errorf("Vault returned %s", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return nil, err
+	}
+	fields := secret.Data
+	// KV version 2 nests the fields in data.data, next to data.metadata
+	if nested, ok := fields["data"]; ok && fields["metadata"] != nil {
+		fields = nil
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, err
+		}
+	}
+
+	if field == "" {
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("secret has %d fields, select one with #<field>", len(fields))
+		}
+		for name := range fields {
+			field = name
+		}
+	}
+	object, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return jsonField(object, field)
+}
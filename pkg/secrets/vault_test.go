@@ -0,0 +1,45 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/hlspresso":
+			w.Write([]byte(`{"data":{"data":{"key":"0123","user":"ftp"},"metadata":{"version":3}}}`))
+		case "/v1/kv/cdn":
+			w.Write([]byte(`{"data":{"token":"abc"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	vault := Vault{Address: server.URL, Token: "token"}
+	for name, want := range map[string]string{"secret/data/hlspresso#key": "0123", "kv/cdn#token": "abc", "kv/cdn": "abc"} {
+		if got, err := vault.Secret(context.Background(), name); err != nil || string(got) != want {
+			t.Errorf("Secret(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"secret/data/hlspresso", "secret/data/missing#key", "kv/cdn#user"} {
+		if _, err := vault.Secret(context.Background(), name); err == nil {
+			t.Errorf("Secret(%q) expected an error", name)
+		}
+	}
+
+	vault.Token = "expired"
+	if _, err := vault.Secret(context.Background(), "kv/cdn"); err == nil || err.Error() != "Vault returned 403 Forbidden: permission denied" {
+		t.Errorf("Secret() error = %v, want the Vault error", err)
+	}
+}
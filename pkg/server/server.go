@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// PlayerPath is the URL path of the hls.js test page served when the player is enabled.
+const PlayerPath = "/player.html"
+
+// shutdownTimeout bounds how long Serve waits for open requests after its context is done.
+const shutdownTimeout = 5 * time.Second
+
+//go:embed player.html
+var playerPage []byte
+
+// contentTypes maps the extensions of the files HLSpresso generates to the MIME
+// types expected by HLS players. Go's mime package does not know most of them.
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".aac":  "audio/aac",
+	".vtt":  "text/vtt; charset=utf-8",
+	".json": "application/json",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+}
+
+// Options contains settings for the preview HTTP server.
+type Options struct {
+	// Dir is the directory to serve, typically the HLS output directory.
+	Dir string
+	// Addr is the TCP address to listen on. Defaults to "localhost:8080".
+	// Use port 0 to pick a free port.
+	Addr string
+	// MasterPlaylist is the playlist opened by the player page, relative to Dir.
+	// Defaults to "master.m3u8".
+	MasterPlaylist string
+	// DisablePlayer turns off the hls.js test page served at PlayerPath.
+	DisablePlayer bool
+}
+
+// Server serves a generated HLS output directory over HTTP for previewing, with
+// the MIME types and CORS headers required by browser players.
+// It is meant for local verification, not as a production origin.
+type Server struct {
+	options  Options
+	listener net.Listener
+}
+
+// New creates a new preview Server, setting defaults for unspecified options.
+func New(options Options) *Server {
+	if options.Addr == "" {
+		options.Addr = "localhost:8080"
+	}
+	if options.MasterPlaylist == "" {
+		options.MasterPlaylist = "master.m3u8"
+	}
+	return &Server{options: options}
+}
+
+// Handler returns the http.Handler serving the directory (and the player page,
+// unless disabled). It can be mounted on a custom http.Server.
+func (s *Server) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.options.Dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Range")
+		h.Set("Access-Control-Expose-Headers", "Content-Length, Content-Range")
+
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodGet, http.MethodHead:
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.URL.Path == PlayerPath && !s.options.DisablePlayer {
+			h.Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(playerPage)
+			return
+		}
+
+		if contentType, ok := contentTypes[strings.ToLower(path.Ext(r.URL.Path))]; ok {
+			h.Set("Content-Type", contentType)
+		}
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			// Playlists of event streams change while they are being generated
+			h.Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// Listen checks the served directory and binds the listening address.
+// It must be called before Serve and URL.
+func (s *Server) Listen() error {
+	info, err := os.Stat(s.options.Dir)
+	if err != nil || !info.IsDir() {
+		return errors.New(errors.FileNotFoundError,
+			errors.GetErrorMessage(errors.ErrDirectoryNotFound),
+			s.options.Dir, errors.ErrDirectoryNotFound)
+	}
+
+	listener, err := net.Listen("tcp", s.options.Addr)
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to listen on preview server address", 1)
+	}
+	s.listener = listener
+	return nil
+}
+
+// URL returns the address to open in a browser: the player page with the master
+// playlist selected, or the master playlist itself when the player is disabled.
+// Returns an empty string if Listen has not been called.
+func (s *Server) URL() string {
+	if s.listener == nil {
+		return ""
+	}
+
+	base := "http://" + s.listener.Addr().String()
+	playlist := "/" + filepath.ToSlash(strings.TrimPrefix(s.options.MasterPlaylist, "/"))
+	if s.options.DisablePlayer {
+		return base + playlist
+	}
+	return fmt.Sprintf("%s%s?src=%s", base, PlayerPath, url.QueryEscape(playlist))
+}
+
+// Serve handles requests until ctx is done, then shuts the server down gracefully.
+func (s *Server) Serve(ctx context.Context) error {
+	if s.listener == nil {
+		return errors.New(errors.SystemError, "Preview server is not listening", "call Listen before Serve", 2)
+	}
+
+	srv := &http.Server{Handler: s.Handler()}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(s.listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return errors.Wrap(err, errors.SystemError, "Preview server failed", 3)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "stream_0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"master.m3u8":            "#EXTM3U\n",
+		"stream_0/playlist.m3u8": "#EXTM3U\n",
+		"stream_0/data000.ts":    "segment",
+		"stream_0/data000.m4s":   "segment",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestHandlerContentTypesAndCORS(t *testing.T) {
+	handler := New(Options{Dir: newTestDir(t)}).Handler()
+
+	tests := map[string]string{
+		"/master.m3u8":          "application/vnd.apple.mpegurl",
+		"/stream_0/data000.ts":  "video/mp2t",
+		"/stream_0/data000.m4s": "video/iso.segment",
+		PlayerPath:              "text/html; charset=utf-8",
+	}
+	for path, wantType := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s status = %d, want 200", path, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != wantType {
+			t.Errorf("GET %s Content-Type = %q, want %q", path, got, wantType)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("GET %s Access-Control-Allow-Origin = %q, want *", path, got)
+		}
+	}
+}
+
+func TestHandlerPreflightAndMethods(t *testing.T) {
+	handler := New(Options{Dir: newTestDir(t)}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/master.m3u8", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Headers") != "Range" {
+		t.Errorf("OPTIONS status = %d, headers = %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/master.m3u8", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestHandlerDisablePlayer(t *testing.T) {
+	handler := New(Options{Dir: newTestDir(t), DisablePlayer: true}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PlayerPath, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET %s status = %d, want 404 with the player disabled", PlayerPath, rec.Code)
+	}
+}
+
+func TestListenMissingDir(t *testing.T) {
+	s := New(Options{Dir: filepath.Join(t.TempDir(), "missing"), Addr: "127.0.0.1:0"})
+	if err := s.Listen(); err == nil {
+		t.Fatal("Listen() expected an error for a missing directory")
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := New(Options{Dir: newTestDir(t), Addr: "127.0.0.1:0"})
+	if err := s.Listen(); err != nil {
+		t.Fatalf("Listen() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(s.URL(), PlayerPath+"?src=%2Fmaster.m3u8") {
+		t.Errorf("URL() = %q, want the player page with the master playlist", s.URL())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+
+	resp, err := http.Get("http://" + s.listener.Addr().String() + "/master.m3u8")
+	if err != nil {
+		t.Fatalf("GET master.m3u8 failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "#EXTM3U\n" {
+		t.Errorf("GET master.m3u8 body = %q", body)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve() returned %v after shutdown, want nil", err)
+	}
+}
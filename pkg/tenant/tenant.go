@@ -0,0 +1,215 @@
+// Package tenant namespaces the jobs and outputs of the tenants of a shared
+// HLSpresso service and enforces their quotas: how many jobs run at once and
+// how much storage their outputs take.
+//
+// Each tenant writes its outputs under its own prefix of the output root, so
+// tenants cannot read or overwrite each other's outputs, and its storage use
+// is the size of that directory.
+package tenant
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Errors returned by the Registry.
+var (
+	// ErrUnknownTenant is returned for tenants that are not registered.
+	ErrUnknownTenant = stderrors.New("unknown tenant")
+	// ErrConcurrencyQuota is returned when a tenant already runs
+	// MaxConcurrentJobs jobs.
+	ErrConcurrencyQuota = stderrors.New("tenant concurrent job quota exceeded")
+	// ErrStorageQuota is returned when the outputs of a tenant would exceed
+	// its StorageQuotaBytes.
+	ErrStorageQuota = stderrors.New("tenant storage quota exceeded")
+	// ErrOutsideNamespace is returned for output paths escaping the prefix of
+	// the tenant (e.g. "../other").
+	ErrOutsideNamespace = stderrors.New("output path outside the tenant namespace")
+)
+
+// idPattern matches the accepted tenant IDs, which are used in paths.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Tenant is a customer of a shared service and its quotas.
+type Tenant struct {
+	// ID identifies the tenant, e.g. the auth.Principal Tenant of its API keys.
+	ID string `json:"id"`
+	// OutputPrefix is the directory of the outputs of the tenant, relative to
+	// the output root. Defaults to the ID.
+	OutputPrefix string `json:"output_prefix,omitempty"`
+	// MaxConcurrentJobs limits the jobs of the tenant running at once. Zero
+	// does not limit them.
+	MaxConcurrentJobs int `json:"max_concurrent_jobs,omitempty"`
+	// StorageQuotaBytes limits the total size of the outputs of the tenant.
+	// Zero does not limit it.
+	StorageQuotaBytes int64 `json:"storage_quota_bytes,omitempty"`
+}
+
+// Registry holds the tenants of a service, their namespaces and their running
+// jobs. It is safe for concurrent use.
+type Registry struct {
+	root    string
+	tenants map[string]Tenant
+
+	mu      sync.Mutex
+	running map[string]int
+	// reserved is the estimated size of the outputs of the running jobs of
+	// each tenant, counted against its quota until the jobs are released.
+	reserved map[string]int64
+}
+
+// NewRegistry returns a Registry of tenants whose outputs are written under root.
+func NewRegistry(root string, tenants []Tenant) (*Registry, error) {
+	r := &Registry{root: root, tenants: make(map[string]Tenant), running: make(map[string]int), reserved: make(map[string]int64)}
+	prefixes := make(map[string]string)
+	for _, t := range tenants {
+		if !idPattern.MatchString(t.ID) {
+			return nil, fmt.Errorf("invalid tenant ID %q (letters, digits, '.', '_' and '-', up to 64 characters)", t.ID)
+		}
+		if _, ok := r.tenants[t.ID]; ok {
+			return nil, fmt.Errorf("tenant %s is registered twice", t.ID)
+		}
+		if t.OutputPrefix == "" {
+			t.OutputPrefix = t.ID
+		}
+		prefix := filepath.Clean(filepath.FromSlash(t.OutputPrefix))
+		if filepath.IsAbs(prefix) || prefix == "." || prefix == ".." || strings.HasPrefix(prefix, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("tenant %s: output prefix %q must be a directory inside the output root", t.ID, t.OutputPrefix)
+		}
+		if other, ok := prefixes[prefix]; ok {
+			return nil, fmt.Errorf("tenants %s and %s share the output prefix %q", other, t.ID, t.OutputPrefix)
+		}
+		// A prefix inside another would let a tenant reach the outputs of the
+		// other, and count them in its storage.
+		for otherPrefix, other := range prefixes {
+			if strings.HasPrefix(prefix, otherPrefix+string(filepath.Separator)) || strings.HasPrefix(otherPrefix, prefix+string(filepath.Separator)) {
+				return nil, fmt.Errorf("tenants %s and %s have nested output prefixes %q and %q", other, t.ID, otherPrefix, prefix)
+			}
+		}
+		if t.MaxConcurrentJobs < 0 || t.StorageQuotaBytes < 0 {
+			return nil, fmt.Errorf("tenant %s: quotas must not be negative", t.ID)
+		}
+		prefixes[prefix] = t.ID
+		t.OutputPrefix = prefix
+		r.tenants[t.ID] = t
+	}
+	return r, nil
+}
+
+// Tenant returns the tenant id.
+func (r *Registry) Tenant(id string) (Tenant, error) {
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w: %s", ErrUnknownTenant, id)
+	}
+	return t, nil
+}
+
+// Dir returns the directory of the outputs of the tenant id.
+func (r *Registry) Dir(id string) (string, error) {
+	t, err := r.Tenant(id)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(r.root, t.OutputPrefix), nil
+}
+
+// OutputPath returns the path of the output path requested by the tenant id,
+// inside its namespace: path is relative to the directory of the tenant, and
+// may not leave it.
+func (r *Registry) OutputPath(id, path string) (string, error) {
+	dir, err := r.Dir(id)
+	if err != nil {
+		return "", err
+	}
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || clean == "." || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrOutsideNamespace, path)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// StorageUsed returns the total size of the outputs of the tenant id.
+func (r *Registry) StorageUsed(id string) (int64, error) {
+	dir, err := r.Dir(id)
+	if err != nil {
+		return 0, err
+	}
+	var used int64
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			used += info.Size()
+		}
+		return nil
+	})
+	return used, err
+}
+
+// Admit checks the quotas of the tenant id for a new job whose output is
+// expected to take estimatedBytes (e.g. the EstimatedSize of transcoder.Plan, or
+// zero), and counts the job as running. estimatedBytes stays reserved against
+// the storage quota until the job is released, so that jobs admitted together
+// cannot exceed it before writing their outputs. The caller must call release
+// once the job is over.
+func (r *Registry) Admit(id string, estimatedBytes int64) (release func(), err error) {
+	t, err := r.Tenant(id)
+	if err != nil {
+		return nil, err
+	}
+	if estimatedBytes < 0 {
+		estimatedBytes = 0
+	}
+
+	// The lock is held while measuring, so that the check and the reservation
+	// are atomic.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.MaxConcurrentJobs > 0 && r.running[id] >= t.MaxConcurrentJobs {
+		return nil, fmt.Errorf("%w: tenant %s already runs %d jobs", ErrConcurrencyQuota, id, r.running[id])
+	}
+	if t.StorageQuotaBytes > 0 {
+		used, err := r.StorageUsed(id)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: failed to measure the storage used: %w", id, err)
+		}
+		if used+r.reserved[id]+estimatedBytes > t.StorageQuotaBytes {
+			return nil, fmt.Errorf("%w: tenant %s uses %d of %d bytes and running jobs reserve %d, the job needs about %d",
+				ErrStorageQuota, id, used, t.StorageQuotaBytes, r.reserved[id], estimatedBytes)
+		}
+	}
+	r.running[id]++
+	r.reserved[id] += estimatedBytes
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.running[id]--
+			r.reserved[id] -= estimatedBytes
+		})
+	}, nil
+}
+
+// Running returns the number of jobs of the tenant id admitted and not released.
+func (r *Registry) Running(id string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[id]
+}
@@ -0,0 +1,129 @@
+package tenant
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) (*Registry, string) {
+	root := t.TempDir()
+	r, err := NewRegistry(root, []Tenant{
+		{ID: "acme", MaxConcurrentJobs: 1, StorageQuotaBytes: 100},
+		{ID: "globex", OutputPrefix: "customers/globex"},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry() error: %v", err)
+	}
+	return r, root
+}
+
+func TestNewRegistryValidation(t *testing.T) {
+	for name, tenants := range map[string][]Tenant{
+		"id":        {{ID: "../acme"}},
+		"duplicate": {{ID: "acme"}, {ID: "acme"}},
+		"prefix":    {{ID: "acme", OutputPrefix: "../shared"}},
+		"absolute":  {{ID: "acme", OutputPrefix: "/srv/acme"}},
+		"shared":    {{ID: "acme", OutputPrefix: "media"}, {ID: "globex", OutputPrefix: "media/"}},
+		"nested":    {{ID: "acme", OutputPrefix: "a"}, {ID: "globex", OutputPrefix: "a/./b"}},
+		"ancestor":  {{ID: "acme", OutputPrefix: "a/b"}, {ID: "globex", OutputPrefix: "a"}},
+		"quota":     {{ID: "acme", StorageQuotaBytes: -1}},
+	} {
+		if _, err := NewRegistry("out", tenants); err == nil {
+			t.Errorf("NewRegistry() with an invalid %s expected an error", name)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	r, root := newTestRegistry(t)
+	for _, tc := range []struct {
+		tenant, path, want string
+		wantErr            error
+	}{
+		{tenant: "acme", path: "movies/trailer", want: filepath.Join(root, "acme", "movies", "trailer")},
+		{tenant: "globex", path: "trailer", want: filepath.Join(root, "customers", "globex", "trailer")},
+		{tenant: "acme", path: "movies/../../globex", wantErr: ErrOutsideNamespace},
+		{tenant: "acme", path: "/etc", wantErr: ErrOutsideNamespace},
+		{tenant: "acme", path: ".", wantErr: ErrOutsideNamespace},
+		{tenant: "initech", path: "trailer", wantErr: ErrUnknownTenant},
+	} {
+		got, err := r.OutputPath(tc.tenant, tc.path)
+		if got != tc.want || !stderrors.Is(err, tc.wantErr) {
+			t.Errorf("OutputPath(%q, %q) = %q, %v, want %q, %v", tc.tenant, tc.path, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestAdmitConcurrency(t *testing.T) {
+	r, _ := newTestRegistry(t)
+	release, err := r.Admit("acme", 0)
+	if err != nil {
+		t.Fatalf("Admit() error: %v", err)
+	}
+	if _, err := r.Admit("acme", 0); !stderrors.Is(err, ErrConcurrencyQuota) {
+		t.Errorf("second Admit() error = %v, want ErrConcurrencyQuota", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Admit("globex", 0); err != nil {
+			t.Errorf("Admit() of an unlimited tenant error: %v", err)
+		}
+	}
+
+	release()
+	release()
+	if n := r.Running("acme"); n != 0 {
+		t.Errorf("Running() after release = %d, want 0", n)
+	}
+	if _, err := r.Admit("acme", 0); err != nil {
+		t.Errorf("Admit() after release error: %v", err)
+	}
+}
+
+func TestAdmitStorage(t *testing.T) {
+	r, root := newTestRegistry(t)
+	if used, err := r.StorageUsed("acme"); err != nil || used != 0 {
+		t.Errorf("StorageUsed() of a new tenant = %d, %v, want 0", used, err)
+	}
+
+	dir := filepath.Join(root, "acme", "movie")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "segment_000.ts"), make([]byte, 80), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if used, err := r.StorageUsed("acme"); err != nil || used != 80 {
+		t.Errorf("StorageUsed() = %d, %v, want 80", used, err)
+	}
+
+	if _, err := r.Admit("acme", 30); !stderrors.Is(err, ErrStorageQuota) {
+		t.Errorf("Admit() over the quota error = %v, want ErrStorageQuota", err)
+	}
+	if n := r.Running("acme"); n != 0 {
+		t.Errorf("Running() after a rejected job = %d, want 0", n)
+	}
+	if _, err := r.Admit("acme", 20); err != nil {
+		t.Errorf("Admit() within the quota error: %v", err)
+	}
+}
+
+func TestAdmitReservesStorage(t *testing.T) {
+	r, err := NewRegistry(t.TempDir(), []Tenant{{ID: "acme", StorageQuotaBytes: 100}})
+	if err != nil {
+		t.Fatalf("NewRegistry() error: %v", err)
+	}
+	release, err := r.Admit("acme", 60)
+	if err != nil {
+		t.Fatalf("Admit() error: %v", err)
+	}
+	// The first job has not written its output yet, but holds 60 bytes.
+	if _, err := r.Admit("acme", 60); !stderrors.Is(err, ErrStorageQuota) {
+		t.Errorf("Admit() over the reserved quota error = %v, want ErrStorageQuota", err)
+	}
+	release()
+	if _, err := r.Admit("acme", 60); err != nil {
+		t.Errorf("Admit() after release error: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package transcoder
+
+import (
+	"context"
+
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// resolveAudioCodec returns the audio codec to encode inputPath with and the
+// RFC 6381 tag of the resulting audio to signal in the master playlist, or ""
+// for AAC, which ffmpeg signals itself. Passthrough (hls.AudioCopy) falls back
+// to AAC when the audio of the input cannot be carried in HLS.
+func (t *Transcoder) resolveAudioCodec(ctx context.Context, inputPath string) (hls.AudioCodec, string) {
+	switch codec := t.options.AudioCodec; codec {
+	case hls.AudioAC3, hls.AudioEAC3:
+		return codec, hls.AudioCodecTag(string(codec))
+	case hls.AudioCopy:
+		inputCodec := ""
+		if output, err := t.ffprobe(ctx, inputPath); err == nil {
+			for _, stream := range output.Streams {
+				if stream.CodecType == "audio" {
+					inputCodec = stream.CodecName
+					break
+				}
+			}
+		}
+		if tag := hls.AudioCodecTag(inputCodec); tag != "" {
+			return hls.AudioCopy, tag
+		}
+		t.logger.Warn("The audio of the input cannot be passed through, encoding AAC instead", "transcoder", map[string]interface{}{
+			"codec": inputCodec,
+		})
+	}
+	return hls.AudioAAC, ""
+}
@@ -0,0 +1,86 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestTranscodeAudioPassthrough(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputAudio string
+		wantCodec  string
+		wantTag    string
+	}{
+		{"dolby input", "eac3", "copy", "ec-3"},
+		{"unsupported input", "pcm_s24le", "aac", "mp4a.40.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "input.mov")
+			if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+				t.Fatalf("Failed to create dummy input file: %v", err)
+			}
+			outputDir := filepath.Join(dir, "hls")
+			probe := `{"streams": [
+				{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "r_frame_rate": "25/1"},
+				{"index": 1, "codec_type": "audio", "codec_name": "` + tt.inputAudio + `", "channels": 6}
+			], "format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "10.000000"}}`
+
+			var encodeArgs []string
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				switch {
+				case name == "ffprobe":
+					return ffmpegtest.Result{Stdout: probe}
+				case len(args) == 1:
+					return scriptedFFmpeg("", nil)(name, args)
+				}
+				encodeArgs = args
+				// ffmpeg signals the audio of every variant as AAC
+				os.WriteFile(filepath.Join(outputDir, "master.m3u8"),
+					[]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=5000000,CODECS=\"avc1.640028,mp4a.40.2\"\nstream_0/playlist.m3u8\n"), 0644)
+				return ffmpegtest.Result{}
+			}}
+
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         outputDir,
+				OutputType:         HLSOutput,
+				HLSResolutions:     []hls.VideoResolution{hls.DefaultResolutions[0]},
+				AudioCodec:         hls.AudioCopy,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+			if _, err := trans.Transcode(context.Background()); err != nil {
+				t.Fatalf("Transcode() unexpected error: %v", err)
+			}
+
+			if i := slices.Index(encodeArgs, "-c:a:0"); i < 0 || encodeArgs[i+1] != tt.wantCodec {
+				t.Errorf("Encode args = %v, want audio codec %s", encodeArgs, tt.wantCodec)
+			}
+			master, _ := os.ReadFile(filepath.Join(outputDir, "master.m3u8"))
+			if !strings.Contains(string(master), `CODECS="avc1.640028,`+tt.wantTag+`"`) {
+				t.Errorf("Master playlist =\n%s\nwant the %s audio codec", master, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestNewInvalidAudioCodec(t *testing.T) {
+	_, err := NewWithDeps(Options{InputPath: "input.mp4", OutputPath: "out", AudioCodec: "dts"},
+		&mockProgressReporter{}, newDiscardLogger(), nil)
+	if err == nil {
+		t.Error("NewWithDeps() expected an error for an unknown audio codec")
+	}
+}
@@ -0,0 +1,94 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/analysis"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// BlankCheckMode selects what happens when the input is entirely black or
+// silent (see Options.BlankCheck).
+type BlankCheckMode string
+
+const (
+	// BlankCheckOff skips the check.
+	BlankCheckOff BlankCheckMode = "off"
+	// BlankCheckWarn logs a warning and encodes the input anyway.
+	BlankCheckWarn BlankCheckMode = "warn"
+	// BlankCheckReject fails the job before encoding, with ErrBlackInput or
+	// ErrSilentInput.
+	BlankCheckReject BlankCheckMode = "reject"
+)
+
+// ParseBlankCheckMode validates a blank check mode name, case-insensitively.
+// The empty string selects BlankCheckOff.
+func ParseBlankCheckMode(value string) (BlankCheckMode, error) {
+	switch mode := BlankCheckMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return BlankCheckOff, nil
+	case BlankCheckOff, BlankCheckWarn, BlankCheckReject:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown blank check mode %q (expected off, warn or reject)", value)
+	}
+}
+
+// checkBlank detects whether the input is entirely black or silent, logging a
+// warning or failing according to Options.BlankCheck. A failure to run the
+// detection is logged and does not fail the job.
+func (t *Transcoder) checkBlank(ctx context.Context, inputPath string) error {
+	if t.options.BlankCheck == BlankCheckOff {
+		return nil
+	}
+	probe, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	var video, audio bool
+	for _, s := range probe.Streams {
+		video = video || s.CodecType == "video"
+		audio = audio || s.CodecType == "audio"
+	}
+	duration := parseFloat(probe.Format.Duration)
+
+	blank, err := analysis.New(analysis.Options{
+		InputFile:    inputPath,
+		FFmpegBinary: t.options.FFmpegBinary,
+		Runner:       t.runner,
+		Logger:       t.logger,
+	}).DetectBlank(ctx, duration, video, audio)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		t.logger.Warn("Failed to check the input for black video and silent audio", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	var sErr *errors.StructuredError
+	switch {
+	case blank.Black:
+		sErr = errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrBlackInput),
+			fmt.Sprintf("%s: every frame of the %.1fs video is black", redactURL(t.options.InputPath), duration), errors.ErrBlackInput)
+	case blank.Silent:
+		sErr = errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrSilentInput),
+			fmt.Sprintf("%s: the %.1fs audio is silent", redactURL(t.options.InputPath), duration), errors.ErrSilentInput)
+	default:
+		return nil
+	}
+
+	if t.options.BlankCheck == BlankCheckReject {
+		return sErr
+	}
+	t.logger.Warn(sErr.Message, "transcoder", map[string]interface{}{
+		"black":  blank.Black,
+		"silent": blank.Silent,
+		"code":   sErr.Code,
+	})
+	return nil
+}
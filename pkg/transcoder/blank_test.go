@@ -0,0 +1,96 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestTranscodeBlankCheck(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		mode     BlankCheckMode
+		stderr   string
+		wantCode int
+		encoded  bool
+	}{
+		{
+			name:     "Black video rejected",
+			mode:     BlankCheckReject,
+			stderr:   "[blackdetect @ 0x1] black_start:0 black_end:59.96 black_duration:59.96\n",
+			wantCode: errors.ErrBlackInput,
+		},
+		{
+			name:     "Silent audio rejected",
+			mode:     BlankCheckReject,
+			stderr:   "[silencedetect @ 0x1] silence_start: 0\n",
+			wantCode: errors.ErrSilentInput,
+		},
+		{
+			name:    "Black video tolerated",
+			mode:    BlankCheckWarn,
+			stderr:  "[blackdetect @ 0x1] black_start:0 black_end:59.96 black_duration:59.96\n",
+			encoded: true,
+		},
+		{
+			name:    "Black intro",
+			mode:    BlankCheckReject,
+			stderr:  "[blackdetect @ 0x1] black_start:0 black_end:2.5 black_duration:2.5\n",
+			encoded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripted := scriptedFFmpeg("", nil)
+			encoded := false
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				if slices.Contains(args, "null") {
+					return ffmpegtest.Result{Stderr: tt.stderr}
+				}
+				if name == "ffmpeg" && len(args) > 1 {
+					encoded = true
+				}
+				return scripted(name, args)
+			}}
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         filepath.Join(dir, tt.name, "video.mp4"),
+				OutputType:         MP4Output,
+				BlankCheck:         tt.mode,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if tt.wantCode != 0 {
+				sErr, ok := err.(*errors.StructuredError)
+				if !ok || sErr.Code != tt.wantCode {
+					t.Fatalf("Transcode() error = %v, want code %d", err, tt.wantCode)
+				}
+			} else if err != nil {
+				t.Fatalf("Transcode() unexpected error: %v", err)
+			}
+			if encoded != tt.encoded {
+				t.Errorf("Encoded = %v, want %v", encoded, tt.encoded)
+			}
+		})
+	}
+
+	if _, err := ParseBlankCheckMode("strict"); err == nil {
+		t.Error("ParseBlankCheckMode() expected an error for an unknown mode")
+	}
+}
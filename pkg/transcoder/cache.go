@@ -0,0 +1,175 @@
+package transcoder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/cache"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// cacheSettings lists the options that affect the encoded output. They are
+// hashed into the cache key together with the input content. The resolutions
+// are the final ones, after the automatic ladder and the upscale and size
+// budget adjustments.
+type cacheSettings struct {
+	OutputType      OutputType            `json:"output_type"`
+	Resolutions     []hls.VideoResolution `json:"resolutions,omitempty"`
+	SegmentDuration int                   `json:"segment_duration,omitempty"`
+	PlaylistType    string                `json:"playlist_type,omitempty"`
+	SharedAudio     bool                  `json:"shared_audio,omitempty"`
+	Version         int                   `json:"version,omitempty"`
+	ByteRange       bool                  `json:"byte_range,omitempty"`
+	MPEGTS          *hls.MPEGTSOptions    `json:"mpegts,omitempty"`
+	SessionData     []hls.SessionData     `json:"session_data,omitempty"`
+	MasterTags      []string              `json:"master_tags,omitempty"`
+	Metadata        bool                  `json:"passthrough_metadata,omitempty"`
+	ExtractCaptions bool                  `json:"extract_captions,omitempty"`
+	CaptionLanguage string                `json:"caption_language,omitempty"`
+	TrickPlay       *trickPlaySettings    `json:"trick_play,omitempty"`
+	ChunkDuration   time.Duration         `json:"chunk_duration,omitempty"`
+	ExtraParams     []string              `json:"extra_params,omitempty"`
+	InputParams     []string              `json:"input_params,omitempty"`
+	AudioCodec      hls.AudioCodec        `json:"audio_codec,omitempty"`
+	Deinterlace     string                `json:"deinterlace,omitempty"`
+	PixelFormat     hls.PixelFormat       `json:"pixel_format,omitempty"`
+	VideoPreset     hls.Preset            `json:"video_preset,omitempty"`
+	VideoTune       hls.Tune              `json:"video_tune,omitempty"`
+	VideoProfile    hls.Profile           `json:"video_profile,omitempty"`
+	VideoLevel      hls.Level             `json:"video_level,omitempty"`
+	Height          int                   `json:"height,omitempty"`
+	Intro           *Slate                `json:"intro,omitempty"`
+	Outro           *Slate                `json:"outro,omitempty"`
+	Deterministic   bool                  `json:"deterministic,omitempty"`
+	Encoder         string                `json:"encoder"`
+}
+
+// trickPlaySettings are the cached settings of the trick-play rendition.
+type trickPlaySettings struct {
+	Interval time.Duration `json:"interval"`
+	Width    int           `json:"width"`
+}
+
+// cacheKey returns the cache key of the output for inputPath, or "" when the
+// cache is disabled or the input cannot be hashed (e.g. a streamed URL).
+func (t *Transcoder) cacheKey(inputPath string) string {
+	if t.cache == nil || (t.options.IsRemoteInput && t.options.StreamFromURL) {
+		return ""
+	}
+	key, err := cache.Key(inputPath, t.outputSettings())
+	if err != nil {
+		t.logger.Warn("Failed to compute the cache key, transcoding without cache", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+	return key
+}
+
+// outputSettings returns the cached settings of the current options.
+func (t *Transcoder) outputSettings() cacheSettings {
+	settings := cacheSettings{
+		OutputType:    t.options.OutputType,
+		ExtraParams:   t.options.FFmpegExtraParams,
+		InputParams:   t.options.FFmpegInputParams,
+		VideoPreset:   t.options.VideoPreset,
+		VideoTune:     t.options.VideoTune,
+		VideoProfile:  t.options.VideoProfile,
+		VideoLevel:    t.options.VideoLevel,
+		Deterministic: t.options.Deterministic,
+		Encoder:       fmt.Sprintf("%T", t.encoder),
+	}
+	// The default codec is left out to keep the keys of existing cache entries
+	if t.options.AudioCodec != hls.AudioAAC {
+		settings.AudioCodec = t.options.AudioCodec
+	}
+	if t.options.Deinterlace != DeinterlaceAuto || t.options.Deinterlacer != hls.DeinterlaceBwdif {
+		settings.Deinterlace = string(t.options.Deinterlace) + ":" + string(t.options.Deinterlacer)
+	}
+	if t.options.PixelFormat != hls.PixelFormatYUV420P {
+		settings.PixelFormat = t.options.PixelFormat
+	}
+	if t.options.OutputType == MP4Output {
+		settings.Height = t.options.MP4Height
+	}
+	if !t.options.Intro.IsZero() {
+		settings.Intro = &t.options.Intro
+	}
+	if !t.options.Outro.IsZero() {
+		settings.Outro = &t.options.Outro
+	}
+	if t.options.OutputType == HLSOutput {
+		settings.Resolutions = t.options.HLSResolutions
+		settings.SegmentDuration = t.options.HLSSegmentDuration
+		settings.PlaylistType = t.options.HLSPlaylistType
+		settings.SharedAudio = t.options.HLSSharedAudio
+		settings.Version = t.options.HLSVersion
+		settings.ByteRange = t.options.HLSByteRange
+		if !t.options.HLSMPEGTS.IsZero() {
+			settings.MPEGTS = &t.options.HLSMPEGTS
+		}
+		settings.SessionData = t.options.HLSSessionData
+		settings.MasterTags = t.options.HLSMasterTags
+		settings.Metadata = t.options.PassthroughMetadata
+		if t.options.ExtractCaptions {
+			// The language is written in the master playlist
+			settings.ExtractCaptions = true
+			settings.CaptionLanguage = t.captionLanguage()
+		}
+		if t.options.TrickPlay {
+			settings.TrickPlay = &trickPlaySettings{
+				Interval: t.options.TrickPlayInterval,
+				Width:    t.options.TrickPlayWidth,
+			}
+		}
+		if t.options.ChunkedEncoding {
+			settings.ChunkDuration = t.options.ChunkDuration
+		}
+	}
+	return settings
+}
+
+// restoreFromCache writes the cached output for key to outputPath, returning the
+// path Transcode returns and whether the output was restored.
+func (t *Transcoder) restoreFromCache(key, outputPath string) (string, bool) {
+	if key == "" || !t.cache.Has(key) {
+		return "", false
+	}
+	result := filepath.Join(outputPath, masterPlaylistName)
+	if t.options.OutputType == MP4Output {
+		result = outputPath
+		if _, err := os.Stat(outputPath); err == nil && !t.options.AllowOverwrite {
+			// Let transcodeToMP4 report the existing file
+			return "", false
+		}
+	}
+
+	if err := t.cache.Restore(key, outputPath); err != nil {
+		t.logger.Warn("Failed to restore the cached output, transcoding", "transcoder", map[string]interface{}{
+			"key":   key,
+			"error": err.Error(),
+		})
+		return "", false
+	}
+	t.logger.Info("Output restored from cache", "transcoder", map[string]interface{}{
+		"key":         key,
+		"output_path": result,
+	})
+	return result, true
+}
+
+// storeInCache adds the output at outputPath to the cache under key. Failures
+// are logged; the transcode itself has succeeded.
+func (t *Transcoder) storeInCache(key, outputPath string) {
+	if key == "" {
+		return
+	}
+	if err := t.cache.Store(key, outputPath); err != nil {
+		t.logger.Warn("Failed to store the output in the cache", "transcoder", map[string]interface{}{
+			"key":   key,
+			"error": err.Error(),
+		})
+	}
+}
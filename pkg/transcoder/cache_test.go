@@ -0,0 +1,107 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestTranscodeCache(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	transcode := func(output string, extraParams ...string) int {
+		t.Helper()
+		encodes := 0
+		scripted := scriptedFFmpeg("", nil)
+		runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+			if name != "ffprobe" && len(args) > 1 {
+				encodes++
+			}
+			return scripted(name, args)
+		}}
+		opts := Options{
+			InputPath:          inputPath,
+			OutputPath:         filepath.Join(dir, output),
+			OutputType:         MP4Output,
+			FFmpegExtraParams:  extraParams,
+			CacheDir:           filepath.Join(dir, "cache"),
+			SkipDiskSpaceCheck: true,
+		}
+		trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		got, err := trans.Transcode(context.Background())
+		if err != nil {
+			t.Fatalf("Transcode() unexpected error: %v", err)
+		}
+		if content, err := os.ReadFile(got); err != nil || string(content) != "encoded" {
+			t.Errorf("Output %s = %q, %v; want the encoded content", got, content, err)
+		}
+		return encodes
+	}
+
+	if encodes := transcode("first.mp4"); encodes != 1 {
+		t.Errorf("First transcode encoded %d times, want 1", encodes)
+	}
+	if encodes := transcode("second.mp4"); encodes != 0 {
+		t.Errorf("Identical transcode encoded %d times, want 0 (restored from cache)", encodes)
+	}
+	if encodes := transcode("third.mp4", "-preset", "slow"); encodes != 1 {
+		t.Errorf("Transcode with different settings encoded %d times, want 1", encodes)
+	}
+}
+
+func TestCacheKeySettings(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	key := func(change func(*Options)) string {
+		t.Helper()
+		opts := Options{
+			InputPath:          inputPath,
+			OutputPath:         filepath.Join(dir, "output"),
+			OutputType:         HLSOutput,
+			CacheDir:           filepath.Join(dir, "cache"),
+			SkipDiskSpaceCheck: true,
+		}
+		change(&opts)
+		trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(&ffmpegtest.Runner{}))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		return trans.cacheKey(inputPath)
+	}
+
+	base := key(func(*Options) {})
+	captions := key(func(o *Options) { o.ExtractCaptions = true })
+	if key(func(o *Options) { o.ExtractCaptions, o.CaptionLanguage = true, "pt" }) == captions {
+		t.Error("cacheKey() did not change with the caption language")
+	}
+	if base == "" {
+		t.Fatal("cacheKey() is empty")
+	}
+	// Cada opção muda a saída codificada e, portanto, a chave
+	for name, change := range map[string]func(*Options){
+		"preset":   func(o *Options) { o.VideoPreset = hls.PresetSlow },
+		"tune":     func(o *Options) { o.VideoTune = hls.TuneFilm },
+		"profile":  func(o *Options) { o.VideoProfile = hls.ProfileMain },
+		"level":    func(o *Options) { o.VideoLevel = "4.1" },
+		"captions": func(o *Options) { o.ExtractCaptions = true },
+		"metadata": func(o *Options) { o.PassthroughMetadata = true },
+	} {
+		if got := key(change); got == base {
+			t.Errorf("%s: cacheKey() did not change", name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+)
+
+// ErrCancelled is the cause of the cancellation of a Transcode call stopped by
+// Transcoder.Cancel. Errors of cancelled calls match both ErrCancelled (when
+// stopped by Cancel) and context.Canceled.
+var ErrCancelled = fmt.Errorf("transcoding cancelled: %w", context.Canceled)
+
+// Cancel stops the running Transcode call: its context is cancelled, the
+// ffmpeg processes are stopped with their process group, the partial output is
+// removed (unless Options.GracefulStop finalized it) and the progress reporter
+// receives a final "cancelled" event. The
+// Transcode call returns an error matching ErrCancelled once the cleanup is
+// done. Cancel returns false when no Transcode call is running. It is safe to
+// call from another goroutine, such as the handler of a cancellation request.
+func (t *Transcoder) Cancel() bool {
+	t.cancelMu.Lock()
+	defer t.cancelMu.Unlock()
+	if t.cancel == nil {
+		return false
+	}
+	t.cancel(ErrCancelled)
+	return true
+}
+
+// startCancellable returns the context of a Transcode call, cancelled by
+// Cancel, and the function ending the call.
+func (t *Transcoder) startCancellable(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t.cancelMu.Lock()
+	t.cancel = cancel
+	t.cancelMu.Unlock()
+	return ctx, func() {
+		t.cancelMu.Lock()
+		t.cancel = nil
+		t.cancelMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelled returns the error of a Transcode call that failed with err because
+// its context was cancelled, by Cancel or by the caller, after removing the
+// output the call created, unless Options.GracefulStop finalized it. It
+// returns nil if the call did not fail or ctx was not cancelled.
+func (t *Transcoder) cancelled(ctx context.Context, err error) error {
+	if err == nil || !stderrors.Is(ctx.Err(), context.Canceled) {
+		return nil
+	}
+	switch {
+	case t.newOutput == "":
+	case t.options.GracefulStop:
+		t.logger.Info("Keeping the output finalized by the graceful stop", "transcoder", map[string]interface{}{
+			"path": t.newOutput,
+		})
+	default:
+		if removeErr := os.RemoveAll(t.newOutput); removeErr != nil {
+			t.logger.Warn("Failed to remove the partial output", "transcoder", map[string]interface{}{
+				"path":  t.newOutput,
+				"error": removeErr.Error(),
+			})
+		}
+	}
+	cause := context.Cause(ctx)
+	t.logger.Info("Transcoding cancelled", "transcoder", map[string]interface{}{
+		"cause": cause.Error(),
+	})
+	return fmt.Errorf("%w: %v", cause, err)
+}
@@ -0,0 +1,162 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+func TestTranscodeCancel(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputPath := filepath.Join(dir, "hls")
+
+	var trans *Transcoder
+	scripted := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffmpeg" && len(args) > 1 {
+			// Cancel the job while ffmpeg writes the first segment
+			os.WriteFile(filepath.Join(outputPath, "stream_0.ts"), []byte("partial"), 0644)
+			if !trans.Cancel() {
+				t.Error("Cancel() = false during Transcode")
+			}
+			return ffmpegtest.Result{Err: stderrors.New("signal: interrupt")}
+		}
+		return scripted(name, args)
+	}}
+	reporter := progress.NewReporter(progress.WithBarWriter(io.Discard))
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputType:         HLSOutput,
+		SkipDiskSpaceCheck: true,
+	}
+	var err error
+	trans, err = NewWithDeps(opts, reporter, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	_, err = trans.Transcode(context.Background())
+	if !stderrors.Is(err, ErrCancelled) || !stderrors.Is(err, context.Canceled) || errors.ExitCode(err) != errors.ExitInterrupted {
+		t.Errorf("Transcode() error = %v, want ErrCancelled", err)
+	}
+	if _, statErr := os.Stat(outputPath); !os.IsNotExist(statErr) {
+		t.Errorf("The partial output was not removed: %v", statErr)
+	}
+	if event := reporter.Snapshot(); event.Status != "cancelled" || event.Error == nil {
+		t.Errorf("Final progress event = %+v, want cancelled", event)
+	}
+	if trans.Cancel() {
+		t.Error("Cancel() = true after Transcode returned")
+	}
+}
+
+func TestTranscodeCancelKeepsExistingOutput(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputPath := filepath.Join(dir, "hls")
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scripted := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffmpeg" && len(args) > 1 {
+			cancel()
+			return ffmpegtest.Result{Err: stderrors.New("signal: interrupt")}
+		}
+		return scripted(name, args)
+	}}
+	mockReporter := &mockProgressReporter{}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputType:         HLSOutput,
+		AllowOverwrite:     true,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, mockReporter, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	_, err = trans.Transcode(ctx)
+	if !stderrors.Is(err, context.Canceled) || stderrors.Is(err, ErrCancelled) {
+		t.Errorf("Transcode() error = %v, want context.Canceled", err)
+	}
+	if _, statErr := os.Stat(outputPath); statErr != nil {
+		t.Errorf("The output directory existing before the call was removed: %v", statErr)
+	}
+	// Reporters that cannot report cancellations fail instead
+	if mockReporter.failErr == nil {
+		t.Error("The progress reporter was not notified of the cancellation")
+	}
+}
+
+func TestTranscodeGracefulStopKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputPath := filepath.Join(dir, "hls")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scripted := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffmpeg" && len(args) > 1 {
+			// ffmpeg finalizes the segment and the playlist when asked to quit
+			os.WriteFile(filepath.Join(outputPath, "stream_0", "data000.ts"), []byte("segment"), 0644)
+			cancel()
+			return ffmpegtest.Result{Err: context.Canceled}
+		}
+		return scripted(name, args)
+	}}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputType:         HLSOutput,
+		SkipDiskSpaceCheck: true,
+		GracefulStop:       true,
+		StopGracePeriod:    time.Second,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	if _, err = trans.Transcode(ctx); !stderrors.Is(err, context.Canceled) {
+		t.Errorf("Transcode() error = %v, want context.Canceled", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(outputPath, "stream_0", "data000.ts")); statErr != nil {
+		t.Errorf("The finalized output was removed: %v", statErr)
+	}
+
+	// The default runner stops ffmpeg with "q"
+	trans, err = NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if r, ok := trans.runner.(ffmpeg.ExecRunner); !ok || !r.GracefulStop || r.GracePeriod != time.Second {
+		t.Errorf("Default runner = %#v, want a graceful stop after 1s", trans.runner)
+	}
+}
@@ -0,0 +1,162 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// captionsDir is the directory of the WebVTT caption rendition in HLS outputs.
+const captionsDir = "subs"
+
+// captionsFile is the name of the WebVTT file holding the extracted captions.
+const captionsFile = "captions.vtt"
+
+// languageTagPattern matches the BCP 47 language tags accepted as
+// Options.CaptionLanguage, e.g. "en" or "pt-BR".
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// limitLowFrameRates halves the high frame rates of the low renditions (see
+// hls.LimitLowFrameRates), unless the video carries closed captions: the
+// captions are stored in the video frames, so dropping frames drops captions.
+func limitLowFrameRates(resolutions []hls.VideoResolution, info *VideoInfo) []hls.VideoResolution {
+	if info.Captions {
+		return resolutions
+	}
+	return hls.LimitLowFrameRates(resolutions, info.FrameRate)
+}
+
+// inputHasCaptions reports whether the video of the input carries CEA-608/708
+// closed captions, which the encode must then preserve.
+func (t *Transcoder) inputHasCaptions(ctx context.Context, inputPath string) bool {
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		t.logger.Warn("Failed to detect closed captions, assuming there are none", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if info.Captions {
+		t.logger.Info("Closed captions detected, preserving them", "transcoder", map[string]interface{}{
+			"input": inputPath,
+		})
+	}
+	return info.Captions
+}
+
+// extractCaptions writes the closed captions of the input to a WebVTT subtitle
+// rendition of the HLS output at outputDir and declares it in the master
+// playlist (see Options.ExtractCaptions).
+func (t *Transcoder) extractCaptions(ctx context.Context, inputPath, outputDir, masterPath string) error {
+	dir := filepath.Join(outputDir, captionsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create the captions directory", 15)
+	}
+	vttPath := filepath.Join(dir, captionsFile)
+
+	// The captions are decoded from the video frames by the movie source
+	captionsCtx, cancel := withStageTimeout(ctx, t.options.EncodeTimeout)
+	defer cancel()
+	_, err := t.runner.Output(captionsCtx, t.options.FFmpegBinary,
+		"-v", "error",
+		"-f", "lavfi",
+		"-i", "movie="+escapeFilterPath(inputPath)+"[out0+subcc]",
+		"-map", "0:s:0",
+		"-c:s", "webvtt",
+		"-y", vttPath,
+	)
+	if err != nil {
+		if stageTimedOut(captionsCtx) {
+			return stageTimeoutError(err, "caption extraction", t.options.EncodeTimeout, errors.ErrEncodeTimeout, errors.TranscodingError)
+		}
+		return errors.Wrap(err, errors.HLSError, "Failed to extract the closed captions", 19)
+	}
+	vtt, err := os.ReadFile(vttPath)
+	if err != nil {
+		return errors.Wrap(err, errors.HLSError, "Failed to extract the closed captions", 19)
+	}
+	if !bytes.Contains(vtt, []byte("-->")) {
+		t.logger.Warn("The closed captions of the input are empty, skipping the subtitle rendition", "transcoder", nil)
+		return os.RemoveAll(dir)
+	}
+
+	// Align the cues with the timestamps of the video segments
+	if offset, ok := t.segmentTimestampOffset(ctx, outputDir); ok {
+		vtt = bytes.Replace(vtt, []byte("WEBVTT\n"),
+			[]byte(fmt.Sprintf("WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n", offset)), 1)
+		if err := os.WriteFile(vttPath, vtt, 0644); err != nil {
+			return errors.Wrap(err, errors.HLSError, "Failed to write the closed captions", 19)
+		}
+	}
+
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		return wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrInvalidFileFormat)
+	}
+	if err := hls.WriteSubtitlePlaylist(filepath.Join(dir, "playlist.m3u8"), captionsFile, info.Duration); err != nil {
+		return errors.Wrap(err, errors.HLSError, "Failed to write the subtitle playlist", 19)
+	}
+	rendition := hls.SubtitleRendition{
+		Name:     fmt.Sprintf("Closed captions (%s)", t.captionLanguage()),
+		Language: t.captionLanguage(),
+		URI:      captionsDir + "/playlist.m3u8",
+	}
+	if err := hls.AddSubtitles(masterPath, rendition); err != nil {
+		return errors.Wrap(err, errors.HLSError, "Failed to add the subtitles to the master playlist", 19)
+	}
+
+	t.logger.Info("Closed captions extracted", "transcoder", map[string]interface{}{
+		"path": vttPath,
+	})
+	return nil
+}
+
+// captionLanguage returns Options.CaptionLanguage or its default.
+func (t *Transcoder) captionLanguage() string {
+	if t.options.CaptionLanguage == "" {
+		return hls.DefaultCaptionLanguage
+	}
+	return t.options.CaptionLanguage
+}
+
+// segmentTimestampOffset returns the MPEG-TS timestamp (90 kHz) of the start of
+// the first video segment, to which WebVTT cue times are mapped.
+func (t *Transcoder) segmentTimestampOffset(ctx context.Context, outputDir string) (int64, bool) {
+	segments, _ := filepath.Glob(filepath.Join(outputDir, "stream_0", "*.ts"))
+	if len(segments) == 0 {
+		return 0, false
+	}
+	// Glob returns the segments sorted, so the first one starts the stream
+	output, err := runFFprobe(ctx, t.runner, segments[0])
+	if err != nil {
+		t.logger.Warn("Failed to read the start of the video segments, captions may be out of sync", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, false
+	}
+	return int64(math.Round(parseFloat(output.Format.StartTime) * 90000)), true
+}
+
+// escapeFilterPath escapes path for use as the file name of an ffmpeg movie
+// source: once for the filter options, then once for the filter graph.
+func escapeFilterPath(path string) string {
+	escape := func(s, special string) string {
+		var b strings.Builder
+		for _, r := range s {
+			if strings.ContainsRune(special, r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	return escape(escape(path, `\':=`), `\'[],;`)
+}
@@ -0,0 +1,114 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestEscapeFilterPath(t *testing.T) {
+	got := escapeFilterPath(`/videos/it's [live], 10:00.ts`)
+	want := `/videos/it\\\'s \[live\]\, 10\\:00.ts`
+	if got != want {
+		t.Errorf("escapeFilterPath() = %s, want %s", got, want)
+	}
+}
+
+func TestLimitLowFrameRatesKeepsCaptionedFrames(t *testing.T) {
+	resolutions := []hls.VideoResolution{{Width: 640, Height: 360, VideoBitrate: "800k"}}
+	if got := limitLowFrameRates(resolutions, &VideoInfo{FrameRate: 59.94, Captions: true}); got[0].FrameRate != 0 {
+		t.Errorf("limitLowFrameRates() with captions = %v fps, want the input frame rate", got[0].FrameRate)
+	}
+	if got := limitLowFrameRates(resolutions, &VideoInfo{FrameRate: 59.94}); got[0].FrameRate == 0 {
+		t.Error("limitLowFrameRates() without captions should halve the frame rate")
+	}
+}
+
+// captionedProbeJSON describes a transport stream whose video carries CEA-608 captions.
+const captionedProbeJSON = `{
+	"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1280, "height": 720, "r_frame_rate": "30/1", "closed_captions": 1},
+		{"index": 1, "codec_type": "audio", "codec_name": "aac"}
+	],
+	"format": {"format_name": "mpegts", "start_time": "1.400000", "duration": "12.500000"}
+}`
+
+func TestTranscodeExtractCaptions(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.ts")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputDir := filepath.Join(dir, "hls")
+
+	var encodeArgs []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		switch {
+		case name == "ffprobe" && strings.HasSuffix(args[len(args)-1], ".ts") && args[len(args)-1] != inputPath:
+			return ffmpegtest.Result{Stdout: `{"format": {"start_time": "1.400000"}}`}
+		case name == "ffprobe":
+			return ffmpegtest.Result{Stdout: captionedProbeJSON}
+		case len(args) == 1:
+			return scriptedFFmpeg("", nil)(name, args)
+		case slices.Contains(args, "lavfi"):
+			os.WriteFile(args[len(args)-1], []byte("WEBVTT\n\n00:00.500 --> 00:02.000\nHELLO\n"), 0644)
+			return ffmpegtest.Result{}
+		}
+		encodeArgs = args
+		os.WriteFile(filepath.Join(outputDir, "master.m3u8"),
+			[]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,CLOSED-CAPTIONS=\"cc\"\nstream_0/playlist.m3u8\n"), 0644)
+		os.MkdirAll(filepath.Join(outputDir, "stream_0"), 0755)
+		os.WriteFile(filepath.Join(outputDir, "stream_0", "data000.ts"), []byte("segment"), 0644)
+		return ffmpegtest.Result{}
+	}}
+
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputDir,
+		OutputType:         HLSOutput,
+		HLSResolutions:     []hls.VideoResolution{hls.DefaultResolutions[0]},
+		ExtractCaptions:    true,
+		CaptionLanguage:    "pt-BR",
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	if !slices.Contains(encodeArgs, "-a53cc") || !slices.Contains(encodeArgs, "ccgroup:cc,instreamid:CC1,language:pt-BR") {
+		t.Errorf("Encode should preserve and declare the captions: %v", encodeArgs)
+	}
+	vtt, _ := os.ReadFile(filepath.Join(outputDir, "subs", "captions.vtt"))
+	if !strings.HasPrefix(string(vtt), "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000\n") {
+		t.Errorf("captions.vtt =\n%s\nwant a timestamp map to the first segment", vtt)
+	}
+	playlist, _ := os.ReadFile(filepath.Join(outputDir, "subs", "playlist.m3u8"))
+	if !strings.Contains(string(playlist), "#EXTINF:12.500000,\ncaptions.vtt\n") {
+		t.Errorf("Subtitle playlist =\n%s", playlist)
+	}
+	master, _ := os.ReadFile(filepath.Join(outputDir, "master.m3u8"))
+	if !strings.Contains(string(master), `LANGUAGE="pt-BR"`) || !strings.Contains(string(master), `CLOSED-CAPTIONS="cc",SUBTITLES="subs"`) {
+		t.Errorf("Master playlist =\n%s", master)
+	}
+}
+
+func TestNewInvalidCaptionLanguage(t *testing.T) {
+	_, err := NewWithDeps(Options{
+		InputPath:       "input.mp4",
+		OutputPath:      "out",
+		CaptionLanguage: `en",X="1`,
+	}, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err == nil {
+		t.Error("NewWithDeps() expected an error for an invalid caption language")
+	}
+}
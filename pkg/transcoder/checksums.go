@@ -0,0 +1,51 @@
+package transcoder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumFileName is the checksum file written into HLS output directories
+// when Options.WriteChecksums is set. MP4 outputs get a "<output>.sha256" file.
+const ChecksumFileName = "SHA256SUMS"
+
+// checksumPath returns the path of the checksum file for the output.
+func (t *Transcoder) checksumPath() string {
+	if t.options.OutputType == MP4Output {
+		return t.options.OutputPath + ".sha256"
+	}
+	return filepath.Join(t.options.OutputPath, ChecksumFileName)
+}
+
+// writeChecksums writes the SHA-256 of every produced file in the format of
+// sha256sum, so that the output can be verified with "sha256sum -c".
+// Errors are logged as warnings since the main output was already produced.
+func (t *Transcoder) writeChecksums() {
+	checksumPath := t.checksumPath()
+	files, err := t.outputFiles(filepath.Dir(checksumPath), checksumPath)
+	if err != nil {
+		t.logger.Warn("Failed to checksum the output files", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var content strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&content, "%s  %s\n", file.SHA256, file.Path)
+	}
+	if err := os.WriteFile(checksumPath, []byte(content.String()), 0644); err != nil {
+		t.logger.Warn("Failed to write checksum file", "transcoder", map[string]interface{}{
+			"path":  checksumPath,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	t.logger.Info("Checksum file created", "transcoder", map[string]interface{}{
+		"checksums": checksumPath,
+		"files":     len(files),
+	})
+}
@@ -0,0 +1,77 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestWriteChecksums(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	outputPath := filepath.Join(dir, "output.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputType:         MP4Output,
+		WriteChecksums:     true,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath + ".sha256")
+	if err != nil {
+		t.Fatalf("Failed to read checksum file: %v", err)
+	}
+	if want := sha256Hex("encoded") + "  output.mp4\n"; string(content) != want {
+		t.Errorf("Checksum file = %q, want %q", content, want)
+	}
+}
+
+func TestOutputFilesHLS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"master.m3u8":            "#EXTM3U",
+		"stream_0/playlist.m3u8": "#EXTM3U",
+		"stream_0/data000.ts":    "segment",
+		ChecksumFileName:         "stale",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trans := &Transcoder{options: Options{OutputPath: dir, OutputType: HLSOutput}}
+	got, err := trans.outputFiles(dir, trans.checksumPath())
+	if err != nil {
+		t.Fatalf("outputFiles() unexpected error: %v", err)
+	}
+	want := []string{"master.m3u8", "stream_0/data000.ts", "stream_0/playlist.m3u8"}
+	if len(got) != len(want) {
+		t.Fatalf("outputFiles() = %+v, want %v", got, want)
+	}
+	for i, file := range got {
+		if file.Path != want[i] || file.SHA256 != sha256Hex(files[want[i]]) {
+			t.Errorf("outputFiles()[%d] = %+v, want %s", i, file, want[i])
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// Defaults of the chunked encoding (see Options.ChunkedEncoding).
+const (
+	DefaultChunkDuration    = time.Minute
+	DefaultChunkConcurrency = 4
+)
+
+// chunksDir is the working directory of the chunked encoding in HLS outputs,
+// removed once the output is packaged.
+const chunksDir = ".chunks"
+
+// validateChunkedEncoding checks the chunked encoding options, setting their defaults.
+func validateChunkedEncoding(options *Options, p *optionProblems) {
+	if !options.ChunkedEncoding {
+		return
+	}
+	if options.OutputType != HLSOutput {
+		p.add("ChunkedEncoding", errors.New(errors.ValidationError, "Invalid chunked encoding",
+			"Chunked encoding is only supported for HLS output", 4))
+	}
+	if options.ChunkDuration == 0 {
+		options.ChunkDuration = DefaultChunkDuration
+	}
+	if options.ChunkConcurrency == 0 {
+		options.ChunkConcurrency = DefaultChunkConcurrency
+	}
+	if segment := time.Duration(chunkSegmentDuration(*options)) * time.Second; options.ChunkDuration < segment {
+		p.add("ChunkDuration", errors.New(errors.ValidationError, "Invalid chunk duration",
+			fmt.Sprintf("Chunks must last at least one segment (%s), got %s", segment, options.ChunkDuration), 4))
+	}
+	if options.ChunkConcurrency < 0 {
+		p.add("ChunkConcurrency", errors.New(errors.ValidationError, "Invalid chunk concurrency",
+			fmt.Sprintf("The chunk concurrency must not be negative, got %d", options.ChunkConcurrency), 4))
+	}
+}
+
+// chunkSegmentDuration returns the HLS segment duration in seconds, with the
+// default of hls.Options.
+func chunkSegmentDuration(options Options) int {
+	if options.HLSSegmentDuration > 0 {
+		return options.HLSSegmentDuration
+	}
+	return 10
+}
+
+// encodeChunks splits the video of the input at keyframes into chunks, in
+// workDir, and encodes each chunk in each of resolutions (hls.DefaultResolutions
+// if empty), keeping the closed captions if captions is set, deinterlacing the
+// video with deinterlace if set and converting it according to color,
+// ChunkConcurrency chunks at a time. It returns the ffconcat lists stitching
+// the chunks of each resolution, to be segmented without re-encoding.
+func (t *Transcoder) encodeChunks(ctx context.Context, inputPath, workDir string, resolutions []hls.VideoResolution, captions bool, deinterlace hls.Deinterlacer, color hls.ColorSettings) ([]string, error) {
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, errors.Wrap(err, errors.SystemError, "Failed to create the chunk directory", 15)
+	}
+	chunkCtx, cancel := withStageTimeout(ctx, t.options.EncodeTimeout)
+	defer cancel()
+
+	// Dividir o vídeo nos quadros-chave, sem recodificar
+	if _, err := t.runner.Output(chunkCtx, t.options.FFmpegBinary,
+		"-v", "error",
+		"-i", inputPath,
+		"-map", "0:v:0", "-an", "-sn", "-dn",
+		"-c", "copy",
+		"-f", "segment",
+		"-segment_time", strconv.FormatFloat(t.options.ChunkDuration.Seconds(), 'f', -1, 64),
+		"-reset_timestamps", "1",
+		"-y", filepath.Join(workDir, "source%05d.mkv"),
+	); err != nil {
+		if stageTimedOut(chunkCtx) {
+			return nil, stageTimeoutError(err, "chunk encoding", t.options.EncodeTimeout, errors.ErrEncodeTimeout, errors.TranscodingError)
+		}
+		return nil, errors.Wrap(err, errors.TranscodingError, "Failed to split the input into chunks", 13)
+	}
+	sources, err := filepath.Glob(filepath.Join(workDir, "source*.mkv"))
+	if err != nil || len(sources) == 0 {
+		return nil, errors.New(errors.TranscodingError, "Failed to split the input into chunks",
+			"The input video produced no chunk", 13)
+	}
+
+	t.logger.Info("Encoding chunks in parallel", "transcoder", map[string]interface{}{
+		"chunks":      len(sources),
+		"concurrency": t.options.ChunkConcurrency,
+	})
+	if t.progRep != nil {
+		t.progRep.Start(int64(len(sources)))
+	}
+
+	if len(resolutions) == 0 {
+		resolutions = hls.DefaultResolutions
+	}
+	generator := hls.New(hls.Options{
+		Resolutions:     resolutions,
+		SegmentDuration: t.options.HLSSegmentDuration,
+		ClosedCaptions:  captions,
+		Deinterlace:     deinterlace,
+		Color:           color,
+		Deterministic:   t.options.Deterministic,
+		EncoderSettings: t.options.encoderSettings(),
+	})
+	outputs := make([][]string, len(resolutions))
+	for i := range resolutions {
+		outputs[i] = make([]string, len(sources))
+		for n := range sources {
+			outputs[i][n] = fmt.Sprintf("r%d_%05d.ts", i, n)
+		}
+	}
+
+	// Codificar as partes em paralelo, parando na primeira falha
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		encoded  int
+	)
+	slots := make(chan struct{}, t.options.ChunkConcurrency)
+	for n, source := range sources {
+		chunkOutputs := make([]string, len(resolutions))
+		for i := range resolutions {
+			chunkOutputs[i] = filepath.Join(workDir, outputs[i][n])
+		}
+		args := generator.ChunkArgs(source, chunkOutputs)
+
+		select {
+		case slots <- struct{}{}:
+		case <-chunkCtx.Done():
+		}
+		if chunkCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(n int, args []string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			_, err := t.runner.Output(chunkCtx, t.options.FFmpegBinary, args...)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrap(err, errors.TranscodingError, fmt.Sprintf("Failed to encode chunk %d", n), 13)
+					cancel()
+				}
+				return
+			}
+			encoded++
+			if t.progRep != nil {
+				t.progRep.Increment("transcoding", fmt.Sprintf("Encoded chunk %d of %d", encoded, len(sources)))
+			}
+		}(n, args)
+	}
+	wg.Wait()
+	if stageTimedOut(chunkCtx) {
+		return nil, stageTimeoutError(chunkCtx.Err(), "chunk encoding", t.options.EncodeTimeout, errors.ErrEncodeTimeout, errors.TranscodingError)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Listar as partes de cada resolução, na ordem, para o empacotamento
+	lists := make([]string, len(resolutions))
+	for i := range resolutions {
+		lists[i] = filepath.Join(workDir, fmt.Sprintf("rendition_%d.ffconcat", i))
+		if err := hls.WriteConcatList(lists[i], outputs[i]); err != nil {
+			return nil, errors.Wrap(err, errors.SystemError, "Failed to write the chunk list", 15)
+		}
+	}
+	return lists, nil
+}
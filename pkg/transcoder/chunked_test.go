@@ -0,0 +1,149 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// chunkedFFmpeg simulates the commands of the chunked encoding: the split of
+// the input into chunks parts, their encodes (failing with chunkErr) and the
+// packaging.
+func chunkedFFmpeg(outputDir string, chunks int, chunkErr error) func(name string, args []string) ffmpegtest.Result {
+	return func(name string, args []string) ffmpegtest.Result {
+		switch {
+		case name == "ffprobe" || len(args) == 1:
+			return scriptedFFmpeg("", nil)(name, args)
+		case slices.Contains(args, "segment"):
+			pattern := args[len(args)-1]
+			for n := 0; n < chunks; n++ {
+				os.WriteFile(fmt.Sprintf(pattern, n), []byte("chunk"), 0644)
+			}
+		case slices.Contains(args, "-force_key_frames"):
+			if chunkErr != nil {
+				return ffmpegtest.Result{Err: chunkErr}
+			}
+			for i, arg := range args {
+				if arg == "-y" {
+					os.WriteFile(args[i+1], []byte("encoded"), 0644)
+				}
+			}
+		default:
+			os.WriteFile(filepath.Join(outputDir, "master.m3u8"),
+				[]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n"), 0644)
+		}
+		return ffmpegtest.Result{}
+	}
+}
+
+func TestTranscodeChunked(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputDir := filepath.Join(dir, "hls")
+	runner := &ffmpegtest.Runner{Handler: chunkedFFmpeg(outputDir, 3, nil)}
+
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputDir,
+		OutputType:         HLSOutput,
+		HLSResolutions:     hls.DefaultResolutions[:2],
+		ChunkedEncoding:    true,
+		ChunkDuration:      30 * time.Second,
+		ChunkConcurrency:   2,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	var split, packaging []string
+	chunkEncodes := 0
+	for _, call := range runner.Calls() {
+		switch {
+		case slices.Contains(call.Args, "segment"):
+			split = call.Args
+		case slices.Contains(call.Args, "-force_key_frames"):
+			chunkEncodes++
+		case slices.Contains(call.Args, "concat"):
+			packaging = call.Args
+		}
+	}
+	if !slices.Contains(split, "30") || !slices.Contains(split, "copy") {
+		t.Errorf("Split args = %v", split)
+	}
+	if chunkEncodes != 3 {
+		t.Errorf("Chunk encodes = %d, want 3", chunkEncodes)
+	}
+	if !slices.Contains(packaging, "-c:v:1") || slices.Contains(packaging, "libx264") ||
+		!slices.Contains(packaging, "2:a:0") {
+		t.Errorf("Packaging args = %v", packaging)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, chunksDir)); !os.IsNotExist(err) {
+		t.Errorf("Chunk directory not removed: %v", err)
+	}
+}
+
+func TestTranscodeChunkedFailure(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputDir := filepath.Join(dir, "hls")
+	var packaged atomic.Bool
+	handler := chunkedFFmpeg(outputDir, 5, stderrors.New("exit status 1"))
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if slices.Contains(args, "concat") {
+			packaged.Store(true)
+		}
+		return handler(name, args)
+	}}
+
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputDir,
+		OutputType:         HLSOutput,
+		ChunkedEncoding:    true,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err == nil || !strings.Contains(err.Error(), "Failed to encode chunk") {
+		t.Errorf("Transcode() error = %v, want a chunk encoding error", err)
+	}
+	if packaged.Load() {
+		t.Error("The output was packaged despite the failed chunk")
+	}
+}
+
+func TestNewInvalidChunkedEncoding(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"MP4 output":  {ChunkedEncoding: true, OutputType: MP4Output},
+		"duration":    {ChunkedEncoding: true, ChunkDuration: 5 * time.Second, HLSSegmentDuration: 6},
+		"concurrency": {ChunkedEncoding: true, ChunkConcurrency: -1},
+	} {
+		opts.InputPath, opts.OutputPath = "input.mp4", "out"
+		if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+			t.Errorf("%s: NewWithDeps() expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package transcoder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/heyjunin/HLSpresso/pkg/cache"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// jobSettings identifies the output of a job before it runs: the cached
+// settings plus the options that resolve the ladder during Transcode and the
+// files written besides the output.
+type jobSettings struct {
+	cacheSettings
+	AutoResolutions        bool            `json:"auto_resolutions,omitempty"`
+	AutoScalePolicy        hls.ScalePolicy `json:"auto_scale_policy,omitempty"`
+	AutoDimensionAlignment int             `json:"auto_dimension_alignment,omitempty"`
+	DisallowUpscale        bool            `json:"disallow_upscale,omitempty"`
+	MaxOutputSizeBytes     int64           `json:"max_output_size_bytes,omitempty"`
+	Outputs                []OutputSpec    `json:"outputs,omitempty"`
+	Mezzanines             []OutputType    `json:"mezzanines,omitempty"`
+	Preview                *previewKey     `json:"preview,omitempty"`
+	AnalyzeMedia           bool            `json:"analyze_media,omitempty"`
+	WriteManifest          bool            `json:"write_manifest,omitempty"`
+	WriteChecksums         bool            `json:"write_checksums,omitempty"`
+	// EncryptionKey is the SHA-256 of the output encryption key, if any.
+	EncryptionKey string `json:"encryption_key,omitempty"`
+	// Input is the URL of remote inputs, which are not hashed.
+	Input string `json:"input,omitempty"`
+}
+
+// previewKey are the job settings of the preview clip.
+type previewKey struct {
+	FileName string  `json:"file_name"`
+	Duration float64 `json:"duration"`
+	Scenes   int     `json:"scenes"`
+}
+
+// JobKey returns a key identifying the job: the SHA-256 of the content of a
+// local input (or the URL of a remote one) and of the options that affect the
+// output. Two transcoders with the same key produce the same output, wherever
+// they write it. It must be called before Transcode.
+func (t *Transcoder) JobKey() (string, error) {
+	settings := jobSettings{
+		cacheSettings:          t.outputSettings(),
+		AutoResolutions:        t.options.UseAutoResolutions,
+		AutoScalePolicy:        t.options.AutoScalePolicy,
+		AutoDimensionAlignment: t.options.AutoDimensionAlignment,
+		DisallowUpscale:        t.options.DisallowUpscale,
+		MaxOutputSizeBytes:     t.options.MaxOutputSizeBytes,
+		Outputs:                t.options.Outputs,
+		Mezzanines:             t.options.Mezzanines,
+		AnalyzeMedia:           t.options.AnalyzeMedia,
+		WriteManifest:          t.options.WriteManifest,
+		WriteChecksums:         t.options.WriteChecksums,
+	}
+	if t.options.GeneratePreview {
+		settings.Preview = &previewKey{
+			FileName: t.options.PreviewFileName,
+			Duration: t.options.PreviewDuration,
+			Scenes:   t.options.PreviewScenes,
+		}
+	}
+	if len(t.options.EncryptionKey) > 0 {
+		sum := sha256.Sum256(t.options.EncryptionKey)
+		settings.EncryptionKey = hex.EncodeToString(sum[:])
+	}
+	if !t.options.IsRemoteInput {
+		return cache.Key(t.options.InputPath, settings)
+	}
+
+	settings.Input = t.options.InputPath
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Coalescer de-duplicates concurrent jobs, e.g. when a client retries a
+// submission to a job queue: a Transcode call for a job with the same key (see
+// Transcoder.JobKey) as one in progress subscribes to that job and returns its
+// result instead of encoding the input again. The zero value is ready to use,
+// and a Coalescer is safe for concurrent use.
+type Coalescer struct {
+	mu   sync.Mutex
+	jobs map[string]*coalescedJob
+}
+
+// coalescedJob is a job in progress and the callers waiting for it.
+type coalescedJob struct {
+	done        chan struct{}
+	result      string
+	err         error
+	subscribers int
+}
+
+// Transcode runs t.Transcode, unless a job with the same key is in progress,
+// in which case it waits for that job and returns its result, shared being
+// true. The job runs with the context of the caller that started it; a
+// subscriber whose ctx is done returns ctx.Err() without stopping the job.
+// Inputs that cannot be hashed, and jobs with side effects of their own (an
+// upload or hooks, see Transcoder.sideEffects), are transcoded without
+// de-duplication.
+func (c *Coalescer) Transcode(ctx context.Context, t *Transcoder) (result string, shared bool, err error) {
+	if t.sideEffects() {
+		result, err = t.Transcode(ctx)
+		return result, false, err
+	}
+	key, err := t.JobKey()
+	if err != nil {
+		t.logger.Warn("Failed to compute the job key, transcoding without de-duplication", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result, err = t.Transcode(ctx)
+		return result, false, err
+	}
+
+	c.mu.Lock()
+	if job, ok := c.jobs[key]; ok {
+		job.subscribers++
+		c.mu.Unlock()
+		t.logger.Info("Duplicate job, waiting for the identical job in progress", "transcoder", map[string]interface{}{
+			"job_id": t.options.JobID,
+			"key":    key,
+		})
+		select {
+		case <-job.done:
+			return job.result, true, job.err
+		case <-ctx.Done():
+			return "", true, ctx.Err()
+		}
+	}
+	if c.jobs == nil {
+		c.jobs = make(map[string]*coalescedJob)
+	}
+	job := &coalescedJob{done: make(chan struct{}), subscribers: 1}
+	c.jobs[key] = job
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.jobs, key)
+		subscribers := job.subscribers
+		c.mu.Unlock()
+		close(job.done)
+		if subscribers > 1 {
+			t.logger.Info("Job result shared with duplicate submissions", "transcoder", map[string]interface{}{
+				"job_id":      t.options.JobID,
+				"subscribers": subscribers,
+			})
+		}
+	}()
+	job.result, job.err = t.Transcode(ctx)
+	return job.result, false, job.err
+}
+
+// sideEffects reports whether the job does more than write its output, so
+// that sharing the result of another job would skip them: it uploads the
+// output or runs hooks.
+func (t *Transcoder) sideEffects() bool {
+	return t.uploader != nil || t.options.PreHook != nil || t.options.PostHook != nil
+}
+
+// InProgress returns the number of distinct jobs in progress.
+func (c *Coalescer) InProgress() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.jobs)
+}
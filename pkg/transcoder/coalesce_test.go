@@ -0,0 +1,203 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestCoalescerSharesDuplicateJobs(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	var encodes atomic.Int32
+	release := make(chan struct{})
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name != "ffprobe" && len(args) > 1 {
+			encodes.Add(1)
+			<-release
+		}
+		return scriptedFFmpeg("", nil)(name, args)
+	}}
+	newJob := func(output string) *Transcoder {
+		trans, err := NewWithDeps(Options{
+			InputPath:          inputPath,
+			OutputPath:         filepath.Join(dir, output),
+			OutputType:         MP4Output,
+			SkipDiskSpaceCheck: true,
+		}, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		return trans
+	}
+
+	var c Coalescer
+	first, duplicate := newJob("first.mp4"), newJob("retry.mp4")
+	key, err := first.JobKey()
+	if err != nil {
+		t.Fatalf("JobKey() error: %v", err)
+	}
+	if other, _ := duplicate.JobKey(); other != key {
+		t.Fatalf("JobKey() = %s and %s, want the same key for the same input and options", key, other)
+	}
+
+	type outcome struct {
+		result string
+		shared bool
+		err    error
+	}
+	outcomes := make([]outcome, 2)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outcomes[0].result, outcomes[0].shared, outcomes[0].err = c.Transcode(context.Background(), first)
+	}()
+	for encodes.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outcomes[1].result, outcomes[1].shared, outcomes[1].err = c.Transcode(context.Background(), duplicate)
+	}()
+	for {
+		c.mu.Lock()
+		subscribers := c.jobs[key].subscribers
+		c.mu.Unlock()
+		if subscribers == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if encodes.Load() != 1 {
+		t.Errorf("Encodes = %d, want 1", encodes.Load())
+	}
+	want := filepath.Join(dir, "first.mp4")
+	for i, o := range outcomes {
+		if o.err != nil || o.result != want || o.shared != (i == 1) {
+			t.Errorf("Caller %d got %+v, want result %s (shared %v)", i, o, want, i == 1)
+		}
+	}
+	if c.InProgress() != 0 {
+		t.Errorf("InProgress() = %d after the job finished", c.InProgress())
+	}
+}
+
+func TestJobKeyDependsOnOptions(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := func(opts Options) string {
+		opts.InputPath, opts.OutputPath = inputPath, filepath.Join(dir, "out")
+		trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		key, err := trans.JobKey()
+		if err != nil {
+			t.Fatalf("JobKey() error: %v", err)
+		}
+		return key
+	}
+
+	base := key(Options{})
+	for name, opts := range map[string]Options{
+		"output type":     {OutputType: MP4Output},
+		"segment length":  {HLSSegmentDuration: 4},
+		"auto ladder":     {UseAutoResolutions: true},
+		"encryption key":  {EncryptionKey: make([]byte, 32)},
+		"max output size": {MaxOutputSizeBytes: 1 << 20},
+		"extra output":    {Outputs: []OutputSpec{{Type: ThumbnailOutput, Path: filepath.Join(dir, "thumb.jpg")}}},
+		"preview":         {GeneratePreview: true},
+		"manifest":        {WriteManifest: true},
+	} {
+		if key(opts) == base {
+			t.Errorf("%s: JobKey() should differ from the default options", name)
+		}
+	}
+	if key(Options{JobID: "other"}) != base {
+		t.Error("JobKey() should not depend on the job ID")
+	}
+}
+
+func TestCoalescerSkipsSideEffects(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var encodes atomic.Int32
+	release := make(chan struct{})
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name != "ffprobe" && len(args) > 1 {
+			encodes.Add(1)
+			<-release
+		}
+		return scriptedFFmpeg("", nil)(name, args)
+	}}
+	var hooks atomic.Int32
+	newJob := func(output string) *Transcoder {
+		trans, err := NewWithDeps(Options{
+			InputPath:          inputPath,
+			OutputPath:         filepath.Join(dir, output),
+			OutputType:         MP4Output,
+			SkipDiskSpaceCheck: true,
+			PostHook: func(ctx context.Context, stage HookStage, manifest *Manifest) error {
+				hooks.Add(1)
+				return nil
+			},
+		}, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		return trans
+	}
+
+	// Os dois jobs têm a mesma chave, mas cada um deve executar o seu hook
+	var c Coalescer
+	results := make([]string, 2)
+	var wg sync.WaitGroup
+	for i, output := range []string{"first.mp4", "retry.mp4"} {
+		wg.Add(1)
+		go func(i int, job *Transcoder) {
+			defer wg.Done()
+			var shared bool
+			var err error
+			results[i], shared, err = c.Transcode(context.Background(), job)
+			if err != nil || shared {
+				t.Errorf("Job %d: shared %v, error %v", i, shared, err)
+			}
+		}(i, newJob(output))
+	}
+	for encodes.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if hooks.Load() != 2 {
+		t.Errorf("Post hooks ran %d times, want 2", hooks.Load())
+	}
+	for i, output := range []string{"first.mp4", "retry.mp4"} {
+		if want := filepath.Join(dir, output); results[i] != want {
+			t.Errorf("Job %d result = %s, want %s", i, results[i], want)
+		}
+	}
+}
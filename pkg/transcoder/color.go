@@ -0,0 +1,91 @@
+package transcoder
+
+import (
+	"context"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// PixelFormatKeep encodes the video in the pixel format of the input (see
+// Options.PixelFormat).
+const PixelFormatKeep hls.PixelFormat = "keep"
+
+// validatePixelFormat checks PixelFormat, which defaults to
+// hls.PixelFormatYUV420P.
+func validatePixelFormat(options *Options, p *optionProblems) {
+	options.PixelFormat = hls.PixelFormat(strings.ToLower(strings.TrimSpace(string(options.PixelFormat))))
+	switch options.PixelFormat {
+	case "":
+		options.PixelFormat = hls.PixelFormatYUV420P
+	case PixelFormatKeep:
+	default:
+		if err := options.PixelFormat.Validate(); err != nil {
+			p.add("PixelFormat", errors.New(errors.ValidationError, "Invalid pixel format", err.Error(), 4))
+		}
+	}
+}
+
+// colorSettings returns the pixel format conversion and the color tags of the
+// video of the input: the tags reported by ffprobe, and a conversion to
+// Options.PixelFormat if the input has another pixel format. A failure to
+// probe the input is logged and leaves the video as is.
+func (t *Transcoder) colorSettings(ctx context.Context, inputPath string) hls.ColorSettings {
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		t.logger.Warn("Failed to detect the pixel format of the video, leaving it as is", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return hls.ColorSettings{}
+	}
+	color := hls.ColorSettings{
+		ColorSpace:     colorTag(info.ColorSpace),
+		ColorTransfer:  colorTag(info.ColorTransfer),
+		ColorPrimaries: colorTag(info.ColorPrimaries),
+		ColorRange:     colorTag(info.ColorRange),
+	}
+	target := t.options.PixelFormat
+	if target == PixelFormatKeep || info.PixelFormat == "" || hls.PixelFormat(info.PixelFormat) == target {
+		return color
+	}
+	color.PixelFormat = target
+
+	// Vídeos sem marcação são BT.709 em HD e BT.601 em SD, como supõem os players
+	standard := "bt709"
+	if info.Height < 720 {
+		standard = "smpte170m"
+		if info.Height == 576 {
+			standard = "bt470bg"
+		}
+	}
+	if color.ColorSpace == "" {
+		color.ColorSpace = standard
+	}
+	if color.ColorPrimaries == "" {
+		color.ColorPrimaries = standard
+	}
+	if color.ColorTransfer == "" {
+		color.ColorTransfer = map[string]string{"bt709": "bt709", "smpte170m": "smpte170m", "bt470bg": "gamma28"}[standard]
+	}
+	// Os formatos yuvj (JPEG) de faixa completa são convertidos para a faixa limitada
+	if color.ColorRange == "" || strings.HasPrefix(info.PixelFormat, "yuvj") {
+		color.ColorRange = "tv"
+	}
+
+	t.logger.Info("Converting the pixel format of the video", "transcoder", map[string]interface{}{
+		"from": info.PixelFormat,
+		"to":   string(target),
+	})
+	return color
+}
+
+// colorTag returns the color tag value reported by ffprobe, or "" if it is
+// not set.
+func colorTag(value string) string {
+	switch value {
+	case "unknown", "reserved", "unspecified":
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,73 @@
+package transcoder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestColorSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		pixelFormat hls.PixelFormat
+		probe       string
+		want        hls.ColorSettings
+	}{
+		{
+			name:  "10-bit 4:2:2 ProRes master",
+			probe: `{"codec_type": "video", "codec_name": "prores", "width": 1920, "height": 1080, "pix_fmt": "yuv422p10le", "color_space": "bt709", "color_transfer": "bt709", "color_primaries": "bt709", "color_range": "tv"}`,
+			want:  hls.ColorSettings{PixelFormat: hls.PixelFormatYUV420P, ColorSpace: "bt709", ColorTransfer: "bt709", ColorPrimaries: "bt709", ColorRange: "tv"},
+		},
+		{
+			name:  "untagged SD master",
+			probe: `{"codec_type": "video", "width": 720, "height": 480, "pix_fmt": "yuv422p", "color_space": "unknown"}`,
+			want:  hls.ColorSettings{PixelFormat: hls.PixelFormatYUV420P, ColorSpace: "smpte170m", ColorTransfer: "smpte170m", ColorPrimaries: "smpte170m", ColorRange: "tv"},
+		},
+		{
+			name:  "full range JPEG",
+			probe: `{"codec_type": "video", "width": 1280, "height": 720, "pix_fmt": "yuvj420p", "color_range": "pc"}`,
+			want:  hls.ColorSettings{PixelFormat: hls.PixelFormatYUV420P, ColorSpace: "bt709", ColorTransfer: "bt709", ColorPrimaries: "bt709", ColorRange: "tv"},
+		},
+		{
+			name:  "8-bit 4:2:0",
+			probe: `{"codec_type": "video", "width": 1280, "height": 720, "pix_fmt": "yuv420p", "color_primaries": "bt709"}`,
+			want:  hls.ColorSettings{ColorPrimaries: "bt709"},
+		},
+		{
+			name:        "kept pixel format",
+			pixelFormat: "KEEP",
+			probe:       `{"codec_type": "video", "width": 1920, "height": 1080, "pix_fmt": "yuv422p10le"}`,
+			want:        hls.ColorSettings{},
+		},
+		{
+			name:        "10-bit output",
+			pixelFormat: hls.PixelFormatYUV420P10,
+			probe:       `{"codec_type": "video", "width": 3840, "height": 2160, "pix_fmt": "yuv422p10le", "color_space": "bt2020nc", "color_transfer": "smpte2084", "color_primaries": "bt2020", "color_range": "tv"}`,
+			want:        hls.ColorSettings{PixelFormat: hls.PixelFormatYUV420P10, ColorSpace: "bt2020nc", ColorTransfer: "smpte2084", ColorPrimaries: "bt2020", ColorRange: "tv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				return ffmpegtest.Result{Stdout: `{"streams": [` + tt.probe + `], "format": {"duration": "60.0"}}`}
+			}}
+			trans, err := NewWithDeps(Options{
+				InputPath:   "master.mov",
+				OutputPath:  "output",
+				PixelFormat: tt.pixelFormat,
+			}, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+			if got := trans.colorSettings(context.Background(), "master.mov"); got != tt.want {
+				t.Errorf("colorSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if err := ValidateOptions(Options{InputPath: "input.mp4", OutputPath: "output", PixelFormat: "rgb24"}); err == nil {
+		t.Error("ValidateOptions() expected a validation error for an unknown pixel format")
+	}
+}
@@ -0,0 +1,143 @@
+package transcoder
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// masterPlaylistName is the master playlist written into HLS output directories.
+const masterPlaylistName = "master.m3u8"
+
+// completeDurationTolerance is the relative difference allowed between the
+// durations of the input and an existing MP4 output for it to count as complete.
+const completeDurationTolerance = 0.01
+
+// completeOutput reports whether outputPath already holds a complete output for
+// the input, returning the path Transcode would return for it. When a job
+// manifest for outputPath is present, the output is complete if every file it lists still has
+// its recorded size. Otherwise HLS output is complete when the master playlist
+// and every variant playlist it lists exist, each variant is finished
+// (#EXT-X-ENDLIST) and all their segments exist, and MP4 output is complete
+// when its duration matches the input duration.
+func (t *Transcoder) completeOutput(ctx context.Context, inputPath, outputPath string) (string, bool) {
+	result := filepath.Join(outputPath, masterPlaylistName)
+	if t.options.OutputType == MP4Output {
+		result = outputPath
+	}
+	if manifest, err := ReadManifest(t.manifestPath()); err == nil && manifest.OutputPath == outputPath && len(manifest.Files) > 0 {
+		return result, manifestFilesPresent(filepath.Dir(t.manifestPath()), manifest.Files)
+	}
+
+	if t.options.OutputType == MP4Output {
+		return result, t.completeMP4(ctx, inputPath, outputPath)
+	}
+	return result, completeHLS(result)
+}
+
+// manifestFilesPresent reports whether every file listed in a manifest exists
+// in dir with its recorded size.
+func manifestFilesPresent(dir string, files []ManifestFile) bool {
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil || info.Size() != file.SizeBytes {
+			return false
+		}
+	}
+	return true
+}
+
+// completeMP4 compares the duration of the MP4 file at outputPath with the
+// duration of the input.
+func (t *Transcoder) completeMP4(ctx context.Context, inputPath, outputPath string) bool {
+	if info, err := os.Stat(outputPath); err != nil || info.Size() == 0 {
+		return false
+	}
+	output, err := runFFprobe(ctx, t.runner, outputPath)
+	if err != nil {
+		return false
+	}
+	outputDuration, err := strconv.ParseFloat(output.Format.Duration, 64)
+	if err != nil || outputDuration <= 0 {
+		return false
+	}
+
+	input, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		return false
+	}
+	if input.Duration <= 0 {
+		// Nothing to compare with: a readable output is the best evidence available
+		return true
+	}
+	tolerance := math.Max(1, input.Duration*completeDurationTolerance)
+	return math.Abs(outputDuration-input.Duration) <= tolerance
+}
+
+// completeHLS checks the master playlist at masterPath and the playlists and
+// segments it references.
+func completeHLS(masterPath string) bool {
+	variants, ok := playlistEntries(masterPath)
+	if !ok || len(variants) == 0 {
+		return false
+	}
+	for _, variant := range variants {
+		segments, ok := playlistEntries(variant)
+		if !ok || len(segments) == 0 {
+			return false
+		}
+		if !playlistEnded(variant) {
+			return false
+		}
+		for _, segment := range segments {
+			if info, err := os.Stat(segment); err != nil || info.Size() == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// playlistEntries returns the local paths of the URIs listed in the playlist
+// at path, resolved against its directory. It fails for missing files, files
+// that are not playlists and remote URIs.
+func playlistEntries(path string) ([]string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			if line != "#EXTM3U" {
+				return nil, false
+			}
+			first = false
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "://") {
+			return nil, false
+		}
+		entries = append(entries, filepath.Join(filepath.Dir(path), filepath.FromSlash(line)))
+	}
+	return entries, scanner.Err() == nil && !first
+}
+
+// playlistEnded reports whether the media playlist at path has an
+// #EXT-X-ENDLIST tag, which ffmpeg writes once the stream is complete.
+func playlistEnded(path string) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(content), "#EXT-X-ENDLIST")
+}
@@ -0,0 +1,126 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+// writeHLSOutput writes a two-variant HLS output into dir.
+func writeHLSOutput(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]string{
+		"master.m3u8":             "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2800000\nstream_1/playlist.m3u8\n",
+		"stream_0/playlist.m3u8":  "#EXTM3U\n#EXTINF:10.0,\nsegment_000.ts\n#EXTINF:5.0,\nsegment_001.ts\n#EXT-X-ENDLIST\n",
+		"stream_1/playlist.m3u8":  "#EXTM3U\n#EXTINF:10.0,\nsegment_000.ts\n#EXTINF:5.0,\nsegment_001.ts\n#EXT-X-ENDLIST\n",
+		"stream_0/segment_000.ts": "ts", "stream_0/segment_001.ts": "ts",
+		"stream_1/segment_000.ts": "ts", "stream_1/segment_001.ts": "ts",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func TestCompleteHLS(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(dir string)
+		want   bool
+	}{
+		{name: "Complete", want: true},
+		{name: "No master playlist", damage: func(dir string) { os.Remove(filepath.Join(dir, "master.m3u8")) }},
+		{name: "Missing variant", damage: func(dir string) { os.Remove(filepath.Join(dir, "stream_1", "playlist.m3u8")) }},
+		{name: "Missing segment", damage: func(dir string) { os.Remove(filepath.Join(dir, "stream_0", "segment_001.ts")) }},
+		{name: "Empty segment", damage: func(dir string) { os.WriteFile(filepath.Join(dir, "stream_1", "segment_000.ts"), nil, 0644) }},
+		{
+			name: "Unfinished variant",
+			damage: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "stream_0", "playlist.m3u8"), []byte("#EXTM3U\n#EXTINF:10.0,\nsegment_000.ts\n"), 0644)
+			},
+		},
+		{name: "Not a playlist", damage: func(dir string) { os.WriteFile(filepath.Join(dir, "master.m3u8"), []byte("<html>"), 0644) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeHLSOutput(t, dir)
+			if tt.damage != nil {
+				tt.damage(dir)
+			}
+			if got := completeHLS(filepath.Join(dir, "master.m3u8")); got != tt.want {
+				t.Errorf("completeHLS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipIfComplete(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	outputPath := filepath.Join(dir, "output.mp4")
+	for _, path := range []string{inputPath, outputPath} {
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		skipIfComplete bool
+		outputDuration string
+		wantEncodes    int
+	}{
+		{name: "Complete output", skipIfComplete: true, outputDuration: "60.020000"},
+		{name: "Truncated output", skipIfComplete: true, outputDuration: "31.500000", wantEncodes: 1},
+		{name: "Disabled", outputDuration: "60.020000", wantEncodes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encodes := 0
+			scripted := scriptedFFmpeg("", nil)
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				if name == "ffprobe" && args[len(args)-1] == outputPath {
+					return ffmpegtest.Result{Stdout: `{"streams": [{"codec_type": "video"}], "format": {"duration": "` + tt.outputDuration + `"}}`}
+				}
+				if name != "ffprobe" && len(args) > 1 {
+					encodes++
+				}
+				return scripted(name, args)
+			}}
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         outputPath,
+				OutputType:         MP4Output,
+				AllowOverwrite:     true,
+				SkipIfComplete:     tt.skipIfComplete,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			got, err := trans.Transcode(context.Background())
+			if err != nil {
+				t.Fatalf("Transcode() unexpected error: %v", err)
+			}
+			if got != outputPath {
+				t.Errorf("Transcode() = %q, want %q", got, outputPath)
+			}
+			if encodes != tt.wantEncodes {
+				t.Errorf("ffmpeg encoded %d times, want %d", encodes, tt.wantEncodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/diskspace"
+	"github.com/heyjunin/HLSpresso/pkg/encrypt"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// validateInputDecryption checks the input decryption options, setting the
+// default cipher.
+func validateInputDecryption(options *Options, p *optionProblems) {
+	cipher, err := encrypt.ParseCipher(string(options.InputCipher))
+	if err != nil {
+		p.add("InputCipher", errors.New(errors.ValidationError, "Invalid input cipher", err.Error(), 4))
+		return
+	}
+	options.InputCipher = cipher
+
+	if len(options.InputIV) > 0 && (cipher != encrypt.CipherAESCBC || len(options.InputIV) != 16) {
+		p.add("InputIV", errors.New(errors.ValidationError, "Invalid input IV",
+			"An IV of 16 bytes is only used with the aes-cbc input cipher", 4))
+	}
+
+	if len(options.InputDecryptionKey) == 0 && options.InputKeyProvider == nil {
+		return
+	}
+	if options.StreamFromURL {
+		p.add("StreamFromURL", errors.New(errors.ValidationError, "Encrypted inputs cannot be streamed",
+			"Download the input (StreamFromURL false) to decrypt it", 4))
+	}
+	if key := options.InputDecryptionKey; len(key) > 0 {
+		if cipher == encrypt.CipherHLSpresso && len(key) != encrypt.KeySize {
+			p.add("InputDecryptionKey", errors.New(errors.ValidationError, "Invalid input decryption key", encrypt.ErrInvalidKey.Error(), 4))
+		}
+		if cipher == encrypt.CipherAESCBC && len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			p.add("InputDecryptionKey", errors.New(errors.ValidationError, "Invalid input decryption key",
+				"AES-CBC keys must be 16, 24 or 32 bytes", 4))
+		}
+	}
+}
+
+// decryptsInput reports whether the input is decrypted before transcoding.
+func (t *Transcoder) decryptsInput() bool {
+	return len(t.options.InputDecryptionKey) > 0 || t.options.InputKeyProvider != nil
+}
+
+// decryptInput decrypts the encrypted input at inputPath into DownloadDir,
+// under its name without encrypt.Suffix, and returns the path of the
+// plaintext, which removeDecryptedInput removes.
+func (t *Transcoder) decryptInput(ctx context.Context, inputPath string) (string, error) {
+	key := t.options.InputDecryptionKey
+	if len(key) == 0 {
+		var err error
+		if key, err = t.options.InputKeyProvider.Key(ctx, t.options.InputPath); err != nil {
+			return "", errors.Wrap(err, errors.NetworkError, "Failed to look up the input decryption key", 24)
+		}
+	}
+
+	if err := os.MkdirAll(t.options.DownloadDir, 0755); err != nil {
+		return "", errors.Wrap(err, errors.SystemError, "Failed to create download directory", 6)
+	}
+	if err := t.checkDiskSpace(t.options.DownloadDir, diskspace.DefaultMinFreeSpace); err != nil {
+		return "", err
+	}
+
+	// Um nome único, mantendo a extensão original para a verificação do formato
+	name := sanitizeFileName(strings.TrimSuffix(filepath.Base(inputPath), encrypt.Suffix))
+	file, err := os.CreateTemp(t.options.DownloadDir, "decrypted-*-"+name)
+	if err != nil {
+		return "", errors.Wrap(err, errors.SystemError, "Failed to create the decrypted input", 24)
+	}
+	file.Close()
+	t.decryptedInput = file.Name()
+
+	if err := encrypt.DecryptInput(t.options.InputCipher, key, t.options.InputIV, inputPath, file.Name()); err != nil {
+		if stderrors.Is(err, encrypt.ErrDecrypt) {
+			return "", errors.Wrap(err, errors.InvalidFileFormatError, "Failed to decrypt the input", 24)
+		}
+		return "", errors.Wrap(err, errors.SystemError, "Failed to decrypt the input", 24)
+	}
+
+	t.logger.Info("Input decrypted", "transcoder", map[string]interface{}{
+		"cipher": t.options.InputCipher,
+		"path":   file.Name(),
+	})
+	return file.Name(), nil
+}
+
+// removeDecryptedInput removes the plaintext written by decryptInput, if any.
+func (t *Transcoder) removeDecryptedInput() {
+	if t.decryptedInput == "" {
+		return
+	}
+	if err := os.Remove(t.decryptedInput); err != nil && !os.IsNotExist(err) {
+		t.logger.Warn("Failed to remove the decrypted input", "transcoder", map[string]interface{}{
+			"path":  t.decryptedInput,
+			"error": err.Error(),
+		})
+	}
+	t.decryptedInput = ""
+}
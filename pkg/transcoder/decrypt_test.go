@@ -0,0 +1,98 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/encrypt"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestTranscodeDecryptsInput(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{0x2a}, encrypt.KeySize)
+	inputPath := filepath.Join(dir, "master.mp4.enc")
+	var sealed bytes.Buffer
+	if err := encrypt.Encrypt(key, &sealed, bytes.NewReader([]byte("dummy video content"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(inputPath, sealed.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create encrypted input file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "key", opts: Options{InputDecryptionKey: key}},
+		{name: "key provider", opts: Options{InputKeyProvider: encrypt.KeyProviderFunc(func(ctx context.Context, input string) ([]byte, error) {
+			if input != inputPath {
+				t.Errorf("Key looked up for %q, want %q", input, inputPath)
+			}
+			return key, nil
+		})}},
+		{name: "wrong key", opts: Options{InputDecryptionKey: bytes.Repeat([]byte{1}, encrypt.KeySize)}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			downloads := t.TempDir()
+			var decrypted string
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				for i, arg := range args {
+					if arg == "-i" {
+						decrypted = args[i+1]
+						content, _ := os.ReadFile(decrypted)
+						if string(content) != "dummy video content" {
+							t.Errorf("ffmpeg input %s = %q, want the plaintext", decrypted, content)
+						}
+					}
+				}
+				return scriptedFFmpeg("", nil)(name, args)
+			}}
+			opts := tc.opts
+			opts.InputPath = inputPath
+			opts.OutputPath = filepath.Join(t.TempDir(), "video.mp4")
+			opts.OutputType = MP4Output
+			opts.DownloadDir = downloads
+			opts.InputExtensions = []string{"mp4"}
+			opts.SkipDiskSpaceCheck = true
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if tc.wantErr {
+				if sErr, ok := err.(*errors.StructuredError); !ok || sErr.Code != 24 {
+					t.Errorf("Transcode() error = %v, want a decryption error", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Transcode() unexpected error: %v", err)
+			} else if filepath.Dir(decrypted) != downloads || filepath.Ext(decrypted) != ".mp4" {
+				t.Errorf("Decrypted input = %q, want an .mp4 file in the download directory", decrypted)
+			}
+			if entries, _ := os.ReadDir(downloads); len(entries) != 0 {
+				t.Errorf("The decrypted input should be removed, found %v", entries)
+			}
+		})
+	}
+}
+
+func TestNewInvalidInputDecryption(t *testing.T) {
+	key := bytes.Repeat([]byte{0x2a}, 16)
+	for name, opts := range map[string]Options{
+		"cipher":      {InputCipher: "aes-ctr"},
+		"key size":    {InputDecryptionKey: key},
+		"streamed":    {InputDecryptionKey: key, InputCipher: encrypt.CipherAESCBC, StreamFromURL: true},
+		"IV with GCM": {InputIV: key},
+	} {
+		opts.InputPath, opts.OutputPath = "input.mp4", "out"
+		if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+			t.Errorf("%s: NewWithDeps() expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// DeinterlaceMode selects when the video is deinterlaced (see
+// Options.Deinterlace).
+type DeinterlaceMode string
+
+const (
+	// DeinterlaceAuto deinterlaces the video when ffprobe reports an
+	// interlaced field order. This is the default.
+	DeinterlaceAuto DeinterlaceMode = "auto"
+	// DeinterlaceOn always deinterlaces the video, e.g. for interlaced inputs
+	// flagged as progressive.
+	DeinterlaceOn DeinterlaceMode = "on"
+	// DeinterlaceOff never deinterlaces the video.
+	DeinterlaceOff DeinterlaceMode = "off"
+)
+
+// ParseDeinterlaceMode validates a deinterlace mode name, case-insensitively.
+// The empty string selects DeinterlaceAuto.
+func ParseDeinterlaceMode(value string) (DeinterlaceMode, error) {
+	switch mode := DeinterlaceMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return DeinterlaceAuto, nil
+	case DeinterlaceAuto, DeinterlaceOn, DeinterlaceOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown deinterlace mode %q (expected auto, on or off)", value)
+	}
+}
+
+// validateDeinterlace parses Deinterlace and checks Deinterlacer, which
+// defaults to bwdif.
+func validateDeinterlace(options *Options, p *optionProblems) {
+	if mode, err := ParseDeinterlaceMode(string(options.Deinterlace)); err != nil {
+		p.add("Deinterlace", errors.New(errors.ValidationError, "Invalid deinterlace mode", err.Error(), 4))
+	} else {
+		options.Deinterlace = mode
+	}
+	options.Deinterlacer = hls.Deinterlacer(strings.ToLower(string(options.Deinterlacer)))
+	if options.Deinterlacer == "" {
+		options.Deinterlacer = hls.DeinterlaceBwdif
+	}
+	if err := options.Deinterlacer.Validate(); err != nil {
+		p.add("Deinterlacer", errors.New(errors.ValidationError, "Invalid deinterlacer", err.Error(), 4))
+	}
+}
+
+// deinterlacer returns the filter deinterlacing the video of the input
+// according to Options.Deinterlace, or "" if it is left as is. A failure to
+// probe the field order is logged and leaves the video as is.
+func (t *Transcoder) deinterlacer(ctx context.Context, inputPath string) hls.Deinterlacer {
+	switch t.options.Deinterlace {
+	case DeinterlaceOff:
+		return ""
+	case DeinterlaceOn:
+		return t.options.Deinterlacer
+	}
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		t.logger.Warn("Failed to detect interlaced video, leaving it as is", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+	if !info.Interlaced() {
+		return ""
+	}
+	t.logger.Info("Interlaced video detected, deinterlacing it", "transcoder", map[string]interface{}{
+		"field_order":  info.FieldOrder,
+		"deinterlacer": string(t.options.Deinterlacer),
+	})
+	return t.options.Deinterlacer
+}
@@ -0,0 +1,59 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestDeinterlacer(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         DeinterlaceMode
+		deinterlacer hls.Deinterlacer
+		fieldOrder   string
+		want         hls.Deinterlacer
+	}{
+		{"interlaced broadcast master", "", "", "tt", hls.DeinterlaceBwdif},
+		{"bottom field first", DeinterlaceAuto, "YADIF", "bb", hls.DeinterlaceYadif},
+		{"progressive", DeinterlaceAuto, "", "progressive", ""},
+		{"unknown field order", DeinterlaceAuto, "", "", ""},
+		{"forced", DeinterlaceOn, hls.DeinterlaceYadif, "progressive", hls.DeinterlaceYadif},
+		{"disabled", DeinterlaceOff, "", "tt", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				return ffmpegtest.Result{Stdout: fmt.Sprintf(`{
+					"streams": [{"index": 0, "codec_type": "video", "width": 1920, "height": 1080, "field_order": %q}],
+					"format": {"format_name": "mpegts", "duration": "60.0"}
+				}`, tt.fieldOrder)}
+			}}
+			trans, err := NewWithDeps(Options{
+				InputPath:    "master.ts",
+				OutputPath:   "output",
+				Deinterlace:  tt.mode,
+				Deinterlacer: tt.deinterlacer,
+			}, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+			if got := trans.deinterlacer(context.Background(), "master.ts"); got != tt.want {
+				t.Errorf("deinterlacer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for name, invalid := range map[string]Options{
+		"mode":         {Deinterlace: "always"},
+		"deinterlacer": {Deinterlacer: "w3fdif"},
+	} {
+		invalid.InputPath, invalid.OutputPath = "input.mp4", "output"
+		if err := ValidateOptions(invalid); err == nil {
+			t.Errorf("%s: ValidateOptions() expected a validation error", name)
+		}
+	}
+}
@@ -10,11 +10,18 @@ import (
 	"testing"
 
 	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
 	"github.com/heyjunin/HLSpresso/pkg/hls"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// newFFmpegRunner retorna um runner simulado cujo FFmpeg possui os codecs necessários,
+// permitindo testar o tratamento de erros sem o FFmpeg instalado.
+func newFFmpegRunner() *ffmpegtest.Runner {
+	return &ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}
+}
+
 // TestNetworkError verifica se erros de rede são tratados corretamente
 func TestNetworkError(t *testing.T) {
 	mockReporter := &mockProgressReporter{}
@@ -61,7 +68,7 @@ func TestNetworkError(t *testing.T) {
 				StreamFromURL: tt.streamFromURL,
 			}
 			
-			trans, err := New(opts, mockReporter)
+			trans, err := NewWithDeps(opts, mockReporter, newDiscardLogger(), nil, WithRunner(newFFmpegRunner()))
 			require.NoError(t, err)
 			
 			// Tentativa de transcodificação que deve falhar
@@ -93,7 +100,7 @@ func TestFileNotFoundError(t *testing.T) {
 		IsRemoteInput: false,
 	}
 	
-	trans, err := New(opts, mockReporter)
+	trans, err := NewWithDeps(opts, mockReporter, newDiscardLogger(), nil, WithRunner(newFFmpegRunner()))
 	require.NoError(t, err)
 	
 	// Tentativa de transcodificação que deve falhar
@@ -111,6 +118,26 @@ func TestFileNotFoundError(t *testing.T) {
 	}
 }
 
+// TestErrorLocale verifica se as mensagens de erro seguem Options.Locale sem alterar o código
+func TestErrorLocale(t *testing.T) {
+	tempDir := t.TempDir()
+
+	opts := Options{
+		InputPath:  filepath.Join(tempDir, "nonexistent.mp4"),
+		OutputPath: filepath.Join(tempDir, "output"),
+		Locale:     "pt_BR.UTF-8",
+	}
+
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(newFFmpegRunner()))
+	require.NoError(t, err)
+
+	_, err = trans.Transcode(context.Background())
+	structErr, ok := err.(*errors.StructuredError)
+	require.True(t, ok, "O erro deveria ser um StructuredError")
+	assert.Equal(t, errors.ErrFileNotFound, structErr.Code)
+	assert.Equal(t, errors.GetLocalizedErrorMessage("pt", errors.ErrFileNotFound), structErr.Message)
+}
+
 // TestInvalidFileFormatError verifica se erros de formato de arquivo são tratados corretamente
 func TestInvalidFileFormatError(t *testing.T) {
 	mockReporter := &mockProgressReporter{}
@@ -129,12 +156,14 @@ func TestInvalidFileFormatError(t *testing.T) {
 	tests := []struct {
 		name          string
 		inputPath     string
+		probeFails    bool
 		expectedError errors.ErrorType
 		expectedCode  int
 	}{
 		{
 			name:          "Arquivo de texto (formato inválido)",
 			inputPath:     textFile,
+			probeFails:    true,
 			expectedError: errors.InvalidFileFormatError,
 			expectedCode:  errors.ErrUnsupportedFileFormat,
 		},
@@ -154,7 +183,18 @@ func TestInvalidFileFormatError(t *testing.T) {
 				IsRemoteInput: false,
 			}
 			
-			trans, err := New(opts, mockReporter)
+			runner := newFFmpegRunner()
+			if tt.probeFails {
+				// O ffprobe não reconhece o conteúdo do arquivo
+				runner = &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+					if name == "ffprobe" {
+						return ffmpegtest.Result{Stderr: "Invalid data found when processing input", Err: fmt.Errorf("exit status 1")}
+					}
+					return scriptedFFmpeg("", nil)(name, args)
+				}}
+			}
+			
+			trans, err := NewWithDeps(opts, mockReporter, newDiscardLogger(), nil, WithRunner(runner))
 			require.NoError(t, err)
 			
 			// Tentativa de transcodificação que deve falhar
@@ -227,7 +267,7 @@ func TestUnsupportedResolutionError(t *testing.T) {
 				AllowOverwrite:  true,
 			}
 			
-			trans, err := New(opts, mockReporter)
+			trans, err := NewWithDeps(opts, mockReporter, newDiscardLogger(), nil, WithRunner(newFFmpegRunner()))
 			require.NoError(t, err)
 			
 			// Tentativa de transcodificação que deve falhar
@@ -276,7 +316,7 @@ func TestInvalidOutputPathError(t *testing.T) {
 		IsRemoteInput: false,
 	}
 	
-	trans, err := New(opts, mockReporter)
+	trans, err := NewWithDeps(opts, mockReporter, newDiscardLogger(), nil, WithRunner(newFFmpegRunner()))
 	require.NoError(t, err)
 	
 	// Tentativa de transcodificação que deve falhar
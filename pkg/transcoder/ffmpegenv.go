@@ -0,0 +1,65 @@
+package transcoder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// validateFFmpegEnv checks the environment variable names of FFmpegEnv and
+// that FFmpegWorkDir is a directory, making it and the relative paths read or
+// written by ffmpeg absolute.
+func validateFFmpegEnv(options *Options, p *optionProblems) {
+	for name := range options.FFmpegEnv {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			p.add("FFmpegEnv", errors.New(errors.ValidationError, "Invalid ffmpeg environment variable",
+				fmt.Sprintf("%q is not a valid variable name", name), 4))
+		}
+	}
+	if options.FFmpegWorkDir == "" {
+		return
+	}
+	if info, err := os.Stat(options.FFmpegWorkDir); err != nil || !info.IsDir() {
+		p.add("FFmpegWorkDir", errors.New(errors.ValidationError, "Invalid ffmpeg working directory",
+			fmt.Sprintf("%q is not a directory", options.FFmpegWorkDir), 4))
+		return
+	}
+
+	// Os caminhos relativos seriam resolvidos pelo ffmpeg a partir do novo diretório
+	paths := []*string{&options.FFmpegWorkDir, &options.OutputPath, &options.DownloadDir, &options.Intro.Path, &options.Outro.Path}
+	for i := range options.Outputs {
+		paths = append(paths, &options.Outputs[i].Path)
+	}
+	if !options.IsRemoteInput && !strings.Contains(options.InputPath, "://") {
+		paths = append(paths, &options.InputPath)
+	}
+	// Binários com separador são relativos ao diretório de trabalho do processo
+	for _, binary := range []*string{&options.FFmpegBinary, &options.YtDlpBinary} {
+		if strings.ContainsAny(*binary, "/"+string(filepath.Separator)) {
+			paths = append(paths, binary)
+		}
+	}
+	for _, path := range paths {
+		if *path == "" || filepath.IsAbs(*path) {
+			continue
+		}
+		if abs, err := filepath.Abs(*path); err == nil {
+			*path = abs
+		}
+	}
+}
+
+// ffmpegEnv returns the variables of env as "KEY=value" entries, sorted by
+// name.
+func ffmpegEnv(env map[string]string) []string {
+	entries := make([]string, 0, len(env))
+	for name, value := range env {
+		entries = append(entries, name+"="+value)
+	}
+	sort.Strings(entries)
+	return entries
+}
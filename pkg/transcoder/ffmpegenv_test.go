@@ -0,0 +1,59 @@
+package transcoder
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+func TestFFmpegEnv(t *testing.T) {
+	workDir := t.TempDir()
+	opts := Options{
+		InputPath:     "videos/input.mp4",
+		OutputPath:    "output",
+		FFmpegBinary:  "bin/ffmpeg",
+		FFmpegEnv:     map[string]string{"FONTCONFIG_PATH": "/fonts", "CUDA_VISIBLE_DEVICES": "1"},
+		FFmpegWorkDir: workDir,
+		Outputs:       []OutputSpec{{Type: MP4Output, Path: "output_720p.mp4"}},
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	runner, ok := trans.runner.(ffmpeg.ExecRunner)
+	if !ok {
+		t.Fatalf("Runner = %T, want the default runner", trans.runner)
+	}
+	if !slices.Equal(runner.Env, []string{"CUDA_VISIBLE_DEVICES=1", "FONTCONFIG_PATH=/fonts"}) || runner.Dir != workDir {
+		t.Errorf("Runner environment = %v in %q", runner.Env, runner.Dir)
+	}
+
+	// Os caminhos relativos continuam apontando para os mesmos arquivos
+	cwd, _ := os.Getwd()
+	for name, got := range map[string]string{
+		"InputPath":    trans.options.InputPath,
+		"OutputPath":   trans.options.OutputPath,
+		"DownloadDir":  trans.options.DownloadDir,
+		"FFmpegBinary": trans.options.FFmpegBinary,
+		"Outputs[0]":   trans.options.Outputs[0].Path,
+	} {
+		if !filepath.IsAbs(got) || !strings.HasPrefix(got, cwd) {
+			t.Errorf("%s = %q, want an absolute path under %q", name, got, cwd)
+		}
+	}
+
+	for name, invalid := range map[string]Options{
+		"variable name": {FFmpegEnv: map[string]string{"A=B": "c"}},
+		"working dir":   {FFmpegWorkDir: filepath.Join(workDir, "missing")},
+	} {
+		invalid.InputPath, invalid.OutputPath = "input.mp4", "output"
+		if _, err := NewWithDeps(invalid, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+			t.Errorf("%s: NewWithDeps() expected a validation error", name)
+		}
+	}
+}
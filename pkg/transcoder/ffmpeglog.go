@@ -0,0 +1,69 @@
+package transcoder
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// Defaults of the ffmpeg log rotation (see Options.FFmpegLogPath).
+const (
+	DefaultFFmpegLogMaxSize = 50 << 20
+	DefaultFFmpegLogBackups = 3
+)
+
+// ffmpegLog is the Log of the default runner. It writes to the ffmpeg log of
+// the running Transcode call and discards the output of the processes run
+// outside of one (e.g. by Plan).
+type ffmpegLog struct {
+	mu   sync.Mutex
+	file *ffmpeg.LogFile
+}
+
+func (l *ffmpegLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return len(p), nil
+	}
+	return l.file.Write(p)
+}
+
+// openFFmpegLog opens the ffmpeg log of the job, if configured, and returns
+// the function closing it. A log that cannot be opened is reported and
+// skipped: it must not fail the job.
+func (t *Transcoder) openFFmpegLog() func() {
+	if t.ffmpegLog == nil {
+		return func() {}
+	}
+	maxSize := t.options.FFmpegLogMaxSize
+	if maxSize == 0 {
+		maxSize = DefaultFFmpegLogMaxSize
+	}
+	backups := t.options.FFmpegLogBackups
+	if backups == 0 {
+		backups = DefaultFFmpegLogBackups
+	}
+	path := strings.ReplaceAll(t.options.FFmpegLogPath, OutputVarJobID, t.options.JobID)
+	file, err := ffmpeg.OpenLogFile(path, maxSize, backups)
+	if err != nil {
+		t.logger.Warn("Failed to open the ffmpeg log", "transcoder", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return func() {}
+	}
+	t.logger.Debug("Writing the ffmpeg output to its log", "transcoder", map[string]interface{}{
+		"path": path,
+	})
+	t.ffmpegLog.mu.Lock()
+	t.ffmpegLog.file = file
+	t.ffmpegLog.mu.Unlock()
+	return func() {
+		t.ffmpegLog.mu.Lock()
+		t.ffmpegLog.file = nil
+		t.ffmpegLog.mu.Unlock()
+		file.Close()
+	}
+}
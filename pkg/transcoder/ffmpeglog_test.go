@@ -0,0 +1,37 @@
+package transcoder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+func TestFFmpegLog(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		InputPath:     "input.mp4",
+		OutputPath:    filepath.Join(dir, "out"),
+		JobID:         "job-1",
+		FFmpegLogPath: filepath.Join(dir, "logs", "{jobid}.log"),
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	runner, ok := trans.runner.(ffmpeg.ExecRunner)
+	if !ok || runner.Log == nil {
+		t.Fatalf("runner = %#v, want an ExecRunner writing the ffmpeg log", trans.runner)
+	}
+
+	runner.Log.Write([]byte("outside of a job\n"))
+	closeLog := trans.openFFmpegLog()
+	runner.Log.Write([]byte("[1] frame=1\n"))
+	closeLog()
+
+	content, err := os.ReadFile(filepath.Join(dir, "logs", "job-1.log"))
+	if err != nil || string(content) != "[1] frame=1\n" {
+		t.Errorf("ffmpeg log = %q, %v", content, err)
+	}
+}
@@ -0,0 +1,97 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// HookStage identifies when a Hook runs.
+type HookStage string
+
+const (
+	// HookPre runs before the input is handled (downloaded or probed).
+	HookPre HookStage = "pre"
+	// HookPost runs once the output is complete (encoded, encrypted and uploaded).
+	HookPost HookStage = "post"
+)
+
+// Hook is a custom step run by Transcode, such as a virus scan of the input or
+// a CMS notification. For HookPre, manifest describes the job only (input,
+// options, output path); for HookPost, it also lists the produced files.
+// Returning an error fails the job.
+type Hook func(ctx context.Context, stage HookStage, manifest *Manifest) error
+
+// hookOutputLimit is the number of bytes of the output of an ExecHook command
+// kept in its error message.
+const hookOutputLimit = 1024
+
+// ExecHook returns a Hook running command with the system shell ("sh -c", or
+// "cmd /C" on Windows). The job manifest is written as JSON to its standard
+// input, and HLSPRESSO_HOOK (the stage), HLSPRESSO_JOB_ID, HLSPRESSO_INPUT and
+// HLSPRESSO_OUTPUT are set in its environment. A non-zero exit status fails the
+// job.
+func ExecHook(command string) Hook {
+	return func(ctx context.Context, stage HookStage, manifest *Manifest) error {
+		content, err := json.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		cmd := ffmpeg.CommandContext(ctx, shell, flag, command)
+		cmd.Stdin = bytes.NewReader(content)
+		cmd.Env = append(os.Environ(),
+			"HLSPRESSO_HOOK="+string(stage),
+			"HLSPRESSO_JOB_ID="+manifest.JobID,
+			"HLSPRESSO_INPUT="+manifest.Input.Path,
+			"HLSPRESSO_OUTPUT="+manifest.OutputPath,
+		)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			if out := strings.TrimSpace(string(output)); out != "" {
+				if len(out) > hookOutputLimit {
+					out = out[len(out)-hookOutputLimit:]
+				}
+				return fmt.Errorf("%w: %s", err, out)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// runHook runs hook for stage, if set, with the job manifest.
+func (t *Transcoder) runHook(ctx context.Context, stage HookStage, hook Hook, inputPath string) error {
+	if hook == nil {
+		return nil
+	}
+
+	var manifest *Manifest
+	if stage == HookPost {
+		var err error
+		if manifest, err = t.buildManifest(ctx, inputPath, t.sidecarDir(), t.manifestPath(), t.checksumPath()); err != nil {
+			return errors.Wrap(err, errors.SystemError, "Failed to checksum the output files for the post-hook", 22)
+		}
+	} else {
+		manifest = t.jobManifest()
+	}
+
+	t.logger.Info("Running hook", "transcoder", map[string]interface{}{
+		"stage": stage,
+	})
+	if err := hook(ctx, stage, manifest); err != nil {
+		return errors.Wrap(err, errors.SystemError, fmt.Sprintf("The %s-hook failed", stage), 22)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestTranscodeHooks(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	outputPath := filepath.Join(dir, "out", "output.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	var stages []HookStage
+	var post *Manifest
+	hook := func(ctx context.Context, stage HookStage, manifest *Manifest) error {
+		stages = append(stages, stage)
+		if stage == HookPost {
+			post = manifest
+		}
+		return nil
+	}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputType:         MP4Output,
+		JobID:              "job-42",
+		SkipDiskSpaceCheck: true,
+		PreHook:            hook,
+		PostHook:           hook,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	if len(stages) != 2 || stages[0] != HookPre || stages[1] != HookPost {
+		t.Errorf("Hooks ran for %v, want [pre post]", stages)
+	}
+	want := ManifestFile{Path: "output.mp4", SizeBytes: 7, SHA256: sha256Hex("encoded")}
+	if post == nil || post.JobID != "job-42" || len(post.Files) != 1 || post.Files[0] != want {
+		t.Errorf("Post-hook manifest = %+v, want the output file %+v", post, want)
+	}
+}
+
+func TestTranscodePreHookFailure(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	runner := &ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "out"),
+		SkipDiskSpaceCheck: true,
+		PreHook: func(ctx context.Context, stage HookStage, manifest *Manifest) error {
+			return stderrors.New("infected file")
+		},
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	_, err = trans.Transcode(context.Background())
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Code != 22 || !strings.Contains(sErr.Error(), "infected file") {
+		t.Fatalf("Transcode() error = %v, want the pre-hook failure", err)
+	}
+	for _, call := range runner.Calls() {
+		if call.Name == "ffprobe" || (call.Name == "ffmpeg" && len(call.Args) > 1) {
+			t.Errorf("The input should not be handled after a pre-hook failure, got %s %v", call.Name, call.Args)
+		}
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	stdin := filepath.Join(t.TempDir(), "stdin.json")
+	manifest := &Manifest{JobID: "job-42", OutputPath: "out"}
+
+	hook := ExecHook(`cat > "` + stdin + `" && test "$HLSPRESSO_HOOK-$HLSPRESSO_JOB_ID" = post-job-42`)
+	if err := hook(context.Background(), HookPost, manifest); err != nil {
+		t.Fatalf("ExecHook() unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(stdin); !strings.Contains(string(content), `"job_id":"job-42"`) {
+		t.Errorf("Hook stdin = %s, want the job manifest", content)
+	}
+
+	err := ExecHook("echo scan failed >&2; exit 3")(context.Background(), HookPre, manifest)
+	if err == nil || !strings.Contains(err.Error(), "scan failed") {
+		t.Errorf("ExecHook() error = %v, want the command output", err)
+	}
+}
@@ -0,0 +1,60 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// DefaultInputExtensions lists the extensions of common video containers, for use
+// as Options.InputExtensions when inputs should be accepted by extension only.
+var DefaultInputExtensions = []string{
+	".mp4", ".mov", ".avi", ".mkv", ".webm",
+	".flv", ".wmv", ".mpeg", ".mpg", ".m4v",
+	".3gp", ".ts", ".mts", ".m2ts",
+}
+
+// checkInputFormat verifies that the local file at inputPath can be transcoded.
+// By default its container is detected with ffprobe, whatever its extension, and
+// it must hold a video stream. With Options.InputExtensions only the extension is
+// checked, and with Options.SkipInputFormatCheck nothing is.
+func (t *Transcoder) checkInputFormat(ctx context.Context, inputPath string) error {
+	if t.options.SkipInputFormatCheck {
+		return nil
+	}
+
+	if len(t.options.InputExtensions) > 0 {
+		ext := strings.ToLower(filepath.Ext(inputPath))
+		for _, allowed := range t.options.InputExtensions {
+			allowed = strings.ToLower(allowed)
+			if allowed != "" && !strings.HasPrefix(allowed, ".") {
+				allowed = "." + allowed
+			}
+			if allowed == ext {
+				return nil
+			}
+		}
+		return errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrUnsupportedFileFormat),
+			fmt.Sprintf("Extension: %s", ext), errors.ErrUnsupportedFileFormat)
+	}
+
+	output, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrUnsupportedFileFormat)
+	}
+	for _, stream := range output.Streams {
+		if stream.CodecType == "video" {
+			t.logger.Debug("Input format detected", "transcoder", map[string]interface{}{
+				"path":   inputPath,
+				"format": output.Format.FormatName,
+			})
+			return nil
+		}
+	}
+	return errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrUnsupportedFileFormat),
+		fmt.Sprintf("No video stream found in %s (format: %s)", inputPath, output.Format.FormatName),
+		errors.ErrUnsupportedFileFormat)
+}
@@ -0,0 +1,111 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestCheckInputFormat(t *testing.T) {
+	dir := t.TempDir()
+	noExtension := filepath.Join(dir, "upload-7f3a")
+	if err := os.WriteFile(noExtension, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	audioOnly := `{"streams": [{"codec_type": "audio"}], "format": {"format_name": "mp3"}}`
+	failingProbe := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		return ffmpegtest.Result{Err: stderrors.New("exit status 1")}
+	}}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		runner   *ffmpegtest.Runner
+		wantCode int
+	}{
+		{name: "Detected by content", runner: newProbeRunner()},
+		{name: "Not a media file", runner: failingProbe, wantCode: errors.ErrUnsupportedFileFormat},
+		{name: "No video stream", runner: newProbeRunnerWith(audioOnly), wantCode: errors.ErrUnsupportedFileFormat},
+		{
+			name:     "Extension whitelist",
+			opts:     Options{InputExtensions: DefaultInputExtensions},
+			runner:   newProbeRunner(),
+			wantCode: errors.ErrUnsupportedFileFormat,
+		},
+		{
+			name:   "Extension whitelist match",
+			opts:   Options{InputExtensions: []string{"mp4", ""}},
+			runner: failingProbe,
+		},
+		{
+			name:   "Check disabled",
+			opts:   Options{SkipInputFormatCheck: true},
+			runner: failingProbe,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.InputPath = noExtension
+			tt.opts.OutputPath = filepath.Join(dir, "out")
+			trans, err := NewWithDeps(tt.opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(tt.runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			got, err := trans.handleInput(context.Background())
+			if tt.wantCode == 0 {
+				if err != nil || got != noExtension {
+					t.Errorf("handleInput() = %q, %v; want %q", got, err, noExtension)
+				}
+				return
+			}
+			sErr, ok := err.(*errors.StructuredError)
+			if !ok {
+				t.Fatalf("handleInput() should fail with a StructuredError, got %T (%v)", err, err)
+			}
+			if sErr.Type != errors.InvalidFileFormatError || sErr.Code != tt.wantCode {
+				t.Errorf("handleInput() error = %v, want code %d", sErr, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestInputProbedOnce(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	runner := newProbeRunner()
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "out"),
+		UseAutoResolutions: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.handleInput(context.Background()); err != nil {
+		t.Fatalf("handleInput() unexpected error: %v", err)
+	}
+	info, err := trans.probeInput(context.Background(), inputPath)
+	if err != nil {
+		t.Fatalf("probeInput() unexpected error: %v", err)
+	}
+	if info.Width != 1920 || info.Height != 1080 {
+		t.Errorf("probeInput() = %+v, want 1920x1080", info)
+	}
+
+	if calls := len(runner.Calls()); calls != 1 {
+		t.Errorf("ffprobe ran %d times, want 1", calls)
+	}
+}
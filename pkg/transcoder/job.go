@@ -0,0 +1,91 @@
+package transcoder
+
+import (
+	"context"
+
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// jobIDKey is the context key of the job identifier set with WithJobID.
+type jobIDKey struct{}
+
+// WithJobID returns a copy of ctx carrying jobID. Transcode uses it as the job
+// identifier when Options.JobID is not set, so that services can pass their
+// request identifiers down with the context.
+func WithJobID(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, jobID)
+}
+
+// JobIDFromContext returns the job identifier set with WithJobID, or an empty
+// string.
+func JobIDFromContext(ctx context.Context) string {
+	jobID, _ := ctx.Value(jobIDKey{}).(string)
+	return jobID
+}
+
+// jobLogger is a logger.Logger adding the job identifier to the data of every
+// message, so that logs of concurrent jobs can be told apart.
+type jobLogger struct {
+	next  logger.Logger
+	jobID string
+}
+
+// with returns data with the job_id field, without modifying data.
+func (l *jobLogger) with(data map[string]interface{}) map[string]interface{} {
+	if l.jobID == "" {
+		return data
+	}
+	fields := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		fields[key] = value
+	}
+	fields["job_id"] = l.jobID
+	return fields
+}
+
+func (l *jobLogger) Debug(message string, component string, data map[string]interface{}) {
+	l.next.Debug(message, component, l.with(data))
+}
+
+func (l *jobLogger) Info(message string, component string, data map[string]interface{}) {
+	l.next.Info(message, component, l.with(data))
+}
+
+func (l *jobLogger) Warn(message string, component string, data map[string]interface{}) {
+	l.next.Warn(message, component, l.with(data))
+}
+
+func (l *jobLogger) Error(message string, component string, data map[string]interface{}) {
+	l.next.Error(message, component, l.with(data))
+}
+
+func (l *jobLogger) Fatal(message string, component string, data map[string]interface{}) {
+	l.next.Fatal(message, component, l.with(data))
+}
+
+// reportEvent reports a lifecycle event to the progress reporter, if any, and
+// records the stage of the job it starts.
+func (t *Transcoder) reportEvent(name string, data map[string]interface{}) {
+	if stage, ok := jobStages[name]; ok {
+		t.stage = stage
+	}
+	if t.progRep != nil {
+		progress.ReportEvent(t.progRep, name, data)
+	}
+}
+
+// startJob sets the job identifier of the call to Transcode: Options.JobID when
+// it was set, otherwise the one of ctx, or the random default. The identifier
+// is attached to the logs, the progress events and the returned errors.
+func (t *Transcoder) startJob(ctx context.Context) {
+	if !t.fixedJobID {
+		if jobID := JobIDFromContext(ctx); jobID != "" {
+			t.options.JobID = jobID
+		}
+	}
+	t.jobLog.jobID = t.options.JobID
+	if t.progRep != nil {
+		progress.SetJobID(t.progRep, t.options.JobID)
+	}
+}
@@ -0,0 +1,162 @@
+package transcoder
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// recordingLogger is a logger.Logger recording the data of every message.
+type recordingLogger struct {
+	mu   sync.Mutex
+	data []map[string]interface{}
+}
+
+func (l *recordingLogger) record(data map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data = append(l.data, data)
+}
+
+func (l *recordingLogger) Debug(msg string, component string, data map[string]interface{}) {
+	l.record(data)
+}
+func (l *recordingLogger) Info(msg string, component string, data map[string]interface{}) {
+	l.record(data)
+}
+func (l *recordingLogger) Warn(msg string, component string, data map[string]interface{}) {
+	l.record(data)
+}
+func (l *recordingLogger) Error(msg string, component string, data map[string]interface{}) {
+	l.record(data)
+}
+func (l *recordingLogger) Fatal(msg string, component string, data map[string]interface{}) {
+	l.record(data)
+}
+
+func TestTranscodeJobID(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	transcode := func(ctx context.Context, jobID string, encodeErr error) (*recordingLogger, *progress.DefaultReporter, error) {
+		t.Helper()
+		log := &recordingLogger{}
+		reporter := progress.NewReporter(progress.WithBarWriter(io.Discard))
+		opts := Options{
+			InputPath:          inputPath,
+			OutputPath:         filepath.Join(t.TempDir(), "output.mp4"),
+			OutputType:         MP4Output,
+			JobID:              jobID,
+			SkipDiskSpaceCheck: true,
+		}
+		trans, err := NewWithDeps(opts, reporter, log, nil,
+			WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", encodeErr)}))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		_, err = trans.Transcode(ctx)
+		return log, reporter, err
+	}
+
+	ctx := WithJobID(context.Background(), "request-7")
+	log, reporter, err := transcode(ctx, "", nil)
+	if err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+	if len(log.data) == 0 {
+		t.Fatal("Transcode() logged nothing")
+	}
+	for _, data := range log.data {
+		if data["job_id"] != "request-7" {
+			t.Errorf("Log data %v, want job_id request-7 from the context", data)
+		}
+	}
+	if reporter.Event.JobID != "request-7" {
+		t.Errorf("Progress event JobID = %q, want request-7", reporter.Event.JobID)
+	}
+
+	// Options.JobID takes precedence, and is attached to errors
+	_, reporter, err = transcode(ctx, "job-42", errors.New(errors.TranscodingError, "Encode failed", "", 1))
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.JobID != "job-42" {
+		t.Errorf("Transcode() error = %#v, want a StructuredError with JobID job-42", err)
+	}
+	if reporter.Event.Status != "failed" || reporter.Event.JobID != "job-42" {
+		t.Errorf("Progress event = %s for %q, want failed for job-42", reporter.Event.Status, reporter.Event.JobID)
+	}
+}
+
+// eventReporter is a mockProgressReporter recording lifecycle events.
+type eventReporter struct {
+	mockProgressReporter
+	names []string
+	data  map[string]map[string]interface{}
+}
+
+func (r *eventReporter) ReportEvent(name string, data map[string]interface{}) {
+	r.names = append(r.names, name)
+	r.data[name] = data
+}
+
+func TestTranscodeLifecycleEvents(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	reporter := &eventReporter{data: map[string]map[string]interface{}{}}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "output.mp4"),
+		OutputType:         MP4Output,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, reporter, newDiscardLogger(), nil,
+		WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}), WithUploader(&recordingUploader{}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	want := []string{
+		progress.EventInputValidated,
+		progress.EventInputProbed,
+		progress.EventEncodeStarted,
+		progress.EventProgressEstimated,
+		progress.EventEncodeCompleted,
+		progress.EventUploadStarted,
+		progress.EventUploadCompleted,
+	}
+	if !reflect.DeepEqual(reporter.names, want) {
+		t.Errorf("Lifecycle events = %v, want %v", reporter.names, want)
+	}
+	command, _ := reporter.data[progress.EventEncodeStarted]["command"].(string)
+	if !strings.HasPrefix(command, "ffmpeg -i "+inputPath) {
+		t.Errorf("encode_started command = %q, want the ffmpeg command", command)
+	}
+	if source := reporter.data[progress.EventInputProbed]; source["height"] != 1080 || source["frame_rate"] != 25.0 || source["duration_seconds"] != 60.0 {
+		t.Errorf("input_probed data = %v, want the source metadata", source)
+	}
+	// The MP4 progress is measured against the probed duration
+	if estimate := reporter.data[progress.EventProgressEstimated]; estimate["basis"] != "duration" || estimate["duration_seconds"] != 60.0 {
+		t.Errorf("progress_estimated data = %v, want the probed duration", estimate)
+	}
+	if files := reporter.data[progress.EventUploadCompleted]["files"]; files != 1 {
+		t.Errorf("upload_completed files = %v, want 1", files)
+	}
+}
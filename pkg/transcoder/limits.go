@@ -0,0 +1,124 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// validateInputLimits checks that the input limits are not negative.
+func validateInputLimits(options *Options, p *optionProblems) {
+	if options.MaxInputDuration < 0 {
+		p.add("MaxInputDuration", errors.New(errors.ValidationError, "Invalid maximum input duration",
+			fmt.Sprintf("The duration must not be negative, got %s", options.MaxInputDuration), 4))
+	}
+	if options.MaxInputSizeBytes < 0 {
+		p.add("MaxInputSizeBytes", errors.New(errors.ValidationError, "Invalid maximum input size",
+			fmt.Sprintf("The size must not be negative, got %d", options.MaxInputSizeBytes), 4))
+	}
+}
+
+// checkInputLimits probes the input and fails with ErrInputTooLong if it is
+// longer than MaxInputDuration. The size of streamed inputs the preflight
+// check could not read is also checked against MaxInputSizeBytes. Inputs
+// whose duration or size is unknown, such as live streams, are accepted.
+func (t *Transcoder) checkInputLimits(ctx context.Context, inputPath string) error {
+	streamed := t.options.IsRemoteInput && t.options.StreamFromURL
+	if t.options.MaxInputDuration == 0 && !(streamed && t.options.MaxInputSizeBytes > 0) {
+		return nil
+	}
+	probe, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrInvalidFileFormat)
+	}
+
+	if streamed {
+		if err := t.checkInputSize(parseInt(probe.Format.Size)); err != nil {
+			return err
+		}
+	}
+	if t.options.MaxInputDuration == 0 {
+		return nil
+	}
+	seconds := parseFloat(probe.Format.Duration)
+	if seconds <= 0 {
+		t.logger.Warn("Unknown input duration, the maximum input duration is not enforced", "transcoder", map[string]interface{}{
+			"input": redactURL(t.options.InputPath),
+		})
+		return nil
+	}
+	duration := time.Duration(seconds * float64(time.Second))
+	if duration <= t.options.MaxInputDuration {
+		return nil
+	}
+	return errors.New(errors.ValidationError, errors.GetErrorMessage(errors.ErrInputTooLong),
+		fmt.Sprintf("%s: %s long, more than the maximum of %s", redactURL(t.options.InputPath),
+			duration.Round(time.Second), t.options.MaxInputDuration), errors.ErrInputTooLong)
+}
+
+// checkInputSize fails with ErrInputTooLarge if size bytes is more than
+// MaxInputSizeBytes. A size of 0 means unknown and is accepted.
+func (t *Transcoder) checkInputSize(size int64) error {
+	if t.options.MaxInputSizeBytes == 0 || size <= t.options.MaxInputSizeBytes {
+		return nil
+	}
+	return errors.New(errors.ValidationError, errors.GetErrorMessage(errors.ErrInputTooLarge),
+		fmt.Sprintf("%s: %d bytes, more than the maximum of %d", redactURL(t.options.InputPath),
+			size, t.options.MaxInputSizeBytes), errors.ErrInputTooLarge)
+}
+
+// checkRemoteInputSize asks the server for the size of the remote input with
+// a HEAD request (or a GET range request when HEAD is rejected) and checks it
+// against MaxInputSizeBytes before downloading it. Servers that fail the
+// request or do not announce the size are left to the download, which stops
+// at the limit.
+func (t *Transcoder) checkRemoteInputSize(ctx context.Context, inputURL *url.URL) error {
+	if t.options.MaxInputSizeBytes == 0 {
+		return nil
+	}
+	timeout := t.options.PreflightTimeout
+	if timeout <= 0 {
+		timeout = DefaultPreflightTimeout
+	}
+	client := http.Client{
+		Timeout: timeout,
+	}
+
+	resp, err := t.preflightRequest(ctx, &client, http.MethodHead, inputURL.String())
+	if err == nil && headRejected(resp.StatusCode) {
+		resp, err = t.preflightRequest(ctx, &client, http.MethodGet, inputURL.String())
+	}
+	if err != nil || resp.StatusCode >= 400 {
+		t.logger.Debug("Could not read the size of the remote input before downloading it", "transcoder", map[string]interface{}{
+			"url": redactURL(inputURL.String()),
+		})
+		return nil
+	}
+	return t.checkInputSize(announcedSize(resp))
+}
+
+// announcedSize returns the size of the resource of a preflight response: the
+// total of the Content-Range of a range request, or else the Content-Length.
+// It returns 0 if the size is unknown.
+func announcedSize(resp *http.Response) int64 {
+	if resp.StatusCode == http.StatusPartialContent {
+		contentRange := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+			if err == nil && size > 0 {
+				return size
+			}
+		}
+		return 0
+	}
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
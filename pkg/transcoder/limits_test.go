@@ -0,0 +1,145 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/downloader"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestTranscodeInputLimits(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		maxDuration time.Duration
+		maxSize     int64
+		wantCode    int
+	}{
+		{name: "Too long", maxDuration: 30 * time.Second, wantCode: errors.ErrInputTooLong},
+		{name: "Too large", maxSize: 4, wantCode: errors.ErrInputTooLarge},
+		{name: "Within the limits", maxDuration: 2 * time.Minute, maxSize: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         filepath.Join(dir, tt.name, "video.mp4"),
+				OutputType:         MP4Output,
+				MaxInputDuration:   tt.maxDuration,
+				MaxInputSizeBytes:  tt.maxSize,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("Transcode() unexpected error: %v", err)
+				}
+				return
+			}
+			sErr, ok := err.(*errors.StructuredError)
+			if !ok || sErr.Code != tt.wantCode || sErr.Type != errors.ValidationError {
+				t.Fatalf("Transcode() error = %v, want a validation error with code %d", err, tt.wantCode)
+			}
+			if _, err := os.Stat(opts.OutputPath); !os.IsNotExist(err) {
+				t.Error("A rejected input should not be encoded")
+			}
+		})
+	}
+
+	if _, err := NewWithDeps(Options{InputPath: inputPath, OutputPath: dir, MaxInputDuration: -time.Second},
+		&mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+		t.Error("NewWithDeps() expected an error for a negative maximum input duration")
+	}
+}
+
+func TestRemoteInputSize(t *testing.T) {
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/presigned.mp4":
+			// Pre-signed URLs are only valid for the method they were signed for
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Range", "bytes 0-0/1000")
+			w.WriteHeader(http.StatusPartialContent)
+		case "/small.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+			if r.Method == http.MethodGet {
+				downloads++
+			}
+			fmt.Fprint(w, "video")
+		default:
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Header().Set("Content-Length", "1000")
+			if r.Method == http.MethodGet {
+				downloads++
+			}
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		streamed bool
+		wantCode int
+	}{
+		{name: "Downloaded", path: "/large.mp4", wantCode: errors.ErrInputTooLarge},
+		{name: "HEAD rejected", path: "/presigned.mp4", wantCode: errors.ErrInputTooLarge},
+		{name: "Streamed", path: "/large.mp4", streamed: true, wantCode: errors.ErrInputTooLarge},
+		{name: "Within the limit", path: "/small.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads = 0
+			opts := Options{
+				InputPath:          server.URL + tt.path,
+				OutputPath:         t.TempDir(),
+				DownloadDir:        t.TempDir(),
+				StreamFromURL:      tt.streamed,
+				MaxInputSizeBytes:  100,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), downloader.New(downloader.Options{}))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.handleInput(context.Background())
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("handleInput() unexpected error: %v", err)
+				}
+				return
+			}
+			sErr, ok := err.(*errors.StructuredError)
+			if !ok || sErr.Code != tt.wantCode {
+				t.Fatalf("handleInput() error = %v, want code %d", err, tt.wantCode)
+			}
+			if downloads != 0 {
+				t.Errorf("The input was downloaded %d times, want none", downloads)
+			}
+		})
+	}
+}
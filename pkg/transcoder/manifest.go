@@ -0,0 +1,271 @@
+package transcoder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/version"
+)
+
+// DefaultManifestFileName is the file name of the job manifest when
+// Options.ManifestFileName is empty.
+const DefaultManifestFileName = "hlspresso.json"
+
+// Manifest records how an output was produced: the source, the options, the
+// ladder, the tools and timings, and a checksum of every produced file. It is
+// written next to the output when Options.WriteManifest is set.
+type Manifest struct {
+	JobID         string `json:"job_id"`
+	Version       string `json:"hlspresso_version"`
+	FFmpegVersion string `json:"ffmpeg_version,omitempty"`
+
+	Input ManifestInput `json:"input"`
+	// Options are the options of the job, with credentials redacted.
+	Options Options `json:"options"`
+	// Ladder lists the HLS renditions produced, after the automatic ladder and
+	// the upscale and size budget adjustments.
+	Ladder []hls.VideoResolution `json:"ladder,omitempty"`
+
+	OutputType OutputType `json:"output_type"`
+	OutputPath string     `json:"output_path"`
+	// Cached is set when the output was restored from the cache.
+	Cached bool `json:"cached,omitempty"`
+
+	StartedAt      time.Time `json:"started_at"`
+	CompletedAt    time.Time `json:"completed_at"`
+	EncodeSeconds  float64   `json:"encode_seconds"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+
+	// Files lists the produced files, relative to the manifest directory.
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestInput describes the source of a job.
+type ManifestInput struct {
+	// Path is the input path or URL, with URL credentials redacted.
+	Path string `json:"path"`
+	// Source is the URL the input was read from, InputPath or one of the
+	// Options.InputMirrors, redacted. It is only set when mirrors are given.
+	Source    string `json:"source,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	// SHA256 is the checksum of the input; empty for streamed inputs.
+	SHA256 string     `json:"sha256,omitempty"`
+	Info   *VideoInfo `json:"info,omitempty"`
+}
+
+// ManifestFile is a file produced by a job.
+type ManifestFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// ReadManifest reads the manifest at path.
+func ReadManifest(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// manifestPath returns the path of the job manifest, in the sidecar directory.
+func (t *Transcoder) manifestPath() string {
+	fileName := t.options.ManifestFileName
+	if fileName == "" {
+		fileName = DefaultManifestFileName
+	}
+	return filepath.Join(t.sidecarDir(), fileName)
+}
+
+// writeManifest writes the job manifest for the output just produced.
+// Errors are logged as warnings since the main output was already produced.
+func (t *Transcoder) writeManifest(ctx context.Context, inputPath string) {
+	manifestPath := t.manifestPath()
+	manifest, err := t.buildManifest(ctx, inputPath, filepath.Dir(manifestPath), manifestPath, t.checksumPath())
+	if err != nil {
+		t.logger.Warn("Failed to checksum the output files", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		t.logger.Warn("Failed to marshal job manifest", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := os.WriteFile(manifestPath, content, 0644); err != nil {
+		t.logger.Warn("Failed to write job manifest", "transcoder", map[string]interface{}{
+			"path":  manifestPath,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	t.logger.Info("Job manifest created", "transcoder", map[string]interface{}{
+		"manifest": manifestPath,
+		"files":    len(manifest.Files),
+	})
+}
+
+// jobManifest returns the manifest of the current job without the details of
+// its output: the job, input and options.
+func (t *Transcoder) jobManifest() *Manifest {
+	manifest := &Manifest{
+		JobID:      t.options.JobID,
+		Version:    version.Get().Version,
+		Input:      ManifestInput{Path: redactURL(t.options.InputPath), Source: redactURL(t.inputSource), Info: t.inputInfo},
+		Options:    redactOptions(t.options),
+		OutputType: t.options.OutputType,
+		OutputPath: t.options.OutputPath,
+		Cached:     t.cached,
+		StartedAt:  t.startedAt.UTC(),
+	}
+	if t.options.OutputType == HLSOutput {
+		manifest.Ladder = t.options.HLSResolutions
+	}
+	return manifest
+}
+
+// buildManifest returns the manifest of the output just produced, listing its
+// files relative to dir but the excluded ones (see outputFiles).
+func (t *Transcoder) buildManifest(ctx context.Context, inputPath, dir string, exclude ...string) (*Manifest, error) {
+	manifest := t.jobManifest()
+	if ffmpegVersion, err := version.FFmpegVersion(ctx, t.runner, t.options.FFmpegBinary); err == nil {
+		manifest.FFmpegVersion = ffmpegVersion
+	}
+	if !(t.options.IsRemoteInput && t.options.StreamFromURL) {
+		if file, err := checksumFile(inputPath); err == nil {
+			manifest.Input.SizeBytes, manifest.Input.SHA256 = file.SizeBytes, file.SHA256
+		}
+	}
+
+	files, err := t.outputFiles(dir, exclude...)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = files
+
+	if t.options.Deterministic {
+		// Timings differ on every run
+		manifest.StartedAt = time.Time{}
+	} else {
+		manifest.CompletedAt = time.Now().UTC()
+		manifest.EncodeSeconds = t.encodeDuration.Seconds()
+		manifest.ElapsedSeconds = manifest.CompletedAt.Sub(manifest.StartedAt).Seconds()
+	}
+	return manifest, nil
+}
+
+// outputFiles checksums the produced files: every file of the HLS output
+// directory (including previews and reports) but the excluded ones, or the MP4
+// file. Paths are relative to dir.
+func (t *Transcoder) outputFiles(dir string, exclude ...string) ([]ManifestFile, error) {
+	excluded := make(map[string]bool, len(exclude))
+	for _, path := range exclude {
+		excluded[path] = true
+	}
+	var paths []string
+	if t.options.OutputType == MP4Output {
+		paths = []string{t.options.OutputPath}
+	} else {
+		err := filepath.WalkDir(t.options.OutputPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && !excluded[path] {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := make([]ManifestFile, 0, len(paths))
+	for _, path := range paths {
+		file, err := checksumFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			file.Path = filepath.ToSlash(rel)
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// checksumFile returns the size and SHA-256 of the file at path.
+func checksumFile(path string) (ManifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	return ManifestFile{Path: path, SizeBytes: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// redactOptions returns options with the upload credentials removed.
+func redactOptions(options Options) Options {
+	options.InputPath = redactURL(options.InputPath)
+	options.UploadURL = redactURL(options.UploadURL)
+	if len(options.InputMirrors) > 0 {
+		mirrors := make([]string, len(options.InputMirrors))
+		for i, mirror := range options.InputMirrors {
+			mirrors[i] = redactURL(mirror)
+		}
+		options.InputMirrors = mirrors
+	}
+	for _, token := range []*string{&options.GoogleDriveToken, &options.DropboxToken} {
+		if *token != "" {
+			*token = "REDACTED"
+		}
+	}
+	if len(options.UploadHeaders) > 0 {
+		headers := make(map[string]string, len(options.UploadHeaders))
+		for name := range options.UploadHeaders {
+			headers[name] = "REDACTED"
+		}
+		options.UploadHeaders = headers
+	}
+	return options
+}
+
+// redactURL hides the password and query string (which often carries signing
+// tokens) of a URL; other values are returned unchanged.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.Redacted()
+}
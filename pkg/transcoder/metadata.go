@@ -0,0 +1,161 @@
+package transcoder
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// timedMetadataCodecs maps the codec of the input data streams carrying timed
+// metadata to the kind of metadata.
+var timedMetadataCodecs = map[string]string{
+	"scte_35":   hls.MetadataSCTE35,
+	"timed_id3": hls.MetadataID3,
+}
+
+// ffprobePackets represents the JSON output of ffprobe with the -show_packets
+// and -show_data flags.
+type ffprobePackets struct {
+	Packets []struct {
+		StreamIndex int    `json:"stream_index"`
+		PTSTime     string `json:"pts_time"`
+		DTSTime     string `json:"dts_time"`
+		Data        string `json:"data"`
+	} `json:"packets"`
+}
+
+// passthroughTimedMetadata copies the timed metadata of the input to the media
+// playlists of the HLS output at outputDir (see Options.PassthroughMetadata).
+func (t *Transcoder) passthroughTimedMetadata(ctx context.Context, inputPath, outputDir string) error {
+	metadata, err := t.readTimedMetadata(ctx, inputPath)
+	if err != nil {
+		if isStageTimeout(err) {
+			return err
+		}
+		return errors.Wrap(err, errors.HLSError, "Failed to read the timed metadata of the input", 18)
+	}
+	if len(metadata) == 0 {
+		t.logger.Info("No timed metadata to pass through", "transcoder", map[string]interface{}{
+			"input": inputPath,
+		})
+		return nil
+	}
+
+	playlists, err := filepath.Glob(filepath.Join(outputDir, "stream_*", "playlist.m3u8"))
+	if err != nil {
+		return errors.Wrap(err, errors.HLSError, "Failed to list the media playlists", 18)
+	}
+	// Every variant must anchor the date ranges to the same date
+	start := time.Now()
+	if t.options.Deterministic {
+		start = time.Unix(0, 0)
+	}
+	for _, playlist := range playlists {
+		if err := hls.InsertDateRanges(playlist, start, metadata); err != nil {
+			return errors.Wrap(err, errors.HLSError, "Failed to add the timed metadata to the playlist", 18)
+		}
+	}
+
+	t.logger.Info("Timed metadata passed through", "transcoder", map[string]interface{}{
+		"entries":   len(metadata),
+		"playlists": len(playlists),
+	})
+	return nil
+}
+
+// readTimedMetadata returns the SCTE-35 and timed ID3 payloads of the input,
+// with their offsets from the start of the output. Returns nil if the input has
+// no such data streams.
+func (t *Transcoder) readTimedMetadata(ctx context.Context, inputPath string) ([]hls.TimedMetadata, error) {
+	probe, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	kinds := make(map[int]string)
+	for _, stream := range probe.Streams {
+		if kind, ok := timedMetadataCodecs[stream.CodecName]; ok && stream.CodecType == "data" {
+			kinds[stream.Index] = kind
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	// ffmpeg shifts the output timestamps so that the output starts at zero
+	startTime, _ := strconv.ParseFloat(probe.Format.StartTime, 64)
+
+	// Reading every packet of the input may take longer than a single probe
+	output, err := t.runner.Output(ctx, "ffprobe",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "d",
+		"-show_packets",
+		"-show_data",
+		inputPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed to read the data packets: %w", err)
+	}
+	var packets ffprobePackets
+	if err := json.Unmarshal(output, &packets); err != nil {
+		return nil, fmt.Errorf("failed to parse the ffprobe packets: %w", err)
+	}
+
+	var metadata []hls.TimedMetadata
+	for _, packet := range packets.Packets {
+		kind, ok := kinds[packet.StreamIndex]
+		if !ok {
+			continue
+		}
+		pts, err := strconv.ParseFloat(packet.PTSTime, 64)
+		if err != nil {
+			// SCTE-35 packets may only carry a decoding timestamp
+			if pts, err = strconv.ParseFloat(packet.DTSTime, 64); err != nil {
+				t.logger.Warn("Skipping timed metadata without timestamp", "transcoder", map[string]interface{}{
+					"kind": kind,
+				})
+				continue
+			}
+		}
+		data, err := parseHexDump(packet.Data)
+		if err != nil || len(data) == 0 {
+			t.logger.Warn("Skipping unreadable timed metadata", "transcoder", map[string]interface{}{
+				"kind": kind,
+				"time": pts,
+			})
+			continue
+		}
+		metadata = append(metadata, hls.TimedMetadata{Kind: kind, Offset: pts - startTime, Data: data})
+	}
+	return metadata, nil
+}
+
+// parseHexDump decodes the hex dump of packet data printed by ffprobe with
+// -show_data, e.g. "\n00000000: fc30 1100 0000 ...  .0......". Each line holds
+// an offset, up to 16 bytes as 8 groups of 4 hex digits, and their ASCII form.
+func parseHexDump(dump string) ([]byte, error) {
+	var data []byte
+	for _, line := range strings.Split(dump, "\n") {
+		_, rest, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		// The hex columns are 40 characters wide, padded on the last line
+		if len(rest) > 40 {
+			rest = rest[:40]
+		}
+		chunk, err := hex.DecodeString(strings.ReplaceAll(rest, " ", ""))
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
@@ -0,0 +1,104 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestParseHexDump(t *testing.T) {
+	dump := "\n00000000: fc30 1100 0000 0000 0000 fff0 0005 0000  .0..............\n" +
+		"00000010: 007f ef                                  ..."
+	got, err := parseHexDump(dump)
+	want := []byte{0xfc, 0x30, 0x11, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xf0, 0, 0x05, 0, 0, 0, 0x7f, 0xef}
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("parseHexDump() = %x, %v; want %x", got, err, want)
+	}
+}
+
+// broadcastProbeJSON describes a transport stream with SCTE-35 and ID3 data streams.
+const broadcastProbeJSON = `{
+	"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "r_frame_rate": "30000/1001"},
+		{"index": 1, "codec_type": "audio", "codec_name": "aac"},
+		{"index": 2, "codec_type": "data", "codec_name": "scte_35"},
+		{"index": 3, "codec_type": "data", "codec_name": "timed_id3"},
+		{"index": 4, "codec_type": "data", "codec_name": "klv"}
+	],
+	"format": {"format_name": "mpegts", "start_time": "1.400000", "duration": "12.000000"}
+}`
+
+const broadcastPacketsJSON = `{
+	"packets": [
+		{"stream_index": 3, "pts_time": "1.600000", "data": "\n00000000: 4944 33                                  ID3"},
+		{"stream_index": 2, "pts_time": "N/A", "dts_time": "7.400000", "data": "\n00000000: fc30 11                                  .0."},
+		{"stream_index": 4, "pts_time": "2.000000", "data": "\n00000000: 0102                                     .."}
+	]
+}`
+
+func TestTranscodePassthroughMetadata(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.ts")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputDir := filepath.Join(dir, "hls")
+
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		switch {
+		case name == "ffprobe" && slices.Contains(args, "-show_packets"):
+			return ffmpegtest.Result{Stdout: broadcastPacketsJSON}
+		case name == "ffprobe" && slices.Contains(args, "-show_streams"):
+			return ffmpegtest.Result{Stdout: broadcastProbeJSON}
+		case name == "ffprobe":
+			return ffmpegtest.Result{Stdout: "360\n"}
+		case len(args) == 1:
+			return scriptedFFmpeg("", nil)(name, args)
+		}
+		os.WriteFile(filepath.Join(outputDir, "master.m3u8"), []byte("#EXTM3U\n"), 0644)
+		for _, stream := range []string{"stream_0", "stream_1"} {
+			os.MkdirAll(filepath.Join(outputDir, stream), 0755)
+			os.WriteFile(filepath.Join(outputDir, stream, "playlist.m3u8"),
+				[]byte("#EXTM3U\n#EXTINF:6.000000,\ndata000.ts\n#EXTINF:6.000000,\ndata001.ts\n#EXT-X-ENDLIST\n"), 0644)
+		}
+		return ffmpegtest.Result{}
+	}}
+
+	opts := Options{
+		InputPath:           inputPath,
+		OutputPath:          outputDir,
+		OutputType:          HLSOutput,
+		HLSResolutions:      []hls.VideoResolution{hls.DefaultResolutions[0], hls.DefaultResolutions[1]},
+		PassthroughMetadata: true,
+		SkipDiskSpaceCheck:  true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	for _, stream := range []string{"stream_0", "stream_1"} {
+		content, _ := os.ReadFile(filepath.Join(outputDir, stream, "playlist.m3u8"))
+		lines := strings.Split(string(content), "\n")
+		// ID3 at 0.2s before the first segment, SCTE-35 at 6s before the second one
+		if len(lines) < 7 ||
+			!strings.HasPrefix(lines[1], "#EXT-X-PROGRAM-DATE-TIME:") ||
+			!strings.HasPrefix(lines[2], `#EXT-X-DATERANGE:ID="id3-1"`) || !strings.HasSuffix(lines[2], "X-ID3=0x494433") ||
+			!strings.HasPrefix(lines[5], `#EXT-X-DATERANGE:ID="scte35-1"`) || !strings.HasSuffix(lines[5], "SCTE35-CMD=0xFC3011") {
+			t.Errorf("%s playlist =\n%s", stream, content)
+		}
+		if strings.Contains(string(content), "0x0102") {
+			t.Errorf("%s playlist contains the KLV data stream", stream)
+		}
+	}
+}
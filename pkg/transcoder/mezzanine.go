@@ -0,0 +1,83 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/encoder"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// mezzanineFormats maps the mezzanine output types to their encoder format.
+var mezzanineFormats = map[OutputType]encoder.Format{
+	ProResOutput: encoder.FormatProRes,
+	DNxHROutput:  encoder.FormatDNxHR,
+}
+
+// validateMezzanines lowercases the Mezzanines and checks that each is a
+// mezzanine output type, listed once.
+func validateMezzanines(options *Options, p *optionProblems) {
+	for i, output := range options.Mezzanines {
+		output = OutputType(strings.ToLower(strings.TrimSpace(string(output))))
+		options.Mezzanines[i] = output
+		switch {
+		case mezzanineFormats[output] == "":
+			p.add("Mezzanines", errors.New(errors.ValidationError, "Invalid mezzanine output",
+				fmt.Sprintf("Expected %s or %s, got %q", ProResOutput, DNxHROutput, output), 4))
+		case slices.Contains(options.Mezzanines[:i], output):
+			p.add("Mezzanines", errors.New(errors.ValidationError, "Invalid mezzanine output",
+				fmt.Sprintf("%s is listed more than once", output), 4))
+		}
+	}
+}
+
+// mezzaninePath returns the path of the mezzanine file of type output (see
+// Options.Mezzanines).
+func (t *Transcoder) mezzaninePath(output OutputType) string {
+	name := "mezzanine"
+	if t.options.OutputType == MP4Output {
+		name = strings.TrimSuffix(filepath.Base(t.options.OutputPath), filepath.Ext(t.options.OutputPath))
+	}
+	return filepath.Join(t.sidecarDir(), name+"_"+string(output)+".mov")
+}
+
+// createMezzanines encodes the Mezzanines from the input, one after the
+// other.
+func (t *Transcoder) createMezzanines(ctx context.Context, inputPath string) error {
+	for _, output := range t.options.Mezzanines {
+		if err := t.createMezzanine(ctx, inputPath, output, t.mezzaninePath(output)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createMezzanine encodes the mezzanine file of type output from the input to
+// outputPath.
+func (t *Transcoder) createMezzanine(ctx context.Context, inputPath string, output OutputType, outputPath string) error {
+	if _, err := os.Stat(outputPath); err == nil && !t.options.AllowOverwrite {
+		return errors.New(errors.InvalidOutputPathError,
+			"O arquivo mezanino já existe e a sobrescrita não está permitida",
+			outputPath, errors.ErrInvalidOutputPath)
+	}
+
+	t.logger.Info("Creating mezzanine file", "transcoder", map[string]interface{}{
+		"type":   string(output),
+		"output": outputPath,
+	})
+	_, err := t.encode(ctx, encoder.Job{
+		Format:        mezzanineFormats[output],
+		InputPath:     inputPath,
+		OutputPath:    outputPath,
+		InputParams:   t.options.FFmpegInputParams,
+		LogOutput:     t.options.LogFFmpegOutput,
+		Deterministic: t.options.Deterministic,
+		Deinterlace:   t.deinterlacer(ctx, inputPath),
+		Color:         t.colorSettings(ctx, inputPath),
+	})
+	return err
+}
@@ -0,0 +1,75 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestCreateMezzanines(t *testing.T) {
+	dir := t.TempDir()
+	var outputs []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffprobe" {
+			return ffmpegtest.Result{Stdout: `{
+				"streams": [{"index": 0, "codec_type": "video", "width": 1920, "height": 1080, "pix_fmt": "yuv420p", "field_order": "tt"}],
+				"format": {"format_name": "mpegts", "duration": "60.0"}
+			}`}
+		}
+		outputs = append(outputs, strings.Join(args[len(args)-3:], " "))
+		if !slices.Contains(args, "bwdif=mode=send_frame,format=yuv422p10le") && !slices.Contains(args, "bwdif=mode=send_frame,format=yuv422p") {
+			t.Errorf("args = %q, want the deinterlaced video in 4:2:2", args)
+		}
+		return ffmpegtest.Result{}
+	}}
+	trans, err := NewWithDeps(Options{
+		InputPath:  "master.ts",
+		OutputPath: filepath.Join(dir, "movie.mp4"),
+		OutputType: MP4Output,
+		Mezzanines: []OutputType{" ProRes", DNxHROutput},
+	}, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	// Os mezaninos são escritos ao lado do MP4, com o tipo como sufixo
+	if err := trans.createMezzanines(context.Background(), "master.ts"); err != nil {
+		t.Fatalf("createMezzanines() error = %v", err)
+	}
+	want := []string{
+		"mov -y " + filepath.Join(dir, "movie_prores.mov"),
+		"mov -y " + filepath.Join(dir, "movie_dnxhr.mov"),
+	}
+	if !slices.Equal(outputs, want) {
+		t.Errorf("outputs = %q, want %q", outputs, want)
+	}
+
+	// Na saída HLS, os mezaninos ficam dentro do diretório de saída
+	trans.options.OutputType, trans.options.OutputPath = HLSOutput, dir
+	if got := trans.mezzaninePath(ProResOutput); got != filepath.Join(dir, "mezzanine_prores.mov") {
+		t.Errorf("mezzaninePath() = %q", got)
+	}
+
+	// Um mezanino existente não é sobrescrito sem AllowOverwrite
+	if err := os.WriteFile(filepath.Join(dir, "mezzanine_prores.mov"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := trans.createMezzanines(context.Background(), "master.ts"); err == nil {
+		t.Error("createMezzanines() expected an error for an existing file")
+	}
+
+	for name, mezzanines := range map[string][]OutputType{
+		"unknown":   {"xdcam"},
+		"streaming": {MP4Output},
+		"duplicate": {ProResOutput, "prores"},
+	} {
+		if err := ValidateOptions(Options{InputPath: "input.mp4", OutputPath: "output", Mezzanines: mezzanines}); err == nil {
+			t.Errorf("%s: ValidateOptions() expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// validateInputMirrors checks that the input mirrors are http(s) URLs of a
+// remote input.
+func validateInputMirrors(options *Options, p *optionProblems) {
+	if len(options.InputMirrors) == 0 {
+		return
+	}
+	if !isHTTPURL(options.InputPath) {
+		p.add("InputMirrors", errors.New(errors.ValidationError, "Invalid input mirrors",
+			"Mirrors can only be used with an http(s) input URL", 4))
+	}
+	for i, mirror := range options.InputMirrors {
+		if !isHTTPURL(mirror) {
+			p.add(fmt.Sprintf("InputMirrors[%d]", i), errors.New(errors.ValidationError, "Invalid input mirror",
+				fmt.Sprintf("Expected an http(s) URL, got %q", redactURL(mirror)), 4))
+		}
+	}
+}
+
+// isHTTPURL reports whether value is an absolute http(s) URL.
+func isHTTPURL(value string) bool {
+	u, err := url.ParseRequestURI(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// openMirroredInput opens InputPath, or else its mirrors in order: a source
+// failing with a network or download error (DNS or connection failure, HTTP
+// error status, interrupted download) is skipped for the next one. The source
+// used is recorded in inputSource. Streamed inputs only fall back when the
+// preflight check fails, since ffmpeg reads them later.
+func (t *Transcoder) openMirroredInput(ctx context.Context) (string, error) {
+	sources := append([]string{t.options.InputPath}, t.options.InputMirrors...)
+	for i, source := range sources {
+		inputPath, err := t.openInput(ctx, source)
+		if err == nil {
+			t.inputSource = source
+			if i > 0 {
+				t.logger.Info("Using an input mirror", "transcoder", map[string]interface{}{
+					"source": redactURL(source),
+				})
+			}
+			return inputPath, nil
+		}
+		if ctx.Err() != nil || !sourceFailed(err) || i == len(sources)-1 {
+			return "", err
+		}
+		t.logger.Warn("Failed to read the input, trying the next mirror", "transcoder", map[string]interface{}{
+			"source": redactURL(source),
+			"next":   redactURL(sources[i+1]),
+			"error":  err.Error(),
+		})
+	}
+	return "", nil // Inalcançável: sources nunca está vazio
+}
+
+// sourceFailed reports whether err is a failure of the input source, after
+// which another copy of the input may succeed.
+func sourceFailed(err error) bool {
+	var sErr *errors.StructuredError
+	if !stderrors.As(err, &sErr) {
+		return false
+	}
+	return sErr.Type == errors.NetworkError || sErr.Type == errors.DownloadError
+}
@@ -0,0 +1,98 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/downloader"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+func TestInputMirrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.mp4":
+			w.WriteHeader(http.StatusNotFound)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+		default:
+			w.Header().Set("Content-Type", "video/mp4")
+			fmt.Fprint(w, "video")
+		}
+	}))
+	defer server.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close() // Conexão recusada
+
+	tests := []struct {
+		name       string
+		input      string
+		mirrors    []string
+		streamed   bool
+		wantSource string
+		wantCode   int
+	}{
+		{name: "Primary", input: server.URL + "/video.mp4", mirrors: []string{down.URL + "/video.mp4"}, wantSource: server.URL + "/video.mp4"},
+		{name: "HTTP error", input: server.URL + "/missing.mp4", mirrors: []string{server.URL + "/video.mp4"}, wantSource: server.URL + "/video.mp4"},
+		{name: "Connection refused", input: down.URL + "/video.mp4", mirrors: []string{server.URL + "/missing.mp4", server.URL + "/video.mp4"}, wantSource: server.URL + "/video.mp4"},
+		{name: "Streamed", input: down.URL + "/video.mp4", mirrors: []string{server.URL + "/video.mp4"}, streamed: true, wantSource: server.URL + "/video.mp4"},
+		{name: "All failed", input: down.URL + "/video.mp4", mirrors: []string{server.URL + "/missing.mp4"}, wantCode: 7},
+		// A resposta inválida não é uma falha da fonte
+		{name: "Not a video", input: server.URL + "/page.html", mirrors: []string{server.URL + "/video.mp4"}, streamed: true, wantCode: errors.ErrInvalidFileFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{
+				InputPath:          tt.input,
+				InputMirrors:       tt.mirrors,
+				OutputPath:         t.TempDir(),
+				DownloadDir:        t.TempDir(),
+				StreamFromURL:      tt.streamed,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), downloader.New(downloader.Options{}))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			inputPath, err := trans.handleInput(context.Background())
+			if tt.wantCode != 0 {
+				sErr, ok := err.(*errors.StructuredError)
+				if !ok || sErr.Code != tt.wantCode {
+					t.Fatalf("handleInput() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handleInput() unexpected error: %v", err)
+			}
+			if trans.inputSource != tt.wantSource {
+				t.Errorf("Input source = %q, want %q", trans.inputSource, tt.wantSource)
+			}
+			if got := trans.jobManifest().Input.Source; got != tt.wantSource {
+				t.Errorf("Manifest source = %q, want %q", got, tt.wantSource)
+			}
+			if tt.streamed {
+				if inputPath != tt.wantSource {
+					t.Errorf("handleInput() = %q, want the mirror URL", inputPath)
+				}
+			} else if content, err := os.ReadFile(inputPath); err != nil || string(content) != "video" {
+				t.Errorf("Downloaded input = %q, %v", content, err)
+			}
+		})
+	}
+
+	for name, opts := range map[string]Options{
+		"local input":  {InputPath: "video.mp4", InputMirrors: []string{server.URL + "/video.mp4"}},
+		"local mirror": {InputPath: server.URL + "/video.mp4", InputMirrors: []string{"video.mp4"}, StreamFromURL: true},
+	} {
+		opts.OutputPath = t.TempDir()
+		if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+			t.Errorf("%s: NewWithDeps() expected a validation error", name)
+		}
+	}
+}
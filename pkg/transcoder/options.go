@@ -2,62 +2,244 @@ package transcoder
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/heyjunin/HLSpresso/pkg/encrypt"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
 	"github.com/heyjunin/HLSpresso/pkg/hls"
 )
 
-// ValidateOptions verifica se as opções estão configuradas corretamente
+// ValidateOptions checks opts the way New and NewWithDeps do, without creating
+// a Transcoder or touching the filesystem, so callers can reject a job before
+// queueing it. Every invalid field is reported at once: the returned error is
+// a *errors.StructuredError whose Fields lists the problems. Its type and code
+// are those of the problem when there is only one, and errors.ValidationError
+// with code 4 otherwise.
 func ValidateOptions(opts Options) error {
-	// Verificar se o caminho de entrada foi especificado
-	if opts.InputPath == "" {
-		return fmt.Errorf("caminho de entrada não especificado")
+	return opts.validate()
+}
+
+// validate sets the defaults of the unspecified options and checks them,
+// collecting the problems of every field rather than stopping at the first.
+func (o *Options) validate() error {
+	// Valores padrão
+	if o.OutputType == "" {
+		o.OutputType = HLSOutput
+	}
+	if o.FFmpegBinary == "" {
+		o.FFmpegBinary = "ffmpeg"
+	}
+	if o.DownloadDir == "" {
+		o.DownloadDir = "downloads"
+	}
+	if o.DownloadTimeout == 0 {
+		o.DownloadTimeout = DefaultDownloadTimeout
+	}
+	if o.ProbeTimeout == 0 {
+		o.ProbeTimeout = DefaultProbeTimeout
+	}
+	if o.AutoDimensionAlignment == 0 {
+		o.AutoDimensionAlignment = hls.DefaultDimensionAlignment
 	}
 
-	// Verificar se o caminho de saída foi especificado
-	if opts.OutputPath == "" {
-		return fmt.Errorf("caminho de saída não especificado")
+	var p optionProblems
+	if o.InputPath == "" {
+		p.add("InputPath", errors.New(errors.ValidationError, "Input path is required",
+			"Set the path or URL of the video to transcode", 1))
+	}
+	if o.OutputPath == "" {
+		p.add("OutputPath", errors.New(errors.ValidationError, "Output path is required",
+			"Set the output directory (HLS) or file (MP4)", 2))
+	} else {
+		p.add("OutputPath", validateOutputTemplate(o.OutputPath))
+	}
+	if o.OutputType != HLSOutput && o.OutputType != MP4Output {
+		p.add("OutputType", errors.New(errors.ValidationError, "Invalid output type",
+			fmt.Sprintf("Expected %s or %s, got %q", HLSOutput, MP4Output, o.OutputType), 4))
 	}
 
-	// Se for remoto, verificar o diretório de download
-	if opts.IsRemoteInput {
-		if opts.DownloadDir == "" {
-			return fmt.Errorf("diretório de download não especificado para entrada remota")
+	if policy, err := hls.ParseScalePolicy(string(o.AutoScalePolicy)); err != nil {
+		p.add("AutoScalePolicy", errors.New(errors.ValidationError, "Invalid auto scale policy", err.Error(), 4))
+	} else {
+		o.AutoScalePolicy = policy
+	}
+	if codec, err := hls.ParseAudioCodec(string(o.AudioCodec)); err != nil {
+		p.add("AudioCodec", errors.New(errors.ValidationError, "Invalid audio codec", err.Error(), 4))
+	} else {
+		o.AudioCodec = codec
+	}
+	for _, setting := range []struct {
+		field    string
+		settings hls.EncoderSettings
+	}{
+		{"VideoPreset", hls.EncoderSettings{Preset: o.VideoPreset}},
+		{"VideoTune", hls.EncoderSettings{Tune: o.VideoTune}},
+		{"VideoProfile", hls.EncoderSettings{Profile: o.VideoProfile}},
+		{"VideoLevel", hls.EncoderSettings{Level: o.VideoLevel}},
+	} {
+		if err := setting.settings.Validate(); err != nil {
+			p.add(setting.field, errors.New(errors.ValidationError, "Invalid encoder setting", err.Error(), 4))
 		}
-
-		// Verificar se o diretório de download existe
-		if _, err := os.Stat(opts.DownloadDir); os.IsNotExist(err) {
-			// Tentar criar o diretório
-			if err := os.MkdirAll(opts.DownloadDir, 0755); err != nil {
-				return fmt.Errorf("não foi possível criar o diretório de download: %w", err)
-			}
+	}
+	for i, res := range o.HLSResolutions {
+		if err := res.EncoderSettings.Validate(); err != nil {
+			p.add(fmt.Sprintf("HLSResolutions[%d]", i), errors.New(errors.ValidationError, "Invalid encoder setting", err.Error(), 4))
+		}
+		if err := res.ValidateExtraParams(); err != nil {
+			p.add(fmt.Sprintf("HLSResolutions[%d]", i), errors.New(errors.ValidationError, "Invalid rendition ffmpeg params", err.Error(), 4))
 		}
 	}
+	if o.AutoDimensionAlignment != 2 && o.AutoDimensionAlignment != 4 {
+		p.add("AutoDimensionAlignment", errors.New(errors.ValidationError, "Invalid auto dimension alignment",
+			fmt.Sprintf("Alignment must be 2 or 4, got %d", o.AutoDimensionAlignment), 4))
+	}
+	if o.CaptionLanguage != "" && !languageTagPattern.MatchString(o.CaptionLanguage) {
+		p.add("CaptionLanguage", errors.New(errors.ValidationError, "Invalid caption language",
+			fmt.Sprintf("Expected a language tag such as en or pt-BR, got %q", o.CaptionLanguage), 4))
+	}
 
-	// Configurar valores padrão para o tipo de saída
-	if opts.OutputType == "" {
-		// Inferir tipo de saída pelo caminho
-		ext := filepath.Ext(opts.OutputPath)
-		if ext == ".mp4" {
-			opts.OutputType = MP4Output
-		} else {
-			opts.OutputType = HLSOutput
+	if o.HLSVersion != 0 || o.HLSByteRange {
+		version := o.HLSVersion
+		if version == 0 {
+			version = hls.DefaultVersion
+		}
+		if err := hls.CheckVersion(version, "", o.HLSByteRange); err != nil {
+			p.add("HLSVersion", errors.New(errors.ValidationError, "Invalid HLS version", err.Error(), 4))
+		}
+	}
+	if err := o.HLSMPEGTS.Validate(); err != nil {
+		p.add("HLSMPEGTS", errors.New(errors.ValidationError, "Invalid MPEG-TS muxer options", err.Error(), 4))
+	} else if !o.HLSMPEGTS.IsZero() && o.HLSVersion >= 7 {
+		p.add("HLSMPEGTS", errors.New(errors.ValidationError, "Invalid MPEG-TS muxer options",
+			fmt.Sprintf("HLS version %d writes fMP4 segments, not MPEG-TS", o.HLSVersion), 4))
+	}
+	if o.HLSMuxOverhead < 0 || o.HLSMuxOverhead >= 1 {
+		p.add("HLSMuxOverhead", errors.New(errors.ValidationError, "Invalid mux overhead",
+			fmt.Sprintf("Mux overhead must be between 0 and 1, got %g", o.HLSMuxOverhead), 4))
+	}
+	for i, d := range o.HLSSessionData {
+		if err := d.Validate(); err != nil {
+			p.add(fmt.Sprintf("HLSSessionData[%d]", i), errors.New(errors.ValidationError, "Invalid HLS session data", err.Error(), 4))
+		}
+	}
+	for i, tag := range o.HLSMasterTags {
+		if err := hls.ValidateMasterTag(tag); err != nil {
+			p.add(fmt.Sprintf("HLSMasterTags[%d]", i), errors.New(errors.ValidationError, "Invalid master playlist tag", err.Error(), 4))
 		}
 	}
 
-	// Se o tipo de saída for HLS e não estiver usando resolução automática
-	if opts.OutputType == HLSOutput && !opts.UseAutoResolutions {
-		// Verificar se as resoluções HLS foram especificadas
-		if len(opts.HLSResolutions) == 0 {
-			// Configurar um conjunto padrão de resoluções
-			opts.HLSResolutions = []hls.VideoResolution{
-				{Width: 1280, Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
-				{Width: 854, Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "96k"},
-				{Width: 640, Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "64k"},
-			}
+	for _, timeout := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"DownloadTimeout", o.DownloadTimeout},
+		{"ProbeTimeout", o.ProbeTimeout},
+		{"EncodeTimeout", o.EncodeTimeout},
+		{"MaxJobDuration", o.MaxJobDuration},
+		{"StallTimeout", o.StallTimeout},
+	} {
+		if timeout.value < 0 {
+			p.add(timeout.field, errors.New(errors.ValidationError, "Invalid timeout",
+				fmt.Sprintf("Timeouts must not be negative, got %s", timeout.value), 4))
 		}
 	}
 
-	return nil
+	if o.StallRetries < 0 {
+		p.add("StallRetries", errors.New(errors.ValidationError, "Invalid stall retries",
+			fmt.Sprintf("The number of retries must not be negative, got %d", o.StallRetries), 4))
+	}
+	if o.StopGracePeriod < 0 {
+		p.add("StopGracePeriod", errors.New(errors.ValidationError, "Invalid stop grace period",
+			fmt.Sprintf("The grace period must not be negative, got %s", o.StopGracePeriod), 4))
+	}
+	if o.FFmpegLogMaxSize < 0 {
+		p.add("FFmpegLogMaxSize", errors.New(errors.ValidationError, "Invalid ffmpeg log size",
+			fmt.Sprintf("The size must not be negative, got %d", o.FFmpegLogMaxSize), 4))
+	}
+	if o.DownloadCacheMaxSize < 0 {
+		p.add("DownloadCacheMaxSize", errors.New(errors.ValidationError, "Invalid download cache size",
+			fmt.Sprintf("The size must not be negative, got %d", o.DownloadCacheMaxSize), 4))
+	}
+	if o.FFmpegLogBackups < 0 {
+		p.add("FFmpegLogBackups", errors.New(errors.ValidationError, "Invalid ffmpeg log backups",
+			fmt.Sprintf("The number of backups must not be negative, got %d", o.FFmpegLogBackups), 4))
+	}
+
+	if len(o.EncryptionKey) > 0 && len(o.EncryptionKey) != encrypt.KeySize {
+		p.add("EncryptionKey", errors.New(errors.ValidationError, "Invalid encryption key", encrypt.ErrInvalidKey.Error(), 4))
+	}
+	validateInputDecryption(o, &p)
+	validateTrickPlay(o, &p)
+	validateChunkedEncoding(o, &p)
+	validatePublishPartial(o, &p)
+	validateSlates(o, &p)
+	validateInputLimits(o, &p)
+	validateInputMirrors(o, &p)
+	validateYtDlp(o, &p)
+	validateShareLinks(o, &p)
+	validateFFmpegEnv(o, &p)
+	validateFFmpegParams(o, &p)
+	validateDeinterlace(o, &p)
+	validatePixelFormat(o, &p)
+	validateMezzanines(o, &p)
+	validateOutputs(o, &p)
+
+	if mode, err := ParseBlankCheckMode(string(o.BlankCheck)); err != nil {
+		p.add("BlankCheck", errors.New(errors.ValidationError, "Invalid blank check mode", err.Error(), 4))
+	} else {
+		o.BlankCheck = mode
+	}
+	if mode, err := ParsePreflightMode(string(o.PreflightMode)); err != nil {
+		p.add("PreflightMode", errors.New(errors.ValidationError, "Invalid preflight mode", err.Error(), 4))
+	} else {
+		o.PreflightMode = mode
+	}
+
+	return p.err()
+}
+
+// encoderSettings returns the x264 settings of the video set in o.
+func (o *Options) encoderSettings() hls.EncoderSettings {
+	return hls.EncoderSettings{Preset: o.VideoPreset, Tune: o.VideoTune, Profile: o.VideoProfile, Level: o.VideoLevel}
+}
+
+// optionProblems collects the problems found by Options.validate.
+type optionProblems struct {
+	first  *errors.StructuredError
+	fields []errors.FieldError
+}
+
+// add records err, if not nil, as a problem of field.
+func (p *optionProblems) add(field string, err error) {
+	if err == nil {
+		return
+	}
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok {
+		sErr = errors.Wrap(err, errors.ValidationError, "Invalid option", 4)
+	}
+	if p.first == nil {
+		p.first = sErr
+	}
+	p.fields = append(p.fields, errors.FieldError{Field: field, Message: sErr.Message, Details: sErr.Details})
+}
+
+// err returns nil when no problem was found, the problem itself when there is
+// one, and a validation error listing them otherwise.
+func (p *optionProblems) err() error {
+	switch len(p.fields) {
+	case 0:
+		return nil
+	case 1:
+		p.first.Fields = p.fields
+		return p.first
+	}
+	problems := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		problems[i] = f.String()
+	}
+	sErr := errors.New(errors.ValidationError, fmt.Sprintf("%d invalid options", len(p.fields)), strings.Join(problems, "; "), 4)
+	sErr.Fields = p.fields
+	return sErr
 }
@@ -0,0 +1,80 @@
+package transcoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestValidateOptions(t *testing.T) {
+	if err := ValidateOptions(Options{InputPath: "input.mp4", OutputPath: "out/{basename}"}); err != nil {
+		t.Errorf("ValidateOptions() of valid options error = %v", err)
+	}
+
+	// A single problem keeps its own type and code
+	err := ValidateOptions(Options{OutputPath: "out"})
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Code != 1 || sErr.Message != "Input path is required" || len(sErr.Fields) != 1 || sErr.Fields[0].Field != "InputPath" {
+		t.Errorf("ValidateOptions() without input error = %#v", err)
+	}
+}
+
+func TestValidateOptionsReportsEveryField(t *testing.T) {
+	opts := Options{
+		OutputPath:      "out/{nmae}",
+		OutputType:      "webm",
+		CaptionLanguage: "english!",
+		EncodeTimeout:   -time.Second,
+		HLSMasterTags:   []string{"#EXT-X-INDEPENDENT-SEGMENTS", "not a tag"},
+		TrickPlay:       true,
+		TrickPlayWidth:  8,
+	}
+	_, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Type != errors.ValidationError || sErr.Code != 4 {
+		t.Fatalf("NewWithDeps() error = %v, want a validation error", err)
+	}
+
+	var fields []string
+	for _, f := range sErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	want := "InputPath OutputPath OutputType CaptionLanguage HLSMasterTags[1] EncodeTimeout TrickPlayWidth"
+	if got := strings.Join(fields, " "); got != want {
+		t.Errorf("Invalid fields = %s, want %s", got, want)
+	}
+	if !strings.Contains(sErr.Details, "OutputPath: ") || !strings.Contains(sErr.Details, "{nmae}") {
+		t.Errorf("Details = %q, want every problem", sErr.Details)
+	}
+}
+
+func TestValidateEncoderSettings(t *testing.T) {
+	opts := Options{
+		InputPath:    "input.mp4",
+		OutputPath:   "out",
+		VideoPreset:  hls.PresetSlow,
+		VideoTune:    "cartoon",
+		VideoProfile: hls.ProfileHigh,
+		VideoLevel:   "4.5",
+		HLSResolutions: []hls.VideoResolution{
+			{Width: 1280, Height: 720, VideoBitrate: "2800k", EncoderSettings: hls.EncoderSettings{Profile: hls.ProfileMain}},
+			{Width: 640, Height: 360, VideoBitrate: "800k", EncoderSettings: hls.EncoderSettings{Preset: "turbo"}},
+			{Width: 426, Height: 240, VideoBitrate: "400k", ExtraParams: []string{"-map 0:v"}},
+		},
+	}
+	err := ValidateOptions(opts)
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok {
+		t.Fatalf("ValidateOptions() error = %v, want a validation error", err)
+	}
+	var fields []string
+	for _, f := range sErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	if got, want := strings.Join(fields, " "), "VideoTune VideoLevel HLSResolutions[1] HLSResolutions[2]"; got != want {
+		t.Errorf("Invalid fields = %s, want %s", got, want)
+	}
+}
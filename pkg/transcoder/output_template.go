@@ -0,0 +1,107 @@
+package transcoder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// Variables recognized in Options.OutputPath, resolved when Transcode runs.
+const (
+	// OutputVarBasename is the input file name without its extension.
+	OutputVarBasename = "{basename}"
+	// OutputVarName is an alias of OutputVarBasename.
+	OutputVarName = "{name}"
+	// OutputVarDate is the date the transcode started, as YYYY-MM-DD.
+	OutputVarDate = "{date}"
+	// OutputVarResolution is the input video resolution, e.g. 1920x1080.
+	// Using it requires probing the input.
+	OutputVarResolution = "{resolution}"
+	// OutputVarJobID is Options.JobID.
+	OutputVarJobID = "{jobid}"
+)
+
+// outputVarPattern matches the variables in an output path template.
+var outputVarPattern = regexp.MustCompile(`\{[a-z]+\}`)
+
+// validateOutputTemplate returns an error naming the first unknown variable in
+// template, so typos do not silently end up in directory names.
+func validateOutputTemplate(template string) error {
+	for _, variable := range outputVarPattern.FindAllString(template, -1) {
+		switch variable {
+		case OutputVarBasename, OutputVarName, OutputVarDate, OutputVarResolution, OutputVarJobID:
+		default:
+			return errors.New(errors.InvalidOutputPathError, errors.GetErrorMessage(errors.ErrInvalidOutputPath),
+				fmt.Sprintf("Unknown variable %s in output path %s (available: %s, %s, %s, %s)", variable, template,
+					OutputVarBasename, OutputVarDate, OutputVarResolution, OutputVarJobID),
+				errors.ErrInvalidOutputPath)
+		}
+	}
+	return nil
+}
+
+// newJobID returns a random identifier for Options.JobID.
+func newJobID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// inputBasename returns the file name of the input path or URL without its
+// extension.
+func inputBasename(input string) string {
+	name := filepath.Base(input)
+	if u, err := url.Parse(input); err == nil && len(u.Scheme) > 1 && u.Host != "" {
+		name = path.Base(u.Path)
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// resolveOutputPath replaces the variables in the output path template. The
+// input is only probed when the template uses OutputVarResolution.
+func (t *Transcoder) resolveOutputPath(ctx context.Context, inputPath string) (string, error) {
+	return t.resolveTemplate(ctx, t.outputTemplate, inputPath)
+}
+
+// resolveTemplate replaces the variables in the output path template, such as
+// the path of an additional output (see Options.Outputs).
+func (t *Transcoder) resolveTemplate(ctx context.Context, template, inputPath string) (string, error) {
+	if !strings.Contains(template, "{") {
+		return template, nil
+	}
+
+	basename := inputBasename(t.options.InputPath)
+	replacements := []string{
+		OutputVarBasename, basename,
+		OutputVarName, basename,
+		OutputVarDate, time.Now().Format("2006-01-02"),
+		OutputVarJobID, t.options.JobID,
+	}
+	if strings.Contains(template, OutputVarResolution) {
+		info, err := t.probeInput(ctx, inputPath)
+		if err != nil {
+			return "", err
+		}
+		replacements = append(replacements, OutputVarResolution, fmt.Sprintf("%dx%d", info.Width, info.Height))
+	}
+
+	resolved := strings.NewReplacer(replacements...).Replace(template)
+	if resolved != template {
+		t.logger.Debug("Output path resolved", "transcoder", map[string]interface{}{
+			"template": template,
+			"path":     resolved,
+		})
+	}
+	return resolved, nil
+}
@@ -0,0 +1,72 @@
+package transcoder
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+func TestResolveOutputPath(t *testing.T) {
+	date := time.Now().Format("2006-01-02")
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		want     string
+		probes   int
+	}{
+		{name: "No variables", input: "in/movie.mov", template: "out/hls", want: "out/hls"},
+		{name: "Basename and date", input: "in/movie.mov", template: "out/{date}/{basename}", want: "out/" + date + "/movie"},
+		{name: "Name alias", input: "in/movie.mov", template: "out/{name}.mp4", want: "out/movie.mp4"},
+		{name: "Job ID", input: "in/movie.mov", template: "out/{jobid}", want: "out/job-42"},
+		{name: "URL input", input: "https://cdn.example.com/media/clip.mp4?token=abc", template: "out/{basename}", want: "out/clip"},
+		{name: "Resolution", input: "in/movie.mov", template: "out/{basename}-{resolution}", want: "out/movie-1920x1080", probes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := newProbeRunner()
+			opts := Options{InputPath: tt.input, OutputPath: tt.template, JobID: "job-42", StreamFromURL: true}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			got, err := trans.resolveOutputPath(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("resolveOutputPath() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveOutputPath() = %q, want %q", got, tt.want)
+			}
+			if calls := len(runner.Calls()); calls != tt.probes {
+				t.Errorf("ffprobe ran %d times, want %d", calls, tt.probes)
+			}
+		})
+	}
+}
+
+func TestOutputTemplateDefaults(t *testing.T) {
+	opts := Options{InputPath: "movie.mov", OutputPath: filepath.Join("out", "{jobid}")}
+	first, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	second, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if first.options.JobID == "" || first.options.JobID == second.options.JobID {
+		t.Errorf("JobID defaults = %q and %q, want distinct random identifiers", first.options.JobID, second.options.JobID)
+	}
+
+	opts.OutputPath = "out/{basname}"
+	_, err = NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Code != errors.ErrInvalidOutputPath {
+		t.Errorf("NewWithDeps() error = %v, want code %d for an unknown variable", err, errors.ErrInvalidOutputPath)
+	}
+}
@@ -0,0 +1,141 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// OutputSpec is an additional output of a job (see Options.Outputs).
+type OutputSpec struct {
+	// Type is the type of the output: HLSOutput, MP4Output, ProResOutput,
+	// DNxHROutput or ThumbnailOutput.
+	Type OutputType `json:"type"`
+	// Path is the output directory for HLSOutput, or the output file for the
+	// other types. It supports the variables of Options.OutputPath.
+	Path string `json:"path"`
+	// Resolutions replaces the HLSResolutions of the job for HLSOutput.
+	Resolutions []hls.VideoResolution `json:"resolutions,omitempty"`
+	// Height scales the video of MP4Output to this height (see
+	// Options.MP4Height).
+	Height int `json:"height,omitempty"`
+	// Width is the width of ThumbnailOutput in pixels. Defaults to 320.
+	Width int `json:"width,omitempty"`
+}
+
+// ParseOutputSpec parses an additional output written as "TYPE[:SIZE]=PATH",
+// e.g. "mp4:720=out/{basename}_720p.mp4" or "thumbnail=out/poster.jpg". SIZE
+// is the Height of MP4Output or the Width of ThumbnailOutput.
+func ParseOutputSpec(value string) (OutputSpec, error) {
+	output, path, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(path) == "" {
+		return OutputSpec{}, fmt.Errorf("invalid output %q (expected TYPE[:SIZE]=PATH)", value)
+	}
+	output, size, sized := strings.Cut(output, ":")
+	spec := OutputSpec{Type: OutputType(strings.ToLower(strings.TrimSpace(output))), Path: strings.TrimSpace(path)}
+	if !sized {
+		return spec, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil || n <= 0 {
+		return OutputSpec{}, fmt.Errorf("invalid size %q of output %q", size, value)
+	}
+	switch spec.Type {
+	case MP4Output:
+		spec.Height = n
+	case ThumbnailOutput:
+		spec.Width = n
+	default:
+		return OutputSpec{}, fmt.Errorf("output %q has no size", spec.Type)
+	}
+	return spec, nil
+}
+
+// validateOutputs checks MP4Height and the Outputs, lowercasing their types.
+func validateOutputs(options *Options, p *optionProblems) {
+	if options.MP4Height < 0 {
+		p.add("MP4Height", errors.New(errors.ValidationError, "Invalid MP4 height",
+			fmt.Sprintf("Expected a positive height, got %d", options.MP4Height), 4))
+	}
+	for i := range options.Outputs {
+		spec := &options.Outputs[i]
+		field := fmt.Sprintf("Outputs[%d]", i)
+		spec.Type = OutputType(strings.ToLower(strings.TrimSpace(string(spec.Type))))
+		switch spec.Type {
+		case HLSOutput, MP4Output, ProResOutput, DNxHROutput, ThumbnailOutput:
+		default:
+			p.add(field, errors.New(errors.ValidationError, "Invalid output type",
+				fmt.Sprintf("Expected %s, %s, %s, %s or %s, got %q", HLSOutput, MP4Output, ProResOutput, DNxHROutput, ThumbnailOutput, spec.Type), 4))
+		}
+		if spec.Path == "" {
+			p.add(field, errors.New(errors.ValidationError, "Output path is required",
+				"Set the output directory (HLS) or file of the additional output", 2))
+		} else {
+			p.add(field, validateOutputTemplate(spec.Path))
+		}
+		switch {
+		case len(spec.Resolutions) > 0 && spec.Type != HLSOutput:
+			p.add(field, errors.New(errors.ValidationError, "Invalid output setting", "Resolutions only apply to hls outputs", 4))
+		case spec.Height < 0 || (spec.Height > 0 && spec.Type != MP4Output):
+			p.add(field, errors.New(errors.ValidationError, "Invalid output setting", "Height must be positive and only applies to mp4 outputs", 4))
+		case spec.Width < 0 || (spec.Width > 0 && spec.Type != ThumbnailOutput):
+			p.add(field, errors.New(errors.ValidationError, "Invalid output setting", "Width must be positive and only applies to thumbnail outputs", 4))
+		}
+	}
+}
+
+// createOutputs produces the Outputs from the input, one after the other.
+func (t *Transcoder) createOutputs(ctx context.Context, inputPath string) error {
+	for _, spec := range t.options.Outputs {
+		if err := t.createOutput(ctx, inputPath, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createOutput produces the additional output spec from the input, with the
+// options of the job and the settings of spec. Additional outputs are never
+// published partially.
+func (t *Transcoder) createOutput(ctx context.Context, inputPath string, spec OutputSpec) error {
+	outputPath, err := t.resolveTemplate(ctx, spec.Path, inputPath)
+	if err != nil {
+		return err
+	}
+	t.logger.Info("Creating additional output", "transcoder", map[string]interface{}{
+		"type":   string(spec.Type),
+		"output": outputPath,
+	})
+
+	switch spec.Type {
+	case HLSOutput:
+		resolutions := t.options.HLSResolutions
+		if len(spec.Resolutions) > 0 {
+			resolutions = spec.Resolutions
+		}
+		_, err = t.createHLSStreams(ctx, inputPath, outputPath, resolutions, false)
+		return err
+	case MP4Output:
+		_, err = t.transcodeToMP4(ctx, inputPath, outputPath, spec.Height)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create output directory", 10)
+	}
+	if spec.Type == ThumbnailOutput {
+		return ThumbnailProcessor{
+			FileName:     filepath.Base(outputPath),
+			Width:        spec.Width,
+			FFmpegBinary: t.options.FFmpegBinary,
+			Runner:       t.runner,
+		}.Process(ctx, Result{InputPath: inputPath, Dir: filepath.Dir(outputPath)})
+	}
+	return t.createMezzanine(ctx, inputPath, spec.Type, outputPath)
+}
@@ -0,0 +1,106 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestParseOutputSpec(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    OutputSpec
+		wantErr bool
+	}{
+		{"MP4:720=out/{basename}_720p.mp4", OutputSpec{Type: MP4Output, Path: "out/{basename}_720p.mp4", Height: 720}, false},
+		{"thumbnail:640=out/poster.jpg", OutputSpec{Type: ThumbnailOutput, Path: "out/poster.jpg", Width: 640}, false},
+		{"hls=out/a=b", OutputSpec{Type: HLSOutput, Path: "out/a=b"}, false},
+		{"prores:720=out/movie.mov", OutputSpec{}, true},
+		{"mp4:high=out/movie.mp4", OutputSpec{}, true},
+		{"mp4", OutputSpec{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOutputSpec(tt.value)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseOutputSpec(%q) = %+v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestCreateOutputs(t *testing.T) {
+	dir := t.TempDir()
+	var commands []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffprobe" {
+			return ffmpegtest.Result{Stdout: `{
+				"streams": [{"index": 0, "codec_type": "video", "width": 1920, "height": 1080, "pix_fmt": "yuv420p"}],
+				"format": {"format_name": "mov,mp4", "duration": "60.0"}
+			}`}
+		}
+		output := args[len(args)-1]
+		commands = append(commands, strings.Join(args, " "))
+		if err := os.WriteFile(output, []byte("data"), 0644); err != nil {
+			t.Errorf("writing %s: %v", output, err)
+		}
+		return ffmpegtest.Result{}
+	}}
+	trans, err := NewWithDeps(Options{
+		InputPath:  "input.mp4",
+		OutputPath: filepath.Join(dir, "movie.mp4"),
+		OutputType: MP4Output,
+		Outputs: []OutputSpec{
+			{Type: "MP4", Path: filepath.Join(dir, "{basename}_720p.mp4"), Height: 720},
+			{Type: ThumbnailOutput, Path: filepath.Join(dir, "thumbs", "poster.jpg")},
+			{Type: DNxHROutput, Path: filepath.Join(dir, "movie.mov")},
+		},
+	}, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	options := trans.options
+
+	// As saídas são produzidas em ordem a partir do mesmo input, com os caminhos resolvidos
+	if err := trans.createOutputs(context.Background(), "input.mp4"); err != nil {
+		t.Fatalf("createOutputs() error = %v", err)
+	}
+	if len(commands) != 3 {
+		t.Fatalf("commands = %q, want 3 ffmpeg runs", commands)
+	}
+	for i, want := range []string{
+		"-vf scale=-2:720 -c:v libx264",
+		"-vf thumbnail,scale=320:-2",
+		"-c:v dnxhd -profile:v dnxhr_hq",
+	} {
+		if !strings.Contains(commands[i], "-i input.mp4 ") || !strings.Contains(commands[i], want) {
+			t.Errorf("commands[%d] = %q, want %q", i, commands[i], want)
+		}
+	}
+	for _, name := range []string{"input_720p.mp4", "thumbs/poster.jpg", "movie.mov"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not created: %v", name, err)
+		}
+	}
+
+	// As opções do job são restauradas depois de cada saída
+	if trans.options.OutputPath != options.OutputPath || trans.options.OutputType != MP4Output || trans.options.MP4Height != 0 {
+		t.Errorf("options = %s %s %d, want the options of the job restored", trans.options.OutputType, trans.options.OutputPath, trans.options.MP4Height)
+	}
+
+	for name, outputs := range map[string][]OutputSpec{
+		"type":       {{Type: "gif", Path: "out.gif"}},
+		"path":       {{Type: MP4Output}},
+		"variable":   {{Type: MP4Output, Path: "{title}.mp4"}},
+		"height":     {{Type: HLSOutput, Path: "out", Height: 720}},
+		"resolution": {{Type: MP4Output, Path: "out.mp4", Resolutions: hls.DefaultResolutions[:1]}},
+	} {
+		if err := ValidateOptions(Options{InputPath: "input.mp4", OutputPath: "output", Outputs: outputs}); err == nil {
+			t.Errorf("%s: ValidateOptions() expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package transcoder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/encoder"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// ParamConflictMode selects how the extra ffmpeg params conflicting with the
+// generated arguments are handled (see Options.FFmpegParamConflicts).
+type ParamConflictMode string
+
+const (
+	// ParamConflictsWarn logs a warning listing the conflicts and runs ffmpeg
+	// anyway. This is the default.
+	ParamConflictsWarn ParamConflictMode = "warn"
+	// ParamConflictsReject fails the job before running ffmpeg.
+	ParamConflictsReject ParamConflictMode = "reject"
+	// ParamConflictsOff passes the params to ffmpeg without checking them.
+	ParamConflictsOff ParamConflictMode = "off"
+)
+
+// ParseParamConflictMode validates a param conflict mode name,
+// case-insensitively. The empty string selects ParamConflictsWarn.
+func ParseParamConflictMode(value string) (ParamConflictMode, error) {
+	switch mode := ParamConflictMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return ParamConflictsWarn, nil
+	case ParamConflictsWarn, ParamConflictsReject, ParamConflictsOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown param conflict mode %q (expected warn, reject or off)", value)
+	}
+}
+
+// validateFFmpegParams splits the "-option value" entries of
+// FFmpegExtraParams and FFmpegInputParams into separate arguments and parses
+// FFmpegParamConflicts.
+func validateFFmpegParams(options *Options, p *optionProblems) {
+	options.FFmpegExtraParams = ffmpeg.SplitParams(options.FFmpegExtraParams)
+	options.FFmpegInputParams = ffmpeg.SplitParams(options.FFmpegInputParams)
+	if mode, err := ParseParamConflictMode(string(options.FFmpegParamConflicts)); err != nil {
+		p.add("FFmpegParamConflicts", errors.New(errors.ValidationError, "Invalid param conflict mode", err.Error(), 4))
+	} else {
+		options.FFmpegParamConflicts = mode
+	}
+}
+
+// checkParams compares the extra and input params of job with the arguments
+// args generates for it without them, warning about the conflicts or
+// rejecting the job according to Options.FFmpegParamConflicts.
+func (t *Transcoder) checkParams(args func(job encoder.Job) ([]string, error), job encoder.Job) error {
+	if t.options.FFmpegParamConflicts == ParamConflictsOff || (len(job.ExtraParams) == 0 && len(job.InputParams) == 0) {
+		return nil
+	}
+	bare := job
+	bare.ExtraParams, bare.InputParams = nil, nil
+	generated, err := args(bare)
+	if err != nil {
+		return nil
+	}
+
+	// Os parâmetros de entrada só competem com as opções da primeira entrada
+	inputArgs := generated
+	for i, arg := range generated {
+		if arg == "-i" {
+			inputArgs = generated[:min(i+2, len(generated))]
+			break
+		}
+	}
+	var fields []errors.FieldError
+	for _, params := range []struct {
+		field     string
+		generated []string
+		params    []string
+	}{
+		{"FFmpegExtraParams", generated, job.ExtraParams},
+		{"FFmpegInputParams", inputArgs, job.InputParams},
+	} {
+		for _, conflict := range ffmpeg.ParamConflicts(params.generated, params.params) {
+			fields = append(fields, errors.FieldError{Field: params.field, Message: "Conflicting ffmpeg param", Details: conflict})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	conflicts := make([]string, len(fields))
+	for i, field := range fields {
+		conflicts[i] = field.Field + ": " + field.Details
+	}
+	if t.options.FFmpegParamConflicts == ParamConflictsReject {
+		sErr := errors.New(errors.ValidationError, "Conflicting ffmpeg params", strings.Join(conflicts, "; "), 4)
+		sErr.Fields = fields
+		return sErr
+	}
+	t.logger.Warn("Extra ffmpeg params conflict with the generated arguments", "transcoder", map[string]interface{}{
+		"conflicts": conflicts,
+	})
+	return nil
+}
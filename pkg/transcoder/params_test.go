@@ -0,0 +1,84 @@
+package transcoder
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/encoder"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestFFmpegParamConflicts(t *testing.T) {
+	newTranscoder := func(t *testing.T, mode ParamConflictMode, log *recordingLogger, runs *int) *Transcoder {
+		t.Helper()
+		runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+			*runs++
+			return ffmpegtest.Result{}
+		}}
+		trans, err := NewWithDeps(Options{
+			InputPath:            "input.mp4",
+			OutputPath:           "output.mp4",
+			OutputType:           MP4Output,
+			FFmpegExtraParams:    []string{"-vcodec libx265", "-crf", "20"},
+			FFmpegInputParams:    []string{"-ss 30", "-re", "-f", "mp4", "-i", "logo.png"},
+			FFmpegParamConflicts: mode,
+		}, &mockProgressReporter{}, log, nil, WithRunner(runner))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		return trans
+	}
+	job := func(trans *Transcoder) encoder.Job {
+		return encoder.Job{
+			Format:         encoder.FormatMP4,
+			InputPath:      "input.mp4",
+			OutputPath:     "output.mp4",
+			ExtraParams:    trans.options.FFmpegExtraParams,
+			InputParams:    trans.options.FFmpegInputParams,
+			RealtimePacing: true,
+		}
+	}
+
+	// As entradas "-opção valor" são divididas em dois argumentos
+	var runs int
+	trans := newTranscoder(t, "", &recordingLogger{}, &runs)
+	if !slices.Equal(trans.options.FFmpegExtraParams, []string{"-vcodec", "libx265", "-crf", "20"}) || trans.options.FFmpegParamConflicts != ParamConflictsWarn {
+		t.Errorf("Options = %q, %q", trans.options.FFmpegExtraParams, trans.options.FFmpegParamConflicts)
+	}
+
+	// O modo reject falha antes de executar o ffmpeg, com um campo por conflito
+	trans = newTranscoder(t, "REJECT", &recordingLogger{}, &runs)
+	_, err := trans.encode(context.Background(), job(trans))
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Code != 4 || len(sErr.Fields) != 3 || runs != 0 {
+		t.Fatalf("encode() = %v, want the conflicts without running ffmpeg (runs = %d)", err, runs)
+	}
+	for i, want := range []errors.FieldError{
+		{Field: "FFmpegExtraParams", Message: "Conflicting ffmpeg param", Details: "-vcodec libx265 overrides the generated -c:v libx264"},
+		{Field: "FFmpegExtraParams", Message: "Conflicting ffmpeg param", Details: "-crf 20 overrides the generated -crf 22"},
+		{Field: "FFmpegInputParams", Message: "Conflicting ffmpeg param", Details: "-i logo.png adds an input to the generated command"},
+	} {
+		if sErr.Fields[i] != want {
+			t.Errorf("Fields[%d] = %+v, want %+v", i, sErr.Fields[i], want)
+		}
+	}
+
+	// O modo warn registra os conflitos e executa o ffmpeg; o modo off não os verifica
+	for mode, wantWarning := range map[ParamConflictMode]bool{ParamConflictsWarn: true, ParamConflictsOff: false} {
+		log := &recordingLogger{}
+		runs = 0
+		trans := newTranscoder(t, mode, log, &runs)
+		if _, err := trans.encode(context.Background(), job(trans)); err != nil || runs == 0 {
+			t.Fatalf("encode() in %s mode = %v (runs = %d), want ffmpeg to run", mode, err, runs)
+		}
+		warned := slices.ContainsFunc(log.data, func(data map[string]interface{}) bool {
+			conflicts, _ := data["conflicts"].([]string)
+			return len(conflicts) == 3
+		})
+		if warned != wantWarning {
+			t.Errorf("%s mode: conflicts logged = %v, want %v", mode, warned, wantWarning)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package transcoder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+const (
+	// defaultPlanFrameRate is assumed when the input frame rate cannot be detected.
+	defaultPlanFrameRate = 30.0
+	// mp4BitsPerPixel approximates the bitrate of the CRF based MP4 encode, which
+	// has no target bitrate, as bits per pixel per frame.
+	mp4BitsPerPixel = 0.1
+	// mp4AudioBitrate matches the audio bitrate used for MP4 output.
+	mp4AudioBitrate = "128k"
+)
+
+// Calibration is the result of the encoding benchmark used to estimate encode time.
+type Calibration struct {
+	// PixelRate is the number of pixels per second the encoder processed during
+	// the benchmark (width x height x frames / elapsed seconds).
+	PixelRate float64 `json:"pixel_rate"`
+}
+
+// PlannedRendition describes one output rendition of an EncodePlan.
+type PlannedRendition struct {
+	// Width of the rendition in pixels.
+	Width int `json:"width"`
+	// Height of the rendition in pixels.
+	Height int `json:"height"`
+	// VideoBitrate is the target video bitrate. Empty for MP4 output, which is
+	// encoded with a constant quality (CRF) instead.
+	VideoBitrate string `json:"video_bitrate,omitempty"`
+	// AudioBitrate is the target audio bitrate. Empty when the audio is shared
+	// by every rendition (see EncodePlan.SharedAudioBitrate).
+	AudioBitrate string `json:"audio_bitrate"`
+	// FrameRate is the output frame rate of the rendition.
+	FrameRate float64 `json:"frame_rate"`
+	// EstimatedSize is the approximate output size of the rendition in bytes.
+	EstimatedSize int64 `json:"estimated_size_bytes"`
+}
+
+// EncodePlan describes what a transcoding job will produce, computed without encoding.
+// All sizes and durations are estimates.
+type EncodePlan struct {
+	// Input holds the probed properties of the input video.
+	Input VideoInfo `json:"input"`
+	// OutputType is the type of output that will be produced.
+	OutputType OutputType `json:"output_type"`
+	// Renditions lists the renditions (the HLS ladder, or the single MP4) to be produced.
+	Renditions []PlannedRendition `json:"renditions"`
+	// SharedAudioBitrate is the bitrate of the audio rendition shared by every
+	// rendition when HLSSharedAudio is set.
+	SharedAudioBitrate string `json:"shared_audio_bitrate,omitempty"`
+	// EstimatedSize is the approximate total output size in bytes, including the
+	// muxing overhead of HLS segments (see Options.HLSMuxOverhead).
+	EstimatedSize int64 `json:"estimated_size_bytes"`
+	// EstimatedEncodeTime is the approximate wall clock time of the encode.
+	EstimatedEncodeTime time.Duration `json:"-"`
+	// EstimatedEncodeSeconds is EstimatedEncodeTime in seconds, for JSON consumers.
+	EstimatedEncodeSeconds float64 `json:"estimated_encode_seconds"`
+	// Calibration is the benchmark result the encode time estimate is based on.
+	Calibration Calibration `json:"calibration"`
+}
+
+// JSON returns the EncodePlan serialized as an indented JSON string.
+func (p *EncodePlan) JSON() (string, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// WithCalibration sets the benchmark result used by Plan, skipping the
+// calibration encode. Useful when the result is stored between runs, and in tests.
+func WithCalibration(c Calibration) DependencyOption {
+	return func(t *Transcoder) {
+		t.calibration = &c
+	}
+}
+
+// Plan probes the input described by options and returns the renditions that would
+// be produced, with estimated output sizes and encode time, without encoding.
+// OutputPath is not used for planning and may be empty.
+// See Transcoder.Plan.
+func Plan(ctx context.Context, options Options) (*EncodePlan, error) {
+	if options.OutputPath == "" {
+		options.OutputPath = "."
+	}
+	t, err := New(options, nil)
+	if err != nil {
+		return nil, err
+	}
+	return t.Plan(ctx)
+}
+
+// Plan probes the input and returns the renditions the Transcoder would produce,
+// the estimated output size of each and an encode time estimate, without encoding.
+// The encode time is based on a short calibration benchmark run with the configured
+// ffmpeg binary; its result is cached for the lifetime of the process.
+func (t *Transcoder) Plan(ctx context.Context) (*EncodePlan, error) {
+	info, err := t.probeInput(ctx, t.options.InputPath)
+	if err != nil {
+		return nil, wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrInvalidFileFormat)
+	}
+
+	frameRate := info.FrameRate
+	if frameRate <= 0 {
+		frameRate = defaultPlanFrameRate
+	}
+
+	plan := &EncodePlan{
+		Input:      *info,
+		OutputType: t.options.OutputType,
+	}
+
+	resolutions, err := t.plannedResolutions(info)
+	if err != nil {
+		return nil, err
+	}
+
+	var overhead float64
+	if t.options.OutputType == HLSOutput {
+		overhead = t.muxOverhead()
+	}
+
+	sharedAudio := t.options.OutputType == HLSOutput && t.options.HLSSharedAudio
+	if sharedAudio {
+		plan.SharedAudioBitrate = hls.SharedAudioBitrate(resolutions)
+		plan.EstimatedSize = hls.EstimateSizeWithOverhead(hls.VideoResolution{AudioBitrate: plan.SharedAudioBitrate}, info.Duration, overhead)
+	}
+
+	var pixelRate float64
+	for _, res := range resolutions {
+		if sharedAudio {
+			res.AudioBitrate = ""
+		}
+		resFrameRate := frameRate
+		if res.FrameRate > 0 {
+			resFrameRate = res.FrameRate
+		}
+
+		size := hls.EstimateSizeWithOverhead(res, info.Duration, overhead)
+		if res.VideoBitrate == "" {
+			videoBitrate := float64(res.Width*res.Height) * resFrameRate * mp4BitsPerPixel
+			size += int64(videoBitrate * info.Duration / 8)
+		}
+
+		plan.Renditions = append(plan.Renditions, PlannedRendition{
+			Width:         res.Width,
+			Height:        res.Height,
+			VideoBitrate:  res.VideoBitrate,
+			AudioBitrate:  res.AudioBitrate,
+			FrameRate:     resFrameRate,
+			EstimatedSize: size,
+		})
+		plan.EstimatedSize += size
+		pixelRate += float64(res.Width*res.Height) * resFrameRate
+	}
+
+	calibration, err := t.calibrate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan.Calibration = calibration
+	if calibration.PixelRate > 0 {
+		seconds := pixelRate * info.Duration / calibration.PixelRate
+		if t.options.RealtimePacing && seconds < info.Duration {
+			// ffmpeg -re never encodes faster than realtime
+			seconds = info.Duration
+		}
+		plan.EstimatedEncodeSeconds = seconds
+		plan.EstimatedEncodeTime = time.Duration(seconds * float64(time.Second))
+	}
+
+	t.logger.Info("Encode plan created", "transcoder", map[string]interface{}{
+		"renditions":       len(plan.Renditions),
+		"estimated_size":   plan.EstimatedSize,
+		"estimated_encode": plan.EstimatedEncodeTime.String(),
+	})
+
+	return plan, nil
+}
+
+// plannedResolutions returns the renditions Transcode would produce for the input,
+// including the adjustments made for UseAutoResolutions, DisallowUpscale and
+// MaxOutputSizeBytes.
+func (t *Transcoder) plannedResolutions(info *VideoInfo) ([]hls.VideoResolution, error) {
+	if t.options.OutputType == MP4Output {
+		return []hls.VideoResolution{
+			{Width: info.Width, Height: info.Height, AudioBitrate: mp4AudioBitrate},
+		}, nil
+	}
+
+	resolutions := t.options.HLSResolutions
+	if len(resolutions) == 0 {
+		resolutions = hls.DefaultResolutions
+	}
+	if t.options.UseAutoResolutions {
+		resolutions = hls.GenerateAutoResolutionsWithPolicy(info.Width, info.Height,
+			t.options.AutoScalePolicy, t.options.AutoDimensionAlignment)
+		resolutions = limitLowFrameRates(resolutions, info)
+	} else if t.options.DisallowUpscale {
+		resolutions, _ = hls.RemoveUpscaled(resolutions, info.Width, info.Height)
+	}
+	return hls.FitToBudgetWithOverhead(resolutions, info.Duration, t.options.MaxOutputSizeBytes, t.muxOverhead())
+}
+
+// Calibration benchmark settings: a synthetic 720p clip encoded with the same
+// x264 preset used for HLS and MP4 output.
+const (
+	calibrationWidth  = 1280
+	calibrationHeight = 720
+	calibrationFrames = 90
+)
+
+var (
+	calibrationMu    sync.Mutex
+	calibrationCache = map[string]Calibration{}
+)
+
+// calibrate returns the calibration set with WithCalibration, or runs (and caches
+// per ffmpeg binary) a short benchmark encode of a synthetic clip.
+func (t *Transcoder) calibrate(ctx context.Context) (Calibration, error) {
+	if t.calibration != nil {
+		return *t.calibration, nil
+	}
+
+	calibrationMu.Lock()
+	defer calibrationMu.Unlock()
+	if c, ok := calibrationCache[t.options.FFmpegBinary]; ok {
+		return c, nil
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-f", "lavfi",
+		"-i", fmt.Sprintf("testsrc2=size=%dx%d:rate=30", calibrationWidth, calibrationHeight),
+		"-frames:v", strconv.Itoa(calibrationFrames),
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-f", "null",
+		"-",
+	}
+
+	start := time.Now()
+	if _, err := t.runner.Output(ctx, t.options.FFmpegBinary, args...); err != nil {
+		return Calibration{}, errors.Wrap(err, errors.CodecNotFoundError,
+			errors.GetErrorMessage(errors.ErrMissingDependency),
+			errors.ErrMissingDependency)
+	}
+	elapsed := time.Since(start).Seconds()
+	if elapsed <= 0 {
+		return Calibration{}, nil
+	}
+
+	c := Calibration{
+		PixelRate: float64(calibrationWidth*calibrationHeight*calibrationFrames) / elapsed,
+	}
+	calibrationCache[t.options.FFmpegBinary] = c
+
+	t.logger.Debug("Encoder calibration completed", "transcoder", map[string]interface{}{
+		"pixel_rate": c.PixelRate,
+		"elapsed":    elapsed,
+	})
+
+	return c, nil
+}
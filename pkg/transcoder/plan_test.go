@@ -0,0 +1,211 @@
+package transcoder
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+const probeJSON = `{
+	"streams": [
+		{"codec_type": "audio"},
+		{"codec_type": "video", "width": 1920, "height": 1080, "r_frame_rate": "25/1"}
+	],
+	"format": {"duration": "60.000000"}
+}`
+
+func newProbeRunner() *ffmpegtest.Runner {
+	return newProbeRunnerWith(probeJSON)
+}
+
+func newProbeRunnerWith(probe string) *ffmpegtest.Runner {
+	return &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			if name == "ffprobe" {
+				return ffmpegtest.Result{Stdout: probe}
+			}
+			return ffmpegtest.Result{}
+		},
+	}
+}
+
+func TestPlanHLS(t *testing.T) {
+	opts := Options{
+		InputPath:  "input.mp4",
+		OutputPath: "out",
+		HLSResolutions: []hls.VideoResolution{
+			{Width: 1280, Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+			{Width: 640, Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+		},
+	}
+	calibration := Calibration{PixelRate: 1280 * 720 * 25}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunner()), WithCalibration(calibration))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	plan, err := trans.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() unexpected error: %v", err)
+	}
+
+	if plan.Input.Width != 1920 || plan.Input.Height != 1080 || plan.Input.FrameRate != 25 || plan.Input.Duration != 60 {
+		t.Errorf("Unexpected input info: %+v", plan.Input)
+	}
+	if len(plan.Renditions) != 2 {
+		t.Fatalf("Plan() returned %d renditions, want 2", len(plan.Renditions))
+	}
+	// (2800k + 128k) * 60s / 8 and (800k + 96k) * 60s / 8, plus the MPEG-TS overhead
+	if plan.Renditions[0].EstimatedSize != 23277600 || plan.Renditions[1].EstimatedSize != 7123200 {
+		t.Errorf("Unexpected rendition sizes: %d, %d", plan.Renditions[0].EstimatedSize, plan.Renditions[1].EstimatedSize)
+	}
+	if plan.EstimatedSize != 30400800 {
+		t.Errorf("EstimatedSize = %d, want 30400800", plan.EstimatedSize)
+	}
+	// The 720p rendition encodes in real time and the 360p one adds a quarter of that.
+	if plan.EstimatedEncodeTime != 75*time.Second {
+		t.Errorf("EstimatedEncodeTime = %v, want 75s", plan.EstimatedEncodeTime)
+	}
+}
+
+func TestPlanHLSSharedAudio(t *testing.T) {
+	opts := Options{
+		InputPath:  "input.mp4",
+		OutputPath: "out",
+		HLSResolutions: []hls.VideoResolution{
+			{Width: 1280, Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+			{Width: 640, Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+		},
+		HLSSharedAudio: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunner()), WithCalibration(Calibration{}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	plan, err := trans.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() unexpected error: %v", err)
+	}
+	// 2800k and 800k of video, and 128k of audio once, for 60s, plus the MPEG-TS overhead
+	if plan.SharedAudioBitrate != "128k" || plan.Renditions[0].AudioBitrate != "" || plan.EstimatedSize != 29637600 {
+		t.Errorf("Plan() = shared audio %q, rendition audio %q, size %d; want 128k, none, 29637600",
+			plan.SharedAudioBitrate, plan.Renditions[0].AudioBitrate, plan.EstimatedSize)
+	}
+}
+
+func TestPlanMP4(t *testing.T) {
+	opts := Options{InputPath: "input.mp4", OutputPath: "out.mp4", OutputType: MP4Output}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunner()), WithCalibration(Calibration{}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	plan, err := trans.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() unexpected error: %v", err)
+	}
+	if len(plan.Renditions) != 1 || plan.Renditions[0].Width != 1920 || plan.Renditions[0].VideoBitrate != "" {
+		t.Errorf("Unexpected renditions: %+v", plan.Renditions)
+	}
+	if plan.EstimatedSize <= 0 {
+		t.Errorf("EstimatedSize = %d, want > 0", plan.EstimatedSize)
+	}
+	if plan.EstimatedEncodeTime != 0 {
+		t.Errorf("EstimatedEncodeTime = %v, want 0 without calibration", plan.EstimatedEncodeTime)
+	}
+}
+
+func TestPlanRunsCalibration(t *testing.T) {
+	runner := newProbeRunner()
+	opts := Options{InputPath: "input.mp4", OutputPath: "out", FFmpegBinary: "ffmpeg-plan-test"}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := trans.Plan(context.Background()); err != nil {
+			t.Fatalf("Plan() unexpected error: %v", err)
+		}
+	}
+
+	benchmarks := 0
+	for _, call := range runner.Calls() {
+		if call.Name == "ffmpeg-plan-test" {
+			benchmarks++
+		}
+	}
+	if benchmarks != 1 {
+		t.Errorf("Calibration benchmark ran %d times, want 1 (cached)", benchmarks)
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	tests := map[string]float64{
+		"25/1":       25,
+		"30000/1001": 30000.0 / 1001,
+		"24":         24,
+		"0/0":        0,
+		"":           0,
+	}
+	for in, want := range tests {
+		if got := parseFrameRate(in); got != want {
+			t.Errorf("parseFrameRate(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestPlanDisallowUpscale(t *testing.T) {
+	opts := Options{
+		InputPath:       "input.mp4",
+		OutputPath:      "out",
+		HLSResolutions:  hls.Ladder4K,
+		DisallowUpscale: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunner()), WithCalibration(Calibration{}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	plan, err := trans.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() unexpected error: %v", err)
+	}
+	// The 1080p source drops the 2160p and 1440p renditions.
+	if len(plan.Renditions) != 4 || plan.Renditions[0].Height != 1080 {
+		t.Errorf("Unexpected renditions: %+v", plan.Renditions)
+	}
+}
+
+func TestPlanAutoResolutionsHalvesLowFrameRates(t *testing.T) {
+	probe := strings.Replace(probeJSON, `"25/1"`, `"60/1"`, 1)
+	opts := Options{InputPath: "input.mp4", OutputPath: "out", UseAutoResolutions: true}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunnerWith(probe)), WithCalibration(Calibration{}))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	plan, err := trans.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() unexpected error: %v", err)
+	}
+	for _, r := range plan.Renditions {
+		want := 60.0
+		if r.Height <= hls.LowFrameRateMaxHeight {
+			want = 30
+		}
+		if r.FrameRate != want {
+			t.Errorf("Rendition %dx%d FrameRate = %v, want %v", r.Width, r.Height, r.FrameRate, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/upload"
+)
+
+// Result describes the output of a completed Transcode call, as passed to
+// post-processors.
+type Result struct {
+	// Path is the primary output file returned by Transcode: the master playlist
+	// for HLSOutput, or the MP4 file, with encrypt.Suffix when encrypted.
+	Path string
+	// OutputPath is the output directory for HLSOutput, or the output file for
+	// MP4Output (without encrypt.Suffix).
+	OutputPath string
+	// OutputType is the type of the output.
+	OutputType OutputType
+	// Dir is the directory where auxiliary files (preview, reports, manifest)
+	// are written.
+	Dir string
+	// InputPath is the local path of the input, or its URL when streamed.
+	InputPath string
+	// JobID is the identifier of the job.
+	JobID string
+	// Encrypted is set when the output files are encrypted at rest.
+	Encrypted bool
+	// Cached is set when the output was restored from the cache.
+	Cached bool
+}
+
+// PostProcessor is a step run on the output once Transcode has produced it,
+// registered with WithPostProcessor. Returning an error fails the job.
+type PostProcessor interface {
+	Process(ctx context.Context, result Result) error
+}
+
+// PostProcessorFunc adapts a function to the PostProcessor interface.
+type PostProcessorFunc func(ctx context.Context, result Result) error
+
+// Process implements PostProcessor.
+func (f PostProcessorFunc) Process(ctx context.Context, result Result) error {
+	return f(ctx, result)
+}
+
+// WithPostProcessor registers post-processors run in order on the output once
+// it is complete (encoded, encrypted and uploaded to Options.UploadURL or the
+// WithUploader destination), before Options.PostHook. It can be given several
+// times.
+func WithPostProcessor(processors ...PostProcessor) DependencyOption {
+	return func(t *Transcoder) {
+		t.postProcessors = append(t.postProcessors, processors...)
+	}
+}
+
+// result returns the Result of the output at path.
+func (t *Transcoder) result(path, inputPath string) Result {
+	return Result{
+		Path:       path,
+		OutputPath: t.options.OutputPath,
+		OutputType: t.options.OutputType,
+		Dir:        t.sidecarDir(),
+		InputPath:  inputPath,
+		JobID:      t.options.JobID,
+		Encrypted:  len(t.options.EncryptionKey) > 0,
+		Cached:     t.cached,
+	}
+}
+
+// runPostProcessors runs the registered post-processors on result, stopping at
+// the first failure. Errors that are not a *errors.StructuredError are wrapped
+// as a SystemError.
+func (t *Transcoder) runPostProcessors(ctx context.Context, result Result) error {
+	for i, processor := range t.postProcessors {
+		t.logger.Debug("Running post-processor", "transcoder", map[string]interface{}{
+			"index":     i,
+			"processor": fmt.Sprintf("%T", processor),
+		})
+		if err := processor.Process(ctx, result); err != nil {
+			var sErr *errors.StructuredError
+			if stderrors.As(err, &sErr) {
+				return sErr
+			}
+			return errors.Wrap(err, errors.SystemError, fmt.Sprintf("Post-processor %T failed", processor), 23)
+		}
+	}
+	return nil
+}
+
+// UploadProcessor is a PostProcessor publishing the output with Uploader: the
+// whole HLS output directory (see upload.UploadDir), or the MP4 file under its
+// base name.
+type UploadProcessor struct {
+	Uploader upload.Uploader
+}
+
+// Process implements PostProcessor.
+func (p UploadProcessor) Process(ctx context.Context, result Result) error {
+	_, err := uploadResult(ctx, p.Uploader, result)
+	return err
+}
+
+// uploadResult uploads the output of result with uploader, returning the remote
+// paths of the uploaded files.
+func uploadResult(ctx context.Context, uploader upload.Uploader, result Result) ([]string, error) {
+	if result.OutputType == MP4Output {
+		remotePath := filepath.Base(result.Path)
+		if err := uploader.Upload(ctx, result.Path, remotePath); err != nil {
+			return nil, err
+		}
+		return []string{remotePath}, nil
+	}
+	return upload.UploadDir(ctx, uploader, result.OutputPath)
+}
+
+// ValidationProcessor is a PostProcessor checking the output is complete before
+// later steps publish it: every variant playlist of the master playlist exists,
+// is finished (#EXT-X-ENDLIST) and lists existing segments, or the MP4 file is
+// not empty. The playlists of encrypted HLS outputs cannot be read, so only the
+// presence of the master playlist is checked for them.
+type ValidationProcessor struct{}
+
+// Process implements PostProcessor.
+func (ValidationProcessor) Process(ctx context.Context, result Result) error {
+	if result.OutputType == HLSOutput && !result.Encrypted {
+		if !completeHLS(result.Path) {
+			return errors.New(errors.HLSError, "The output is incomplete",
+				"a variant playlist or segment of "+result.Path+" is missing or unfinished", 23)
+		}
+		return nil
+	}
+
+	if info, err := os.Stat(result.Path); err != nil || info.Size() == 0 {
+		return errors.New(errors.FileNotFoundError, "The output is missing or empty", result.Path, errors.ErrFileNotAccessible)
+	}
+	return nil
+}
+
+// ThumbnailProcessor is a PostProcessor writing a JPEG thumbnail of the input
+// into the auxiliary files directory, using the ffmpeg thumbnail filter to pick
+// a representative frame.
+type ThumbnailProcessor struct {
+	// FileName is the name of the thumbnail. Defaults to "thumbnail.jpg".
+	FileName string
+	// Offset is the position in the input from which a frame is picked.
+	Offset time.Duration
+	// Width of the thumbnail in pixels. The height is derived from the aspect
+	// ratio. Defaults to 320.
+	Width int
+	// FFmpegBinary allows specifying a custom path to the ffmpeg executable. Defaults to "ffmpeg".
+	FFmpegBinary string
+	// Runner launches the ffmpeg process. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+}
+
+// Process implements PostProcessor.
+func (p ThumbnailProcessor) Process(ctx context.Context, result Result) error {
+	fileName, width, binary, runner := p.FileName, p.Width, p.FFmpegBinary, p.Runner
+	if fileName == "" {
+		fileName = "thumbnail.jpg"
+	}
+	if width <= 0 {
+		width = 320
+	}
+	if binary == "" {
+		binary = "ffmpeg"
+	}
+	if runner == nil {
+		runner = ffmpeg.ExecRunner{}
+	}
+
+	args := []string{"-y"}
+	if p.Offset > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(p.Offset.Seconds(), 'f', -1, 64))
+	}
+	args = append(args,
+		"-i", result.InputPath,
+		"-vf", fmt.Sprintf("thumbnail,scale=%d:-2", width),
+		"-frames:v", "1",
+		"-q:v", "2",
+		filepath.Join(result.Dir, fileName),
+	)
+
+	proc, err := runner.Start(ctx, binary, args...)
+	if err != nil {
+		return errors.Wrap(err, errors.TranscodingError, "Failed to start FFmpeg", 23)
+	}
+	var stderr bytes.Buffer
+	_, _ = io.Copy(&stderr, proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		return errors.New(errors.TranscodingError, "FFmpeg thumbnail command failed", strings.TrimSpace(stderr.String()), 23).WithFFmpegOutput(stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestTranscodePostProcessors(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	var order []string
+	var got Result
+	record := func(name string) PostProcessor {
+		return PostProcessorFunc(func(ctx context.Context, result Result) error {
+			order = append(order, name)
+			got = result
+			return nil
+		})
+	}
+	uploader := &recordingUploader{}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "out", "video.mp4"),
+		OutputType:         MP4Output,
+		JobID:              "job-42",
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}),
+		WithPostProcessor(record("first"), ValidationProcessor{}),
+		WithPostProcessor(UploadProcessor{Uploader: uploader}, record("last")))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	if strings.Join(order, ",") != "first,last" {
+		t.Errorf("Post-processors ran as %v, want [first last]", order)
+	}
+	if len(uploader.uploaded) != 1 || uploader.uploaded[0] != "video.mp4" {
+		t.Errorf("Uploaded %v, want [video.mp4]", uploader.uploaded)
+	}
+	want := Result{
+		Path:       opts.OutputPath,
+		OutputPath: opts.OutputPath,
+		OutputType: MP4Output,
+		Dir:        filepath.Join(dir, "out"),
+		InputPath:  inputPath,
+		JobID:      "job-42",
+	}
+	if got != want {
+		t.Errorf("Result = %+v, want %+v", got, want)
+	}
+}
+
+func TestTranscodePostProcessorFailure(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	ran := false
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "video.mp4"),
+		OutputType:         MP4Output,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}),
+		WithPostProcessor(
+			PostProcessorFunc(func(ctx context.Context, result Result) error { return stderrors.New("catalog unavailable") }),
+			PostProcessorFunc(func(ctx context.Context, result Result) error { ran = true; return nil })))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	_, err = trans.Transcode(context.Background())
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Code != 23 || !strings.Contains(sErr.Error(), "catalog unavailable") {
+		t.Fatalf("Transcode() error = %v, want the post-processor failure", err)
+	}
+	if ran {
+		t.Error("Post-processors after a failure should not run")
+	}
+}
+
+func TestValidationProcessor(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("master.m3u8", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n")
+	write("stream_0/playlist.m3u8", "#EXTM3U\n#EXTINF:4.0,\ndata000.ts\n")
+	write("stream_0/data000.ts", "segment")
+
+	result := Result{Path: filepath.Join(dir, "master.m3u8"), OutputPath: dir, OutputType: HLSOutput}
+	if err := (ValidationProcessor{}).Process(context.Background(), result); err == nil {
+		t.Error("Process() expected an error for an unfinished variant playlist")
+	}
+	write("stream_0/playlist.m3u8", "#EXTM3U\n#EXTINF:4.0,\ndata000.ts\n#EXT-X-ENDLIST\n")
+	if err := (ValidationProcessor{}).Process(context.Background(), result); err != nil {
+		t.Errorf("Process() unexpected error: %v", err)
+	}
+
+	mp4 := Result{Path: filepath.Join(dir, "missing.mp4"), OutputType: MP4Output}
+	if err := (ValidationProcessor{}).Process(context.Background(), mp4); err == nil {
+		t.Error("Process() expected an error for a missing MP4 output")
+	}
+}
+
+func TestThumbnailProcessor(t *testing.T) {
+	runner := &ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}
+	dir := t.TempDir()
+	result := Result{InputPath: "input.mp4", Dir: dir}
+	processor := ThumbnailProcessor{Offset: 90 * time.Second, Width: 480, Runner: runner}
+	if err := processor.Process(context.Background(), result); err != nil {
+		t.Fatalf("Process() unexpected error: %v", err)
+	}
+
+	calls := runner.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("Expected one ffmpeg call, got %d", len(calls))
+	}
+	args := strings.Join(calls[0].Args, " ")
+	want := "-y -ss 90 -i input.mp4 -vf thumbnail,scale=480:-2 -frames:v 1 -q:v 2 " + filepath.Join(dir, "thumbnail.jpg")
+	if calls[0].Name != "ffmpeg" || args != want {
+		t.Errorf("ffmpeg args = %q, want %q", args, want)
+	}
+}
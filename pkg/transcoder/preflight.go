@@ -0,0 +1,191 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// PreflightMode selects how a streamed http(s) input is checked before ffmpeg opens it.
+type PreflightMode string
+
+const (
+	// PreflightHead sends a HEAD request, falling back to a GET range probe when
+	// the server rejects HEAD (405, 501, or 403 as returned for pre-signed URLs
+	// signed for GET). This is the default.
+	PreflightHead PreflightMode = "head"
+	// PreflightGet sends a GET request for the first byte only ("Range: bytes=0-0").
+	PreflightGet PreflightMode = "get"
+	// PreflightNone skips the check; ffmpeg reports unreachable inputs itself.
+	PreflightNone PreflightMode = "none"
+)
+
+// DefaultPreflightTimeout is the time allowed for each preflight request when
+// Options.PreflightTimeout is zero.
+const DefaultPreflightTimeout = 10 * time.Second
+
+// DefaultPreflightContentTypes lists the Content-Type values accepted by the
+// preflight check when Options.PreflightContentTypes is empty. An entry ending in
+// "/" matches every subtype.
+var DefaultPreflightContentTypes = []string{
+	"video/",
+	"application/octet-stream",
+	"binary/octet-stream", // Amazon S3 default for uploads without a content type
+	"application/vnd.apple.mpegurl",
+	"application/x-mpegurl",
+	"application/dash+xml",
+}
+
+// ParsePreflightMode validates a preflight mode name, case-insensitively.
+// The empty string selects PreflightHead.
+func ParsePreflightMode(value string) (PreflightMode, error) {
+	switch mode := PreflightMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return PreflightHead, nil
+	case PreflightHead, PreflightGet, PreflightNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown preflight mode %q (expected head, get or none)", value)
+	}
+}
+
+// isURL reports whether input is a URL with a scheme ffmpeg may open directly,
+// such as https://, rtmp:// or srt://. Single-letter schemes are Windows drive letters.
+func isURL(input string) bool {
+	u, err := url.Parse(input)
+	return err == nil && len(u.Scheme) > 1 && (u.Host != "" || u.Opaque != "")
+}
+
+// preflight checks that the streamed input URL is reachable and serves a
+// supported content type before ffmpeg opens it. Only http(s) URLs are checked.
+func (t *Transcoder) preflight(ctx context.Context, inputURL *url.URL) error {
+	if t.options.SkipPreflight || t.options.PreflightMode == PreflightNone {
+		return nil
+	}
+	if inputURL.Scheme != "http" && inputURL.Scheme != "https" {
+		t.logger.Debug("Skipping preflight check for non-HTTP input", "transcoder", map[string]interface{}{
+			"scheme": inputURL.Scheme,
+		})
+		return nil
+	}
+
+	timeout := t.options.PreflightTimeout
+	if timeout <= 0 {
+		timeout = DefaultPreflightTimeout
+	}
+	client := http.Client{
+		Timeout: timeout,
+	}
+
+	method := http.MethodHead
+	if t.options.PreflightMode == PreflightGet {
+		method = http.MethodGet
+	}
+	resp, err := t.preflightRequest(ctx, &client, method, inputURL.String())
+	if err != nil {
+		return err
+	}
+	if method == http.MethodHead && headRejected(resp.StatusCode) {
+		t.logger.Debug("HEAD request rejected, probing with a GET range request", "transcoder", map[string]interface{}{
+			"status": resp.StatusCode,
+		})
+		resp, err = t.preflightRequest(ctx, &client, http.MethodGet, inputURL.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		return errors.New(errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkServerUnavailable),
+			fmt.Sprintf("Server returned status code %d", resp.StatusCode), errors.ErrNetworkServerUnavailable)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !acceptedContentType(contentType, t.options.PreflightContentTypes) {
+		return errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrInvalidFileFormat),
+			fmt.Sprintf("Content-Type: %s", contentType), errors.ErrInvalidFileFormat)
+	}
+	return t.checkInputSize(announcedSize(resp))
+}
+
+// preflightRequest sends a preflight request, classifying connection failures.
+// GET requests only ask for the first byte, and the body is never read.
+func (t *Transcoder) preflightRequest(ctx context.Context, client *http.Client, method, inputURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, inputURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, classifyRequestError(err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// classifyRequestError maps a failed HTTP request to a NetworkError with the
+// timeout, DNS or connection failure code.
+func classifyRequestError(err error) *errors.StructuredError {
+	if os.IsTimeout(err) {
+		return errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkTimeout), errors.ErrNetworkTimeout)
+	}
+
+	// Verificar o erro de DNS - precisamos garantir que não seja caso-sensível e inclua variações comuns
+	errMsg := strings.ToLower(err.Error())
+	if strings.Contains(errMsg, "no such host") ||
+		strings.Contains(errMsg, "lookup") ||
+		strings.Contains(errMsg, "dns") ||
+		strings.Contains(errMsg, "could not resolve") ||
+		strings.Contains(errMsg, "unknown host") {
+		return errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkDNSFailure), errors.ErrNetworkDNSFailure)
+	}
+
+	return errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
+}
+
+// headRejected reports whether status means that the server does not answer
+// HEAD requests, although it may serve GET.
+func headRejected(status int) bool {
+	return status == http.StatusMethodNotAllowed ||
+		status == http.StatusNotImplemented ||
+		status == http.StatusForbidden
+}
+
+// acceptedContentType reports whether contentType matches one of accepted
+// (DefaultPreflightContentTypes when empty). Entries ending in "/" match every
+// subtype and "*" matches anything. A missing Content-Type is accepted.
+func acceptedContentType(contentType string, accepted []string) bool {
+	if contentType == "" {
+		return true
+	}
+	if len(accepted) == 0 {
+		accepted = DefaultPreflightContentTypes
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, entry := range accepted {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "*":
+			return true
+		case strings.HasSuffix(entry, "/") && strings.HasPrefix(mediaType, entry):
+			return true
+		case entry == mediaType:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package transcoder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// newStreamTranscoder returns a Transcoder streaming inputURL with the given
+// preflight options.
+func newStreamTranscoder(t *testing.T, inputURL string, mode PreflightMode, contentTypes []string) *Transcoder {
+	t.Helper()
+	opts := Options{
+		InputPath:             inputURL,
+		OutputPath:            t.TempDir(),
+		StreamFromURL:         true,
+		PreflightMode:         mode,
+		PreflightContentTypes: contentTypes,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	return trans
+}
+
+func TestPreflight(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.Header.Get("Range"))
+		switch r.URL.Path {
+		case "/presigned.mp4":
+			// Pre-signed URLs are only valid for the method they were signed for
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "binary/octet-stream")
+			w.WriteHeader(http.StatusPartialContent)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		case "/missing.mp4":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "video/mp4")
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		mode         PreflightMode
+		contentTypes []string
+		wantMethods  []string
+		wantCode     int
+	}{
+		{name: "HEAD", path: "/video.mp4", wantMethods: []string{"HEAD "}},
+		{name: "GET range", path: "/video.mp4", mode: PreflightGet, wantMethods: []string{"GET bytes=0-0"}},
+		{name: "HEAD rejected", path: "/presigned.mp4", wantMethods: []string{"HEAD ", "GET bytes=0-0"}},
+		{name: "Skipped", path: "/page.html", mode: PreflightNone},
+		{name: "Unsupported content type", path: "/page.html", wantMethods: []string{"HEAD "}, wantCode: errors.ErrInvalidFileFormat},
+		{name: "Accepted content type", path: "/page.html", contentTypes: []string{"text/html"}, wantMethods: []string{"HEAD "}},
+		{name: "Not found", path: "/missing.mp4", wantMethods: []string{"HEAD "}, wantCode: errors.ErrNetworkServerUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			methods = nil
+			trans := newStreamTranscoder(t, server.URL+tt.path, tt.mode, tt.contentTypes)
+
+			_, err := trans.handleInput(context.Background())
+			if tt.wantCode == 0 && err != nil {
+				t.Fatalf("handleInput() unexpected error: %v", err)
+			}
+			if tt.wantCode != 0 {
+				sErr, ok := err.(*errors.StructuredError)
+				if !ok || sErr.Code != tt.wantCode {
+					t.Fatalf("handleInput() error = %v, want code %d", err, tt.wantCode)
+				}
+			}
+			if len(methods) != len(tt.wantMethods) {
+				t.Fatalf("Requests = %q, want %q", methods, tt.wantMethods)
+			}
+			for i := range methods {
+				if methods[i] != tt.wantMethods[i] {
+					t.Errorf("Request %d = %q, want %q", i, methods[i], tt.wantMethods[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPreflightTimeoutAndSkip(t *testing.T) {
+	// Cada caso usa seu próprio servidor, para que o handler lento do
+	// primeiro não seja contado no segundo
+	newSlowServer := func(requests *atomic.Int32) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			time.Sleep(200 * time.Millisecond)
+			w.Header().Set("Content-Type", "video/mp4")
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	var slowRequests atomic.Int32
+	trans := newStreamTranscoder(t, newSlowServer(&slowRequests).URL+"/slow.mp4", "", nil)
+	trans.options.PreflightTimeout = 20 * time.Millisecond
+	_, err := trans.handleInput(context.Background())
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Code != errors.ErrNetworkTimeout {
+		t.Fatalf("handleInput() error = %v, want code %d", err, errors.ErrNetworkTimeout)
+	}
+
+	var requests atomic.Int32
+	trans = newStreamTranscoder(t, newSlowServer(&requests).URL+"/slow.mp4", "", nil)
+	trans.options.SkipPreflight = true
+	if _, err := trans.handleInput(context.Background()); err != nil {
+		t.Fatalf("handleInput() unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("SkipPreflight sent %d requests, want 0", n)
+	}
+}
+
+func TestStreamNonHTTPInput(t *testing.T) {
+	trans := newStreamTranscoder(t, "rtmp://live.example.com/app/stream", "", nil)
+	if !trans.options.IsRemoteInput {
+		t.Fatal("rtmp:// input should be treated as remote when streaming")
+	}
+
+	got, err := trans.handleInput(context.Background())
+	if err != nil {
+		t.Fatalf("handleInput() unexpected error: %v", err)
+	}
+	if got != "rtmp://live.example.com/app/stream" {
+		t.Errorf("handleInput() = %q, want the input URL", got)
+	}
+}
+
+func TestInvalidPreflightMode(t *testing.T) {
+	opts := Options{InputPath: "https://example.com/video.mp4", OutputPath: "out", StreamFromURL: true, PreflightMode: "options"}
+	if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+		t.Fatal("NewWithDeps() expected an error for an unknown preflight mode")
+	}
+}
+
+func TestAcceptedContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		accepted    []string
+		want        bool
+	}{
+		{"video/mp4", nil, true},
+		{"Video/MP2T", nil, true},
+		{"application/octet-stream", nil, true},
+		{"application/vnd.apple.mpegurl; charset=utf-8", nil, true},
+		{"", nil, true},
+		{"text/html", nil, false},
+		{"text/html", []string{"*"}, true},
+		{"video/mp4", []string{"video/webm"}, false},
+		{"application/x-custom", []string{"application/"}, true},
+	}
+	for _, tt := range tests {
+		if got := acceptedContentType(tt.contentType, tt.accepted); got != tt.want {
+			t.Errorf("acceptedContentType(%q, %q) = %v, want %v", tt.contentType, tt.accepted, got, tt.want)
+		}
+	}
+}
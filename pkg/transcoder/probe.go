@@ -0,0 +1,235 @@
+package transcoder
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// StreamInfo describes one stream of the input as reported by ffprobe.
+// Fields that do not apply to the stream type are omitted from the JSON.
+type StreamInfo struct {
+	// Index is the stream index in the container.
+	Index int `json:"index"`
+	// Type is the stream type: "video", "audio", "subtitle", "data" or "attachment".
+	Type string `json:"type"`
+	// Codec is the ffmpeg codec name (e.g. "h264", "aac").
+	Codec string `json:"codec,omitempty"`
+	// Profile is the codec profile (e.g. "High", "LC").
+	Profile string `json:"profile,omitempty"`
+	// Width of a video stream in pixels.
+	Width int `json:"width,omitempty"`
+	// Height of a video stream in pixels.
+	Height int `json:"height,omitempty"`
+	// PixelFormat of a video stream (e.g. "yuv420p").
+	PixelFormat string `json:"pixel_format,omitempty"`
+	// FrameRate of a video stream in frames per second.
+	FrameRate float64 `json:"frame_rate,omitempty"`
+	// SampleRate of an audio stream in Hz.
+	SampleRate int `json:"sample_rate,omitempty"`
+	// Channels is the number of audio channels.
+	Channels int `json:"channels,omitempty"`
+	// ChannelLayout of an audio stream (e.g. "stereo", "5.1").
+	ChannelLayout string `json:"channel_layout,omitempty"`
+	// BitRate of the stream in bits per second, when known.
+	BitRate int64 `json:"bit_rate,omitempty"`
+	// Duration of the stream in seconds, when known.
+	Duration float64 `json:"duration,omitempty"`
+	// Language is the ISO 639 language tag of the stream, when set.
+	Language string `json:"language,omitempty"`
+	// Captions reports whether a video stream carries embedded CEA-608/708
+	// closed captions.
+	Captions bool `json:"closed_captions,omitempty"`
+}
+
+// MediaInfo describes an input file: its container, streams and the HLS ladder
+// automatic resolutions would produce for it.
+type MediaInfo struct {
+	// Input is the probed path or URL.
+	Input string `json:"input"`
+	// Format is the container format reported by ffprobe (e.g. "mov,mp4,m4a,3gp,3g2,mj2").
+	Format string `json:"format"`
+	// Duration of the input in seconds.
+	Duration float64 `json:"duration"`
+	// Size of the input in bytes, when known.
+	Size int64 `json:"size_bytes,omitempty"`
+	// BitRate is the overall bitrate of the input in bits per second, when known.
+	BitRate int64 `json:"bit_rate,omitempty"`
+	// Video holds the resolution, duration and frame rate of the first video
+	// stream, as used for transcoding. Nil when the input has no video.
+	Video *VideoInfo `json:"video,omitempty"`
+	// VideoCodec and AudioCodec are the codecs of the first video and audio streams.
+	VideoCodec string `json:"video_codec,omitempty"`
+	AudioCodec string `json:"audio_codec,omitempty"`
+	// Streams lists every stream of the input, in container order.
+	Streams []StreamInfo `json:"streams"`
+	// SuggestedLadder is the HLS ladder UseAutoResolutions would produce for the
+	// input, with the configured AutoScalePolicy and AutoDimensionAlignment.
+	SuggestedLadder []hls.VideoResolution `json:"suggested_ladder"`
+}
+
+// JSON returns the MediaInfo serialized as an indented JSON string.
+func (m *MediaInfo) JSON() (string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Probe inspects the input described by options with ffprobe and returns its
+// container, streams and suggested auto ladder, without transcoding.
+// OutputPath is not used for probing and may be empty.
+// See Transcoder.Probe.
+func Probe(ctx context.Context, options Options) (*MediaInfo, error) {
+	if options.OutputPath == "" {
+		options.OutputPath = "."
+	}
+	t, err := New(options, nil)
+	if err != nil {
+		return nil, err
+	}
+	return t.Probe(ctx)
+}
+
+// Probe inspects the input with ffprobe and returns its container, streams and
+// the HLS ladder automatic resolutions would produce for it. Inputs without a
+// video stream are described with an empty ladder.
+func (t *Transcoder) Probe(ctx context.Context) (*MediaInfo, error) {
+	output, err := runFFprobe(ctx, t.runner, t.options.InputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InvalidFileFormatError,
+			errors.GetErrorMessage(errors.ErrInvalidFileFormat),
+			errors.ErrInvalidFileFormat)
+	}
+
+	info := &MediaInfo{
+		Input:           t.options.InputPath,
+		Format:          output.Format.FormatName,
+		Duration:        parseFloat(output.Format.Duration),
+		Size:            parseInt(output.Format.Size),
+		BitRate:         parseInt(output.Format.BitRate),
+		Streams:         []StreamInfo{},
+		SuggestedLadder: []hls.VideoResolution{},
+	}
+
+	for _, s := range output.Streams {
+		stream := StreamInfo{
+			Index:         s.Index,
+			Type:          s.CodecType,
+			Codec:         s.CodecName,
+			Profile:       s.Profile,
+			Width:         s.Width,
+			Height:        s.Height,
+			PixelFormat:   s.PixelFormat,
+			FrameRate:     parseFrameRate(s.FrameRate),
+			SampleRate:    int(parseInt(s.SampleRate)),
+			Channels:      s.Channels,
+			ChannelLayout: s.ChannelLayout,
+			BitRate:       parseInt(s.BitRate),
+			Duration:      parseFloat(s.Duration),
+			Language:      s.Tags.Language,
+			Captions:      s.Captions == 1,
+		}
+		info.Streams = append(info.Streams, stream)
+
+		switch {
+		case s.CodecType == "video" && info.Video == nil && s.Width > 0 && s.Height > 0:
+			info.Video = &VideoInfo{
+				Width:     s.Width,
+				Height:    s.Height,
+				Duration:  info.Duration,
+				FrameRate: stream.FrameRate,
+				Captions:  stream.Captions,
+			}
+			info.VideoCodec = s.CodecName
+		case s.CodecType == "audio" && info.AudioCodec == "":
+			info.AudioCodec = s.CodecName
+		}
+	}
+
+	if info.Video != nil {
+		ladder := hls.GenerateAutoResolutionsWithPolicy(info.Video.Width, info.Video.Height,
+			t.options.AutoScalePolicy, t.options.AutoDimensionAlignment)
+		info.SuggestedLadder = limitLowFrameRates(ladder, info.Video)
+	}
+
+	t.logger.Info("Input probed", "transcoder", map[string]interface{}{
+		"streams":  len(info.Streams),
+		"duration": info.Duration,
+		"ladder":   len(info.SuggestedLadder),
+	})
+
+	return info, nil
+}
+
+// reportInputProbed reports EventInputProbed with the container, duration and
+// main streams of the input, so that UIs can describe the job before the
+// encoding progresses. The input is only probed here when the progress
+// reporter receives lifecycle events; otherwise the result of the format check
+// is reused. A failed probe is logged and left to the steps needing it.
+func (t *Transcoder) reportInputProbed(ctx context.Context, inputPath string) {
+	if _, ok := t.progRep.(progress.EventReporter); !ok {
+		return
+	}
+	output, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		t.logger.Debug("Failed to probe the input for the progress events", "transcoder", map[string]interface{}{
+			"path":  redactURL(inputPath),
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"format":           output.Format.FormatName,
+		"duration_seconds": parseFloat(output.Format.Duration),
+	}
+	if size := parseInt(output.Format.Size); size > 0 {
+		data["bytes"] = size
+	}
+	for _, s := range output.Streams {
+		switch {
+		case s.CodecType == "video" && data["video_codec"] == nil:
+			data["video_codec"] = s.CodecName
+			data["width"] = s.Width
+			data["height"] = s.Height
+			data["frame_rate"] = parseFrameRate(s.FrameRate)
+		case s.CodecType == "audio" && data["audio_codec"] == nil:
+			data["audio_codec"] = s.CodecName
+		}
+	}
+	t.reportEvent(progress.EventInputProbed, data)
+}
+
+// inputDuration returns the duration in seconds of the input from the cached
+// ffprobe run, against which the MP4 progress is measured, or 0 if unknown.
+func (t *Transcoder) inputDuration(ctx context.Context, inputPath string) float64 {
+	output, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return 0
+	}
+	return parseFloat(output.Format.Duration)
+}
+
+// parseFloat parses an ffprobe decimal value, returning 0 if it is missing or invalid.
+func parseFloat(value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// parseInt parses an ffprobe integer value, returning 0 if it is missing or invalid.
+func parseInt(value string) int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
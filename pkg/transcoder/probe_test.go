@@ -0,0 +1,104 @@
+package transcoder
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const fullProbeJSON = `{
+	"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "h264", "profile": "High", "width": 1280, "height": 720,
+		 "pix_fmt": "yuv420p", "r_frame_rate": "30000/1001", "bit_rate": "2500000", "duration": "12.5"},
+		{"index": 1, "codec_type": "audio", "codec_name": "aac", "profile": "LC", "sample_rate": "48000",
+		 "channels": 2, "channel_layout": "stereo", "bit_rate": "128000", "tags": {"language": "eng"}},
+		{"index": 2, "codec_type": "subtitle", "codec_name": "mov_text", "tags": {"language": "por"}}
+	],
+	"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.500000", "size": "4100000", "bit_rate": "2624000"}
+}`
+
+func TestProbe(t *testing.T) {
+	opts := Options{InputPath: "input.mp4", OutputPath: "out"}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunnerWith(fullProbeJSON)))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	info, err := trans.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe() unexpected error: %v", err)
+	}
+
+	if info.Format != "mov,mp4,m4a,3gp,3g2,mj2" || info.Duration != 12.5 || info.Size != 4100000 || info.BitRate != 2624000 {
+		t.Errorf("Unexpected container info: %+v", info)
+	}
+	if info.Video == nil || info.Video.Width != 1280 || info.Video.Height != 720 || info.Video.Duration != 12.5 {
+		t.Fatalf("Unexpected video info: %+v", info.Video)
+	}
+	if info.VideoCodec != "h264" || info.AudioCodec != "aac" {
+		t.Errorf("Codecs = %q/%q, want h264/aac", info.VideoCodec, info.AudioCodec)
+	}
+	if len(info.Streams) != 3 {
+		t.Fatalf("Probe() returned %d streams, want 3", len(info.Streams))
+	}
+	audio := info.Streams[1]
+	if audio.SampleRate != 48000 || audio.Channels != 2 || audio.ChannelLayout != "stereo" || audio.Language != "eng" {
+		t.Errorf("Unexpected audio stream: %+v", audio)
+	}
+	if info.Streams[2].Type != "subtitle" || info.Streams[2].Language != "por" {
+		t.Errorf("Unexpected subtitle stream: %+v", info.Streams[2])
+	}
+
+	if len(info.SuggestedLadder) == 0 {
+		t.Fatal("Probe() suggested an empty ladder for a video input")
+	}
+	for _, r := range info.SuggestedLadder {
+		if r.Height > 720 {
+			t.Errorf("Suggested ladder upscales to %dx%d", r.Width, r.Height)
+		}
+	}
+}
+
+func TestProbeAudioOnly(t *testing.T) {
+	probe := `{"streams": [{"index": 0, "codec_type": "audio", "codec_name": "mp3"}], "format": {"duration": "30.0"}}`
+	opts := Options{InputPath: "input.mp3", OutputPath: "out"}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunnerWith(probe)))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	info, err := trans.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe() unexpected error: %v", err)
+	}
+	if info.Video != nil || len(info.SuggestedLadder) != 0 {
+		t.Errorf("Audio-only input got video %+v and ladder %+v", info.Video, info.SuggestedLadder)
+	}
+
+	// Empty lists are encoded as [] rather than null for scripts.
+	content, err := info.JSON()
+	if err != nil {
+		t.Fatalf("JSON() unexpected error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &decoded); err != nil {
+		t.Fatalf("JSON() returned invalid JSON: %v", err)
+	}
+	if ladder, ok := decoded["suggested_ladder"].([]interface{}); !ok || len(ladder) != 0 {
+		t.Errorf("suggested_ladder = %v, want []", decoded["suggested_ladder"])
+	}
+}
+
+func TestProbeInvalidOutput(t *testing.T) {
+	opts := Options{InputPath: "input.mp4", OutputPath: "out"}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(newProbeRunnerWith("not json")))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Probe(context.Background()); err == nil {
+		t.Fatal("Probe() expected an error for unparsable ffprobe output")
+	}
+}
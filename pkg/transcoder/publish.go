@@ -0,0 +1,267 @@
+package transcoder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+	"github.com/heyjunin/HLSpresso/pkg/upload"
+)
+
+// DefaultPublishInterval is the interval between two publications of the
+// partial output when Options.PublishPartial is set and
+// Options.PublishInterval is zero.
+const DefaultPublishInterval = 10 * time.Second
+
+// validatePublishPartial sets the default publish interval and checks that
+// partial publishing is compatible with the other options.
+func validatePublishPartial(options *Options, p *optionProblems) {
+	if !options.PublishPartial {
+		return
+	}
+	if options.OutputType != HLSOutput {
+		p.add("PublishPartial", errors.New(errors.ValidationError, "Invalid partial publishing",
+			"Partial publishing is only supported for HLS output", 4))
+	}
+	if len(options.EncryptionKey) > 0 {
+		p.add("PublishPartial", errors.New(errors.ValidationError, "Invalid partial publishing",
+			"Segments cannot be published before they are encrypted at rest", 4))
+	}
+	if options.PublishInterval == 0 {
+		options.PublishInterval = DefaultPublishInterval
+	}
+	if options.PublishInterval < 0 {
+		p.add("PublishInterval", errors.New(errors.ValidationError, "Invalid publish interval",
+			"The publish interval must not be negative, got "+options.PublishInterval.String(), 4))
+	}
+}
+
+// publisher uploads the segments and playlists of an HLS output while it is
+// being encoded. It remembers the size of the files it uploaded, so that the
+// final upload skips the segments already published.
+type publisher struct {
+	uploader upload.Uploader
+	dir      string
+
+	mu        sync.Mutex
+	published map[string]int64
+}
+
+// startPublishing publishes the output in dir every Options.PublishInterval
+// until the returned function is called. Failed publications are logged and
+// retried at the next interval: the final upload reports the errors.
+func (t *Transcoder) startPublishing(ctx context.Context, dir string) func() {
+	t.publisher = &publisher{uploader: t.uploader, dir: dir, published: make(map[string]int64)}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(t.options.PublishInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			files, err := t.publisher.publish(ctx)
+			if err != nil && ctx.Err() == nil {
+				t.logger.Warn("Failed to publish the partial output", "transcoder", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+			if files > 0 {
+				t.logger.Debug("Partial output published", "transcoder", map[string]interface{}{
+					"files": files,
+				})
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// publish uploads the segments listed in the media playlists that were not
+// uploaded yet, then the media playlists, as they were when their segments
+// were listed, and the master playlist once all its variants are published.
+// Returns the number of uploaded files.
+func (p *publisher) publish(ctx context.Context) (int, error) {
+	playlists, err := hls.MediaPlaylists(p.dir)
+	if err != nil {
+		return 0, err
+	}
+	playlists = append(playlists, filepath.Join(p.dir, masterPlaylistName))
+
+	files := 0
+playlists:
+	for _, playlist := range playlists {
+		content, err := os.ReadFile(playlist)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return files, err
+		}
+		remotePath, err := p.remotePath(playlist)
+		if err != nil {
+			return files, err
+		}
+		for _, uri := range playlistURIs(content) {
+			segment := path.Join(path.Dir(remotePath), uri)
+			if isPlaylist(uri) {
+				// Wait for the variant playlists before publishing the master playlist
+				if !p.uploaded(segment, -1) {
+					continue playlists
+				}
+				continue
+			}
+			uploaded, err := p.upload(ctx, filepath.Join(p.dir, filepath.FromSlash(segment)), segment)
+			if err != nil {
+				return files, err
+			}
+			if uploaded {
+				files++
+			}
+		}
+		if p.uploaded(remotePath, int64(len(content))) {
+			continue
+		}
+		if err := p.uploadSnapshot(ctx, content, remotePath); err != nil {
+			return files, err
+		}
+		files++
+	}
+	return files, nil
+}
+
+// upload uploads the file at localPath unless it was already uploaded with
+// its current size. Byte range segments grow as the encode goes on and are
+// uploaded again when they do.
+func (p *publisher) upload(ctx context.Context, localPath, remotePath string) (bool, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false, err
+	}
+	if p.uploaded(remotePath, info.Size()) {
+		return false, nil
+	}
+	if err := p.uploader.Upload(ctx, localPath, remotePath); err != nil {
+		return false, err
+	}
+	p.mu.Lock()
+	p.published[remotePath] = info.Size()
+	p.mu.Unlock()
+	return true, nil
+}
+
+// uploadSnapshot uploads content, a playlist read before its segments were
+// uploaded, rather than the file itself, which ffmpeg may have updated since
+// to list segments that are not published yet. EVENT playlists only grow, so
+// their size tells whether they changed since the last upload.
+func (p *publisher) uploadSnapshot(ctx context.Context, content []byte, remotePath string) error {
+	file, err := os.CreateTemp("", "hlspresso-playlist-*.m3u8")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.uploader.Upload(ctx, file.Name(), remotePath); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.published[remotePath] = int64(len(content))
+	p.mu.Unlock()
+	return nil
+}
+
+// uploaded reports whether the file at remotePath was published with size,
+// or with any size if size is negative.
+func (p *publisher) uploaded(remotePath string, size int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	published, ok := p.published[remotePath]
+	return ok && (size < 0 || published == size)
+}
+
+// remotePath returns the slash separated path of localPath relative to the
+// output directory.
+func (p *publisher) remotePath(localPath string) (string, error) {
+	rel, err := filepath.Rel(p.dir, localPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// skipPublished wraps the uploader of the final upload to skip the segments
+// published unchanged during the encode. Playlists are always uploaded, since
+// they were published as EVENT playlists.
+func (p *publisher) skipPublished(uploader upload.Uploader) upload.Uploader {
+	return skippingUploader{Uploader: uploader, publisher: p}
+}
+
+type skippingUploader struct {
+	upload.Uploader
+	publisher *publisher
+}
+
+// Upload implements upload.Uploader.
+func (u skippingUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	if !isPlaylist(remotePath) {
+		if info, err := os.Stat(localPath); err == nil && u.publisher.uploaded(remotePath, info.Size()) {
+			return nil
+		}
+	}
+	return u.Uploader.Upload(ctx, localPath, remotePath)
+}
+
+// playlistURIs returns the local URIs of the segments, initialization
+// sections (EXT-X-MAP) and variant playlists listed in a playlist, without
+// duplicates.
+func playlistURIs(content []byte) []string {
+	var uris []string
+	seen := make(map[string]bool)
+	add := func(uri string) {
+		if uri != "" && !strings.Contains(uri, "://") && !seen[uri] {
+			seen[uri] = true
+			uris = append(uris, uri)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			if _, uri, ok := strings.Cut(line, `URI="`); ok {
+				uri, _, _ = strings.Cut(uri, `"`)
+				add(uri)
+			}
+		case !strings.HasPrefix(line, "#"):
+			add(line)
+		}
+	}
+	return uris
+}
+
+// isPlaylist reports whether uri names an HLS playlist.
+func isPlaylist(uri string) bool {
+	return strings.EqualFold(path.Ext(uri), ".m3u8")
+}
@@ -0,0 +1,147 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// contentUploader is a fake upload.Uploader recording the remote paths and
+// contents it receives. It is safe for concurrent use.
+type contentUploader struct {
+	mu       sync.Mutex
+	uploaded []string
+	contents map[string]string
+}
+
+func (u *contentUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.uploaded = append(u.uploaded, remotePath)
+	if u.contents == nil {
+		u.contents = make(map[string]string)
+	}
+	u.contents[remotePath] = string(content)
+	return nil
+}
+
+func (u *contentUploader) snapshot() ([]string, map[string]string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	contents := make(map[string]string, len(u.contents))
+	for k, v := range u.contents {
+		contents[k] = v
+	}
+	return append([]string(nil), u.uploaded...), contents
+}
+
+func TestTranscodePublishPartial(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputDir := filepath.Join(dir, "hls")
+	playlist := filepath.Join(outputDir, "stream_0", "playlist.m3u8")
+
+	uploader := &contentUploader{}
+	var playlistType string
+	var published []string
+	var partial map[string]string
+	scripted := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name != "ffmpeg" || len(args) == 1 {
+			return scripted(name, args)
+		}
+		playlistType = args[slices.Index(args, "-hls_playlist_type")+1]
+		os.MkdirAll(filepath.Dir(playlist), 0755)
+		os.WriteFile(filepath.Join(outputDir, "stream_0", "data000.ts"), []byte("segment 0"), 0644)
+		os.WriteFile(filepath.Join(outputDir, "stream_0", "data001.ts"), []byte("segment 1 being written"), 0644)
+		os.WriteFile(playlist, []byte("#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:6.000000,\ndata000.ts\n"), 0644)
+		os.WriteFile(filepath.Join(outputDir, "master.m3u8"),
+			[]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n"), 0644)
+
+		// Wait for the first publication before finishing the encode
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if published, partial = uploader.snapshot(); slices.Contains(published, "master.m3u8") {
+				break
+			}
+		}
+		os.WriteFile(filepath.Join(outputDir, "stream_0", "data001.ts"), []byte("segment 1"), 0644)
+		os.WriteFile(playlist, []byte("#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:6.000000,\ndata000.ts\n#EXTINF:4.000000,\ndata001.ts\n#EXT-X-ENDLIST\n"), 0644)
+		return ffmpegtest.Result{}
+	}}
+
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputDir,
+		OutputType:         HLSOutput,
+		HLSResolutions:     []hls.VideoResolution{hls.DefaultResolutions[0]},
+		PublishPartial:     true,
+		PublishInterval:    10 * time.Millisecond,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(runner), WithUploader(uploader))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	if playlistType != "event" {
+		t.Errorf("-hls_playlist_type = %q, want event while publishing", playlistType)
+	}
+	if want := []string{"stream_0/data000.ts", "stream_0/playlist.m3u8", "master.m3u8"}; !slices.Equal(published, want) {
+		t.Errorf("Published during the encode %v, want %v", published, want)
+	}
+	if !strings.Contains(partial["stream_0/playlist.m3u8"], "#EXT-X-PLAYLIST-TYPE:EVENT") {
+		t.Errorf("Partial playlist =\n%s\nwant an EVENT playlist", partial["stream_0/playlist.m3u8"])
+	}
+
+	uploaded, contents := uploader.snapshot()
+	final := uploaded[len(published):]
+	if slices.Contains(final, "stream_0/data000.ts") || !slices.Contains(final, "stream_0/data001.ts") {
+		t.Errorf("Final upload %v should only send the segments not published yet", final)
+	}
+	if final[len(final)-1] != "master.m3u8" {
+		t.Errorf("Final upload %v should end with the master playlist", final)
+	}
+	if got := contents["stream_0/playlist.m3u8"]; !strings.Contains(got, "#EXT-X-PLAYLIST-TYPE:VOD") || !strings.Contains(got, "data001.ts") {
+		t.Errorf("Final playlist =\n%s\nwant a complete VOD playlist", got)
+	}
+	if got := contents["stream_0/data001.ts"]; got != "segment 1" {
+		t.Errorf("data001.ts = %q, want the complete segment", got)
+	}
+}
+
+func TestValidatePublishPartial(t *testing.T) {
+	opts := Options{InputPath: "input.mp4", OutputPath: "out", PublishPartial: true}
+	if err := ValidateOptions(opts); err != nil {
+		t.Errorf("ValidateOptions() unexpected error: %v", err)
+	}
+	for name, modify := range map[string]func(*Options){
+		"MP4 output":        func(o *Options) { o.OutputType = MP4Output },
+		"Encryption":        func(o *Options) { o.EncryptionKey = make([]byte, 32) },
+		"Negative interval": func(o *Options) { o.PublishInterval = -time.Second },
+	} {
+		invalid := opts
+		modify(&invalid)
+		if err := ValidateOptions(invalid); err == nil {
+			t.Errorf("%s: ValidateOptions() expected an error", name)
+		}
+	}
+}
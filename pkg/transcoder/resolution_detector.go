@@ -4,18 +4,46 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
 )
 
 // VideoInfo holds detected resolution and duration information for a video file.
 type VideoInfo struct {
 	// Width of the video in pixels.
-	Width int
+	Width int `json:"width"`
 	// Height of the video in pixels.
-	Height int
+	Height int `json:"height"`
 	// Duration of the video in seconds.
-	Duration float64
+	Duration float64 `json:"duration"`
+	// FrameRate of the video in frames per second. Zero if it could not be detected.
+	FrameRate float64 `json:"frame_rate"`
+	// Captions reports whether the video carries embedded CEA-608/708 closed
+	// captions.
+	Captions bool `json:"closed_captions,omitempty"`
+	// FieldOrder is the field order of the video reported by ffprobe:
+	// "progressive", "tt", "bb", "tb", "bt" or "unknown" (see Interlaced).
+	FieldOrder string `json:"field_order,omitempty"`
+	// PixelFormat is the pixel format of the video, e.g. "yuv422p10le".
+	PixelFormat string `json:"pix_fmt,omitempty"`
+	// ColorSpace, ColorTransfer, ColorPrimaries and ColorRange are the color
+	// tags of the video reported by ffprobe, e.g. "bt709" or "tv", or
+	// "unknown".
+	ColorSpace     string `json:"color_space,omitempty"`
+	ColorTransfer  string `json:"color_transfer,omitempty"`
+	ColorPrimaries string `json:"color_primaries,omitempty"`
+	ColorRange     string `json:"color_range,omitempty"`
+}
+
+// Interlaced reports whether the field order of the video is interlaced.
+func (v *VideoInfo) Interlaced() bool {
+	switch v.FieldOrder {
+	case "tt", "bb", "tb", "bt":
+		return true
+	}
+	return false
 }
 
 // FFprobeOutput represents the structure of the JSON output from the ffprobe command
@@ -23,12 +51,35 @@ type VideoInfo struct {
 // It's used internally for parsing the ffprobe results.
 type FFprobeOutput struct {
 	Streams []struct {
-		CodecType string `json:"codec_type"`
-		Width     int    `json:"width,omitempty"`
-		Height    int    `json:"height,omitempty"`
+		Index          int    `json:"index"`
+		CodecType      string `json:"codec_type"`
+		CodecName      string `json:"codec_name,omitempty"`
+		Profile        string `json:"profile,omitempty"`
+		Width          int    `json:"width,omitempty"`
+		Height         int    `json:"height,omitempty"`
+		PixelFormat    string `json:"pix_fmt,omitempty"`
+		FrameRate      string `json:"r_frame_rate,omitempty"`
+		Captions       int    `json:"closed_captions,omitempty"`
+		FieldOrder     string `json:"field_order,omitempty"`
+		ColorSpace     string `json:"color_space,omitempty"`
+		ColorTransfer  string `json:"color_transfer,omitempty"`
+		ColorPrimaries string `json:"color_primaries,omitempty"`
+		ColorRange     string `json:"color_range,omitempty"`
+		SampleRate     string `json:"sample_rate,omitempty"`
+		Channels       int    `json:"channels,omitempty"`
+		ChannelLayout  string `json:"channel_layout,omitempty"`
+		BitRate        string `json:"bit_rate,omitempty"`
+		Duration       string `json:"duration,omitempty"`
+		Tags           struct {
+			Language string `json:"language,omitempty"`
+		} `json:"tags"`
 	} `json:"streams"`
 	Format struct {
-		Duration string `json:"duration"`
+		FormatName string `json:"format_name"`
+		StartTime  string `json:"start_time,omitempty"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
 	} `json:"format"`
 }
 
@@ -39,29 +90,21 @@ type FFprobeOutput struct {
 // Returns a VideoInfo struct containing the detected information or an error if ffprobe fails,
 // parsing fails, or video stream information cannot be found.
 func DetectVideoResolution(ctx context.Context, inputPath string) (*VideoInfo, error) {
-	// Preparar comando FFprobe para obter informações do vídeo em formato JSON
-	cmd := exec.CommandContext(
-		ctx,
-		"ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		inputPath,
-	)
+	return detectVideoResolution(ctx, ffmpeg.ExecRunner{}, inputPath)
+}
 
-	// Executar comando e obter saída
-	output, err := cmd.Output()
+// detectVideoResolution implements DetectVideoResolution, launching ffprobe through runner.
+func detectVideoResolution(ctx context.Context, runner ffmpeg.Runner, inputPath string) (*VideoInfo, error) {
+	probeOutput, err := runFFprobe(ctx, runner, inputPath)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao executar FFprobe: %w", err)
-	}
-
-	// Parsear a saída JSON
-	var probeOutput FFprobeOutput
-	if err := json.Unmarshal(output, &probeOutput); err != nil {
-		return nil, fmt.Errorf("erro ao parsear saída do FFprobe: %w", err)
+		return nil, err
 	}
+	return videoInfoFromProbe(probeOutput)
+}
 
+// videoInfoFromProbe extracts the resolution, frame rate and duration of the first
+// video stream described by probeOutput.
+func videoInfoFromProbe(probeOutput *FFprobeOutput) (*VideoInfo, error) {
 	// Encontrar o stream de vídeo
 	var videoInfo VideoInfo
 	foundVideo := false
@@ -70,6 +113,14 @@ func DetectVideoResolution(ctx context.Context, inputPath string) (*VideoInfo, e
 		if stream.CodecType == "video" {
 			videoInfo.Width = stream.Width
 			videoInfo.Height = stream.Height
+			videoInfo.FrameRate = parseFrameRate(stream.FrameRate)
+			videoInfo.Captions = stream.Captions == 1
+			videoInfo.FieldOrder = stream.FieldOrder
+			videoInfo.PixelFormat = stream.PixelFormat
+			videoInfo.ColorSpace = stream.ColorSpace
+			videoInfo.ColorTransfer = stream.ColorTransfer
+			videoInfo.ColorPrimaries = stream.ColorPrimaries
+			videoInfo.ColorRange = stream.ColorRange
 			foundVideo = true
 			break
 		}
@@ -94,3 +145,46 @@ func DetectVideoResolution(ctx context.Context, inputPath string) (*VideoInfo, e
 
 	return &videoInfo, nil
 }
+
+// runFFprobe runs ffprobe on inputPath and parses its JSON description of the
+// container and streams.
+func runFFprobe(ctx context.Context, runner ffmpeg.Runner, inputPath string) (*FFprobeOutput, error) {
+	// Executar FFprobe para obter informações do vídeo em formato JSON
+	output, err := runner.Output(
+		ctx,
+		"ffprobe",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		inputPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar FFprobe: %w", err)
+	}
+
+	// Parsear a saída JSON
+	var probeOutput FFprobeOutput
+	if err := json.Unmarshal(output, &probeOutput); err != nil {
+		return nil, fmt.Errorf("erro ao parsear saída do FFprobe: %w", err)
+	}
+	return &probeOutput, nil
+}
+
+// parseFrameRate converts an ffprobe frame rate such as "30000/1001" or "25" to
+// frames per second. Returns 0 if the value cannot be parsed.
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
@@ -0,0 +1,26 @@
+package transcoder
+
+import (
+	"github.com/heyjunin/HLSpresso/pkg/downloader"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// validateShareLinks checks that the Google Drive and Dropbox share links
+// downloaded through their API are not streamed, since ffmpeg cannot
+// authenticate to the APIs.
+func validateShareLinks(options *Options, p *optionProblems) {
+	if !options.StreamFromURL {
+		return
+	}
+	for _, source := range append([]string{options.InputPath}, options.InputMirrors...) {
+		if downloader.UsesConnector(downloader.Options{
+			URL:              source,
+			GoogleDriveToken: options.GoogleDriveToken,
+			DropboxToken:     options.DropboxToken,
+		}) {
+			p.add("StreamFromURL", errors.New(errors.ValidationError, "Share links cannot be streamed",
+				"Download the input (StreamFromURL false) to read Google Drive and Dropbox share links", 4))
+			return
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package transcoder
+
+import (
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/downloader"
+)
+
+func TestValidateShareLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"Downloaded", Options{InputPath: "https://drive.google.com/file/d/1AbC/view", GoogleDriveToken: "token"}, false},
+		{"Streamed", Options{InputPath: "https://drive.google.com/file/d/1AbC/view", GoogleDriveToken: "token", StreamFromURL: true}, true},
+		{"Streamed mirror", Options{InputPath: "https://cdn.example.com/video.mp4", InputMirrors: []string{"https://www.dropbox.com/s/abc/video.mp4"}, DropboxToken: "token", StreamFromURL: true}, true},
+		// Sem token, o link é lido como qualquer URL
+		{"Streamed without token", Options{InputPath: "https://www.dropbox.com/s/abc/video.mp4?dl=1", StreamFromURL: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.OutputPath = t.TempDir()
+			tt.opts.IsRemoteInput = true
+			_, err := NewWithDeps(tt.opts, &mockProgressReporter{}, newDiscardLogger(), downloader.New(downloader.Options{}))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWithDeps() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
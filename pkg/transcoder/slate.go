@@ -0,0 +1,295 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/diskspace"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// DefaultSlateDuration is how long a still image slate is shown when its
+// Duration is zero.
+const DefaultSlateDuration = 5 * time.Second
+
+// slateImageExtensions are the file extensions of still image slates.
+var slateImageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".bmp": true, ".webp": true, ".tif": true, ".tiff": true,
+}
+
+// Slate is a clip or still image inserted before (Options.Intro) or after
+// (Options.Outro) the video, e.g. a branding bumper. It is scaled, padded and
+// resampled to the format of the input, so it may have any resolution, frame
+// rate or audio layout.
+type Slate struct {
+	// Path is the clip or image file. Images (.png, .jpg, .jpeg, .bmp, .webp,
+	// .tif, .tiff) are shown for Duration; clips are played whole, with
+	// silence if they have no audio.
+	Path string `json:"path"`
+	// Duration is how long an image is shown, DefaultSlateDuration by
+	// default. A nonzero Duration cuts a clip after that long.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// IsZero reports whether no slate is configured.
+func (s Slate) IsZero() bool {
+	return s.Path == ""
+}
+
+// IsImage reports whether the slate is a still image, from its extension.
+func (s Slate) IsImage() bool {
+	return slateImageExtensions[strings.ToLower(filepath.Ext(s.Path))]
+}
+
+// validateSlates checks the intro and outro slates, setting the default
+// duration of the images.
+func validateSlates(options *Options, p *optionProblems) {
+	for _, slate := range []struct {
+		field string
+		slate *Slate
+	}{
+		{"Intro", &options.Intro},
+		{"Outro", &options.Outro},
+	} {
+		if slate.slate.IsZero() {
+			continue
+		}
+		if slate.slate.Duration < 0 {
+			p.add(slate.field, errors.New(errors.ValidationError, "Invalid slate duration",
+				fmt.Sprintf("The duration must not be negative, got %s", slate.slate.Duration), 4))
+		}
+		if slate.slate.Duration == 0 && slate.slate.IsImage() {
+			slate.slate.Duration = DefaultSlateDuration
+		}
+		if options.PassthroughMetadata {
+			p.add(slate.field, errors.New(errors.ValidationError, "Invalid slate",
+				"Slates shift the timeline of the input, so its timed metadata cannot be passed through", 4))
+		}
+		if options.ExtractCaptions {
+			p.add(slate.field, errors.New(errors.ValidationError, "Invalid slate",
+				"The closed captions of the input are lost when slates are inserted", 4))
+		}
+	}
+}
+
+// hasSlates reports whether an intro or outro slate is configured.
+func (t *Transcoder) hasSlates() bool {
+	return !t.options.Intro.IsZero() || !t.options.Outro.IsZero()
+}
+
+// slateSegment is one of the parts concatenated by insertSlates.
+type slateSegment struct {
+	path  string
+	image bool
+	// duration limits the part, if not zero.
+	duration time.Duration
+	// audio reports whether the part has an audio stream; silence of
+	// silenceDuration seconds is generated for the parts without one.
+	audio           bool
+	silenceDuration float64
+}
+
+// slateFormat is the format of the input the slates are normalized to.
+type slateFormat struct {
+	width, height int
+	frameRate     float64
+	// audio is set when the input has audio, which the slates then get too.
+	audio         bool
+	sampleRate    int
+	channelLayout string
+}
+
+// insertSlates encodes the intro slate, the input at inputPath and the outro
+// slate into a single file in DownloadDir, normalized to the resolution, frame
+// rate and audio format of the input, and returns its path, which
+// removeSlatedInput removes. The file has the video and the audio of the
+// input only: inputs with several audio tracks are rejected, like captions
+// and timed metadata by validateSlates.
+func (t *Transcoder) insertSlates(ctx context.Context, inputPath string) (string, error) {
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		return "", err
+	}
+	probe, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return "", err
+	}
+	format := slateFormat{width: info.Width, height: info.Height, frameRate: info.FrameRate, sampleRate: 48000, channelLayout: "stereo"}
+	if format.frameRate <= 0 {
+		format.frameRate = 30
+	}
+	audioTracks := 0
+	for _, s := range probe.Streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		if audioTracks++; audioTracks > 1 {
+			continue
+		}
+		format.audio = true
+		if rate, err := strconv.Atoi(s.SampleRate); err == nil && rate > 0 {
+			format.sampleRate = rate
+		}
+		if s.ChannelLayout != "" {
+			format.channelLayout = s.ChannelLayout
+		}
+	}
+	if audioTracks > 1 {
+		// O input com slates só tem a primeira faixa de áudio
+		return "", errors.New(errors.InvalidFileFormatError, "Slates cannot be inserted into an input with several audio tracks",
+			fmt.Sprintf("The input has %d audio tracks, only the first one would be kept", audioTracks), errors.ErrUnsupportedFileFormat)
+	}
+
+	segments := []slateSegment{{path: inputPath, audio: format.audio}}
+	if !t.options.Intro.IsZero() {
+		intro, err := t.slateSegment(ctx, t.options.Intro)
+		if err != nil {
+			return "", err
+		}
+		segments = append([]slateSegment{intro}, segments...)
+	}
+	if !t.options.Outro.IsZero() {
+		outro, err := t.slateSegment(ctx, t.options.Outro)
+		if err != nil {
+			return "", err
+		}
+		segments = append(segments, outro)
+	}
+
+	if err := os.MkdirAll(t.options.DownloadDir, 0755); err != nil {
+		return "", errors.Wrap(err, errors.SystemError, "Failed to create download directory", 6)
+	}
+	if err := t.checkDiskSpace(t.options.DownloadDir, diskspace.DefaultMinFreeSpace); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(sanitizeFileName(filepath.Base(inputPath)), filepath.Ext(inputPath))
+	file, err := os.CreateTemp(t.options.DownloadDir, "slated-*-"+name+".mp4")
+	if err != nil {
+		return "", errors.Wrap(err, errors.SystemError, "Failed to create the input with slates", 13)
+	}
+	file.Close()
+	t.slatedInput = file.Name()
+
+	slateCtx, cancel := withStageTimeout(ctx, t.options.EncodeTimeout)
+	defer cancel()
+	if _, err := t.runner.Output(slateCtx, t.options.FFmpegBinary, slateArgs(segments, format, file.Name())...); err != nil {
+		if stageTimedOut(slateCtx) {
+			return "", stageTimeoutError(err, "slate insertion", t.options.EncodeTimeout, errors.ErrEncodeTimeout, errors.TranscodingError)
+		}
+		return "", errors.Wrap(err, errors.TranscodingError, "Failed to insert the slates", 13)
+	}
+
+	t.logger.Info("Slates inserted", "transcoder", map[string]interface{}{
+		"intro": t.options.Intro.Path,
+		"outro": t.options.Outro.Path,
+		"path":  file.Name(),
+	})
+	return file.Name(), nil
+}
+
+// slateSegment probes the clip of slate for audio. Images have none.
+func (t *Transcoder) slateSegment(ctx context.Context, slate Slate) (slateSegment, error) {
+	if _, err := os.Stat(slate.Path); err != nil {
+		return slateSegment{}, errors.New(errors.FileNotFoundError, errors.GetErrorMessage(errors.ErrFileNotFound),
+			slate.Path, errors.ErrFileNotFound)
+	}
+	segment := slateSegment{path: slate.Path, image: slate.IsImage(), duration: slate.Duration}
+	segment.silenceDuration = slate.Duration.Seconds()
+	if segment.image {
+		return segment, nil
+	}
+
+	output, err := runFFprobe(ctx, t.runner, slate.Path)
+	if err != nil {
+		return slateSegment{}, errors.Wrap(err, errors.InvalidFileFormatError, "Failed to read the slate "+slate.Path,
+			errors.ErrInvalidFileFormat)
+	}
+	for _, s := range output.Streams {
+		if s.CodecType == "audio" {
+			segment.audio = true
+		}
+	}
+	if duration := parseFloat(output.Format.Duration); segment.silenceDuration == 0 || duration < segment.silenceDuration {
+		segment.silenceDuration = duration
+	}
+	return segment, nil
+}
+
+// slateArgs returns the ffmpeg arguments concatenating segments into output,
+// normalized to format.
+func slateArgs(segments []slateSegment, format slateFormat, output string) []string {
+	args := []string{"-v", "error"}
+	frameRate := strconv.FormatFloat(format.frameRate, 'f', -1, 64)
+	for _, s := range segments {
+		if s.image {
+			args = append(args, "-loop", "1", "-framerate", frameRate)
+		}
+		if s.duration > 0 {
+			args = append(args, "-t", strconv.FormatFloat(s.duration.Seconds(), 'f', -1, 64))
+		}
+		args = append(args, "-i", s.path)
+	}
+
+	// Gerar silêncio para as partes sem áudio
+	audioInputs := make([]int, len(segments))
+	next := len(segments)
+	for i, s := range segments {
+		audioInputs[i] = i
+		if format.audio && !s.audio {
+			args = append(args, "-f", "lavfi",
+				"-t", strconv.FormatFloat(s.silenceDuration, 'f', -1, 64),
+				"-i", fmt.Sprintf("anullsrc=channel_layout=%s:sample_rate=%d", format.channelLayout, format.sampleRate))
+			audioInputs[i] = next
+			next++
+		}
+	}
+
+	var filters []string
+	var concat string
+	for i := range segments {
+		filters = append(filters, fmt.Sprintf(
+			"[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=yuv420p[v%d]",
+			i, format.width, format.height, format.width, format.height, frameRate, i))
+		concat += fmt.Sprintf("[v%d]", i)
+		if format.audio {
+			filters = append(filters, fmt.Sprintf("[%d:a:0]aresample=%d,aformat=sample_fmts=fltp:channel_layouts=%s[a%d]",
+				audioInputs[i], format.sampleRate, format.channelLayout, i))
+			concat += fmt.Sprintf("[a%d]", i)
+		}
+	}
+	audioStreams := 0
+	if format.audio {
+		audioStreams = 1
+	}
+	filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=%d[v]", concat, len(segments), audioStreams))
+	if format.audio {
+		filters[len(filters)-1] += "[a]"
+	}
+
+	args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", "[v]")
+	if format.audio {
+		args = append(args, "-map", "[a]", "-c:a", "aac", "-b:a", "320k")
+	}
+	return append(args,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "16",
+		"-y", output)
+}
+
+// removeSlatedInput removes the file written by insertSlates, if any.
+func (t *Transcoder) removeSlatedInput() {
+	if t.slatedInput == "" {
+		return
+	}
+	if err := os.Remove(t.slatedInput); err != nil && !os.IsNotExist(err) {
+		t.logger.Warn("Failed to remove the input with slates", "transcoder", map[string]interface{}{
+			"path":  t.slatedInput,
+			"error": err.Error(),
+		})
+	}
+	t.slatedInput = ""
+}
@@ -0,0 +1,143 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestSlateArgs(t *testing.T) {
+	segments := []slateSegment{
+		{path: "intro.png", image: true, duration: 3 * time.Second, silenceDuration: 3},
+		{path: "input.mp4", audio: true},
+		{path: "outro.mov", silenceDuration: 2.5},
+	}
+	format := slateFormat{width: 1280, height: 720, frameRate: 25, audio: true, sampleRate: 44100, channelLayout: "5.1"}
+	args := strings.Join(slateArgs(segments, format, "out.mp4"), " ")
+
+	for _, want := range []string{
+		"-loop 1 -framerate 25 -t 3 -i intro.png -i input.mp4 -i outro.mov",
+		"-f lavfi -t 3 -i anullsrc=channel_layout=5.1:sample_rate=44100 -f lavfi -t 2.5 -i anullsrc=channel_layout=5.1:sample_rate=44100",
+		"[0:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=25,format=yuv420p[v0]",
+		"[3:a:0]aresample=44100,aformat=sample_fmts=fltp:channel_layouts=5.1[a0]",
+		"[1:a:0]aresample=44100",
+		"[4:a:0]aresample=44100",
+		"[v0][a0][v1][a1][v2][a2]concat=n=3:v=1:a=1[v][a] -map [v] -map [a]",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("slateArgs() = %s\nmissing %q", args, want)
+		}
+	}
+
+	// Inputs without audio produce a video-only output
+	format.audio = false
+	args = strings.Join(slateArgs(segments, format, "out.mp4"), " ")
+	if strings.Contains(args, "anullsrc") || !strings.Contains(args, "concat=n=3:v=1:a=0[v] -map [v] -c:v") {
+		t.Errorf("slateArgs() without audio = %s", args)
+	}
+}
+
+func TestTranscodeSlates(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	introPath := filepath.Join(dir, "intro.png")
+	for _, path := range []string{inputPath, introPath} {
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatalf("Failed to create dummy file: %v", err)
+		}
+	}
+
+	var slateOutput, encodeInput string
+	scripted := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffmpeg" && slices.Contains(args, "-filter_complex") {
+			slateOutput = args[len(args)-1]
+		} else if name == "ffmpeg" && len(args) > 1 {
+			encodeInput = args[slices.Index(args, "-i")+1]
+		}
+		return scripted(name, args)
+	}}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "video.mp4"),
+		OutputType:         MP4Output,
+		DownloadDir:        filepath.Join(dir, "downloads"),
+		Intro:              Slate{Path: introPath},
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if trans.options.Intro.Duration != DefaultSlateDuration {
+		t.Errorf("Intro duration = %s, want %s for an image", trans.options.Intro.Duration, DefaultSlateDuration)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	if slateOutput == "" || encodeInput != slateOutput {
+		t.Errorf("Encoded %q, want the input with slates %q", encodeInput, slateOutput)
+	}
+	if _, err := os.Stat(slateOutput); !os.IsNotExist(err) {
+		t.Error("The input with slates should be removed once the job ends")
+	}
+
+	opts.OutputPath = filepath.Join(dir, "outro.mp4")
+	opts.Outro = Slate{Path: filepath.Join(dir, "missing.mov")}
+	trans, err = NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	_, err = trans.Transcode(context.Background())
+	if sErr, ok := err.(*errors.StructuredError); !ok || sErr.Code != errors.ErrFileNotFound {
+		t.Errorf("Transcode() error = %v, want a file not found error for the missing slate", err)
+	}
+}
+
+func TestTranscodeSlatesRejectsAudioTracks(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	introPath := filepath.Join(dir, "intro.png")
+	for _, path := range []string{inputPath, introPath} {
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatalf("Failed to create dummy file: %v", err)
+		}
+	}
+
+	probe := `{"streams": [
+		{"codec_type": "video", "width": 1920, "height": 1080, "r_frame_rate": "25/1"},
+		{"codec_type": "audio"},
+		{"codec_type": "audio"}
+	], "format": {"duration": "60.000000"}}`
+	scripted := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffprobe" {
+			return ffmpegtest.Result{Stdout: probe}
+		}
+		return scripted(name, args)
+	}}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "video.mp4"),
+		OutputType:         MP4Output,
+		DownloadDir:        filepath.Join(dir, "downloads"),
+		Intro:              Slate{Path: introPath},
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	_, err = trans.Transcode(context.Background())
+	if sErr, ok := err.(*errors.StructuredError); !ok || sErr.Code != errors.ErrUnsupportedFileFormat {
+		t.Errorf("Transcode() error = %v, want an error for the second audio track", err)
+	}
+}
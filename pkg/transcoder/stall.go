@@ -0,0 +1,143 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// errStalled is the cause of the context of an encode stopped because ffmpeg
+// wrote no output for Options.StallTimeout.
+var errStalled = stderrors.New("ffmpeg stalled")
+
+// activity records when the ffmpeg processes of a Transcoder last showed
+// signs of life.
+type activity struct {
+	// last is the time of the last output, in Unix nanoseconds.
+	last atomic.Int64
+	// commands counts the commands run with Output, whose output is only
+	// available once they exit, and which are considered active meanwhile.
+	commands atomic.Int32
+}
+
+// touch records activity now.
+func (a *activity) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+// idle returns how long the processes have been silent at now.
+func (a *activity) idle(now time.Time) time.Duration {
+	if a.commands.Load() > 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, a.last.Load()))
+}
+
+// activityRunner is the Runner of a Transcoder with Options.StallTimeout set.
+// It records the output of the processes it starts in activity.
+type activityRunner struct {
+	ffmpeg.Runner
+	activity *activity
+}
+
+// Output implements ffmpeg.Runner.
+func (r activityRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	r.activity.commands.Add(1)
+	defer func() {
+		r.activity.touch()
+		r.activity.commands.Add(-1)
+	}()
+	return r.Runner.Output(ctx, name, args...)
+}
+
+// Start implements ffmpeg.Runner.
+func (r activityRunner) Start(ctx context.Context, name string, args ...string) (ffmpeg.Process, error) {
+	proc, err := r.Runner.Start(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	r.activity.touch()
+	return activityProcess{Process: proc, activity: r.activity}, nil
+}
+
+// activityProcess records the reads of its standard error in activity.
+type activityProcess struct {
+	ffmpeg.Process
+	activity *activity
+}
+
+func (p activityProcess) Stderr() io.Reader {
+	return activityReader{Reader: p.Process.Stderr(), activity: p.activity}
+}
+
+type activityReader struct {
+	io.Reader
+	activity *activity
+}
+
+func (r activityReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.activity.touch()
+	}
+	return n, err
+}
+
+// watchStalls returns a context derived from ctx that is cancelled with
+// errStalled when the ffmpeg processes write no output for
+// Options.StallTimeout, and the function ending the watch.
+func (t *Transcoder) watchStalls(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	timeout := t.options.StallTimeout
+	if timeout <= 0 || t.activity == nil {
+		return ctx, func() { cancel(nil) }
+	}
+
+	t.activity.touch()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(timeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if t.activity.idle(now) > timeout {
+					cancel(errStalled)
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		cancel(nil)
+	}
+}
+
+// stalled reports whether ctx, returned by watchStalls, was cancelled because
+// ffmpeg stalled.
+func stalled(ctx context.Context) bool {
+	return context.Cause(ctx) == errStalled
+}
+
+// stallError returns the error of an encode that stalled on every attempt,
+// with the last lines ffmpeg printed, if known.
+func stallError(err error, timeout time.Duration, attempts int) *errors.StructuredError {
+	details := fmt.Sprintf("ffmpeg wrote no output for %s (%d attempts): %v", timeout, attempts, err)
+	sErr := errors.New(errors.StallError, errors.GetErrorMessage(errors.ErrFFmpegStalled), details, errors.ErrFFmpegStalled)
+	var cause *errors.StructuredError
+	if stderrors.As(err, &cause) {
+		sErr.FFmpegOutput = cause.FFmpegOutput
+	}
+	return sErr
+}
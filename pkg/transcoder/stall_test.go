@@ -0,0 +1,89 @@
+package transcoder
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+// hangingRunner simulates an ffmpeg hanging on its input for the first hangs
+// encodes, and runs the others with a scripted runner.
+type hangingRunner struct {
+	*ffmpegtest.Runner
+	hangs  int
+	starts int
+}
+
+func (r *hangingRunner) Start(ctx context.Context, name string, args ...string) (ffmpeg.Process, error) {
+	r.starts++
+	if r.starts > r.hangs {
+		return r.Runner.Start(ctx, name, args...)
+	}
+	stderr, w := io.Pipe()
+	go func() {
+		<-ctx.Done()
+		w.Close()
+	}()
+	return hungProcess{ctx: ctx, stderr: stderr}, nil
+}
+
+type hungProcess struct {
+	ctx    context.Context
+	stderr io.Reader
+}
+
+func (p hungProcess) Stderr() io.Reader { return p.stderr }
+func (p hungProcess) Wait() error       { return p.ctx.Err() }
+
+func TestTranscodeStallDetection(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		retries  int
+		wantCode int
+	}{
+		{"Restarted after a stall", 1, 0},
+		{"Failed after the retries", 0, errors.ErrFFmpegStalled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &hangingRunner{Runner: &ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}, hangs: 1}
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         filepath.Join(t.TempDir(), "output.mp4"),
+				OutputType:         MP4Output,
+				SkipDiskSpaceCheck: true,
+				StallTimeout:       20 * time.Millisecond,
+				StallRetries:       tt.retries,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if tt.wantCode == 0 {
+				if err != nil || runner.starts != 2 {
+					t.Errorf("Transcode() error = %v after %d encodes, want a restarted encode", err, runner.starts)
+				}
+				return
+			}
+			sErr, ok := err.(*errors.StructuredError)
+			if !ok || sErr.Type != errors.StallError || sErr.Code != tt.wantCode || errors.ExitCode(err) != errors.ExitStalled {
+				t.Errorf("Transcode() error = %v, want a stall error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// DefaultDownloadTimeout is the time allowed to download a remote input when
+// Options.DownloadTimeout is zero.
+const DefaultDownloadTimeout = 30 * time.Minute
+
+// DefaultProbeTimeout is the time allowed for each ffprobe run when
+// Options.ProbeTimeout is zero.
+const DefaultProbeTimeout = 2 * time.Minute
+
+// Stages of a job, reported by the errors.TimeoutError of a job exceeding
+// Options.MaxJobDuration.
+const (
+	// StagePrepare covers the checks and hooks run before the input is read.
+	StagePrepare = "prepare"
+	// StageDownload is the download of a remote input.
+	StageDownload = "download"
+	// StageProbe covers the analysis of the input and the encoding plan.
+	StageProbe = "probe"
+	// StageEncode is the ffmpeg encode.
+	StageEncode = "encode"
+	// StageFinalize covers the steps following the encode (playlists, trick
+	// play, post-processors...).
+	StageFinalize = "finalize"
+	// StageUpload is the upload of the output.
+	StageUpload = "upload"
+)
+
+// jobStages maps the lifecycle events to the stage of the job they start.
+var jobStages = map[string]string{
+	progress.EventDownloadStarted:   StageDownload,
+	progress.EventDownloadCompleted: StageProbe,
+	progress.EventInputValidated:    StageProbe,
+	progress.EventEncodeStarted:     StageEncode,
+	progress.EventEncodeCompleted:   StageFinalize,
+	progress.EventUploadStarted:     StageUpload,
+	progress.EventUploadCompleted:   StageFinalize,
+}
+
+// errJobTimeout is the cause of the context of a Transcode call that exceeded
+// Options.MaxJobDuration.
+var errJobTimeout = stderrors.New("maximum job duration exceeded")
+
+// withJobTimeout returns a context derived from ctx that is cancelled after
+// Options.MaxJobDuration, or ctx itself if there is no limit.
+func (t *Transcoder) withJobTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.options.MaxJobDuration <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, t.options.MaxJobDuration, errJobTimeout)
+}
+
+// jobTimedOut returns the error of a Transcode call that failed with err
+// because it exceeded Options.MaxJobDuration, naming the stage it was stopped
+// in. It returns nil if the call did not fail or did not time out.
+func (t *Transcoder) jobTimedOut(ctx context.Context, err error) error {
+	if err == nil || context.Cause(ctx) != errJobTimeout {
+		return nil
+	}
+	details := fmt.Sprintf("The job exceeded the %s maximum duration in the %s stage: %v", t.options.MaxJobDuration, t.stage, err)
+	sErr := errors.New(errors.TimeoutError, errors.GetErrorMessage(errors.ErrJobTimeout), details, errors.ErrJobTimeout)
+	sErr.Stage = t.stage
+	t.logger.Error("Maximum job duration exceeded", "transcoder", map[string]interface{}{
+		"stage":            t.stage,
+		"max_job_duration": t.options.MaxJobDuration.String(),
+	})
+	return sErr
+}
+
+// errStageTimeout is the cause of the contexts returned by withStageTimeout, so
+// that a stage timeout can be told apart from a deadline set by the caller.
+var errStageTimeout = stderrors.New("stage timeout exceeded")
+
+// withStageTimeout returns a context derived from ctx that is cancelled after
+// timeout, or that only inherits the cancellation of ctx if timeout is zero or
+// negative.
+func withStageTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, errStageTimeout)
+}
+
+// stageTimedOut reports whether ctx, returned by withStageTimeout, was cancelled
+// because its own timeout expired.
+func stageTimedOut(ctx context.Context) bool {
+	return context.Cause(ctx) == errStageTimeout
+}
+
+// stageTimeoutError returns the error of a stage that exceeded its timeout.
+func stageTimeoutError(err error, stage string, timeout time.Duration, code int, errorType errors.ErrorType) *errors.StructuredError {
+	details := fmt.Sprintf("%s exceeded the %s timeout", stage, timeout)
+	if err != nil {
+		details += ": " + err.Error()
+	}
+	return errors.New(errorType, errors.GetErrorMessage(code), details, code)
+}
+
+// isStageTimeout reports whether err is a stage timeout error, which callers
+// return as is rather than wrapping it in a more general error.
+func isStageTimeout(err error) bool {
+	var sErr *errors.StructuredError
+	if !stderrors.As(err, &sErr) {
+		return false
+	}
+	switch sErr.Code {
+	case errors.ErrDownloadTimeout, errors.ErrProbeTimeout, errors.ErrEncodeTimeout:
+		return true
+	}
+	return false
+}
+
+// wrapProbeError wraps an error of probeInput like errors.Wrap, but keeps probe
+// timeouts so that they are not reported as invalid inputs.
+func wrapProbeError(err error, errorType errors.ErrorType, code int) error {
+	if isStageTimeout(err) {
+		return err
+	}
+	return errors.Wrap(err, errorType, errors.GetErrorMessage(code), code)
+}
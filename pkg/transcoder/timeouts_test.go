@@ -0,0 +1,117 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestTranscodeStageTimeouts(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	// slow delays the commands named slow past their timeout
+	slow := func(slow string) func(name string, args []string) ffmpegtest.Result {
+		scripted := scriptedFFmpeg("", nil)
+		return func(name string, args []string) ffmpegtest.Result {
+			if name == slow && len(args) > 1 {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return scripted(name, args)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		opts       Options
+		slow       string
+		wantType   errors.ErrorType
+		wantCode   int
+		wantStage  string
+		wantResult bool
+	}{
+		{
+			name:     "Encode timeout",
+			opts:     Options{OutputType: MP4Output, EncodeTimeout: 10 * time.Millisecond},
+			slow:     "ffmpeg",
+			wantType: errors.TranscodingError,
+			wantCode: errors.ErrEncodeTimeout,
+		},
+		{
+			name:     "Encode timeout on HLS",
+			opts:     Options{OutputType: HLSOutput, EncodeTimeout: 10 * time.Millisecond},
+			slow:     "ffmpeg",
+			wantType: errors.TranscodingError,
+			wantCode: errors.ErrEncodeTimeout,
+		},
+		{
+			name:     "Probe timeout",
+			opts:     Options{OutputType: HLSOutput, UseAutoResolutions: true, ProbeTimeout: 10 * time.Millisecond},
+			slow:     "ffprobe",
+			wantType: errors.TranscodingError,
+			wantCode: errors.ErrProbeTimeout,
+		},
+		{
+			name:      "Job timeout during the encode",
+			opts:      Options{OutputType: HLSOutput, EncodeTimeout: time.Minute, MaxJobDuration: 20 * time.Millisecond},
+			slow:      "ffmpeg",
+			wantType:  errors.TimeoutError,
+			wantCode:  errors.ErrJobTimeout,
+			wantStage: StageEncode,
+		},
+		{
+			name:       "Within the timeouts",
+			opts:       Options{OutputType: MP4Output, EncodeTimeout: time.Minute},
+			slow:       "ffmpeg",
+			wantResult: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.InputPath = inputPath
+			opts.OutputPath = filepath.Join(t.TempDir(), "output")
+			if opts.OutputType == MP4Output {
+				opts.OutputPath += ".mp4"
+			}
+			opts.SkipDiskSpaceCheck = true
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+				WithRunner(&ffmpegtest.Runner{Handler: slow(tt.slow)}))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if tt.wantResult {
+				if err != nil {
+					t.Fatalf("Transcode() unexpected error: %v", err)
+				}
+				return
+			}
+			sErr, ok := err.(*errors.StructuredError)
+			if !ok || sErr.Type != tt.wantType || sErr.Code != tt.wantCode {
+				t.Fatalf("Transcode() error = %v, want %s %d", err, tt.wantType, tt.wantCode)
+			}
+			if sErr.Stage != tt.wantStage {
+				t.Errorf("Stage = %q, want %q", sErr.Stage, tt.wantStage)
+			}
+		})
+	}
+}
+
+func TestNewRejectsNegativeTimeouts(t *testing.T) {
+	_, err := NewWithDeps(Options{InputPath: "input.mp4", OutputPath: "out", EncodeTimeout: -time.Second},
+		&mockProgressReporter{}, newDiscardLogger(), nil)
+	if sErr, ok := err.(*errors.StructuredError); !ok || sErr.Type != errors.ValidationError {
+		t.Errorf("NewWithDeps() error = %v, want a ValidationError", err)
+	}
+}
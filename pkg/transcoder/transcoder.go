@@ -1,26 +1,31 @@
 package transcoder
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/heyjunin/HLSpresso/pkg/analysis"
+	"github.com/heyjunin/HLSpresso/pkg/cache"
+	"github.com/heyjunin/HLSpresso/pkg/diskspace"
 	"github.com/heyjunin/HLSpresso/pkg/downloader"
+	"github.com/heyjunin/HLSpresso/pkg/encoder"
+	"github.com/heyjunin/HLSpresso/pkg/encrypt"
 	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
 	"github.com/heyjunin/HLSpresso/pkg/hls"
 	"github.com/heyjunin/HLSpresso/pkg/logger"
+	"github.com/heyjunin/HLSpresso/pkg/preview"
 	"github.com/heyjunin/HLSpresso/pkg/progress"
-	"golang.org/x/sys/unix"
+	"github.com/heyjunin/HLSpresso/pkg/upload"
 	stderrors "errors" // Renomeado para evitar conflito
 )
 
@@ -33,6 +38,15 @@ const (
 	// HLSOutput specifies that the output should be HLS adaptive streaming files
 	// (manifests and segments).
 	HLSOutput OutputType = "hls"
+	// ProResOutput specifies a ProRes 422 QuickTime file, a mezzanine output
+	// produced alongside the main output (see Options.Mezzanines).
+	ProResOutput OutputType = "prores"
+	// DNxHROutput specifies a DNxHR HQ QuickTime file, a mezzanine output
+	// produced alongside the main output (see Options.Mezzanines).
+	DNxHROutput OutputType = "dnxhr"
+	// ThumbnailOutput specifies a JPEG thumbnail of the input, an additional
+	// output (see Options.Outputs).
+	ThumbnailOutput OutputType = "thumbnail"
 )
 
 // Options contains settings for configuring the Transcoder.
@@ -42,20 +56,160 @@ type Options struct {
 	// IsRemoteInput indicates whether the InputPath should be treated as a remote URL
 	// to be downloaded first.
 	IsRemoteInput bool
+	// InputMirrors lists http(s) URLs of copies of the remote input, tried in
+	// order when InputPath (then the previous mirror) fails with a network or
+	// download error: DNS or connection failure, HTTP error status or
+	// interrupted download. Streamed inputs only fall back when the preflight
+	// check fails. The URL used is recorded in the job manifest.
+	InputMirrors []string
+	// UseYtDlp fetches the remote input with yt-dlp instead of downloading it,
+	// so that InputPath can be the page of a video on any platform yt-dlp
+	// supports. The best video and audio streams are downloaded to
+	// DownloadDir and merged into an MP4 file, reporting the download progress.
+	// It cannot be used with StreamFromURL.
+	UseYtDlp bool
+	// YtDlpBinary is the path of the yt-dlp executable.
+	// Defaults to DefaultYtDlpBinary.
+	YtDlpBinary string
 	// DownloadDir specifies the directory where remote files should be downloaded.
 	// Defaults to "downloads" if not set. Only used if IsRemoteInput is true.
 	DownloadDir string
+	// DownloadTimeout is the time allowed to download a remote input.
+	// Defaults to DefaultDownloadTimeout.
+	DownloadTimeout time.Duration
+	// DownloadCacheDir enables the download cache: remote inputs are downloaded
+	// there, keyed by their URL and ETag, and reused by the later jobs of any
+	// process while they do not change on the server. Jobs of the same process
+	// requesting an input being downloaded share that download (see
+	// downloader.Cache). Inputs whose server sends neither an ETag nor a
+	// Last-Modified date are downloaded to DownloadDir as usual.
+	DownloadCacheDir string
+	// DownloadCacheMaxSize is the total size in bytes of the files kept in
+	// DownloadCacheDir, above which the least recently used files not in use
+	// are evicted. Zero disables the limit.
+	DownloadCacheMaxSize int64
+	// GoogleDriveToken is the OAuth access token used to download Google
+	// Drive share links through the Drive API, so that files shared with the
+	// token's account can be used as input. The file name and size are read
+	// from the API. Share links cannot be streamed.
+	GoogleDriveToken string
+	// DropboxToken is the access token used to download Dropbox share links
+	// through the Dropbox API, as for GoogleDriveToken.
+	DropboxToken string
+	// ProbeTimeout is the time allowed for each ffprobe run on the input, which
+	// may hang on unresponsive remote inputs. Defaults to DefaultProbeTimeout.
+	ProbeTimeout time.Duration
+	// EncodeTimeout is the time allowed for each encode, e.g. to stop jobs stuck
+	// on a stalled stream. Zero means no limit.
+	EncodeTimeout time.Duration
+	// MaxJobDuration bounds the total time of a Transcode call, whatever the
+	// deadline of its context: the running stage is stopped (ffmpeg included)
+	// and the call fails with an errors.TimeoutError whose Stage tells where
+	// the job was stuck. Zero means no limit.
+	MaxJobDuration time.Duration
+	// StallTimeout stops an encode when ffmpeg writes no output (progress
+	// lines included) for this long, e.g. when a network input hangs, instead
+	// of waiting for EncodeTimeout or MaxJobDuration. The encode is restarted
+	// up to StallRetries times, then fails with an errors.StallError. Chunk
+	// encodes (ChunkedEncoding) are not watched. Zero disables the detection.
+	StallTimeout time.Duration
+	// StallRetries is the number of times a stalled encode is restarted.
+	StallRetries int
 	// AllowOverwrite allows the transcoder to overwrite existing output files or
 	// downloaded files without error.
 	AllowOverwrite bool
 
+	// InputExtensions, if set, restricts local inputs to files with one of these
+	// extensions (e.g. ".mp4", case-insensitive; "" accepts files without one)
+	// instead of detecting their container with ffprobe. DefaultInputExtensions
+	// lists the common ones.
+	InputExtensions []string
+	// SkipInputFormatCheck disables the format validation of local inputs;
+	// unreadable inputs then fail when ffmpeg opens them.
+	SkipInputFormatCheck bool
+	// MaxInputDuration rejects inputs longer than this with ErrInputTooLong,
+	// right after they are probed. Zero disables the limit.
+	MaxInputDuration time.Duration
+	// MaxInputSizeBytes rejects inputs larger than this many bytes with
+	// ErrInputTooLarge: local files before they are read, and remote inputs
+	// from the size announced by the server before they are downloaded or
+	// streamed. Downloads are also stopped once they exceed it. Zero disables
+	// the limit.
+	MaxInputSizeBytes int64
+
+	// MinFreeDiskSpace sets the minimum free space (in bytes) required in the download
+	// and output directories before writing to them. If zero, the defaults are used:
+	// diskspace.DefaultMinFreeSpace for downloads and MP4 output, and
+	// diskspace.DefaultMinFreeSpaceHLS for HLS output.
+	MinFreeDiskSpace uint64
+	// SkipDiskSpaceCheck disables the free space checks entirely.
+	SkipDiskSpaceCheck bool
+
 	// OutputPath specifies the destination for the transcoded output.
 	// For HLSOutput, this should be a directory where manifests and segments will be stored.
 	// For MP4Output, this should be the full path to the output MP4 file.
+	// It may contain the variables {basename}, {date}, {resolution} and {jobid}
+	// (see OutputVarBasename and friends), resolved when Transcode runs,
+	// e.g. "out/{date}/{basename}".
 	OutputPath string
 	// OutputType determines the format of the output (HLS or MP4).
 	// Defaults to HLSOutput if not set.
 	OutputType OutputType
+	// JobID identifies the transcode in OutputPath ({jobid}), the manifest, and
+	// the job_id field of every log message, progress event and StructuredError
+	// of a Transcode call. Defaults to the identifier of the context passed to
+	// Transcode (see WithJobID), or to a random identifier.
+	JobID string
+	// SkipIfComplete makes Transcode return the existing output without encoding
+	// when OutputPath already holds a complete one: for HLSOutput a master playlist
+	// whose variant playlists are finished and whose segments all exist, for
+	// MP4Output a file with the duration of the input. Incomplete outputs are
+	// re-encoded (which requires AllowOverwrite for MP4Output).
+	SkipIfComplete bool
+
+	// CacheDir enables the output cache: outputs are stored there keyed by the
+	// checksum of the input and the options affecting the encode, and identical
+	// transcodes are restored from it instead of being encoded again. Streamed
+	// inputs are not cached. The ffmpeg version is not part of the key; clear
+	// the cache after upgrading ffmpeg if outputs must reflect it.
+	CacheDir string
+	// CacheLink hard-links outputs restored from the cache instead of copying
+	// them. Such outputs must not be modified in place.
+	CacheLink bool
+
+	// WriteManifest writes a JSON job manifest (see Manifest) next to the output,
+	// recording the source, options, ladder, ffmpeg version, timings and the
+	// checksum of every produced file. SkipIfComplete uses it when present.
+	WriteManifest bool
+	// ManifestFileName is the file name of the job manifest, written inside the
+	// output directory for HLSOutput and next to the output file for MP4Output.
+	// Defaults to DefaultManifestFileName.
+	ManifestFileName string
+	// WriteChecksums writes the SHA-256 of every produced segment and playlist
+	// in sha256sum format, to ChecksumFileName inside the output directory for
+	// HLSOutput or to "<output>.sha256" for MP4Output, so that delivery can
+	// verify the output with "sha256sum -c". Written before the output is uploaded.
+	WriteChecksums bool
+	// EncryptionKey, if set, encrypts the output files at rest with AES-256-GCM
+	// once the output is complete and before it is uploaded, for storage that is
+	// shared with others (see the encrypt package). Every file is replaced by a
+	// "<name>.enc" file, which must be decrypted before playback. Must be
+	// encrypt.KeySize bytes. Outputs stored in the cache are not encrypted.
+	EncryptionKey []byte `json:"-"`
+	// InputDecryptionKey, if set, decrypts the input before transcoding it, so
+	// that mezzanine files stored encrypted can be fed directly (see
+	// encrypt.DecryptInput). The plaintext is written to DownloadDir and removed
+	// once the job ends. Streamed inputs cannot be decrypted.
+	InputDecryptionKey []byte `json:"-"`
+	// InputKeyProvider, if set and InputDecryptionKey is not, looks up the input
+	// decryption key from InputPath, e.g. in a key management service.
+	InputKeyProvider encrypt.KeyProvider `json:"-"`
+	// InputCipher is the format of the encrypted input. Defaults to
+	// encrypt.CipherHLSpresso.
+	InputCipher encrypt.Cipher
+	// InputIV is the initialization vector of encrypt.CipherAESCBC inputs. If
+	// empty, it is read from the first 16 bytes of the input.
+	InputIV []byte `json:"-"`
 
 	// HLSSegmentDuration sets the target duration for HLS segments in seconds.
 	// Only used if OutputType is HLSOutput. Defaults to 10.
@@ -68,35 +222,434 @@ type Options struct {
 	// HLSPlaylistType specifies the HLS playlist type ("vod" or "event").
 	// Only used if OutputType is HLSOutput. Defaults to "vod".
 	HLSPlaylistType string
+	// HLSSharedAudio encodes the audio once, as an audio rendition referenced by
+	// every variant, instead of muxing a copy of it into each variant, saving
+	// bandwidth and storage (the packaging Apple recommends). The audio is
+	// encoded at the highest audio bitrate of the ladder.
+	// Only used if OutputType is HLSOutput.
+	HLSSharedAudio bool
+	// HLSVersion is the HLS protocol version targeted by the playlists
+	// (EXT-X-VERSION), which controls the features used: 3 for legacy players
+	// such as old smart TVs, 6 (the default) for independent segments, or 7 for
+	// fMP4 (CMAF) segments on modern players. Only used if OutputType is HLSOutput.
+	HLSVersion int
+	// HLSByteRange stores the segments of each variant in a single file
+	// addressed with EXT-X-BYTERANGE, which requires HLSVersion 4 or later.
+	// Only used if OutputType is HLSOutput.
+	HLSByteRange bool
+	// HLSMPEGTS tunes the MPEG-TS muxer of the segments (PCR period, PAT/PMT
+	// interval and flags). Not allowed with the fMP4 segments of HLSVersion 7.
+	// Only used if OutputType is HLSOutput.
+	HLSMPEGTS hls.MPEGTSOptions
+	// HLSMuxOverhead is the share of the segment size taken by the container,
+	// accounted for by MaxOutputSizeBytes and the size estimates of Plan (e.g.
+	// 0.06 for 6%). Zero uses the default of the segment format
+	// (hls.DefaultMuxOverhead). Only used if OutputType is HLSOutput.
+	HLSMuxOverhead float64
+	// HLSSessionData lists EXT-X-SESSION-DATA entries added to the master
+	// playlist, e.g. a content ID. Only used if OutputType is HLSOutput.
+	HLSSessionData []hls.SessionData
+	// HLSMasterTags lists custom tags or comments (single lines starting with
+	// "#") added to the master playlist, e.g. analytics hints for the player.
+	// Only used if OutputType is HLSOutput.
+	HLSMasterTags []string
+	// MaxOutputSizeBytes limits the estimated total size of the HLS output.
+	// Video bitrates are lowered (down to hls.MinBudgetBitrateScale) and, if needed,
+	// the highest renditions are dropped so that the ladder fits the budget for the
+	// input duration. Transcode fails before encoding if even the lowest rendition
+	// does not fit. Zero disables the limit. Only used if OutputType is HLSOutput.
+	MaxOutputSizeBytes int64
 
 	// FFmpegBinary allows specifying a custom path to the ffmpeg executable.
 	// Defaults to "ffmpeg" if not set (assuming it's in the system PATH).
 	FFmpegBinary string
 	// FFmpegExtraParams provides a way to pass additional command-line arguments
-	// directly to the underlying ffmpeg process. Use with caution.
+	// directly to the underlying ffmpeg process. Use with caution. They are
+	// appended to the output options; an entry holding an option and its value
+	// (e.g. "-crf 18") is split into two arguments.
 	FFmpegExtraParams []string
+	// FFmpegInputParams are additional arguments placed before the -i of the
+	// input, applying to the input rather than to the output, e.g. -ss to
+	// start at an offset, -probesize or -hwaccel. Entries are split like those
+	// of FFmpegExtraParams.
+	FFmpegInputParams []string
+	// FFmpegParamConflicts selects how FFmpegExtraParams and FFmpegInputParams
+	// overriding or breaking the generated arguments (e.g. a second -c:v or -f,
+	// a -vf next to the generated -filter_complex, or a file name after which
+	// the other params would trail the output) are handled before the encode:
+	// ParamConflictsWarn (default) logs a warning, ParamConflictsReject fails
+	// the job with the conflicts in the Fields of the error, and
+	// ParamConflictsOff skips the check.
+	FFmpegParamConflicts ParamConflictMode
+	// FFmpegEnv sets environment variables of the ffmpeg and ffprobe
+	// processes, on top of the environment of this process, e.g.
+	// FONTCONFIG_PATH for the fonts of drawtext or CUDA_VISIBLE_DEVICES to pin
+	// NVENC encodes to a GPU. Only applies to the default runner.
+	FFmpegEnv map[string]string
+	// FFmpegWorkDir is the working directory of the ffmpeg and ffprobe
+	// processes, against which relative paths in FFmpegExtraParams and in
+	// filters (e.g. drawtext fontfile) are resolved. The relative paths of the
+	// other options are made absolute, so that they still point to the same
+	// files. Only applies to the default runner.
+	FFmpegWorkDir string
+	// LogFFmpegOutput, if true, logs every line ffmpeg writes to stderr at the
+	// debug level. Only the last lines are kept (for error analysis) otherwise.
+	LogFFmpegOutput bool
+	// FFmpegLogPath, if set, appends the command line, the full standard
+	// error and the exit status of every ffmpeg and ffprobe process of each
+	// job to this file, separately from the application logs, for post-mortem
+	// debugging of encoding artifacts. The {jobid} variable is replaced with
+	// the job identifier to get one file per job. Only the default runner
+	// writes it (see WithRunner).
+	FFmpegLogPath string
+	// FFmpegLogMaxSize is the size in bytes beyond which the ffmpeg log is
+	// rotated. Defaults to DefaultFFmpegLogMaxSize.
+	FFmpegLogMaxSize int64
+	// FFmpegLogBackups is the number of rotated ffmpeg logs kept (path.1,
+	// path.2...). Defaults to DefaultFFmpegLogBackups.
+	FFmpegLogBackups int
+	// GracefulStop stops a cancelled encode (e.g. on SIGTERM) by sending "q"
+	// to ffmpeg, so that it finishes the segment it is writing and finalizes
+	// the playlists, and keeps the output encoded so far instead of removing
+	// it, e.g. to serve what was already broadcast from an "event" playlist.
+	// Only applies to the default runner.
+	GracefulStop bool
+	// StopGracePeriod is how long a stopped ffmpeg is given to exit before
+	// it is killed. Defaults to ffmpeg.GracePeriod.
+	StopGracePeriod time.Duration
+	// PassthroughMetadata copies the SCTE-35 cues and timed ID3 tags of the
+	// input (e.g. broadcast transport streams) to the HLS media playlists as
+	// EXT-X-DATERANGE tags instead of dropping them. HLS output only.
+	PassthroughMetadata bool
+	// RealtimePacing encodes at about 1x realtime (ffmpeg -re) instead of as
+	// fast as possible, for simulated live workflows (e.g. with an "event"
+	// playlist) or to avoid bursty IO on shared storage. The encode then takes at
+	// least the duration of the input.
+	RealtimePacing bool
+	// Deterministic produces byte-identical outputs for identical inputs and
+	// options, e.g. to diff outputs in CI: the encoders run single-threaded,
+	// the muxers are bitexact, the metadata of the input is dropped and the
+	// dates written by HLSpresso (timed metadata anchors, job manifest
+	// timings) are zeroed. Encoding is slower. Outputs encrypted with
+	// EncryptionKey still differ, since every file gets a random nonce.
+	Deterministic bool
+	// ExtractCaptions writes the CEA-608/708 closed captions of the input, which
+	// are always preserved in the video, to a WebVTT subtitle rendition of the
+	// HLS output as well, for players that do not render embedded captions.
+	ExtractCaptions bool
+	// CaptionLanguage is the language tag of the closed captions in the master
+	// playlist, e.g. "en" (the default) or "pt-BR".
+	CaptionLanguage string
+	// TrickPlay adds a trick-play rendition to the HLS output: a low frame rate
+	// video made only of keyframes, declared in the master playlist as an
+	// I-frame stream (EXT-X-I-FRAME-STREAM-INF), which players such as smart TVs
+	// show while scrubbing. Requires HLSVersion 4 or later.
+	TrickPlay bool
+	// TrickPlayInterval is the time between two frames of the trick-play
+	// rendition. Defaults to hls.DefaultTrickPlayInterval (one second).
+	TrickPlayInterval time.Duration
+	// TrickPlayWidth is the width of the trick-play frames in pixels, the height
+	// keeping the aspect ratio of the input. Defaults to hls.DefaultTrickPlayWidth.
+	TrickPlayWidth int
+	// ChunkedEncoding encodes the HLS output in parallel chunks, cutting the
+	// wall clock time of long inputs on machines with many cores: the input is
+	// split at keyframes into chunks of about ChunkDuration, whose video is
+	// encoded ChunkConcurrency chunks at a time, then stitched and segmented
+	// without re-encoding, with the audio encoded in a single pass. Segments
+	// may be shorter at chunk boundaries. Only used if OutputType is HLSOutput.
+	ChunkedEncoding bool
+	// ChunkDuration is the minimum duration of the chunks. Defaults to
+	// DefaultChunkDuration.
+	ChunkDuration time.Duration
+	// ChunkConcurrency is how many chunks are encoded at once. Defaults to
+	// DefaultChunkConcurrency.
+	ChunkConcurrency int
+	// VideoPreset, VideoTune, VideoProfile and VideoLevel set the x264
+	// preset, tune, profile and level of the video, instead of passing them in
+	// FFmpegExtraParams, which are appended after the generated arguments and
+	// may conflict with them. The settings of each resolution of
+	// HLSResolutions override them (see hls.EncoderSettings). Empty values keep
+	// the encoder defaults.
+	VideoPreset  hls.Preset
+	VideoTune    hls.Tune
+	VideoProfile hls.Profile
+	VideoLevel   hls.Level
+	// Deinterlace selects when the video is converted to progressive frames
+	// before it is scaled, avoiding the combing artifacts of interlaced
+	// broadcast masters in every rendition: DeinterlaceAuto (default) when
+	// ffprobe reports an interlaced field order, DeinterlaceOn always, or
+	// DeinterlaceOff never.
+	Deinterlace DeinterlaceMode
+	// Deinterlacer is the filter deinterlacing the video: hls.DeinterlaceBwdif
+	// (default) or hls.DeinterlaceYadif. The frame rate of the input is kept.
+	Deinterlacer hls.Deinterlacer
+	// PixelFormat is the pixel format of the encoded video. Inputs in another
+	// format, such as 10-bit 4:2:2 ProRes masters, are converted to it and
+	// tagged with the color space, transfer, primaries and range of the input
+	// (BT.709 in HD and BT.601 in SD when untagged). Defaults to
+	// hls.PixelFormatYUV420P (8-bit 4:2:0), the only format every H.264
+	// decoder supports; 10-bit, 4:2:2 and 4:4:4 formats require the matching
+	// VideoProfile (e.g. high10). PixelFormatKeep encodes the pixel format of
+	// the input.
+	PixelFormat hls.PixelFormat
+	// MP4Height scales the video of MP4Output to this height, keeping its
+	// aspect ratio, e.g. 720. Zero keeps the size of the input.
+	MP4Height int
+	// AudioCodec selects the audio codec of the output: AAC (the default),
+	// Dolby Digital (ac3) or Dolby Digital Plus (eac3) for living-room devices,
+	// or copy to pass the audio of the input through unchanged. The codec is
+	// signaled in the CODECS attribute of the master playlist.
+	AudioCodec hls.AudioCodec
+	// Intro and Outro, if set, are clips or still images inserted before and
+	// after the video, e.g. branding bumpers. They are normalized to the
+	// resolution, frame rate and audio format of the input, which is encoded
+	// with them into an intermediate file in DownloadDir before transcoding.
+	// Cannot be combined with PassthroughMetadata or ExtractCaptions.
+	Intro Slate
+	Outro Slate
 
 	// UseAutoResolutions, if true, attempts to detect the input video's resolution
 	// and automatically generates a set of suitable HLS resolutions, overriding
-	// the HLSResolutions field.
+	// the HLSResolutions field. Renditions of 480p or less are encoded at half the
+	// frame rate of high frame rate (above 30fps) inputs without closed captions.
 	// Only used if OutputType is HLSOutput.
 	UseAutoResolutions bool
+	// AutoScalePolicy sets how the automatic resolutions handle the input aspect
+	// ratio: hls.ScaleExact (default) keeps it, hls.ScalePad and hls.ScaleCrop
+	// produce standard 16:9 renditions by padding or cropping the picture.
+	// Only used if UseAutoResolutions is true.
+	AutoScalePolicy hls.ScalePolicy
+	// AutoDimensionAlignment is the multiple the automatic resolution dimensions
+	// are rounded to, 2 (default) or 4.
+	// Only used if UseAutoResolutions is true.
+	AutoDimensionAlignment int
+	// DisallowUpscale, if true, drops the HLSResolutions renditions that are larger
+	// than the input video, logging the skipped ones. If every rendition is larger,
+	// the smallest one is kept and capped to the input size.
+	// Automatic resolutions never upscale, so this only affects explicit ladders.
+	// Only used if OutputType is HLSOutput.
+	DisallowUpscale bool
 
 	// StreamFromURL, if true and InputPath is a URL, instructs the transcoder to
 	// attempt streaming directly from the URL via ffmpeg instead of downloading
 	// the file first. This requires ffmpeg to have network access and support
-	// for the URL's protocol. The Downloader is not used in this mode.
+	// for the URL's protocol (e.g. https://, rtmp://, srt://). The Downloader is
+	// not used in this mode.
 	// Defaults to false.
 	StreamFromURL bool
+	// PreflightMode selects how a streamed http(s) input is checked before
+	// ffmpeg opens it: PreflightHead (default), PreflightGet or PreflightNone.
+	// Inputs with other schemes are never checked.
+	PreflightMode PreflightMode
+	// PreflightContentTypes lists the Content-Type values the preflight check
+	// accepts, e.g. "video/" (every subtype), "video/mp4" or "*" (anything).
+	// Defaults to DefaultPreflightContentTypes.
+	PreflightContentTypes []string
+	// PreflightTimeout is the time allowed for each preflight request, e.g. for
+	// origins that are slow to answer the first request.
+	// Defaults to DefaultPreflightTimeout.
+	PreflightTimeout time.Duration
+	// SkipPreflight disables the preflight check entirely, like PreflightNone;
+	// ffmpeg then reports unreachable or unsupported inputs itself.
+	SkipPreflight bool
+	// BlankCheck detects inputs whose video is entirely black or whose audio is
+	// entirely silent, such as bad uploads, with a decoding pass before the
+	// encode: BlankCheckOff (default), BlankCheckWarn to log a warning, or
+	// BlankCheckReject to fail fast with errors.ErrBlackInput or
+	// errors.ErrSilentInput instead of wasting an expensive encode.
+	BlankCheck BlankCheckMode
+	// VerifyInput decodes the whole input before the encode, which is much
+	// faster than encoding it, and fails with errors.ErrCorruptedFile if ffmpeg
+	// reports decode errors, listed with their position in the DecodeErrors
+	// of the returned error.
+	VerifyInput bool
+
+	// Outputs lists additional outputs produced by the same job once the
+	// main output is encoded, such as an MP4 next to the HLS streams or a
+	// thumbnail. They share the download and the probe of the input, and use
+	// the options of the job unless their OutputSpec overrides them, but are
+	// each encoded by a separate ffmpeg run, and are not cached, encrypted,
+	// uploaded or listed in the manifest. A failure to create one fails the
+	// job.
+	Outputs []OutputSpec
+	// Mezzanines lists the mezzanine outputs, ProResOutput or DNxHROutput,
+	// to produce from the input for archival or editing once the main output
+	// is encoded: a QuickTime file named after the output with the type as
+	// suffix (e.g. "movie_prores.mov" for "movie.mp4"), written next to the
+	// output file for MP4Output and as "mezzanine_prores.mov" inside the
+	// output directory for HLSOutput. They are deinterlaced and keep the color
+	// tags of the input like the main output, but not its slates or extra
+	// params. A failure to create one fails the job.
+	Mezzanines []OutputType
+
+	// GeneratePreview, if true, creates a short silent preview MP4 (built from a few
+	// scenes selected via scene detection) next to the main output, useful for hover
+	// previews. A failure to create the preview is logged and does not fail the job.
+	GeneratePreview bool
+	// PreviewFileName is the file name of the preview clip. It is written inside the
+	// output directory for HLSOutput and next to the output file for MP4Output.
+	// Defaults to "preview.mp4".
+	PreviewFileName string
+	// PreviewDuration is the total length of the preview clip in seconds. Defaults to 6.
+	PreviewDuration float64
+	// PreviewScenes is the number of scenes that make up the preview clip. Defaults to 3.
+	PreviewScenes int
+
+	// AnalyzeMedia, if true, runs a loudness (LUFS, true peak) and silence/black
+	// detection pass over the input after transcoding. The report is written as JSON
+	// next to the main output and is available through Transcoder.AnalysisReport.
+	// A failure to analyze the input is logged and does not fail the job.
+	AnalyzeMedia bool
+	// AnalysisReportFileName is the file name of the JSON analysis report.
+	// Defaults to "analysis.json".
+	AnalysisReportFileName string
+
+	// UploadURL, if set, publishes the output once it is complete: every file of
+	// the HLS output directory (playlists last), or the MP4 file. The scheme
+	// selects the backend: http(s):// uses HTTP PUT, ftp:// and sftp:// upload to
+	// FTP and SFTP servers (see the upload package). A failed upload fails the job.
+	// Ignored when an uploader is set with WithUploader.
+	UploadURL string
+	// UploadHeaders are added to every HTTP upload request, e.g. for authentication.
+	UploadHeaders map[string]string
+	// UploadCreateCollections sends WebDAV MKCOL requests to create the remote
+	// directories before uploading into them. Only used for http(s) URLs.
+	UploadCreateCollections bool
+	// UploadIdentityFile is the SSH private key used for sftp:// URLs.
+	UploadIdentityFile string
+	// UploadContentMD5 sends a Content-MD5 header with every HTTP upload, so that
+	// origins such as S3 reject files corrupted in transit. Only used for http(s) URLs.
+	UploadContentMD5 bool
+	// UploadRetries is the number of times a failed file upload is retried,
+	// with exponential backoff.
+	UploadRetries int
+	// PublishPartial uploads the HLS output while it is being encoded, so that
+	// viewers can start watching long recordings before the encode finishes:
+	// the completed segments, then the media playlists, written as EVENT
+	// playlists, and the master playlist are published every PublishInterval.
+	// Once the encode is complete the playlists are converted to VOD (unless
+	// HLSPlaylistType is "event") and the final upload sends the files not
+	// published yet. Requires an uploader (UploadURL or WithUploader) and
+	// cannot be combined with EncryptionKey.
+	PublishPartial bool
+	// PublishInterval is the interval between two publications of the partial
+	// output. Defaults to DefaultPublishInterval.
+	PublishInterval time.Duration
+
+	// PreHook, if set, runs before the input is handled, e.g. to scan it for
+	// viruses; an error fails the job before anything is downloaded or encoded.
+	// See ExecHook to run a command.
+	PreHook Hook `json:"-"`
+	// PostHook, if set, runs once the output is complete (after the encryption
+	// and upload), e.g. to notify a CMS, with the job manifest listing the
+	// produced files. An error fails the job. It does not run when
+	// SkipIfComplete skips the job.
+	PostHook Hook `json:"-"`
+
+	// Locale selects the language of the standard error messages returned by
+	// Transcode, e.g. "en" or "pt" (see errors.Locales). Defaults to the locale
+	// set with errors.SetLocale or the HLSPRESSO_LANG environment variable.
+	// Error codes do not depend on the locale.
+	Locale string
 }
 
 // Transcoder handles the video transcoding process.
 // It should be created using New() or NewWithDeps().
+
 type Transcoder struct {
-	options    Options
-	progRep    progress.Reporter
-	logger     logger.Logger
-	downloader *downloader.Downloader
+	options     Options
+	progRep     progress.Reporter
+	logger      logger.Logger
+	downloader  *downloader.Downloader
+	analysis    *analysis.Report
+	disk        diskspace.Checker
+	encoder     encoder.Encoder
+	runner      ffmpeg.Runner
+	calibration *Calibration
+	inputInfo   *VideoInfo
+	probeOutput *FFprobeOutput
+	probedPath  string
+	uploader    upload.Uploader
+	// postProcessors are registered with WithPostProcessor.
+	postProcessors []PostProcessor
+	// startedAt and encodeDuration time the last Transcode call for the manifest.
+	startedAt      time.Time
+	encodeDuration time.Duration
+	// cached is set when the last Transcode call restored its output from the cache.
+	cached bool
+	// decryptedInput is the plaintext of an encrypted input, removed once the
+	// Transcode call ends.
+	decryptedInput string
+	// cache is set when Options.CacheDir is.
+	cache *cache.Cache
+	// outputTemplate is Options.OutputPath as given, before Transcode resolves
+	// its variables into options.OutputPath.
+	outputTemplate string
+	// slatedInput is the input with the intro and outro slates, removed once
+	// the Transcode call ends.
+	slatedInput string
+	// downloadCache is set when Options.DownloadCacheDir is, and
+	// releaseDownload releases the cached input of the last Transcode call.
+	downloadCache   *downloader.Cache
+	releaseDownload func()
+	// inputSource is the URL the remote input of the last Transcode call was
+	// read from, when Options.InputMirrors is set.
+	inputSource string
+	// closeUploader is set when the uploader was created from UploadURL and its
+	// connections must be closed after uploading.
+	closeUploader bool
+	// publisher is set while the last Transcode call publishes its partial
+	// output (see Options.PublishPartial).
+	publisher *publisher
+	// jobLog wraps the logger to add the job identifier to every message;
+	// fixedJobID is set when Options.JobID was given, which takes precedence
+	// over the identifier of the context.
+	jobLog     *jobLogger
+	fixedJobID bool
+	// cancel cancels the running Transcode call, if any (see Cancel).
+	cancel   context.CancelCauseFunc
+	cancelMu sync.Mutex
+	// newOutput is the output path of the running Transcode call when it did
+	// not exist before the call, removed if the call is cancelled.
+	newOutput string
+	// ffmpegLog is the Log of the default runner when Options.FFmpegLogPath
+	// is set.
+	ffmpegLog *ffmpegLog
+	// stage is the step the running Transcode call is in (see jobStages),
+	// reported when it exceeds Options.MaxJobDuration.
+	stage string
+	// activity records the output of ffmpeg when Options.StallTimeout is set.
+	activity *activity
+}
+
+// DependencyOption configures optional dependencies of a Transcoder created with
+// NewWithDeps.
+type DependencyOption func(*Transcoder)
+
+// WithEncoder sets the encoding backend used by the Transcoder.
+// Defaults to an encoder.FFmpegEncoder running Options.FFmpegBinary.
+func WithEncoder(enc encoder.Encoder) DependencyOption {
+	return func(t *Transcoder) {
+		t.encoder = enc
+	}
+}
+
+// WithRunner sets the ffmpeg.Runner used to launch ffmpeg and ffprobe, including
+// by the default encoder, so tests can simulate their output deterministically.
+// Defaults to ffmpeg.ExecRunner.
+func WithRunner(runner ffmpeg.Runner) DependencyOption {
+	return func(t *Transcoder) {
+		t.runner = runner
+	}
+}
+
+// WithUploader sets the Uploader the output is published with once it is
+// complete, e.g. an SFTP or custom storage backend, instead of the HTTP PUT
+// uploader created from Options.UploadURL.
+func WithUploader(uploader upload.Uploader) DependencyOption {
+	return func(t *Transcoder) {
+		t.uploader = uploader
+	}
 }
 
 // New creates a new Transcoder with the given options and progress reporter.
@@ -127,45 +680,125 @@ func New(options Options, progressReporter progress.Reporter) (*Transcoder, erro
 // - If options.StreamFromURL is true, the downloader (dl) is ignored and can be nil.
 // - If options.InputPath is a local path, the downloader (dl) is ignored and can be nil.
 //
-// Returns an error if the provided options are invalid (e.g., missing paths, missing downloader when required).
-func NewWithDeps(options Options, progressReporter progress.Reporter, logger logger.Logger, dl *downloader.Downloader) (*Transcoder, error) {
-	// Set defaults if not specified
-	if options.OutputType == "" {
-		options.OutputType = HLSOutput
-	}
-	if options.FFmpegBinary == "" {
-		options.FFmpegBinary = "ffmpeg"
-	}
-	if options.DownloadDir == "" {
-		options.DownloadDir = "downloads"
-	}
-
-	// Validate options
-	if options.InputPath == "" {
-		return nil, errors.New(errors.ValidationError, "Input path is required", "", 1)
+// Additional dependencies, such as the encoding backend, can be provided with
+// DependencyOption values (e.g. WithEncoder, WithRunner).
+//
+// Returns an error if the provided options are invalid, reporting every invalid field
+// (see ValidateOptions), or if the downloader is missing when required.
+func NewWithDeps(options Options, progressReporter progress.Reporter, logger logger.Logger, dl *downloader.Downloader, deps ...DependencyOption) (*Transcoder, error) {
+	// Validate options, setting defaults if not specified
+	if err := options.validate(); err != nil {
+		return nil, err
 	}
-	if options.OutputPath == "" {
-		return nil, errors.New(errors.ValidationError, "Output path is required", "", 2)
+	fixedJobID := options.JobID != ""
+	if !fixedJobID {
+		options.JobID = newJobID()
 	}
 
-	// Check if input is remote
+	// Check if input is remote. Other URL schemes (rtmp://, srt://, ...) can only be streamed.
 	isRemote, _ := url.ParseRequestURI(options.InputPath)
-	options.IsRemoteInput = (isRemote != nil && (isRemote.Scheme == "http" || isRemote.Scheme == "https"))
+	options.IsRemoteInput = (isRemote != nil && (isRemote.Scheme == "http" || isRemote.Scheme == "https")) ||
+		(options.StreamFromURL && isURL(options.InputPath))
 
 	// Validate downloader requirement
-	if options.IsRemoteInput && !options.StreamFromURL && dl == nil {
+	if options.IsRemoteInput && !options.StreamFromURL && !options.UseYtDlp && dl == nil {
 		// Se não foi fornecido um downloader e estamos processando entrada remota
 		// E não estamos no modo StreamFromURL, retornar erro.
 		// (No modo StreamFromURL, o downloader não é necessário)
 		return nil, errors.New(errors.ValidationError, "Downloader dependency is required for remote inputs when StreamFromURL is false", "", 3)
 	}
 
-	return &Transcoder{
-		options:    options,
-		progRep:    progressReporter,
-		logger:     logger,
-		downloader: dl, // Assign the provided downloader (can be nil if not needed)
-	}, nil
+	jobLog := &jobLogger{next: logger, jobID: options.JobID}
+	t := &Transcoder{
+		options:        options,
+		outputTemplate: options.OutputPath,
+		progRep:        progressReporter,
+		logger:         jobLog,
+		downloader:     dl, // Assign the provided downloader (can be nil if not needed)
+		disk:           diskspace.NewChecker(),
+		jobLog:         jobLog,
+		fixedJobID:     fixedJobID,
+	}
+	if options.CacheDir != "" {
+		t.cache = cache.New(cache.Options{Dir: options.CacheDir, Link: options.CacheLink})
+	}
+	if options.DownloadCacheDir != "" {
+		t.downloadCache = downloader.OpenCache(downloader.CacheOptions{
+			Dir:     options.DownloadCacheDir,
+			MaxSize: options.DownloadCacheMaxSize,
+			Logger:  logger,
+		})
+	}
+	for _, dep := range deps {
+		dep(t)
+	}
+	if t.runner == nil {
+		runner := ffmpeg.ExecRunner{
+			GracefulStop: options.GracefulStop,
+			GracePeriod:  options.StopGracePeriod,
+			Env:          ffmpegEnv(options.FFmpegEnv),
+			Dir:          options.FFmpegWorkDir,
+		}
+		if options.FFmpegLogPath != "" {
+			t.ffmpegLog = &ffmpegLog{}
+			runner.Log = t.ffmpegLog
+		}
+		t.runner = runner
+	}
+	if options.StallTimeout > 0 {
+		t.activity = &activity{}
+		t.runner = activityRunner{Runner: t.runner, activity: t.activity}
+	}
+	if t.encoder == nil {
+		t.encoder = encoder.NewFFmpegEncoder(options.FFmpegBinary, t.runner, t.logger)
+	}
+	if t.uploader == nil && options.UploadURL != "" {
+		uploader, err := upload.New(upload.Options{
+			URL:               options.UploadURL,
+			Headers:           options.UploadHeaders,
+			CreateCollections: options.UploadCreateCollections,
+			ContentMD5:        options.UploadContentMD5,
+			IdentityFile:      options.UploadIdentityFile,
+			Retries:           options.UploadRetries,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t.uploader = uploader
+		t.closeUploader = true
+	}
+
+	return t, nil
+}
+
+// checkDiskSpace verifies that dir has at least MinFreeDiskSpace bytes available,
+// falling back to defaultMin when no threshold is configured.
+// Failures to determine the free space (e.g. unsupported platforms) are logged
+// and ignored.
+func (t *Transcoder) checkDiskSpace(dir string, defaultMin uint64) error {
+	if t.options.SkipDiskSpaceCheck {
+		return nil
+	}
+
+	minFree := t.options.MinFreeDiskSpace
+	if minFree == 0 {
+		minFree = defaultMin
+	}
+
+	err := diskspace.Check(t.disk, dir, minFree)
+	if err == nil {
+		return nil
+	}
+	var sErr *errors.StructuredError
+	if stderrors.As(err, &sErr) {
+		return sErr
+	}
+
+	t.logger.Debug("Unable to determine free disk space, skipping check", "transcoder", map[string]interface{}{
+		"path":  dir,
+		"error": err.Error(),
+	})
+	return nil
 }
 
 // Transcode executes the video transcoding process based on the options the Transcoder
@@ -173,10 +806,58 @@ func NewWithDeps(options Options, progressReporter progress.Reporter, logger log
 // The context can be used to cancel the transcoding operation (e.g., on timeout or user request).
 // It returns the path to the primary output file (e.g., the main HLS manifest or the MP4 file)
 // upon successful completion, or an error if the process fails. The error may be a
-// *errors.StructuredError containing more details.
+// *errors.StructuredError containing more details. On failure the progress reporter
+// receives the error through Fail. A call stopped by Cancel or by cancelling ctx
+// removes the output it created and returns an error matching context.Canceled;
+// the progress reporter then receives it through progress.Cancel.
 func (t *Transcoder) Transcode(ctx context.Context) (string, error) {
-	// Primeiro, verificar se o FFmpeg está disponível
-	if err := t.checkFFmpeg(); err != nil {
+	ctx, done := t.startCancellable(ctx)
+	defer done()
+	ctx, stopTimer := t.withJobTimeout(ctx)
+	defer stopTimer()
+	t.startJob(ctx)
+	defer t.openFFmpegLog()()
+	result, err := t.transcode(ctx)
+	if timeoutErr := t.jobTimedOut(ctx, err); timeoutErr != nil {
+		err = timeoutErr
+	}
+	cancelErr := t.cancelled(ctx, err)
+	if cancelErr != nil {
+		err = cancelErr
+	}
+	if err != nil && t.options.Locale != "" {
+		err = errors.Localize(err, t.options.Locale)
+	}
+	if err != nil {
+		err = errors.WithJobID(err, t.options.JobID)
+	}
+	if err != nil && t.progRep != nil {
+		if cancelErr != nil {
+			progress.Cancel(t.progRep, err)
+		} else {
+			t.progRep.Fail(err)
+		}
+	}
+	return result, err
+}
+
+// transcode runs the steps of Transcode.
+func (t *Transcoder) transcode(ctx context.Context) (string, error) {
+	t.startedAt = time.Now()
+	t.cached = false
+	t.stage = StagePrepare
+	t.publisher = nil
+	defer t.removeDecryptedInput()
+	defer t.removeSlatedInput()
+	defer t.releaseCachedDownload()
+
+	// Primeiro, verificar se o backend de codificação (FFmpeg por padrão) está disponível
+	if err := t.encoder.Check(ctx); err != nil {
+		return "", err
+	}
+
+	// Executar o hook pré-processamento antes de tocar no input
+	if err := t.runHook(ctx, HookPre, t.options.PreHook, t.options.InputPath); err != nil {
 		return "", err
 	}
 
@@ -186,7 +867,39 @@ func (t *Transcoder) Transcode(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	outputPath := t.options.OutputPath
+	// Rejeitar inputs longos ou grandes demais antes de consumir recursos
+	if err := t.checkInputLimits(ctx, inputPath); err != nil {
+		return "", err
+	}
+
+	// Resolver as variáveis do caminho de saída ({basename}, {date}, ...)
+	outputPath, err := t.resolveOutputPath(ctx, inputPath)
+	if err != nil {
+		return "", err
+	}
+	t.options.OutputPath = outputPath
+	t.newOutput = ""
+	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
+		t.newOutput = outputPath
+	}
+	t.reportEvent(progress.EventInputValidated, map[string]interface{}{
+		"input":    redactURL(t.options.InputPath),
+		"path":     redactURL(inputPath),
+		"remote":   t.options.IsRemoteInput,
+		"streamed": t.options.IsRemoteInput && t.options.StreamFromURL,
+		"output":   outputPath,
+	})
+	t.reportInputProbed(ctx, inputPath)
+
+	// Reaproveitar uma saída completa de uma execução anterior
+	if t.options.SkipIfComplete {
+		if result, ok := t.completeOutput(ctx, inputPath, outputPath); ok {
+			t.logger.Info("Output already complete, skipping transcoding", "transcoder", map[string]interface{}{
+				"output_path": result,
+			})
+			return result, nil
+		}
+	}
 
 	// Se estiver usando resolução automática e o tipo de saída for HLS,
 	// detectar a resolução do vídeo de entrada e configurar as resoluções HLS
@@ -194,19 +907,23 @@ func (t *Transcoder) Transcode(ctx context.Context) (string, error) {
 		t.logger.Info("Detectando resolução do vídeo para configuração automática", "transcoder", nil)
 
 		// Detectar a resolução do vídeo
-		videoInfo, err := DetectVideoResolution(ctx, inputPath)
+		videoInfo, err := t.probeInput(ctx, inputPath)
 		if err != nil {
 			return "", fmt.Errorf("erro ao detectar resolução do vídeo: %w", err)
 		}
 
 		t.logger.Info("Resolução do vídeo detectada", "transcoder", map[string]interface{}{
-			"width":    videoInfo.Width,
-			"height":   videoInfo.Height,
-			"duration": videoInfo.Duration,
+			"width":      videoInfo.Width,
+			"height":     videoInfo.Height,
+			"duration":   videoInfo.Duration,
+			"frame_rate": videoInfo.FrameRate,
 		})
 
-		// Gerar resoluções automáticas com base na resolução detectada
-		autoResolutions := hls.GenerateAutoResolutions(videoInfo.Width, videoInfo.Height)
+		// Gerar resoluções automáticas com base na resolução detectada,
+		// reduzindo pela metade frame rates altos nas renditions baixas
+		autoResolutions := hls.GenerateAutoResolutionsWithPolicy(videoInfo.Width, videoInfo.Height,
+			t.options.AutoScalePolicy, t.options.AutoDimensionAlignment)
+		autoResolutions = limitLowFrameRates(autoResolutions, videoInfo)
 
 		// Registrar as resoluções que serão usadas
 		t.logger.Info("Usando resoluções automáticas", "transcoder", map[string]interface{}{
@@ -217,91 +934,403 @@ func (t *Transcoder) Transcode(ctx context.Context) (string, error) {
 		t.options.HLSResolutions = autoResolutions
 	}
 
-	// Transcodificar de acordo com o tipo de saída
-	switch t.options.OutputType {
-	case MP4Output:
-		t.logger.Info("Transcoding to MP4", "transcoder", map[string]interface{}{
-			"input":  inputPath,
-			"output": outputPath,
+	// Remover renditions maiores que o vídeo de entrada, evitando upscale
+	if t.options.DisallowUpscale && !t.options.UseAutoResolutions && t.options.OutputType == HLSOutput {
+		if err := t.removeUpscaledResolutions(ctx, inputPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Ajustar as resoluções ao orçamento de tamanho de saída, falhando antes de codificar
+	if t.options.MaxOutputSizeBytes > 0 && t.options.OutputType == HLSOutput {
+		if err := t.applySizeBudget(ctx, inputPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Reaproveitar uma saída idêntica do cache, se houver
+	cacheKey := t.cacheKey(inputPath)
+	encodeStart := time.Now()
+	result, cached := t.restoreFromCache(cacheKey, outputPath)
+	t.cached = cached
+	if cached {
+		t.reportEvent(progress.EventEncodeCompleted, map[string]interface{}{
+			"output": result,
+			"cached": true,
+		})
+	} else {
+		// Decodificar o input inteiro para detectar corrupção antes de codificar
+		if t.options.VerifyInput {
+			if err := t.verifyInput(ctx, inputPath); err != nil {
+				return "", err
+			}
+		}
+
+		// Rejeitar inputs inteiramente pretos ou silenciosos antes de codificar
+		if err := t.checkBlank(ctx, inputPath); err != nil {
+			return "", err
+		}
+
+		// Inserir as vinhetas de abertura e encerramento no input
+		encodeInput := inputPath
+		if t.hasSlates() {
+			if encodeInput, err = t.insertSlates(ctx, inputPath); err != nil {
+				return "", err
+			}
+		}
+
+		// Transcodificar de acordo com o tipo de saída
+		switch t.options.OutputType {
+		case MP4Output:
+			t.logger.Info("Transcoding to MP4", "transcoder", map[string]interface{}{
+				"input":  encodeInput,
+				"output": outputPath,
+			})
+			result, err = t.transcodeToMP4(ctx, encodeInput, outputPath, t.options.MP4Height)
+		case HLSOutput:
+			t.logger.Info("Creating HLS adaptive streams", "transcoder", map[string]interface{}{
+				"input":  encodeInput,
+				"output": outputPath,
+			})
+			result, err = t.createHLSStreams(ctx, encodeInput, outputPath, t.options.HLSResolutions, t.options.PublishPartial)
+		default:
+			return "", fmt.Errorf("tipo de saída desconhecido: %s", t.options.OutputType)
+		}
+		if err != nil {
+			return "", err
+		}
+		t.storeInCache(cacheKey, outputPath)
+	}
+	t.encodeDuration = time.Since(encodeStart)
+
+	// Produzir os arquivos mezanino ao lado da saída principal
+	if err := t.createMezzanines(ctx, inputPath); err != nil {
+		return "", err
+	}
+	// Produzir as saídas adicionais a partir do mesmo input
+	if err := t.createOutputs(ctx, inputPath); err != nil {
+		return "", err
+	}
+	if t.options.GeneratePreview {
+		t.createPreview(ctx, inputPath)
+	}
+	if t.options.AnalyzeMedia {
+		t.analyzeMedia(ctx, inputPath)
+	}
+	if t.options.WriteManifest {
+		t.writeManifest(ctx, inputPath)
+	}
+	if t.options.WriteChecksums {
+		t.writeChecksums()
+	}
+	if len(t.options.EncryptionKey) > 0 {
+		if result, err = t.encryptOutput(result); err != nil {
+			return "", err
+		}
+	}
+	if t.uploader != nil {
+		if err := t.uploadOutput(ctx, t.result(result, inputPath)); err != nil {
+			return "", err
+		}
+	}
+
+	// Executar os pós-processadores registrados, na ordem
+	if err := t.runPostProcessors(ctx, t.result(result, inputPath)); err != nil {
+		return "", err
+	}
+
+	// Executar o hook pós-processamento com o manifesto do job
+	if err := t.runHook(ctx, HookPost, t.options.PostHook, inputPath); err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// AnalysisReport returns the loudness and silence report produced by the last call
+// to Transcode, or nil if AnalyzeMedia is disabled or the analysis failed.
+func (t *Transcoder) AnalysisReport() *analysis.Report {
+	return t.analysis
+}
+
+// probeInput detects the resolution and duration of the input, caching the result
+// so that the resolution adjustments made before encoding share a single ffprobe run.
+func (t *Transcoder) probeInput(ctx context.Context, inputPath string) (*VideoInfo, error) {
+	if t.inputInfo != nil {
+		return t.inputInfo, nil
+	}
+	output, err := t.ffprobe(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := videoInfoFromProbe(output)
+	if err != nil {
+		return nil, err
+	}
+	t.inputInfo = info
+	return info, nil
+}
+
+// ffprobe runs ffprobe on inputPath, caching the result so that the input format
+// check and the resolution detection share a single run.
+func (t *Transcoder) ffprobe(ctx context.Context, inputPath string) (*FFprobeOutput, error) {
+	if t.probeOutput != nil && t.probedPath == inputPath {
+		return t.probeOutput, nil
+	}
+	probeCtx, cancel := withStageTimeout(ctx, t.options.ProbeTimeout)
+	defer cancel()
+	output, err := runFFprobe(probeCtx, t.runner, inputPath)
+	if err != nil {
+		if stageTimedOut(probeCtx) {
+			return nil, stageTimeoutError(err, "ffprobe", t.options.ProbeTimeout, errors.ErrProbeTimeout, errors.TranscodingError)
+		}
+		return nil, err
+	}
+	t.probeOutput, t.probedPath = output, inputPath
+	return output, nil
+}
+
+// removeUpscaledResolutions drops the HLS resolutions larger than the input video
+// (see hls.RemoveUpscaled).
+func (t *Transcoder) removeUpscaledResolutions(ctx context.Context, inputPath string) error {
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		return wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrInvalidFileFormat)
+	}
+
+	resolutions := t.options.HLSResolutions
+	if len(resolutions) == 0 {
+		resolutions = hls.DefaultResolutions
+	}
+
+	kept, skipped := hls.RemoveUpscaled(resolutions, info.Width, info.Height)
+	if len(skipped) > 0 {
+		t.logger.Info("Skipping resolutions larger than the input video", "transcoder", map[string]interface{}{
+			"source":  fmt.Sprintf("%dx%d", info.Width, info.Height),
+			"skipped": hls.FormatAutoResolutions(skipped),
+			"kept":    hls.FormatAutoResolutions(kept),
 		})
-		return t.transcodeToMP4(ctx, inputPath, outputPath)
-	case HLSOutput:
-		t.logger.Info("Creating HLS adaptive streams", "transcoder", map[string]interface{}{
-			"input":  inputPath,
-			"output": outputPath,
+	}
+
+	t.options.HLSResolutions = kept
+	return nil
+}
+
+// applySizeBudget adapts the HLS resolutions so that the estimated output size,
+// muxing overhead included, stays within MaxOutputSizeBytes (see
+// hls.FitToBudgetWithOverhead).
+func (t *Transcoder) applySizeBudget(ctx context.Context, inputPath string) error {
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		return wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrInvalidFileFormat)
+	}
+
+	resolutions := t.options.HLSResolutions
+	if len(resolutions) == 0 {
+		resolutions = hls.DefaultResolutions
+	}
+
+	fitted, err := hls.FitToBudgetWithOverhead(resolutions, info.Duration, t.options.MaxOutputSizeBytes, t.muxOverhead())
+	if err != nil {
+		return err
+	}
+
+	t.logger.Info("Resolutions adjusted to output size budget", "transcoder", map[string]interface{}{
+		"max_output_size": t.options.MaxOutputSizeBytes,
+		"duration":        info.Duration,
+		"mux_overhead":    t.muxOverhead(),
+		"resolutions":     hls.FormatAutoResolutions(fitted),
+	})
+
+	t.options.HLSResolutions = fitted
+	return nil
+}
+
+// muxOverhead returns the muxing overhead of the HLS segments: HLSMuxOverhead,
+// or the default of the segment format of HLSVersion.
+func (t *Transcoder) muxOverhead() float64 {
+	if t.options.HLSMuxOverhead > 0 {
+		return t.options.HLSMuxOverhead
+	}
+	return hls.DefaultMuxOverhead(t.options.HLSVersion)
+}
+
+// sidecarDir returns the directory where auxiliary files (preview, reports) are
+// written: the output directory for HLSOutput, or the directory containing the
+// output file for MP4Output.
+func (t *Transcoder) sidecarDir() string {
+	if t.options.OutputType == MP4Output {
+		return filepath.Dir(t.options.OutputPath)
+	}
+	return t.options.OutputPath
+}
+
+// uploadOutput publishes the output of result with the configured uploader: the
+// whole HLS output directory, or the MP4 file under its base name.
+func (t *Transcoder) uploadOutput(ctx context.Context, result Result) error {
+	if closer, ok := t.uploader.(io.Closer); ok && t.closeUploader {
+		defer closer.Close()
+	}
+
+	t.reportEvent(progress.EventUploadStarted, map[string]interface{}{
+		"url": redactURL(t.options.UploadURL),
+	})
+	uploadStart := time.Now()
+
+	uploader := t.uploader
+	if t.publisher != nil {
+		uploader = t.publisher.skipPublished(uploader)
+	}
+	uploaded, err := uploadResult(ctx, uploader, result)
+	if err != nil {
+		t.logger.Error("Failed to upload output", "transcoder", map[string]interface{}{
+			"uploaded": len(uploaded),
+			"error":    err.Error(),
+		})
+		return err
+	}
+
+	t.logger.Info("Output uploaded", "transcoder", map[string]interface{}{
+		"files": len(uploaded),
+	})
+	t.reportEvent(progress.EventUploadCompleted, map[string]interface{}{
+		"files":            len(uploaded),
+		"duration_seconds": time.Since(uploadStart).Seconds(),
+	})
+	return nil
+}
+
+// encryptOutput encrypts the output files at rest: every file of the HLS output
+// directory, or the MP4 file. Returns the path of result once encrypted.
+func (t *Transcoder) encryptOutput(result string) (string, error) {
+	root := t.options.OutputPath
+	encrypted, err := encrypt.EncryptTree(t.options.EncryptionKey, root)
+	if err != nil {
+		t.logger.Error("Failed to encrypt output", "transcoder", map[string]interface{}{
+			"encrypted": len(encrypted),
+			"error":     err.Error(),
+		})
+		if stderrors.Is(err, syscall.ENOSPC) {
+			return "", errors.Wrap(err, errors.DiskSpaceError, "Failed to encrypt output", errors.ErrDiskWriteFailed)
+		}
+		return "", errors.Wrap(err, errors.SystemError, "Failed to encrypt output", errors.ErrEncryptionFailed)
+	}
+
+	t.logger.Info("Output encrypted", "transcoder", map[string]interface{}{
+		"files": len(encrypted),
+	})
+	return result + encrypt.Suffix, nil
+}
+
+// analyzeMedia runs the loudness and silence analysis on the input and writes the
+// JSON report next to the main output.
+// Errors are logged as warnings since the main output was already produced.
+func (t *Transcoder) analyzeMedia(ctx context.Context, inputPath string) {
+	fileName := t.options.AnalysisReportFileName
+	if fileName == "" {
+		fileName = "analysis.json"
+	}
+
+	report, err := analysis.New(analysis.Options{
+		InputFile:    inputPath,
+		FFmpegBinary: t.options.FFmpegBinary,
+		Runner:       t.runner,
+		Logger:       t.logger,
+	}).Analyze(ctx)
+	if err != nil {
+		t.logger.Warn("Failed to analyze media", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	t.analysis = report
+
+	content, err := report.JSON()
+	if err != nil {
+		t.logger.Warn("Failed to marshal analysis report", "transcoder", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	reportPath := filepath.Join(t.sidecarDir(), fileName)
+	if err := os.WriteFile(reportPath, []byte(content), 0644); err != nil {
+		t.logger.Warn("Failed to write analysis report", "transcoder", map[string]interface{}{
+			"path":  reportPath,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	t.logger.Info("Analysis report created", "transcoder", map[string]interface{}{
+		"report": reportPath,
+	})
+}
+
+// createPreview generates the preview clip next to the main output.
+// Errors are logged as warnings since the main output was already produced.
+func (t *Transcoder) createPreview(ctx context.Context, inputPath string) {
+	fileName := t.options.PreviewFileName
+	if fileName == "" {
+		fileName = "preview.mp4"
+	}
+
+	gen := preview.New(preview.Options{
+		InputFile:    inputPath,
+		OutputFile:   filepath.Join(t.sidecarDir(), fileName),
+		Duration:     t.options.PreviewDuration,
+		Scenes:       t.options.PreviewScenes,
+		FFmpegBinary: t.options.FFmpegBinary,
+		Runner:       t.runner,
+		Logger:       t.logger,
+	})
+
+	previewPath, err := gen.CreatePreview(ctx)
+	if err != nil {
+		t.logger.Warn("Failed to create preview clip", "transcoder", map[string]interface{}{
+			"error": err.Error(),
 		})
-		return t.createHLSStreams(ctx, inputPath, outputPath)
-	default:
-		return "", fmt.Errorf("tipo de saída desconhecido: %s", t.options.OutputType)
+		return
 	}
+
+	t.logger.Info("Preview clip created", "transcoder", map[string]interface{}{
+		"preview": previewPath,
+	})
 }
 
-// handleInput processes the input path. If the input is a remote URL and
-// StreamFromURL is false, it downloads the file first. Otherwise, it returns
-// the original InputPath (either local file or URL for streaming).
+// handleInput processes the input path, falling back to the mirrors of a
+// remote input (see openMirroredInput).
 // Returns the path/URL to be used as input for ffmpeg, or an error.
 func (t *Transcoder) handleInput(ctx context.Context) (string, error) {
+	t.inputSource = ""
+	if t.options.IsRemoteInput && len(t.options.InputMirrors) > 0 {
+		return t.openMirroredInput(ctx)
+	}
+	return t.openInput(ctx, t.options.InputPath)
+}
+
+// openInput processes the input at source, InputPath or one of its mirrors.
+// If the input is a remote URL and StreamFromURL is false, it downloads the
+// file first. Otherwise, it returns source (either local file or URL for
+// streaming).
+func (t *Transcoder) openInput(ctx context.Context, source string) (string, error) {
 	// If input is a URL and StreamFromURL is true, use the URL directly.
 	if t.options.IsRemoteInput && t.options.StreamFromURL {
 		t.logger.Info("Streaming directly from URL", "transcoder", map[string]interface{}{
-			"url": t.options.InputPath,
+			"url": source,
 		})
 		// Basic validation of the URL format itself
-		_, err := url.ParseRequestURI(t.options.InputPath)
+		inputURL, err := url.ParseRequestURI(source)
 		if err != nil {
 			return "", errors.Wrap(err, errors.ValidationError, "Invalid input URL for streaming", 5)
 		}
 		
 		// Verificar se a URL é acessível antes de prosseguir
-		timeout := time.Second * 10
-		client := http.Client{
-			Timeout: timeout,
+		if err := t.preflight(ctx, inputURL); err != nil {
+			return "", err
 		}
 		
-		req, err := http.NewRequestWithContext(ctx, http.MethodHead, t.options.InputPath, nil)
-		if err != nil {
-			return "", errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
-		}
-		
-		resp, err := client.Do(req)
-		if err != nil {
-			if os.IsTimeout(err) {
-				return "", errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkTimeout), errors.ErrNetworkTimeout)
-			}
-			
-			// Verificar o erro de DNS - precisamos garantir que não seja caso-sensível e inclua variações comuns
-			errMsg := strings.ToLower(err.Error())
-			if strings.Contains(errMsg, "no such host") || 
-			   strings.Contains(errMsg, "lookup") || 
-			   strings.Contains(errMsg, "dns") || 
-			   strings.Contains(errMsg, "could not resolve") || 
-			   strings.Contains(errMsg, "unknown host") {
-				return "", errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkDNSFailure), errors.ErrNetworkDNSFailure)
-			}
-			
-			// Outros erros de conexão
-			if strings.Contains(err.Error(), "dial") || strings.Contains(err.Error(), "connection") {
-				return "", errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
-			}
-			
-			return "", errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
-		}
-		
-		defer resp.Body.Close()
-		
-		if resp.StatusCode >= 400 {
-			return "", errors.New(errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkServerUnavailable), 
-				fmt.Sprintf("Server returned status code %d", resp.StatusCode), errors.ErrNetworkServerUnavailable)
-		}
-		
-		// Verificar se é um formato de vídeo suportado
-		contentType := resp.Header.Get("Content-Type")
-		if !strings.HasPrefix(contentType, "video/") && !strings.Contains(contentType, "application/octet-stream") {
-			return "", errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrInvalidFileFormat), 
-				fmt.Sprintf("Content-Type: %s", contentType), errors.ErrInvalidFileFormat)
-		}
-		
-		return t.options.InputPath, nil // Return the URL
+		return source, nil // Return the URL
 	}
 
 	// If input is not remote, check if the local file exists.
@@ -315,7 +1344,7 @@ func (t *Transcoder) handleInput(ctx context.Context) (string, error) {
 		
 		// Verificar se é realmente um arquivo e não um diretório
 		if info.IsDir() {
-			return "", errors.New(errors.InvalidFileFormatError, "O caminho fornecido é um diretório, não um arquivo", 
+			return "", errors.New(errors.InvalidFileFormatError, "The input path is a directory, not a file", 
 				t.options.InputPath, errors.ErrInvalidFileFormat)
 		}
 		
@@ -326,30 +1355,45 @@ func (t *Transcoder) handleInput(ctx context.Context) (string, error) {
 				return "", errors.New(errors.PermissionError, errors.GetErrorMessage(errors.ErrReadPermissionDenied), 
 					t.options.InputPath, errors.ErrReadPermissionDenied)
 			}
-			return "", errors.Wrap(err, errors.SystemError, "Falha ao abrir o arquivo de entrada", 4)
+			return "", errors.Wrap(err, errors.SystemError, "Failed to open the input file", 4)
 		}
 		file.Close()
 		
 		// Verificar se o arquivo tem tamanho não-zero
 		if info.Size() == 0 {
-			return "", errors.New(errors.InvalidFileFormatError, "O arquivo está vazio", 
+			return "", errors.New(errors.InvalidFileFormatError, "The input file is empty", 
 				t.options.InputPath, errors.ErrCorruptedFile)
 		}
-		
-		// Verificar extensão do arquivo para formatos comuns de vídeo
-		ext := strings.ToLower(filepath.Ext(t.options.InputPath))
-		supportedFormats := map[string]bool{
-			".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".webm": true,
-			".flv": true, ".wmv": true, ".mpeg": true, ".mpg": true, ".m4v": true,
-			".3gp": true, ".ts": true, ".mts": true, ".m2ts": true,
+		if err := t.checkInputSize(info.Size()); err != nil {
+			return "", err
 		}
 		
-		if !supportedFormats[ext] {
-			return "", errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrUnsupportedFileFormat), 
-				fmt.Sprintf("Extensão: %s", ext), errors.ErrUnsupportedFileFormat)
+		// Decifrar o input criptografado antes de verificar o formato
+		inputPath := t.options.InputPath
+		if t.decryptsInput() {
+			if inputPath, err = t.decryptInput(ctx, inputPath); err != nil {
+				return "", err
+			}
+		}
+
+		// Verificar o formato do arquivo pelo conteúdo (ou pela extensão, se configurado)
+		if err := t.checkInputFormat(ctx, inputPath); err != nil {
+			return "", err
 		}
 		
-		return t.options.InputPath, nil // Return the local file path
+		return inputPath, nil // Return the local file path (decrypted if needed)
+	}
+
+	// Buscar o input com o yt-dlp, que resolve páginas de plataformas de vídeo
+	if t.options.UseYtDlp {
+		downloadedPath, err := t.fetchWithYtDlp(ctx, source)
+		if err != nil {
+			return "", err
+		}
+		if t.decryptsInput() {
+			return t.decryptInput(ctx, downloadedPath)
+		}
+		return downloadedPath, nil
 	}
 
 	// --- Download Logic (only runs if IsRemoteInput is true and StreamFromURL is false) ---
@@ -360,38 +1404,43 @@ func (t *Transcoder) handleInput(ctx context.Context) (string, error) {
 	}
 
 	t.logger.Info("Downloading remote input before transcoding", "transcoder", map[string]interface{}{
-		"url": t.options.InputPath,
+		"url": source,
 	})
 
 	// Parse URL to validate and extract filename
-	parsedURL, err := url.Parse(t.options.InputPath)
+	parsedURL, err := url.Parse(source)
 	if err != nil {
 		return "", errors.Wrap(err, errors.ValidationError, "Invalid input URL", 5)
 	}
 
-	// Extract filename from URL path
-	urlPath := parsedURL.Path
-	fileName := filepath.Base(urlPath)
-	if fileName == "" || fileName == "." || fileName == "/" {
-		fileName = fmt.Sprintf("download_%d.mp4", time.Now().Unix())
+	// Configurar o downloader existente para esta tarefa
+	downloadOptions := downloader.Options{
+		URL:              source,
+		Timeout:          t.options.DownloadTimeout,
+		Progress:         t.progRep,
+		AllowOverride:    t.options.AllowOverwrite,
+		GoogleDriveToken: t.options.GoogleDriveToken,
+		DropboxToken:     t.options.DropboxToken,
+		MaxSize:          t.options.MaxInputSizeBytes,
+		Logger:           t.logger,
 	}
 
-	// Verificar espaço em disco antes de iniciar o download
-	downloadDir := t.options.DownloadDir
-	var stat unix.Statfs_t
-	
-	// Verificar espaço disponível no diretório de download
-	if err := unix.Statfs(downloadDir, &stat); err == nil {
-		// Calcular espaço livre em bytes
-		freeSpace := stat.Bavail * uint64(stat.Bsize)
-		
-		// Verificar se há pelo menos 500MB disponíveis (valor arbitrário, ajustar conforme necessário)
-		minRequiredSpace := uint64(500 * 1024 * 1024) // 500 MB
-		if freeSpace < minRequiredSpace {
-			return "", errors.New(errors.DiskSpaceError, errors.GetErrorMessage(errors.ErrDiskSpaceInsufficient), 
-				fmt.Sprintf("Espaço disponível: %d bytes", freeSpace), errors.ErrDiskSpaceInsufficient)
-		}
+	// Links de compartilhamento: o nome e o tamanho vêm da API do serviço
+	sharedFile, isShareLink, err := downloader.New(downloadOptions).Stat(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Extract filename from URL path. URL paths always use forward slashes, so
+	// path.Base is used instead of filepath.Base (which also splits on '\' on Windows).
+	fileName := path.Base(parsedURL.Path)
+	if isShareLink {
+		fileName = sharedFile.Name
+	}
+	if fileName == "" || fileName == "." || fileName == "/" {
+		fileName = fmt.Sprintf("download_%d.mp4", time.Now().Unix())
 	}
+	fileName = sanitizeFileName(fileName)
 
 	// Create download directory
 	if err := os.MkdirAll(t.options.DownloadDir, 0755); err != nil {
@@ -401,27 +1450,56 @@ func (t *Transcoder) handleInput(ctx context.Context) (string, error) {
 		return "", errors.Wrap(err, errors.SystemError, "Failed to create download directory", 6)
 	}
 
+	// Verificar o tamanho anunciado pelo servidor antes de iniciar o download
+	if isShareLink {
+		err = t.checkInputSize(sharedFile.Size)
+	} else {
+		err = t.checkRemoteInputSize(ctx, parsedURL)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Verificar espaço em disco antes de iniciar o download
+	if err := t.checkDiskSpace(t.options.DownloadDir, diskspace.DefaultMinFreeSpace); err != nil {
+		return "", err
+	}
+
 	// Set output path for download
 	downloadPath := filepath.Join(t.options.DownloadDir, fileName)
 
 	// Initialize variable for downloaded path
 	var downloadedPath string
 
-	// Configurar o downloader existente para esta tarefa
-	downloadOptions := downloader.Options{
-		URL:           t.options.InputPath,
-		OutputPath:    downloadPath,
-		Timeout:       30 * time.Minute, // TODO: Make timeout configurable?
-		Progress:      t.progRep,
-		AllowOverride: t.options.AllowOverwrite,
-	}
+	downloadOptions.OutputPath = downloadPath
 
 	// Se um downloader foi injetado, reconfigure-o
 	*t.downloader = *downloader.New(downloadOptions)
 
 	// Download file
-	downloadedPath, err = t.downloader.Download(ctx)
+	t.reportEvent(progress.EventDownloadStarted, map[string]interface{}{
+		"url":  redactURL(source),
+		"path": downloadPath,
+	})
+	downloadStart := time.Now()
+	downloadCtx, cancel := withStageTimeout(ctx, t.options.DownloadTimeout)
+	defer cancel()
+	if t.downloadCache != nil {
+		downloadedPath, t.releaseDownload, err = t.downloadCache.Acquire(downloadCtx, downloadOptions)
+	} else {
+		downloadedPath, err = t.downloader.Download(downloadCtx)
+	}
 	if err != nil {
+		var sErr *errors.StructuredError
+		if stderrors.As(err, &sErr) && sErr.Code == errors.ErrInputTooLarge {
+			return "", err
+		}
+
+		// O cliente HTTP usa o mesmo tempo limite e pode expirar primeiro
+		if stageTimedOut(downloadCtx) || strings.Contains(err.Error(), "Client.Timeout") {
+			return "", stageTimeoutError(err, "download", t.options.DownloadTimeout, errors.ErrDownloadTimeout, errors.NetworkError)
+		}
+
 		// Melhorar a tipagem de erros do downloader
 		if os.IsPermission(err) {
 			return "", errors.Wrap(err, errors.PermissionError, errors.GetErrorMessage(errors.ErrWritePermissionDenied), errors.ErrWritePermissionDenied)
@@ -442,219 +1520,111 @@ func (t *Transcoder) handleInput(ctx context.Context) (string, error) {
 		return "", errors.Wrap(err, errors.DownloadError, "Failed to download input file", 7)
 	}
 
-	return downloadedPath, nil
-}
-
-// createHLS generates HLS adaptive streaming files based on the transcoder's options.
-// Deprecated: This function's logic is now part of the internal createHLSStreams.
-func (t *Transcoder) createHLS(ctx context.Context, inputPath string) (string, error) {
-	t.logger.Info("Creating HLS adaptive streams", "transcoder", map[string]interface{}{
-		"input":  inputPath,
-		"output": t.options.OutputPath,
-	})
-
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(t.options.OutputPath, 0755); err != nil {
-		return "", errors.Wrap(err, errors.SystemError, "Failed to create output directory", 8)
+	downloaded := map[string]interface{}{
+		"path":             downloadedPath,
+		"duration_seconds": time.Since(downloadStart).Seconds(),
 	}
-
-	// Set HLS options
-	hlsOptions := hls.Options{
-		InputFile:         inputPath,
-		OutputDir:         t.options.OutputPath,
-		SegmentDuration:   t.options.HLSSegmentDuration,
-		PlaylistType:      t.options.HLSPlaylistType,
-		Resolutions:       t.options.HLSResolutions,
-		FFmpegBinary:      t.options.FFmpegBinary,
-		FFmpegExtraParams: t.options.FFmpegExtraParams,
-		Progress:          t.progRep,
+	if info, err := os.Stat(downloadedPath); err == nil {
+		downloaded["bytes"] = info.Size()
 	}
+	t.reportEvent(progress.EventDownloadCompleted, downloaded)
 
-	// Create HLS generator
-	hlsGen := hls.New(hlsOptions)
-
-	// Generate HLS streams
-	masterPlaylistPath, err := hlsGen.CreateHLS(ctx)
-	if err != nil {
-		return "", errors.Wrap(err, errors.HLSError, "Failed to create HLS streams", 9)
+	if t.decryptsInput() {
+		return t.decryptInput(ctx, downloadedPath)
 	}
-
-	return masterPlaylistPath, nil
+	return downloadedPath, nil
 }
 
-// createMP4 generates a single MP4 file based on the transcoder's options.
-// Deprecated: This function's logic is now part of the internal transcodeToMP4.
-func (t *Transcoder) createMP4(ctx context.Context, inputPath string) (string, error) {
-	t.logger.Info("Transcoding to MP4", "transcoder", map[string]interface{}{
-		"input":  inputPath,
-		"output": t.options.OutputPath,
-	})
-
-	// Create output directory if needed
-	outputDir := filepath.Dir(t.options.OutputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", errors.Wrap(err, errors.SystemError, "Failed to create output directory", 10)
-	}
-
-	// Build FFmpeg command for MP4 output
-	args := []string{
-		"-i", inputPath,
-		"-c:v", "libx264",
-		"-preset", "medium",
-		"-crf", "22",
-		"-c:a", "aac",
-		"-b:a", "128k",
-	}
-
-	// Add any extra parameters
-	args = append(args, t.options.FFmpegExtraParams...)
-
-	// Add output path
-	args = append(args, "-y", t.options.OutputPath)
-
-	// Log FFmpeg command
-	cmdStr := t.options.FFmpegBinary + " " + strings.Join(args, " ")
-	t.logger.Debug("Executing FFmpeg command", "ffmpeg", map[string]interface{}{
-		"command": cmdStr,
-	})
-
-	// Run FFmpeg command
-	cmd := exec.CommandContext(ctx, t.options.FFmpegBinary, args...)
-
-	// Capture stderr for progress tracking
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return "", errors.Wrap(err, errors.TranscodingError, "Failed to create stderr pipe", 11)
-	}
-
-	// Start command
-	if err := cmd.Start(); err != nil {
-		return "", errors.Wrap(err, errors.TranscodingError, "Failed to start FFmpeg", 12)
+// releaseCachedDownload releases the input acquired from the download cache by
+// the last Transcode call, if any, which may then be evicted.
+func (t *Transcoder) releaseCachedDownload() {
+	if t.releaseDownload != nil {
+		t.releaseDownload()
+		t.releaseDownload = nil
 	}
+}
 
-	// Get total duration to estimate progress
-	totalDuration := getVideoDuration(inputPath)
-	if totalDuration > 0 && t.progRep != nil {
-		// Convert to frames at approx 25 fps for progress reporting
-		totalFrames := int64(totalDuration * 25)
-		t.progRep.Start(totalFrames)
-	}
-
-	// Process FFmpeg output for progress
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		timeRegex := regexp.MustCompile(`time=(\d+):(\d+):(\d+\.\d+)`)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			// Log FFmpeg output
-			t.logger.Debug(line, "ffmpeg", nil)
-
-			// Parse time for progress
-			if t.progRep != nil && totalDuration > 0 {
-				if matches := timeRegex.FindStringSubmatch(line); len(matches) > 3 {
-					hours, _ := strconv.Atoi(matches[1])
-					minutes, _ := strconv.Atoi(matches[2])
-					seconds, _ := strconv.ParseFloat(matches[3], 64)
-
-					currentTime := float64(hours*3600) + float64(minutes*60) + seconds
-					progress := int64((currentTime / totalDuration) * 100)
-
-					// Update progress
-					t.progRep.Update(progress, "transcoding", "Creating MP4")
-				}
-			}
+// sanitizeFileName replaces characters that are not allowed in file names on
+// Windows (and path separators on any platform) with underscores.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
 		}
-	}()
-
-	// Wait for command to complete
-	if err := cmd.Wait(); err != nil {
-		return "", errors.Wrap(err, errors.TranscodingError, "FFmpeg command failed", 13)
-	}
-
-	// Complete progress
-	if t.progRep != nil {
-		t.progRep.Complete()
-	}
-
-	return t.options.OutputPath, nil
+		return r
+	}, name)
 }
 
-// getVideoDuration gets the duration of a video file in seconds
-func getVideoDuration(filePath string) float64 {
-	cmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-show_entries", "format=duration",
-		"-of", "default=noprint_wrappers=1:nokey=1",
-		filePath)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return 0
+// encode runs job with the configured encoder, reporting the
+// EventEncodeStarted and EventEncodeCompleted lifecycle events.
+func (t *Transcoder) encode(ctx context.Context, job encoder.Job) (string, error) {
+	started := map[string]interface{}{
+		"encoder": t.encoder.Name(),
+		"format":  string(job.Format),
+		"output":  job.OutputPath,
 	}
-
-	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
-	if err != nil {
-		return 0
+	if e, ok := t.encoder.(interface {
+		Args(job encoder.Job) ([]string, error)
+	}); ok {
+		if err := t.checkParams(e.Args, job); err != nil {
+			return "", err
+		}
+		if args, err := e.Args(job); err == nil {
+			for i, arg := range args {
+				args[i] = redactURL(arg)
+			}
+			started["command"] = t.options.FFmpegBinary + " " + strings.Join(args, " ")
+		}
 	}
+	t.reportEvent(progress.EventEncodeStarted, started)
 
-	return duration
-}
-
-// checkFFmpeg verifies that FFmpeg is installed and working correctly,
-// including checking for essential codecs and dependencies.
-func (t *Transcoder) checkFFmpeg() error {
-	cmd := exec.Command(t.options.FFmpegBinary, "-version")
-	output, err := cmd.CombinedOutput()
+	start := time.Now()
+	result, err := t.runEncode(ctx, job)
 	if err != nil {
-		return errors.Wrap(err, errors.CodecNotFoundError, 
-			errors.GetErrorMessage(errors.ErrMissingDependency), 
-			errors.ErrMissingDependency)
+		return "", err
 	}
-
-	outputStr := string(output)
-	t.logger.Debug("FFmpeg version info", "ffmpeg", map[string]interface{}{
-		"version_output": outputStr,
+	t.reportEvent(progress.EventEncodeCompleted, map[string]interface{}{
+		"output":           result,
+		"duration_seconds": time.Since(start).Seconds(),
 	})
+	return result, nil
+}
 
-	// Verificar codecs importantes
-	cmd = exec.Command(t.options.FFmpegBinary, "-codecs")
-	codecsOutput, err := cmd.CombinedOutput()
-	if err != nil {
-		return errors.Wrap(err, errors.SystemError, "Falha ao verificar codecs do FFmpeg", 20)
-	}
-
-	codecsStr := string(codecsOutput)
-	
-	// Verificar se os codecs principais estão presentes
-	requiredCodecs := []struct {
-		name        string
-		searchTerm  string
-		errorCode   int
-	}{
-		{"libx264", "libx264", errors.ErrCodecNotFound},
-		{"AAC audio", "aac", errors.ErrCodecNotSupported},
-	}
-
-	for _, codec := range requiredCodecs {
-		if !strings.Contains(codecsStr, codec.searchTerm) {
-			return errors.New(errors.CodecNotFoundError, 
-				errors.GetErrorMessage(codec.errorCode), 
-				fmt.Sprintf("Codec %s não encontrado", codec.name), 
-				codec.errorCode)
+// runEncode runs the encoder under the encode timeout and the stall detection,
+// restarting stalled encodes up to Options.StallRetries times.
+func (t *Transcoder) runEncode(ctx context.Context, job encoder.Job) (string, error) {
+	for attempt := 1; ; attempt++ {
+		encodeCtx, cancel := withStageTimeout(ctx, t.options.EncodeTimeout)
+		watchCtx, stopWatch := t.watchStalls(encodeCtx)
+		result, err := t.encoder.Encode(watchCtx, job)
+		stopWatch()
+		cancel()
+		switch {
+		case err == nil:
+			return result, nil
+		case stageTimedOut(encodeCtx):
+			return "", stageTimeoutError(err, "encode", t.options.EncodeTimeout, errors.ErrEncodeTimeout, errors.TranscodingError)
+		case !stalled(watchCtx):
+			return "", err
+		case attempt > t.options.StallRetries:
+			return "", stallError(err, t.options.StallTimeout, attempt)
 		}
+		t.logger.Warn("FFmpeg stalled, restarting the encode", "transcoder", map[string]interface{}{
+			"attempt":       attempt,
+			"stall_timeout": t.options.StallTimeout.String(),
+		})
 	}
-
-	return nil
 }
 
-// transcodeToMP4 converts the input to MP4 format
-func (t *Transcoder) transcodeToMP4(ctx context.Context, inputPath, outputPath string) (string, error) {
+// transcodeToMP4 converts the input to MP4 format, scaled to height if not zero
+func (t *Transcoder) transcodeToMP4(ctx context.Context, inputPath, outputPath string, height int) (string, error) {
 	t.logger.Info("Transcoding to MP4", "transcoder", map[string]interface{}{
 		"input":  inputPath,
 		"output": outputPath,
 	})
+	if t.options.PassthroughMetadata {
+		t.logger.Warn("Timed metadata passthrough is only supported for HLS output, dropping it", "transcoder", nil)
+	}
 
 	// Create output directory if needed
 	outputDir := filepath.Dir(outputPath)
@@ -664,22 +1634,14 @@ func (t *Transcoder) transcodeToMP4(ctx context.Context, inputPath, outputPath s
 				errors.GetErrorMessage(errors.ErrWritePermissionDenied), 
 				errors.ErrWritePermissionDenied)
 		}
-		
-		// Verificar se há espaço no disco
-		var stat unix.Statfs_t
-		if unix.Statfs(outputDir, &stat) == nil {
-			freeSpace := stat.Bavail * uint64(stat.Bsize)
-			if freeSpace < uint64(500*1024*1024) { // 500MB mínimo
-				return "", errors.New(errors.DiskSpaceError, 
-					errors.GetErrorMessage(errors.ErrDiskSpaceInsufficient), 
-					fmt.Sprintf("Espaço livre: %d bytes", freeSpace), 
-					errors.ErrDiskSpaceInsufficient)
-			}
-		}
-		
 		return "", errors.Wrap(err, errors.SystemError, "Failed to create output directory", 10)
 	}
 
+	// Verificar se há espaço no disco
+	if err := t.checkDiskSpace(outputDir, diskspace.DefaultMinFreeSpace); err != nil {
+		return "", err
+	}
+
 	// Verificar se já existe um arquivo de saída e se podemos sobrescrevê-lo
 	if _, err := os.Stat(outputPath); err == nil && !t.options.AllowOverwrite {
 		return "", errors.New(errors.InvalidOutputPathError, 
@@ -687,85 +1649,27 @@ func (t *Transcoder) transcodeToMP4(ctx context.Context, inputPath, outputPath s
 			outputPath, errors.ErrInvalidOutputPath)
 	}
 
-	// Build FFmpeg command for MP4 output
-	args := []string{
-		"-i", inputPath,
-		"-c:v", "libx264",
-		"-preset", "medium",
-		"-crf", "22",
-		"-c:a", "aac",
-		"-b:a", "128k",
-	}
-
-	// Add any extra parameters
-	args = append(args, t.options.FFmpegExtraParams...)
-
-	// Add output path
-	args = append(args, "-y", outputPath)
-
-	// Log FFmpeg command
-	cmdStr := t.options.FFmpegBinary + " " + strings.Join(args, " ")
-	t.logger.Debug("Executing FFmpeg command", "ffmpeg", map[string]interface{}{
-		"command": cmdStr,
-	})
-
-	// Run FFmpeg command
-	cmd := exec.CommandContext(ctx, t.options.FFmpegBinary, args...)
-
-	// Capture stderr for progress tracking
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return "", errors.Wrap(err, errors.TranscodingError, "Failed to create stderr pipe", 11)
-	}
-
-	// Start command
-	if err := cmd.Start(); err != nil {
-		if strings.Contains(err.Error(), "no such file") {
-			return "", errors.Wrap(err, errors.CodecNotFoundError, 
-				errors.GetErrorMessage(errors.ErrMissingDependency), 
-				errors.ErrMissingDependency)
-		}
-		return "", errors.Wrap(err, errors.TranscodingError, "Failed to start FFmpeg", 12)
-	}
-
-	// Start progress reader in a goroutine
-	go func() {
-		t.trackProgress(stderr)
-	}()
-
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
-		var exitErr *exec.ExitError
-		if stderrors.As(err, &exitErr) {
-			// Analisar o código de saída para determinar o tipo de erro
-			if exitErr.ExitCode() == 1 {
-				// Verificar se há problemas comuns de codec
-				errOutput := string(exitErr.Stderr)
-				if strings.Contains(errOutput, "Unknown encoder") {
-					return "", errors.Wrap(err, errors.CodecNotFoundError, 
-						errors.GetErrorMessage(errors.ErrCodecNotFound), 
-						errors.ErrCodecNotFound)
-				}
-				
-				// Verificar se há problemas de memória
-				if strings.Contains(errOutput, "Cannot allocate memory") || 
-				   strings.Contains(errOutput, "out of memory") {
-					return "", errors.Wrap(err, errors.MemoryError, 
-						errors.GetErrorMessage(errors.ErrOutOfMemory), 
-						errors.ErrOutOfMemory)
-				}
-				
-				// Verificar problemas com o formato do arquivo
-				if strings.Contains(errOutput, "Invalid data") || 
-				   strings.Contains(errOutput, "could not find codec parameters") {
-					return "", errors.Wrap(err, errors.InvalidFileFormatError, 
-						errors.GetErrorMessage(errors.ErrCorruptedFile), 
-						errors.ErrCorruptedFile)
-				}
-			}
-		}
-		
-		return "", errors.Wrap(err, errors.TranscodingError, "FFmpeg process failed", 13)
+	// Encode using the configured backend
+	audioCodec, _ := t.resolveAudioCodec(ctx, inputPath)
+	if _, err := t.encode(ctx, encoder.Job{
+		Format:         encoder.FormatMP4,
+		InputPath:      inputPath,
+		OutputPath:     outputPath,
+		ExtraParams:     t.options.FFmpegExtraParams,
+		InputParams:     t.options.FFmpegInputParams,
+		EncoderSettings: t.options.encoderSettings(),
+		LogOutput:       t.options.LogFFmpegOutput,
+		RealtimePacing:  t.options.RealtimePacing,
+		Deterministic:  t.options.Deterministic,
+		ClosedCaptions: t.inputHasCaptions(ctx, inputPath),
+		Deinterlace:    t.deinterlacer(ctx, inputPath),
+		Color:          t.colorSettings(ctx, inputPath),
+		Height:         height,
+		AudioCodec:     audioCodec,
+		Duration:       t.inputDuration(ctx, inputPath),
+		Progress:       t.progRep,
+	}); err != nil {
+		return "", err
 	}
 
 	// Check if output file exists
@@ -780,39 +1684,9 @@ func (t *Transcoder) transcodeToMP4(ctx context.Context, inputPath, outputPath s
 	return outputPath, nil
 }
 
-// trackProgress lê a saída do FFmpeg em stderr e atualiza o progresso da transcodificação
-func (t *Transcoder) trackProgress(stderr io.ReadCloser) {
-	scanner := bufio.NewScanner(stderr)
-	timeRegex := regexp.MustCompile(`time=(\d+):(\d+):(\d+\.\d+)`)
-	
-	for scanner.Scan() {
-		line := scanner.Text()
-		
-		// Log FFmpeg output
-		t.logger.Debug(line, "ffmpeg", nil)
-		
-		// Se não temos reporter de progresso, apenas continue registrando a saída
-		if t.progRep == nil {
-			continue
-		}
-		
-		// Procurar informações de tempo no formato HH:MM:SS.MS
-		if matches := timeRegex.FindStringSubmatch(line); len(matches) > 3 {
-			hours, _ := strconv.Atoi(matches[1])
-			minutes, _ := strconv.Atoi(matches[2])
-			seconds, _ := strconv.ParseFloat(matches[3], 64)
-			
-			// Converter para segundos
-			currentTime := float64(hours*3600) + float64(minutes*60) + seconds
-			
-			// Atualizar o progresso (valor entre 0-100 ou valor absoluto em segundos)
-			t.progRep.Update(int64(currentTime), "transcoding", fmt.Sprintf("Processando: %02d:%02d:%05.2f", hours, minutes, seconds))
-		}
-	}
-}
-
-// createHLSStreams converts the input to HLS format with multiple quality levels
-func (t *Transcoder) createHLSStreams(ctx context.Context, inputPath, outputPath string) (string, error) {
+// createHLSStreams converts the input to HLS format with multiple quality levels,
+// the given resolutions, publishing the partial output if publish is set
+func (t *Transcoder) createHLSStreams(ctx context.Context, inputPath, outputPath string, resolutions []hls.VideoResolution, publish bool) (string, error) {
 	t.logger.Info("Creating HLS adaptive streams", "transcoder", map[string]interface{}{
 		"input":  inputPath,
 		"output": outputPath,
@@ -826,22 +1700,14 @@ func (t *Transcoder) createHLSStreams(ctx context.Context, inputPath, outputPath
 				errors.ErrWritePermissionDenied)
 		}
 		
-		// Verificar espaço em disco
-		var stat unix.Statfs_t
-		if unix.Statfs(outputPath, &stat) == nil {
-			freeSpace := stat.Bavail * uint64(stat.Bsize)
-			// Para HLS precisamos de mais espaço (múltiplas resoluções)
-			if freeSpace < uint64(1024*1024*1024) { // 1GB mínimo
-				return "", errors.New(errors.DiskSpaceError, 
-					errors.GetErrorMessage(errors.ErrDiskSpaceInsufficient), 
-					fmt.Sprintf("Espaço livre: %d bytes", freeSpace), 
-					errors.ErrDiskSpaceInsufficient)
-			}
-		}
-		
 		return "", errors.Wrap(err, errors.SystemError, "Failed to create output directory", 15)
 	}
 
+	// Verificar espaço em disco (HLS precisa de mais espaço: múltiplas resoluções)
+	if err := t.checkDiskSpace(outputPath, diskspace.DefaultMinFreeSpaceHLS); err != nil {
+		return "", err
+	}
+
 	// Verificar se o caminho é acessível para escrita
 	testFile := filepath.Join(outputPath, "test_write_permission.tmp")
 	tmpFile, err := os.Create(testFile)
@@ -859,7 +1725,7 @@ func (t *Transcoder) createHLSStreams(ctx context.Context, inputPath, outputPath
 	os.Remove(testFile)
 
 	// Verificar resoluções
-	for _, res := range t.options.HLSResolutions {
+	for _, res := range resolutions {
 		if res.Width <= 0 || res.Height <= 0 {
 			return "", errors.New(errors.UnsupportedResolutionError, 
 				errors.GetErrorMessage(errors.ErrInvalidResolution), 
@@ -884,24 +1750,64 @@ func (t *Transcoder) createHLSStreams(ctx context.Context, inputPath, outputPath
 		}
 	}
 
-	// Set HLS options
-	hlsOptions := hls.Options{
-		InputFile:         inputPath,
-		OutputDir:         outputPath,
-		SegmentDuration:   t.options.HLSSegmentDuration,
-		PlaylistType:      t.options.HLSPlaylistType,
-		Resolutions:       t.options.HLSResolutions,
-		FFmpegBinary:      t.options.FFmpegBinary,
-		FFmpegExtraParams: t.options.FFmpegExtraParams,
-		Progress:          t.progRep,
+	captions := t.inputHasCaptions(ctx, inputPath)
+	deinterlace := t.deinterlacer(ctx, inputPath)
+	color := t.colorSettings(ctx, inputPath)
+	audioCodec, audioTag := t.resolveAudioCodec(ctx, inputPath)
+
+	// Codificar o vídeo em partes paralelas, empacotadas depois sem recodificar
+	var videoLists []string
+	reporter := t.progRep
+	if t.options.ChunkedEncoding {
+		workDir := filepath.Join(outputPath, chunksDir)
+		defer os.RemoveAll(workDir)
+		if videoLists, err = t.encodeChunks(ctx, inputPath, workDir, resolutions, captions, deinterlace, color); err != nil {
+			return "", err
+		}
+		reporter = nil
 	}
 
-	// Create HLS generator
-	hlsGen := hls.New(hlsOptions)
+	// Publicar a saída parcial durante a codificação, com playlists EVENT
+	playlistType := t.options.HLSPlaylistType
+	publishing := publish && t.uploader != nil
+	stopPublishing := func() {}
+	if publishing {
+		playlistType = "event"
+		stopPublishing = t.startPublishing(ctx, outputPath)
+	}
 
-	// Generate HLS streams
-	masterPlaylistPath, err := hlsGen.CreateHLS(ctx)
+	// Generate HLS streams using the configured backend
+	masterPlaylistPath, err := t.encode(ctx, encoder.Job{
+		Format:           encoder.FormatHLS,
+		InputPath:        inputPath,
+		OutputPath:       outputPath,
+		Resolutions:      resolutions,
+		SegmentDuration:  t.options.HLSSegmentDuration,
+		PlaylistType:     playlistType,
+		ExtraParams:      t.options.FFmpegExtraParams,
+		InputParams:      t.options.FFmpegInputParams,
+		EncoderSettings:  t.options.encoderSettings(),
+		LogOutput:        t.options.LogFFmpegOutput,
+		RealtimePacing:   t.options.RealtimePacing,
+		Deterministic:    t.options.Deterministic,
+		ClosedCaptions:   captions,
+		AudioCodec:       audioCodec,
+		SharedAudio:      t.options.HLSSharedAudio,
+		Version:          t.options.HLSVersion,
+		ByteRange:        t.options.HLSByteRange,
+		MPEGTS:           t.options.HLSMPEGTS,
+		CaptionLanguage:  t.captionLanguage(),
+		Deinterlace:      deinterlace,
+		Color:            color,
+		VideoConcatLists: videoLists,
+		Progress:         reporter,
+	})
+	stopPublishing()
 	if err != nil {
+		if isStageTimeout(err) {
+			return "", err
+		}
+
 		// Analisar a mensagem de erro para fornecer mais detalhes
 		errMsg := err.Error()
 		
@@ -936,6 +1842,65 @@ func (t *Transcoder) createHLSStreams(ctx context.Context, inputPath, outputPath
 	if _, err := os.Stat(masterPlaylistPath); os.IsNotExist(err) {
 		return "", errors.New(errors.HLSError, "Master playlist não foi criado", outputPath, 17)
 	}
+	if t.options.ChunkedEncoding && t.progRep != nil {
+		t.progRep.Complete()
+	}
+
+	// Converter as playlists publicadas como EVENT em VOD
+	if publishing && t.options.HLSPlaylistType != "event" {
+		if err := hls.ConvertEventToVOD(outputPath); err != nil {
+			return "", errors.Wrap(err, errors.HLSError, "Failed to convert the event playlists to VOD", errors.ErrPlaylistConversionFailed)
+		}
+	}
+
+	// Declarar a versão do protocolo pedida em todas as playlists
+	if t.options.HLSVersion != 0 {
+		if err := hls.SetPlaylistVersions(outputPath, masterPlaylistPath, t.options.HLSVersion); err != nil {
+			return "", errors.Wrap(err, errors.HLSError, "Failed to set the playlist version", errors.ErrPlaylistVersionFailed)
+		}
+	}
+
+	// Declarar as taxas medidas nos segmentos, com o overhead do contêiner
+	if err := hls.UpdateBandwidth(masterPlaylistPath); err != nil {
+		return "", errors.Wrap(err, errors.HLSError, "Failed to update the bandwidth in the master playlist", errors.ErrBandwidthUpdateFailed)
+	}
+
+	// Sinalizar o codec de áudio no master playlist: o Dolby, ou qualquer codec
+	// do grupo de áudio compartilhado, que o ffmpeg não lista nas variantes
+	if audioTag == "" && t.options.HLSSharedAudio {
+		audioTag = hls.AudioCodecTag(string(hls.AudioAAC))
+	}
+	if audioTag != "" {
+		if err := hls.SetAudioCodec(masterPlaylistPath, audioTag); err != nil {
+			return "", errors.Wrap(err, errors.HLSError, "Failed to signal the audio codec in the master playlist", errors.ErrAudioCodecSignalFailed)
+		}
+	}
+
+	// Copiar os marcadores SCTE-35 e ID3 da entrada para as playlists
+	if t.options.PassthroughMetadata {
+		if err := t.passthroughTimedMetadata(ctx, inputPath, outputPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Extrair as legendas ocultas para uma faixa WebVTT
+	if t.options.ExtractCaptions && captions {
+		if err := t.extractCaptions(ctx, inputPath, outputPath, masterPlaylistPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Gerar a faixa de trick-play, referenciada como stream de I-frames
+	if t.options.TrickPlay {
+		if err := t.generateTrickPlay(ctx, inputPath, outputPath, masterPlaylistPath); err != nil {
+			return "", err
+		}
+	}
+
+	// Adicionar os dados de sessão e as tags personalizadas ao master playlist
+	if err := hls.AddMasterTags(masterPlaylistPath, t.options.HLSSessionData, t.options.HLSMasterTags); err != nil {
+		return "", errors.Wrap(err, errors.HLSError, "Failed to add the custom tags to the master playlist", errors.ErrMasterTagsFailed)
+	}
 
 	t.logger.Info("HLS creation completed successfully", "transcoder", map[string]interface{}{
 		"master_playlist": masterPlaylistPath,
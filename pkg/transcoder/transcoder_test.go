@@ -2,14 +2,23 @@ package transcoder
 
 import (
 	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"strings"
+	"time"
 
 	"github.com/heyjunin/HLSpresso/pkg/downloader"
+	"github.com/heyjunin/HLSpresso/pkg/encoder"
+	"github.com/heyjunin/HLSpresso/pkg/encrypt"
 	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
 	"github.com/heyjunin/HLSpresso/pkg/logger"
 	"github.com/heyjunin/HLSpresso/pkg/progress"
 )
@@ -29,19 +38,22 @@ func newDiscardLogger() logger.Logger {
 	return &discardLogger{}
 }
 
-// mockProgressReporter simple mock
-type mockProgressReporter struct{}
+// mockProgressReporter simple mock, recording the error passed to Fail
+type mockProgressReporter struct {
+	failErr error
+}
 
 func (m *mockProgressReporter) Start(total int64)                 {}
 func (m *mockProgressReporter) Update(current int64, _, _ string) {}
 func (m *mockProgressReporter) Increment(_, _ string)             {}
 func (m *mockProgressReporter) Complete()                         {}
+func (m *mockProgressReporter) Fail(err error)                    { m.failErr = err }
 func (m *mockProgressReporter) Updates() <-chan progress.ProgressEvent {
 	ch := make(chan progress.ProgressEvent)
 	close(ch)
 	return ch
 }
-func (m *mockProgressReporter) Close()                {}
+func (m *mockProgressReporter) Close() error          { return nil }
 func (m *mockProgressReporter) JSON() (string, error) { return "{}", nil }
 
 func TestNewTranscoderValidation(t *testing.T) {
@@ -74,6 +86,89 @@ func TestNewTranscoderValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Invalid Auto Scale Policy",
+			opts: Options{
+				InputPath:       "input.mp4",
+				OutputPath:      "output/dir",
+				AutoScalePolicy: "stretch",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Auto Dimension Alignment",
+			opts: Options{
+				InputPath:              "input.mp4",
+				OutputPath:             "output/dir",
+				AutoDimensionAlignment: 3,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid HLS Version",
+			opts: Options{
+				InputPath:  "input.mp4",
+				OutputPath: "output/dir",
+				HLSVersion: 8,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid HLS Session Data",
+			opts: Options{
+				InputPath:      "input.mp4",
+				OutputPath:     "output/dir",
+				HLSSessionData: []hls.SessionData{{DataID: "com.example.id"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Master Playlist Tag",
+			opts: Options{
+				InputPath:     "input.mp4",
+				OutputPath:    "output/dir",
+				HLSMasterTags: []string{"EXT-X-FOO"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Byte Ranges Before HLS Version 4",
+			opts: Options{
+				InputPath:    "input.mp4",
+				OutputPath:   "output/dir",
+				HLSVersion:   3,
+				HLSByteRange: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "MPEG-TS Options with fMP4 Segments",
+			opts: Options{
+				InputPath:  "input.mp4",
+				OutputPath: "output/dir",
+				HLSVersion: 7,
+				HLSMPEGTS:  hls.MPEGTSOptions{ResendHeaders: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid MPEG-TS PCR Period",
+			opts: Options{
+				InputPath:  "input.mp4",
+				OutputPath: "output/dir",
+				HLSMPEGTS:  hls.MPEGTSOptions{PCRPeriod: -time.Millisecond},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid Mux Overhead",
+			opts: Options{
+				InputPath:      "input.mp4",
+				OutputPath:     "output/dir",
+				HLSMuxOverhead: 1.5,
+			},
+			wantErr: true,
+		},
 		{
 			name: "Remote without Downloader (using New)", // New creates a default downloader if needed
 			opts: Options{
@@ -378,7 +473,7 @@ func TestTranscoderHandleInput(t *testing.T) {
 				}()
 			}
 
-			trans, err := NewWithDeps(tt.opts, mockReporter, mockLogger, tt.mockDownloader)
+			trans, err := NewWithDeps(tt.opts, mockReporter, mockLogger, tt.mockDownloader, WithRunner(newProbeRunner()))
 			// Check constructor error first, as handleInput might not be reached
 			if tt.name == "Remote Input, Stream Disabled, No Downloader" {
 				if err == nil {
@@ -404,3 +499,353 @@ func TestTranscoderHandleInput(t *testing.T) {
 		})
 	}
 }
+
+// --- Test Encoder Injection ---
+
+// fakeEncoder records the jobs it receives and writes a placeholder output
+// instead of running ffmpeg.
+type fakeEncoder struct {
+	jobs []encoder.Job
+}
+
+func (f *fakeEncoder) Name() string                    { return "fake" }
+func (f *fakeEncoder) Check(ctx context.Context) error { return nil }
+func (f *fakeEncoder) Encode(ctx context.Context, job encoder.Job) (string, error) {
+	f.jobs = append(f.jobs, job)
+	return job.OutputPath, os.WriteFile(job.OutputPath, []byte("encoded"), 0644)
+}
+
+func TestTranscodeWithEncoder(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	fake := &fakeEncoder{}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "out", "video.mp4"),
+		OutputType:         MP4Output,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithEncoder(fake), WithRunner(newProbeRunner()))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	result, err := trans.Transcode(context.Background())
+	if err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+	if result != opts.OutputPath {
+		t.Errorf("Transcode() = %q, want %q", result, opts.OutputPath)
+	}
+	if len(fake.jobs) != 1 {
+		t.Fatalf("Encoder received %d jobs, want 1", len(fake.jobs))
+	}
+	if fake.jobs[0].Format != encoder.FormatMP4 || fake.jobs[0].InputPath != inputPath {
+		t.Errorf("Unexpected job: %+v", fake.jobs[0])
+	}
+}
+
+// --- Test Runner Injection ---
+
+// scriptedFFmpeg returns an ffmpegtest handler that reports the required codecs,
+// describes every input with probeJSON and, for encoding runs, writes the output
+// file and fails with encodeErr if set.
+func scriptedFFmpeg(stderr string, encodeErr error) func(name string, args []string) ffmpegtest.Result {
+	return func(name string, args []string) ffmpegtest.Result {
+		switch {
+		case name == "ffprobe":
+			return ffmpegtest.Result{Stdout: probeJSON}
+		case len(args) == 1 && args[0] == "-version":
+			return ffmpegtest.Result{Stdout: "ffmpeg version 7.1"}
+		case len(args) == 1 && args[0] == "-codecs":
+			return ffmpegtest.Result{Stdout: "DEV.LS h264 (encoders: libx264)\nDEA.L. aac"}
+		}
+		if encodeErr == nil {
+			os.WriteFile(args[len(args)-1], []byte("encoded"), 0644)
+		}
+		return ffmpegtest.Result{Stderr: stderr, Err: encodeErr}
+	}
+}
+
+func TestTranscodeWithRunner(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		stderr    string
+		encodeErr error
+		codecs    string
+		wantErr   bool
+		wantCode  int
+	}{
+		{
+			name:   "Success",
+			stderr: "frame=   25 fps=25 time=00:00:01.00 bitrate=N/A\n",
+		},
+		{
+			name:      "FFmpeg failure",
+			stderr:    "Conversion failed!\n",
+			encodeErr: stderrors.New("exit status 1"),
+			wantErr:   true,
+			wantCode:  13,
+		},
+		{
+			name:     "Missing codec",
+			codecs:   "DEA.L. aac",
+			wantErr:  true,
+			wantCode: errors.ErrCodecNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := scriptedFFmpeg(tt.stderr, tt.encodeErr)
+			runner := &ffmpegtest.Runner{
+				Handler: func(name string, args []string) ffmpegtest.Result {
+					if tt.codecs != "" && len(args) == 1 && args[0] == "-codecs" {
+						return ffmpegtest.Result{Stdout: tt.codecs}
+					}
+					return handler(name, args)
+				},
+			}
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         filepath.Join(dir, tt.name, "video.mp4"),
+				OutputType:         MP4Output,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transcode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				sErr, ok := err.(*errors.StructuredError)
+				if !ok {
+					t.Fatalf("Transcode() returned non-structured error: %T", err)
+				}
+				if sErr.Code != tt.wantCode {
+					t.Errorf("Transcode() error code = %d, want %d", sErr.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscodeOutputSizeBudget(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	handler := scriptedFFmpeg("", nil)
+	runner := &ffmpegtest.Runner{
+		Handler: func(name string, args []string) ffmpegtest.Result {
+			if name == "ffprobe" {
+				return ffmpegtest.Result{Stdout: probeJSON}
+			}
+			return handler(name, args)
+		},
+	}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         filepath.Join(dir, "out"),
+		MaxOutputSizeBytes: 1000,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+
+	_, err = trans.Transcode(context.Background())
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok {
+		t.Fatalf("Transcode() should fail with a StructuredError, got %T (%v)", err, err)
+	}
+	if sErr.Type != errors.ValidationError {
+		t.Errorf("Transcode() error type = %q, want %q", sErr.Type, errors.ValidationError)
+	}
+	for _, call := range runner.Calls() {
+		if call.Name == "ffmpeg" && len(call.Args) > 1 {
+			t.Errorf("No encode should be started when the budget cannot be met, got %v", call.Args)
+		}
+	}
+}
+
+// recordingUploader is a fake upload.Uploader recording the remote paths it receives.
+type recordingUploader struct {
+	uploaded []string
+	err      error
+}
+
+func (u *recordingUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.uploaded = append(u.uploaded, remotePath)
+	return nil
+}
+
+func TestTranscodeUploadsOutput(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	for _, uploadErr := range []error{nil, errors.New(errors.NetworkError, "Upload rejected by the origin server", "", errors.ErrNetworkServerUnavailable)} {
+		uploader := &recordingUploader{err: uploadErr}
+		opts := Options{
+			InputPath:          inputPath,
+			OutputPath:         filepath.Join(t.TempDir(), "video.mp4"),
+			OutputType:         MP4Output,
+			SkipDiskSpaceCheck: true,
+		}
+		runner := &ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}
+		reporter := &mockProgressReporter{}
+		trans, err := NewWithDeps(opts, reporter, newDiscardLogger(), nil, WithRunner(runner), WithUploader(uploader))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+
+		_, err = trans.Transcode(context.Background())
+		if uploadErr != nil {
+			if err != uploadErr {
+				t.Errorf("Transcode() error = %v, want the upload error", err)
+			}
+			if reporter.failErr != uploadErr {
+				t.Errorf("Reporter failed with %v, want the upload error", reporter.failErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Transcode() unexpected error: %v", err)
+		}
+		if len(uploader.uploaded) != 1 || uploader.uploaded[0] != "video.mp4" {
+			t.Errorf("Uploaded %v, want [video.mp4]", uploader.uploaded)
+		}
+	}
+}
+
+func TestNewInvalidUploadURL(t *testing.T) {
+	opts := Options{InputPath: "input.mp4", OutputPath: "out", UploadURL: "origin.example.com/live"}
+	if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+		t.Error("NewWithDeps() expected an error for an invalid upload URL")
+	}
+}
+
+func TestTranscodeEncryptsOutput(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	outputPath := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(inputPath, []byte("dummy video content"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	uploader := &recordingUploader{}
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputPath,
+		OutputType:         MP4Output,
+		EncryptionKey:      key,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil,
+		WithRunner(&ffmpegtest.Runner{Handler: scriptedFFmpeg("", nil)}), WithUploader(uploader))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	result, err := trans.Transcode(context.Background())
+	if err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	if result != outputPath+encrypt.Suffix {
+		t.Errorf("Transcode() = %q, want %q", result, outputPath+encrypt.Suffix)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("The plaintext output should be removed once encrypted")
+	}
+	if len(uploader.uploaded) != 1 || uploader.uploaded[0] != "video.mp4.enc" {
+		t.Errorf("Uploaded %v, want [video.mp4.enc]", uploader.uploaded)
+	}
+	decrypted, err := encrypt.DecryptFile(key, result)
+	if err != nil {
+		t.Fatalf("DecryptFile() unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(decrypted); string(content) != "encoded" {
+		t.Errorf("Decrypted output = %q, want the encoded content", content)
+	}
+
+	opts.EncryptionKey = key[:16]
+	if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+		t.Error("NewWithDeps() expected an error for a short encryption key")
+	}
+
+	// Uma falha da cifra não é uma falta de espaço em disco
+	if err := os.WriteFile(outputPath, []byte("encoded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	trans.options.EncryptionKey = key[:5]
+	var sErr *errors.StructuredError
+	if _, err := trans.encryptOutput(outputPath); !stderrors.As(err, &sErr) ||
+		sErr.Type != errors.SystemError || sErr.Code != errors.ErrEncryptionFailed {
+		t.Errorf("encryptOutput() with a bad key error = %v, want a SystemError with ErrEncryptionFailed", err)
+	}
+}
+
+func TestTranscoderHandleInputDownloadCache(t *testing.T) {
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodGet {
+			downloads++
+		}
+		fmt.Fprint(w, "video")
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	var paths []string
+	for i := 0; i < 2; i++ {
+		opts := Options{
+			InputPath:          server.URL + "/video.mp4",
+			OutputPath:         t.TempDir(),
+			DownloadDir:        t.TempDir(),
+			DownloadCacheDir:   cacheDir,
+			SkipDiskSpaceCheck: true,
+		}
+		trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), downloader.New(downloader.Options{}))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		path, err := trans.handleInput(context.Background())
+		if err != nil {
+			t.Fatalf("handleInput() unexpected error: %v", err)
+		}
+		trans.releaseCachedDownload()
+		paths = append(paths, path)
+	}
+
+	if downloads != 1 {
+		t.Errorf("The input was downloaded %d times, want 1", downloads)
+	}
+	if paths[0] != paths[1] || filepath.Dir(paths[0]) != cacheDir {
+		t.Errorf("handleInput() = %v, want the same file of %s", paths, cacheDir)
+	}
+}
@@ -0,0 +1,120 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+// trickPlayDir is the directory of the trick-play rendition in HLS outputs.
+const trickPlayDir = "trickplay"
+
+// validateTrickPlay checks the trick-play options, setting their defaults.
+func validateTrickPlay(options *Options, p *optionProblems) {
+	if !options.TrickPlay {
+		return
+	}
+	if options.TrickPlayInterval == 0 {
+		options.TrickPlayInterval = hls.DefaultTrickPlayInterval
+	}
+	if options.TrickPlayWidth == 0 {
+		options.TrickPlayWidth = hls.DefaultTrickPlayWidth
+	}
+	if options.TrickPlayInterval < hls.MinTrickPlayInterval {
+		p.add("TrickPlayInterval", errors.New(errors.ValidationError, "Invalid trick-play interval",
+			fmt.Sprintf("The interval must be at least %s, got %s", hls.MinTrickPlayInterval, options.TrickPlayInterval), 4))
+	}
+	if options.TrickPlayWidth < 64 || options.TrickPlayWidth > hls.MaxTrickPlayWidth {
+		p.add("TrickPlayWidth", errors.New(errors.ValidationError, "Invalid trick-play width",
+			fmt.Sprintf("The width must be between 64 and %d pixels, got %d", hls.MaxTrickPlayWidth, options.TrickPlayWidth), 4))
+	}
+	if options.HLSVersion != 0 && options.HLSVersion < 4 {
+		p.add("HLSVersion", errors.New(errors.ValidationError, "Invalid HLS version",
+			fmt.Sprintf("Trick-play (I-frame) playlists require HLS version 4, got %d", options.HLSVersion), 4))
+	}
+}
+
+// trickPlaySize returns the dimensions of the trick-play frames: the configured
+// width and the height keeping the aspect ratio of the input, both even.
+func (t *Transcoder) trickPlaySize(info *VideoInfo) (int, int) {
+	width := t.options.TrickPlayWidth &^ 1
+	height := 2 * int(float64(width)*float64(info.Height)/float64(info.Width)/2+0.5)
+	return width, max(height, 2)
+}
+
+// generateTrickPlay encodes the trick-play rendition of the HLS output at
+// outputDir, one keyframe every TrickPlayInterval in segments of a single
+// frame, and declares it in the master playlist as an I-frame stream (see
+// Options.TrickPlay).
+func (t *Transcoder) generateTrickPlay(ctx context.Context, inputPath, outputDir, masterPath string) error {
+	info, err := t.probeInput(ctx, inputPath)
+	if err != nil {
+		return wrapProbeError(err, errors.InvalidFileFormatError, errors.ErrInvalidFileFormat)
+	}
+	if info.Width <= 0 || info.Height <= 0 {
+		return errors.New(errors.HLSError, "Failed to generate the trick-play rendition",
+			"The dimensions of the input video are unknown", 19)
+	}
+	dir := filepath.Join(outputDir, trickPlayDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create the trick-play directory", 15)
+	}
+
+	width, height := t.trickPlaySize(info)
+	level, codecs := hls.TrickPlayLevel(width, height)
+	interval := strconv.FormatFloat(t.options.TrickPlayInterval.Seconds(), 'f', -1, 64)
+	args := []string{
+		"-v", "error",
+		"-i", inputPath,
+		"-map", "0:v:0", "-an", "-sn", "-dn",
+		"-vf", fmt.Sprintf("fps=1/%s,scale=%d:%d", interval, width, height),
+		"-c:v", "libx264", "-profile:v", "main", "-level:v", level, "-pix_fmt", "yuv420p",
+		"-g", "1", "-bf", "0",
+	}
+	if t.options.Deterministic {
+		args = append(args, hls.DeterministicArgs()...)
+	}
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", interval,
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "mpegts",
+		"-hls_segment_filename", filepath.Join(dir, "frame%05d.ts"),
+		"-y", filepath.Join(dir, "playlist.m3u8"),
+	)
+
+	trickPlayCtx, cancel := withStageTimeout(ctx, t.options.EncodeTimeout)
+	defer cancel()
+	if _, err := t.runner.Output(trickPlayCtx, t.options.FFmpegBinary, args...); err != nil {
+		if stageTimedOut(trickPlayCtx) {
+			return stageTimeoutError(err, "trick-play encoding", t.options.EncodeTimeout, errors.ErrEncodeTimeout, errors.TranscodingError)
+		}
+		return errors.Wrap(err, errors.HLSError, "Failed to encode the trick-play rendition", 19)
+	}
+
+	// Cada segmento tem um único quadro-chave: a playlist é de I-frames
+	if err := hls.MarkIFramesOnly(filepath.Join(dir, "playlist.m3u8")); err != nil {
+		return errors.Wrap(err, errors.HLSError, "Failed to write the trick-play playlist", 19)
+	}
+	stream := hls.IFrameStream{
+		URI:    trickPlayDir + "/playlist.m3u8",
+		Width:  width,
+		Height: height,
+		Codecs: codecs,
+	}
+	if err := hls.AddIFrameStream(masterPath, stream); err != nil {
+		return errors.Wrap(err, errors.HLSError, "Failed to add the trick-play rendition to the master playlist", 19)
+	}
+
+	t.logger.Info("Trick-play rendition generated", "transcoder", map[string]interface{}{
+		"interval": t.options.TrickPlayInterval.String(),
+		"width":    width,
+		"height":   height,
+	})
+	return nil
+}
@@ -0,0 +1,85 @@
+package transcoder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+	"github.com/heyjunin/HLSpresso/pkg/hls"
+)
+
+func TestTranscodeTrickPlay(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+	outputDir := filepath.Join(dir, "hls")
+
+	var trickPlayArgs []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		if name == "ffprobe" || len(args) == 1 {
+			return scriptedFFmpeg("", nil)(name, args)
+		}
+		if slices.Contains(args, "-g") {
+			trickPlayArgs = args
+			trickDir := filepath.Join(outputDir, "trickplay")
+			os.WriteFile(filepath.Join(trickDir, "playlist.m3u8"),
+				[]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:2\n#EXTINF:2.000000,\nframe00000.ts\n#EXT-X-ENDLIST\n"), 0644)
+			os.WriteFile(filepath.Join(trickDir, "frame00000.ts"), make([]byte, 5000), 0644)
+			return ffmpegtest.Result{}
+		}
+		os.WriteFile(filepath.Join(outputDir, "master.m3u8"),
+			[]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nstream_0/playlist.m3u8\n"), 0644)
+		return ffmpegtest.Result{}
+	}}
+
+	opts := Options{
+		InputPath:          inputPath,
+		OutputPath:         outputDir,
+		OutputType:         HLSOutput,
+		HLSResolutions:     []hls.VideoResolution{hls.DefaultResolutions[0]},
+		TrickPlay:          true,
+		TrickPlayInterval:  2 * time.Second,
+		TrickPlayWidth:     480,
+		SkipDiskSpaceCheck: true,
+	}
+	trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+	if err != nil {
+		t.Fatalf("NewWithDeps failed: %v", err)
+	}
+	if _, err := trans.Transcode(context.Background()); err != nil {
+		t.Fatalf("Transcode() unexpected error: %v", err)
+	}
+
+	// probeJSON is 1920x1080: 480 pixels wide keeps 16:9
+	if !slices.Contains(trickPlayArgs, "fps=1/2,scale=480:270") || !slices.Contains(trickPlayArgs, "-an") {
+		t.Errorf("Trick-play encode args = %v", trickPlayArgs)
+	}
+	playlist, _ := os.ReadFile(filepath.Join(outputDir, "trickplay", "playlist.m3u8"))
+	if !strings.Contains(string(playlist), "#EXT-X-VERSION:4\n#EXT-X-I-FRAMES-ONLY\n") {
+		t.Errorf("Trick-play playlist =\n%s", playlist)
+	}
+	master, _ := os.ReadFile(filepath.Join(outputDir, "master.m3u8"))
+	if !strings.Contains(string(master), `#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=20000,AVERAGE-BANDWIDTH=20000,RESOLUTION=480x270,CODECS="avc1.4d401e",URI="trickplay/playlist.m3u8"`) {
+		t.Errorf("Master playlist =\n%s", master)
+	}
+}
+
+func TestNewInvalidTrickPlay(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"interval":    {TrickPlay: true, TrickPlayInterval: time.Millisecond},
+		"width":       {TrickPlay: true, TrickPlayWidth: 4096},
+		"HLS version": {TrickPlay: true, HLSVersion: 3},
+	} {
+		opts.InputPath, opts.OutputPath = "input.mp4", "out"
+		if _, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+			t.Errorf("%s: NewWithDeps() expected a validation error", name)
+		}
+	}
+}
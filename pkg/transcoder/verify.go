@@ -0,0 +1,105 @@
+package transcoder
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+const (
+	// maxDecodeErrors is the number of decode errors kept in the error of a
+	// corrupted input. The others are only counted.
+	maxDecodeErrors = 50
+	// decodeErrorDetails is the number of decode errors listed in its details.
+	decodeErrorDetails = 5
+)
+
+// verifyInput decodes the whole input without encoding it, to detect
+// corruption before the real encode. It fails with ErrCorruptedFile, listing
+// the errors ffmpeg reported and the position they were found at.
+func (t *Transcoder) verifyInput(ctx context.Context, inputPath string) error {
+	t.logger.Info("Verifying the input", "transcoder", map[string]interface{}{
+		"input": redactURL(inputPath),
+	})
+
+	proc, err := t.runner.Start(ctx, t.options.FFmpegBinary,
+		"-v", "error",
+		"-stats",
+		"-i", inputPath,
+		"-f", "null",
+		"-")
+	if err != nil {
+		return errors.Wrap(err, errors.CodecNotFoundError, errors.GetErrorMessage(errors.ErrMissingDependency), errors.ErrMissingDependency)
+	}
+	decodeErrors, total := scanDecodeErrors(proc.Stderr())
+	waitErr := proc.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if waitErr == nil && total == 0 {
+		t.logger.Info("Input verified", "transcoder", nil)
+		return nil
+	}
+
+	details := fmt.Sprintf("ffmpeg could not decode the input: %v", waitErr)
+	if total > 0 {
+		details = fmt.Sprintf("%d decode errors: %s", total, joinDecodeErrors(decodeErrors, total))
+	}
+	sErr := errors.New(errors.InvalidFileFormatError, errors.GetErrorMessage(errors.ErrCorruptedFile), details, errors.ErrCorruptedFile)
+	sErr.DecodeErrors = decodeErrors
+	t.logger.Error("The input is corrupted", "transcoder", map[string]interface{}{
+		"decode_errors": total,
+		"error":         details,
+	})
+	return sErr
+}
+
+// scanDecodeErrors reads the output of an "ffmpeg -v error -stats" decode
+// pass and returns the error lines, at most maxDecodeErrors, each with the
+// position of the last statistics line, and the number of errors.
+func scanDecodeErrors(r io.Reader) ([]errors.DecodeError, int) {
+	var decodeErrors []errors.DecodeError
+	var position float64
+	total := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(ffmpeg.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if stats, ok := ffmpeg.ParseStats(line); ok {
+			if stats.Time >= 0 {
+				position = stats.Time.Seconds()
+			}
+			continue
+		}
+		total++
+		if len(decodeErrors) < maxDecodeErrors {
+			decodeErrors = append(decodeErrors, errors.DecodeError{Time: position, Message: line})
+		}
+	}
+	return decodeErrors, total
+}
+
+// joinDecodeErrors returns the first decodeErrorDetails errors of total on
+// one line, separated by semicolons.
+func joinDecodeErrors(decodeErrors []errors.DecodeError, total int) string {
+	var lines []string
+	for i, d := range decodeErrors {
+		if i == decodeErrorDetails {
+			break
+		}
+		lines = append(lines, d.String())
+	}
+	if total > len(lines) {
+		lines = append(lines, fmt.Sprintf("and %d more", total-len(lines)))
+	}
+	return strings.Join(lines, "; ")
+}
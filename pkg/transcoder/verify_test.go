@@ -0,0 +1,104 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+const corruptDecodeOutput = "frame=  250 fps=0.0 q=-0.0 size=N/A time=00:00:10.00 bitrate=N/A speed=20x\r" +
+	"[h264 @ 0x5581] error while decoding MB 12 34, bytestream -7\n" +
+	"frame=  500 fps=0.0 q=-0.0 size=N/A time=00:01:02.50 bitrate=N/A speed=20x\r" +
+	"[h264 @ 0x5581] concealing 1200 DC, 1200 AC, 1200 MV errors in P frame\n" +
+	"frame=  750 fps=0.0 q=-0.0 Lsize=N/A time=00:01:30.00 bitrate=N/A speed=20x\n"
+
+func TestScanDecodeErrors(t *testing.T) {
+	decodeErrors, total := scanDecodeErrors(strings.NewReader(corruptDecodeOutput))
+	want := []errors.DecodeError{
+		{Time: 10, Message: "[h264 @ 0x5581] error while decoding MB 12 34, bytestream -7"},
+		{Time: 62.5, Message: "[h264 @ 0x5581] concealing 1200 DC, 1200 AC, 1200 MV errors in P frame"},
+	}
+	if total != 2 || !slices.Equal(decodeErrors, want) {
+		t.Errorf("scanDecodeErrors() = %+v, %d; want %+v, 2", decodeErrors, total, want)
+	}
+	if got := want[1].String(); got != "[00:01:02.50] [h264 @ 0x5581] concealing 1200 DC, 1200 AC, 1200 MV errors in P frame" {
+		t.Errorf("DecodeError.String() = %q", got)
+	}
+
+	if _, total := scanDecodeErrors(strings.NewReader("frame=  750 fps=0.0 q=-0.0 time=00:01:30.00 speed=20x\n")); total != 0 {
+		t.Errorf("scanDecodeErrors() found %d errors in a clean decode", total)
+	}
+}
+
+func TestTranscodeVerifyInput(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.mp4")
+	if err := os.WriteFile(inputPath, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to create dummy input file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		result   ffmpegtest.Result
+		wantErrs int
+		wantErr  bool
+	}{
+		{name: "Clean", result: ffmpegtest.Result{Stderr: "frame=  750 fps=0.0 q=-0.0 time=00:01:30.00 speed=20x\n"}},
+		{name: "Decode errors", result: ffmpegtest.Result{Stderr: corruptDecodeOutput}, wantErrs: 2, wantErr: true},
+		{name: "Unreadable", result: ffmpegtest.Result{Err: stderrors.New("exit status 1")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripted := scriptedFFmpeg("", nil)
+			encoded := false
+			runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+				if slices.Contains(args, "-stats") && slices.Contains(args, "null") {
+					return tt.result
+				}
+				if name == "ffmpeg" && len(args) > 1 {
+					encoded = true
+				}
+				return scripted(name, args)
+			}}
+			opts := Options{
+				InputPath:          inputPath,
+				OutputPath:         filepath.Join(dir, tt.name, "video.mp4"),
+				OutputType:         MP4Output,
+				VerifyInput:        true,
+				SkipDiskSpaceCheck: true,
+			}
+			trans, err := NewWithDeps(opts, &mockProgressReporter{}, newDiscardLogger(), nil, WithRunner(runner))
+			if err != nil {
+				t.Fatalf("NewWithDeps failed: %v", err)
+			}
+
+			_, err = trans.Transcode(context.Background())
+			if !tt.wantErr {
+				if err != nil || !encoded {
+					t.Errorf("Transcode() error = %v, encoded = %v; want a successful encode", err, encoded)
+				}
+				return
+			}
+			sErr, ok := err.(*errors.StructuredError)
+			if !ok || sErr.Code != errors.ErrCorruptedFile {
+				t.Fatalf("Transcode() error = %v, want ErrCorruptedFile", err)
+			}
+			if len(sErr.DecodeErrors) != tt.wantErrs {
+				t.Errorf("DecodeErrors = %+v, want %d errors", sErr.DecodeErrors, tt.wantErrs)
+			}
+			if tt.wantErrs > 0 && !strings.Contains(sErr.Details, "[00:00:10.00] [h264 @ 0x5581] error while decoding MB 12 34") {
+				t.Errorf("Details = %q, want the timestamped decode errors", sErr.Details)
+			}
+			if encoded {
+				t.Error("A corrupted input should not be encoded")
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package transcoder
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/diskspace"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+	"github.com/heyjunin/HLSpresso/pkg/progress"
+)
+
+// DefaultYtDlpBinary is the yt-dlp executable run when Options.YtDlpBinary is
+// empty.
+const DefaultYtDlpBinary = "yt-dlp"
+
+// ytDlpFormat selects the best video and audio streams, merged, or else the
+// best file with both.
+const ytDlpFormat = "bv*+ba/b"
+
+// ytDlpProgressPrefix starts the progress lines printed by yt-dlp with
+// ytDlpProgressTemplate.
+const ytDlpProgressPrefix = "hlspresso-progress"
+
+// ytDlpProgressTemplate prints the downloaded bytes, the total (or its
+// estimate) and the speed of the download, "NA" when unknown.
+const ytDlpProgressTemplate = "download:" + ytDlpProgressPrefix +
+	" %(progress.downloaded_bytes)s %(progress.total_bytes)s %(progress.total_bytes_estimate)s %(progress.speed)s"
+
+// validateYtDlp sets the default yt-dlp binary and checks that yt-dlp is used
+// with a downloaded http(s) input.
+func validateYtDlp(options *Options, p *optionProblems) {
+	if !options.UseYtDlp {
+		return
+	}
+	if options.YtDlpBinary == "" {
+		options.YtDlpBinary = DefaultYtDlpBinary
+	}
+	if !isHTTPURL(options.InputPath) {
+		p.add("UseYtDlp", errors.New(errors.ValidationError, "Invalid yt-dlp input",
+			"yt-dlp can only fetch http(s) URLs", 4))
+	}
+	if options.StreamFromURL {
+		p.add("StreamFromURL", errors.New(errors.ValidationError, "Inputs fetched with yt-dlp cannot be streamed",
+			"Download the input (StreamFromURL false) to fetch it with yt-dlp", 4))
+	}
+}
+
+// fetchWithYtDlp downloads the media of the page at source (e.g. a video
+// platform URL) with yt-dlp into DownloadDir, reporting the download progress,
+// and returns the path of the downloaded file.
+func (t *Transcoder) fetchWithYtDlp(ctx context.Context, source string) (string, error) {
+	if err := os.MkdirAll(t.options.DownloadDir, 0755); err != nil {
+		if os.IsPermission(err) {
+			return "", errors.Wrap(err, errors.PermissionError, errors.GetErrorMessage(errors.ErrWritePermissionDenied), errors.ErrWritePermissionDenied)
+		}
+		return "", errors.Wrap(err, errors.SystemError, "Failed to create download directory", 6)
+	}
+	if err := t.checkDiskSpace(t.options.DownloadDir, diskspace.DefaultMinFreeSpace); err != nil {
+		return "", err
+	}
+
+	// yt-dlp escreve o caminho final do arquivo, após a junção dos streams, neste arquivo
+	pathFile := filepath.Join(t.options.DownloadDir, t.options.JobID+".ytdlp")
+	defer os.Remove(pathFile)
+	args := []string{
+		"--no-playlist",
+		"--quiet", "--progress", "--newline",
+		"--progress-template", ytDlpProgressTemplate,
+		"--format", ytDlpFormat,
+		"--merge-output-format", "mp4",
+		"--output", filepath.Join(t.options.DownloadDir, t.options.JobID+"-%(id)s.%(ext)s"),
+		"--print-to-file", "after_move:filepath", pathFile,
+	}
+	if t.options.MaxInputSizeBytes > 0 {
+		args = append(args, "--max-filesize", strconv.FormatInt(t.options.MaxInputSizeBytes, 10))
+	}
+	if t.options.FFmpegBinary != "ffmpeg" {
+		args = append(args, "--ffmpeg-location", t.options.FFmpegBinary)
+	}
+	args = append(args, "--", source)
+
+	t.logger.Info("Fetching the input with yt-dlp", "transcoder", map[string]interface{}{
+		"url": redactURL(source),
+	})
+	t.reportEvent(progress.EventDownloadStarted, map[string]interface{}{
+		"url":    redactURL(source),
+		"yt_dlp": true,
+	})
+	downloadStart := time.Now()
+	downloadCtx, cancel := withStageTimeout(ctx, t.options.DownloadTimeout)
+	defer cancel()
+
+	proc, err := t.runner.Start(downloadCtx, t.options.YtDlpBinary, args...)
+	if err != nil {
+		return "", errors.Wrap(err, errors.CodecNotFoundError, errors.GetErrorMessage(errors.ErrMissingDependency), errors.ErrMissingDependency)
+	}
+	lastError := t.scanYtDlpOutput(proc.Stderr())
+	if err := proc.Wait(); err != nil {
+		if stageTimedOut(downloadCtx) {
+			return "", stageTimeoutError(err, "yt-dlp download", t.options.DownloadTimeout, errors.ErrDownloadTimeout, errors.NetworkError)
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if lastError != "" {
+			err = fmt.Errorf("%w: %s", err, lastError)
+		}
+		return "", errors.Wrap(err, errors.DownloadError, "Failed to fetch the input with yt-dlp", 8)
+	}
+
+	content, err := os.ReadFile(pathFile)
+	downloadedPath := strings.TrimSpace(string(content))
+	if err != nil || downloadedPath == "" {
+		// Com --max-filesize, o yt-dlp ignora arquivos grandes demais sem falhar
+		details := "yt-dlp did not download any file"
+		if lastError != "" {
+			details += ": " + lastError
+		}
+		if t.options.MaxInputSizeBytes > 0 {
+			return "", errors.New(errors.ValidationError, errors.GetErrorMessage(errors.ErrInputTooLarge), details, errors.ErrInputTooLarge)
+		}
+		return "", errors.New(errors.DownloadError, "Failed to fetch the input with yt-dlp", details, 8)
+	}
+
+	downloaded := map[string]interface{}{
+		"path":             downloadedPath,
+		"duration_seconds": time.Since(downloadStart).Seconds(),
+	}
+	if info, err := os.Stat(downloadedPath); err == nil {
+		downloaded["bytes"] = info.Size()
+		if err := t.checkInputSize(info.Size()); err != nil {
+			return "", err
+		}
+	}
+	t.reportEvent(progress.EventDownloadCompleted, downloaded)
+	return downloadedPath, nil
+}
+
+// scanYtDlpOutput reads the standard error of yt-dlp, reporting the progress
+// lines printed with ytDlpProgressTemplate, and returns its last error line.
+func (t *Transcoder) scanYtDlpOutput(r io.Reader) string {
+	var lastError string
+	started := false
+	scanner := bufio.NewScanner(r)
+	scanner.Split(ffmpeg.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "ERROR:") {
+			lastError = line
+			continue
+		}
+		current, total, speed, ok := parseYtDlpProgress(line)
+		if !ok {
+			continue
+		}
+		if !started && total > 0 {
+			t.progRep.Start(total)
+			started = true
+		}
+		progress.UpdateBytes(t.progRep, current, total, speed, "downloading", "Downloading file with yt-dlp")
+	}
+	if started {
+		t.progRep.Complete()
+	}
+	return lastError
+}
+
+// parseYtDlpProgress parses a progress line printed with
+// ytDlpProgressTemplate, returning the downloaded bytes, the total (its
+// estimate if unknown, or 0) and the speed in bytes per second.
+func parseYtDlpProgress(line string) (current, total int64, speed float64, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 || fields[0] != ytDlpProgressPrefix {
+		return 0, 0, 0, false
+	}
+	downloaded, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	for _, field := range fields[2:4] {
+		if size, err := strconv.ParseFloat(field, 64); err == nil && size > 0 {
+			total = int64(size)
+			break
+		}
+	}
+	speed, _ = strconv.ParseFloat(fields[4], 64)
+	return int64(downloaded), total, speed, true
+}
@@ -0,0 +1,116 @@
+package transcoder
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+// byteReporter records the last byte progress reported.
+type byteReporter struct {
+	mockProgressReporter
+	current, total int64
+	speed          float64
+}
+
+func (r *byteReporter) UpdateBytes(current, total int64, bytesPerSecond float64, _, _ string) {
+	r.current, r.total, r.speed = current, total, bytesPerSecond
+}
+
+func TestParseYtDlpProgress(t *testing.T) {
+	tests := []struct {
+		line    string
+		current int64
+		total   int64
+		speed   float64
+		ok      bool
+	}{
+		{"hlspresso-progress 1024 4096 NA 512.5", 1024, 4096, 512.5, true},
+		{"hlspresso-progress 1024 NA 8192.7 NA", 1024, 8192, 0, true},
+		{"hlspresso-progress 1024 NA NA NA", 1024, 0, 0, true},
+		{"[youtube] dQw4w9WgXcQ: Downloading webpage", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		current, total, speed, ok := parseYtDlpProgress(tt.line)
+		if current != tt.current || total != tt.total || speed != tt.speed || ok != tt.ok {
+			t.Errorf("parseYtDlpProgress(%q) = %d, %d, %v, %v", tt.line, current, total, speed, ok)
+		}
+	}
+}
+
+func TestFetchWithYtDlp(t *testing.T) {
+	downloadDir := t.TempDir()
+	var args []string
+	runner := &ffmpegtest.Runner{Handler: func(name string, a []string) ffmpegtest.Result {
+		if name != "/opt/yt-dlp" {
+			return ffmpegtest.Result{}
+		}
+		args = a
+		if slices.Contains(a, "https://video.example.com/private") {
+			return ffmpegtest.Result{Stderr: "ERROR: [generic] Private video\n", Err: stderrors.New("exit status 1")}
+		}
+		// Simular o download e a impressão do caminho final
+		i := slices.Index(a, "--print-to-file")
+		path := filepath.Join(downloadDir, "job-1-dQw4w9WgXcQ.mp4")
+		os.WriteFile(path, []byte("video"), 0644)
+		os.WriteFile(a[i+2], []byte(path+"\n"), 0644)
+		return ffmpegtest.Result{Stderr: "hlspresso-progress 2048 4096 NA 1000\rhlspresso-progress 4096 4096 NA 2000\n"}
+	}}
+
+	newTranscoder := func(input string) (*Transcoder, *byteReporter) {
+		opts := Options{
+			InputPath:          input,
+			OutputPath:         t.TempDir(),
+			DownloadDir:        downloadDir,
+			JobID:              "job-1",
+			UseYtDlp:           true,
+			YtDlpBinary:        "/opt/yt-dlp",
+			SkipDiskSpaceCheck: true,
+		}
+		reporter := &byteReporter{}
+		trans, err := NewWithDeps(opts, reporter, newDiscardLogger(), nil, WithRunner(runner))
+		if err != nil {
+			t.Fatalf("NewWithDeps failed: %v", err)
+		}
+		return trans, reporter
+	}
+
+	trans, reporter := newTranscoder("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	inputPath, err := trans.handleInput(context.Background())
+	if err != nil {
+		t.Fatalf("handleInput() unexpected error: %v", err)
+	}
+	if inputPath != filepath.Join(downloadDir, "job-1-dQw4w9WgXcQ.mp4") {
+		t.Errorf("handleInput() = %q, want the file downloaded by yt-dlp", inputPath)
+	}
+	if reporter.current != 4096 || reporter.total != 4096 || reporter.speed != 2000 {
+		t.Errorf("Byte progress = %d/%d at %v, want 4096/4096 at 2000", reporter.current, reporter.total, reporter.speed)
+	}
+	for _, want := range []string{"--no-playlist", "--merge-output-format", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("yt-dlp args missing %q: %v", want, args)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(downloadDir, "job-1.ytdlp")); !os.IsNotExist(err) {
+		t.Error("The file with the downloaded path should be removed")
+	}
+
+	trans, _ = newTranscoder("https://video.example.com/private")
+	_, err = trans.handleInput(context.Background())
+	sErr, ok := err.(*errors.StructuredError)
+	if !ok || sErr.Type != errors.DownloadError || !strings.Contains(sErr.Details, "ERROR: [generic] Private video") {
+		t.Errorf("handleInput() error = %v, want a download error with the yt-dlp error", err)
+	}
+
+	if _, err := NewWithDeps(Options{InputPath: "video.mp4", OutputPath: "out", UseYtDlp: true},
+		&mockProgressReporter{}, newDiscardLogger(), nil); err == nil {
+		t.Error("NewWithDeps() expected an error for a local input fetched with yt-dlp")
+	}
+}
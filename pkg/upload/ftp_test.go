@@ -0,0 +1,166 @@
+package upload
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFTPServer is a minimal passive mode FTP server recording the commands and
+// stored files it receives.
+type fakeFTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands []string
+	files    map[string]string
+	logins   int
+}
+
+func newFakeFTPServer(t *testing.T) *fakeFTPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeFTPServer{listener: l, files: map[string]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeFTPServer) record(cmd string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, cmd)
+	if strings.HasPrefix(cmd, "PASS") {
+		s.logins++
+	}
+}
+
+func (s *fakeFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 fake FTP\r\n")
+
+	var data net.Listener
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		s.record(cmd)
+		verb, arg, _ := strings.Cut(cmd, " ")
+
+		switch verb {
+		case "USER":
+			fmt.Fprint(conn, "331 password required\r\n")
+		case "PASS":
+			fmt.Fprint(conn, "230 logged in\r\n")
+		case "TYPE":
+			fmt.Fprint(conn, "200 binary\r\n")
+		case "MKD":
+			fmt.Fprintf(conn, "257 \"%s\" created\r\n", arg)
+		case "EPSV":
+			data, _ = net.Listen("tcp", "127.0.0.1:0")
+			fmt.Fprintf(conn, "229 Entering Extended Passive Mode (|||%d|)\r\n", data.Addr().(*net.TCPAddr).Port)
+		case "STOR":
+			fmt.Fprint(conn, "150 ok\r\n")
+			dc, err := data.Accept()
+			if err != nil {
+				return
+			}
+			content, _ := io.ReadAll(dc)
+			dc.Close()
+			data.Close()
+			s.mu.Lock()
+			s.files[arg] = string(content)
+			s.mu.Unlock()
+			fmt.Fprint(conn, "226 transfer complete\r\n")
+		case "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "502 not implemented\r\n")
+		}
+	}
+}
+
+func TestFTPUploader(t *testing.T) {
+	srv := newFakeFTPServer(t)
+	u, err := NewFTPUploader(FTPOptions{URL: "ftp://partner:secret@" + srv.listener.Addr().String() + "/incoming/"})
+	if err != nil {
+		t.Fatalf("NewFTPUploader() unexpected error: %v", err)
+	}
+
+	uploaded, err := UploadDir(context.Background(), u, newOutputDir(t))
+	if err != nil {
+		t.Fatalf("UploadDir() unexpected error: %v", err)
+	}
+	u.Close()
+
+	if len(uploaded) != 3 {
+		t.Errorf("UploadDir() uploaded %v, want 3 files", uploaded)
+	}
+	wantFiles := map[string]string{
+		"/incoming/master.m3u8":            "#EXTM3U master",
+		"/incoming/stream_0/playlist.m3u8": "#EXTM3U variant",
+		"/incoming/stream_0/data000.ts":    "segment",
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !reflect.DeepEqual(srv.files, wantFiles) {
+		t.Errorf("stored files = %v, want %v", srv.files, wantFiles)
+	}
+	// The control connection is reused between uploads and each directory is created once.
+	if srv.logins != 1 {
+		t.Errorf("logged in %d times, want 1 (pooled connection)", srv.logins)
+	}
+	mkd := 0
+	for _, cmd := range srv.commands {
+		if strings.HasPrefix(cmd, "MKD") {
+			mkd++
+		}
+	}
+	if mkd != 2 {
+		t.Errorf("sent %d MKD commands, want 2: %v", mkd, srv.commands)
+	}
+}
+
+func TestFTPUploaderConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	u, err := NewFTPUploader(FTPOptions{URL: "ftp://" + addr + "/"})
+	if err != nil {
+		t.Fatalf("NewFTPUploader() unexpected error: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(file, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Upload(context.Background(), file, "video.mp4"); err == nil {
+		t.Error("Upload() expected an error when the server is unreachable")
+	}
+}
@@ -0,0 +1,199 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// HTTPOptions contains settings for an HTTPUploader.
+type HTTPOptions struct {
+	// BaseURL is the destination root; remote paths are appended to it
+	// (e.g. "https://origin.example.com/live/event1/").
+	BaseURL string
+	// Headers are added to every request, e.g. {"Authorization": "Bearer ..."}.
+	Headers map[string]string
+	// CreateCollections sends a WebDAV MKCOL request for each remote directory
+	// before uploading into it. Needed by WebDAV servers that do not create
+	// missing directories on PUT (nginx does with create_full_put_path).
+	CreateCollections bool
+	// ContentMD5 sends the base64 MD5 digest of each file in a Content-MD5
+	// header, which S3 and other origins check to reject corrupted uploads.
+	ContentMD5 bool
+	// Client is the HTTP client used for the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HTTPUploader uploads files with HTTP PUT requests, as accepted by WebDAV
+// servers and HTTP ingest origins.
+type HTTPUploader struct {
+	options HTTPOptions
+	base    *url.URL
+
+	mu          sync.Mutex
+	collections map[string]bool
+}
+
+// NewHTTPUploader creates an HTTPUploader for options.BaseURL.
+// Returns an error if the URL is not an absolute http or https URL.
+func NewHTTPUploader(options HTTPOptions) (*HTTPUploader, error) {
+	base, err := url.Parse(options.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, errors.New(errors.ValidationError, "Invalid upload URL",
+			fmt.Sprintf("%q is not an absolute http(s) URL", options.BaseURL), 1)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	return &HTTPUploader{
+		options:     options,
+		base:        base,
+		collections: map[string]bool{},
+	}, nil
+}
+
+// Upload sends localPath to remotePath with a PUT request, creating the parent
+// collections first if CreateCollections is set.
+func (u *HTTPUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	if u.options.CreateCollections {
+		if err := u.createCollections(ctx, path.Dir(remotePath)); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrap(err, errors.FileNotFoundError, errors.GetErrorMessage(errors.ErrFileNotFound), errors.ErrFileNotFound)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, errors.FileNotFoundError, errors.GetErrorMessage(errors.ErrFileNotAccessible), errors.ErrFileNotAccessible)
+	}
+
+	var digest string
+	if u.options.ContentMD5 {
+		hash := md5.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return errors.Wrap(err, errors.FileNotFoundError, errors.GetErrorMessage(errors.ErrFileNotAccessible), errors.ErrFileNotAccessible)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrap(err, errors.FileNotFoundError, errors.GetErrorMessage(errors.ErrFileNotAccessible), errors.ErrFileNotAccessible)
+		}
+		digest = base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	}
+
+	req, err := u.newRequest(ctx, http.MethodPut, remotePath, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	if digest != "" {
+		req.Header.Set("Content-MD5", digest)
+	}
+	if contentType := contentType(remotePath); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return u.do(req, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+// createCollections sends MKCOL for dir and each of its parents, outermost first,
+// skipping the ones already created by this uploader.
+func (u *HTTPUploader) createCollections(ctx context.Context, dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	for i := range parts {
+		collection := strings.Join(parts[:i+1], "/") + "/"
+
+		u.mu.Lock()
+		done := u.collections[collection]
+		u.mu.Unlock()
+		if done {
+			continue
+		}
+
+		req, err := u.newRequest(ctx, "MKCOL", collection, nil)
+		if err != nil {
+			return err
+		}
+		// 405 Method Not Allowed means the collection already exists
+		if err := u.do(req, http.StatusCreated, http.StatusOK, http.StatusNoContent, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.collections[collection] = true
+		u.mu.Unlock()
+	}
+	return nil
+}
+
+// newRequest builds a request for remotePath relative to the base URL, with the
+// configured headers.
+func (u *HTTPUploader) newRequest(ctx context.Context, method, remotePath string, body io.Reader) (*http.Request, error) {
+	target := u.base.ResolveReference(&url.URL{Path: strings.TrimPrefix(remotePath, "/")})
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ValidationError, "Invalid upload request", 2)
+	}
+	for name, value := range u.options.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// do sends req and checks that the response status is one of accepted.
+func (u *HTTPUploader) do(req *http.Request, accepted ...int) error {
+	resp, err := u.options.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkConnectionFailed), errors.ErrNetworkConnectionFailed)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	for _, status := range accepted {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	return errors.New(errors.NetworkError, "Upload rejected by the origin server",
+		fmt.Sprintf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status),
+		errors.ErrNetworkServerUnavailable)
+}
+
+// contentType returns the MIME type sent for the files HLSpresso generates.
+func contentType(remotePath string) string {
+	switch strings.ToLower(path.Ext(remotePath)) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".mp4":
+		return "video/mp4"
+	case ".json":
+		return "application/json"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,52 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// DefaultRetryDelay is the delay before the first retry of a failed upload.
+const DefaultRetryDelay = time.Second
+
+// retryUploader retries failed uploads of the wrapped Uploader.
+type retryUploader struct {
+	uploader Uploader
+	retries  int
+	delay    time.Duration
+}
+
+// WithRetry wraps u so that a failed upload is retried up to retries times,
+// doubling delay between attempts (DefaultRetryDelay if zero). Uploads are not
+// retried once ctx is done. The returned Uploader implements io.Closer,
+// closing u if it does.
+func WithRetry(u Uploader, retries int, delay time.Duration) Uploader {
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	return &retryUploader{uploader: u, retries: retries, delay: delay}
+}
+
+// Upload implements Uploader.
+func (r *retryUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	delay := r.delay
+	err := r.uploader.Upload(ctx, localPath, remotePath)
+	for attempt := 0; err != nil && attempt < r.retries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		err = r.uploader.Upload(ctx, localPath, remotePath)
+	}
+	return err
+}
+
+// Close implements io.Closer.
+func (r *retryUploader) Close() error {
+	if c, ok := r.uploader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package upload
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// DefaultSFTPControlPersist is how long, in seconds, the shared SSH connection
+// stays open after the last upload.
+const DefaultSFTPControlPersist = 60
+
+// SFTPOptions contains settings for an SFTPUploader.
+type SFTPOptions struct {
+	// URL is the destination root, e.g. "sftp://user@sftp.example.com:2222/incoming/".
+	// Passwords are not supported: authentication uses SSH keys (and the SSH agent).
+	URL string
+	// IdentityFile is an optional private key passed to sftp with -i.
+	IdentityFile string
+	// Binary is the OpenSSH sftp client to run. Defaults to "sftp".
+	Binary string
+	// ControlPersist is how long, in seconds, the shared SSH connection stays open
+	// after the last upload. Defaults to DefaultSFTPControlPersist.
+	ControlPersist int
+	// Runner launches the sftp processes. Defaults to ffmpeg.ExecRunner.
+	Runner ffmpeg.Runner
+}
+
+// SFTPUploader uploads files with the OpenSSH sftp client in batch mode.
+// Uploads share one SSH connection through OpenSSH connection multiplexing
+// (ControlMaster), so each file does not pay for a new SSH handshake.
+// Remote directories are created as needed.
+type SFTPUploader struct {
+	options     SFTPOptions
+	destination string
+	port        string
+	root        string
+}
+
+// NewSFTPUploader creates an SFTPUploader for options.URL.
+// Returns an error if the URL is not an sftp:// URL or contains a password.
+func NewSFTPUploader(options SFTPOptions) (*SFTPUploader, error) {
+	u, err := url.Parse(options.URL)
+	if err != nil || u.Scheme != "sftp" || u.Host == "" {
+		return nil, errors.New(errors.ValidationError, "Invalid upload URL",
+			fmt.Sprintf("%q is not an sftp:// URL", options.URL), 1)
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return nil, errors.New(errors.ValidationError, "Invalid upload URL",
+			"SFTP passwords are not supported, use SSH keys instead", 1)
+	}
+	if options.Binary == "" {
+		options.Binary = "sftp"
+	}
+	if options.ControlPersist <= 0 {
+		options.ControlPersist = DefaultSFTPControlPersist
+	}
+	if options.Runner == nil {
+		options.Runner = ffmpeg.ExecRunner{}
+	}
+
+	destination := u.Hostname()
+	if u.User != nil {
+		destination = u.User.Username() + "@" + destination
+	}
+
+	return &SFTPUploader{
+		options:     options,
+		destination: destination,
+		port:        u.Port(),
+		root:        u.Path,
+	}, nil
+}
+
+// Upload stores localPath at remotePath, relative to the URL path, creating the
+// remote directories first.
+func (u *SFTPUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	target := path.Join("/", u.root, remotePath)
+
+	// Directories are created with "-mkdir", which ignores "already exists" errors
+	var batch strings.Builder
+	current := ""
+	for _, part := range strings.Split(strings.Trim(path.Dir(target), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current += "/" + part
+		fmt.Fprintf(&batch, "-mkdir %s\n", quoteSFTP(current))
+	}
+	fmt.Fprintf(&batch, "put %s %s\n", quoteSFTP(localPath), quoteSFTP(target))
+
+	batchFile, err := os.CreateTemp("", "hlspresso-sftp-*.batch")
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create SFTP batch file", 2)
+	}
+	defer os.Remove(batchFile.Name())
+	_, err = batchFile.WriteString(batch.String())
+	if closeErr := batchFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to create SFTP batch file", 2)
+	}
+
+	if _, err := u.options.Runner.Output(ctx, u.options.Binary, u.args(batchFile.Name())...); err != nil {
+		details := err.Error()
+		var exitErr *exec.ExitError
+		if stderrors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			details = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return errors.New(errors.NetworkError, "SFTP upload failed",
+			fmt.Sprintf("%s: %s", target, details), errors.ErrNetworkServerUnavailable)
+	}
+	return nil
+}
+
+// args returns the sftp arguments running batchFile with a shared connection.
+func (u *SFTPUploader) args(batchFile string) []string {
+	args := []string{
+		"-b", batchFile,
+		"-o", "BatchMode=yes",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(os.TempDir(), "hlspresso-ssh-%C"),
+		"-o", fmt.Sprintf("ControlPersist=%d", u.options.ControlPersist),
+	}
+	if u.port != "" {
+		args = append(args, "-P", u.port)
+	}
+	if u.options.IdentityFile != "" {
+		args = append(args, "-i", u.options.IdentityFile)
+	}
+	return append(args, u.destination)
+}
+
+// quoteSFTP quotes a path for an sftp batch file.
+func quoteSFTP(p string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(p, `\`, `\\`), `"`, `\"`) + `"`
+}
@@ -0,0 +1,125 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+)
+
+// Uploader publishes local files to a remote destination such as an origin server.
+type Uploader interface {
+	// Upload sends the file at localPath to remotePath, a slash separated path
+	// relative to the destination root (e.g. "stream_0/data000.ts").
+	Upload(ctx context.Context, localPath, remotePath string) error
+}
+
+// Options selects and configures an Uploader created with New.
+type Options struct {
+	// URL is the destination root. Its scheme selects the backend:
+	// http:// and https:// (HTTPUploader), ftp:// (FTPUploader) or sftp:// (SFTPUploader).
+	URL string
+	// Headers are added to every HTTP request. Only used for http(s) URLs.
+	Headers map[string]string
+	// CreateCollections sends WebDAV MKCOL requests for the remote directories.
+	// Only used for http(s) URLs.
+	CreateCollections bool
+	// ContentMD5 sends a Content-MD5 header with every request. Only used for
+	// http(s) URLs.
+	ContentMD5 bool
+	// IdentityFile is the SSH private key. Only used for sftp URLs.
+	IdentityFile string
+	// Retries is the number of times a failed upload is retried (see WithRetry).
+	Retries int
+	// RetryDelay is the delay before the first retry. Defaults to DefaultRetryDelay.
+	RetryDelay time.Duration
+}
+
+// New creates the Uploader for options.URL, retrying failed uploads if
+// options.Retries is set. Returns an error for unsupported or invalid URLs.
+// The returned Uploader may hold connections; close it with io.Closer when done.
+func New(options Options) (Uploader, error) {
+	var uploader Uploader
+	var err error
+	switch scheme, _, _ := strings.Cut(options.URL, "://"); strings.ToLower(scheme) {
+	case "http", "https":
+		uploader, err = NewHTTPUploader(HTTPOptions{
+			BaseURL:           options.URL,
+			Headers:           options.Headers,
+			CreateCollections: options.CreateCollections,
+			ContentMD5:        options.ContentMD5,
+		})
+	case "ftp":
+		uploader, err = NewFTPUploader(FTPOptions{URL: options.URL})
+	case "sftp":
+		uploader, err = NewSFTPUploader(SFTPOptions{URL: options.URL, IdentityFile: options.IdentityFile})
+	default:
+		return nil, errors.New(errors.ValidationError, "Invalid upload URL",
+			fmt.Sprintf("%q: supported schemes are http, https, ftp and sftp", options.URL), 1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Retries > 0 {
+		uploader = WithRetry(uploader, options.Retries, options.RetryDelay)
+	}
+	return uploader, nil
+}
+
+// UploadDir uploads every file under dir with u, keeping the directory layout.
+// Media segments and other files are sent first, then variant playlists, and the
+// top-level playlists (e.g. master.m3u8) last, so players fetching a playlist
+// never see references to files that are not uploaded yet.
+// Returns the remote paths of the uploaded files in upload order.
+func UploadDir(ctx context.Context, u Uploader, dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.FileNotFoundError, "Failed to list files to upload", errors.ErrFileNotAccessible)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return uploadRank(files[i]) < uploadRank(files[j])
+	})
+
+	for i, remotePath := range files {
+		if err := ctx.Err(); err != nil {
+			return files[:i], err
+		}
+		if err := u.Upload(ctx, filepath.Join(dir, filepath.FromSlash(remotePath)), remotePath); err != nil {
+			return files[:i], err
+		}
+	}
+	return files, nil
+}
+
+// uploadRank orders files for UploadDir: media and other files (0), variant
+// playlists in subdirectories (1), top-level playlists (2).
+func uploadRank(remotePath string) int {
+	if !strings.EqualFold(path.Ext(remotePath), ".m3u8") {
+		return 0
+	}
+	if strings.Contains(remotePath, "/") {
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,217 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingOrigin is a fake WebDAV origin recording the requests it receives.
+type recordingOrigin struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]string
+	headers  http.Header
+	status   int
+}
+
+func (o *recordingOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, r.Method+" "+r.URL.Path)
+	o.headers = r.Header.Clone()
+	if r.Method == http.MethodPut {
+		body, _ := io.ReadAll(r.Body)
+		o.bodies[r.URL.Path] = string(body)
+	}
+	if o.status != 0 {
+		w.WriteHeader(o.status)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func newOutputDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"master.m3u8":            "#EXTM3U master",
+		"stream_0/playlist.m3u8": "#EXTM3U variant",
+		"stream_0/data000.ts":    "segment",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestUploadDirHTTP(t *testing.T) {
+	origin := &recordingOrigin{bodies: map[string]string{}}
+	srv := httptest.NewServer(origin)
+	defer srv.Close()
+
+	u, err := NewHTTPUploader(HTTPOptions{
+		BaseURL:           srv.URL + "/live/event1",
+		Headers:           map[string]string{"Authorization": "Bearer token"},
+		CreateCollections: true,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPUploader() unexpected error: %v", err)
+	}
+
+	uploaded, err := UploadDir(context.Background(), u, newOutputDir(t))
+	if err != nil {
+		t.Fatalf("UploadDir() unexpected error: %v", err)
+	}
+
+	wantUploaded := []string{"stream_0/data000.ts", "stream_0/playlist.m3u8", "master.m3u8"}
+	if !reflect.DeepEqual(uploaded, wantUploaded) {
+		t.Errorf("UploadDir() = %v, want %v", uploaded, wantUploaded)
+	}
+
+	// The collection is created once, before the first file uploaded into it.
+	wantRequests := []string{
+		"MKCOL /live/event1/stream_0/",
+		"PUT /live/event1/stream_0/data000.ts",
+		"PUT /live/event1/stream_0/playlist.m3u8",
+		"PUT /live/event1/master.m3u8",
+	}
+	if !reflect.DeepEqual(origin.requests, wantRequests) {
+		t.Errorf("requests = %v, want %v", origin.requests, wantRequests)
+	}
+	if origin.bodies["/live/event1/master.m3u8"] != "#EXTM3U master" {
+		t.Errorf("master.m3u8 body = %q", origin.bodies["/live/event1/master.m3u8"])
+	}
+	if origin.headers.Get("Authorization") != "Bearer token" {
+		t.Errorf("Authorization header = %q", origin.headers.Get("Authorization"))
+	}
+	if origin.headers.Get("Content-Type") != "application/vnd.apple.mpegurl" {
+		t.Errorf("Content-Type header = %q", origin.headers.Get("Content-Type"))
+	}
+}
+
+func TestUploadDirHTTPRejected(t *testing.T) {
+	origin := &recordingOrigin{bodies: map[string]string{}, status: http.StatusForbidden}
+	srv := httptest.NewServer(origin)
+	defer srv.Close()
+
+	u, err := NewHTTPUploader(HTTPOptions{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewHTTPUploader() unexpected error: %v", err)
+	}
+
+	uploaded, err := UploadDir(context.Background(), u, newOutputDir(t))
+	if err == nil {
+		t.Fatal("UploadDir() expected an error for a 403 response")
+	}
+	if len(uploaded) != 0 || len(origin.requests) != 1 {
+		t.Errorf("UploadDir() should stop at the first failure, uploaded %v with requests %v", uploaded, origin.requests)
+	}
+}
+
+func TestHTTPUploaderContentMD5(t *testing.T) {
+	origin := &recordingOrigin{bodies: map[string]string{}}
+	srv := httptest.NewServer(origin)
+	defer srv.Close()
+
+	u, err := NewHTTPUploader(HTTPOptions{BaseURL: srv.URL, ContentMD5: true})
+	if err != nil {
+		t.Fatalf("NewHTTPUploader() unexpected error: %v", err)
+	}
+	dir := newOutputDir(t)
+	if err := u.Upload(context.Background(), filepath.Join(dir, "master.m3u8"), "master.m3u8"); err != nil {
+		t.Fatalf("Upload() unexpected error: %v", err)
+	}
+
+	sum := md5.Sum([]byte("#EXTM3U master"))
+	if got, want := origin.headers.Get("Content-MD5"), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("Content-MD5 header = %q, want %q", got, want)
+	}
+	if origin.bodies["/master.m3u8"] != "#EXTM3U master" {
+		t.Errorf("master.m3u8 body = %q after hashing it", origin.bodies["/master.m3u8"])
+	}
+}
+
+func TestNewHTTPUploaderInvalidURL(t *testing.T) {
+	for _, base := range []string{"", "origin.example.com/live", "ftp://origin.example.com/"} {
+		if _, err := NewHTTPUploader(HTTPOptions{BaseURL: base}); err == nil {
+			t.Errorf("NewHTTPUploader(%q) expected an error", base)
+		}
+	}
+}
+
+// flakyUploader fails the first failures uploads.
+type flakyUploader struct {
+	failures int
+	calls    int
+}
+
+func (u *flakyUploader) Upload(ctx context.Context, localPath, remotePath string) error {
+	u.calls++
+	if u.calls <= u.failures {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func TestWithRetry(t *testing.T) {
+	flaky := &flakyUploader{failures: 2}
+	if err := WithRetry(flaky, 2, time.Millisecond).Upload(context.Background(), "a", "a"); err != nil {
+		t.Errorf("Upload() unexpected error after retries: %v", err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("Upload() called %d times, want 3", flaky.calls)
+	}
+
+	flaky = &flakyUploader{failures: 5}
+	if err := WithRetry(flaky, 2, time.Millisecond).Upload(context.Background(), "a", "a"); err == nil {
+		t.Error("Upload() expected an error once retries are exhausted")
+	}
+	if flaky.calls != 3 {
+		t.Errorf("Upload() called %d times, want 3", flaky.calls)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := map[string]string{
+		"https://origin.example.com/live/": "*upload.HTTPUploader",
+		"ftp://ftp.example.com/incoming/":  "*upload.FTPUploader",
+		"sftp://user@sftp.example.com/":    "*upload.SFTPUploader",
+	}
+	for rawURL, want := range tests {
+		u, err := New(Options{URL: rawURL})
+		if err != nil {
+			t.Errorf("New(%q) unexpected error: %v", rawURL, err)
+			continue
+		}
+		if got := reflect.TypeOf(u).String(); got != want {
+			t.Errorf("New(%q) = %s, want %s", rawURL, got, want)
+		}
+	}
+
+	if u, err := New(Options{URL: "ftp://ftp.example.com/", Retries: 3}); err != nil {
+		t.Errorf("New() with retries unexpected error: %v", err)
+	} else if _, ok := u.(io.Closer); !ok {
+		t.Error("New() with retries should return a closable uploader")
+	}
+
+	if _, err := New(Options{URL: "s3://bucket/prefix"}); err == nil {
+		t.Error("New() expected an error for an unsupported scheme")
+	}
+}
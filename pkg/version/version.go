@@ -0,0 +1,225 @@
+// Package version reports the build metadata of HLSpresso and checks GitHub
+// for newer releases.
+//
+// Release builds set the metadata with the linker, e.g.:
+//
+//	go build -ldflags "-X github.com/heyjunin/HLSpresso/pkg/version.Version=v1.2.0 \
+//	  -X github.com/heyjunin/HLSpresso/pkg/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/heyjunin/HLSpresso/pkg/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, the values recorded by the Go toolchain (module version and VCS
+// stamp) are used when available.
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg"
+)
+
+// Build metadata, set with -ldflags "-X ...".
+var (
+	// Version is the semantic version of the build (e.g. "v1.2.0").
+	Version = "dev"
+	// Commit is the git commit the binary was built from.
+	Commit = ""
+	// BuildDate is the build time in RFC 3339 format.
+	BuildDate = ""
+)
+
+// DefaultReleasesURL is the GitHub API endpoint of the latest HLSpresso release.
+const DefaultReleasesURL = "https://api.github.com/repos/heyjunin/HLSpresso/releases/latest"
+
+// Info describes the running binary and its environment.
+type Info struct {
+	// Version is the semantic version, or "dev" for development builds.
+	Version string `json:"version"`
+	// Commit is the git commit, when known.
+	Commit string `json:"commit,omitempty"`
+	// BuildDate is the build time, when known.
+	BuildDate string `json:"build_date,omitempty"`
+	// GoVersion is the Go toolchain the binary was built with.
+	GoVersion string `json:"go_version"`
+	// Platform is the operating system and architecture, e.g. "linux/amd64".
+	Platform string `json:"platform"`
+	// FFmpegVersion is the version reported by the ffmpeg binary, empty if it
+	// was not detected.
+	FFmpegVersion string `json:"ffmpeg_version,omitempty"`
+}
+
+// Get returns the build metadata of the running binary. FFmpegVersion is not set.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, s := range build.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.BuildDate == "":
+			info.BuildDate = s.Value
+		}
+	}
+	return info
+}
+
+// String returns the multi-line, human readable form of the information.
+func (i Info) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HLSpresso %s\n", i.Version)
+	fmt.Fprintf(&b, "  commit:   %s\n", valueOr(i.Commit, "unknown"))
+	fmt.Fprintf(&b, "  built:    %s\n", valueOr(i.BuildDate, "unknown"))
+	fmt.Fprintf(&b, "  go:       %s\n", i.GoVersion)
+	fmt.Fprintf(&b, "  platform: %s\n", i.Platform)
+	fmt.Fprintf(&b, "  ffmpeg:   %s\n", valueOr(i.FFmpegVersion, "not found"))
+	return b.String()
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// FFmpegVersion runs "binary -version" through runner and returns the version
+// it reports, e.g. "6.1.1" for "ffmpeg version 6.1.1 Copyright ...".
+func FFmpegVersion(ctx context.Context, runner ffmpeg.Runner, binary string) (string, error) {
+	if runner == nil {
+		runner = ffmpeg.ExecRunner{}
+	}
+	output, err := runner.Output(ctx, binary, "-version")
+	if err != nil {
+		return "", errors.Wrap(err, errors.CodecNotFoundError,
+			errors.GetErrorMessage(errors.ErrMissingDependency),
+			errors.ErrMissingDependency)
+	}
+
+	line := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if f == "version" && i+1 < len(fields) {
+			return fields[i+1], nil
+		}
+	}
+	if line == "" {
+		return "", errors.New(errors.CodecNotFoundError, "Unexpected ffmpeg -version output", binary, errors.ErrMissingDependency)
+	}
+	return line, nil
+}
+
+// Release is the latest published release, as returned by CheckUpdate.
+type Release struct {
+	// Version is the release tag, e.g. "v1.3.0".
+	Version string `json:"version"`
+	// URL is the release page.
+	URL string `json:"url"`
+	// UpdateAvailable reports whether the release is newer than the checked version.
+	UpdateAvailable bool `json:"update_available"`
+}
+
+// CheckUpdate fetches the latest release from releasesURL (DefaultReleasesURL
+// when empty) and compares it with current. Development builds ("dev") are
+// always considered out of date.
+func CheckUpdate(ctx context.Context, client *http.Client, releasesURL, current string) (*Release, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if releasesURL == "" {
+		releasesURL = DefaultReleasesURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ValidationError, "Invalid releases URL", 1)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.NetworkError,
+			errors.GetErrorMessage(errors.ErrNetworkConnectionFailed),
+			errors.ErrNetworkConnectionFailed)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(errors.NetworkError, errors.GetErrorMessage(errors.ErrNetworkServerUnavailable),
+			fmt.Sprintf("%s answered %s", releasesURL, resp.Status), errors.ErrNetworkServerUnavailable)
+	}
+
+	var latest struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&latest); err != nil {
+		return nil, errors.Wrap(err, errors.NetworkError, "Invalid release information", errors.ErrNetworkServerUnavailable)
+	}
+	if latest.TagName == "" {
+		return nil, errors.New(errors.NetworkError, "Invalid release information", "missing tag_name", errors.ErrNetworkServerUnavailable)
+	}
+
+	return &Release{
+		Version:         latest.TagName,
+		URL:             latest.HTMLURL,
+		UpdateAvailable: current == "dev" || Compare(latest.TagName, current) > 0,
+	}, nil
+}
+
+// Compare compares two semantic versions such as "v1.2.3" and "1.10.0-rc.1",
+// returning -1, 0 or 1. A pre-release sorts before the release it precedes;
+// pre-release identifiers and build metadata are otherwise ignored.
+func Compare(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+	for i := 0; i < 3; i++ {
+		if aCore[i] != bCore[i] {
+			if aCore[i] < bCore[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre && !bPre:
+		return -1
+	case !aPre && bPre:
+		return 1
+	}
+	return 0
+}
+
+// splitVersion returns the major, minor and patch numbers of v and whether it
+// is a pre-release. Missing or invalid numbers are 0.
+func splitVersion(v string) ([3]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, _, prerelease := strings.Cut(v, "-")
+
+	var core [3]int
+	for i, part := range strings.SplitN(v, ".", 3) {
+		core[i], _ = strconv.Atoi(part)
+	}
+	return core, prerelease
+}
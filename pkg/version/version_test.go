@@ -0,0 +1,96 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/heyjunin/HLSpresso/pkg/ffmpeg/ffmpegtest"
+)
+
+func TestGet(t *testing.T) {
+	old := Version
+	Version = "v1.2.3"
+	defer func() { Version = old }()
+
+	info := Get()
+	if info.Version != "v1.2.3" {
+		t.Errorf("Version = %q, want v1.2.3", info.Version)
+	}
+	if !strings.HasPrefix(info.GoVersion, "go") || !strings.Contains(info.Platform, "/") {
+		t.Errorf("Unexpected toolchain info: %+v", info)
+	}
+	if !strings.Contains(info.String(), "HLSpresso v1.2.3\n") || !strings.Contains(info.String(), "ffmpeg:   not found") {
+		t.Errorf("Unexpected String():\n%s", info.String())
+	}
+}
+
+func TestFFmpegVersion(t *testing.T) {
+	runner := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		return ffmpegtest.Result{Stdout: "ffmpeg version 6.1.1-static https://johnvansickle.com/ffmpeg/ Copyright (c) 2000-2023\nbuilt with gcc 8\n"}
+	}}
+	got, err := FFmpegVersion(context.Background(), runner, "ffmpeg")
+	if err != nil {
+		t.Fatalf("FFmpegVersion() unexpected error: %v", err)
+	}
+	if got != "6.1.1-static" {
+		t.Errorf("FFmpegVersion() = %q, want 6.1.1-static", got)
+	}
+
+	missing := &ffmpegtest.Runner{Handler: func(name string, args []string) ffmpegtest.Result {
+		return ffmpegtest.Result{Err: exec.ErrNotFound}
+	}}
+	if _, err := FFmpegVersion(context.Background(), missing, "ffmpeg"); err == nil {
+		t.Error("FFmpegVersion() expected an error for a missing binary")
+	}
+}
+
+func TestCheckUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.3.0", "html_url": "https://github.com/heyjunin/HLSpresso/releases/tag/v1.3.0"}`))
+	}))
+	defer server.Close()
+
+	tests := map[string]bool{"v1.2.9": true, "v1.3.0": false, "v1.4.0": false, "v1.3.0-rc.1": true, "dev": true}
+	for current, want := range tests {
+		release, err := CheckUpdate(context.Background(), server.Client(), server.URL, current)
+		if err != nil {
+			t.Fatalf("CheckUpdate(%q) unexpected error: %v", current, err)
+		}
+		if release.Version != "v1.3.0" || release.UpdateAvailable != want {
+			t.Errorf("CheckUpdate(%q) = %+v, want UpdateAvailable %v", current, release, want)
+		}
+	}
+}
+
+func TestCheckUpdateServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if _, err := CheckUpdate(context.Background(), server.Client(), server.URL, "v1.0.0"); err == nil {
+		t.Fatal("CheckUpdate() expected an error for a 403 response")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v1.2", "v1.2.1", -1},
+		{"v2.0.0-rc.1", "v2.0.0", -1},
+		{"v2.0.0+build.5", "v2.0.0", 0},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
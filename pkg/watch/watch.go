@@ -0,0 +1,323 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/heyjunin/HLSpresso/pkg/errors"
+	"github.com/heyjunin/HLSpresso/pkg/logger"
+)
+
+const (
+	// DefaultStableFor is how long a file's size and modification time must stay
+	// unchanged before it is considered completely uploaded.
+	DefaultStableFor = 5 * time.Second
+	// DefaultPollInterval is how often pending files are checked for changes.
+	DefaultPollInterval = time.Second
+)
+
+// partialSuffixes lists the suffixes of temporary files written by common upload
+// tools and browsers while a transfer is in progress. They are never processed;
+// the final name appears once the transfer completes.
+var partialSuffixes = []string{".part", ".partial", ".tmp", ".crdownload", ".filepart", ".download", "~"}
+
+// Handler processes a file that has finished arriving in the watched directory.
+// Returning an error moves the file to Options.FailedDir.
+type Handler func(ctx context.Context, path string) error
+
+// Options contains settings for watching a directory.
+type Options struct {
+	// InputDir is the directory watched for new files. Subdirectories, hidden
+	// files and temporary upload files (".part", ".tmp", ...) are ignored.
+	InputDir string
+	// ArchiveDir receives the files processed successfully.
+	// Defaults to InputDir/processed.
+	ArchiveDir string
+	// FailedDir receives the files the handler failed on.
+	// Defaults to InputDir/failed.
+	FailedDir string
+	// DeleteProcessed removes files processed successfully instead of moving
+	// them to ArchiveDir.
+	DeleteProcessed bool
+	// StableFor is how long a file must stay unchanged before it is processed,
+	// so partially uploaded files are not picked up. Defaults to DefaultStableFor.
+	StableFor time.Duration
+	// PollInterval is how often pending files are checked for changes.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// SkipExisting ignores the files already in InputDir when watching starts;
+	// by default they are processed like new arrivals.
+	SkipExisting bool
+}
+
+// pendingFile is a file seen in the watched directory that has not been
+// processed yet.
+type pendingFile struct {
+	size        int64
+	modTime     time.Time
+	stableSince time.Time
+}
+
+// Watcher processes the files added to a directory one at a time, once they
+// have stopped changing, and moves them out of the way afterwards.
+type Watcher struct {
+	options Options
+	handler Handler
+	logger  logger.Logger
+	pending map[string]*pendingFile
+}
+
+// New creates a Watcher calling handler for each new file, setting defaults
+// for unspecified options.
+func New(options Options, handler Handler, log logger.Logger) *Watcher {
+	if options.ArchiveDir == "" {
+		options.ArchiveDir = filepath.Join(options.InputDir, "processed")
+	}
+	if options.FailedDir == "" {
+		options.FailedDir = filepath.Join(options.InputDir, "failed")
+	}
+	if options.StableFor <= 0 {
+		options.StableFor = DefaultStableFor
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	if log == nil {
+		log = logger.NewLogger()
+	}
+	return &Watcher{
+		options: options,
+		handler: handler,
+		logger:  log,
+		pending: make(map[string]*pendingFile),
+	}
+}
+
+// Run watches the input directory until ctx is done. Files are handled
+// sequentially; a file being handled when ctx is cancelled stays in place and
+// is picked up again by the next run.
+func (w *Watcher) Run(ctx context.Context) error {
+	info, err := os.Stat(w.options.InputDir)
+	if err != nil {
+		return errors.Wrap(err, errors.FileNotFoundError, errors.GetErrorMessage(errors.ErrDirectoryNotFound), errors.ErrDirectoryNotFound)
+	}
+	if !info.IsDir() {
+		return errors.New(errors.ValidationError, errors.GetErrorMessage(errors.ErrDirectoryNotFound),
+			fmt.Sprintf("%s is not a directory", w.options.InputDir), errors.ErrDirectoryNotFound)
+	}
+	for _, dir := range []string{w.options.ArchiveDir, w.options.FailedDir} {
+		if w.options.DeleteProcessed && dir == w.options.ArchiveDir {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrap(err, errors.PermissionError, errors.GetErrorMessage(errors.ErrOutputDirectoryCreationFailed), errors.ErrOutputDirectoryCreationFailed)
+		}
+	}
+
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to start watching the input directory", 0)
+	}
+	defer notifier.Close()
+	if err := notifier.Add(w.options.InputDir); err != nil {
+		return errors.Wrap(err, errors.SystemError, "Failed to start watching the input directory", 0)
+	}
+
+	if !w.options.SkipExisting {
+		entries, err := os.ReadDir(w.options.InputDir)
+		if err != nil {
+			return errors.Wrap(err, errors.PermissionError, errors.GetErrorMessage(errors.ErrReadPermissionDenied), errors.ErrReadPermissionDenied)
+		}
+		for _, entry := range entries {
+			w.track(filepath.Join(w.options.InputDir, entry.Name()))
+		}
+	}
+
+	w.logger.Info("Watching directory", "watch", map[string]interface{}{
+		"input_dir":  w.options.InputDir,
+		"stable_for": w.options.StableFor.String(),
+	})
+
+	ticker := time.NewTicker(w.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-notifier.Events:
+			if !ok {
+				return nil
+			}
+			switch {
+			case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+				w.track(event.Name)
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				delete(w.pending, event.Name)
+			}
+		case err, ok := <-notifier.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Warn("Directory watch error", "watch", map[string]interface{}{
+				"error": err.Error(),
+			})
+		case <-ticker.C:
+			for _, path := range w.ready() {
+				if ctx.Err() != nil {
+					return nil
+				}
+				w.process(ctx, path)
+			}
+		}
+	}
+}
+
+// ignored reports whether the file at path is never processed: hidden files
+// and temporary upload files.
+func ignored(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// track records path as pending, restarting its stability window.
+func (w *Watcher) track(path string) {
+	if ignored(path) {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	w.pending[path] = &pendingFile{size: info.Size(), modTime: info.ModTime(), stableSince: time.Now()}
+}
+
+// ready returns the pending files, in name order, whose size and modification
+// time have not changed for Options.StableFor.
+func (w *Watcher) ready() []string {
+	var paths []string
+	now := time.Now()
+	for path, file := range w.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			file.size, file.modTime, file.stableSince = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(file.stableSince) >= w.options.StableFor {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// process calls the handler for path, then archives, deletes or moves it to the
+// failed directory.
+func (w *Watcher) process(ctx context.Context, path string) {
+	delete(w.pending, path)
+	w.logger.Info("Processing file", "watch", map[string]interface{}{
+		"path": path,
+	})
+
+	if err := w.handler(ctx, path); err != nil {
+		if ctx.Err() != nil {
+			// Interrupted: leave the file for the next run
+			return
+		}
+		w.logger.Error("Failed to process file", "watch", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+		w.move(path, w.options.FailedDir)
+		return
+	}
+
+	if w.options.DeleteProcessed {
+		if err := os.Remove(path); err != nil {
+			w.logger.Warn("Failed to delete processed file", "watch", map[string]interface{}{
+				"path":  path,
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+	w.move(path, w.options.ArchiveDir)
+}
+
+// move moves path into dir, adding a numeric suffix when a file with the same
+// name is already there, and logs failures.
+func (w *Watcher) move(path, dir string) {
+	target := uniquePath(filepath.Join(dir, filepath.Base(path)))
+	if err := moveFile(path, target); err != nil {
+		w.logger.Warn("Failed to move file", "watch", map[string]interface{}{
+			"path":   path,
+			"target": target,
+			"error":  err.Error(),
+		})
+		return
+	}
+	w.logger.Debug("Moved file", "watch", map[string]interface{}{
+		"path":   path,
+		"target": target,
+	})
+}
+
+// uniquePath returns path, or path with "-1", "-2", ... inserted before the
+// extension if it already exists.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// moveFile renames src to dst, copying it when they are on different file
+// systems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,122 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// discardLogger implements logger.Logger without output.
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, string, map[string]interface{}) {}
+func (discardLogger) Info(string, string, map[string]interface{})  {}
+func (discardLogger) Warn(string, string, map[string]interface{})  {}
+func (discardLogger) Error(string, string, map[string]interface{}) {}
+func (discardLogger) Fatal(string, string, map[string]interface{}) {}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "existing.mp4"), "existing")
+
+	var mu sync.Mutex
+	var handled []string
+	done := make(chan struct{}, 3)
+	handler := func(ctx context.Context, path string) error {
+		mu.Lock()
+		handled = append(handled, filepath.Base(path))
+		mu.Unlock()
+		done <- struct{}{}
+		if filepath.Base(path) == "broken.mp4" {
+			return fmt.Errorf("transcoding failed")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := New(Options{InputDir: dir, StableFor: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond}, handler, discardLogger{})
+	result := make(chan error, 1)
+	go func() { result <- w.Run(ctx) }()
+
+	// Let the watch start before adding files
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(dir, "new.mp4"), "new")
+	writeFile(t, filepath.Join(dir, "broken.mp4"), "broken")
+	writeFile(t, filepath.Join(dir, "upload.mp4.part"), "partial")
+	writeFile(t, filepath.Join(dir, ".hidden.mp4"), "hidden")
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for files to be processed, handled %v", handled)
+		}
+	}
+	cancel()
+	if err := <-result; err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if len(handled) != 3 {
+		t.Errorf("Handled %v, want existing.mp4, new.mp4 and broken.mp4", handled)
+	}
+	for _, path := range []string{
+		filepath.Join(dir, "processed", "existing.mp4"),
+		filepath.Join(dir, "processed", "new.mp4"),
+		filepath.Join(dir, "failed", "broken.mp4"),
+		filepath.Join(dir, "upload.mp4.part"),
+		filepath.Join(dir, ".hidden.mp4"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to exist: %v", path, err)
+		}
+	}
+}
+
+func TestWatcherWaitsForStableFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "upload.mp4")
+	writeFile(t, path, "first chunk")
+
+	w := New(Options{InputDir: dir, StableFor: time.Minute}, nil, discardLogger{})
+	w.track(path)
+	if got := w.ready(); len(got) != 0 {
+		t.Fatalf("ready() = %v for a file that just arrived", got)
+	}
+
+	w.pending[path].stableSince = time.Now().Add(-2 * time.Minute)
+	if got := w.ready(); len(got) != 1 || got[0] != path {
+		t.Fatalf("ready() = %v, want [%s]", got, path)
+	}
+
+	// Still being uploaded: the stability window starts over
+	writeFile(t, path, "first chunk, second chunk")
+	if got := w.ready(); len(got) != 0 {
+		t.Errorf("ready() = %v for a file that changed", got)
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "video.mp4")
+	if got := uniquePath(path); got != path {
+		t.Errorf("uniquePath() = %s, want %s", got, path)
+	}
+	writeFile(t, path, "")
+	writeFile(t, filepath.Join(dir, "video-1.mp4"), "")
+	if got, want := uniquePath(path), filepath.Join(dir, "video-2.mp4"); got != want {
+		t.Errorf("uniquePath() = %s, want %s", got, want)
+	}
+}
@@ -129,9 +129,31 @@ func (m *MockProgressReporter) Complete() {
 	m.events = append(m.events, event)
 }
 
+// Fail marca o progresso como falho
+func (m *MockProgressReporter) Fail(err error) {
+	m.lastStatus = "failed"
+
+	percentage := 0.0
+	if m.total > 0 {
+		percentage = float64(m.current) / float64(m.total) * 100
+	}
+
+	// Registrar evento de falha
+	event := progress.ProgressEvent{
+		Status:     "failed",
+		Percentage: percentage,
+		Step:       m.lastStep,
+		Stage:      m.lastStage,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Error:      progress.NewEventError(err),
+	}
+	m.events = append(m.events, event)
+}
+
 // Close is a no-op implementation for the mock reporter to satisfy the interface.
-func (m *MockProgressReporter) Close() {
+func (m *MockProgressReporter) Close() error {
 	// No-op for mock
+	return nil
 }
 
 // Updates returns a closed channel, as the mock reporter primarily uses GetEvents().